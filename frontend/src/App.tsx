@@ -46,6 +46,13 @@ function App() {
         // eslint-disable-next-line react-hooks/exhaustive-deps
     }, []);
 
+    // Report minimize/restore so the backend can pause downloads while hidden
+    useEffect(() => {
+        const onVisibility = () => AppBinding.SetWindowVisible?.(!document.hidden);
+        document.addEventListener('visibilitychange', onVisibility);
+        return () => document.removeEventListener('visibilitychange', onVisibility);
+    }, []);
+
     const sidebarCollapsed = useSettingsStore(s => s.sidebarCollapsed);
     const setSidebarCollapsed = useSettingsStore(s => s.setSidebarCollapsed);
 
@@ -70,6 +77,19 @@ function App() {
         return cleanup;
     }, []);
 
+    // Toasts requested by the backend (e.g. TestNotification)
+    useEffect(() => {
+        const cleanups = [
+            EventsOn("notification:show", (n: any) => {
+                addToast(n.type || 'info', n.title, n.message);
+            }),
+            EventsOn("download:integrity_warning", (w: any) => {
+                addToast('error', 'Integrity check failed', `${w.path} no longer matches its checksum and was not opened.`);
+            }),
+        ];
+        return () => cleanups.forEach(c => c());
+    }, [addToast]);
+
     // openFolder and openFile trigger backend ops by ID
     // We pass addToast to useTachyon if we want it to manage some errors, or just pass it down to components
     const { downloads, addDownload, openFolder, openFile, totalSpeed, reorderDownload } = useTachyon();
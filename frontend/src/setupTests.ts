@@ -57,6 +57,7 @@ vi.mock('../wailsjs/go/app/App', () => ({
     GetAIToken: vi.fn().mockResolvedValue('mock-token'),
     SetMaxConcurrentDownloads: vi.fn(),
     SetGlobalSpeedLimit: vi.fn(),
+    SetWindowVisible: vi.fn(),
     UpdateSettings: vi.fn(),
     FactoryReset: vi.fn(),
     GetRecentAuditLogs: vi.fn().mockResolvedValue([]),
@@ -128,11 +128,20 @@ func (sm *StatsManager) GetDiskUsage() DiskUsageInfo {
 	}
 }
 
+// DefaultHistoryDays is the daily-history window of GetAnalytics.
+const DefaultHistoryDays = 7
+
 // GetAnalytics returns comprehensive analytics data
 func (sm *StatsManager) GetAnalytics() AnalyticsData {
+	return sm.GetAnalyticsForDays(DefaultHistoryDays)
+}
+
+// GetAnalyticsForDays returns the same data as GetAnalytics with the daily
+// history covering the last days days that have stats.
+func (sm *StatsManager) GetAnalyticsForDays(days int) AnalyticsData {
 	lifetime, _ := sm.GetLifetimeStats()
 	totalFiles, _ := sm.GetTotalFiles()
-	daily, _ := sm.GetDailyStats(7)
+	daily, _ := sm.GetDailyStats(days)
 	diskUsage := sm.GetDiskUsage()
 
 	return AnalyticsData{
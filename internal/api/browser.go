@@ -93,8 +93,8 @@ func (s *ControlServer) handleBrowserTrigger(w http.ResponseWriter, r *http.Requ
 	// Start Download
 	id, err := s.engine.StartDownload(params.URL, defaultPath, params.Filename, options)
 	if err != nil {
-		s.audit.Log("127.0.0.1", r.UserAgent(), "POST /v1/browser/trigger", 500, err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		code := writeStartError(w, err)
+		s.audit.Log("127.0.0.1", r.UserAgent(), "POST /v1/browser/trigger", code, err.Error())
 		return
 	}
 
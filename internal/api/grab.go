@@ -146,8 +146,8 @@ func (s *ControlServer) handleGrabDownload(w http.ResponseWriter, r *http.Reques
 
 	id, err := s.engine.StartDownload(req.URL, defaultPath, filename, options)
 	if err != nil {
-		s.audit.Log("127.0.0.1", r.UserAgent(), "POST /v1/grab/download", 500, err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		code := writeStartError(w, err)
+		s.audit.Log("127.0.0.1", r.UserAgent(), "POST /v1/grab/download", code, err.Error())
 		return
 	}
 
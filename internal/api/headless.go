@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"project-tachyon/internal/engine"
+)
+
+// RunServer runs Tachyon headless, as in a container: the engine and the
+// control server s, without a window or tray. It blocks until SIGINT or
+// SIGTERM, then stops accepting requests and shuts the engine down, which
+// saves running downloads so they resume on the next start.
+func RunServer(s *ControlServer, logger *slog.Logger) error {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	engine.WaitForSignals(func() {
+		logger.Info("OS Signal received, shutting down server...")
+		stop()
+	})
+	return s.serve(ctx, logger)
+}
+
+// serve runs the engine's background work until ctx is cancelled, then
+// shuts the control server and the engine down.
+func (s *ControlServer) serve(ctx context.Context, logger *slog.Logger) error {
+	if s.Port() == 0 {
+		return errors.New("control server is not listening")
+	}
+	s.engine.Run(ctx)
+	logger.Info("Server mode ready", "addr", s.cfg.GetAIBindAddress(), "port", s.Port(), "api_enabled", s.cfg.GetEnableAI())
+	<-ctx.Done()
+
+	s.Shutdown()
+	return s.engine.Shutdown()
+}
+
+// Shutdown stops the server, giving requests in flight shutdownTimeout to
+// finish.
+func (s *ControlServer) Shutdown() {
+	s.srvMu.Lock()
+	defer s.srvMu.Unlock()
+	if s.srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	s.srv.Shutdown(ctx)
+	s.srv, s.port = nil, 0
+}
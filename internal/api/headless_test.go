@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/config"
+)
+
+func TestServe_HealthzUntilCancelled(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	s := NewControlServer(srv.engine, config.NewConfigManager(srv.engine.GetStorage()), newTestAudit(t))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	port := freePort(t)
+	s.Start(port)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.serve(ctx, logger) }()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/healthz", port)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/healthz = %d, want 200", resp.StatusCode)
+	}
+
+	// SIGTERM cancels the context: the server and the engine stop
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve did not return after cancellation")
+	}
+	if s.Port() != 0 {
+		t.Errorf("Port = %d after shutdown, want 0", s.Port())
+	}
+	if resp, err := http.Get(url); err == nil {
+		resp.Body.Close()
+		t.Error("control server still answering after shutdown")
+	}
+}
+
+func TestServe_NotListening(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	s := NewControlServer(srv.engine, config.NewConfigManager(srv.engine.GetStorage()), newTestAudit(t))
+	if err := s.serve(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Error("expected an error when the port could not be bound")
+	}
+}
@@ -1,10 +1,15 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"project-tachyon/internal/config"
 )
 
 func TestHandleHealth_ReturnsOK(t *testing.T) {
@@ -65,3 +70,112 @@ func TestHandleHealth_NoAuthRequired(t *testing.T) {
 		t.Fatalf("health should not require auth, got status %d", rec.Code)
 	}
 }
+
+func TestProbes_NoAuthAndReadinessFollowsDB(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	store := srv.engine.GetStorage()
+	// The AI interface is off, so every other endpoint is refused.
+	s := NewControlServer(srv.engine, config.NewConfigManager(store), newTestAudit(t))
+
+	probe := func(path string) (int, map[string]string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.7:40000" // kubelet, not loopback, no token
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		var body map[string]string
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	if code, _ := probe("/v1/status"); code == http.StatusOK {
+		t.Fatal("expected the authenticated API to stay closed")
+	}
+	if code, body := probe("/healthz"); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("/healthz = %d %v, want 200 ok", code, body)
+	}
+	if code, body := probe("/readyz"); code != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("/readyz = %d %v, want 200 ready", code, body)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := probe("/readyz"); code != http.StatusServiceUnavailable || body["error"] == "" {
+		t.Errorf("/readyz with the database closed = %d %v, want 503 with an error", code, body)
+	}
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz with the database closed = %d, want 200", code)
+	}
+}
+
+func TestHandleReadyz_NoEngine(t *testing.T) {
+	s := newTestControlServer(t)
+
+	rec := httptest.NewRecorder()
+	s.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+// freePort returns a loopback port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestControlServer_RestartMovesPort(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	s := NewControlServer(srv.engine, config.NewConfigManager(srv.engine.GetStorage()), newTestAudit(t))
+	healthz := func(port int) error {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/healthz", port))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	first := freePort(t)
+	s.Start(first)
+	if s.Port() != first || healthz(first) != nil {
+		t.Fatalf("server not listening on %d", first)
+	}
+
+	second := freePort(t)
+	if err := s.Restart(second); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if s.Port() != second {
+		t.Errorf("Port = %d, want %d", s.Port(), second)
+	}
+	if err := healthz(second); err != nil {
+		t.Errorf("new port not serving: %v", err)
+	}
+	if healthz(first) == nil {
+		t.Error("old port still serving after restart")
+	}
+
+	// A port that can't be bound leaves the server where it was.
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	if err := s.Restart(taken.Addr().(*net.TCPAddr).Port); err == nil {
+		t.Error("expected an error restarting onto a busy port")
+	}
+	if s.Port() != second || healthz(second) != nil {
+		t.Error("failed restart dropped the working listener")
+	}
+	s.Restart(second) // same port: nothing to do
+	if healthz(second) != nil {
+		t.Error("restart onto the current port interrupted the server")
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyTTL       = 24 * time.Hour // how long a key keeps returning its task
+	maxIdempotencyKeyLen = 255
+)
+
+// errIdempotencyMismatch means a key was reused for a different request.
+var errIdempotencyMismatch = errors.New("Idempotency-Key was already used for a different request")
+
+// idempotencyCache remembers which task each Idempotency-Key created, so a
+// client retrying POST /v1/queue after a dropped response gets the same task
+// back instead of a duplicate download.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type idempotencyEntry struct {
+	request string        // fingerprint of the request that claimed the key
+	taskID  string        // set once the first request succeeds
+	expires time.Time     // zero while the first request is in flight
+	done    chan struct{} // closed when the first request finishes
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		entries: make(map[string]*idempotencyEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// do returns the task ID recorded for key, or runs start and records its
+// result. Concurrent calls with the same key wait for the first one; if it
+// fails the key is released so a retry can try again. replayed reports
+// whether the ID came from an earlier call.
+func (c *idempotencyCache) do(key, request string, start func() (string, error)) (id string, replayed bool, err error) {
+	var claimed *idempotencyEntry
+	for claimed == nil {
+		c.mu.Lock()
+		c.pruneLocked()
+		entry, ok := c.entries[key]
+		if !ok {
+			claimed = &idempotencyEntry{request: request, done: make(chan struct{})}
+			c.entries[key] = claimed
+			c.mu.Unlock()
+			break
+		}
+		c.mu.Unlock()
+
+		if entry.request != request {
+			return "", false, errIdempotencyMismatch
+		}
+		<-entry.done
+		c.mu.Lock()
+		id := entry.taskID
+		c.mu.Unlock()
+		if id != "" {
+			return id, true, nil
+		}
+		// The first attempt failed and released the key; try to claim it.
+	}
+
+	id, err = start()
+	c.mu.Lock()
+	if err != nil {
+		delete(c.entries, key)
+	} else {
+		claimed.taskID = id
+		claimed.expires = c.now().Add(c.ttl)
+	}
+	c.mu.Unlock()
+	close(claimed.done)
+	return id, false, err
+}
+
+// pruneLocked drops finished entries past their expiry.
+func (c *idempotencyCache) pruneLocked() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
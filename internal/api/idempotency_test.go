@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCache_ReplaysWithinTTL(t *testing.T) {
+	c := newIdempotencyCache(time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	var calls int
+	start := func() (string, error) {
+		calls++
+		return "task-" + string(rune('0'+calls)), nil
+	}
+
+	id, replayed, err := c.do("k", "req", start)
+	if err != nil || replayed || id != "task-1" {
+		t.Fatalf("first call = %q %v %v", id, replayed, err)
+	}
+	id, replayed, err = c.do("k", "req", start)
+	if err != nil || !replayed || id != "task-1" {
+		t.Errorf("repeat = %q %v %v, want task-1 replayed", id, replayed, err)
+	}
+	if _, _, err := c.do("k", "other req", start); !errors.Is(err, errIdempotencyMismatch) {
+		t.Errorf("reusing the key for another request: err = %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if id, replayed, _ := c.do("k", "req", start); replayed || id != "task-2" {
+		t.Errorf("after expiry = %q replayed=%v, want a new task", id, replayed)
+	}
+	if calls != 2 {
+		t.Errorf("start called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyCache_FailureReleasesKey(t *testing.T) {
+	c := newIdempotencyCache(time.Hour)
+	if _, _, err := c.do("k", "req", func() (string, error) { return "", errors.New("queue full") }); err == nil {
+		t.Fatal("expected the start error")
+	}
+	id, replayed, err := c.do("k", "req", func() (string, error) { return "task", nil })
+	if err != nil || replayed || id != "task" {
+		t.Errorf("retry after failure = %q %v %v, want a fresh start", id, replayed, err)
+	}
+}
+
+func TestIdempotencyCache_ConcurrentCallsStartOnce(t *testing.T) {
+	c := newIdempotencyCache(time.Hour)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	start := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "task", nil
+	}
+
+	var wg sync.WaitGroup
+	ids := make([]string, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], _, _ = c.do("k", "req", start)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("start called %d times, want 1", calls.Load())
+	}
+	for i, id := range ids {
+		if id != "task" {
+			t.Errorf("caller %d got %q", i, id)
+		}
+	}
+}
@@ -2,21 +2,30 @@ package api
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"project-tachyon/internal/engine"
+	"project-tachyon/internal/storage"
+	"strconv"
+	"strings"
 	"sync"
 )
 
+// maxMCPMessage caps the body a Content-Length header may announce.
+const maxMCPMessage = 64 << 20
+
 // MCPServer implements a basic JSON-RPC 2.0 handler for Model Context Protocol
 // It reads from an io.Reader and writes to an io.Writer (defaults to Stdin/Stdout).
 type MCPServer struct {
 	engine *engine.TachyonEngine
 	mu     sync.Mutex
 	writer io.Writer
+	framed bool // client sends Content-Length framing; reply the same way
 }
 
 func NewMCPServer(engine *engine.TachyonEngine) *MCPServer {
@@ -41,22 +50,66 @@ func (s *MCPServer) Start() {
 }
 
 // StartWithReader processes messages from the given reader until EOF.
+// Messages are either one JSON object per line, of any length, or bodies
+// framed by LSP-style Content-Length headers; the framing is detected per
+// message.
 func (s *MCPServer) StartWithReader(r io.Reader) {
 	log.SetOutput(os.Stderr)
 	log.Printf("MCP Server Started. Listening...")
 
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+	br := bufio.NewReader(r)
+	for {
+		msg, err := s.readMessage(br)
+		if len(msg) > 0 {
+			s.handleMessage(msg)
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("MCP read error: %v", err)
+			}
+			return
 		}
-		s.handleMessage(line)
+	}
+}
+
+// readMessage reads the next message from br. Blank lines yield an empty
+// message. A framing error is returned as is, since the stream cannot be
+// resynchronised after it.
+func (s *MCPServer) readMessage(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadBytes('\n')
+	line = bytes.TrimSpace(line)
+	name, value, ok := strings.Cut(string(line), ":")
+	if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+		return line, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("MCP Scan error: %v", err)
+	length, convErr := strconv.Atoi(strings.TrimSpace(value))
+	if convErr != nil || length < 0 || length > maxMCPMessage {
+		return nil, fmt.Errorf("invalid Content-Length %q", strings.TrimSpace(value))
+	}
+	s.mu.Lock()
+	s.framed = true
+	s.mu.Unlock()
+
+	// Skip any other headers (Content-Type) up to the blank line
+	for len(line) > 0 {
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		line, err = br.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+	}
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
 	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(br, body); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
 }
 
 type JsonRpcRequest struct {
@@ -91,12 +144,24 @@ func (s *MCPServer) handleMessage(data []byte) {
 		s.handleInitialize(req)
 	case "notifications/initialized":
 		// Acknowledgement from client — no response required
+	case "ping":
+		s.sendResponse(req.ID, map[string]interface{}{})
 	// --- MCP tool discovery & invocation ---
 	case "tools/list":
 		s.handleToolsList(req)
 	case "tools/call":
 		s.handleToolCall(req)
+	// --- MCP resources: download history ---
+	case "resources/list":
+		s.handleResourcesList(req)
+	case "resources/read":
+		s.handleResourcesRead(req)
 	default:
+		// Notifications carry no ID and must never be answered, not even
+		// with an error (e.g. notifications/cancelled).
+		if req.ID == nil {
+			return
+		}
 		s.sendError(req.ID, -32601, "Method not found")
 	}
 }
@@ -125,8 +190,12 @@ func (s *MCPServer) sendError(id interface{}, code int, message string) {
 func (s *MCPServer) write(resp JsonRpcResponse) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	bytes, _ := json.Marshal(resp)
-	fmt.Fprintf(s.writer, "%s\n", bytes)
+	data, _ := json.Marshal(resp)
+	if s.framed {
+		fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n%s", len(data), data)
+		return
+	}
+	fmt.Fprintf(s.writer, "%s\n", data)
 }
 
 // Handlers
@@ -136,7 +205,8 @@ func (s *MCPServer) handleInitialize(req JsonRpcRequest) {
 	s.sendResponse(req.ID, map[string]interface{}{
 		"protocolVersion": "2024-11-05",
 		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{},
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{},
 		},
 		"serverInfo": map[string]interface{}{
 			"name":    "tachyon",
@@ -279,3 +349,91 @@ func (s *MCPServer) handleToolsList(req JsonRpcRequest) {
 		"tools": tools,
 	})
 }
+
+// resourceURIPrefix prefixes the URI of each completed download exposed as
+// an MCP resource; the task ID follows it.
+const resourceURIPrefix = "tachyon://downloads/"
+
+// historyResource is what resources/read returns for a download. Headers
+// and cookies are left out, as they may hold credentials.
+type historyResource struct {
+	ID            string `json:"id"`
+	Filename      string `json:"filename"`
+	URL           string `json:"url"`
+	SavePath      string `json:"save_path"`
+	Size          int64  `json:"size"`
+	Category      string `json:"category,omitempty"`
+	Hash          string `json:"hash,omitempty"`
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	CompletedAt   string `json:"completed_at"`
+}
+
+// handleResourcesList lists completed downloads as resources.
+func (s *MCPServer) handleResourcesList(req JsonRpcRequest) {
+	tasks, err := s.engine.GetHistory()
+	if err != nil {
+		s.sendError(req.ID, -32603, "Failed to load history: "+err.Error())
+		return
+	}
+
+	resources := []map[string]interface{}{}
+	for _, t := range tasks {
+		if t.Status != storage.StatusCompleted {
+			continue
+		}
+		r := map[string]interface{}{
+			"uri":         resourceURIPrefix + t.ID,
+			"name":        t.Filename,
+			"description": fmt.Sprintf("Downloaded from %s to %s", t.URL, t.SavePath),
+			"mimeType":    "application/json",
+		}
+		if t.TotalSize > 0 {
+			r["size"] = t.TotalSize
+		}
+		resources = append(resources, r)
+	}
+	s.sendResponse(req.ID, map[string]interface{}{
+		"resources": resources,
+	})
+}
+
+// handleResourcesRead returns the metadata of one completed download.
+func (s *MCPServer) handleResourcesRead(req JsonRpcRequest) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		s.sendError(req.ID, -32602, "Invalid params: uri is required")
+		return
+	}
+
+	id, ok := strings.CutPrefix(params.URI, resourceURIPrefix)
+	if !ok || id == "" {
+		s.sendError(req.ID, -32002, "Resource not found: "+params.URI)
+		return
+	}
+	t, err := s.engine.GetTask(id)
+	if err != nil || t.Status != storage.StatusCompleted {
+		s.sendError(req.ID, -32002, "Resource not found: "+params.URI)
+		return
+	}
+
+	data, _ := json.MarshalIndent(historyResource{
+		ID:            t.ID,
+		Filename:      t.Filename,
+		URL:           t.URL,
+		SavePath:      t.SavePath,
+		Size:          t.TotalSize,
+		Category:      t.Category,
+		Hash:          t.ExpectedHash,
+		HashAlgorithm: t.HashAlgorithm,
+		CreatedAt:     t.CreatedAt,
+		CompletedAt:   t.UpdatedAt,
+	}, "", "  ")
+	s.sendResponse(req.ID, map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{"uri": params.URI, "mimeType": "application/json", "text": string(data)},
+		},
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -41,7 +42,7 @@ func newTestMCPServer(t *testing.T, buf *bytes.Buffer) *MCPServer {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db.AutoMigrate(&storage.DownloadTask{}, &storage.DownloadLocation{}, &storage.DailyStat{}, &storage.AppSetting{})
+	db.AutoMigrate(&storage.DownloadTask{}, &storage.DownloadLocation{}, &storage.DailyStat{}, &storage.AppSetting{}, &storage.HostProfile{})
 	store := &storage.Storage{DB: db}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
@@ -95,6 +96,9 @@ func TestMCP_Initialize(t *testing.T) {
 	if caps["tools"] == nil {
 		t.Error("missing tools capability")
 	}
+	if caps["resources"] == nil {
+		t.Error("missing resources capability")
+	}
 	info := result["serverInfo"].(map[string]interface{})
 	if info["name"] != "tachyon" {
 		t.Errorf("expected server name 'tachyon', got %v", info["name"])
@@ -111,6 +115,29 @@ func TestMCP_Initialized_NoResponse(t *testing.T) {
 	}
 }
 
+func TestMCP_UnknownNotification_NoResponse(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+
+	srv.handleMessage([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`))
+	if buf.Len() != 0 {
+		t.Errorf("notifications should not produce a response, got %q", buf.String())
+	}
+}
+
+func TestMCP_Ping(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+
+	resp := sendRPC(t, srv, `{"jsonrpc":"2.0","method":"ping","id":7}`)
+	if resp.Error != nil {
+		t.Fatalf("ping failed: %s", resp.Error.Message)
+	}
+	if resp.ID != float64(7) {
+		t.Errorf("response ID = %v, want 7", resp.ID)
+	}
+}
+
 // --- handleMessage routing tests ---
 
 func TestMCP_HandleMessage_InvalidJSON(t *testing.T) {
@@ -331,6 +358,110 @@ func TestMCP_List_AfterDownload(t *testing.T) {
 	}
 }
 
+// --- resources: download history ---
+
+// seedHistory saves a completed, a running and a second completed download.
+func seedHistory(t *testing.T, srv *MCPServer) {
+	t.Helper()
+	store := srv.engine.GetStorage()
+	for _, task := range []storage.DownloadTask{
+		{ID: "done-1", Filename: "a.iso", URL: "https://example.com/a.iso", SavePath: "/dl/a.iso", Status: storage.StatusCompleted, TotalSize: 4096, ExpectedHash: "abc", HashAlgorithm: "sha256", Cookies: `{"session":"secret"}`},
+		{ID: "running", Filename: "b.zip", URL: "https://example.com/b.zip", Status: storage.StatusDownloading, TotalSize: 100},
+		{ID: "done-2", Filename: "c.txt", URL: "https://example.com/c.txt", SavePath: "/dl/c.txt", Status: storage.StatusCompleted},
+	} {
+		if err := store.SaveTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMCP_ResourcesList(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+
+	// No history yet: an empty list, not null
+	resp := sendRPC(t, srv, `{"jsonrpc":"2.0","method":"resources/list","id":1}`)
+	if resp.Error != nil {
+		t.Fatalf("resources/list failed: %s", resp.Error.Message)
+	}
+	if res := resp.Result.(map[string]interface{})["resources"].([]interface{}); len(res) != 0 {
+		t.Errorf("expected no resources, got %v", res)
+	}
+
+	seedHistory(t, srv)
+	resp = sendRPC(t, srv, `{"jsonrpc":"2.0","method":"resources/list","id":2}`)
+	res := resp.Result.(map[string]interface{})["resources"].([]interface{})
+	got := map[string]map[string]interface{}{}
+	for _, r := range res {
+		m := r.(map[string]interface{})
+		got[m["uri"].(string)] = m
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected the 2 completed downloads, got %v", res)
+	}
+	a := got["tachyon://downloads/done-1"]
+	if a == nil || a["name"] != "a.iso" || a["size"] != float64(4096) || a["mimeType"] != "application/json" {
+		t.Errorf("resource for done-1 = %v", a)
+	}
+	if c := got["tachyon://downloads/done-2"]; c == nil || c["size"] != nil {
+		t.Errorf("resource for done-2 = %v, want no size", c)
+	}
+}
+
+func TestMCP_ResourcesRead(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+	seedHistory(t, srv)
+
+	resp := sendRPC(t, srv, `{"jsonrpc":"2.0","method":"resources/read","params":{"uri":"tachyon://downloads/done-1"},"id":1}`)
+	if resp.Error != nil {
+		t.Fatalf("resources/read failed: %s", resp.Error.Message)
+	}
+	contents := resp.Result.(map[string]interface{})["contents"].([]interface{})
+	if len(contents) != 1 {
+		t.Fatalf("expected 1 content item, got %d", len(contents))
+	}
+	item := contents[0].(map[string]interface{})
+	if item["uri"] != "tachyon://downloads/done-1" || item["mimeType"] != "application/json" {
+		t.Errorf("content = %v", item)
+	}
+	text := item["text"].(string)
+	var meta historyResource
+	if err := json.Unmarshal([]byte(text), &meta); err != nil {
+		t.Fatalf("metadata is not JSON: %v\n%s", err, text)
+	}
+	if meta.Filename != "a.iso" || meta.SavePath != "/dl/a.iso" || meta.Size != 4096 || meta.Hash != "abc" {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if strings.Contains(text, "secret") {
+		t.Errorf("metadata leaks cookies: %s", text)
+	}
+}
+
+func TestMCP_ResourcesRead_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+	seedHistory(t, srv)
+
+	cases := []struct {
+		params string
+		code   int
+	}{
+		{`{"uri":"tachyon://downloads/missing"}`, -32002},
+		{`{"uri":"tachyon://downloads/running"}`, -32002}, // not finished
+		{`{"uri":"file:///etc/passwd"}`, -32002},
+		{`{"uri":"tachyon://downloads/"}`, -32002},
+		{`{}`, -32602},
+		{`"bad"`, -32602},
+	}
+	for i, tc := range cases {
+		resp := sendRPC(t, srv, fmt.Sprintf(`{"jsonrpc":"2.0","method":"resources/read","params":%s,"id":%d}`, tc.params, i))
+		if resp.Error == nil || resp.Error.Code != tc.code {
+			t.Errorf("params %s: error = %+v, want code %d", tc.params, resp.Error, tc.code)
+		}
+	}
+}
+
 // --- StartWithReader integration ---
 
 func TestMCP_StartWithReader_MultipleMessages(t *testing.T) {
@@ -378,6 +509,91 @@ func TestMCP_StartWithReader_SkipsEmptyLines(t *testing.T) {
 	}
 }
 
+func TestMCP_StartWithReader_LargeMessage(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+
+	// Well past bufio.Scanner's 64KB token limit
+	pad := strings.Repeat("x", 200*1024)
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{"pad":"` + pad + `"},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/list","id":2}` + "\n"
+	srv.StartWithReader(strings.NewReader(input))
+
+	dec := json.NewDecoder(&buf)
+	for _, wantID := range []float64{1, 2} {
+		var resp JsonRpcResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("response %v: %v", wantID, err)
+		}
+		if resp.ID != wantID || resp.Error != nil {
+			t.Errorf("response = %+v, want a result for id %v", resp, wantID)
+		}
+	}
+}
+
+// framedMessage wraps body in LSP-style Content-Length framing.
+func framedMessage(body string, extraHeaders ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	for _, h := range extraHeaders {
+		b.WriteString(h + "\r\n")
+	}
+	b.WriteString("\r\n" + body)
+	return b.String()
+}
+
+func TestMCP_StartWithReader_ContentLengthFraming(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestMCPServer(t, &buf)
+
+	// Bodies are not newline-terminated, and may span lines
+	input := framedMessage(`{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`) +
+		framedMessage("{\"jsonrpc\":\"2.0\",\n\"method\":\"ping\",\"id\":2}", "Content-Type: application/vscode-jsonrpc; charset=utf-8") +
+		framedMessage(`{"jsonrpc":"2.0","method":"notifications/initialized"}`) +
+		framedMessage(`{"jsonrpc":"2.0","method":"tools/list","id":3}`)
+	srv.StartWithReader(strings.NewReader(input))
+
+	// Replies use the same framing
+	br := bufio.NewReader(&buf)
+	for _, wantID := range []float64{1, 2, 3} {
+		body, err := srv.readMessage(br)
+		if err != nil && len(body) == 0 {
+			t.Fatalf("response %v: %v", wantID, err)
+		}
+		var resp JsonRpcResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("response %v: %v (%q)", wantID, err, body)
+		}
+		if resp.ID != wantID || resp.Error != nil {
+			t.Errorf("response = %+v, want a result for id %v", resp, wantID)
+		}
+	}
+	if rest, _ := io.ReadAll(br); len(rest) != 0 {
+		t.Errorf("unexpected extra output %q", rest)
+	}
+}
+
+func TestMCP_StartWithReader_BadFraming(t *testing.T) {
+	ping := "\n" + `{"jsonrpc":"2.0","method":"ping","id":2}` + "\n"
+	for name, input := range map[string]string{
+		// The stream cannot be resynchronised, so reading stops rather than
+		// guessing where the next message starts
+		"bad length": "Content-Length: lots\r\n\r\n{}" + ping,
+		"too large":  "Content-Length: 999999999999\r\n\r\n{}" + ping,
+		"short body": framedMessage(`{"jsonrpc":"2.0","method":"ping","id":1}`)[:30],
+		"no body":    "Content-Length: 10\r\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			srv := newTestMCPServer(t, &buf)
+			srv.StartWithReader(strings.NewReader(input))
+			if buf.Len() != 0 {
+				t.Errorf("unexpected output %q", buf.String())
+			}
+		})
+	}
+}
+
 // --- Response format verification ---
 
 func TestMCP_ResponseFormat_JSONRPC(t *testing.T) {
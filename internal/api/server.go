@@ -1,14 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"project-tachyon/internal/analytics"
 	"project-tachyon/internal/config"
 	"project-tachyon/internal/engine"
 	"project-tachyon/internal/security"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -26,21 +30,37 @@ type ControlServer struct {
 	activeReqs int64
 	rateMu     sync.Mutex
 	rateHits   map[string][]time.Time // IP -> request timestamps
+
+	idempotency *idempotencyCache // Idempotency-Key -> task for POST /v1/queue
+
+	srvMu sync.Mutex
+	srv   *http.Server // nil until Start binds
+	port  int
 }
 
 const (
 	rateLimit      = 60      // max requests per window
 	rateWindow     = 60      // window size in seconds
 	maxRequestBody = 1 << 20 // 1 MB
+
+	queueFullRetryAfter = "30" // seconds
+
+	maxAnalyticsDays = 365 // longest daily history /v1/analytics returns
+
+	readyTimeout    = 2 * time.Second // bound on the /readyz database check
+	shutdownTimeout = 5 * time.Second // grace for requests when Restart moves ports
+
+	serverVersion = "1.0.0"
 )
 
 func NewControlServer(engine *engine.TachyonEngine, cfg *config.ConfigManager, audit *security.AuditLogger) *ControlServer {
 	s := &ControlServer{
-		engine:   engine,
-		cfg:      cfg,
-		audit:    audit,
-		router:   chi.NewRouter(),
-		rateHits: make(map[string][]time.Time),
+		engine:      engine,
+		cfg:         cfg,
+		audit:       audit,
+		router:      chi.NewRouter(),
+		rateHits:    make(map[string][]time.Time),
+		idempotency: newIdempotencyCache(idempotencyTTL),
 	}
 	s.setupRoutes()
 	return s
@@ -50,6 +70,10 @@ func NewControlServer(engine *engine.TachyonEngine, cfg *config.ConfigManager, a
 
 func (s *ControlServer) concurrencyLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbePath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
 		max := int64(s.cfg.GetAIMaxConcurrent())
 		if max <= 0 {
 			max = 1 // Safety default
@@ -70,21 +94,72 @@ func (s *ControlServer) concurrencyLimitMiddleware(next http.Handler) http.Handl
 }
 
 func (s *ControlServer) Start(port int) {
-	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	s.srvMu.Lock()
+	defer s.srvMu.Unlock()
+	srv, err := s.listen(port)
+	if err != nil {
+		log.Printf("Control Server failed to bind: %v", err)
+		return
+	}
+	s.srv, s.port = srv, port
+}
+
+// Restart moves the server to port. The new listener is bound first, so a
+// port that can't be bound leaves the server where it was; requests in
+// flight on the old listener are given shutdownTimeout to finish.
+func (s *ControlServer) Restart(port int) error {
+	s.srvMu.Lock()
+	defer s.srvMu.Unlock()
+	if s.srv != nil && s.port == port {
+		return nil
+	}
+	srv, err := s.listen(port)
+	if err != nil {
+		return fmt.Errorf("control server: %w", err)
+	}
+	old := s.srv
+	s.srv, s.port = srv, port
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		old.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Port returns the port the server is listening on, 0 if it isn't.
+func (s *ControlServer) Port() int {
+	s.srvMu.Lock()
+	defer s.srvMu.Unlock()
+	if s.srv == nil {
+		return 0
+	}
+	return s.port
+}
+
+// listen binds port on the configured interface (loopback unless set
+// otherwise) and serves on it in the background. It refuses a non-loopback
+// interface while the allowlist is empty, which would leave the extension
+// and grab endpoints open to the network.
+func (s *ControlServer) listen(port int) (*http.Server, error) {
+	bind := s.cfg.GetAIBindAddress()
+	if !isLoopbackBind(bind) && len(s.cfg.GetAIAllowedIPs()) == 0 {
+		return nil, fmt.Errorf("refusing to listen on %s without an allowed IP list", bind)
+	}
+	addr := net.JoinHostPort(bind, strconv.Itoa(port))
+	conn, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
 	log.Printf("Control Server listening on %s", addr)
 
+	srv := &http.Server{Handler: s.router}
 	go func() {
-		// Enforce loopback for the listener itself as an extra layer
-		conn, err := net.Listen("tcp", addr)
-		if err != nil {
-			log.Printf("Control Server failed to bind: %v", err)
-			return
-		}
-
-		if err := http.Serve(conn, s.router); err != nil {
+		if err := srv.Serve(conn); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("Control Server failed: %v", err)
 		}
 	}()
+	return srv, nil
 }
 
 func (s *ControlServer) setupRoutes() {
@@ -98,6 +173,10 @@ func (s *ControlServer) setupRoutes() {
 	s.router.Get("/v1/health", s.handleHealth)
 	s.router.Options("/v1/health", s.handleHealth)
 
+	// Orchestrator probes — exempted from auth and request limits
+	s.router.Get("/healthz", s.handleHealthz)
+	s.router.Get("/readyz", s.handleReadyz)
+
 	s.router.Post("/v1/queue", s.handleQueueDownload)
 	s.router.Post("/v1/browser/trigger", s.handleBrowserTrigger)
 	s.router.Post("/v1/browser/check", s.handleBrowserCheck)
@@ -106,22 +185,30 @@ func (s *ControlServer) setupRoutes() {
 	s.router.Get("/v1/tasks/{id}", s.handleGetTask)
 	s.router.Post("/v1/tasks/{id}/control", s.handleTaskControl)
 	s.router.Get("/v1/status", s.handleGetStatus)
+	s.router.Post("/v1/probe", s.handleProbe)
+	s.router.Get("/v1/analytics", s.handleGetAnalytics)
 }
 
 func (s *ControlServer) securityMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Browser extension endpoints — skip AI feature flag and token auth
+		sourceIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+		userAgent := r.UserAgent()
+		action := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+
+		// Browser extension endpoints — skip AI feature flag and token auth,
+		// but not the allowlist once the server is reachable off this host
 		path := r.URL.Path
-		if path == "/v1/health" || strings.HasPrefix(path, "/v1/browser/") ||
+		if path == "/v1/health" || isProbePath(path) || strings.HasPrefix(path, "/v1/browser/") ||
 			strings.HasPrefix(path, "/v1/grab/") {
+			if !isLoopbackBind(s.cfg.GetAIBindAddress()) && !clientAllowed(sourceIP, s.cfg.GetAIAllowedIPs()) {
+				s.audit.Log(sourceIP, userAgent, action, 403, "External Access Denied")
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		sourceIP, _, _ := net.SplitHostPort(r.RemoteAddr)
-		userAgent := r.UserAgent()
-		action := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
-
 		// 1. Feature Flag Check (Runtime)
 		if !s.cfg.GetEnableAI() {
 			// Even if listener is running (dynamic disable), reject
@@ -130,9 +217,8 @@ func (s *ControlServer) securityMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// 2. Localhost Enforcement
-		// Note: net.SplitHostPort might return "::1" or "127.0.0.1"
-		if sourceIP != "127.0.0.1" && sourceIP != "::1" {
+		// 2. Source Enforcement: loopback, or an allowlisted address
+		if !clientAllowed(sourceIP, s.cfg.GetAIAllowedIPs()) {
 			s.audit.Log(sourceIP, userAgent, action, 403, "External Access Denied")
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
@@ -154,6 +240,36 @@ func (s *ControlServer) securityMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// isLoopbackBind reports whether the bind address only accepts connections
+// from this host.
+func isLoopbackBind(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
+
+// clientAllowed reports whether ip may use the authenticated API: loopback
+// always may, anything else only when it matches an allowed address or
+// CIDR range.
+func clientAllowed(ip string, allowed []string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // Request/Response Models
 type EnqueueRequest struct {
 	URL      string `json:"url"`
@@ -169,6 +285,10 @@ type ControlRequest struct {
 	Action string `json:"action"` // "pause", "resume", "cancel", "delete"
 }
 
+type ProbeRequest struct {
+	URL string `json:"url"`
+}
+
 func (s *ControlServer) handleQueueDownload(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
 	var req EnqueueRequest
@@ -186,10 +306,34 @@ func (s *ControlServer) handleQueueDownload(w http.ResponseWriter, r *http.Reque
 	}
 	req.Filename = engine.SanitizeFilename(req.Filename)
 
-	id, err := s.engine.StartDownload(req.URL, req.Path, req.Filename, nil)
+	start := func() (string, error) {
+		return s.engine.StartDownload(req.URL, req.Path, req.Filename, nil)
+	}
+	var id string
+	var err error
+	// A retried request carrying the same Idempotency-Key gets the task the
+	// first one created instead of a duplicate download.
+	if key := r.Header.Get("Idempotency-Key"); key != "" && s.idempotency != nil {
+		if len(key) > maxIdempotencyKeyLen {
+			http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+			return
+		}
+		var replayed bool
+		id, replayed, err = s.idempotency.do(key, req.URL+"\x00"+req.Path+"\x00"+req.Filename, start)
+		if errors.Is(err, errIdempotencyMismatch) {
+			s.audit.Log("127.0.0.1", r.UserAgent(), "POST /queue", 422, err.Error())
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+	} else {
+		id, err = start()
+	}
 	if err != nil {
-		s.audit.Log("127.0.0.1", r.UserAgent(), "POST /queue", 500, err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		code := writeStartError(w, err)
+		s.audit.Log("127.0.0.1", r.UserAgent(), "POST /queue", code, err.Error())
 		return
 	}
 
@@ -203,7 +347,12 @@ func (s *ControlServer) handleGetTask(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
-	json.NewEncoder(w).Encode(task)
+	pt := NewPublicTask(task)
+	if live, ok := s.engine.GetLiveProgress(id); ok {
+		pt = pt.withLiveProgress(live)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pt)
 }
 
 func (s *ControlServer) handleTaskControl(w http.ResponseWriter, r *http.Request) {
@@ -237,8 +386,83 @@ func (s *ControlServer) handleTaskControl(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleProbe reports what the server says about a URL without queueing it.
+// The probe runs under the request's context, so a client that disconnects
+// stops it instead of leaving it holding connections.
+func (s *ControlServer) handleProbe(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+	var req ProbeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := engine.ValidateURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.engine.ProbeURLContext(r.Context(), req.URL, "", "")
+	if r.Context().Err() != nil {
+		return // client went away
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeStartError reports a failed StartDownload and returns the status code
+// sent. A full queue is a 429 with Retry-After so clients can back off.
+func writeStartError(w http.ResponseWriter, err error) int {
+	if errors.Is(err, engine.ErrQueueFull) {
+		w.Header().Set("Retry-After", queueFullRetryAfter)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return http.StatusTooManyRequests
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+	return http.StatusInternalServerError
+}
+
 func (s *ControlServer) handleGetStatus(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte(`{"status": "running"}`))
+	st := s.engine.Status()
+	resp := map[string]interface{}{
+		"status":         "running",
+		"version":        serverVersion,
+		"uptime_seconds": st.Uptime,
+		"queue_size":     s.engine.QueueSize(),
+		"max_queue_size": s.engine.GetMaxQueueSize(),
+		"active":         st.Active,
+		"queued":         st.Queued,
+		"completed":      st.Completed,
+		"speed":          st.Speed,
+		"session_bytes":  st.SessionBytes,
+		"speed_limit":    st.SpeedLimit,
+	}
+	if s.cfg != nil {
+		resp["av_scan"] = s.cfg.GetEnableAVScan()
+		resp["integrity_check"] = s.cfg.GetEnableIntegrityCheck()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleGetAnalytics returns the lifetime totals, daily history and disk
+// usage shown on the analytics page. ?days= sets how many days of history
+// to include (default 7, at most maxAnalyticsDays).
+func (s *ControlServer) handleGetAnalytics(w http.ResponseWriter, r *http.Request) {
+	days := analytics.DefaultHistoryDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAnalyticsDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", maxAnalyticsDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.engine.GetStats().GetAnalyticsForDays(days))
 }
 
 func (s *ControlServer) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -254,13 +478,45 @@ func (s *ControlServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":  "ok",
-		"version": "1.0.0",
+		"version": serverVersion,
 	})
 }
 
+// isProbePath reports whether path is a liveness or readiness probe, which
+// must answer without a token so container orchestrators can call it.
+func isProbePath(path string) bool {
+	return path == "/healthz" || path == "/readyz"
+}
+
+// handleHealthz is the liveness probe: answering at all means the process
+// is up.
+func (s *ControlServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReadyz is the readiness probe: 200 once the engine is initialized
+// and its database answers, 503 otherwise.
+func (s *ControlServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.engine.Ready(ctx); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
+
 // rateLimitMiddleware enforces a sliding-window rate limit per source IP.
 func (s *ControlServer) rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbePath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
 		sourceIP, _, _ := net.SplitHostPort(r.RemoteAddr)
 		now := time.Now()
 		cutoff := now.Add(-time.Duration(rateWindow) * time.Second)
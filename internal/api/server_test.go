@@ -1,14 +1,25 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"project-tachyon/internal/analytics"
+	"project-tachyon/internal/config"
+	"project-tachyon/internal/engine"
 	"project-tachyon/internal/security"
+	"project-tachyon/internal/storage"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // Ensure imports are used
@@ -130,3 +141,405 @@ func TestRateLimitMiddleware_DifferentIPsIndependent(t *testing.T) {
 		t.Fatalf("expected 200 for different IP, got %d", rec.Code)
 	}
 }
+
+func TestClientAllowed(t *testing.T) {
+	allowed := []string{"172.17.0.0/16", "192.168.1.5"}
+	cases := map[string]bool{
+		"127.0.0.1":   true,
+		"::1":         true,
+		"172.17.0.1":  true,
+		"192.168.1.5": true,
+		"192.168.1.6": false,
+		"10.0.0.7":    false,
+		"not-an-ip":   false,
+	}
+	for ip, want := range cases {
+		if got := clientAllowed(ip, allowed); got != want {
+			t.Errorf("clientAllowed(%q) = %v, want %v", ip, got, want)
+		}
+	}
+	if clientAllowed("172.17.0.1", nil) {
+		t.Error("an empty allowlist should admit loopback only")
+	}
+}
+
+func TestSecurityMiddleware_Allowlist(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	cfg := config.NewConfigManager(srv.engine.GetStorage())
+	cfg.SetEnableAI(true)
+	token := cfg.GetAIToken()
+	s := NewControlServer(srv.engine, cfg, newTestAudit(t))
+
+	status := func(remote string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Tachyon-Token", token)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := status("172.17.0.1:40000"); code != http.StatusForbidden {
+		t.Errorf("outside client = %d, want 403", code)
+	}
+	if err := cfg.SetAIAllowedIPs([]string{"172.17.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := status("172.17.0.1:40000"); code != http.StatusOK {
+		t.Errorf("allowlisted client = %d, want 200", code)
+	}
+	if code := status("10.0.0.7:40000"); code != http.StatusForbidden {
+		t.Errorf("client outside the allowlist = %d, want 403", code)
+	}
+}
+
+func TestSecurityMiddleware_UnauthenticatedRoutesOnNetworkBind(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	cfg := config.NewConfigManager(srv.engine.GetStorage())
+	s := NewControlServer(srv.engine, cfg, newTestAudit(t))
+
+	status := func(remote string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/grab/resolve", strings.NewReader("{}"))
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if err := cfg.SetAIBindAddress("0.0.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetAIAllowedIPs([]string{"172.17.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := status("10.0.0.7:40000"); code != http.StatusForbidden {
+		t.Errorf("client outside the allowlist = %d, want 403", code)
+	}
+	for _, remote := range []string{"172.17.0.1:40000", "127.0.0.1:40000"} {
+		if code := status(remote); code == http.StatusForbidden {
+			t.Errorf("%s was refused", remote)
+		}
+	}
+}
+
+func TestListen_RefusesNetworkBindWithoutAllowlist(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	cfg := config.NewConfigManager(srv.engine.GetStorage())
+	s := NewControlServer(srv.engine, cfg, newTestAudit(t))
+	if err := cfg.SetAIBindAddress("0.0.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Restart(0); err == nil {
+		s.Shutdown()
+		t.Fatal("expected a non-loopback bind with an empty allowlist to be refused")
+	}
+	if s.Port() != 0 {
+		t.Error("server is listening after a refused bind")
+	}
+}
+
+func TestWriteStartError_QueueFull(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if code := writeStartError(rec, fmt.Errorf("wrapped: %w", engine.ErrQueueFull)); code != http.StatusTooManyRequests {
+		t.Errorf("code = %d, want 429", code)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	rec = httptest.NewRecorder()
+	if code := writeStartError(rec, fmt.Errorf("disk on fire")); code != http.StatusInternalServerError {
+		t.Errorf("code = %d, want 500", code)
+	}
+}
+
+func TestHandleGetStatus_ReportsQueueSize(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	srv.engine.SetMaxQueueSize(10)
+	s := &ControlServer{engine: srv.engine}
+
+	rec := httptest.NewRecorder()
+	s.handleGetStatus(rec, httptest.NewRequest("GET", "/v1/status", nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body["queue_size"] != float64(0) || body["max_queue_size"] != float64(10) {
+		t.Errorf("unexpected status body: %v", body)
+	}
+}
+
+func TestHandleGetStatus_ReportsEngineHealth(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	store := srv.engine.GetStorage()
+	store.SaveTask(storage.DownloadTask{ID: "done", Status: storage.StatusCompleted})
+	store.SaveTask(storage.DownloadTask{ID: "waiting", Status: storage.StatusPaused})
+	srv.engine.SetGlobalLimit(1 << 20)
+	s := &ControlServer{engine: srv.engine, cfg: config.NewConfigManager(store)}
+
+	rec := httptest.NewRecorder()
+	s.handleGetStatus(rec, httptest.NewRequest("GET", "/v1/status", nil))
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"version", "uptime_seconds", "active", "queued", "completed", "speed", "session_bytes", "speed_limit", "av_scan", "integrity_check"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("missing %q in status: %v", key, body)
+		}
+	}
+	if body["completed"] != float64(1) {
+		t.Errorf("completed = %v, want 1", body["completed"])
+	}
+	if body["active"] != float64(0) || body["speed"] != float64(0) {
+		t.Errorf("expected an idle engine, got active=%v speed=%v", body["active"], body["speed"])
+	}
+	if body["speed_limit"] != float64(1<<20) {
+		t.Errorf("speed_limit = %v, want %d", body["speed_limit"], 1<<20)
+	}
+	if up, _ := body["uptime_seconds"].(float64); up < 0 {
+		t.Errorf("uptime_seconds = %v", body["uptime_seconds"])
+	}
+	if body["av_scan"] != true {
+		t.Errorf("av_scan = %v, want default true", body["av_scan"])
+	}
+}
+
+func TestHandleGetTask_OmitsCredentials(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	store := srv.engine.GetStorage()
+	store.SaveTask(storage.DownloadTask{
+		ID:       "secret-task",
+		Filename: "private.zip",
+		URL:      "https://example.com/private.zip",
+		Status:   storage.StatusPaused,
+		Headers:  `{"Authorization":"Bearer sk-header-secret"}`,
+		Cookies:  `[{"name":"session","value":"cookie-secret"}]`,
+	})
+	s := &ControlServer{engine: srv.engine, router: chi.NewRouter()}
+	s.router.Get("/v1/tasks/{id}", s.handleGetTask)
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/tasks/secret-task", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, secret := range []string{"sk-header-secret", "cookie-secret", `"headers"`, `"cookies"`} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response leaks %q: %s", secret, body)
+		}
+	}
+	var task PublicTask
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if task.ID != "secret-task" || task.Filename != "private.zip" || task.Status != storage.StatusPaused {
+		t.Errorf("unexpected task: %+v", task)
+	}
+}
+
+func TestPublicTask_WithLiveProgress(t *testing.T) {
+	// The stored task was last saved at 1000 bytes; the transfer is at 3000
+	stored := NewPublicTask(storage.DownloadTask{
+		ID:               "live",
+		Status:           storage.StatusDownloading,
+		TotalSize:        4000,
+		Downloaded:       1000,
+		BytesTransferred: 1000,
+		Progress:         25,
+		Speed:            10,
+	})
+	got := stored.withLiveProgress(engine.LiveProgress{Downloaded: 3000, Transferred: 3200, Speed: 500})
+	if got.Downloaded != 3000 || got.BytesTransferred != 3200 || got.Speed != 500 || got.Progress != 75 {
+		t.Errorf("merged task = %+v, want the live values", got)
+	}
+	if stored.Downloaded != 1000 {
+		t.Error("withLiveProgress modified the stored view")
+	}
+
+	// Unknown size: progress is left alone
+	stream := NewPublicTask(storage.DownloadTask{ID: "stream", Downloaded: 10})
+	if got := stream.withLiveProgress(engine.LiveProgress{Downloaded: 50}); got.Downloaded != 50 || got.Progress != 0 {
+		t.Errorf("merged stream = %+v", got)
+	}
+}
+
+func TestHandleGetTask_StoredValuesWhenNotRunning(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	srv.engine.GetStorage().SaveTask(storage.DownloadTask{
+		ID:         "paused-task",
+		Status:     storage.StatusPaused,
+		TotalSize:  4000,
+		Downloaded: 1000,
+		Progress:   25,
+	})
+	s := &ControlServer{engine: srv.engine, router: chi.NewRouter()}
+	s.router.Get("/v1/tasks/{id}", s.handleGetTask)
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/tasks/paused-task", nil))
+	var task PublicTask
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if task.Downloaded != 1000 || task.Progress != 25 {
+		t.Errorf("task = %+v, want the stored progress", task)
+	}
+}
+
+func TestHandleProbe_StopsWhenClientDisconnects(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	s := &ControlServer{engine: srv.engine}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // client already gone
+	req := httptest.NewRequest("POST", "/v1/probe", strings.NewReader(`{"url":"https://example.com/file.zip"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	s.handleProbe(rec, req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("probe took %v for a disconnected client", elapsed)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected no response for a disconnected client, got %q", rec.Body.String())
+	}
+}
+
+func TestHandleProbe_RejectsInvalidURL(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	s := &ControlServer{engine: srv.engine}
+
+	rec := httptest.NewRecorder()
+	s.handleProbe(rec, httptest.NewRequest("POST", "/v1/probe", strings.NewReader(`{"url":"http://127.0.0.1/x"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for loopback URL, got %d", rec.Code)
+	}
+}
+
+func TestHandleGetAnalytics_MatchesStatsManager(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	store := srv.engine.GetStorage()
+	for i := 0; i < 10; i++ {
+		date := time.Now().AddDate(0, 0, -i).Format("2006-01-02")
+		store.DB.Create(&storage.DailyStat{Date: date, Bytes: int64(1000 * (i + 1)), Files: int64(i + 1)})
+	}
+	cfg := config.NewConfigManager(store)
+	cfg.SetEnableAI(true)
+	s := NewControlServer(srv.engine, cfg, newTestAudit(t))
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/analytics"+query, nil)
+		req.RemoteAddr = "127.0.0.1:5555"
+		if token != "" {
+			req.Header.Set("X-Tachyon-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", rec.Code)
+	}
+
+	token := cfg.GetAIToken()
+	for _, tt := range []struct {
+		query string
+		days  int
+	}{
+		{"", 7},
+		{"?days=3", 3},
+		{"?days=30", 30},
+	} {
+		rec := get(tt.query, token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d: %s", tt.query, rec.Code, rec.Body.String())
+		}
+		var got analytics.AnalyticsData
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%q: invalid JSON: %v", tt.query, err)
+		}
+		want := srv.engine.GetStats().GetAnalyticsForDays(tt.days)
+		if got.TotalDownloaded != want.TotalDownloaded || got.TotalFiles != want.TotalFiles {
+			t.Errorf("%q: totals = %d bytes/%d files, want %d/%d", tt.query, got.TotalDownloaded, got.TotalFiles, want.TotalDownloaded, want.TotalFiles)
+		}
+		if len(got.DailyHistory) != min(tt.days, 10) {
+			t.Errorf("%q: %d days of history, want %d", tt.query, len(got.DailyHistory), min(tt.days, 10))
+		}
+		if fmt.Sprint(got.DailyHistory) != fmt.Sprint(want.DailyHistory) {
+			t.Errorf("%q: daily history = %v, want %v", tt.query, got.DailyHistory, want.DailyHistory)
+		}
+		if got.DiskUsage.TotalGB != want.DiskUsage.TotalGB {
+			t.Errorf("%q: disk total = %v, want %v", tt.query, got.DiskUsage.TotalGB, want.DiskUsage.TotalGB)
+		}
+	}
+	if rec := get("?days=3", token); !strings.Contains(rec.Body.String(), `"total_downloaded":55000`) {
+		t.Errorf("totals should cover every day, not just the window: %s", rec.Body.String())
+	}
+
+	for _, bad := range []string{"?days=0", "?days=-1", "?days=abc", "?days=366"} {
+		if rec := get(bad, token); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+func TestHandleQueueDownload_IdempotencyKey(t *testing.T) {
+	srv := newTestMCPServer(t, &bytes.Buffer{})
+	store := srv.engine.GetStorage()
+	s := &ControlServer{engine: srv.engine, audit: newTestAudit(t), idempotency: newIdempotencyCache(idempotencyTTL)}
+
+	dir := t.TempDir()
+	post := func(key, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/queue", strings.NewReader(fmt.Sprintf(`{"url":%q,"path":%q}`, url, dir)))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.handleQueueDownload(rec, req)
+		return rec
+	}
+	taskID := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp EnqueueResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return resp.TaskID
+	}
+
+	const url = "http://downloads.invalid/file.bin"
+	first := taskID(post("retry-1", url))
+	rec := post("retry-1", url)
+	if again := taskID(rec); again != first {
+		t.Errorf("retry created task %s, want %s", again, first)
+	}
+	if rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response not marked")
+	}
+	if tasks, _ := store.GetAllTasks(); len(tasks) != 1 {
+		t.Errorf("%d tasks stored, want 1", len(tasks))
+	}
+
+	if rec := post("retry-1", "http://downloads.invalid/other.bin"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another URL: status %d, want 422", rec.Code)
+	}
+	if other := taskID(post("retry-2", url)); other == first {
+		t.Error("a new key returned the old task")
+	}
+	if rec := post(strings.Repeat("k", maxIdempotencyKeyLen+1), url); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized key: status %d, want 400", rec.Code)
+	}
+}
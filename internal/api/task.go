@@ -0,0 +1,82 @@
+package api
+
+import (
+	"project-tachyon/internal/engine"
+	"project-tachyon/internal/storage"
+)
+
+// PublicTask is the view of a download returned by the API. It leaves out
+// the request headers and cookies stored with the task, which may carry
+// credentials for the download site.
+type PublicTask struct {
+	ID               string         `json:"id"`
+	Filename         string         `json:"filename"`
+	URL              string         `json:"url"`
+	SavePath         string         `json:"save_path"`
+	Status           storage.Status `json:"status"`
+	Priority         int            `json:"priority"`
+	QueueOrder       int            `json:"queue_order"`
+	Category         string         `json:"category"`
+	TotalSize        int64          `json:"total_size"`
+	Downloaded       int64          `json:"downloaded"`
+	BytesTransferred int64          `json:"bytes_transferred"`
+	Progress         float64        `json:"progress"`
+	Speed            float64        `json:"speed"`
+	TimeRemaining    string         `json:"time_remaining"`
+	FileExists       bool           `json:"file_exists"`
+	ExpectedHash     string         `json:"expected_hash"`
+	HashAlgorithm    string         `json:"hash_algorithm"`
+	StartTime        string         `json:"start_time"`
+	Domain           string         `json:"domain"`
+	Connections      int            `json:"connections"`
+	ReplacePath      string         `json:"replace_path,omitempty"`
+	Debug            bool           `json:"debug"`
+	SetID            string         `json:"set_id,omitempty"`
+	MaxBytes         int64          `json:"max_bytes,omitempty"`
+	CreatedAt        string         `json:"created_at"`
+	UpdatedAt        string         `json:"updated_at"`
+}
+
+// NewPublicTask copies the shareable fields of t.
+func NewPublicTask(t storage.DownloadTask) PublicTask {
+	return PublicTask{
+		ID:               t.ID,
+		Filename:         t.Filename,
+		URL:              t.URL,
+		SavePath:         t.SavePath,
+		Status:           t.Status,
+		Priority:         t.Priority,
+		QueueOrder:       t.QueueOrder,
+		Category:         t.Category,
+		TotalSize:        t.TotalSize,
+		Downloaded:       t.Downloaded,
+		BytesTransferred: t.BytesTransferred,
+		Progress:         t.Progress,
+		Speed:            t.Speed,
+		TimeRemaining:    t.TimeRemaining,
+		FileExists:       t.FileExists,
+		ExpectedHash:     t.ExpectedHash,
+		HashAlgorithm:    t.HashAlgorithm,
+		StartTime:        t.StartTime,
+		Domain:           t.Domain,
+		Connections:      t.Connections,
+		ReplacePath:      t.ReplacePath,
+		Debug:            t.Debug,
+		SetID:            t.SetID,
+		MaxBytes:         t.MaxBytes,
+		CreatedAt:        t.CreatedAt,
+		UpdatedAt:        t.UpdatedAt,
+	}
+}
+
+// withLiveProgress overlays the live progress of a running download on t,
+// whose stored progress is saved only every few seconds.
+func (t PublicTask) withLiveProgress(live engine.LiveProgress) PublicTask {
+	t.Downloaded = live.Downloaded
+	t.BytesTransferred = live.Transferred
+	t.Speed = float64(live.Speed)
+	if t.TotalSize > 0 {
+		t.Progress = float64(live.Downloaded) / float64(t.TotalSize) * 100
+	}
+	return t
+}
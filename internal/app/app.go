@@ -7,11 +7,16 @@ import (
 	"log/slog"
 	"sync"
 
+	"project-tachyon/internal/api"
 	"project-tachyon/internal/config"
 	"project-tachyon/internal/engine"
+	"project-tachyon/internal/filesystem"
 	"project-tachyon/internal/logger"
+	"project-tachyon/internal/platform"
 	"project-tachyon/internal/security"
+	"project-tachyon/internal/storage"
 
+	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -25,9 +30,26 @@ type App struct {
 	cfg          *config.ConfigManager
 	audit        *security.AuditLogger
 	isQuitting   bool
+	quitCh       chan struct{}
+	quitOnce     sync.Once
+	trayCh       chan struct{} // signalled when the tray's download list may have changed
 
 	speedTestMu     sync.Mutex
 	speedTestCancel context.CancelFunc
+
+	suspendMu    sync.Mutex
+	suspendedIDs []string // downloads paused by EmergencyStop, resumed by OnResume
+
+	windowMu        sync.Mutex
+	pauseWhenHidden bool
+	windowHidden    bool
+	hiddenIDs       []string // downloads paused while the window was hidden
+
+	controlServer *api.ControlServer // restarted by ReloadSettings; nil when not serving
+	launchArgs    []string           // command line, searched for tachyon:// links in Startup
+
+	openFile func(path string) error   // filesystem.OpenFile; replaced in tests
+	quit     func(ctx context.Context) // runtime.Quit; replaced in tests
 }
 
 // NewApp creates a new App application struct with all dependencies injected.
@@ -45,6 +67,10 @@ func NewApp(
 		cfg:          cfg,
 		audit:        audit,
 		isQuitting:   false,
+		quitCh:       make(chan struct{}),
+		trayCh:       make(chan struct{}, 1),
+		openFile:     filesystem.OpenFile,
+		quit:         runtime.Quit,
 	}
 }
 
@@ -53,6 +79,7 @@ func NewApp(
 func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
 	a.engine.SetContext(ctx)
+	a.applySettings()
 	if a.wailsHandler != nil {
 		a.wailsHandler.SetContext(ctx)
 	}
@@ -61,6 +88,50 @@ func (a *App) Startup(ctx context.Context) {
 	if a.audit != nil {
 		a.audit.SetContext(ctx)
 	}
+	// Startup only runs in the primary instance; a second launch hands its
+	// arguments over through HandleSecondInstance instead.
+	a.handleProtocolArgs(a.launchArgs)
+}
+
+// SetLaunchArgs gives the app its command-line arguments. Any tachyon://
+// link among them is enqueued in Startup.
+func (a *App) SetLaunchArgs(args []string) {
+	a.launchArgs = args
+}
+
+// applySettings pushes the saved configuration into the engine and the
+// window behaviour. It runs at startup and again on ReloadSettings.
+func (a *App) applySettings() {
+	if a.cfg == nil {
+		return
+	}
+	ApplySettings(a.cfg, a.engine, a.logger)
+	a.windowMu.Lock()
+	a.pauseWhenHidden = a.cfg.GetPauseWhenHidden()
+	a.windowMu.Unlock()
+}
+
+// ReloadSettings re-applies the saved configuration without restarting the
+// app: engine settings are pushed again, the engine rebuilds its transport,
+// and the control server moves to the configured port if it changed.
+// Running downloads keep going.
+func (a *App) ReloadSettings() error {
+	a.logger.Info("frontend_request", "method", "ReloadSettings")
+	a.applySettings()
+	a.engine.Reload()
+	if a.controlServer != nil && a.cfg != nil {
+		if err := a.controlServer.Restart(a.cfg.GetAIPort()); err != nil {
+			a.logger.Error("Failed to restart control server", "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// SetControlServer gives the app the control server to restart when its
+// port setting changes.
+func (a *App) SetControlServer(s *api.ControlServer) {
+	a.controlServer = s
 }
 
 // BeforeClose is called when the application is about to close.
@@ -73,17 +144,88 @@ func (a *App) BeforeClose(ctx context.Context) (prevent bool) {
 	// Hide window instead of closing
 	a.logger.Info("Window close requested, minimizing to tray")
 	runtime.WindowHide(ctx)
+	a.SetWindowVisible(false)
 	return true // Prevent close
 }
 
 // QuitApp is called from the Tray menu to truly exit
 func (a *App) QuitApp() {
 	a.isQuitting = true
+	a.quitOnce.Do(func() { close(a.quitCh) })
 	// Ensure engine shuts down gracefully
 	if err := a.engine.Shutdown(); err != nil {
 		a.logger.Error("Error during shutdown", "error", err)
 	}
-	runtime.Quit(a.ctx)
+	a.quit(a.ctx)
+}
+
+// EmergencyStop pauses every download and persists its progress as fast as
+// possible. It is called on system suspend and forced termination.
+func (a *App) EmergencyStop() {
+	ids, err := a.engine.EmergencyStop(engine.DefaultEmergencyTimeout)
+	if err != nil {
+		a.logger.Error("Emergency stop failed", "error", err)
+	}
+	a.suspendMu.Lock()
+	a.suspendedIDs = append(a.suspendedIDs, ids...)
+	a.suspendMu.Unlock()
+}
+
+// OnResume restarts the downloads EmergencyStop paused when the system
+// wakes from suspend.
+func (a *App) OnResume() {
+	a.suspendMu.Lock()
+	ids := a.suspendedIDs
+	a.suspendedIDs = nil
+	a.suspendMu.Unlock()
+	for _, id := range ids {
+		if err := a.engine.ResumeDownload(id); err != nil {
+			a.logger.Warn("Failed to resume download after suspend", "id", id, "error", err)
+		}
+	}
+}
+
+// SetWindowVisible is told when the window is minimized or hidden to the
+// tray and when it comes back: the frontend reports page visibility
+// changes, and the tray's hide and restore call it directly. With
+// pause-when-hidden on, hiding pauses every download and holds the queue,
+// so one added while hidden waits as pending; showing releases the queue
+// and resumes the ones it paused, leaving any the user paused or stopped
+// meanwhile.
+func (a *App) SetWindowVisible(visible bool) {
+	a.windowMu.Lock()
+	defer a.windowMu.Unlock()
+	if a.windowHidden == !visible {
+		return
+	}
+	a.windowHidden = !visible
+	if !visible {
+		if a.pauseWhenHidden {
+			a.engine.HoldDispatch(true)
+			ids := a.engine.SuspendDownloads()
+			a.logger.Info("Window hidden, pausing downloads", "paused", len(ids))
+			a.hiddenIDs = append(a.hiddenIDs, ids...)
+		}
+		return
+	}
+	a.resumeHidden()
+}
+
+// resumeHidden releases the queue and resumes the downloads paused while
+// the window was hidden that are still paused. windowMu must be held.
+func (a *App) resumeHidden() {
+	a.engine.HoldDispatch(false)
+	ids := a.hiddenIDs
+	a.hiddenIDs = nil
+	for _, id := range ids {
+		task, err := a.engine.GetStorage().GetTask(id)
+		if err != nil || task.Status != storage.StatusPaused {
+			continue
+		}
+		if err := a.engine.ResumeDownload(id); err != nil {
+			a.logger.Warn("Failed to resume download after window restore", "id", id, "error", err)
+		}
+	}
 }
 
 // ShowApp is called from the Tray menu to restore the window
@@ -94,9 +236,30 @@ func (a *App) ShowApp() {
 	}
 	runtime.WindowSetAlwaysOnTop(a.ctx, true) // Bring to front
 	runtime.WindowSetAlwaysOnTop(a.ctx, false)
+	a.SetWindowVisible(true)
 }
 
 // GetContext returns the Wails context for emitting events from other bridge files
 func (a *App) GetContext() context.Context {
 	return a.ctx
 }
+
+// HandleSecondInstance is invoked when another launch of Tachyon is blocked by
+// the single-instance lock. The window is focused and any tachyon:// links
+// passed to the new process are enqueued here.
+func (a *App) HandleSecondInstance(data options.SecondInstanceData) {
+	a.logger.Info("Second instance launched", "args", data.Args)
+	a.handleProtocolArgs(data.Args)
+	if a.ctx != nil {
+		a.ShowApp()
+	}
+}
+
+// handleProtocolArgs enqueues every tachyon:// link in args.
+func (a *App) handleProtocolArgs(args []string) {
+	for _, arg := range args {
+		if platform.IsProtocolURI(arg) {
+			a.HandleProtocolURL(arg)
+		}
+	}
+}
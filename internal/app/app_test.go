@@ -2,11 +2,16 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"project-tachyon/internal/config"
 	"project-tachyon/internal/engine"
@@ -267,6 +272,79 @@ func TestAddDownloadLocation(t *testing.T) {
 	}
 }
 
+func TestAddDownloadLocation_Validates(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	missing := filepath.Join(t.TempDir(), "new", "folder")
+	if err := a.AddDownloadLocation(missing, "created"); err != nil {
+		t.Fatalf("missing folder: %v", err)
+	}
+	if info, err := os.Stat(missing); err != nil || !info.IsDir() {
+		t.Errorf("missing folder was not created: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+	if err := a.AddDownloadLocation(file, "file"); err == nil || !strings.Contains(err.Error(), "not a folder") {
+		t.Errorf("file accepted as a location: %v", err)
+	}
+	if err := a.ValidateLocation(""); err == nil {
+		t.Error("empty path accepted")
+	}
+
+	locs := a.GetDownloadLocations()
+	if len(locs) != 1 || locs[0].Path != missing {
+		t.Errorf("locations = %+v, want only %s", locs, missing)
+	}
+}
+
+func TestValidateLocation_ReadOnly(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced here")
+	}
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+	if err := a.ValidateLocation(dir); err == nil {
+		t.Error("read-only folder accepted")
+	}
+	if err := a.AddDownloadLocation(dir, "ro"); err == nil {
+		t.Error("read-only folder saved")
+	}
+}
+
+func TestGetDownloadLocations_FreeSpace(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	a.AddDownloadLocation(dir, "present")
+	// A drive that was unplugged after the location was saved
+	if err := a.AddDownloadLocation(filepath.Join(dir, "unplugged"), "missing"); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, "unplugged"))
+
+	byPath := make(map[string]DownloadLocationInfo)
+	for _, loc := range a.GetDownloadLocations() {
+		byPath[loc.Path] = loc
+	}
+	present := byPath[dir]
+	if !present.Available || present.TotalGB <= 0 || present.FreeGB <= 0 || present.FreeGB > present.TotalGB {
+		t.Errorf("temp location = %+v, want available with free space", present)
+	}
+	missing := byPath[filepath.Join(dir, "unplugged")]
+	if missing.Nickname != "missing" || missing.Available || missing.FreeGB != 0 {
+		t.Errorf("missing location = %+v, want unavailable", missing)
+	}
+}
+
 // --- Download operations ---
 
 func TestAddDownload_InvalidURL(t *testing.T) {
@@ -282,6 +360,52 @@ func TestAddDownload_InvalidURL(t *testing.T) {
 	}
 }
 
+func TestHandleProtocolURL_EnqueuesDownload(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+	t.Setenv("TACHYON_DOWNLOAD_DIR", t.TempDir())
+
+	id, err := a.HandleProtocolURL("tachyon://download?url=https%3A%2F%2Fexample.com%2Ffile.zip&filename=..%2F..%2Fevil.zip")
+	if err != nil {
+		t.Fatalf("HandleProtocolURL failed: %v", err)
+	}
+	task, err := a.engine.GetTask(id)
+	if err != nil {
+		t.Fatalf("task not stored: %v", err)
+	}
+	if task.URL != "https://example.com/file.zip" {
+		t.Errorf("URL = %q", task.URL)
+	}
+	if strings.Contains(task.Filename, "..") || strings.ContainsAny(task.Filename, "/\\") {
+		t.Errorf("filename not sanitized: %q", task.Filename)
+	}
+}
+
+func TestSetLaunchArgs_EnqueuedOnlyAtStartup(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+	t.Setenv("TACHYON_DOWNLOAD_DIR", t.TempDir())
+
+	a.SetLaunchArgs([]string{"--minimized", "tachyon://download?url=https%3A%2F%2Fexample.com%2Ffile.zip"})
+	if tasks, _ := a.engine.GetHistory(); len(tasks) != 0 {
+		t.Fatalf("%d tasks enqueued before Startup", len(tasks))
+	}
+	a.handleProtocolArgs(a.launchArgs)
+	tasks, _ := a.engine.GetHistory()
+	if len(tasks) != 1 || tasks[0].URL != "https://example.com/file.zip" {
+		t.Errorf("tasks = %+v, want the launch link", tasks)
+	}
+}
+
+func TestHandleProtocolURL_Invalid(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	if _, err := a.HandleProtocolURL("tachyon://download?url=file%3A%2F%2F%2Fetc%2Fpasswd"); err == nil {
+		t.Error("expected error for non-http url")
+	}
+}
+
 func TestPauseDownload_NonExistent(t *testing.T) {
 	a, cleanup := newTestApp(t)
 	defer cleanup()
@@ -383,3 +507,177 @@ func TestUpdateSettings_BoolAndNumericValues(t *testing.T) {
 		t.Errorf("expected '42', got %q", val)
 	}
 }
+
+func TestGetAllSettings_RoundTripsUpdateSettings(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	before := a.GetAllSettings()
+	if before[config.KeyAIPort] != 4444 || before[config.KeyWatchDownloadDirs] != true {
+		t.Fatalf("unexpected defaults: %v", before)
+	}
+
+	a.UpdateSettings(`{"ai_port":5000,"watch_download_dirs":false}`)
+	after := a.GetAllSettings()
+	if after[config.KeyAIPort] != 5000 {
+		t.Errorf("ai_port = %v, want 5000", after[config.KeyAIPort])
+	}
+	if after[config.KeyWatchDownloadDirs] != false {
+		t.Errorf("watch_download_dirs = %v, want false", after[config.KeyWatchDownloadDirs])
+	}
+}
+
+func TestReloadSettings_AppliesSavedConfig(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	if err := a.cfg.SetNetworkProfile("conservative"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.cfg.SetCollisionStrategy(engine.CollisionSkip); err != nil {
+		t.Fatal(err)
+	}
+	if a.engine.GetNetworkProfile() == "conservative" {
+		t.Fatal("setting applied before reload")
+	}
+
+	if err := a.ReloadSettings(); err != nil {
+		t.Fatalf("ReloadSettings: %v", err)
+	}
+	if got := a.engine.GetNetworkProfile(); got != "conservative" {
+		t.Errorf("network profile = %s, want conservative", got)
+	}
+	if got := a.engine.GetCollisionStrategy(); got != engine.CollisionSkip {
+		t.Errorf("collision strategy = %s, want %s", got, engine.CollisionSkip)
+	}
+}
+
+func TestOpenFile_RechecksIntegrity(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+	var opened []string
+	a.openFile = func(path string) error {
+		opened = append(opened, path)
+		return nil
+	}
+
+	content := []byte("critical release image")
+	sum := sha256.Sum256(content)
+	path := filepath.Join(t.TempDir(), "release.iso")
+	os.WriteFile(path, content, 0644)
+	a.engine.GetStorage().SaveTask(storage.DownloadTask{
+		ID:           "iso",
+		Status:       storage.StatusCompleted,
+		SavePath:     path,
+		ExpectedHash: hex.EncodeToString(sum[:]),
+	})
+	if err := a.SetVerifyOnOpen(true); err != nil {
+		t.Fatal(err)
+	}
+	if !a.GetVerifyOnOpen() {
+		t.Fatal("verify-on-open not enabled")
+	}
+
+	a.OpenFile("iso")
+	if len(opened) != 1 {
+		t.Fatalf("intact file was not opened (opened %v)", opened)
+	}
+
+	os.WriteFile(path, []byte("critical release imagE"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	a.OpenFile("iso")
+	if len(opened) != 1 {
+		t.Error("corrupted file was opened despite failing its recheck")
+	}
+}
+
+func TestSetWindowVisible_PausesWhileHidden(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+	store := a.engine.GetStorage()
+	save := func(id string, status storage.Status) {
+		store.SaveTask(storage.DownloadTask{ID: id, URL: "http://127.0.0.1:1/" + id, Status: status})
+	}
+	status := func(id string) storage.Status {
+		task, _ := store.GetTask(id)
+		return task.Status
+	}
+
+	// Off by default: hiding the window leaves downloads alone
+	save("queued", storage.StatusPending)
+	a.SetWindowVisible(false)
+	if got := status("queued"); got != storage.StatusPending {
+		t.Fatalf("status = %s with the option off, want pending", got)
+	}
+	a.SetWindowVisible(true)
+
+	if err := a.SetPauseWhenHidden(true); err != nil {
+		t.Fatal(err)
+	}
+	if !a.GetPauseWhenHidden() || !a.cfg.GetPauseWhenHidden() {
+		t.Fatal("pause-when-hidden not enabled")
+	}
+	save("mine", storage.StatusPaused) // paused by the user beforehand
+	save("later", storage.StatusPending)
+	a.SetWindowVisible(false)
+	for _, id := range []string{"queued", "later"} {
+		if got := status(id); got != storage.StatusPaused {
+			t.Errorf("%s status = %s while hidden, want paused", id, got)
+		}
+	}
+	// Hiding to the tray after minimizing is still one hide
+	a.SetWindowVisible(false)
+	// The user stops one of them from the tray while hidden
+	if err := a.engine.StopDownload("later"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.SetWindowVisible(true)
+	if got := status("queued"); got == storage.StatusPaused {
+		t.Error("download paused by the hide was not resumed")
+	}
+	if got := status("mine"); got != storage.StatusPaused {
+		t.Errorf("download the user paused was resumed (status %s)", got)
+	}
+	if got := status("later"); got != storage.StatusStopped {
+		t.Errorf("download the user stopped was resumed (status %s)", got)
+	}
+}
+
+func TestSetWindowVisible_HoldsDownloadsStartedWhileHidden(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+	store := a.engine.GetStorage()
+	status := func() storage.Status {
+		task, _ := store.GetTask("new")
+		return task.Status
+	}
+	if err := a.SetPauseWhenHidden(true); err != nil {
+		t.Fatal(err)
+	}
+	a.SetWindowVisible(false)
+
+	// Started from the tray while hidden: it is queued but not dispatched
+	store.SaveTask(storage.DownloadTask{ID: "new", URL: "http://127.0.0.1:1/new", Status: storage.StatusPaused})
+	if err := a.engine.ResumeDownload("new"); err != nil {
+		t.Fatal(err)
+	}
+	for end := time.Now().Add(300 * time.Millisecond); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+		if a.engine.Status().Active > 0 {
+			t.Fatal("download started while the window was hidden")
+		}
+	}
+	if got := status(); got != storage.StatusPending {
+		t.Fatalf("status = %s while hidden, want pending", got)
+	}
+
+	a.SetWindowVisible(true)
+	deadline := time.Now().Add(10 * time.Second)
+	for a.engine.Status().Active == 0 && status() == storage.StatusPending {
+		if time.Now().After(deadline) {
+			t.Fatal("download still not dispatched after the window came back")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
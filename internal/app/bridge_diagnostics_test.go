@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"project-tachyon/internal/security"
+)
+
+// mockScanner reports the result of scan for every file, recording what
+// it was given.
+type mockScanner struct {
+	available bool
+	scan      func(content string) error
+	scanned   string
+}
+
+func (m *mockScanner) Name() string      { return "Mock AV" }
+func (m *mockScanner) IsAvailable() bool { return m.available }
+func (m *mockScanner) ScanFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m.scanned = string(data)
+	return m.scan(m.scanned)
+}
+
+func TestTestScanner_NoOpScanner(t *testing.T) {
+	app, cleanup := newTestApp(t)
+	defer cleanup()
+	app.engine.SetScanner(security.NewNoOpScanner(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	res := app.TestScanner()
+	if res.Available || res.Detected {
+		t.Errorf("no-op scanner reported available=%v detected=%v", res.Available, res.Detected)
+	}
+	if res.Message == "" || !strings.Contains(res.Scanner, "NoOp") {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestTestScanner_MockScanner(t *testing.T) {
+	app, cleanup := newTestApp(t)
+	defer cleanup()
+
+	cases := []struct {
+		name     string
+		scan     func(string) error
+		detected bool
+		message  string
+	}{
+		{
+			name: "detects",
+			scan: func(content string) error {
+				if strings.Contains(content, "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					return fmt.Errorf("%w: Eicar-Test-Signature", security.ErrThreatDetected)
+				}
+				return nil
+			},
+			detected: true,
+			message:  "Eicar-Test-Signature",
+		},
+		{
+			name:    "misses",
+			scan:    func(string) error { return nil },
+			message: "did not detect",
+		},
+		{
+			name:    "fails",
+			scan:    func(string) error { return errors.New("daemon unreachable") },
+			message: "daemon unreachable",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &mockScanner{available: true, scan: tc.scan}
+			app.engine.SetScanner(m)
+
+			res := app.TestScanner()
+			if !res.Available || res.Scanner != "Mock AV" {
+				t.Errorf("result = %+v", res)
+			}
+			if res.Detected != tc.detected {
+				t.Errorf("detected = %v, want %v (%s)", res.Detected, tc.detected, res.Message)
+			}
+			if !strings.Contains(res.Message, tc.message) {
+				t.Errorf("message %q does not mention %q", res.Message, tc.message)
+			}
+			if !strings.HasPrefix(m.scanned, "X5O!P%@AP") {
+				t.Errorf("scanner was given %q, want the EICAR test file", m.scanned)
+			}
+		})
+	}
+}
+
+func TestTestWebhook_NotConfigured(t *testing.T) {
+	app, cleanup := newTestApp(t)
+	defer cleanup()
+	if err := app.TestWebhook(); err == nil {
+		t.Error("expected an error with no webhook configured")
+	}
+}
@@ -1,11 +1,15 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"time"
 
 	"project-tachyon/internal/engine"
+	"project-tachyon/internal/extractor"
 	"project-tachyon/internal/filesystem"
-	"project-tachyon/internal/storage"
+	"project-tachyon/internal/platform"
 )
 
 // AddDownload is exposed to the Frontend
@@ -91,6 +95,13 @@ func (a *App) AddDownloadWithParams(url, path, filename string, options map[stri
 	return id, nil
 }
 
+// ExtractLinks lists the download links found on a web page, best first, so
+// the user can pick which one to download
+func (a *App) ExtractLinks(pageURL string) ([]extractor.Candidate, error) {
+	a.logger.Info("frontend_request", "method", "ExtractLinks", "url", pageURL)
+	return a.engine.ExtractLinks(pageURL)
+}
+
 // GetDefaultDownloadPath returns the system default download directory
 func (a *App) GetDefaultDownloadPath() string {
 	path, err := filesystem.GetDefaultDownloadPath()
@@ -101,21 +112,85 @@ func (a *App) GetDefaultDownloadPath() string {
 	return path
 }
 
-// GetDownloadLocations returns saved download paths
-func (a *App) GetDownloadLocations() []storage.DownloadLocation {
+// DownloadLocationInfo is a saved download location with the space left on
+// its drive
+type DownloadLocationInfo struct {
+	Path       string  `json:"path"`
+	Nickname   string  `json:"nickname"`
+	WriteLimit int64   `json:"write_limit"`
+	Available  bool    `json:"available"` // false if the folder is missing or its drive is offline
+	FreeGB     float64 `json:"free_gb"`
+	TotalGB    float64 `json:"total_gb"`
+}
+
+// GetDownloadLocations returns saved download paths with the free space on
+// each one's drive
+func (a *App) GetDownloadLocations() []DownloadLocationInfo {
 	locs, err := a.engine.GetStorage().GetLocations()
 	if err != nil {
 		a.logger.Error("Failed to get download locations", "error", err)
-		return []storage.DownloadLocation{}
+		return []DownloadLocationInfo{}
+	}
+
+	const bytesPerGB = 1024 * 1024 * 1024
+	infos := make([]DownloadLocationInfo, 0, len(locs))
+	for _, loc := range locs {
+		info := DownloadLocationInfo{Path: loc.Path, Nickname: loc.Nickname, WriteLimit: loc.WriteLimit}
+		if free, total, err := filesystem.DiskSpace(loc.Path); err == nil {
+			info.Available = true
+			info.FreeGB = float64(free) / bytesPerGB
+			info.TotalGB = float64(total) / bytesPerGB
+		} else {
+			a.logger.Debug("Download location unavailable", "path", loc.Path, "error", err)
+		}
+		infos = append(infos, info)
 	}
-	return locs
+	return infos
 }
 
-// AddDownloadLocation saves a new download path
-func (a *App) AddDownloadLocation(path, nickname string) {
-	if err := a.engine.GetStorage().AddLocation(path, nickname); err != nil {
+// ValidateLocation reports why a folder cannot be used as a download
+// location, creating it if missing; nil means it is usable
+func (a *App) ValidateLocation(path string) error {
+	_, err := engine.ValidateLocation(path)
+	return err
+}
+
+// AddDownloadLocation saves a new download path after checking that
+// downloads can be written there
+func (a *App) AddDownloadLocation(path, nickname string) error {
+	a.logger.Info("frontend_request", "method", "AddDownloadLocation", "path", path)
+	abs, err := engine.ValidateLocation(path)
+	if err != nil {
+		a.logger.Warn("Rejected download location", "path", path, "error", err)
+		return err
+	}
+	if err := a.engine.GetStorage().AddLocation(abs, nickname); err != nil {
 		a.logger.Error("Failed to add download location", "error", err)
+		return err
 	}
+	return nil
+}
+
+// MigrateLocation moves every completed download saved under oldPath to
+// newPath, e.g. before retiring a drive; with updateSaved the saved
+// location is moved too. Unfinished downloads are left where they are.
+func (a *App) MigrateLocation(oldPath, newPath string, updateSaved bool) (engine.MigrationResult, error) {
+	a.logger.Info("frontend_request", "method", "MigrateLocation", "from", oldPath, "to", newPath)
+	return a.engine.MigrateLocation(oldPath, newPath, updateSaved)
+}
+
+// SetLocationWriteLimit caps how fast downloads write to a folder in
+// bytes/sec, for slow disks or network shares (0 = unlimited)
+func (a *App) SetLocationWriteLimit(path string, bytesPerSec int64) error {
+	a.logger.Info("frontend_request", "method", "SetLocationWriteLimit", "path", path, "bytes_per_sec", bytesPerSec)
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	if err := a.engine.GetStorage().SetLocationWriteLimit(path, bytesPerSec); err != nil {
+		return err
+	}
+	a.engine.SetDiskWriteLimit(path, bytesPerSec)
+	return nil
 }
 
 // PauseDownload pauses/cancels an active download
@@ -142,7 +217,7 @@ func (a *App) PauseAllDownloads() {
 	a.engine.PauseAllDownloads()
 }
 
-// ResumeAllDownloads resumes all downloads
+// ResumeAllDownloads resumes the downloads paused by PauseAllDownloads
 func (a *App) ResumeAllDownloads() {
 	a.logger.Info("frontend_request", "method", "ResumeAllDownloads")
 	a.engine.ResumeAllDownloads()
@@ -155,6 +230,27 @@ func (a *App) UpdateDownloadURL(taskID, newURL string) error {
 	return a.engine.UpdateDownloadURL(taskID, newURL)
 }
 
+// RefreshDownloadAuth swaps in a new URL, headers and cookies for a download
+// that lost access and resumes it once the new link probes successfully
+func (a *App) RefreshDownloadAuth(taskID, newURL, headersJSON, cookiesJSON string) error {
+	a.logger.Info("frontend_request", "method", "RefreshDownloadAuth", "taskID", taskID)
+	if err := a.engine.RefreshDownloadAuth(taskID, newURL, headersJSON, cookiesJSON); err != nil {
+		a.logger.Error("Failed to refresh download auth", "id", taskID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// RelocateAndResume moves an interrupted download to a new folder and resumes it
+func (a *App) RelocateAndResume(id, newPath string) error {
+	a.logger.Info("frontend_request", "method", "RelocateAndResume", "id", id, "path", newPath)
+	if err := a.engine.RelocateAndResume(id, newPath); err != nil {
+		a.logger.Error("Failed to relocate download", "id", id, "error", err)
+		return err
+	}
+	return nil
+}
+
 // StopDownload stops a download permanently (can still be resumed manually)
 func (a *App) StopDownload(id string) {
 	a.logger.Info("frontend_request", "method", "StopDownload", "id", id)
@@ -183,6 +279,60 @@ func (a *App) BulkDeleteDownloads(ids []string, deleteFile bool) error {
 	return nil
 }
 
+// StartDownloadSet queues a group of related URLs as one download set.
+// An empty dir uses the default download folder.
+func (a *App) StartDownloadSet(name string, urls []string, dir string) (string, error) {
+	a.logger.Info("frontend_request", "method", "StartDownloadSet", "name", name, "count", len(urls), "dir", dir)
+
+	if dir == "" {
+		var err error
+		dir, err = filesystem.GetDefaultDownloadPath()
+		if err != nil {
+			a.logger.Error("Failed to get default download path", "error", err)
+			return "", fmt.Errorf("failed to resolve download path: %w", err)
+		}
+	}
+
+	id, err := a.engine.StartDownloadSet(name, urls, dir)
+	if err != nil {
+		a.logger.Error("Failed to start download set", "error", err)
+		return "", err
+	}
+	return id, nil
+}
+
+// GetDownloadSets returns every download set with its combined progress
+func (a *App) GetDownloadSets() ([]engine.DownloadSetProgress, error) {
+	return a.engine.GetDownloadSets()
+}
+
+// GetDownloadSetProgress returns the combined progress of one download set
+func (a *App) GetDownloadSetProgress(id string) (engine.DownloadSetProgress, error) {
+	return a.engine.GetDownloadSetProgress(id)
+}
+
+// PauseDownloadSet pauses every download in a set
+func (a *App) PauseDownloadSet(id string) error {
+	a.logger.Info("frontend_request", "method", "PauseDownloadSet", "id", id)
+	return a.engine.PauseDownloadSet(id)
+}
+
+// ResumeDownloadSet resumes the paused and failed downloads in a set
+func (a *App) ResumeDownloadSet(id string) error {
+	a.logger.Info("frontend_request", "method", "ResumeDownloadSet", "id", id)
+	return a.engine.ResumeDownloadSet(id)
+}
+
+// DeleteDownloadSet removes a download set and its downloads
+func (a *App) DeleteDownloadSet(id string, deleteFiles bool) error {
+	a.logger.Info("frontend_request", "method", "DeleteDownloadSet", "id", id, "deleteFiles", deleteFiles)
+	if err := a.engine.DeleteDownloadSet(id, deleteFiles); err != nil {
+		a.logger.Error("Failed to delete download set", "error", err)
+		return err
+	}
+	return nil
+}
+
 // ReorderDownload moves a download in the queue
 // direction: "first", "prev", "next", "last"
 func (a *App) ReorderDownload(id string, direction string) error {
@@ -190,10 +340,39 @@ func (a *App) ReorderDownload(id string, direction string) error {
 	return a.engine.ReorderDownload(id, direction)
 }
 
+// PromoteDownload moves a download to the front of the queue, optionally
+// preempting a running download so it starts right away
+func (a *App) PromoteDownload(id string, preempt bool) error {
+	a.logger.Info("frontend_request", "method", "PromoteDownload", "id", id, "preempt", preempt)
+	return a.engine.PromoteDownload(id, preempt)
+}
+
+// SetDownloadConnections changes how many connections a download uses,
+// live if it is running; 0 returns it to automatic tuning
+func (a *App) SetDownloadConnections(id string, n int) error {
+	a.logger.Info("frontend_request", "method", "SetDownloadConnections", "id", id, "n", n)
+	return a.engine.SetDownloadConnections(id, n)
+}
+
+// ReorderBatch moves several queued downloads at once
+func (a *App) ReorderBatch(ids []string, position string) error {
+	a.logger.Info("frontend_request", "method", "ReorderBatch", "count", len(ids), "position", position)
+	return a.engine.ReorderBatch(ids, position)
+}
+
+// SetPriorityBatch sets the priority of several downloads at once
+func (a *App) SetPriorityBatch(ids []string, priority int) error {
+	a.logger.Info("frontend_request", "method", "SetPriorityBatch", "count", len(ids), "priority", priority)
+	return a.engine.SetPriorityBatch(ids, priority)
+}
+
 // SetGlobalSpeedLimit sets the global download speed limit
 func (a *App) SetGlobalSpeedLimit(bytesPerSec int) {
 	a.logger.Info("frontend_request", "method", "SetGlobalSpeedLimit", "bytesPerSec", bytesPerSec)
 	a.engine.SetGlobalLimit(bytesPerSec)
+	if a.cfg != nil {
+		a.cfg.SetGlobalSpeedLimit(max(bytesPerSec, 0))
+	}
 }
 
 // UpdateScheduledTime updates the start time for all scheduled downloads
@@ -202,10 +381,207 @@ func (a *App) UpdateScheduledTime(startTimeRFC3339 string) error {
 	return a.engine.UpdateScheduledTime(startTimeRFC3339)
 }
 
-// SetMaxConcurrentDownloads sets the maximum number of concurrent downloads
+// GetPartIdleTimeout returns the per-part idle timeout in seconds (0 = adaptive)
+func (a *App) GetPartIdleTimeout() int {
+	return int(a.engine.GetPartIdleTimeout() / time.Second)
+}
+
+// SetPartIdleTimeout sets how many seconds a part may receive no data before
+// it is retried on a new connection (0 = adaptive)
+func (a *App) SetPartIdleTimeout(seconds int) {
+	a.logger.Info("frontend_request", "method", "SetPartIdleTimeout", "seconds", seconds)
+	if seconds < 0 {
+		seconds = 0
+	}
+	a.engine.SetPartIdleTimeout(time.Duration(seconds) * time.Second)
+	if a.cfg != nil {
+		a.cfg.SetPartIdleTimeout(seconds)
+	}
+}
+
+// GetProbeRetries returns how many times a failed probe is retried
+func (a *App) GetProbeRetries() int {
+	return a.engine.GetProbeRetries()
+}
+
+// SetProbeRetries sets how many times a download's probe is retried after a
+// timeout, reset or server error before the download fails (0 = no retries)
+func (a *App) SetProbeRetries(n int) {
+	a.logger.Info("frontend_request", "method", "SetProbeRetries", "n", n)
+	if n < 0 {
+		n = 0
+	}
+	a.engine.SetProbeRetries(n)
+	if a.cfg != nil {
+		a.cfg.SetProbeRetries(n)
+	}
+}
+
+// GetMinPartSizeKB returns the smallest final part, in KB, a download is
+// split into
+func (a *App) GetMinPartSizeKB() int {
+	return int(a.engine.GetMinPartSize() / 1024)
+}
+
+// SetMinPartSizeKB sets the smallest final part in KB; a shorter tail is
+// merged into the part before it (0 = never merge). It applies to
+// downloads planned from now on.
+func (a *App) SetMinPartSizeKB(kb int) {
+	a.logger.Info("frontend_request", "method", "SetMinPartSizeKB", "kb", kb)
+	if kb < 0 {
+		kb = 0
+	}
+	a.engine.SetMinPartSize(int64(kb) * 1024)
+	if a.cfg != nil {
+		a.cfg.SetMinPartSizeKB(kb)
+	}
+}
+
+// GetTreat416AsComplete reports whether a part starting at the end of the
+// file counts as complete when the server answers 416
+func (a *App) GetTreat416AsComplete() bool {
+	return a.engine.GetTreat416AsComplete()
+}
+
+// SetTreat416AsComplete sets whether a 416 Range Not Satisfiable that puts
+// the end of the file where a part starts completes the download at that
+// size, instead of failing it because the file changed on the server
+func (a *App) SetTreat416AsComplete(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetTreat416AsComplete", "enabled", enabled)
+	a.engine.SetTreat416AsComplete(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetTreat416AsComplete(enabled)
+	}
+	return nil
+}
+
+// SetDownloadDebug turns header logging on or off for a download; it
+// applies from the download's next start
+func (a *App) SetDownloadDebug(id string, enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetDownloadDebug", "id", id, "enabled", enabled)
+	return a.engine.SetDownloadDebug(id, enabled)
+}
+
+// GetDownloadDebugLog returns the request and response headers logged for
+// a download with debugging on (credentials redacted)
+func (a *App) GetDownloadDebugLog(id string) (string, error) {
+	return a.engine.GetDownloadDebugLog(id)
+}
+
+// ExportAsCurl returns a curl command that reproduces a download, with
+// cookies and authorization headers optionally redacted
+func (a *App) ExportAsCurl(id string, redact bool) (string, error) {
+	return a.engine.ExportAsCurl(id, redact)
+}
+
+// ExportAsWget returns a wget command that reproduces a download, with
+// cookies and authorization headers optionally redacted
+func (a *App) ExportAsWget(id string, redact bool) (string, error) {
+	return a.engine.ExportAsWget(id, redact)
+}
+
+// ExportTaskState returns a token that moves an unfinished download to
+// another Tachyon instance. It includes the download's cookies and headers.
+func (a *App) ExportTaskState(id string) (string, error) {
+	a.logger.Info("frontend_request", "method", "ExportTaskState", "id", id)
+	return a.engine.ExportTaskState(id)
+}
+
+// ImportTaskState recreates a download exported by another instance and
+// queues it, saving to newPath (a file, or a folder to keep its name)
+func (a *App) ImportTaskState(token, newPath string) (string, error) {
+	a.logger.Info("frontend_request", "method", "ImportTaskState", "path", newPath)
+	return a.engine.ImportTaskState(token, newPath)
+}
+
+// GetConcurrencyCurve returns the file size steps that bound how many
+// connections a download starts with
+func (a *App) GetConcurrencyCurve() []engine.ConcurrencyStep {
+	return a.engine.GetConcurrencyCurve()
+}
+
+// SetConcurrencyCurve sets the file size steps that bound how many
+// connections a download starts with (empty = no size limit)
+func (a *App) SetConcurrencyCurve(steps []engine.ConcurrencyStep) error {
+	a.logger.Info("frontend_request", "method", "SetConcurrencyCurve", "steps", len(steps))
+	if err := a.engine.SetConcurrencyCurve(steps); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		data, err := json.Marshal(a.engine.GetConcurrencyCurve())
+		if err != nil {
+			return err
+		}
+		return a.cfg.SetConcurrencyCurve(string(data))
+	}
+	return nil
+}
+
+// SetStallThresholds configures when a download that makes no progress is
+// reported as stalled and when it is paused (seconds, 0 disables)
+func (a *App) SetStallThresholds(warnSeconds, pauseSeconds int) {
+	a.logger.Info("frontend_request", "method", "SetStallThresholds", "warn", warnSeconds, "pause", pauseSeconds)
+	if warnSeconds < 0 {
+		warnSeconds = 0
+	}
+	if pauseSeconds < 0 {
+		pauseSeconds = 0
+	}
+	a.engine.SetStallThresholds(time.Duration(warnSeconds)*time.Second, time.Duration(pauseSeconds)*time.Second)
+	if a.cfg != nil {
+		a.cfg.SetStallThresholds(warnSeconds, pauseSeconds)
+	}
+}
+
+// GetMaxQueueSize returns the queue size limit (0 = unlimited)
+func (a *App) GetMaxQueueSize() int {
+	return a.engine.GetMaxQueueSize()
+}
+
+// SetMaxQueueSize limits how many downloads may be queued or running at once
+// (0 = unlimited)
+func (a *App) SetMaxQueueSize(n int) {
+	a.logger.Info("frontend_request", "method", "SetMaxQueueSize", "n", n)
+	if n < 0 {
+		n = 0
+	}
+	a.engine.SetMaxQueueSize(n)
+	if a.cfg != nil {
+		a.cfg.SetMaxQueueSize(n)
+	}
+}
+
+// SetMaxConcurrentDownloads sets the maximum number of concurrent downloads.
+// While autoscaling is on the value is kept and applied when it is switched
+// off.
 func (a *App) SetMaxConcurrentDownloads(n int) {
 	a.logger.Info("frontend_request", "method", "SetMaxConcurrentDownloads", "n", n)
 	a.engine.SetMaxConcurrent(n)
+	if a.cfg != nil {
+		a.cfg.SetMaxConcurrentDownloads(max(n, 1))
+	}
+}
+
+// GetMaxConcurrentDownloads returns how many downloads may run at once right
+// now, including any adjustment made by the autoscaler
+func (a *App) GetMaxConcurrentDownloads() int {
+	return a.engine.GetMaxConcurrent()
+}
+
+// GetConcurrencyAutoscale reports whether the number of simultaneous
+// downloads is picked automatically
+func (a *App) GetConcurrencyAutoscale() bool {
+	return a.engine.IsConcurrencyAutoscale()
+}
+
+// SetConcurrencyAutoscale turns automatic download concurrency on or off
+func (a *App) SetConcurrencyAutoscale(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetConcurrencyAutoscale", "enabled", enabled)
+	a.engine.SetConcurrencyAutoscale(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetConcurrencyAutoscale(enabled)
+	}
+	return nil
 }
 
 // SetHostLimit sets the per-host connection limit
@@ -214,6 +590,197 @@ func (a *App) SetHostLimit(domain string, limit int) {
 	a.engine.SetHostLimit(domain, limit)
 }
 
+// GetMaxConnectionsPerHost returns the cap on connections to one host across
+// all downloads (0 = unlimited)
+func (a *App) GetMaxConnectionsPerHost() int {
+	return a.engine.GetMaxConnectionsPerHost()
+}
+
+// SetMaxConnectionsPerHost caps connections to one host across all downloads
+// (0 = unlimited)
+func (a *App) SetMaxConnectionsPerHost(n int) {
+	a.logger.Info("frontend_request", "method", "SetMaxConnectionsPerHost", "n", n)
+	if n < 0 {
+		n = 0
+	}
+	a.engine.SetMaxConnectionsPerHost(n)
+	if a.cfg != nil {
+		a.cfg.SetMaxConnectionsPerHost(n)
+	}
+}
+
+// GetMaxConnectionsPerDownload returns the most connections one download
+// may open
+func (a *App) GetMaxConnectionsPerDownload() int {
+	return a.engine.GetMaxConnectionsPerDownload()
+}
+
+// SetMaxConnectionsPerDownload sets the ceiling congestion control scales a
+// download within (1-64). Running downloads keep their current ceiling.
+func (a *App) SetMaxConnectionsPerDownload(n int) {
+	a.logger.Info("frontend_request", "method", "SetMaxConnectionsPerDownload", "n", n)
+	a.engine.SetMaxConnectionsPerDownload(n)
+	if a.cfg != nil {
+		a.cfg.SetMaxConnectionsPerDownload(a.engine.GetMaxConnectionsPerDownload())
+		// An unset host cap follows the new ceiling
+		a.engine.SetMaxConnectionsPerHost(a.cfg.GetMaxConnectionsPerHost())
+	}
+}
+
+// GetMaxVerifications returns how many finished downloads are verified at
+// once
+func (a *App) GetMaxVerifications() int {
+	return a.engine.GetMaxVerifications()
+}
+
+// SetMaxVerifications sets how many finished downloads are hashed and
+// scanned in parallel. Verification no longer holds a download slot, so
+// lower values only delay the "completed" state, not other downloads.
+func (a *App) SetMaxVerifications(n int) {
+	a.logger.Info("frontend_request", "method", "SetMaxVerifications", "n", n)
+	a.engine.SetMaxVerifications(n)
+	if a.cfg != nil {
+		a.cfg.SetMaxVerifications(a.engine.GetMaxVerifications())
+	}
+}
+
+// GetVerifyRetries returns how many times a download that fails its
+// integrity check is downloaded again before it is quarantined
+func (a *App) GetVerifyRetries() int {
+	return a.engine.GetVerifyRetries()
+}
+
+// SetVerifyRetries sets how many times a download that fails its integrity
+// check is discarded and fetched again (0 = quarantine it at once)
+func (a *App) SetVerifyRetries(n int) {
+	a.logger.Info("frontend_request", "method", "SetVerifyRetries", "n", n)
+	a.engine.SetVerifyRetries(n)
+	if a.cfg != nil {
+		a.cfg.SetVerifyRetries(a.engine.GetVerifyRetries())
+	}
+}
+
+// GetHoldQueueWhileVerifying reports whether new downloads wait while a
+// finished one is verified or scanned
+func (a *App) GetHoldQueueWhileVerifying() bool {
+	return a.engine.GetHoldQueueWhileVerifying()
+}
+
+// SetHoldQueueWhileVerifying stops new downloads from starting while a
+// finished download is verified or scanned, for machines where hashing and
+// scanning slow active transfers down
+func (a *App) SetHoldQueueWhileVerifying(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetHoldQueueWhileVerifying", "enabled", enabled)
+	a.engine.SetHoldQueueWhileVerifying(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetHoldQueueWhileVerifying(enabled)
+	}
+	return nil
+}
+
+// GetDispatchOrder returns the order queued downloads start in
+func (a *App) GetDispatchOrder() string {
+	return a.engine.GetDispatchOrder()
+}
+
+// SetDispatchOrder picks the order queued downloads start in: "priority",
+// "fifo", "size_asc" (finish small files first) or "size_desc"
+func (a *App) SetDispatchOrder(mode string) error {
+	a.logger.Info("frontend_request", "method", "SetDispatchOrder", "mode", mode)
+	if err := a.engine.SetDispatchOrder(mode); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetDispatchOrder(a.engine.GetDispatchOrder())
+	}
+	return nil
+}
+
+// GetDefaultPriority returns the priority (0=Low, 1=Normal, 2=High) new
+// downloads get
+func (a *App) GetDefaultPriority() int {
+	return a.engine.GetDefaultPriority()
+}
+
+// SetDefaultPriority sets the priority new downloads get; downloads already
+// in the queue keep theirs
+func (a *App) SetDefaultPriority(priority int) error {
+	a.logger.Info("frontend_request", "method", "SetDefaultPriority", "priority", priority)
+	if err := a.engine.SetDefaultPriority(priority); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetDefaultPriority(priority)
+	}
+	return nil
+}
+
+// SetSpawnPacing staggers worker start-up to avoid tripping CDN burst
+// detection (milliseconds; 0/0 disables)
+func (a *App) SetSpawnPacing(jitterMs, rampMs int) {
+	a.logger.Info("frontend_request", "method", "SetSpawnPacing", "jitter_ms", jitterMs, "ramp_ms", rampMs)
+	if jitterMs < 0 {
+		jitterMs = 0
+	}
+	if rampMs < 0 {
+		rampMs = 0
+	}
+	a.engine.SetSpawnPacing(time.Duration(jitterMs)*time.Millisecond, time.Duration(rampMs)*time.Millisecond)
+	if a.cfg != nil {
+		a.cfg.SetSpawnPacing(jitterMs, rampMs)
+	}
+}
+
+// SetHostPacing turns worker start-up pacing on or off for a single host
+func (a *App) SetHostPacing(domain string, enabled bool) {
+	a.logger.Info("frontend_request", "method", "SetHostPacing", "domain", domain, "enabled", enabled)
+	a.engine.SetHostPacing(domain, enabled)
+}
+
+// GetTempDownloadDir returns the folder used for in-progress downloads
+// ("" = beside each download)
+func (a *App) GetTempDownloadDir() string {
+	return a.engine.GetTempDownloadDir()
+}
+
+// SetTempDownloadDir keeps in-progress downloads in dir and moves them to
+// their final location on completion ("" = beside each download)
+func (a *App) SetTempDownloadDir(dir string) error {
+	a.logger.Info("frontend_request", "method", "SetTempDownloadDir", "dir", dir)
+	if err := a.engine.SetTempDownloadDir(dir); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetTempDownloadDir(a.engine.GetTempDownloadDir())
+	}
+	return nil
+}
+
+// GetCollisionStrategies lists what a download can do when its file name
+// is taken
+func (a *App) GetCollisionStrategies() []string {
+	return engine.CollisionStrategies()
+}
+
+// GetCollisionStrategy returns the default file name collision strategy
+func (a *App) GetCollisionStrategy() string {
+	return a.engine.GetCollisionStrategy()
+}
+
+// SetCollisionStrategy sets what new downloads do when their file name is
+// taken ("rename", "overwrite", "skip" or "timestamp"). A download's
+// "on_collision" option overrides it.
+func (a *App) SetCollisionStrategy(name string) error {
+	a.logger.Info("frontend_request", "method", "SetCollisionStrategy", "strategy", name)
+	if err := a.engine.SetCollisionStrategy(name); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetCollisionStrategy(name)
+	}
+	return nil
+}
+
 // GetHostLimit returns the per-host connection limit
 func (a *App) GetHostLimit(domain string) int {
 	return a.engine.GetHostLimit(domain)
@@ -262,3 +829,20 @@ func (a *App) CheckCollision(filename string) CollisionResult {
 	}
 	return CollisionResult{Exists: exists, Path: path}
 }
+
+// HandleProtocolURL enqueues a download from a tachyon:// link handed over by the OS
+func (a *App) HandleProtocolURL(raw string) (string, error) {
+	req, err := platform.ParseProtocolURI(raw)
+	if err != nil {
+		a.logger.Warn("Rejected protocol URI", "error", err)
+		return "", err
+	}
+	// Only the host is logged; the link may carry signed tokens
+	host := ""
+	if u, err := url.Parse(req.URL); err == nil {
+		host = u.Host
+	}
+	a.logger.Info("protocol_request", "host", host)
+
+	return a.AddDownloadWithFilename(req.URL, engine.SanitizeFilename(req.Filename))
+}
@@ -1,6 +1,10 @@
 package app
 
 import (
+	"time"
+
+	"project-tachyon/internal/engine"
+
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -18,6 +22,38 @@ type NetworkHealthEvent struct {
 	Details string `json:"details,omitempty"`
 }
 
+// GetEventLog returns the n most recent engine events (downloads started,
+// finished and failed, scheduling and scaling decisions), newest first.
+// n <= 0 returns everything kept.
+func (a *App) GetEventLog(n int) []engine.EngineEvent {
+	return a.engine.GetEventLog(n)
+}
+
+// GetAppProgress returns the combined progress of every running download,
+// the same summary the app:progress event carries
+func (a *App) GetAppProgress() engine.AppProgress {
+	return a.engine.GetAppProgress()
+}
+
+// GetAppProgressInterval returns how often app:progress is sent, in
+// milliseconds (0 = off)
+func (a *App) GetAppProgressInterval() int {
+	return int(a.engine.GetAppProgressInterval() / time.Millisecond)
+}
+
+// SetAppProgressInterval sets how often app:progress is sent while
+// downloads run, in milliseconds (0 = off)
+func (a *App) SetAppProgressInterval(ms int) {
+	a.logger.Info("frontend_request", "method", "SetAppProgressInterval", "ms", ms)
+	if ms < 0 {
+		ms = 0
+	}
+	a.engine.SetAppProgressInterval(time.Duration(ms) * time.Millisecond)
+	if a.cfg != nil {
+		a.cfg.SetAppProgressInterval(ms)
+	}
+}
+
 // EmitScanResult emits a security scan result event to the frontend
 func (a *App) EmitScanResult(file, status, threatName string) {
 	if a.ctx == nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"project-tachyon/internal/engine"
 	"project-tachyon/internal/network"
 	"project-tachyon/internal/storage"
 	"project-tachyon/internal/updater"
@@ -74,6 +75,7 @@ func (a *App) RunNetworkSpeedTest() *network.SpeedTestResult {
 	if err := a.engine.GetStorage().SaveSpeedTest(history); err != nil {
 		a.logger.Error("Failed to save speed test history", "error", err)
 	}
+	a.engine.SetLineCapacity(mbpsToBytes(res.DownloadSpeed))
 
 	return res
 }
@@ -103,6 +105,89 @@ func (a *App) ClearSpeedTestHistory() error {
 	return a.engine.GetStorage().ClearSpeedTestHistory()
 }
 
+// GetHostProfile returns what has been learned about downloading from a
+// domain (ideal connections, typical round trip, error rate)
+func (a *App) GetHostProfile(domain string) (storage.HostProfile, error) {
+	return a.engine.GetHostProfile(domain)
+}
+
+// TestHostThroughput measures bandwidth to the server behind url for about
+// seconds and pre-tunes connection counts for that host
+func (a *App) TestHostThroughput(url string, seconds int) (*engine.HostThroughputResult, error) {
+	a.logger.Info("frontend_request", "method", "TestHostThroughput", "url", url, "seconds", seconds)
+	return a.engine.TestHostThroughput(url, seconds)
+}
+
+// GetNetworkProfiles lists the available network profiles
+func (a *App) GetNetworkProfiles() []string {
+	return engine.NetworkProfiles()
+}
+
+// GetNetworkProfile returns the active network profile
+func (a *App) GetNetworkProfile() string {
+	return a.engine.GetNetworkProfile()
+}
+
+// SetNetworkProfile switches connection pooling and timeouts to the named
+// profile ("balanced", "conservative", "aggressive" or "satellite")
+func (a *App) SetNetworkProfile(name string) error {
+	a.logger.Info("frontend_request", "method", "SetNetworkProfile", "profile", name)
+	if err := a.engine.SetNetworkProfile(name); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetNetworkProfile(name)
+	}
+	return nil
+}
+
+// GetWorkerGrowthPolicy returns how many workers a running download may add
+// per scale tick and the tick interval in seconds
+func (a *App) GetWorkerGrowthPolicy() map[string]int {
+	perTick, interval := a.engine.GetWorkerGrowthPolicy()
+	return map[string]int{
+		"per_tick":         perTick,
+		"interval_seconds": int(interval / time.Second),
+	}
+}
+
+// SetWorkerGrowthPolicy overrides the network profile's worker ramp-up:
+// perTick workers (1-16) every intervalSeconds (1-60). 0 keeps the
+// profile's value.
+func (a *App) SetWorkerGrowthPolicy(perTick, intervalSeconds int) error {
+	a.logger.Info("frontend_request", "method", "SetWorkerGrowthPolicy", "per_tick", perTick, "interval_seconds", intervalSeconds)
+	if err := a.engine.SetWorkerGrowthPolicy(perTick, time.Duration(intervalSeconds)*time.Second); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetWorkerGrowthPolicy(perTick, intervalSeconds)
+	}
+	return nil
+}
+
+// GetDNSOverHTTPS returns the DNS-over-HTTPS provider URL ("" = system DNS)
+func (a *App) GetDNSOverHTTPS() string {
+	return a.engine.GetDNSOverHTTPS()
+}
+
+// SetDNSOverHTTPS resolves download hosts through a DNS-over-HTTPS provider,
+// falling back to system DNS if it fails ("" = system DNS only)
+func (a *App) SetDNSOverHTTPS(providerURL string) error {
+	a.logger.Info("frontend_request", "method", "SetDNSOverHTTPS", "provider", providerURL)
+	if err := a.engine.SetDNSOverHTTPS(providerURL); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetDoHURL(providerURL)
+	}
+	return nil
+}
+
+// mbpsToBytes converts a speed test result in megabits/sec to bytes/sec
+func mbpsToBytes(mbps float64) int64 {
+	return int64(mbps * 1e6 / 8)
+}
+
 // checkUpdaterPackage wraps the updater package call
 func checkUpdaterPackage(currentVersion, owner, repo string) (*updater.Release, error) {
 	return updater.CheckForUpdates(currentVersion, owner, repo)
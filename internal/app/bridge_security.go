@@ -28,10 +28,10 @@ func (a *App) GetAIPort() int {
 	return a.cfg.GetAIPort()
 }
 
-// SetAIPort sets the AI interface port (requires restart)
+// SetAIPort sets the AI interface port (applied by ReloadSettings)
 func (a *App) SetAIPort(port int) {
 	a.cfg.SetAIPort(port)
-	a.logger.Info("AI Port setting changed (requires restart)", "port", port)
+	a.logger.Info("AI Port setting changed (applied on reload)", "port", port)
 }
 
 // GetAIMaxConcurrent returns the max concurrent AI requests
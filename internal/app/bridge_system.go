@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 
+	"project-tachyon/internal/engine"
 	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/notify"
+	"project-tachyon/internal/platform"
 	"project-tachyon/internal/storage"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -22,20 +25,13 @@ func (a *App) GetQueuedDownloads() []map[string]interface{} {
 	items := a.engine.GetQueuedDownloads()
 	result := make([]map[string]interface{}, len(items))
 	for i, item := range items {
-		// Check file existence
-		fileExists := false
-		if item.SavePath != "" {
-			if _, err := os.Stat(item.SavePath); err == nil {
-				fileExists = true
-			}
-		}
-
 		result[i] = map[string]interface{}{
-			"id":          item.ID,
-			"filename":    item.Filename,
-			"queue_order": item.QueueOrder,
-			"status":      item.Status,
-			"file_exists": fileExists,
+			"id":           item.ID,
+			"filename":     item.Filename,
+			"queue_order":  item.QueueOrder,
+			"status":       item.Status,
+			"file_exists":  a.engine.FileExists(item.ID, item.SavePath),
+			"wait_seconds": int(a.engine.QueueWaitTime(item.ID).Seconds()),
 		}
 	}
 	return result
@@ -58,17 +54,241 @@ func (a *App) GetTasks() []storage.Task {
 		return []storage.Task{}
 	}
 
-	// Populate FileExists for each task
-	for i := range tasks {
-		if tasks[i].SavePath != "" {
-			if _, err := os.Stat(tasks[i].SavePath); err == nil {
-				tasks[i].FileExists = true
-			} else {
-				tasks[i].FileExists = false
-			}
+	// FileExists comes from the engine's cache, kept fresh by the
+	// background reconciliation scan
+	a.engine.PopulateFileExists(tasks)
+	return tasks
+}
+
+// GetWatchDownloadDirs reports whether download folders are being watched
+func (a *App) GetWatchDownloadDirs() bool {
+	return a.engine.IsFileWatching()
+}
+
+// SetWatchDownloadDirs turns download folder watching on or off
+func (a *App) SetWatchDownloadDirs(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetWatchDownloadDirs", "enabled", enabled)
+	if err := a.engine.SetFileWatching(enabled); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetWatchDownloadDirs(enabled)
+	}
+	return nil
+}
+
+// GetFixMissingExtensions reports whether extensionless downloads are named
+// from the server's Content-Type
+func (a *App) GetFixMissingExtensions() bool {
+	return a.engine.GetFixMissingExtensions()
+}
+
+// SetFixMissingExtensions turns Content-Type based extension correction on or off
+func (a *App) SetFixMissingExtensions(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetFixMissingExtensions", "enabled", enabled)
+	a.engine.SetFixMissingExtensions(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetFixMissingExtensions(enabled)
+	}
+	return nil
+}
+
+// GetPreserveModTime reports whether completed files keep the server's
+// Last-Modified time
+func (a *App) GetPreserveModTime() bool {
+	return a.engine.GetPreserveModTime()
+}
+
+// SetPreserveModTime turns Last-Modified timestamp preservation on or off
+func (a *App) SetPreserveModTime(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetPreserveModTime", "enabled", enabled)
+	a.engine.SetPreserveModTime(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetPreserveModTime(enabled)
+	}
+	return nil
+}
+
+// GetWriteSidecar reports whether completed downloads get a .tachyon.json
+// provenance file
+func (a *App) GetWriteSidecar() bool {
+	return a.engine.GetWriteSidecar()
+}
+
+// SetWriteSidecar turns sidecar metadata files on or off
+func (a *App) SetWriteSidecar(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetWriteSidecar", "enabled", enabled)
+	a.engine.SetWriteSidecar(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetWriteSidecar(enabled)
+	}
+	return nil
+}
+
+// GetPauseWhenHidden reports whether downloads pause while the window is
+// minimized or hidden to the tray
+func (a *App) GetPauseWhenHidden() bool {
+	a.windowMu.Lock()
+	defer a.windowMu.Unlock()
+	return a.pauseWhenHidden
+}
+
+// SetPauseWhenHidden turns pausing while the window is hidden on or off.
+// Turning it off while hidden resumes what it paused.
+func (a *App) SetPauseWhenHidden(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetPauseWhenHidden", "enabled", enabled)
+	a.windowMu.Lock()
+	a.pauseWhenHidden = enabled
+	if !enabled {
+		a.resumeHidden()
+	}
+	a.windowMu.Unlock()
+	if a.cfg != nil {
+		return a.cfg.SetPauseWhenHidden(enabled)
+	}
+	return nil
+}
+
+// GetVerifyOnOpen reports whether completed downloads are re-verified
+// against their hash before being opened
+func (a *App) GetVerifyOnOpen() bool {
+	return a.engine.GetVerifyOnOpen()
+}
+
+// SetVerifyOnOpen turns re-verification before opening on or off
+func (a *App) SetVerifyOnOpen(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetVerifyOnOpen", "enabled", enabled)
+	a.engine.SetVerifyOnOpen(enabled)
+	if a.cfg != nil {
+		return a.cfg.SetVerifyOnOpen(enabled)
+	}
+	return nil
+}
+
+// GetSoundPreferences returns the completion and error sound settings
+func (a *App) GetSoundPreferences() engine.SoundPreferences {
+	return a.engine.GetSoundPreferences()
+}
+
+// SetSoundPreferences configures which download events play a sound and
+// the quiet hours during which they stay silent
+func (a *App) SetSoundPreferences(prefs engine.SoundPreferences) error {
+	a.logger.Info("frontend_request", "method", "SetSoundPreferences", "enabled", prefs.Enabled)
+	if err := a.engine.SetSoundPreferences(prefs); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		data, err := json.Marshal(prefs)
+		if err != nil {
+			return err
 		}
+		return a.cfg.SetSoundPreferences(string(data))
 	}
-	return tasks
+	return nil
+}
+
+// GetCompletionWebhook returns the URL notified when downloads finish
+// ("" = off). The signing secret is never returned.
+func (a *App) GetCompletionWebhook() string {
+	return a.engine.GetCompletionWebhook()
+}
+
+// SetCompletionWebhook sets the URL POSTed to when a download completes or
+// fails, and the secret that signs each payload ("" = unsigned)
+func (a *App) SetCompletionWebhook(url, secret string) error {
+	a.logger.Info("frontend_request", "method", "SetCompletionWebhook", "url", url, "signed", secret != "")
+	if err := a.engine.SetCompletionWebhook(url, secret); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetCompletionWebhook(url, secret)
+	}
+	return nil
+}
+
+// GetNotificationSettings returns the email, Telegram and Discord
+// notification backends. Their credentials are returned blank.
+func (a *App) GetNotificationSettings() notify.Settings {
+	return a.engine.GetNotificationSettings().Redacted()
+}
+
+// SetNotificationSettings configures the backends told when a download
+// completes or fails. A credential left blank keeps the stored one.
+func (a *App) SetNotificationSettings(s notify.Settings) error {
+	a.logger.Info("frontend_request", "method", "SetNotificationSettings",
+		"email", s.Email.Enabled, "telegram", s.Telegram.Enabled, "discord", s.Discord.Enabled)
+	s = s.KeepSecrets(a.engine.GetNotificationSettings())
+	if err := a.engine.SetNotificationSettings(s); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetNotificationSettings(s)
+	}
+	return nil
+}
+
+// TestNotifications sends a test message through every enabled backend
+func (a *App) TestNotifications() error {
+	a.logger.Info("frontend_request", "method", "TestNotifications")
+	return a.engine.SendTestNotification()
+}
+
+// GetAuthRefreshURL returns the endpoint asked for a fresh link when a
+// download's link expires ("" = off)
+func (a *App) GetAuthRefreshURL() string {
+	return a.engine.GetAuthRefreshURL()
+}
+
+// SetAuthRefreshURL sets the endpoint asked for a fresh link when a
+// download's link expires, so it resumes without the user pasting one
+func (a *App) SetAuthRefreshURL(url string) error {
+	a.logger.Info("frontend_request", "method", "SetAuthRefreshURL", "url", url)
+	if err := a.engine.SetAuthRefreshURL(url); err != nil {
+		return err
+	}
+	if a.cfg != nil {
+		return a.cfg.SetAuthRefreshURL(url)
+	}
+	return nil
+}
+
+// GetHistoryRetention returns how many days finished downloads stay in
+// history (0 = forever) and whether pruning deletes their files
+func (a *App) GetHistoryRetention() map[string]interface{} {
+	days, deleteFiles := a.engine.GetHistoryRetention()
+	return map[string]interface{}{
+		"days":         days,
+		"delete_files": deleteFiles,
+	}
+}
+
+// SetHistoryRetention sets the history retention window and prunes
+// immediately so the change takes effect without waiting a day
+func (a *App) SetHistoryRetention(days int, deleteFiles bool) (int, error) {
+	a.logger.Info("frontend_request", "method", "SetHistoryRetention", "days", days, "delete_files", deleteFiles)
+	if days < 0 {
+		days = 0
+	}
+	a.engine.SetHistoryRetention(days, deleteFiles)
+	if a.cfg != nil {
+		if err := a.cfg.SetHistoryRetention(days, deleteFiles); err != nil {
+			return 0, err
+		}
+	}
+	return a.engine.PruneHistory()
+}
+
+// PruneHistory removes finished downloads older than the retention window
+// and returns how many were removed
+func (a *App) PruneHistory() (int, error) {
+	a.logger.Info("frontend_request", "method", "PruneHistory")
+	return a.engine.PruneHistory()
+}
+
+// UpdateSavePath re-links a completed download to a file the user moved
+func (a *App) UpdateSavePath(id, newPath string) error {
+	a.logger.Info("frontend_request", "method", "UpdateSavePath", "id", id, "path", newPath)
+	return a.engine.UpdateSavePath(id, newPath)
 }
 
 // OpenFolder opens the file explorer with the file selected
@@ -99,7 +319,9 @@ func (a *App) OpenFolderByPath(path string) {
 	}
 }
 
-// OpenFile opens a downloaded file with the default application
+// OpenFile opens a downloaded file with the default application. With
+// verify-on-open enabled, a file that no longer matches its hash is not
+// opened; the frontend gets download:integrity_warning instead.
 func (a *App) OpenFile(id string) {
 	task, err := a.engine.GetTask(id)
 	if err != nil {
@@ -111,11 +333,25 @@ func (a *App) OpenFile(id string) {
 		return
 	}
 
-	if err := filesystem.OpenFile(task.SavePath); err != nil {
+	if err := a.engine.RecheckIntegrity(id); err != nil {
+		a.logger.Warn("Not opening file that failed its integrity recheck", "path", task.SavePath, "error", err)
+		return
+	}
+
+	if err := a.openFile(task.SavePath); err != nil {
 		a.logger.Error("Failed to open file", "path", task.SavePath, "error", err)
 	}
 }
 
+// GetAllSettings returns every effective setting, defaults included, in
+// one call. Keys match those accepted by UpdateSettings.
+func (a *App) GetAllSettings() map[string]interface{} {
+	if a.cfg == nil {
+		return map[string]interface{}{}
+	}
+	return a.cfg.GetAll()
+}
+
 // UpdateSettings saves user settings from a JSON payload to the database.
 func (a *App) UpdateSettings(jsonSettings string) {
 	a.logger.Info("UpdateSettings called", "settings", jsonSettings)
@@ -217,3 +453,32 @@ func (a *App) FactoryReset() error {
 	a.logger.Info("Factory reset completed successfully")
 	return nil
 }
+
+// GetAutostart reports whether Tachyon is registered to launch at login
+func (a *App) GetAutostart() bool {
+	as, err := platform.NewAutostart("--minimized")
+	if err != nil {
+		a.logger.Error("Failed to resolve autostart entry", "error", err)
+		return false
+	}
+	return as.IsEnabled()
+}
+
+// SetAutostart registers or removes the launch-at-login entry.
+// The app is started with --minimized so it comes up in the tray.
+func (a *App) SetAutostart(enabled bool) error {
+	a.logger.Info("frontend_request", "method", "SetAutostart", "enabled", enabled)
+	as, err := platform.NewAutostart("--minimized")
+	if err != nil {
+		return err
+	}
+	if enabled {
+		err = as.Enable()
+	} else {
+		err = as.Disable()
+	}
+	if err != nil {
+		a.logger.Error("Failed to update autostart", "enabled", enabled, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"project-tachyon/internal/config"
+	"project-tachyon/internal/engine"
+	"project-tachyon/internal/filesystem"
+)
+
+// ApplySettings pushes the saved configuration in c into the engine. The
+// desktop app runs it at startup and on every settings reload, server mode
+// once at boot, so both honour the same settings. Invalid stored values
+// are logged and left at the engine's defaults.
+func ApplySettings(c *config.ConfigManager, e *engine.TachyonEngine, logger *slog.Logger) {
+	e.SetPartIdleTimeout(time.Duration(c.GetPartIdleTimeout()) * time.Second)
+	warn, pause := c.GetStallThresholds()
+	e.SetStallThresholds(time.Duration(warn)*time.Second, time.Duration(pause)*time.Second)
+	e.SetMaxQueueSize(c.GetMaxQueueSize())
+	e.SetProbeRetries(c.GetProbeRetries())
+	e.SetMinPartSize(int64(c.GetMinPartSizeKB()) * 1024)
+	e.SetTreat416AsComplete(c.GetTreat416AsComplete())
+	e.SetAppProgressInterval(time.Duration(c.GetAppProgressInterval()) * time.Millisecond)
+	e.SetMaxConcurrent(c.GetMaxConcurrentDownloads())
+	e.SetGlobalLimit(c.GetGlobalSpeedLimit())
+	filesystem.SetDefaultDownloadPath(c.GetDownloadDir())
+	e.SetFixMissingExtensions(c.GetFixMissingExtensions())
+	e.SetPreserveModTime(c.GetPreserveModTime())
+	e.SetWriteSidecar(c.GetWriteSidecar())
+	e.SetVerifyOnOpen(c.GetVerifyOnOpen())
+	if curve := c.GetConcurrencyCurve(); curve != "" {
+		var steps []engine.ConcurrencyStep
+		err := json.Unmarshal([]byte(curve), &steps)
+		if err == nil {
+			err = e.SetConcurrencyCurve(steps)
+		}
+		if err != nil {
+			logger.Warn("Ignoring invalid concurrency curve", "error", err)
+		}
+	}
+	e.SetMaxConnectionsPerHost(c.GetMaxConnectionsPerHost())
+	e.SetMaxConnectionsPerDownload(c.GetMaxConnectionsPerDownload())
+	e.SetMaxVerifications(c.GetMaxVerifications())
+	e.SetVerifyRetries(c.GetVerifyRetries())
+	e.SetHoldQueueWhileVerifying(c.GetHoldQueueWhileVerifying())
+	e.SetDefaultPriority(c.GetDefaultPriority())
+	e.SetHistoryRetention(c.GetHistoryRetention())
+	e.SetConcurrencyAutoscale(c.GetConcurrencyAutoscale())
+	if order := c.GetDispatchOrder(); order != "" {
+		if err := e.SetDispatchOrder(order); err != nil {
+			logger.Warn("Ignoring unknown dispatch order", "error", err)
+		}
+	}
+	if profile := c.GetNetworkProfile(); profile != "" {
+		if err := e.SetNetworkProfile(profile); err != nil {
+			logger.Warn("Ignoring unknown network profile", "error", err)
+		}
+	}
+	if strategy := c.GetCollisionStrategy(); strategy != "" {
+		if err := e.SetCollisionStrategy(strategy); err != nil {
+			logger.Warn("Ignoring unknown collision strategy", "error", err)
+		}
+	}
+	growth, scaleInterval := c.GetWorkerGrowthPolicy()
+	if err := e.SetWorkerGrowthPolicy(growth, time.Duration(scaleInterval)*time.Second); err != nil {
+		logger.Warn("Ignoring invalid worker growth policy", "error", err)
+	}
+	if err := e.SetDNSOverHTTPS(c.GetDoHURL()); err != nil {
+		logger.Warn("Ignoring invalid DNS-over-HTTPS provider", "error", err)
+	}
+	if prefs := c.GetSoundPreferences(); prefs != "" {
+		var p engine.SoundPreferences
+		err := json.Unmarshal([]byte(prefs), &p)
+		if err == nil {
+			err = e.SetSoundPreferences(p)
+		}
+		if err != nil {
+			logger.Warn("Ignoring invalid sound preferences", "error", err)
+		}
+	}
+	if err := e.SetCompletionWebhook(c.GetCompletionWebhook()); err != nil {
+		logger.Warn("Ignoring invalid completion webhook", "error", err)
+	}
+	if s, err := c.GetNotificationSettings(); err != nil {
+		logger.Warn("Ignoring invalid notification settings", "error", err)
+	} else if err := e.SetNotificationSettings(s); err != nil {
+		logger.Warn("Ignoring invalid notification settings", "error", err)
+	}
+	if err := e.SetAuthRefreshURL(c.GetAuthRefreshURL()); err != nil {
+		logger.Warn("Ignoring invalid link refresh endpoint", "error", err)
+	}
+	jitter, ramp := c.GetSpawnPacing()
+	e.SetSpawnPacing(time.Duration(jitter)*time.Millisecond, time.Duration(ramp)*time.Millisecond)
+	if err := e.SetTempDownloadDir(c.GetTempDownloadDir()); err != nil {
+		logger.Warn("Temp download folder unavailable, using download folders", "error", err)
+	}
+	if locs, err := e.GetStorage().GetLocations(); err == nil {
+		for _, loc := range locs {
+			if loc.WriteLimit > 0 {
+				e.SetDiskWriteLimit(loc.Path, loc.WriteLimit)
+			}
+		}
+	}
+	if history, err := e.GetStorage().GetSpeedTestHistory(1); err == nil && len(history) > 0 {
+		e.SetLineCapacity(int64(history[0].DownloadSpeed * 1e6 / 8)) // Mbps to bytes/s
+	}
+	if err := e.SetFileWatching(c.GetWatchDownloadDirs()); err != nil {
+		logger.Warn("Download folder watching disabled", "error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestApplySettings(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+	cfg, e := a.cfg, a.engine
+
+	if err := cfg.SetVerifyRetries(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetDefaultPriority(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetHoldQueueWhileVerifying(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetAuthRefreshURL("https://auth.example.com/refresh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetNetworkProfile("conservative"); err != nil {
+		t.Fatal(err)
+	}
+
+	ApplySettings(cfg, e, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if got := e.GetVerifyRetries(); got != 3 {
+		t.Errorf("verify retries = %d, want 3", got)
+	}
+	if got := e.GetDefaultPriority(); got != 2 {
+		t.Errorf("default priority = %d, want 2", got)
+	}
+	if !e.GetHoldQueueWhileVerifying() {
+		t.Error("hold queue while verifying was not applied")
+	}
+	if got := e.GetAuthRefreshURL(); got != "https://auth.example.com/refresh" {
+		t.Errorf("auth refresh URL = %q", got)
+	}
+	if got := e.GetNetworkProfile(); got != "conservative" {
+		t.Errorf("network profile = %q, want conservative", got)
+	}
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"project-tachyon/internal/storage"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// MaxTrayDownloads caps the number of entries shown in the tray's
+// active downloads submenu.
+const MaxTrayDownloads = 8
+
+// TrayDownload is a compact view of an active download for the system tray.
+type TrayDownload struct {
+	ID       string  `json:"id"`
+	Filename string  `json:"filename"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress"`
+}
+
+// Label returns the text shown for the download in the tray submenu.
+func (d TrayDownload) Label() string {
+	name := d.Filename
+	if len([]rune(name)) > 40 {
+		name = string([]rune(name)[:37]) + "..."
+	}
+	if d.Status != "downloading" {
+		return fmt.Sprintf("%s (%s)", name, d.Status)
+	}
+	return fmt.Sprintf("%s - %.0f%%", name, d.Progress)
+}
+
+// trayActiveStatuses are the states listed in the tray submenu.
+var trayActiveStatuses = map[storage.Status]bool{
+	storage.StatusPending:     true,
+	storage.StatusProbing:     true,
+	storage.StatusDownloading: true,
+	storage.StatusMerging:     true,
+	storage.StatusVerifying:   true,
+}
+
+// GetTrayDownloads returns up to limit active downloads for the tray menu.
+func (a *App) GetTrayDownloads(limit int) []TrayDownload {
+	tasks, err := a.engine.GetHistory()
+	if err != nil {
+		a.logger.Error("Failed to get tasks for tray", "error", err)
+		return nil
+	}
+
+	var result []TrayDownload
+	for _, task := range tasks {
+		if !trayActiveStatuses[task.Status] {
+			continue
+		}
+		result = append(result, TrayDownload{
+			ID:       task.ID,
+			Filename: task.Filename,
+			Status:   string(task.Status),
+			Progress: task.Progress,
+		})
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
+
+// FocusDownload restores the window and asks the frontend to select the download
+func (a *App) FocusDownload(id string) {
+	a.logger.Info("frontend_request", "method", "FocusDownload", "id", id)
+	if a.ctx == nil {
+		return
+	}
+	a.ShowApp()
+	runtime.EventsEmit(a.ctx, "tray:focus_download", map[string]interface{}{
+		"id": id,
+	})
+}
+
+// trayEvents are the engine events after which the tray's download list
+// may be out of date.
+var trayEvents = []string{
+	"download:progress",
+	"download:paused",
+	"download:stopped",
+	"download:completed",
+	"download:error",
+	"download:deleted",
+	"download:paused_all",
+	"download:resumed_all",
+}
+
+// WatchTrayEvents makes TrayChanged receive whenever one of trayEvents is
+// emitted. Signals that arrive before the tray has caught up are merged.
+// ctx must be the Wails context given to Startup.
+func (a *App) WatchTrayEvents(ctx context.Context) {
+	for _, name := range trayEvents {
+		runtime.EventsOn(ctx, name, func(...interface{}) {
+			select {
+			case a.trayCh <- struct{}{}:
+			default:
+			}
+		})
+	}
+}
+
+// TrayChanged returns a channel that receives when the tray's download
+// list should be refreshed.
+func (a *App) TrayChanged() <-chan struct{} {
+	return a.trayCh
+}
+
+// Done returns a channel that is closed once QuitApp has been called.
+// Background loops such as the tray click handler select on it to exit.
+func (a *App) Done() <-chan struct{} {
+	return a.quitCh
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-tachyon/internal/config"
+	"project-tachyon/internal/engine"
+	"project-tachyon/internal/storage"
+)
+
+func TestGetTrayDownloads_FiltersActive(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	store := a.engine.GetStorage()
+	store.SaveTask(storage.DownloadTask{ID: "a1", Filename: "a.zip", Status: "downloading", Progress: 42})
+	store.SaveTask(storage.DownloadTask{ID: "a2", Filename: "b.zip", Status: "pending"})
+	store.SaveTask(storage.DownloadTask{ID: "a3", Filename: "c.zip", Status: "completed"})
+	store.SaveTask(storage.DownloadTask{ID: "a4", Filename: "d.zip", Status: "paused"})
+
+	downloads := a.GetTrayDownloads(MaxTrayDownloads)
+	if len(downloads) != 2 {
+		t.Fatalf("expected 2 active downloads, got %d", len(downloads))
+	}
+	for _, d := range downloads {
+		if d.ID != "a1" && d.ID != "a2" {
+			t.Errorf("unexpected download in tray list: %s", d.ID)
+		}
+	}
+}
+
+func TestGetTrayDownloads_Limit(t *testing.T) {
+	a, cleanup := newTestApp(t)
+	defer cleanup()
+
+	store := a.engine.GetStorage()
+	for _, id := range []string{"l1", "l2", "l3"} {
+		store.SaveTask(storage.DownloadTask{ID: id, Filename: id + ".bin", Status: "downloading"})
+	}
+
+	if got := len(a.GetTrayDownloads(2)); got != 2 {
+		t.Errorf("expected limit of 2 to be honoured, got %d", got)
+	}
+}
+
+func TestTrayDownloadLabel(t *testing.T) {
+	d := TrayDownload{Filename: "file.iso", Status: "downloading", Progress: 12.6}
+	if got := d.Label(); got != "file.iso - 13%" {
+		t.Errorf("Label() = %q", got)
+	}
+
+	d.Status = "verifying"
+	if got := d.Label(); got != "file.iso (verifying)" {
+		t.Errorf("Label() = %q", got)
+	}
+
+	d = TrayDownload{Filename: strings.Repeat("x", 60), Status: "downloading"}
+	if got := d.Label(); !strings.HasPrefix(got, strings.Repeat("x", 37)+"...") {
+		t.Errorf("long filename not truncated: %q", got)
+	}
+}
+
+func TestDone_ClosedOnlyAfterQuit(t *testing.T) {
+	// QuitApp shuts the engine down itself, so newTestApp's cleanup
+	// doesn't fit
+	store, err := storage.NewStorageWithPath(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := NewApp(logger, engine.NewEngine(logger, store), nil, config.NewConfigManager(store), nil)
+	a.ctx = context.Background()
+	quit := 0
+	a.quit = func(context.Context) { quit++ }
+
+	select {
+	case <-a.Done():
+		t.Fatal("Done() should not be closed before QuitApp")
+	default:
+	}
+
+	a.QuitApp()
+	select {
+	case <-a.Done():
+	default:
+		t.Fatal("Done() should be closed after QuitApp")
+	}
+	if quit != 1 {
+		t.Errorf("runtime quit called %d times, want 1", quit)
+	}
+	if prevent := a.BeforeClose(a.ctx); prevent {
+		t.Error("window close still prevented after QuitApp")
+	}
+}
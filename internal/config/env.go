@@ -0,0 +1,156 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project-tachyon/internal/notify"
+	"project-tachyon/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read at startup. Each one that is set overrides the
+// stored setting for that run, so a container can be configured without
+// the GUI.
+const (
+	EnvAIPort        = "TACHYON_AI_PORT"
+	EnvAIToken       = "TACHYON_AI_TOKEN"
+	EnvDownloadDir   = "TACHYON_DOWNLOAD_DIR"
+	EnvMaxConcurrent = "TACHYON_MAX_CONCURRENT"
+	EnvGlobalLimit   = "TACHYON_GLOBAL_LIMIT"
+	EnvAIBind        = "TACHYON_AI_BIND"
+	EnvAIAllowedIPs  = "TACHYON_AI_ALLOWED_IPS"
+	EnvNotifications = "TACHYON_NOTIFICATIONS"
+	EnvDBBusyTimeout = "TACHYON_DB_BUSY_TIMEOUT_MS"
+	EnvDBMaxReaders  = "TACHYON_DB_MAX_READERS"
+)
+
+// StorageOptionsFromEnv returns the database connection options, with
+// TACHYON_DB_BUSY_TIMEOUT_MS and TACHYON_DB_MAX_READERS, looked up with
+// lookup, over the defaults. Invalid values are reported together and
+// leave the default in place.
+func StorageOptionsFromEnv(lookup func(string) (string, bool)) (storage.Options, error) {
+	opts := storage.DefaultOptions()
+	var errs []error
+	if val, ok := lookup(EnvDBBusyTimeout); ok {
+		ms, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || ms < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid timeout %q (milliseconds)", EnvDBBusyTimeout, val))
+		} else {
+			opts.BusyTimeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if val, ok := lookup(EnvDBMaxReaders); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid reader count %q", EnvDBMaxReaders, val))
+		} else {
+			opts.MaxReaders = n
+		}
+	}
+	return opts, errors.Join(errs...)
+}
+
+// ApplyEnv overrides settings with the values given by environment
+// variables, looked up with lookup (normally os.LookupEnv). The overrides
+// last for this run only and are never saved: once a variable is unset the
+// stored setting is back, and a setting changed in the app replaces its
+// override. It returns the names of the variables applied. Invalid values
+// are skipped and reported together in the error; the valid ones are
+// still applied.
+//
+// A token in TACHYON_AI_TOKEN also turns the control API on, since a
+// headless instance is of no use without it.
+func (c *ConfigManager) ApplyEnv(lookup func(string) (string, bool)) ([]string, error) {
+	var applied []string
+	var errs []error
+	env := make(map[string]string)
+	set := func(name string, apply func(val string) error) {
+		val, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if err := apply(strings.TrimSpace(val)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		applied = append(applied, name)
+	}
+
+	set(EnvAIPort, func(val string) error {
+		port, err := strconv.Atoi(val)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %q", val)
+		}
+		env[KeyAIPort] = strconv.Itoa(port)
+		return nil
+	})
+	set(EnvAIToken, func(val string) error {
+		if val == "" {
+			return errors.New("empty token")
+		}
+		env[KeyAIToken] = val
+		env[KeyEnableAIInterface] = "true"
+		return nil
+	})
+	set(EnvDownloadDir, func(val string) error {
+		if val == "" {
+			return errors.New("empty path")
+		}
+		env[KeyDownloadDir] = val
+		return nil
+	})
+	set(EnvMaxConcurrent, func(val string) error {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid download count %q", val)
+		}
+		env[KeyMaxConcurrent] = strconv.Itoa(n)
+		return nil
+	})
+	set(EnvGlobalLimit, func(val string) error {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid speed limit %q (bytes/sec, 0 = unlimited)", val)
+		}
+		env[KeyGlobalLimit] = strconv.Itoa(n)
+		return nil
+	})
+	set(EnvAIBind, func(val string) error {
+		if err := checkBindAddress(val); err != nil {
+			return err
+		}
+		env[KeyAIBindAddress] = val
+		return nil
+	})
+	set(EnvAIAllowedIPs, func(val string) error {
+		joined, err := joinAllowedIPs(strings.Split(val, ","))
+		if err != nil {
+			return err
+		}
+		env[KeyAIAllowedIPs] = joined
+		return nil
+	})
+	set(EnvNotifications, func(val string) error {
+		var s notify.Settings
+		if err := json.Unmarshal([]byte(val), &s); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		env[KeyNotifications] = string(data)
+		return nil
+	})
+
+	c.envMu.Lock()
+	c.env = env
+	c.envMu.Unlock()
+	return applied, errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestConfigManager_ApplyEnv(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SetAIPort(5000)
+	cfg.SetMaxConcurrentDownloads(2)
+	cfg.SetGlobalSpeedLimit(1000)
+
+	t.Setenv(EnvAIPort, "8765")
+	t.Setenv(EnvAIToken, "container-secret")
+	t.Setenv(EnvDownloadDir, "/downloads")
+	t.Setenv(EnvMaxConcurrent, " 7 ")
+	t.Setenv(EnvGlobalLimit, "0")
+	t.Setenv(EnvAIBind, "0.0.0.0")
+	t.Setenv(EnvAIAllowedIPs, "172.17.0.0/16, 10.1.2.3")
+	t.Setenv(EnvNotifications, `{"discord":{"enabled":true,"webhook_url":"https://discord.com/api/webhooks/1/x"}}`)
+
+	applied, err := cfg.ApplyEnv(os.LookupEnv)
+	if err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	if len(applied) != 8 {
+		t.Errorf("applied = %v, want all eight variables", applied)
+	}
+	if got := cfg.GetAIPort(); got != 8765 {
+		t.Errorf("AI port = %d, want 8765", got)
+	}
+	if got := cfg.GetAIToken(); got != "container-secret" {
+		t.Errorf("AI token = %q, want container-secret", got)
+	}
+	if !cfg.GetEnableAI() {
+		t.Error("a token from the environment should turn the control API on")
+	}
+	if got := cfg.GetDownloadDir(); got != "/downloads" {
+		t.Errorf("download dir = %q, want /downloads", got)
+	}
+	if got := cfg.GetMaxConcurrentDownloads(); got != 7 {
+		t.Errorf("max concurrent = %d, want 7", got)
+	}
+	if got := cfg.GetGlobalSpeedLimit(); got != 0 {
+		t.Errorf("global limit = %d, want 0 from the environment over the stored 1000", got)
+	}
+	if got := cfg.GetAIBindAddress(); got != "0.0.0.0" {
+		t.Errorf("bind address = %q, want 0.0.0.0", got)
+	}
+	if got := cfg.GetAIAllowedIPs(); len(got) != 2 || got[1] != "10.1.2.3" {
+		t.Errorf("allowlist = %v", got)
+	}
+	if got, err := cfg.GetNotificationSettings(); err != nil || !got.Discord.Enabled || got.Discord.WebhookURL != "https://discord.com/api/webhooks/1/x" {
+		t.Errorf("notification backends = %+v, %v", got, err)
+	}
+
+	// Nothing from the environment is saved
+	stored := NewConfigManager(cfg.storage)
+	if stored.GetAIPort() != 5000 || stored.GetEnableAI() || stored.GetAIBindAddress() != "127.0.0.1" || stored.GetGlobalSpeedLimit() != 1000 {
+		t.Errorf("environment values were saved: port %d, API %v, bind %q, limit %d",
+			stored.GetAIPort(), stored.GetEnableAI(), stored.GetAIBindAddress(), stored.GetGlobalSpeedLimit())
+	}
+	if got := stored.GetAIToken(); got == "container-secret" {
+		t.Error("the environment's token was saved")
+	}
+
+	// A change made in the app replaces the override
+	if err := cfg.SetEnableAI(false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetEnableAI() {
+		t.Error("turning the control API off in the app did not replace the environment's value")
+	}
+}
+
+func TestConfigManager_ApplyEnvSkipsInvalid(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SetAIPort(5000)
+	env := map[string]string{
+		EnvAIPort:        "99999",
+		EnvMaxConcurrent: "many",
+		EnvGlobalLimit:   "2048",
+		EnvNotifications: `{"telegram":{"enabled":true}}`,
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	applied, err := cfg.ApplyEnv(lookup)
+	if err == nil || !strings.Contains(err.Error(), EnvAIPort) || !strings.Contains(err.Error(), EnvMaxConcurrent) || !strings.Contains(err.Error(), EnvNotifications) {
+		t.Errorf("err = %v, want every invalid variable named", err)
+	}
+	if len(applied) != 1 || applied[0] != EnvGlobalLimit {
+		t.Errorf("applied = %v, want only %s", applied, EnvGlobalLimit)
+	}
+	if got := cfg.GetAIPort(); got != 5000 {
+		t.Errorf("AI port = %d, want the stored 5000 kept", got)
+	}
+	if got := cfg.GetMaxConcurrentDownloads(); got != 5 {
+		t.Errorf("max concurrent = %d, want the default 5", got)
+	}
+	if got := cfg.GetGlobalSpeedLimit(); got != 2048 {
+		t.Errorf("global limit = %d, want 2048", got)
+	}
+	if cfg.GetEnableAI() {
+		t.Error("control API turned on without a token")
+	}
+	if got, _ := cfg.GetNotificationSettings(); got.Telegram.Enabled {
+		t.Errorf("invalid notification backends stored: %+v", got)
+	}
+
+	// Nothing set: nothing changes
+	if applied, err := cfg.ApplyEnv(func(string) (string, bool) { return "", false }); err != nil || len(applied) != 0 {
+		t.Errorf("empty environment: applied = %v, err = %v", applied, err)
+	}
+}
+
+func TestStorageOptionsFromEnv(t *testing.T) {
+	env := map[string]string{}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	opts, err := StorageOptionsFromEnv(lookup)
+	if err != nil || opts != storage.DefaultOptions() {
+		t.Errorf("empty environment: opts = %+v, err = %v, want the defaults", opts, err)
+	}
+
+	env[EnvDBBusyTimeout] = "15000"
+	env[EnvDBMaxReaders] = " 0 "
+	opts, err = StorageOptionsFromEnv(lookup)
+	if err != nil || opts.BusyTimeout != 15*time.Second || opts.MaxReaders != 0 {
+		t.Errorf("opts = %+v, err = %v, want 15s and no readers", opts, err)
+	}
+
+	env[EnvDBBusyTimeout] = "-1"
+	env[EnvDBMaxReaders] = "two"
+	opts, err = StorageOptionsFromEnv(lookup)
+	if err == nil || !strings.Contains(err.Error(), EnvDBBusyTimeout) || !strings.Contains(err.Error(), EnvDBMaxReaders) {
+		t.Errorf("err = %v, want both invalid variables named", err)
+	}
+	if opts != storage.DefaultOptions() {
+		t.Errorf("opts = %+v, want the defaults kept for invalid values", opts)
+	}
+}
@@ -3,8 +3,14 @@ package config
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"project-tachyon/internal/notify"
 	"project-tachyon/internal/storage"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 // Keys for AppSettings in DB
@@ -16,18 +22,87 @@ const (
 	KeyAIPort               = "ai_port"
 	KeyAIMaxConcurrent      = "ai_max_concurrent"
 	KeyUserAgent            = "user_agent"
+	KeyPartIdleTimeout      = "part_idle_timeout"
+	KeyStallWarnSeconds     = "stall_warn_seconds"
+	KeyStallPauseSeconds    = "stall_pause_seconds"
+	KeyMaxQueueSize         = "max_queue_size"
+	KeyMaxConnsPerHost      = "max_connections_per_host"
+	KeySpawnJitterMs        = "spawn_jitter_ms"
+	KeySpawnRampMs          = "spawn_ramp_ms"
+	KeyWatchDownloadDirs    = "watch_download_dirs"
+	KeyTempDownloadDir      = "temp_download_dir"
+	KeyProbeRetries         = "probe_retries"
+	KeyFixExtensions        = "fix_missing_extensions"
+	KeyPreserveModTime      = "preserve_mod_time"
+	KeyWriteSidecar         = "write_sidecar"
+	KeyConcurrencyCurve     = "concurrency_curve"
+	KeyNetworkProfile       = "network_profile"
+	KeyDoHURL               = "doh_url"
+	KeySoundPreferences     = "sound_preferences"
+	KeyMaxConnsPerDownload  = "max_connections_per_download"
+	KeyRetentionDays        = "history_retention_days"
+	KeyRetentionDeleteFiles = "history_retention_delete_files"
+	KeyConcurrencyAutoscale = "concurrency_autoscale"
+	KeyWorkerGrowth         = "worker_growth_per_tick"
+	KeyScaleIntervalSeconds = "worker_scale_interval_seconds"
+	KeyOnCollision          = "on_collision"
+	KeyWebhookURL           = "completion_webhook_url"
+	KeyWebhookSecret        = "completion_webhook_secret"
+	KeyMaxVerifications     = "max_parallel_verifications"
+	KeyVerifyOnOpen         = "verify_on_open"
+	KeyHoldWhileVerifying   = "hold_queue_while_verifying"
+	KeyDefaultPriority      = "default_priority"
+	KeyDispatchOrder        = "dispatch_order"
+	KeyAuthRefreshURL       = "auth_refresh_url"
+	KeyPauseWhenHidden      = "pause_when_hidden"
+	KeyMinPartSizeKB        = "min_part_size_kb"
+	KeyAppProgressMs        = "app_progress_interval_ms"
+	KeyDownloadDir          = "download_dir"
+	KeyMaxConcurrent        = "max_concurrent_downloads"
+	KeyGlobalLimit          = "global_speed_limit"
+	KeyAIBindAddress        = "ai_bind_address"
+	KeyAIAllowedIPs         = "ai_allowed_ips"
+	KeyTreat416AsComplete   = "treat_416_as_complete"
+	KeyNotifications        = "notification_backends"
+	KeyVerifyRetries        = "verify_retries"
 )
 
 type ConfigManager struct {
 	storage *storage.Storage
+
+	// Values taken from the environment (see ApplyEnv), by key. They shadow
+	// the stored settings for this run and are never saved.
+	envMu sync.RWMutex
+	env   map[string]string
 }
 
 func NewConfigManager(s *storage.Storage) *ConfigManager {
 	return &ConfigManager{storage: s}
 }
 
+// getString returns the environment's value for key, if it gave one, and
+// otherwise the stored setting.
+func (c *ConfigManager) getString(key string) (string, error) {
+	c.envMu.RLock()
+	val, ok := c.env[key]
+	c.envMu.RUnlock()
+	if ok {
+		return val, nil
+	}
+	return c.storage.GetString(key)
+}
+
+// setString stores a setting. A value changed in the app replaces the
+// environment's for the rest of the run.
+func (c *ConfigManager) setString(key, val string) error {
+	c.envMu.Lock()
+	delete(c.env, key)
+	c.envMu.Unlock()
+	return c.storage.SetString(key, val)
+}
+
 func (c *ConfigManager) GetAIPort() int {
-	valStr, err := c.storage.GetString(KeyAIPort)
+	valStr, err := c.getString(KeyAIPort)
 	if err != nil || valStr == "" {
 		return 4444 // Default
 	}
@@ -39,11 +114,11 @@ func (c *ConfigManager) GetAIPort() int {
 }
 
 func (c *ConfigManager) SetAIPort(port int) error {
-	return c.storage.SetString(KeyAIPort, strconv.Itoa(port))
+	return c.setString(KeyAIPort, strconv.Itoa(port))
 }
 
 func (c *ConfigManager) GetAIMaxConcurrent() int {
-	valStr, err := c.storage.GetString(KeyAIMaxConcurrent)
+	valStr, err := c.getString(KeyAIMaxConcurrent)
 	if err != nil || valStr == "" {
 		return 5 // Default
 	}
@@ -55,11 +130,11 @@ func (c *ConfigManager) GetAIMaxConcurrent() int {
 }
 
 func (c *ConfigManager) SetAIMaxConcurrent(max int) error {
-	return c.storage.SetString(KeyAIMaxConcurrent, strconv.Itoa(max))
+	return c.setString(KeyAIMaxConcurrent, strconv.Itoa(max))
 }
 
 func (c *ConfigManager) GetEnableAI() bool {
-	val, err := c.storage.GetString(KeyEnableAIInterface)
+	val, err := c.getString(KeyEnableAIInterface)
 	if err != nil {
 		return false
 	}
@@ -71,22 +146,90 @@ func (c *ConfigManager) SetEnableAI(enabled bool) error {
 	if enabled {
 		val = "true"
 	}
-	return c.storage.SetString(KeyEnableAIInterface, val)
+	return c.setString(KeyEnableAIInterface, val)
 }
 
 func (c *ConfigManager) GetAIToken() string {
-	val, err := c.storage.GetString(KeyAIToken)
+	val, err := c.getString(KeyAIToken)
 	if err != nil || val == "" {
 		// Generate if missing
 		token := generateSecureToken()
-		c.storage.SetString(KeyAIToken, token)
+		c.setString(KeyAIToken, token)
 		return token
 	}
 	return val
 }
 
+// GetAIBindAddress returns the interface the control API listens on
+// (default 127.0.0.1, loopback only)
+func (c *ConfigManager) GetAIBindAddress() string {
+	val, err := c.getString(KeyAIBindAddress)
+	if err != nil || val == "" {
+		return "127.0.0.1"
+	}
+	return val
+}
+
+// SetAIBindAddress stores the control API interface, an IP address such
+// as 0.0.0.0 for every interface. Empty restores loopback only.
+func (c *ConfigManager) SetAIBindAddress(addr string) error {
+	if err := checkBindAddress(addr); err != nil {
+		return err
+	}
+	return c.setString(KeyAIBindAddress, addr)
+}
+
+func checkBindAddress(addr string) error {
+	if addr != "" && net.ParseIP(addr) == nil {
+		return fmt.Errorf("invalid bind address %q", addr)
+	}
+	return nil
+}
+
+// GetAIAllowedIPs returns the addresses and CIDR ranges, besides loopback,
+// allowed to use the control API (default none)
+func (c *ConfigManager) GetAIAllowedIPs() []string {
+	val, err := c.getString(KeyAIAllowedIPs)
+	if err != nil || val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
+// SetAIAllowedIPs stores the control API allowlist. Each entry is an IP
+// address or a CIDR range such as 172.17.0.0/16.
+func (c *ConfigManager) SetAIAllowedIPs(entries []string) error {
+	val, err := joinAllowedIPs(entries)
+	if err != nil {
+		return err
+	}
+	return c.setString(KeyAIAllowedIPs, val)
+}
+
+// joinAllowedIPs checks each allowlist entry and joins the non-empty ones
+// in their stored form.
+func joinAllowedIPs(entries []string) (string, error) {
+	cleaned := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return "", fmt.Errorf("invalid address or CIDR range %q", entry)
+		}
+		cleaned = append(cleaned, entry)
+	}
+	return strings.Join(cleaned, ","), nil
+}
+
+// SetAIToken replaces the control API token
+func (c *ConfigManager) SetAIToken(token string) error {
+	return c.setString(KeyAIToken, token)
+}
+
 func (c *ConfigManager) GetEnableIntegrityCheck() bool {
-	val, err := c.storage.GetString(KeyEnableIntegrityCheck)
+	val, err := c.getString(KeyEnableIntegrityCheck)
 	if err != nil {
 		return true // Default True
 	}
@@ -98,11 +241,137 @@ func (c *ConfigManager) SetEnableIntegrityCheck(enabled bool) error {
 	if enabled {
 		val = "true"
 	}
-	return c.storage.SetString(KeyEnableIntegrityCheck, val)
+	return c.setString(KeyEnableIntegrityCheck, val)
+}
+
+// GetWatchDownloadDirs reports whether download folders are watched for
+// externally deleted or moved files (default enabled)
+func (c *ConfigManager) GetWatchDownloadDirs() bool {
+	val, err := c.getString(KeyWatchDownloadDirs)
+	if err != nil {
+		return true
+	}
+	return val != "false"
+}
+
+func (c *ConfigManager) SetWatchDownloadDirs(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyWatchDownloadDirs, val)
+}
+
+// GetFixMissingExtensions reports whether extensionless downloads get an
+// extension from the server's Content-Type (default disabled)
+func (c *ConfigManager) GetFixMissingExtensions() bool {
+	val, err := c.getString(KeyFixExtensions)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetFixMissingExtensions(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyFixExtensions, val)
+}
+
+// GetPreserveModTime reports whether completed files keep the server's
+// Last-Modified time (default disabled)
+func (c *ConfigManager) GetPreserveModTime() bool {
+	val, err := c.getString(KeyPreserveModTime)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetPreserveModTime(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyPreserveModTime, val)
+}
+
+// GetWriteSidecar reports whether a <file>.tachyon.json provenance record
+// is written beside completed downloads (default disabled)
+func (c *ConfigManager) GetWriteSidecar() bool {
+	val, err := c.getString(KeyWriteSidecar)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetWriteSidecar(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyWriteSidecar, val)
+}
+
+// GetVerifyOnOpen reports whether completed downloads with a known hash are
+// re-verified before being opened (default disabled)
+func (c *ConfigManager) GetVerifyOnOpen() bool {
+	val, err := c.getString(KeyVerifyOnOpen)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetVerifyOnOpen(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyVerifyOnOpen, val)
+}
+
+// GetPauseWhenHidden reports whether downloads pause while the window is
+// minimized or hidden to the tray (default disabled)
+func (c *ConfigManager) GetPauseWhenHidden() bool {
+	val, err := c.getString(KeyPauseWhenHidden)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetPauseWhenHidden(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyPauseWhenHidden, val)
+}
+
+// GetHoldQueueWhileVerifying reports whether new downloads wait while a
+// finished one is verified or scanned (default disabled)
+func (c *ConfigManager) GetHoldQueueWhileVerifying() bool {
+	val, err := c.getString(KeyHoldWhileVerifying)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetHoldQueueWhileVerifying(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyHoldWhileVerifying, val)
 }
 
 func (c *ConfigManager) GetEnableAVScan() bool {
-	val, err := c.storage.GetString(KeyEnableAVScan)
+	val, err := c.getString(KeyEnableAVScan)
 	if err != nil {
 		return true // Default enabled
 	}
@@ -114,7 +383,7 @@ func (c *ConfigManager) SetEnableAVScan(enabled bool) error {
 	if enabled {
 		val = "true"
 	}
-	return c.storage.SetString(KeyEnableAVScan, val)
+	return c.setString(KeyEnableAVScan, val)
 }
 
 func generateSecureToken() string {
@@ -129,7 +398,7 @@ func generateSecureToken() string {
 // GetUserAgent returns the custom User-Agent string
 // Returns empty string if not set (caller should use default)
 func (c *ConfigManager) GetUserAgent() string {
-	val, err := c.storage.GetString(KeyUserAgent)
+	val, err := c.getString(KeyUserAgent)
 	if err != nil {
 		return "" // Use default
 	}
@@ -138,7 +407,514 @@ func (c *ConfigManager) GetUserAgent() string {
 
 // SetUserAgent stores a custom User-Agent string
 func (c *ConfigManager) SetUserAgent(ua string) error {
-	return c.storage.SetString(KeyUserAgent, ua)
+	return c.setString(KeyUserAgent, ua)
+}
+
+// GetPartIdleTimeout returns the per-part idle timeout in seconds.
+// 0 means the engine picks an adaptive timeout.
+func (c *ConfigManager) GetPartIdleTimeout() int {
+	valStr, err := c.getString(KeyPartIdleTimeout)
+	if err != nil || valStr == "" {
+		return 0 // Default: adaptive
+	}
+	val, err := strconv.Atoi(valStr)
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// SetPartIdleTimeout stores the per-part idle timeout in seconds (0 = adaptive)
+func (c *ConfigManager) SetPartIdleTimeout(seconds int) error {
+	return c.setString(KeyPartIdleTimeout, strconv.Itoa(seconds))
+}
+
+// GetStallThresholds returns the whole-download stall warn and pause
+// thresholds in seconds. 0 disables a stage.
+func (c *ConfigManager) GetStallThresholds() (warnSeconds, pauseSeconds int) {
+	return c.getNonNegativeInt(KeyStallWarnSeconds, 45), c.getNonNegativeInt(KeyStallPauseSeconds, 300)
+}
+
+// SetStallThresholds stores the stall warn and pause thresholds in seconds
+func (c *ConfigManager) SetStallThresholds(warnSeconds, pauseSeconds int) error {
+	if err := c.setString(KeyStallWarnSeconds, strconv.Itoa(warnSeconds)); err != nil {
+		return err
+	}
+	return c.setString(KeyStallPauseSeconds, strconv.Itoa(pauseSeconds))
+}
+
+// GetMaxQueueSize returns the maximum number of queued downloads (0 = unlimited)
+func (c *ConfigManager) GetMaxQueueSize() int {
+	return c.getNonNegativeInt(KeyMaxQueueSize, 0)
+}
+
+// SetMaxQueueSize stores the maximum number of queued downloads (0 = unlimited)
+func (c *ConfigManager) SetMaxQueueSize(n int) error {
+	return c.setString(KeyMaxQueueSize, strconv.Itoa(n))
+}
+
+// GetMaxConnectionsPerHost returns the cap on simultaneous connections to one
+// host across all downloads (0 = unlimited). Unset, it is the per-download
+// ceiling, so a lone download is never held below it.
+func (c *ConfigManager) GetMaxConnectionsPerHost() int {
+	return c.getNonNegativeInt(KeyMaxConnsPerHost, c.GetMaxConnectionsPerDownload())
+}
+
+// SetMaxConnectionsPerHost stores the per-host connection cap (0 = unlimited)
+func (c *ConfigManager) SetMaxConnectionsPerHost(n int) error {
+	return c.setString(KeyMaxConnsPerHost, strconv.Itoa(n))
+}
+
+// GetMaxConnectionsPerDownload returns the most connections one download
+// may open
+func (c *ConfigManager) GetMaxConnectionsPerDownload() int {
+	n := c.getNonNegativeInt(KeyMaxConnsPerDownload, 24)
+	if n < 1 {
+		return 24
+	}
+	return n
+}
+
+// SetMaxConnectionsPerDownload stores the per-download connection ceiling
+func (c *ConfigManager) SetMaxConnectionsPerDownload(n int) error {
+	return c.setString(KeyMaxConnsPerDownload, strconv.Itoa(n))
+}
+
+// GetMaxVerifications returns how many finished downloads are verified at
+// once. Defaults to 2.
+func (c *ConfigManager) GetMaxVerifications() int {
+	n := c.getNonNegativeInt(KeyMaxVerifications, 2)
+	if n < 1 {
+		return 2
+	}
+	return n
+}
+
+// SetMaxVerifications stores the verification concurrency limit
+func (c *ConfigManager) SetMaxVerifications(n int) error {
+	return c.setString(KeyMaxVerifications, strconv.Itoa(n))
+}
+
+// GetVerifyRetries returns how many times a download that fails its
+// integrity check is downloaded again before it is quarantined. Defaults
+// to 0.
+func (c *ConfigManager) GetVerifyRetries() int {
+	return c.getNonNegativeInt(KeyVerifyRetries, 0)
+}
+
+// SetVerifyRetries stores the re-download count for failed integrity checks
+func (c *ConfigManager) SetVerifyRetries(n int) error {
+	return c.setString(KeyVerifyRetries, strconv.Itoa(n))
+}
+
+// GetDefaultPriority returns the priority (0=Low, 1=Normal, 2=High) new
+// downloads get. Defaults to 1.
+func (c *ConfigManager) GetDefaultPriority() int {
+	p := c.getNonNegativeInt(KeyDefaultPriority, 1)
+	if p > 2 {
+		return 1
+	}
+	return p
+}
+
+// SetDefaultPriority stores the priority new downloads get
+func (c *ConfigManager) SetDefaultPriority(priority int) error {
+	if priority < 0 || priority > 2 {
+		return fmt.Errorf("invalid priority: %d", priority)
+	}
+	return c.setString(KeyDefaultPriority, strconv.Itoa(priority))
+}
+
+// GetConcurrencyAutoscale reports whether the number of simultaneous
+// downloads is picked automatically. Defaults to false.
+func (c *ConfigManager) GetConcurrencyAutoscale() bool {
+	val, err := c.getString(KeyConcurrencyAutoscale)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+// SetConcurrencyAutoscale persists the concurrency autoscaling setting
+func (c *ConfigManager) SetConcurrencyAutoscale(enabled bool) error {
+	return c.setString(KeyConcurrencyAutoscale, strconv.FormatBool(enabled))
+}
+
+// GetCollisionStrategy returns what new downloads do when their file name
+// is taken: "rename", "overwrite", "skip" or "timestamp". Empty (the
+// default) means rename.
+func (c *ConfigManager) GetCollisionStrategy() string {
+	val, err := c.getString(KeyOnCollision)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetCollisionStrategy stores the file name collision strategy
+func (c *ConfigManager) SetCollisionStrategy(name string) error {
+	return c.setString(KeyOnCollision, name)
+}
+
+// GetWorkerGrowthPolicy returns the custom worker growth per scale tick and
+// the tick interval in seconds. 0 (the default) uses the network profile's.
+func (c *ConfigManager) GetWorkerGrowthPolicy() (perTick, intervalSeconds int) {
+	return c.getNonNegativeInt(KeyWorkerGrowth, 0), c.getNonNegativeInt(KeyScaleIntervalSeconds, 0)
+}
+
+// SetWorkerGrowthPolicy stores the worker growth per tick and the tick interval in seconds
+func (c *ConfigManager) SetWorkerGrowthPolicy(perTick, intervalSeconds int) error {
+	if err := c.setString(KeyWorkerGrowth, strconv.Itoa(perTick)); err != nil {
+		return err
+	}
+	return c.setString(KeyScaleIntervalSeconds, strconv.Itoa(intervalSeconds))
+}
+
+// GetHistoryRetention returns how many days finished downloads stay in
+// history (0 = forever, the default) and whether pruning deletes files.
+func (c *ConfigManager) GetHistoryRetention() (days int, deleteFiles bool) {
+	days = c.getNonNegativeInt(KeyRetentionDays, 0)
+	val, err := c.getString(KeyRetentionDeleteFiles)
+	if err != nil {
+		return days, false
+	}
+	return days, val == "true"
+}
+
+// SetHistoryRetention stores the history retention window and file policy
+func (c *ConfigManager) SetHistoryRetention(days int, deleteFiles bool) error {
+	if err := c.setString(KeyRetentionDays, strconv.Itoa(days)); err != nil {
+		return err
+	}
+	return c.setString(KeyRetentionDeleteFiles, strconv.FormatBool(deleteFiles))
+}
+
+// GetSpawnPacing returns the worker start-up jitter and ramp step in
+// milliseconds. 0/0 (the default) disables pacing.
+func (c *ConfigManager) GetSpawnPacing() (jitterMs, rampMs int) {
+	return c.getNonNegativeInt(KeySpawnJitterMs, 0), c.getNonNegativeInt(KeySpawnRampMs, 0)
+}
+
+// SetSpawnPacing stores the worker start-up jitter and ramp step in milliseconds
+func (c *ConfigManager) SetSpawnPacing(jitterMs, rampMs int) error {
+	if err := c.setString(KeySpawnJitterMs, strconv.Itoa(jitterMs)); err != nil {
+		return err
+	}
+	return c.setString(KeySpawnRampMs, strconv.Itoa(rampMs))
+}
+
+// GetTempDownloadDir returns the folder for in-progress downloads.
+// Empty (the default) keeps them beside each download.
+func (c *ConfigManager) GetTempDownloadDir() string {
+	val, err := c.getString(KeyTempDownloadDir)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetTempDownloadDir stores the folder for in-progress downloads
+func (c *ConfigManager) SetTempDownloadDir(dir string) error {
+	return c.setString(KeyTempDownloadDir, dir)
+}
+
+// GetProbeRetries returns how many times a failed probe is retried before
+// the download fails (default 2)
+func (c *ConfigManager) GetProbeRetries() int {
+	return c.getNonNegativeInt(KeyProbeRetries, 2)
+}
+
+// SetProbeRetries stores the number of probe retries
+func (c *ConfigManager) SetProbeRetries(n int) error {
+	return c.setString(KeyProbeRetries, strconv.Itoa(n))
+}
+
+// GetMinPartSizeKB returns the smallest final part, in KB, a download is
+// split into (default 256). 0 disables merging a short tail.
+func (c *ConfigManager) GetMinPartSizeKB() int {
+	return c.getNonNegativeInt(KeyMinPartSizeKB, 256)
+}
+
+// SetMinPartSizeKB stores the minimum final part size in KB
+func (c *ConfigManager) SetMinPartSizeKB(kb int) error {
+	return c.setString(KeyMinPartSizeKB, strconv.Itoa(kb))
+}
+
+// GetTreat416AsComplete reports whether a part whose range starts at the
+// end of the file counts as complete on HTTP 416 (default disabled)
+func (c *ConfigManager) GetTreat416AsComplete() bool {
+	val, err := c.getString(KeyTreat416AsComplete)
+	if err != nil {
+		return false
+	}
+	return val == "true"
+}
+
+func (c *ConfigManager) SetTreat416AsComplete(enabled bool) error {
+	val := "false"
+	if enabled {
+		val = "true"
+	}
+	return c.setString(KeyTreat416AsComplete, val)
+}
+
+// GetAppProgressInterval returns how often, in milliseconds, the combined
+// app:progress event is sent (default 1000). 0 turns it off.
+func (c *ConfigManager) GetAppProgressInterval() int {
+	return c.getNonNegativeInt(KeyAppProgressMs, 1000)
+}
+
+// SetAppProgressInterval stores the app:progress interval in milliseconds
+func (c *ConfigManager) SetAppProgressInterval(ms int) error {
+	return c.setString(KeyAppProgressMs, strconv.Itoa(ms))
+}
+
+// GetDownloadDir returns the folder downloads are saved to when none is
+// given. Empty (the default) means the user's Downloads folder.
+func (c *ConfigManager) GetDownloadDir() string {
+	val, err := c.getString(KeyDownloadDir)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetDownloadDir stores the default download folder
+func (c *ConfigManager) SetDownloadDir(dir string) error {
+	return c.setString(KeyDownloadDir, dir)
+}
+
+// GetMaxConcurrentDownloads returns how many downloads may run at once
+// (default 5)
+func (c *ConfigManager) GetMaxConcurrentDownloads() int {
+	n := c.getNonNegativeInt(KeyMaxConcurrent, 5)
+	if n < 1 {
+		return 5
+	}
+	return n
+}
+
+// SetMaxConcurrentDownloads stores the number of simultaneous downloads
+func (c *ConfigManager) SetMaxConcurrentDownloads(n int) error {
+	return c.setString(KeyMaxConcurrent, strconv.Itoa(n))
+}
+
+// GetGlobalSpeedLimit returns the global speed limit in bytes/sec
+// (default 0 = unlimited)
+func (c *ConfigManager) GetGlobalSpeedLimit() int {
+	return c.getNonNegativeInt(KeyGlobalLimit, 0)
+}
+
+// SetGlobalSpeedLimit stores the global speed limit in bytes/sec
+func (c *ConfigManager) SetGlobalSpeedLimit(bytesPerSec int) error {
+	return c.setString(KeyGlobalLimit, strconv.Itoa(bytesPerSec))
+}
+
+// GetConcurrencyCurve returns the JSON-encoded file size to connection
+// curve. Empty (the default) keeps the engine's built-in curve.
+func (c *ConfigManager) GetConcurrencyCurve() string {
+	val, err := c.getString(KeyConcurrencyCurve)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetConcurrencyCurve stores the JSON-encoded size curve
+func (c *ConfigManager) SetConcurrencyCurve(curve string) error {
+	return c.setString(KeyConcurrencyCurve, curve)
+}
+
+// GetNetworkProfile returns the transport tuning profile name.
+// Empty (the default) keeps the engine's default profile.
+func (c *ConfigManager) GetNetworkProfile() string {
+	val, err := c.getString(KeyNetworkProfile)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetNetworkProfile stores the transport tuning profile name
+func (c *ConfigManager) SetNetworkProfile(name string) error {
+	return c.setString(KeyNetworkProfile, name)
+}
+
+// GetDispatchOrder returns the order queued downloads start in.
+// Empty (the default) keeps the engine's priority order.
+func (c *ConfigManager) GetDispatchOrder() string {
+	val, err := c.getString(KeyDispatchOrder)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetDispatchOrder stores the dispatch order mode
+func (c *ConfigManager) SetDispatchOrder(mode string) error {
+	return c.setString(KeyDispatchOrder, mode)
+}
+
+// GetDoHURL returns the DNS-over-HTTPS provider URL.
+// Empty (the default) uses system DNS.
+func (c *ConfigManager) GetDoHURL() string {
+	val, err := c.getString(KeyDoHURL)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetDoHURL stores the DNS-over-HTTPS provider URL
+func (c *ConfigManager) SetDoHURL(url string) error {
+	return c.setString(KeyDoHURL, url)
+}
+
+// GetSoundPreferences returns the JSON-encoded completion sound settings.
+// Empty (the default) leaves sounds off.
+func (c *ConfigManager) GetSoundPreferences() string {
+	val, err := c.getString(KeySoundPreferences)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetSoundPreferences stores the JSON-encoded completion sound settings
+func (c *ConfigManager) SetSoundPreferences(prefs string) error {
+	return c.setString(KeySoundPreferences, prefs)
+}
+
+// GetNotificationSettings returns the email and chat notification
+// backends. None are stored by default, which leaves them all off.
+func (c *ConfigManager) GetNotificationSettings() (notify.Settings, error) {
+	var s notify.Settings
+	val, err := c.getString(KeyNotifications)
+	if err != nil || val == "" {
+		return s, nil
+	}
+	if err := json.Unmarshal([]byte(val), &s); err != nil {
+		return notify.Settings{}, fmt.Errorf("invalid notification settings: %w", err)
+	}
+	return s, nil
+}
+
+// SetNotificationSettings stores the notification backends
+func (c *ConfigManager) SetNotificationSettings(s notify.Settings) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return c.setString(KeyNotifications, string(data))
+}
+
+// GetCompletionWebhook returns the URL notified when a download finishes or
+// fails, and the secret its payloads are signed with. An empty URL (the
+// default) turns the webhook off.
+func (c *ConfigManager) GetCompletionWebhook() (url, secret string) {
+	url, _ = c.getString(KeyWebhookURL)
+	secret, _ = c.getString(KeyWebhookSecret)
+	return url, secret
+}
+
+// SetCompletionWebhook stores the completion webhook URL and signing secret
+func (c *ConfigManager) SetCompletionWebhook(url, secret string) error {
+	if err := c.setString(KeyWebhookURL, url); err != nil {
+		return err
+	}
+	return c.setString(KeyWebhookSecret, secret)
+}
+
+// GetAuthRefreshURL returns the endpoint asked for a fresh link when a
+// download's link expires. Empty (the default) leaves such downloads
+// waiting for the user.
+func (c *ConfigManager) GetAuthRefreshURL() string {
+	val, err := c.getString(KeyAuthRefreshURL)
+	if err != nil {
+		return ""
+	}
+	return val
+}
+
+// SetAuthRefreshURL stores the link refresh endpoint
+func (c *ConfigManager) SetAuthRefreshURL(url string) error {
+	return c.setString(KeyAuthRefreshURL, url)
+}
+
+// getNonNegativeInt reads an integer setting, falling back to def when the
+// key is unset or invalid.
+func (c *ConfigManager) getNonNegativeInt(key string, def int) int {
+	valStr, err := c.getString(key)
+	if err != nil || valStr == "" {
+		return def
+	}
+	val, err := strconv.Atoi(valStr)
+	if err != nil || val < 0 {
+		return def
+	}
+	return val
+}
+
+// GetAll returns the effective value of every setting, defaults included,
+// keyed by the same names UpdateSettings writes. The AI token, webhook
+// secret and notification backends are left out so a settings snapshot
+// never carries a credential; use GetAIToken, GetCompletionWebhook and
+// GetNotificationSettings.
+func (c *ConfigManager) GetAll() map[string]interface{} {
+	warn, pause := c.GetStallThresholds()
+	jitter, ramp := c.GetSpawnPacing()
+	growth, scaleInterval := c.GetWorkerGrowthPolicy()
+	retentionDays, retentionDeleteFiles := c.GetHistoryRetention()
+	webhookURL, _ := c.GetCompletionWebhook()
+	return map[string]interface{}{
+		KeyEnableAIInterface:    c.GetEnableAI(),
+		KeyEnableIntegrityCheck: c.GetEnableIntegrityCheck(),
+		KeyEnableAVScan:         c.GetEnableAVScan(),
+		KeyAIPort:               c.GetAIPort(),
+		KeyAIMaxConcurrent:      c.GetAIMaxConcurrent(),
+		KeyUserAgent:            c.GetUserAgent(),
+		KeyPartIdleTimeout:      c.GetPartIdleTimeout(),
+		KeyStallWarnSeconds:     warn,
+		KeyStallPauseSeconds:    pause,
+		KeyMaxQueueSize:         c.GetMaxQueueSize(),
+		KeyMaxConnsPerHost:      c.GetMaxConnectionsPerHost(),
+		KeySpawnJitterMs:        jitter,
+		KeySpawnRampMs:          ramp,
+		KeyWatchDownloadDirs:    c.GetWatchDownloadDirs(),
+		KeyTempDownloadDir:      c.GetTempDownloadDir(),
+		KeyProbeRetries:         c.GetProbeRetries(),
+		KeyFixExtensions:        c.GetFixMissingExtensions(),
+		KeyPreserveModTime:      c.GetPreserveModTime(),
+		KeyWriteSidecar:         c.GetWriteSidecar(),
+		KeyConcurrencyCurve:     c.GetConcurrencyCurve(),
+		KeyNetworkProfile:       c.GetNetworkProfile(),
+		KeyDoHURL:               c.GetDoHURL(),
+		KeySoundPreferences:     c.GetSoundPreferences(),
+		KeyMaxConnsPerDownload:  c.GetMaxConnectionsPerDownload(),
+		KeyRetentionDays:        retentionDays,
+		KeyRetentionDeleteFiles: retentionDeleteFiles,
+		KeyConcurrencyAutoscale: c.GetConcurrencyAutoscale(),
+		KeyWorkerGrowth:         growth,
+		KeyScaleIntervalSeconds: scaleInterval,
+		KeyOnCollision:          c.GetCollisionStrategy(),
+		KeyWebhookURL:           webhookURL,
+		KeyMaxVerifications:     c.GetMaxVerifications(),
+		KeyVerifyOnOpen:         c.GetVerifyOnOpen(),
+		KeyHoldWhileVerifying:   c.GetHoldQueueWhileVerifying(),
+		KeyDefaultPriority:      c.GetDefaultPriority(),
+		KeyDispatchOrder:        c.GetDispatchOrder(),
+		KeyAuthRefreshURL:       c.GetAuthRefreshURL(),
+		KeyPauseWhenHidden:      c.GetPauseWhenHidden(),
+		KeyMinPartSizeKB:        c.GetMinPartSizeKB(),
+		KeyAppProgressMs:        c.GetAppProgressInterval(),
+		KeyDownloadDir:          c.GetDownloadDir(),
+		KeyMaxConcurrent:        c.GetMaxConcurrentDownloads(),
+		KeyGlobalLimit:          c.GetGlobalSpeedLimit(),
+		KeyAIBindAddress:        c.GetAIBindAddress(),
+		KeyAIAllowedIPs:         strings.Join(c.GetAIAllowedIPs(), ","),
+		KeyTreat416AsComplete:   c.GetTreat416AsComplete(),
+		KeyVerifyRetries:        c.GetVerifyRetries(),
+	}
 }
 
 // FactoryReset resets all configuration to defaults
@@ -151,13 +927,56 @@ func (c *ConfigManager) FactoryReset() error {
 		KeyAIPort,
 		KeyAIMaxConcurrent,
 		KeyUserAgent,
+		KeyPartIdleTimeout,
+		KeyStallWarnSeconds,
+		KeyStallPauseSeconds,
+		KeyMaxQueueSize,
+		KeyMaxConnsPerHost,
+		KeySpawnJitterMs,
+		KeySpawnRampMs,
+		KeyWatchDownloadDirs,
+		KeyTempDownloadDir,
+		KeyProbeRetries,
+		KeyFixExtensions,
+		KeyPreserveModTime,
+		KeyWriteSidecar,
+		KeyConcurrencyCurve,
+		KeyNetworkProfile,
+		KeyDoHURL,
+		KeySoundPreferences,
+		KeyMaxConnsPerDownload,
+		KeyRetentionDays,
+		KeyRetentionDeleteFiles,
+		KeyConcurrencyAutoscale,
+		KeyWorkerGrowth,
+		KeyScaleIntervalSeconds,
+		KeyOnCollision,
+		KeyWebhookURL,
+		KeyWebhookSecret,
+		KeyMaxVerifications,
+		KeyVerifyOnOpen,
+		KeyHoldWhileVerifying,
+		KeyDefaultPriority,
+		KeyDispatchOrder,
+		KeyAuthRefreshURL,
+		KeyPauseWhenHidden,
+		KeyMinPartSizeKB,
+		KeyAppProgressMs,
+		KeyDownloadDir,
+		KeyMaxConcurrent,
+		KeyGlobalLimit,
+		KeyAIBindAddress,
+		KeyAIAllowedIPs,
+		KeyTreat416AsComplete,
+		KeyNotifications,
+		KeyVerifyRetries,
 	}
 
 	for _, key := range keys {
 		// Set to empty string effectively resets it (or we could use a DeleteString if we had one)
 		// Since we don't have DeleteString in Storage interface exposed here yet (it only has DeleteTask/Location),
 		// we can set to empty. Getters check for empty string.
-		if err := c.storage.SetString(key, ""); err != nil {
+		if err := c.setString(key, ""); err != nil {
 			return err
 		}
 	}
@@ -3,8 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
+	"project-tachyon/internal/notify"
 	"project-tachyon/internal/storage"
 )
 
@@ -99,5 +101,750 @@ func TestConfigManager_UserAgentEmpty(t *testing.T) {
 	}
 }
 
+func TestConfigManager_PartIdleTimeout(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetPartIdleTimeout() != 0 {
+		t.Fatalf("expected adaptive (0) by default, got %d", cfg.GetPartIdleTimeout())
+	}
+	if err := cfg.SetPartIdleTimeout(45); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetPartIdleTimeout() != 45 {
+		t.Fatalf("expected 45, got %d", cfg.GetPartIdleTimeout())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetPartIdleTimeout() != 0 {
+		t.Fatalf("expected reset to 0, got %d", cfg.GetPartIdleTimeout())
+	}
+}
+
+func TestConfigManager_ProbeRetries(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetProbeRetries() != 2 {
+		t.Fatalf("expected default 2, got %d", cfg.GetProbeRetries())
+	}
+	if err := cfg.SetProbeRetries(0); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetProbeRetries() != 0 {
+		t.Fatalf("expected 0, got %d", cfg.GetProbeRetries())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetProbeRetries() != 2 {
+		t.Fatalf("expected reset to 2, got %d", cfg.GetProbeRetries())
+	}
+}
+
+func TestConfigManager_MinPartSizeKB(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetMinPartSizeKB() != 256 {
+		t.Fatalf("expected default 256, got %d", cfg.GetMinPartSizeKB())
+	}
+	if err := cfg.SetMinPartSizeKB(0); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetMinPartSizeKB() != 0 {
+		t.Fatalf("expected 0, got %d", cfg.GetMinPartSizeKB())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetMinPartSizeKB() != 256 {
+		t.Fatalf("expected reset to 256, got %d", cfg.GetMinPartSizeKB())
+	}
+}
+
+func TestConfigManager_Treat416AsComplete(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetTreat416AsComplete() {
+		t.Fatal("expected disabled by default")
+	}
+	if err := cfg.SetTreat416AsComplete(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetTreat416AsComplete() {
+		t.Fatal("expected enabled")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetTreat416AsComplete() {
+		t.Fatal("expected reset to disabled")
+	}
+}
+
+func TestConfigManager_AppProgressInterval(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetAppProgressInterval() != 1000 {
+		t.Fatalf("expected default 1000, got %d", cfg.GetAppProgressInterval())
+	}
+	if err := cfg.SetAppProgressInterval(0); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetAppProgressInterval() != 0 {
+		t.Fatalf("expected 0, got %d", cfg.GetAppProgressInterval())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetAppProgressInterval() != 1000 {
+		t.Fatalf("expected reset to 1000, got %d", cfg.GetAppProgressInterval())
+	}
+}
+
+func TestConfigManager_AIBindAndAllowlist(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetAIBindAddress(); got != "127.0.0.1" {
+		t.Fatalf("expected default 127.0.0.1, got %q", got)
+	}
+	if got := cfg.GetAIAllowedIPs(); len(got) != 0 {
+		t.Fatalf("expected an empty allowlist, got %v", got)
+	}
+	if err := cfg.SetAIBindAddress("localhost"); err == nil {
+		t.Error("expected a host name to be rejected as bind address")
+	}
+	if err := cfg.SetAIBindAddress("0.0.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetAIBindAddress(); got != "0.0.0.0" {
+		t.Errorf("expected 0.0.0.0, got %q", got)
+	}
+
+	if err := cfg.SetAIAllowedIPs([]string{"10.0.0.1", "bogus"}); err == nil {
+		t.Error("expected an invalid entry to be rejected")
+	}
+	if err := cfg.SetAIAllowedIPs([]string{" 172.17.0.0/16", "", "192.168.1.5 "}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetAIAllowedIPs(); len(got) != 2 || got[0] != "172.17.0.0/16" || got[1] != "192.168.1.5" {
+		t.Errorf("allowlist = %v", got)
+	}
+
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetAIBindAddress() != "127.0.0.1" || len(cfg.GetAIAllowedIPs()) != 0 {
+		t.Error("expected reset to loopback only")
+	}
+}
+
+func TestConfigManager_FixMissingExtensions(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetFixMissingExtensions() {
+		t.Fatal("expected disabled by default")
+	}
+	if err := cfg.SetFixMissingExtensions(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetFixMissingExtensions() {
+		t.Fatal("expected enabled")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetFixMissingExtensions() {
+		t.Fatal("expected reset to disabled")
+	}
+}
+
+func TestConfigManager_PreserveModTime(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetPreserveModTime() {
+		t.Fatal("expected disabled by default")
+	}
+	if err := cfg.SetPreserveModTime(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetPreserveModTime() {
+		t.Fatal("expected enabled")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetPreserveModTime() {
+		t.Fatal("expected reset to disabled")
+	}
+}
+
+func TestConfigManager_HoldQueueWhileVerifying(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetHoldQueueWhileVerifying() {
+		t.Fatal("expected disabled by default")
+	}
+	if err := cfg.SetHoldQueueWhileVerifying(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetHoldQueueWhileVerifying() {
+		t.Fatal("expected enabled")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetHoldQueueWhileVerifying() {
+		t.Fatal("expected reset to disabled")
+	}
+}
+
+func TestConfigManager_DefaultPriority(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetDefaultPriority(); got != 1 {
+		t.Fatalf("default = %d, want 1 (Normal)", got)
+	}
+	if err := cfg.SetDefaultPriority(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetDefaultPriority(); got != 2 {
+		t.Fatalf("got %d, want 2", got)
+	}
+	for _, p := range []int{-1, 3} {
+		if err := cfg.SetDefaultPriority(p); err == nil {
+			t.Errorf("SetDefaultPriority(%d) succeeded", p)
+		}
+	}
+	if got := cfg.GetDefaultPriority(); got != 2 {
+		t.Errorf("invalid values changed the setting to %d", got)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetDefaultPriority(); got != 1 {
+		t.Errorf("after reset = %d, want 1", got)
+	}
+}
+
+func TestConfigManager_VerifyOnOpen(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetVerifyOnOpen() {
+		t.Fatal("expected disabled by default")
+	}
+	if err := cfg.SetVerifyOnOpen(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetVerifyOnOpen() {
+		t.Fatal("expected enabled")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetVerifyOnOpen() {
+		t.Fatal("expected reset to disabled")
+	}
+}
+
+func TestConfigManager_WriteSidecar(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetWriteSidecar() {
+		t.Fatal("expected disabled by default")
+	}
+	if err := cfg.SetWriteSidecar(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetWriteSidecar() {
+		t.Fatal("expected enabled")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetWriteSidecar() {
+		t.Fatal("expected reset to disabled")
+	}
+}
+
+func TestConfigManager_ConcurrencyCurve(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetConcurrencyCurve() != "" {
+		t.Fatalf("expected no stored curve by default, got %q", cfg.GetConcurrencyCurve())
+	}
+	curve := `[{"max_size":1048576,"connections":2}]`
+	if err := cfg.SetConcurrencyCurve(curve); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetConcurrencyCurve() != curve {
+		t.Fatalf("expected %q, got %q", curve, cfg.GetConcurrencyCurve())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetConcurrencyCurve() != "" {
+		t.Fatalf("expected factory reset to clear curve, got %q", cfg.GetConcurrencyCurve())
+	}
+}
+
+func TestConfigManager_DispatchOrder(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetDispatchOrder(); got != "" {
+		t.Fatalf("expected no order by default, got %q", got)
+	}
+	if err := cfg.SetDispatchOrder("size_asc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetDispatchOrder(); got != "size_asc" {
+		t.Fatalf("expected size_asc, got %q", got)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetDispatchOrder(); got != "" {
+		t.Fatalf("expected factory reset to clear the order, got %q", got)
+	}
+}
+
+func TestConfigManager_AuthRefreshURL(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetAuthRefreshURL(); got != "" {
+		t.Fatalf("expected no refresh endpoint by default, got %q", got)
+	}
+	if err := cfg.SetAuthRefreshURL("https://tokens.example.com/refresh"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetAuthRefreshURL(); got != "https://tokens.example.com/refresh" {
+		t.Fatalf("got %q", got)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetAuthRefreshURL(); got != "" {
+		t.Fatalf("expected factory reset to clear the endpoint, got %q", got)
+	}
+}
+
+func TestConfigManager_PauseWhenHidden(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetPauseWhenHidden() {
+		t.Fatal("expected pause-when-hidden to be off by default")
+	}
+	if err := cfg.SetPauseWhenHidden(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetPauseWhenHidden() {
+		t.Fatal("expected pause-when-hidden to be on")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetPauseWhenHidden() {
+		t.Fatal("expected factory reset to turn pause-when-hidden off")
+	}
+}
+
+func TestConfigManager_NetworkProfile(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetNetworkProfile() != "" {
+		t.Fatalf("expected no profile by default, got %q", cfg.GetNetworkProfile())
+	}
+	if err := cfg.SetNetworkProfile("satellite"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetNetworkProfile() != "satellite" {
+		t.Fatalf("expected satellite, got %q", cfg.GetNetworkProfile())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetNetworkProfile() != "" {
+		t.Fatalf("expected factory reset to clear profile, got %q", cfg.GetNetworkProfile())
+	}
+}
+
+func TestConfigManager_DoHURL(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetDoHURL() != "" {
+		t.Fatalf("expected system DNS by default, got %q", cfg.GetDoHURL())
+	}
+	if err := cfg.SetDoHURL("https://dns.example/dns-query"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetDoHURL() != "https://dns.example/dns-query" {
+		t.Fatalf("unexpected DoH URL %q", cfg.GetDoHURL())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetDoHURL() != "" {
+		t.Fatalf("expected factory reset to clear DoH URL, got %q", cfg.GetDoHURL())
+	}
+}
+
+func TestConfigManager_SoundPreferences(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetSoundPreferences() != "" {
+		t.Fatalf("expected no stored sound preferences by default, got %q", cfg.GetSoundPreferences())
+	}
+	prefs := `{"enabled":true,"on_complete":true,"on_error":false,"quiet_start":"22:00","quiet_end":"07:00"}`
+	if err := cfg.SetSoundPreferences(prefs); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetSoundPreferences() != prefs {
+		t.Fatalf("expected %q, got %q", prefs, cfg.GetSoundPreferences())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetSoundPreferences() != "" {
+		t.Fatalf("expected factory reset to clear sound preferences, got %q", cfg.GetSoundPreferences())
+	}
+}
+
+func TestConfigManager_NotificationSettings(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got, err := cfg.GetNotificationSettings(); err != nil || got.Telegram.Enabled || got.Email.Enabled || got.Discord.Enabled {
+		t.Fatalf("expected every backend off by default, got %+v, %v", got, err)
+	}
+	want := notify.Settings{
+		Email:    notify.EmailConfig{Enabled: true, Host: "smtp.example.com", From: "tachyon@example.com", To: []string{"me@example.com"}},
+		Telegram: notify.TelegramConfig{Enabled: true, BotToken: "123:abc", ChatID: "42"},
+	}
+	if err := cfg.SetNotificationSettings(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cfg.GetNotificationSettings()
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, %v, want %+v", got, err, want)
+	}
+
+	cfg.storage.SetString(KeyNotifications, "{not json")
+	if _, err := cfg.GetNotificationSettings(); err == nil {
+		t.Error("expected an error for corrupt notification settings")
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cfg.GetNotificationSettings(); err != nil || got.Telegram.Enabled {
+		t.Fatalf("expected factory reset to clear notification backends, got %+v, %v", got, err)
+	}
+}
+
+func TestConfigManager_MaxConnectionsPerDownload(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetMaxConnectionsPerDownload(); got != 24 {
+		t.Fatalf("expected default 24, got %d", got)
+	}
+	if err := cfg.SetMaxConnectionsPerDownload(48); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetMaxConnectionsPerDownload(); got != 48 {
+		t.Fatalf("expected 48, got %d", got)
+	}
+	cfg.SetMaxConnectionsPerDownload(0)
+	if got := cfg.GetMaxConnectionsPerDownload(); got != 24 {
+		t.Fatalf("expected 0 to fall back to the default, got %d", got)
+	}
+}
+
+func TestConfigManager_ConcurrencyAutoscale(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetConcurrencyAutoscale() {
+		t.Fatal("expected autoscaling off by default")
+	}
+	if err := cfg.SetConcurrencyAutoscale(true); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GetConcurrencyAutoscale() {
+		t.Error("expected autoscaling on after SetConcurrencyAutoscale(true)")
+	}
+}
+
+func TestConfigManager_HistoryRetention(t *testing.T) {
+	cfg := newTestConfig(t)
+	if days, deleteFiles := cfg.GetHistoryRetention(); days != 0 || deleteFiles {
+		t.Fatalf("expected history kept forever by default, got %d/%v", days, deleteFiles)
+	}
+	if err := cfg.SetHistoryRetention(30, true); err != nil {
+		t.Fatal(err)
+	}
+	if days, deleteFiles := cfg.GetHistoryRetention(); days != 30 || !deleteFiles {
+		t.Fatalf("expected 30/true, got %d/%v", days, deleteFiles)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if days, deleteFiles := cfg.GetHistoryRetention(); days != 0 || deleteFiles {
+		t.Fatalf("expected factory reset to clear retention, got %d/%v", days, deleteFiles)
+	}
+}
+
+func TestConfigManager_GetAllDefaults(t *testing.T) {
+	cfg := newTestConfig(t)
+	want := map[string]interface{}{
+		KeyEnableAIInterface:    false,
+		KeyEnableIntegrityCheck: true,
+		KeyEnableAVScan:         true,
+		KeyAIPort:               4444,
+		KeyAIMaxConcurrent:      5,
+		KeyUserAgent:            "",
+		KeyPartIdleTimeout:      0,
+		KeyStallWarnSeconds:     45,
+		KeyStallPauseSeconds:    300,
+		KeyMaxQueueSize:         0,
+		KeyMaxConnsPerHost:      24,
+		KeySpawnJitterMs:        0,
+		KeySpawnRampMs:          0,
+		KeyWatchDownloadDirs:    true,
+		KeyTempDownloadDir:      "",
+		KeyProbeRetries:         2,
+		KeyFixExtensions:        false,
+		KeyPreserveModTime:      false,
+		KeyWriteSidecar:         false,
+		KeyConcurrencyCurve:     "",
+		KeyNetworkProfile:       "",
+		KeyDoHURL:               "",
+		KeySoundPreferences:     "",
+		KeyMaxConnsPerDownload:  24,
+		KeyRetentionDays:        0,
+		KeyRetentionDeleteFiles: false,
+		KeyConcurrencyAutoscale: false,
+		KeyWorkerGrowth:         0,
+		KeyScaleIntervalSeconds: 0,
+		KeyOnCollision:          "",
+		KeyWebhookURL:           "",
+		KeyMaxVerifications:     2,
+		KeyVerifyOnOpen:         false,
+		KeyHoldWhileVerifying:   false,
+		KeyDefaultPriority:      1,
+		KeyDispatchOrder:        "",
+		KeyAuthRefreshURL:       "",
+		KeyPauseWhenHidden:      false,
+		KeyMinPartSizeKB:        256,
+		KeyAppProgressMs:        1000,
+		KeyDownloadDir:          "",
+		KeyMaxConcurrent:        5,
+		KeyGlobalLimit:          0,
+		KeyAIBindAddress:        "127.0.0.1",
+		KeyAIAllowedIPs:         "",
+		KeyTreat416AsComplete:   false,
+		KeyVerifyRetries:        0,
+	}
+
+	got := cfg.GetAll()
+	for key, def := range want {
+		val, ok := got[key]
+		if !ok {
+			t.Errorf("missing setting %q", key)
+			continue
+		}
+		if val != def {
+			t.Errorf("%s = %v (%T), want default %v (%T)", key, val, val, def, def)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("unexpected setting %q", key)
+		}
+	}
+	if _, ok := got[KeyAIToken]; ok {
+		t.Error("the AI token must not be part of the snapshot")
+	}
+	if _, ok := got[KeyWebhookSecret]; ok {
+		t.Error("the webhook secret must not be part of the snapshot")
+	}
+	if _, ok := got[KeyNotifications]; ok {
+		t.Error("the notification backends must not be part of the snapshot")
+	}
+}
+
+func TestConfigManager_GetAllReflectsChanges(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SetAIPort(5555)
+	cfg.SetStallThresholds(10, 0)
+	cfg.SetWatchDownloadDirs(false)
+
+	got := cfg.GetAll()
+	if got[KeyAIPort] != 5555 || got[KeyStallWarnSeconds] != 10 || got[KeyStallPauseSeconds] != 0 {
+		t.Errorf("snapshot does not reflect stored ints: %v", got)
+	}
+	if got[KeyWatchDownloadDirs] != false {
+		t.Errorf("watch_download_dirs = %v, want false", got[KeyWatchDownloadDirs])
+	}
+}
+
+func TestConfigManager_WorkerGrowthPolicy(t *testing.T) {
+	cfg := newTestConfig(t)
+	if perTick, interval := cfg.GetWorkerGrowthPolicy(); perTick != 0 || interval != 0 {
+		t.Fatalf("expected the profile's policy by default, got %d/%d", perTick, interval)
+	}
+	if err := cfg.SetWorkerGrowthPolicy(4, 2); err != nil {
+		t.Fatal(err)
+	}
+	if perTick, interval := cfg.GetWorkerGrowthPolicy(); perTick != 4 || interval != 2 {
+		t.Fatalf("expected 4/2, got %d/%d", perTick, interval)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if perTick, interval := cfg.GetWorkerGrowthPolicy(); perTick != 0 || interval != 0 {
+		t.Errorf("expected reset to 0/0, got %d/%d", perTick, interval)
+	}
+}
+
+func TestConfigManager_CollisionStrategy(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetCollisionStrategy(); got != "" {
+		t.Fatalf("expected no strategy by default, got %q", got)
+	}
+	if err := cfg.SetCollisionStrategy("skip"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetCollisionStrategy(); got != "skip" {
+		t.Fatalf("expected skip, got %q", got)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetCollisionStrategy(); got != "" {
+		t.Errorf("expected reset to default, got %q", got)
+	}
+}
+
+func TestConfigManager_StallThresholds(t *testing.T) {
+	cfg := newTestConfig(t)
+	warn, pause := cfg.GetStallThresholds()
+	if warn != 45 || pause != 300 {
+		t.Fatalf("expected defaults 45/300, got %d/%d", warn, pause)
+	}
+	if err := cfg.SetStallThresholds(10, 0); err != nil {
+		t.Fatal(err)
+	}
+	warn, pause = cfg.GetStallThresholds()
+	if warn != 10 || pause != 0 {
+		t.Fatalf("expected 10/0, got %d/%d", warn, pause)
+	}
+}
+
+func TestConfigManager_MaxQueueSize(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetMaxQueueSize() != 0 {
+		t.Fatalf("expected unlimited (0) by default, got %d", cfg.GetMaxQueueSize())
+	}
+	if err := cfg.SetMaxQueueSize(50); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetMaxQueueSize() != 50 {
+		t.Fatalf("expected 50, got %d", cfg.GetMaxQueueSize())
+	}
+}
+
+func TestConfigManager_MaxConnectionsPerHost(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetMaxConnectionsPerHost() != 24 {
+		t.Fatalf("expected default 24, got %d", cfg.GetMaxConnectionsPerHost())
+	}
+	if err := cfg.SetMaxConnectionsPerDownload(48); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetMaxConnectionsPerHost() != 48 {
+		t.Fatalf("expected an unset cap to follow the per-download ceiling to 48, got %d", cfg.GetMaxConnectionsPerHost())
+	}
+	if err := cfg.SetMaxConnectionsPerHost(0); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetMaxConnectionsPerHost() != 0 {
+		t.Fatalf("expected 0 (unlimited), got %d", cfg.GetMaxConnectionsPerHost())
+	}
+}
+
+func TestConfigManager_SpawnPacing(t *testing.T) {
+	cfg := newTestConfig(t)
+	if j, r := cfg.GetSpawnPacing(); j != 0 || r != 0 {
+		t.Fatalf("expected pacing off by default, got %d/%d", j, r)
+	}
+	if err := cfg.SetSpawnPacing(200, 150); err != nil {
+		t.Fatal(err)
+	}
+	if j, r := cfg.GetSpawnPacing(); j != 200 || r != 150 {
+		t.Fatalf("expected 200/150, got %d/%d", j, r)
+	}
+}
+
+func TestConfigManager_WatchDownloadDirs(t *testing.T) {
+	cfg := newTestConfig(t)
+	if !cfg.GetWatchDownloadDirs() {
+		t.Fatal("expected folder watching enabled by default")
+	}
+	if err := cfg.SetWatchDownloadDirs(false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetWatchDownloadDirs() {
+		t.Fatal("expected folder watching disabled after set")
+	}
+}
+
+func TestConfigManager_TempDownloadDir(t *testing.T) {
+	cfg := newTestConfig(t)
+	if cfg.GetTempDownloadDir() != "" {
+		t.Fatalf("expected no temp dir by default, got %q", cfg.GetTempDownloadDir())
+	}
+	if err := cfg.SetTempDownloadDir("/fast/tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetTempDownloadDir() != "/fast/tmp" {
+		t.Fatalf("expected /fast/tmp, got %q", cfg.GetTempDownloadDir())
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetTempDownloadDir() != "" {
+		t.Fatalf("expected factory reset to clear temp dir, got %q", cfg.GetTempDownloadDir())
+	}
+}
+
 // Suppress unused import warning
 var _ = os.DevNull
+
+func TestConfigManager_CompletionWebhook(t *testing.T) {
+	cfg := newTestConfig(t)
+	if url, secret := cfg.GetCompletionWebhook(); url != "" || secret != "" {
+		t.Fatalf("expected no webhook by default, got %q/%q", url, secret)
+	}
+	if err := cfg.SetCompletionWebhook("https://hooks.example.com/tachyon", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if url, secret := cfg.GetCompletionWebhook(); url != "https://hooks.example.com/tachyon" || secret != "s3cret" {
+		t.Fatalf("got %q/%q", url, secret)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if url, secret := cfg.GetCompletionWebhook(); url != "" || secret != "" {
+		t.Errorf("expected factory reset to clear the webhook, got %q/%q", url, secret)
+	}
+}
+
+func TestConfigManager_MaxVerifications(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetMaxVerifications(); got != 2 {
+		t.Fatalf("expected default 2, got %d", got)
+	}
+	if err := cfg.SetMaxVerifications(4); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetMaxVerifications(); got != 4 {
+		t.Fatalf("expected 4, got %d", got)
+	}
+	cfg.SetMaxVerifications(0)
+	if got := cfg.GetMaxVerifications(); got != 2 {
+		t.Errorf("expected 0 to fall back to the default, got %d", got)
+	}
+}
+
+func TestConfigManager_VerifyRetries(t *testing.T) {
+	cfg := newTestConfig(t)
+	if got := cfg.GetVerifyRetries(); got != 0 {
+		t.Fatalf("expected default 0, got %d", got)
+	}
+	if err := cfg.SetVerifyRetries(3); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetVerifyRetries(); got != 3 {
+		t.Fatalf("expected 3, got %d", got)
+	}
+	if err := cfg.FactoryReset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetVerifyRetries(); got != 0 {
+		t.Errorf("expected factory reset to restore 0, got %d", got)
+	}
+}
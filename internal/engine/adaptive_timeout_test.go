@@ -63,3 +63,20 @@ func TestAdaptiveStallTimeout_TypeIsDuration(t *testing.T) {
 	timeout := adaptiveStallTimeout(1000, BufferSize)
 	var _ time.Duration = timeout // compile-time type check
 }
+
+func TestStallTimeout_FixedOverride(t *testing.T) {
+	e := &TachyonEngine{}
+	if got := e.stallTimeout(0, BufferSize); got != maxStallTimeout {
+		t.Errorf("expected adaptive default %v, got %v", maxStallTimeout, got)
+	}
+
+	e.SetPartIdleTimeout(3 * time.Second)
+	if got := e.stallTimeout(0, BufferSize); got != 3*time.Second {
+		t.Errorf("expected fixed 3s timeout, got %v", got)
+	}
+
+	e.SetPartIdleTimeout(-time.Second)
+	if got := e.GetPartIdleTimeout(); got != 0 {
+		t.Errorf("negative timeout should reset to adaptive, got %v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAppProgressInterval is how often app:progress is emitted while
+// downloads are running.
+const DefaultAppProgressInterval = time.Second
+
+// AppProgress sums up every running download, for views too small to list
+// them one by one (the tray, a compact window).
+type AppProgress struct {
+	Active     int     `json:"active"`     // downloads transferring now
+	Speed      int64   `json:"speed"`      // bytes/sec summed over them
+	TotalSize  int64   `json:"total_size"` // bytes, of the downloads with a known size
+	Downloaded int64   `json:"downloaded"` // bytes received so far
+	Progress   float64 `json:"progress"`   // percent of TotalSize
+}
+
+// SetAppProgressInterval sets how often app:progress is emitted while
+// downloads are running. 0 turns the event off.
+func (e *TachyonEngine) SetAppProgressInterval(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	e.appProgressInterval.Store(int64(d))
+}
+
+// GetAppProgressInterval returns the app:progress interval (0 = off).
+func (e *TachyonEngine) GetAppProgressInterval() time.Duration {
+	return time.Duration(e.appProgressInterval.Load())
+}
+
+// GetAppProgress returns the combined progress of the running downloads,
+// from their live byte counters and speed.
+func (e *TachyonEngine) GetAppProgress() AppProgress {
+	var p AppProgress
+	var sizedDownloaded int64
+	e.activeDownloads.Range(func(_, value interface{}) bool {
+		info, ok := value.(*activeDownloadInfo)
+		if !ok {
+			return true
+		}
+		p.Active++
+		p.Speed += info.Speed.Load()
+		// Still probing, or a stream without a byte counter: nothing to add
+		counter := info.downloaded.Load()
+		if counter == nil {
+			return true
+		}
+		downloaded := atomic.LoadInt64(counter)
+		p.Downloaded += downloaded
+		if size := info.totalSize.Load(); size > 0 {
+			p.TotalSize += size
+			sizedDownloaded += downloaded
+		}
+		return true
+	})
+	if p.TotalSize > 0 {
+		p.Progress = float64(sizedDownloaded) / float64(p.TotalSize) * 100
+	}
+	return p
+}
+
+// appProgressLoop emits app:progress while downloads are running, plus one
+// last event when the final one stops, until ctx is cancelled.
+func (e *TachyonEngine) appProgressLoop(ctx context.Context) {
+	running := false
+	for {
+		interval := e.GetAppProgressInterval()
+		wait := interval
+		if wait <= 0 {
+			// Off: check back now and then in case it is turned on again
+			wait = DefaultAppProgressInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if interval <= 0 {
+			running = false
+			continue
+		}
+		p := e.GetAppProgress()
+		if p.Active > 0 || running {
+			e.emit("app:progress", p)
+		}
+		running = p.Active > 0
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetAppProgress_SumsActiveDownloads(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	defer e.Shutdown()
+
+	// The live counters are what counts; nothing is read from the database
+	live := map[string]struct{ speed, size, downloaded int64 }{
+		"a":      {speed: 100, size: 1000, downloaded: 600},
+		"b":      {speed: 200, size: 3000, downloaded: 900},
+		"stream": {speed: 50, downloaded: 500},
+	}
+	for id, l := range live {
+		info := &activeDownloadInfo{Wait: &sync.WaitGroup{}}
+		info.Speed.Store(l.speed)
+		info.totalSize.Store(l.size)
+		downloaded := l.downloaded
+		info.downloaded.Store(&downloaded)
+		e.activeDownloads.Store(id, info)
+	}
+	// Still probing: active, but no bytes yet
+	e.activeDownloads.Store("probing", &activeDownloadInfo{Wait: &sync.WaitGroup{}})
+
+	p := e.GetAppProgress()
+	want := AppProgress{Active: 4, Speed: 350, TotalSize: 4000, Downloaded: 2000, Progress: 37.5}
+	if p != want {
+		t.Errorf("GetAppProgress = %+v, want %+v", p, want)
+	}
+
+	for id := range live {
+		e.activeDownloads.Delete(id)
+	}
+	e.activeDownloads.Delete("probing")
+	if p := e.GetAppProgress(); p != (AppProgress{}) {
+		t.Errorf("GetAppProgress with nothing running = %+v, want zero", p)
+	}
+}
+
+func TestAppProgressLoop_EmitsWhileRunning(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	defer e.Shutdown()
+	e.SetAppProgressInterval(20 * time.Millisecond)
+
+	events := make(chan AppProgress, 64)
+	e.eventHook = func(name string, data interface{}) {
+		if name == "app:progress" {
+			events <- data.(AppProgress)
+		}
+	}
+	info := &activeDownloadInfo{Wait: &sync.WaitGroup{}}
+	info.Speed.Store(400)
+	info.totalSize.Store(1000)
+	downloaded := int64(250)
+	info.downloaded.Store(&downloaded)
+	e.activeDownloads.Store("a", info)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.appProgressLoop(ctx)
+
+	next := func() AppProgress {
+		t.Helper()
+		select {
+		case p := <-events:
+			return p
+		case <-time.After(2 * time.Second):
+			t.Fatal("no app:progress event")
+			return AppProgress{}
+		}
+	}
+	if p := next(); p.Active != 1 || p.Speed != 400 || p.Progress != 25 {
+		t.Errorf("app:progress = %+v", p)
+	}
+
+	// Once the download stops, one last event clears the summary
+	e.activeDownloads.Delete("a")
+	for p := next(); p.Active != 0; p = next() {
+	}
+	select {
+	case p := <-events:
+		t.Errorf("unexpected app:progress while idle: %+v", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSetAppProgressInterval(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	defer e.Shutdown()
+	if got := e.GetAppProgressInterval(); got != DefaultAppProgressInterval {
+		t.Errorf("default interval = %v, want %v", got, DefaultAppProgressInterval)
+	}
+	e.SetAppProgressInterval(-time.Second)
+	if got := e.GetAppProgressInterval(); got != 0 {
+		t.Errorf("negative interval stored as %v, want 0 (off)", got)
+	}
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+const (
+	authRefreshTimeout = 30 * time.Second
+	// authRefreshInterval is how soon the same download may be refreshed
+	// again, so a refresher handing out links that still 403 cannot loop.
+	authRefreshInterval = time.Minute
+)
+
+// RefreshedAuth is what an AuthRefresher hands back for a download whose
+// link expired. Empty fields keep the download's current value.
+type RefreshedAuth struct {
+	URL     string
+	Headers string // JSON object of header name -> value
+	Cookies string
+}
+
+// AuthRefresher fetches fresh credentials for a download the server
+// rejected, so it can resume without the user pasting a new link.
+type AuthRefresher interface {
+	Refresh(ctx context.Context, task storage.DownloadTask) (RefreshedAuth, error)
+}
+
+// HTTPAuthRefresher asks an HTTP endpoint for a fresh link. It POSTs
+// {"id", "url", "filename"} for the expired download and expects
+// {"url", "headers", "cookies"} back, any of which may be omitted.
+type HTTPAuthRefresher struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewHTTPAuthRefresher returns a refresher that calls endpoint, which must
+// be an http or https URL.
+func NewHTTPAuthRefresher(endpoint string) (*HTTPAuthRefresher, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid auth refresh URL %q (want http or https)", endpoint)
+	}
+	return &HTTPAuthRefresher{Endpoint: endpoint, Client: &http.Client{}}, nil
+}
+
+// Refresh implements AuthRefresher. Any non-2xx answer is a failure.
+func (r *HTTPAuthRefresher) Refresh(ctx context.Context, task storage.DownloadTask) (RefreshedAuth, error) {
+	body, err := json.Marshal(map[string]string{
+		"id":       task.ID,
+		"url":      task.URL,
+		"filename": task.Filename,
+	})
+	if err != nil {
+		return RefreshedAuth{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return RefreshedAuth{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tachyon-AuthRefresh/1.0")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return RefreshedAuth{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return RefreshedAuth{}, fmt.Errorf("auth refresh endpoint returned %s", resp.Status)
+	}
+
+	var out struct {
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Cookies string            `json:"cookies"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return RefreshedAuth{}, fmt.Errorf("invalid auth refresh response: %w", err)
+	}
+	auth := RefreshedAuth{URL: out.URL, Cookies: out.Cookies}
+	if len(out.Headers) > 0 {
+		headers, err := json.Marshal(out.Headers)
+		if err != nil {
+			return RefreshedAuth{}, err
+		}
+		auth.Headers = string(headers)
+	}
+	if auth.URL == "" && auth.Headers == "" && auth.Cookies == "" {
+		return RefreshedAuth{}, fmt.Errorf("auth refresh endpoint returned nothing new")
+	}
+	return auth, nil
+}
+
+// SetAuthRefresher sets the strategy used to refresh a download that
+// needs new credentials. nil turns automatic refreshing off, leaving such
+// downloads waiting for the user.
+func (e *TachyonEngine) SetAuthRefresher(r AuthRefresher) {
+	e.authRefreshMu.Lock()
+	e.authRefresher = r
+	e.authRefreshMu.Unlock()
+}
+
+// GetAuthRefresher returns the configured AuthRefresher, or nil.
+func (e *TachyonEngine) GetAuthRefresher() AuthRefresher {
+	e.authRefreshMu.RLock()
+	defer e.authRefreshMu.RUnlock()
+	return e.authRefresher
+}
+
+// SetAuthRefreshURL configures an HTTPAuthRefresher for endpoint. An empty
+// endpoint turns automatic refreshing off.
+func (e *TachyonEngine) SetAuthRefreshURL(endpoint string) error {
+	if endpoint == "" {
+		e.SetAuthRefresher(nil)
+		return nil
+	}
+	r, err := NewHTTPAuthRefresher(endpoint)
+	if err != nil {
+		return err
+	}
+	e.SetAuthRefresher(r)
+	return nil
+}
+
+// GetAuthRefreshURL returns the endpoint of the configured HTTP refresher
+// ("" when refreshing is off or done some other way).
+func (e *TachyonEngine) GetAuthRefreshURL() string {
+	if r, ok := e.GetAuthRefresher().(*HTTPAuthRefresher); ok {
+		return r.Endpoint
+	}
+	return ""
+}
+
+// autoRefreshAuth tries to get task, which just stopped in needs_auth,
+// going again through the configured refresher. If that fails the task
+// stays in needs_auth and download:auth_refresh_failed is emitted.
+func (e *TachyonEngine) autoRefreshAuth(task storage.DownloadTask) {
+	refresher := e.GetAuthRefresher()
+	if refresher == nil {
+		return
+	}
+	now := time.Now()
+	if last, ok := e.authRefreshes.Load(task.ID); ok && now.Sub(last.(time.Time)) < authRefreshInterval {
+		e.logger.Warn("Link expired again right after a refresh - waiting for the user", "id", task.ID)
+		e.authRefreshFailed(task.ID, fmt.Errorf("refreshed link was rejected"))
+		return
+	}
+	e.authRefreshes.Store(task.ID, now)
+
+	parent := e.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, authRefreshTimeout)
+	defer cancel()
+	e.logger.Info("Refreshing expired link", "id", task.ID)
+	auth, err := refresher.Refresh(ctx, task)
+	if err != nil {
+		e.authRefreshFailed(task.ID, err)
+		return
+	}
+	// The user may have paused, refreshed or removed it meanwhile
+	if cur, err := e.storage.GetTask(task.ID); err != nil || cur.Status != StatusNeedsAuth {
+		return
+	}
+	if err := e.RefreshDownloadAuth(task.ID, auth.URL, auth.Headers, auth.Cookies); err != nil {
+		e.authRefreshFailed(task.ID, err)
+	}
+}
+
+func (e *TachyonEngine) authRefreshFailed(id string, err error) {
+	e.logger.Warn("Automatic link refresh failed", "id", id, "error", err)
+	e.emit("download:auth_refresh_failed", map[string]interface{}{
+		"id":    id,
+		"error": err.Error(),
+	})
+}
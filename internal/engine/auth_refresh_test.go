@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// mockRefresher hands out a fixed RefreshedAuth (or error) and counts calls.
+type mockRefresher struct {
+	auth  RefreshedAuth
+	err   error
+	calls atomic.Int32
+}
+
+func (m *mockRefresher) Refresh(ctx context.Context, task storage.DownloadTask) (RefreshedAuth, error) {
+	m.calls.Add(1)
+	return m.auth, m.err
+}
+
+// newExpiringServer serves content at /fresh.bin; /stale.bin answers the
+// probe but rejects every data request with 403.
+func newExpiringServer(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stale.bin" && r.Method != "HEAD" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method == "HEAD" {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		http.ServeContent(w, r, "fresh.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAutoRefreshAuth_ResumesWithNewURL(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	server := newExpiringServer(t, content)
+
+	e, store := newLoopbackEngine(t)
+	refresher := &mockRefresher{auth: RefreshedAuth{URL: server.URL + "/fresh.bin"}}
+	e.SetAuthRefresher(refresher)
+
+	id, err := e.StartDownload(server.URL+"/stale.bin", t.TempDir(), "video.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if task.URL != server.URL+"/fresh.bin" {
+		t.Errorf("URL = %q, want the refreshed link", task.URL)
+	}
+	if n := refresher.calls.Load(); n != 1 {
+		t.Errorf("refresher called %d times, want 1", n)
+	}
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md5.Sum(got) != md5.Sum(content) {
+		t.Error("content mismatch after refresh")
+	}
+}
+
+func TestAutoRefreshAuth_FailureLeavesNeedsAuth(t *testing.T) {
+	content := generateDummyContent(16 * 1024)
+	server := newExpiringServer(t, content)
+
+	e, store := newLoopbackEngine(t)
+	failed := make(chan string, 1)
+	e.eventHook = func(name string, data interface{}) {
+		if name == "download:auth_refresh_failed" {
+			failed <- data.(map[string]interface{})["error"].(string)
+		}
+	}
+
+	// A refresher whose link is rejected too must not loop
+	for _, r := range []*mockRefresher{
+		{err: errors.New("token service down")},
+		{auth: RefreshedAuth{URL: server.URL + "/stale.bin?token=new"}},
+	} {
+		e.SetAuthRefresher(r)
+		id, err := e.StartDownload(server.URL+"/stale.bin", t.TempDir(), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-failed:
+		case <-time.After(10 * time.Second):
+			t.Fatal("no download:auth_refresh_failed event")
+		}
+		if task, _ := store.GetTask(id); task.Status != StatusNeedsAuth {
+			t.Errorf("status = %s, want needs_auth", task.Status)
+		}
+		if n := r.calls.Load(); n != 1 {
+			t.Errorf("refresher called %d times, want 1", n)
+		}
+	}
+}
+
+func TestHTTPAuthRefresher(t *testing.T) {
+	var got map[string]string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if got["id"] == "broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"url":"https://cdn.example.com/a.bin?sig=new","headers":{"Authorization":"Bearer new"}}`))
+	}))
+	defer endpoint.Close()
+
+	if _, err := NewHTTPAuthRefresher("ftp://example.com"); err == nil {
+		t.Error("expected non-HTTP endpoint to be rejected")
+	}
+	r, err := NewHTTPAuthRefresher(endpoint.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := r.Refresh(context.Background(), storage.DownloadTask{ID: "a", URL: "https://cdn.example.com/a.bin?sig=old", Filename: "a.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["url"] != "https://cdn.example.com/a.bin?sig=old" || got["filename"] != "a.bin" {
+		t.Errorf("request body = %v", got)
+	}
+	if auth.URL != "https://cdn.example.com/a.bin?sig=new" || auth.Headers != `{"Authorization":"Bearer new"}` || auth.Cookies != "" {
+		t.Errorf("auth = %+v", auth)
+	}
+
+	if _, err := r.Refresh(context.Background(), storage.DownloadTask{ID: "broken"}); err == nil {
+		t.Error("expected error status to fail the refresh")
+	}
+}
+
+func TestSetAuthRefreshURL(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	defer e.Shutdown()
+	if err := e.SetAuthRefreshURL("not a url"); err == nil {
+		t.Error("expected invalid endpoint to be rejected")
+	}
+	if err := e.SetAuthRefreshURL("https://tokens.example.com/refresh"); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.GetAuthRefreshURL(); got != "https://tokens.example.com/refresh" {
+		t.Errorf("GetAuthRefreshURL = %q", got)
+	}
+	e.SetAuthRefreshURL("")
+	if e.GetAuthRefresher() != nil {
+		t.Error("empty endpoint should turn refreshing off")
+	}
+}
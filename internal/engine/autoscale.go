@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"context"
+	"runtime/metrics"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+const (
+	autoscaleInterval = 5 * time.Second
+
+	// autoscaleHeadroom is the fraction of line capacity below which another
+	// download is started if one is waiting.
+	autoscaleHeadroom = 0.8
+
+	// diskSaturation is the share of an interval during which part files
+	// were being written at which the disk is treated as the bottleneck.
+	diskSaturation = 0.9
+
+	// cpuSaturation is the share of available CPU the process may use before
+	// the autoscaler backs off.
+	cpuSaturation = 0.9
+)
+
+// autoscaleSample is one interval's worth of signals for the autoscaler.
+type autoscaleSample struct {
+	Throughput int64   // bytes/sec summed over active downloads
+	Capacity   int64   // line capacity in bytes/sec, 0 = unknown
+	DiskBusy   float64 // share of the interval with a disk write in progress
+	CPUBusy    float64 // share of available CPU used by the process
+	Running    int     // downloads holding a slot
+	Waiting    int     // downloads queued for a slot
+}
+
+// nextConcurrency returns the download limit that follows cur for sample s.
+// Saturated disk or CPU always lowers the limit; otherwise it rises by one
+// while every slot is busy, downloads are waiting and the line has room.
+func nextConcurrency(cur int, s autoscaleSample) int {
+	if s.DiskBusy >= diskSaturation || s.CPUBusy >= cpuSaturation {
+		return max(cur-1, 1)
+	}
+	if s.Capacity <= 0 || s.Waiting == 0 || s.Running < cur {
+		return cur
+	}
+	if float64(s.Throughput) < float64(s.Capacity)*autoscaleHeadroom {
+		return min(cur+1, MaxConcurrentCap)
+	}
+	return cur
+}
+
+// SetConcurrencyAutoscale lets the engine pick how many downloads run at
+// once from throughput, disk and CPU load. Switching it off restores the
+// limit last given to SetMaxConcurrent.
+func (e *TachyonEngine) SetConcurrencyAutoscale(enabled bool) {
+	e.workerMutex.Lock()
+	defer e.workerMutex.Unlock()
+	if e.autoscale.Swap(enabled) == enabled {
+		return
+	}
+	if !enabled {
+		e.maxConcurrent = e.manualConcurrent
+		e.workerCond.Signal()
+		e.queue.Signal()
+	}
+}
+
+// IsConcurrencyAutoscale reports whether the autoscaler is on.
+func (e *TachyonEngine) IsConcurrencyAutoscale() bool {
+	return e.autoscale.Load()
+}
+
+// SetLineCapacity records the connection's download capacity in bytes/sec,
+// typically from the latest speed test. 0 means unknown, in which case the
+// autoscaler only ever lowers the limit.
+func (e *TachyonEngine) SetLineCapacity(bytesPerSec int64) {
+	e.lineCapacity.Store(max(bytesPerSec, 0))
+}
+
+// GetMaxConcurrent returns the number of downloads currently allowed to run
+// at once, whether set by hand or by the autoscaler.
+func (e *TachyonEngine) GetMaxConcurrent() int {
+	e.workerMutex.Lock()
+	defer e.workerMutex.Unlock()
+	return e.maxConcurrent
+}
+
+// applyAutoscale moves the download limit one step for sample s and
+// returns the new limit. It does nothing while autoscaling is off.
+func (e *TachyonEngine) applyAutoscale(s autoscaleSample) int {
+	e.workerMutex.Lock()
+	defer e.workerMutex.Unlock()
+	if !e.autoscale.Load() {
+		return e.maxConcurrent
+	}
+	next := nextConcurrency(e.maxConcurrent, s)
+	if next != e.maxConcurrent {
+		e.logger.Info("Autoscaled concurrent downloads", "from", e.maxConcurrent, "to", next,
+			"speed", s.Throughput, "capacity", s.Capacity, "disk_busy", s.DiskBusy, "cpu_busy", s.CPUBusy)
+		e.recordEvent(EventConcurrencyScaled, "", "Concurrent downloads changed from %d to %d", e.maxConcurrent, next)
+		e.maxConcurrent = next
+		e.workerCond.Signal()
+		e.queue.Signal()
+	}
+	return next
+}
+
+// autoscaleLoop samples load every autoscaleInterval and adjusts the
+// download limit while autoscaling is on.
+func (e *TachyonEngine) autoscaleLoop(ctx context.Context) {
+	ticker := time.NewTicker(autoscaleInterval)
+	defer ticker.Stop()
+
+	cpu := newCPUSampler()
+	last := time.Now()
+	lastDisk := e.diskBusy.total(last)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		disk := e.diskBusy.total(now)
+		elapsed := now.Sub(last)
+		s := e.autoscaleSignals()
+		s.DiskBusy = float64(disk-lastDisk) / float64(elapsed)
+		s.CPUBusy = cpu.busy()
+		lastDisk, last = disk, now
+
+		if e.autoscale.Load() {
+			e.applyAutoscale(s)
+		}
+	}
+}
+
+// autoscaleSignals fills in the throughput and queue parts of a sample.
+func (e *TachyonEngine) autoscaleSignals() autoscaleSample {
+	s := autoscaleSample{Capacity: e.lineCapacity.Load()}
+	e.activeDownloads.Range(func(_, value interface{}) bool {
+		if info, ok := value.(*activeDownloadInfo); ok {
+			s.Throughput += info.Speed.Load()
+		}
+		return true
+	})
+	e.workerMutex.Lock()
+	s.Running = e.runningDownloads
+	e.workerMutex.Unlock()
+	waiting := []storage.Status{storage.StatusPending}
+	if n, err := e.storage.CountTasksByStatus(waiting); err == nil {
+		s.Waiting = int(n)
+	}
+	return s
+}
+
+// cpuSampler reports the process's share of available CPU between calls,
+// from the Go runtime's CPU accounting.
+type cpuSampler struct {
+	samples   []metrics.Sample
+	lastTotal float64
+	lastIdle  float64
+}
+
+func newCPUSampler() *cpuSampler {
+	c := &cpuSampler{samples: []metrics.Sample{
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+	}}
+	c.busy()
+	return c
+}
+
+func (c *cpuSampler) busy() float64 {
+	metrics.Read(c.samples)
+	if c.samples[0].Value.Kind() != metrics.KindFloat64 || c.samples[1].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	total, idle := c.samples[0].Value.Float64(), c.samples[1].Value.Float64()
+	dTotal, dIdle := total-c.lastTotal, idle-c.lastIdle
+	c.lastTotal, c.lastIdle = total, idle
+	if dTotal <= 0 {
+		return 0
+	}
+	return (dTotal - dIdle) / dTotal
+}
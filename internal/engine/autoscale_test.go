@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestNextConcurrency(t *testing.T) {
+	const capacity = 10 * 1024 * 1024
+	tests := []struct {
+		name string
+		cur  int
+		s    autoscaleSample
+		want int
+	}{
+		{"below capacity with waiting downloads", 3, autoscaleSample{Throughput: capacity / 4, Capacity: capacity, Running: 3, Waiting: 2}, 4},
+		{"line saturated", 3, autoscaleSample{Throughput: capacity, Capacity: capacity, Running: 3, Waiting: 2}, 3},
+		{"nothing waiting", 3, autoscaleSample{Throughput: 0, Capacity: capacity, Running: 3}, 3},
+		{"free slots", 3, autoscaleSample{Throughput: 0, Capacity: capacity, Running: 1, Waiting: 2}, 3},
+		{"capacity unknown", 3, autoscaleSample{Throughput: 0, Running: 3, Waiting: 2}, 3},
+		{"disk saturated", 3, autoscaleSample{Capacity: capacity, DiskBusy: 0.95, Running: 3, Waiting: 2}, 2},
+		{"cpu saturated", 3, autoscaleSample{Capacity: capacity, CPUBusy: 0.95, Running: 3, Waiting: 2}, 2},
+		{"floor", 1, autoscaleSample{DiskBusy: 1}, 1},
+		{"ceiling", MaxConcurrentCap, autoscaleSample{Capacity: capacity, Running: MaxConcurrentCap, Waiting: 5}, MaxConcurrentCap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextConcurrency(tt.cur, tt.s); got != tt.want {
+				t.Errorf("nextConcurrency(%d) = %d, want %d", tt.cur, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyAutoscale_FollowsSignals(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createDownloadsTestDB(t))
+	defer e.Shutdown()
+	e.SetMaxConcurrent(2)
+	e.SetConcurrencyAutoscale(true)
+
+	const capacity = 100 * 1024 * 1024
+	feed := func(s autoscaleSample, want int) {
+		t.Helper()
+		s.Capacity = capacity
+		s.Running = e.GetMaxConcurrent()
+		s.Waiting = 5
+		if got := e.applyAutoscale(s); got != want {
+			t.Fatalf("limit = %d, want %d (sample %+v)", got, want, s)
+		}
+	}
+
+	// Plenty of headroom: ramp up one step per sample.
+	feed(autoscaleSample{Throughput: capacity / 10}, 3)
+	feed(autoscaleSample{Throughput: capacity / 5}, 4)
+	// Line is full: hold.
+	feed(autoscaleSample{Throughput: capacity}, 4)
+	// Disk, then CPU, can't keep up: back off.
+	feed(autoscaleSample{Throughput: capacity / 2, DiskBusy: 0.92}, 3)
+	feed(autoscaleSample{Throughput: capacity / 2, CPUBusy: 0.97}, 2)
+}
+
+func TestConcurrencyAutoscale_ManualOverride(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createDownloadsTestDB(t))
+	defer e.Shutdown()
+	e.SetMaxConcurrent(3)
+
+	grow := autoscaleSample{Capacity: 1 << 30, Running: 3, Waiting: 1}
+	if got := e.applyAutoscale(grow); got != 3 {
+		t.Fatalf("autoscaler changed the limit while off: %d", got)
+	}
+
+	e.SetConcurrencyAutoscale(true)
+	if got := e.applyAutoscale(grow); got != 4 {
+		t.Fatalf("limit = %d, want 4", got)
+	}
+
+	// A manual limit set while autoscaling is remembered, not applied.
+	e.SetMaxConcurrent(2)
+	if got := e.GetMaxConcurrent(); got != 4 {
+		t.Errorf("manual limit applied while autoscaling: %d", got)
+	}
+
+	e.SetConcurrencyAutoscale(false)
+	if got := e.GetMaxConcurrent(); got != 2 {
+		t.Errorf("limit after disabling autoscale = %d, want manual 2", got)
+	}
+}
+
+func TestConcurrencyAutoscale_StartsMoreDownloads(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(2 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 200*time.Millisecond)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	e.allowLoopback = true
+	e.SetMaxConcurrent(1)
+	e.SetConcurrencyAutoscale(true)
+	defer e.Shutdown()
+
+	activeCount := func() int {
+		n := 0
+		e.activeDownloads.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	waitActive := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if activeCount() == want {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("active downloads = %d, want %d", activeCount(), want)
+	}
+
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		if _, err := e.StartDownload(server.URL+"/"+name, tmpDir, name, nil); err != nil {
+			t.Fatalf("StartDownload(%s): %v", name, err)
+		}
+	}
+	waitActive(1)
+
+	// A slow aggregate rate on a fast line lets a second download start.
+	s := e.autoscaleSignals()
+	s.Capacity = 1 << 30
+	if s.Waiting == 0 {
+		t.Fatal("expected queued downloads in the sample")
+	}
+	if got := e.applyAutoscale(s); got != 2 {
+		t.Fatalf("limit = %d, want 2", got)
+	}
+	waitActive(2)
+}
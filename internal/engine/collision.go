@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// What StartDownload does when the target file name is already taken on
+// disk or by another download.
+const (
+	CollisionRename    = "rename"    // save as "name (1).ext"
+	CollisionOverwrite = "overwrite" // replace the existing file once the download verifies
+	CollisionSkip      = "skip"      // keep the existing file and don't download
+	CollisionTimestamp = "timestamp" // save as "name_20060102-150405.ext"
+)
+
+// DefaultCollisionStrategy is used unless configured.
+const DefaultCollisionStrategy = CollisionRename
+
+// CollisionStrategies returns the names of the collision strategies.
+func CollisionStrategies() []string {
+	return []string{CollisionRename, CollisionOverwrite, CollisionSkip, CollisionTimestamp}
+}
+
+func validCollisionStrategy(name string) bool {
+	for _, s := range CollisionStrategies() {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCollisionStrategy sets what new downloads do when their file name is
+// taken. A download's "on_collision" option overrides it.
+func (e *TachyonEngine) SetCollisionStrategy(name string) error {
+	if !validCollisionStrategy(name) {
+		return fmt.Errorf("unknown collision strategy %q", name)
+	}
+	e.collisionMu.Lock()
+	defer e.collisionMu.Unlock()
+	e.collisionStrategy = name
+	return nil
+}
+
+// GetCollisionStrategy returns the default collision strategy.
+func (e *TachyonEngine) GetCollisionStrategy() string {
+	e.collisionMu.RLock()
+	defer e.collisionMu.RUnlock()
+	return e.collisionStrategy
+}
+
+// pathTaken reports whether path exists on disk or is claimed by a
+// download that hasn't finished.
+func pathTaken(path string, reserved map[string]bool) bool {
+	if reserved[path] {
+		return true
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// timestampedPath inserts t before the extension of path.
+func timestampedPath(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_" + t.Format("20060102-150405") + ext
+}
+
+// skipExisting returns the download that owns path instead of starting a
+// new one. A file with no download behind it is recorded as a completed
+// download in set setID (if any) so the caller still gets an ID for it.
+// A download that already owns path keeps its own set.
+func (e *TachyonEngine) skipExisting(urlStr, path, setID string) (string, error) {
+	if task, err := e.storage.GetTaskBySavePath(path); err == nil {
+		e.logger.Info("File exists, skipping download", "path", path, "id", task.ID)
+		return task.ID, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("existing file unavailable: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	task := storage.DownloadTask{
+		ID:         uuid.New().String(),
+		URL:        urlStr,
+		Filename:   filepath.Base(path),
+		SavePath:   path,
+		Status:     storage.StatusCompleted,
+		Progress:   100,
+		TotalSize:  info.Size(),
+		Downloaded: info.Size(),
+		Category:   filesystem.GetCategory(filepath.Base(path)),
+		SetID:      setID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := e.storage.SaveTask(task); err != nil {
+		return "", fmt.Errorf("failed to record existing file: %w", err)
+	}
+	e.logger.Info("File exists, skipping download", "path", path, "id", task.ID)
+	e.emit("download:skipped", map[string]interface{}{
+		"id":       task.ID,
+		"path":     task.SavePath,
+		"filename": task.Filename,
+		"total":    task.TotalSize,
+	})
+	return task.ID, nil
+}
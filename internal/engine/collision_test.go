@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// newCollisionTest serves content and places an older file where the
+// download would land. It returns the URL, download folder and that file.
+func newCollisionTest(t *testing.T, content []byte, hits *atomic.Int32) (*TachyonEngine, *storage.Storage, string, string, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.ServeContent(w, r, "report.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	existing, err := filesystem.GetOrganizedPath(dir, "report.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("old version"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, store := newLoopbackEngine(t)
+	return e, store, server.URL + "/report.bin", dir, existing
+}
+
+func TestCollision_Rename(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	var hits atomic.Int32
+	e, store, url, dir, existing := newCollisionTest(t, content, &hits)
+
+	id, err := e.StartDownload(url, dir, "report.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if want := filepath.Join(filepath.Dir(existing), "report (1).bin"); task.SavePath != want {
+		t.Errorf("SavePath = %s, want %s", task.SavePath, want)
+	}
+	if old, _ := os.ReadFile(existing); string(old) != "old version" {
+		t.Error("rename must leave the existing file alone")
+	}
+}
+
+func TestCollision_Timestamp(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	var hits atomic.Int32
+	e, store, url, dir, existing := newCollisionTest(t, content, &hits)
+
+	id, err := e.StartDownload(url, dir, "report.bin", map[string]string{"on_collision": CollisionTimestamp})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if !regexp.MustCompile(`^report_\d{8}-\d{6}\.bin$`).MatchString(task.Filename) {
+		t.Errorf("Filename = %s, want a timestamped name", task.Filename)
+	}
+	if got, _ := os.ReadFile(task.SavePath); !bytes.Equal(got, content) {
+		t.Error("timestamped download is corrupt")
+	}
+	if old, _ := os.ReadFile(existing); string(old) != "old version" {
+		t.Error("timestamp must leave the existing file alone")
+	}
+}
+
+func TestCollision_Overwrite(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	var hits atomic.Int32
+	e, store, url, dir, existing := newCollisionTest(t, content, &hits)
+	if err := e.SetCollisionStrategy(CollisionOverwrite); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := e.StartDownload(url, dir, "report.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if task.SavePath != existing {
+		t.Errorf("SavePath = %s, want %s", task.SavePath, existing)
+	}
+	if got, _ := os.ReadFile(existing); !bytes.Equal(got, content) {
+		t.Error("existing file was not overwritten")
+	}
+}
+
+func TestCollision_Skip(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	var hits atomic.Int32
+	e, store, url, dir, existing := newCollisionTest(t, content, &hits)
+
+	id, err := e.StartDownload(url, dir, "report.bin", map[string]string{"on_collision": CollisionSkip})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task, err := store.GetTask(id)
+	if err != nil {
+		t.Fatalf("skipped file has no task: %v", err)
+	}
+	if task.Status != storage.StatusCompleted || task.SavePath != existing || task.TotalSize != int64(len("old version")) {
+		t.Errorf("unexpected task for the existing file: %+v", task)
+	}
+
+	// The same file again resolves to the same task.
+	again, err := e.StartDownload(url, dir, "report.bin", map[string]string{"on_collision": CollisionSkip})
+	if err != nil || again != id {
+		t.Errorf("second skip = %q, %v; want %q", again, err, id)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if hits.Load() != 0 {
+		t.Errorf("skip downloaded anyway: %d requests", hits.Load())
+	}
+	if old, _ := os.ReadFile(existing); string(old) != "old version" {
+		t.Error("skip must leave the existing file alone")
+	}
+}
+
+func TestCollision_NoCollisionIgnoresStrategy(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	var hits atomic.Int32
+	e, store, url, dir, _ := newCollisionTest(t, content, &hits)
+
+	id, err := e.StartDownload(url, dir, "fresh.bin", map[string]string{"on_collision": CollisionSkip})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Filename != "fresh.bin" || task.Status != storage.StatusCompleted {
+		t.Errorf("got %s (%s), want fresh.bin downloaded", task.Filename, task.Status)
+	}
+}
+
+func TestCollisionStrategy_Validation(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	if got := e.GetCollisionStrategy(); got != DefaultCollisionStrategy {
+		t.Fatalf("default = %s, want %s", got, DefaultCollisionStrategy)
+	}
+	if err := e.SetCollisionStrategy("shred"); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	if got := e.GetCollisionStrategy(); got != DefaultCollisionStrategy {
+		t.Errorf("rejected strategy changed the setting to %s", got)
+	}
+	if _, err := e.StartDownload("http://example.com/a.bin", t.TempDir(), "", map[string]string{"on_collision": "shred"}); err == nil {
+		t.Error("unknown per-download strategy accepted")
+	}
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"fmt"
+
+	"project-tachyon/internal/storage"
+)
+
+// SetDownloadConnections pins a download to n connections, or hands it back
+// to automatic tuning when n is 0. A running download spawns workers at
+// once, while surplus workers finish their current part before exiting;
+// other downloads use the new count when they next start. n is clamped to
+// the per-download ceiling.
+func (e *TachyonEngine) SetDownloadConnections(id string, n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid connection count %d", n)
+	}
+	if n > 0 {
+		n = e.clampConnections(n)
+	}
+	if _, err := e.storage.GetTask(id); err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	if v, ok := e.activeDownloads.Load(id); ok {
+		info := v.(*activeDownloadInfo)
+		if info.singleStream.Load() {
+			return fmt.Errorf("download uses a single connection: the server does not support ranges")
+		}
+		if info.connCh != nil {
+			// Replace any change the executor has not picked up yet
+			for sent := false; !sent; {
+				select {
+				case info.connCh <- n:
+					sent = true
+				default:
+					select {
+					case <-info.connCh:
+					default:
+					}
+				}
+			}
+		}
+	}
+	e.queue.SetConnections(id, n)
+	if err := e.storage.SaveTaskAtomic(id, func(t *storage.DownloadTask) {
+		t.Connections = n
+	}); err != nil {
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+	e.logger.Info("Download connections set", "id", id, "connections", n)
+	return nil
+}
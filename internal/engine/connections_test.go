@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// waitForInflight polls until cond holds for the number of range requests
+// the server is serving, three samples in a row.
+func waitForInflight(t *testing.T, inflight *atomic.Int32, what string, cond func(n int32) bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for streak := 0; streak < 3; {
+		if time.Now().After(deadline) {
+			t.Fatalf("connections never converged: %s (in flight: %d)", what, inflight.Load())
+		}
+		if cond(inflight.Load()) {
+			streak++
+		} else {
+			streak = 0
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSetDownloadConnections_Live(t *testing.T) {
+	content := generateDummyContent(32 * 1024 * 1024)
+	var inflight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); r.Method == http.MethodGet && rng != "" && rng != "bytes=0-0" {
+			inflight.Add(1)
+			defer inflight.Add(-1)
+			time.Sleep(150 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "live.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	e.allowLoopback = true
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	defer e.Shutdown()
+
+	id, err := e.StartDownload(server.URL+"/live.bin", t.TempDir(), "", map[string]string{"connections": "2"})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	waitForInflight(t, &inflight, "2 pinned", func(n int32) bool { return n == 2 })
+
+	if err := e.SetDownloadConnections(id, 6); err != nil {
+		t.Fatalf("SetDownloadConnections(6): %v", err)
+	}
+	waitForInflight(t, &inflight, "raised to 6", func(n int32) bool { return n == 6 })
+
+	if err := e.SetDownloadConnections(id, 1); err != nil {
+		t.Fatalf("SetDownloadConnections(1): %v", err)
+	}
+	waitForInflight(t, &inflight, "lowered to 1", func(n int32) bool { return n == 1 })
+
+	if task, _ := store.GetTask(id); task.Connections != 1 {
+		t.Errorf("stored connections = %d, want 1", task.Connections)
+	}
+
+	// Finish quickly and check the resized download assembled correctly
+	e.SetDownloadConnections(id, 12)
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded file does not match the served content")
+	}
+}
+
+func TestMaxConnectionsPerDownload_AboveDefault(t *testing.T) {
+	content := generateDummyContent(24 * 1024 * 1024)
+	var inflight, peak atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); r.Method == http.MethodGet && rng != "" && rng != "bytes=0-0" {
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		http.ServeContent(w, r, "wide.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	e.allowLoopback = true
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	e.SetMaxConnectionsPerDownload(40)
+	defer e.Shutdown()
+
+	id, err := e.StartDownload(server.URL+"/wide.bin", t.TempDir(), "", map[string]string{"connections": "40"})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	waitForInflight(t, &inflight, "40 connections", func(n int32) bool { return n == 40 })
+	close(release)
+
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if task.Connections != 40 {
+		t.Errorf("stored connections = %d, want 40", task.Connections)
+	}
+	if p := peak.Load(); p != 40 {
+		t.Errorf("peak connections = %d, want 40", p)
+	}
+}
+
+func TestMaxConnectionsPerHost_FollowsDownloadCeiling(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	defer e.Shutdown()
+
+	e.SetMaxConnectionsPerDownload(40)
+	if got := e.GetMaxConnectionsPerHost(); got != 40 {
+		t.Errorf("host cap = %d, want it to follow the ceiling to 40", got)
+	}
+	e.SetMaxConnectionsPerHost(8)
+	e.SetMaxConnectionsPerDownload(48)
+	if got := e.GetMaxConnectionsPerHost(); got != 8 {
+		t.Errorf("host cap = %d, want the explicit 8 kept", got)
+	}
+}
+
+func TestSetDownloadConnections_NotRunning(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	defer e.Shutdown()
+
+	if err := e.SetDownloadConnections("missing", 4); err == nil {
+		t.Error("expected an error for an unknown download")
+	}
+
+	later := map[string]string{"start_time": time.Now().Add(time.Hour).Format(time.RFC3339)}
+	id, err := e.StartDownload("https://example.com/file.bin", t.TempDir(), "", later)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	if err := e.SetDownloadConnections(id, -1); err == nil {
+		t.Error("expected an error for a negative count")
+	}
+	if err := e.SetDownloadConnections(id, 1000); err != nil {
+		t.Fatalf("SetDownloadConnections: %v", err)
+	}
+	task, _ := e.GetTask(id)
+	if task.Connections != MaxWorkersPerTask {
+		t.Errorf("stored connections = %d, want clamped to %d", task.Connections, MaxWorkersPerTask)
+	}
+	for _, queued := range e.GetQueuedDownloads() {
+		if queued.ID == id && queued.Connections != MaxWorkersPerTask {
+			t.Errorf("queued connections = %d, want %d", queued.Connections, MaxWorkersPerTask)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// maxDebugLogBytes caps the header log kept per download.
+const maxDebugLogBytes = 64 * 1024
+
+// credentialHeaders have their values redacted wherever a request is shown
+// to the user (debug logs, exported commands) and are left out of sidecars,
+// so what Tachyon writes can be shared safely. Use isCredentialHeader, which
+// also recognises custom token headers by name.
+var credentialHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Api-Key":            true,
+	"X-Auth-Token":         true,
+	"X-Access-Token":       true,
+	"X-Csrf-Token":         true,
+	"X-Xsrf-Token":         true,
+	"X-Amz-Security-Token": true,
+}
+
+// credentialHeaderWords mark any other header as a credential by name.
+var credentialHeaderWords = []string{"token", "secret", "password", "api-key", "apikey", "session"}
+
+// isCredentialHeader reports whether the header called name carries
+// credentials.
+func isCredentialHeader(name string) bool {
+	if credentialHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, w := range credentialHeaderWords {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// debugLog records the HTTP exchanges of a download with debugging on: its
+// probe requests and the first part request.
+type debugLog struct {
+	mu         sync.Mutex
+	buf        strings.Builder
+	truncated  bool
+	partLogged bool
+}
+
+type debugLogKey struct{}
+
+func withDebugLog(ctx context.Context, l *debugLog) context.Context {
+	return context.WithValue(ctx, debugLogKey{}, l)
+}
+
+// debugLogFrom returns the debug log carried by ctx, or nil. All debugLog
+// methods are no-ops on nil so call sites need no checks.
+func debugLogFrom(ctx context.Context) *debugLog {
+	l, _ := ctx.Value(debugLogKey{}).(*debugLog)
+	return l
+}
+
+// claimFirstPart reports true once, for the first part request logged.
+func (l *debugLog) claimFirstPart() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.partLogged {
+		return false
+	}
+	l.partLogged = true
+	return true
+}
+
+// recordExchange appends req's headers and resp's status and headers (or
+// err when the request failed) under a label such as "probe".
+func (l *debugLog) recordExchange(label string, req *http.Request, resp *http.Response, err error) {
+	if l == nil {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s %s\n", label, time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, redactURL(req.URL))
+	writeDebugHeaders(&b, "> ", req.Header)
+	if err != nil {
+		fmt.Fprintf(&b, "! %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "< %s %s\n", resp.Proto, resp.Status)
+		writeDebugHeaders(&b, "< ", resp.Header)
+	}
+	b.WriteByte('\n')
+	l.write(b.String())
+}
+
+// redactURL returns u with its password and every query value redacted,
+// keeping the parameter names. Signed links carry their credentials there.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	redacted := *u
+	params := strings.Split(u.RawQuery, "&")
+	for i, p := range params {
+		if name, _, ok := strings.Cut(p, "="); ok {
+			params[i] = name + "=[redacted]"
+		}
+	}
+	redacted.RawQuery = strings.Join(params, "&")
+	return redacted.Redacted()
+}
+
+func writeDebugHeaders(b *strings.Builder, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			if isCredentialHeader(k) {
+				v = "[redacted]"
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+}
+
+func (l *debugLog) write(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.truncated {
+		return
+	}
+	if l.buf.Len()+len(s) > maxDebugLogBytes {
+		l.buf.WriteString(s[:max(maxDebugLogBytes-l.buf.Len(), 0)])
+		l.buf.WriteString("\n[debug log truncated]\n")
+		l.truncated = true
+		return
+	}
+	l.buf.WriteString(s)
+}
+
+func (l *debugLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// SetDownloadDebug turns header logging on or off for a download. It takes
+// effect the next time the download starts; GetDownloadDebugLog then shows
+// the exchanged headers.
+func (e *TachyonEngine) SetDownloadDebug(id string, enabled bool) error {
+	if _, err := e.storage.GetTask(id); err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	if !enabled {
+		e.debugLogs.Delete(id)
+	}
+	return e.storage.SaveTaskAtomic(id, func(t *storage.DownloadTask) {
+		t.Debug = enabled
+	})
+}
+
+// GetDownloadDebugLog returns the headers logged for a download's latest
+// run with debugging on.
+func (e *TachyonEngine) GetDownloadDebugLog(id string) (string, error) {
+	v, ok := e.debugLogs.Load(id)
+	if !ok {
+		return "", fmt.Errorf("no debug log for download %s", id)
+	}
+	return v.(*debugLog).String(), nil
+}
+
+// startDebugLog begins a fresh debug log for task when debugging is on and
+// returns ctx carrying it.
+func (e *TachyonEngine) startDebugLog(ctx context.Context, task *storage.DownloadTask) context.Context {
+	if !task.Debug {
+		return ctx
+	}
+	l := &debugLog{}
+	e.debugLogs.Store(task.ID, l)
+	// Probe for real rather than reusing a cached result with no headers
+	e.probes.Delete(task.URL)
+	return withDebugLog(ctx, l)
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestDownloadDebugLog_CapturesHeadersRedacted(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cdn-Edge", "edge-42")
+		w.Header().Set("Set-Cookie", "cdn_session=server-secret")
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+
+	id, err := engine.StartDownload(server.URL+"/data.bin?X-Amz-Signature=url-secret&expires=123", t.TempDir(), "data.bin", map[string]string{
+		"debug":        "true",
+		"headers_json": `{"Authorization":"Bearer header-secret","Referer":"https://example.com/page"}`,
+		"cookies_json": `[{"name":"session","value":"cookie-secret"}]`,
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+
+	log, err := engine.GetDownloadDebugLog(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"=== probe HEAD", "=== part 0", "> Referer: https://example.com/page", "< X-Cdn-Edge: edge-42", "> Authorization: [redacted]", "> Cookie: [redacted]", "< Set-Cookie: [redacted]", "/data.bin?X-Amz-Signature=[redacted]&expires=[redacted]\n"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("debug log missing %q:\n%s", want, log)
+		}
+	}
+	for _, secret := range []string{"header-secret", "cookie-secret", "server-secret", "url-secret"} {
+		if strings.Contains(log, secret) {
+			t.Errorf("debug log leaks %q", secret)
+		}
+	}
+	if strings.Count(log, "=== part") != 1 {
+		t.Errorf("expected only the first part logged:\n%s", log)
+	}
+}
+
+func TestIsCredentialHeader(t *testing.T) {
+	cases := map[string]bool{
+		"authorization":   true,
+		"Set-Cookie":      true,
+		"X-API-Key":       true,
+		"X-Auth-Token":    true,
+		"X-Upload-Secret": true,
+		"Private-Token":   true,
+		"Referer":         false,
+		"User-Agent":      false,
+		"Accept-Encoding": false,
+		"If-None-Match":   false,
+	}
+	for name, want := range cases {
+		if got := isCredentialHeader(name); got != want {
+			t.Errorf("isCredentialHeader(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDownloadDebugLog_OffByDefault(t *testing.T) {
+	store := createTempDB(t)
+	engine := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	store.SaveTask(storage.DownloadTask{ID: "quiet", Status: storage.StatusPaused})
+
+	if _, err := engine.GetDownloadDebugLog("quiet"); err == nil {
+		t.Error("expected no debug log without debugging on")
+	}
+	if err := engine.SetDownloadDebug("quiet", true); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := store.GetTask("quiet"); !task.Debug {
+		t.Error("expected debug flag persisted")
+	}
+	if err := engine.SetDownloadDebug("missing", true); err == nil {
+		t.Error("expected error for unknown download")
+	}
+}
+
+func TestDebugLog_Capped(t *testing.T) {
+	l := &debugLog{}
+	chunk := strings.Repeat("x", 1000)
+	for i := 0; i < 100; i++ {
+		l.write(chunk)
+	}
+	out := l.String()
+	if len(out) > maxDebugLogBytes+100 {
+		t.Errorf("log grew to %d bytes, cap is %d", len(out), maxDebugLogBytes)
+	}
+	if !strings.HasSuffix(out, "[debug log truncated]\n") {
+		t.Error("expected truncation marker")
+	}
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// diskThrottle caps the rate part files are written to each configured
+// folder, independent of the network speed limit. It keeps slow media such
+// as spinning disks and network shares from being flooded by every worker
+// of every download at once. A nil throttle is unlimited.
+type diskThrottle struct {
+	mu       sync.RWMutex
+	limiters map[string]*rate.Limiter // cleaned folder -> limiter
+}
+
+func newDiskThrottle() *diskThrottle {
+	return &diskThrottle{limiters: make(map[string]*rate.Limiter)}
+}
+
+// set caps writes below dir at bytesPerSec; 0 removes the cap.
+func (d *diskThrottle) set(dir string, bytesPerSec int64) {
+	dir = filepath.Clean(dir)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if bytesPerSec <= 0 {
+		delete(d.limiters, dir)
+		return
+	}
+	if lim, ok := d.limiters[dir]; ok {
+		lim.SetLimit(rate.Limit(bytesPerSec))
+		lim.SetBurst(int(bytesPerSec))
+		return
+	}
+	d.limiters[dir] = rate.NewLimiter(rate.Limit(bytesPerSec), int(bytesPerSec))
+}
+
+// limit returns the cap configured for exactly dir (0 = none).
+func (d *diskThrottle) limit(dir string) int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if lim, ok := d.limiters[filepath.Clean(dir)]; ok {
+		return int64(lim.Limit())
+	}
+	return 0
+}
+
+// limiterFor returns the limiter of the deepest configured folder containing
+// path, or nil if none does.
+func (d *diskThrottle) limiterFor(path string) *rate.Limiter {
+	if d == nil {
+		return nil
+	}
+	path = filepath.Clean(path)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var best *rate.Limiter
+	bestLen := -1
+	for dir, lim := range d.limiters {
+		if path != dir && !strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			continue
+		}
+		if len(dir) > bestLen {
+			best, bestLen = lim, len(dir)
+		}
+	}
+	return best
+}
+
+// wait blocks until n bytes may be written under path. Writes larger than
+// the limiter's burst are admitted in burst-sized steps.
+func (d *diskThrottle) wait(ctx context.Context, path string, n int) error {
+	lim := d.limiterFor(path)
+	if lim == nil {
+		return nil
+	}
+	for n > 0 {
+		step := min(n, lim.Burst())
+		if err := lim.WaitN(ctx, step); err != nil {
+			return err
+		}
+		n -= step
+	}
+	return nil
+}
+
+// SetDiskWriteLimit caps how fast downloads write part files into dir or any
+// folder below it, in bytes/sec. 0 removes the cap. Where several configured
+// folders contain a download, the deepest one applies.
+func (e *TachyonEngine) SetDiskWriteLimit(dir string, bytesPerSec int64) {
+	e.diskThrottle.set(dir, bytesPerSec)
+	e.logger.Info("Disk write limit updated", "dir", dir, "bytes_per_sec", bytesPerSec)
+}
+
+// GetDiskWriteLimit returns the write cap configured for dir (0 = none).
+func (e *TachyonEngine) GetDiskWriteLimit(dir string) int64 {
+	return e.diskThrottle.limit(dir)
+}
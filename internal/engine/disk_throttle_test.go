@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"crypto/md5"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskThrottle_DeepestFolderWins(t *testing.T) {
+	d := newDiskThrottle()
+	root := filepath.Join(string(filepath.Separator), "mnt", "nas")
+	d.set(root, 10<<20)
+	d.set(filepath.Join(root, "slow"), 1<<20)
+
+	if lim := d.limiterFor(filepath.Join(root, "slow", "a.bin.part")); lim == nil || lim.Limit() != 1<<20 {
+		t.Errorf("expected the nested folder's limit, got %v", lim)
+	}
+	if lim := d.limiterFor(filepath.Join(root, "fast", "a.bin")); lim == nil || lim.Limit() != 10<<20 {
+		t.Errorf("expected the parent folder's limit, got %v", lim)
+	}
+	if lim := d.limiterFor(filepath.Join(string(filepath.Separator), "mnt", "nasty", "a.bin")); lim != nil {
+		t.Error("a sibling folder sharing a name prefix must not match")
+	}
+
+	d.set(root, 0)
+	if d.limit(root) != 0 {
+		t.Error("expected limit removed")
+	}
+	if (*diskThrottle)(nil).wait(context.Background(), root, 1<<30) != nil {
+		t.Error("nil throttle should never block")
+	}
+}
+
+func TestDiskThrottle_WaitHonoursRate(t *testing.T) {
+	d := newDiskThrottle()
+	dir := t.TempDir()
+	const limit = 256 * 1024
+	d.set(dir, limit)
+
+	// The first second's worth is the burst; the rest is paced.
+	const total = 3 * limit
+	start := time.Now()
+	for written := 0; written < total; written += 64 * 1024 {
+		if err := d.wait(context.Background(), filepath.Join(dir, "x.part"), 64*1024); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 1800*time.Millisecond {
+		t.Errorf("wrote %d bytes in %v, faster than the %d B/s cap allows", total, elapsed, limit)
+	}
+
+	// Writes larger than the burst are split rather than rejected.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.wait(ctx, dir, 4*limit); err == nil {
+		t.Error("expected oversized write to block until the context expires")
+	}
+}
+
+func TestDiskWriteLimit_CapsDownloadRate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(768 * 1024)
+	server := spawnThrottledRangeServer(t, content, 0)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	e, store := newLoopbackEngine(t)
+
+	const limit = 256 * 1024
+	e.SetDiskWriteLimit(tmpDir, limit)
+	if got := e.GetDiskWriteLimit(tmpDir); got != limit {
+		t.Fatalf("GetDiskWriteLimit = %d, want %d", got, limit)
+	}
+
+	start := time.Now()
+	id, err := e.StartDownload(server.URL+"/throttled.bin", tmpDir, "throttled.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	elapsed := time.Since(start)
+	if task.Status != "completed" {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+
+	// One second of burst, then the remaining 512KB at 256KB/s.
+	if elapsed < 1800*time.Millisecond {
+		t.Errorf("downloaded %d bytes in %v, above the %d B/s write cap", len(content), elapsed, limit)
+	}
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md5.Sum(got) != md5.Sum(content) {
+		t.Error("throttled download is corrupt")
+	}
+}
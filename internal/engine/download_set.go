@@ -0,0 +1,294 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"project-tachyon/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// setProgressInterval is how often set:progress is emitted for sets with
+// running members.
+const setProgressInterval = time.Second
+
+// DownloadSetProgress is the combined state of a download set's members.
+type DownloadSetProgress struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Dir        string         `json:"dir"`
+	CreatedAt  string         `json:"created_at"`
+	Total      int            `json:"total"`     // member downloads
+	Completed  int            `json:"completed"` // members finished
+	Failed     int            `json:"failed"`    // members in error or needing auth
+	Active     int            `json:"active"`    // members transferring now
+	TotalSize  int64          `json:"total_size"`
+	Downloaded int64          `json:"downloaded"`
+	Progress   float64        `json:"progress"` // percent of TotalSize, or of members when sizes are unknown
+	Speed      int64          `json:"speed"`    // bytes/sec summed over active members
+	Status     storage.Status `json:"status"`
+	TaskIDs    []string       `json:"task_ids"`
+}
+
+// StartDownloadSet queues every URL into dir as one download set and
+// returns the set's ID. All URLs are validated first; if queueing any of
+// them fails, the downloads the set added are removed again so no partial
+// set is left behind. A URL skipped because another download already owns
+// its file doesn't join the set.
+func (e *TachyonEngine) StartDownloadSet(name string, urls []string, dir string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("download set needs a name")
+	}
+	if len(urls) == 0 {
+		return "", fmt.Errorf("download set %q has no URLs", name)
+	}
+	for _, u := range urls {
+		validate := ValidateURL
+		if e.allowLoopback {
+			validate = ValidateURLAllowLoopback
+		}
+		if err := validate(u); err != nil {
+			return "", fmt.Errorf("invalid URL %q: %w", u, err)
+		}
+	}
+
+	set := storage.DownloadSet{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Dir:       dir,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := e.storage.SaveDownloadSet(set); err != nil {
+		return "", fmt.Errorf("failed to persist download set: %w", err)
+	}
+
+	ids := make([]string, 0, len(urls))
+	for _, u := range urls {
+		id, err := e.StartDownload(u, dir, "", map[string]string{"set_id": set.ID})
+		if err != nil {
+			if len(ids) > 0 {
+				e.BulkDeleteDownloads(ids, false)
+			}
+			e.storage.DeleteDownloadSet(set.ID)
+			return "", fmt.Errorf("failed to queue %s: %w", u, err)
+		}
+		// A skipped file that another download already owns stays out of
+		// the set, and out of the rollback above
+		if task, err := e.storage.GetTask(id); err == nil && task.SetID == set.ID {
+			ids = append(ids, id)
+		}
+	}
+
+	e.logger.Info("Download set queued", "id", set.ID, "name", name, "count", len(ids))
+	e.emitSetProgress(set.ID)
+	return set.ID, nil
+}
+
+// GetDownloadSets returns the progress of every download set, newest first.
+func (e *TachyonEngine) GetDownloadSets() ([]DownloadSetProgress, error) {
+	sets, err := e.storage.GetDownloadSets()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DownloadSetProgress, 0, len(sets))
+	for _, set := range sets {
+		p, err := e.setProgress(set)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// GetDownloadSetProgress returns the combined progress of one download set.
+func (e *TachyonEngine) GetDownloadSetProgress(id string) (DownloadSetProgress, error) {
+	set, err := e.storage.GetDownloadSet(id)
+	if err != nil {
+		return DownloadSetProgress{}, fmt.Errorf("download set not found: %w", err)
+	}
+	return e.setProgress(set)
+}
+
+// setProgress aggregates the stored members of set, using the live
+// checkpoint and speed of members that are running.
+func (e *TachyonEngine) setProgress(set storage.DownloadSet) (DownloadSetProgress, error) {
+	tasks, err := e.storage.GetTasksBySet(set.ID)
+	if err != nil {
+		return DownloadSetProgress{}, err
+	}
+	p := DownloadSetProgress{
+		ID:        set.ID,
+		Name:      set.Name,
+		Dir:       set.Dir,
+		CreatedAt: set.CreatedAt,
+		Total:     len(tasks),
+		TaskIDs:   make([]string, 0, len(tasks)),
+	}
+	sized := true
+	paused := 0
+	for _, t := range tasks {
+		p.TaskIDs = append(p.TaskIDs, t.ID)
+		downloaded := t.Downloaded
+		if val, ok := e.activeDownloads.Load(t.ID); ok {
+			p.Active++
+			if info, ok := val.(*activeDownloadInfo); ok {
+				p.Speed += info.Speed.Load()
+				if cp := info.checkpoint.Load(); cp != nil {
+					downloaded = cp.Downloaded
+				}
+			}
+		}
+		switch t.Status {
+		case storage.StatusCompleted, storage.StatusPartial:
+			p.Completed++
+			if t.TotalSize > 0 {
+				downloaded = t.TotalSize
+			}
+		case storage.StatusError, storage.StatusNeedsAuth:
+			p.Failed++
+		case storage.StatusPaused, storage.StatusStopped:
+			paused++
+		}
+		if t.TotalSize > 0 {
+			p.TotalSize += t.TotalSize
+		} else {
+			sized = false
+		}
+		p.Downloaded += downloaded
+	}
+
+	switch {
+	case p.Total == 0:
+	case sized && p.TotalSize > 0:
+		p.Progress = float64(p.Downloaded) / float64(p.TotalSize) * 100
+	default:
+		p.Progress = float64(p.Completed) / float64(p.Total) * 100
+	}
+
+	switch {
+	case p.Total > 0 && p.Completed == p.Total:
+		p.Status = storage.StatusCompleted
+	case p.Active > 0:
+		p.Status = storage.StatusDownloading
+	case p.Total > 0 && p.Completed+p.Failed == p.Total:
+		p.Status = storage.StatusError
+	case paused > 0 && p.Completed+p.Failed+paused == p.Total:
+		p.Status = storage.StatusPaused
+	default:
+		p.Status = storage.StatusPending
+	}
+	return p, nil
+}
+
+// PauseDownloadSet pauses every member of a download set.
+func (e *TachyonEngine) PauseDownloadSet(id string) error {
+	tasks, err := e.setMembers(id)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if err := e.PauseDownload(t.ID); err != nil {
+			e.logger.Warn("Failed to pause set member", "set", id, "id", t.ID, "error", err)
+		}
+	}
+	e.emitSetProgress(id)
+	return nil
+}
+
+// ResumeDownloadSet re-queues the paused, stopped and failed members of a
+// download set. Members that are running or finished are left alone.
+func (e *TachyonEngine) ResumeDownloadSet(id string) error {
+	tasks, err := e.setMembers(id)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		switch t.Status {
+		case storage.StatusPaused, storage.StatusStopped, storage.StatusError:
+		default:
+			continue
+		}
+		if err := e.ResumeDownload(t.ID); err != nil {
+			e.logger.Warn("Failed to resume set member", "set", id, "id", t.ID, "error", err)
+		}
+	}
+	e.emitSetProgress(id)
+	return nil
+}
+
+// DeleteDownloadSet removes a download set and all of its members,
+// deleting their files too when deleteFiles is set.
+func (e *TachyonEngine) DeleteDownloadSet(id string, deleteFiles bool) error {
+	tasks, err := e.setMembers(id)
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 0 {
+		ids := make([]string, len(tasks))
+		for i, t := range tasks {
+			ids[i] = t.ID
+		}
+		if err := e.BulkDeleteDownloads(ids, deleteFiles); err != nil {
+			return err
+		}
+	}
+	if err := e.storage.DeleteDownloadSet(id); err != nil {
+		return err
+	}
+	e.emit("set:deleted", map[string]interface{}{"id": id})
+	return nil
+}
+
+// setMembers returns the tasks of an existing download set.
+func (e *TachyonEngine) setMembers(id string) ([]storage.DownloadTask, error) {
+	if _, err := e.storage.GetDownloadSet(id); err != nil {
+		return nil, fmt.Errorf("download set not found: %w", err)
+	}
+	return e.storage.GetTasksBySet(id)
+}
+
+// emitSetProgress sends a set:progress event for the set.
+func (e *TachyonEngine) emitSetProgress(id string) {
+	p, err := e.GetDownloadSetProgress(id)
+	if err != nil {
+		e.logger.Debug("Set progress unavailable", "set", id, "error", err)
+		return
+	}
+	e.emit("set:progress", p)
+}
+
+// setProgressLoop emits set:progress for every set with running members,
+// plus one last event when a set stops running, until ctx is cancelled.
+func (e *TachyonEngine) setProgressLoop(ctx context.Context) {
+	ticker := time.NewTicker(setProgressInterval)
+	defer ticker.Stop()
+	running := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := make(map[string]bool)
+		e.activeDownloads.Range(func(_, value interface{}) bool {
+			if info, ok := value.(*activeDownloadInfo); ok && info.SetID != "" {
+				now[info.SetID] = true
+			}
+			return true
+		})
+		for id := range now {
+			e.emitSetProgress(id)
+		}
+		for id := range running {
+			if !now[id] {
+				e.emitSetProgress(id)
+			}
+		}
+		running = now
+	}
+}
@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+func TestDownloadSetProgress_Aggregates(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+
+	if err := store.SaveDownloadSet(storage.DownloadSet{ID: "album", Name: "Album"}); err != nil {
+		t.Fatal(err)
+	}
+	members := []storage.DownloadTask{
+		{ID: "done", SetID: "album", Status: storage.StatusCompleted, TotalSize: 1000, Downloaded: 1000, QueueOrder: 1},
+		{ID: "half", SetID: "album", Status: storage.StatusPaused, TotalSize: 1000, Downloaded: 500, QueueOrder: 2},
+		{ID: "live", SetID: "album", Status: storage.StatusDownloading, TotalSize: 2000, Downloaded: 100, QueueOrder: 3},
+		{ID: "other", SetID: "", Status: storage.StatusDownloading, TotalSize: 9999},
+	}
+	for _, m := range members {
+		if err := store.SaveTask(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The running member reports fresher numbers than the database holds.
+	info := &activeDownloadInfo{Wait: &sync.WaitGroup{}, SetID: "album"}
+	info.Speed.Store(300)
+	info.checkpoint.Store(&storage.TaskCheckpoint{ID: "live", Downloaded: 500})
+	e.activeDownloads.Store("live", info)
+
+	p, err := e.GetDownloadSetProgress("album")
+	if err != nil {
+		t.Fatalf("GetDownloadSetProgress: %v", err)
+	}
+	if p.Total != 3 || p.Completed != 1 || p.Active != 1 || p.Failed != 0 {
+		t.Errorf("counts = total %d completed %d active %d failed %d, want 3/1/1/0", p.Total, p.Completed, p.Active, p.Failed)
+	}
+	if p.TotalSize != 4000 || p.Downloaded != 2000 {
+		t.Errorf("bytes = %d/%d, want 2000/4000", p.Downloaded, p.TotalSize)
+	}
+	if p.Progress != 50 {
+		t.Errorf("progress = %v, want 50", p.Progress)
+	}
+	if p.Speed != 300 {
+		t.Errorf("speed = %d, want 300", p.Speed)
+	}
+	if p.Status != storage.StatusDownloading {
+		t.Errorf("status = %s, want downloading", p.Status)
+	}
+	if len(p.TaskIDs) != 3 || p.TaskIDs[0] != "done" {
+		t.Errorf("task IDs = %v, want members in queue order", p.TaskIDs)
+	}
+
+	e.activeDownloads.Delete("live")
+	live, _ := store.GetTask("live")
+	setStatus(&live, storage.StatusPaused)
+	store.SaveTask(live)
+	if p, _ := e.GetDownloadSetProgress("album"); p.Status != storage.StatusPaused {
+		t.Errorf("status with every unfinished member paused = %s, want paused", p.Status)
+	}
+}
+
+func TestDownloadSetProgress_UnknownSizesCountMembers(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+
+	store.SaveDownloadSet(storage.DownloadSet{ID: "feed", Name: "Feed"})
+	store.SaveTask(storage.DownloadTask{ID: "a", SetID: "feed", Status: storage.StatusCompleted, TotalSize: 10, Downloaded: 10})
+	store.SaveTask(storage.DownloadTask{ID: "b", SetID: "feed", Status: storage.StatusError})
+	store.SaveTask(storage.DownloadTask{ID: "c", SetID: "feed", Status: storage.StatusCompleted})
+	store.SaveTask(storage.DownloadTask{ID: "d", SetID: "feed", Status: storage.StatusError})
+
+	p, err := e.GetDownloadSetProgress("feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Progress != 50 {
+		t.Errorf("progress = %v, want 50 (two of four members done)", p.Progress)
+	}
+	if p.Failed != 2 || p.Status != storage.StatusError {
+		t.Errorf("failed = %d status = %s, want 2 and error", p.Failed, p.Status)
+	}
+
+	if _, err := e.GetDownloadSetProgress("missing"); err == nil {
+		t.Error("expected an error for an unknown set")
+	}
+}
+
+func TestStartDownloadSet_RejectsBadInput(t *testing.T) {
+	e, store := newLoopbackEngine(t)
+
+	if _, err := e.StartDownloadSet(" ", []string{"http://127.0.0.1/a.bin"}, t.TempDir()); err == nil {
+		t.Error("expected an error for a blank name")
+	}
+	if _, err := e.StartDownloadSet("Empty", nil, t.TempDir()); err == nil {
+		t.Error("expected an error for a set without URLs")
+	}
+	if _, err := e.StartDownloadSet("Mixed", []string{"http://127.0.0.1/a.bin", "ftp://example.com/b.bin"}, t.TempDir()); err == nil {
+		t.Error("expected an error for an unsupported URL")
+	}
+
+	// Nothing from the rejected sets may be left behind.
+	if sets, _ := store.GetDownloadSets(); len(sets) != 0 {
+		t.Errorf("rejected sets were saved: %v", sets)
+	}
+	if tasks, _ := store.GetAllTasks(); len(tasks) != 0 {
+		t.Errorf("rejected sets queued %d downloads", len(tasks))
+	}
+}
+
+func TestDownloadSet_GroupOperations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(4 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 20*time.Millisecond)
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+
+	var mu sync.Mutex
+	var events []DownloadSetProgress
+	e.eventHook = func(name string, data interface{}) {
+		if p, ok := data.(DownloadSetProgress); ok && name == "set:progress" {
+			mu.Lock()
+			events = append(events, p)
+			mu.Unlock()
+		}
+	}
+
+	urls := []string{server.URL + "/one.bin", server.URL + "/two.bin", server.URL + "/three.bin"}
+	id, err := e.StartDownloadSet("Album", urls, t.TempDir())
+	if err != nil {
+		t.Fatalf("StartDownloadSet: %v", err)
+	}
+	p, err := e.GetDownloadSetProgress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 3 || p.Name != "Album" {
+		t.Fatalf("set = %+v, want three members named Album", p)
+	}
+	mu.Lock()
+	if len(events) == 0 || events[0].ID != id {
+		t.Error("expected set:progress when the set was queued")
+	}
+	mu.Unlock()
+
+	// Pause the whole set once it is moving.
+	waitForSet(t, e, id, func(p DownloadSetProgress) bool { return p.Active > 0 })
+	if err := e.PauseDownloadSet(id); err != nil {
+		t.Fatalf("PauseDownloadSet: %v", err)
+	}
+	p = waitForSet(t, e, id, func(p DownloadSetProgress) bool {
+		return p.Active == 0 && p.Status == storage.StatusPaused
+	})
+	if p.Completed == p.Total {
+		t.Fatal("set finished before it could be paused")
+	}
+
+	// Resume it and let every member finish.
+	if err := e.ResumeDownloadSet(id); err != nil {
+		t.Fatalf("ResumeDownloadSet: %v", err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if p, _ = e.GetDownloadSetProgress(id); p.Status == storage.StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("set did not complete: %+v", p)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if p.Progress != 100 || p.Downloaded != int64(3*len(content)) {
+		t.Errorf("completed set reports %v%% (%d bytes)", p.Progress, p.Downloaded)
+	}
+
+	var paths []string
+	for _, taskID := range p.TaskIDs {
+		task, _ := store.GetTask(taskID)
+		if _, err := os.Stat(task.SavePath); err != nil {
+			t.Errorf("member %s missing its file: %v", taskID, err)
+		}
+		paths = append(paths, task.SavePath)
+	}
+
+	if err := e.DeleteDownloadSet(id, true); err != nil {
+		t.Fatalf("DeleteDownloadSet: %v", err)
+	}
+	if _, err := e.GetDownloadSetProgress(id); err == nil {
+		t.Error("set still exists after delete")
+	}
+	for i, taskID := range p.TaskIDs {
+		if _, err := store.GetTask(taskID); err == nil {
+			t.Errorf("member %s still stored after delete", taskID)
+		}
+		if _, err := os.Stat(paths[i]); !os.IsNotExist(err) {
+			t.Errorf("member file %s not deleted", paths[i])
+		}
+	}
+}
+
+// waitForSet polls a set's progress until done reports true.
+func waitForSet(t *testing.T, e *TachyonEngine, id string, done func(DownloadSetProgress) bool) DownloadSetProgress {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		p, err := e.GetDownloadSetProgress(id)
+		if err == nil && done(p) {
+			return p
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for set %s: %+v", id, p)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestStartDownloadSet_SkipsExistingFiles(t *testing.T) {
+	content := generateDummyContent(4 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 20*time.Millisecond)
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	if err := e.SetCollisionStrategy(CollisionSkip); err != nil {
+		t.Fatal(err)
+	}
+
+	// One file belongs to an earlier download, the other to nothing.
+	dir := t.TempDir()
+	owned, _ := filesystem.GetOrganizedPath(dir, "owned.bin")
+	loose, _ := filesystem.GetOrganizedPath(dir, "loose.bin")
+	for _, p := range []string{owned, loose} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("old version"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := storage.DownloadTask{ID: "history", URL: server.URL + "/owned.bin", Filename: "owned.bin", SavePath: owned, Status: storage.StatusCompleted}
+	if err := store.SaveTask(history); err != nil {
+		t.Fatal(err)
+	}
+
+	// A set that fails part way is rolled back without touching the
+	// earlier download.
+	e.SetMaxQueueSize(1)
+	urls := []string{server.URL + "/owned.bin", server.URL + "/one.bin", server.URL + "/two.bin"}
+	if _, err := e.StartDownloadSet("Rolled back", urls, dir); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("StartDownloadSet = %v, want ErrQueueFull", err)
+	}
+	if _, err := store.GetTask(history.ID); err != nil {
+		t.Errorf("rollback deleted the earlier download: %v", err)
+	}
+	tasks, _ := store.GetAllTasks()
+	if len(tasks) != 1 {
+		t.Errorf("rollback left %d downloads, want only the earlier one", len(tasks))
+	}
+	e.SetMaxQueueSize(0)
+
+	urls = []string{server.URL + "/owned.bin", server.URL + "/loose.bin"}
+	id, err := e.StartDownloadSet("Skipped", urls, dir)
+	if err != nil {
+		t.Fatalf("StartDownloadSet: %v", err)
+	}
+	p, err := e.GetDownloadSetProgress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 1 || p.Completed != 1 {
+		t.Fatalf("set = %+v, want the loose file as its one completed member", p)
+	}
+	if member, _ := store.GetTask(p.TaskIDs[0]); member.SavePath != loose {
+		t.Errorf("member saves to %s, want %s", member.SavePath, loose)
+	}
+	if task, _ := store.GetTask(history.ID); task.SetID != "" {
+		t.Errorf("earlier download joined set %q", task.SetID)
+	}
+}
@@ -1,19 +1,30 @@
 package engine
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/integrity"
 	"project-tachyon/internal/storage"
 
 	"github.com/google/uuid"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// ErrQueueFull is returned by StartDownload when max_queue_size is reached.
+var ErrQueueFull = errors.New("download queue is full")
+
+// queuedStatuses are the states that count towards the queue size limit.
+var queuedStatuses = []storage.Status{storage.StatusPending, storage.StatusScheduled, storage.StatusProbing, storage.StatusDownloading, storage.StatusMerging, storage.StatusVerifying}
+
 func parseInt64(s string) (int64, error) {
 	return strconv.ParseInt(s, 10, 64)
 }
@@ -28,6 +39,38 @@ func (e *TachyonEngine) GetTask(id string) (storage.Task, error) {
 	return e.storage.GetTask(id)
 }
 
+// LiveProgress is the in-memory progress of a running download. It runs
+// ahead of the stored task, whose progress is saved every few seconds.
+type LiveProgress struct {
+	Downloaded  int64 // bytes written to the file
+	Transferred int64 // bytes received, including data re-fetched after errors
+	Speed       int64 // smoothed bytes/sec
+	Health      int   // 0-100 (see healthScore)
+}
+
+// GetLiveProgress returns the live progress of download id. It reports false
+// when the download is not transferring, e.g. still probing or finished.
+func (e *TachyonEngine) GetLiveProgress(id string) (LiveProgress, bool) {
+	v, ok := e.activeDownloads.Load(id)
+	if !ok {
+		return LiveProgress{}, false
+	}
+	info, ok := v.(*activeDownloadInfo)
+	if !ok {
+		return LiveProgress{}, false
+	}
+	downloaded := info.downloaded.Load()
+	if downloaded == nil {
+		return LiveProgress{}, false
+	}
+	return LiveProgress{
+		Downloaded:  atomic.LoadInt64(downloaded),
+		Transferred: info.Transferred.Load(),
+		Speed:       info.Speed.Load(),
+		Health:      int(info.Health.Load()),
+	}, true
+}
+
 // GetTaskByURL returns the most recent task matching the given URL
 func (e *TachyonEngine) GetTaskByURL(url string) (storage.Task, error) {
 	return e.storage.GetTaskByURL(url)
@@ -50,7 +93,7 @@ func (e *TachyonEngine) getReservedPaths() map[string]bool {
 	if tasks, err := e.storage.GetAllTasks(); err == nil {
 		for _, t := range tasks {
 			switch t.Status {
-			case "downloading", "probing", "pending", "scheduled", "merging", "verifying":
+			case storage.StatusDownloading, storage.StatusProbing, storage.StatusPending, storage.StatusScheduled, storage.StatusMerging, storage.StatusVerifying:
 				if t.SavePath != "" {
 					reserved[t.SavePath] = true
 				}
@@ -60,6 +103,31 @@ func (e *TachyonEngine) getReservedPaths() map[string]bool {
 	return reserved
 }
 
+// renameQueuedTask gives a download that hasn't written its file yet a new
+// name, re-filing it under the matching category folder when it was
+// auto-organized. The caller saves the task.
+func (e *TachyonEngine) renameQueuedTask(task *storage.DownloadTask, newName string) {
+	if task.ReplacePath != "" {
+		return // the download takes the name of the file it replaces
+	}
+	dir := filepath.Dir(task.SavePath)
+	if task.Category != "" && filepath.Base(dir) == task.Category {
+		// Auto-organized: <dest>/<category>/<name>
+		dir = filepath.Join(filepath.Dir(dir), filesystem.GetCategory(newName))
+	}
+	newPath := filesystem.FindAvailablePathExcluding(fitPath(filepath.Join(dir, newName)), e.getReservedPaths())
+
+	task.SavePath = newPath
+	task.Filename = filepath.Base(newPath)
+	task.Category = filesystem.GetCategory(task.Filename)
+	e.emit("download:path_updated", map[string]interface{}{
+		"id":       task.ID,
+		"path":     task.SavePath,
+		"filename": task.Filename,
+		"category": task.Category,
+	})
+}
+
 // StartDownload initiates a new download
 func (e *TachyonEngine) StartDownload(urlStr string, destPath string, customFilename string, options map[string]string) (string, error) {
 	// Validate URL scheme, length, and host
@@ -73,6 +141,17 @@ func (e *TachyonEngine) StartDownload(urlStr string, destPath string, customFile
 		}
 	}
 
+	e.admitMu.Lock()
+	defer e.admitMu.Unlock()
+	if options["dedupe"] == "true" {
+		if id, ok := e.existingDownload(urlStr, options["dedupe_resume"] == "true"); ok {
+			return id, nil
+		}
+	}
+	if limit := e.GetMaxQueueSize(); limit > 0 && e.QueueSize() >= limit {
+		return "", ErrQueueFull
+	}
+
 	downloadID := uuid.New().String()
 
 	cookies := options["cookies"]
@@ -96,56 +175,148 @@ func (e *TachyonEngine) StartDownload(urlStr string, destPath string, customFile
 		}
 	}
 
+	onCollision := options["on_collision"]
+	if onCollision == "" {
+		onCollision = e.GetCollisionStrategy()
+	} else if !validCollisionStrategy(onCollision) {
+		return "", fmt.Errorf("unknown collision strategy %q", onCollision)
+	}
+
 	organizedPath, _ := filesystem.GetOrganizedPath(destPath, guessedFilename)
+	organizedPath = fitPath(organizedPath)
 	// Collect paths already claimed by queued/active downloads
 	reservedPaths := e.getReservedPaths()
+
+	// Optional replacement of an existing file: download beside it and swap
+	// it in only once the new copy has verified.
+	replacePath := options["replace"]
+	if replacePath == "" && pathTaken(organizedPath, reservedPaths) {
+		switch onCollision {
+		case CollisionSkip:
+			return e.skipExisting(urlStr, organizedPath, options["set_id"])
+		case CollisionOverwrite:
+			// A file another download is still writing can't be replaced
+			if !reservedPaths[organizedPath] {
+				replacePath = organizedPath
+			}
+		case CollisionTimestamp:
+			organizedPath = timestampedPath(organizedPath, time.Now())
+		}
+	}
+
 	// Find available path checking both disk and in-flight downloads
 	finalPath := filesystem.FindAvailablePathExcluding(organizedPath, reservedPaths)
 	category := filesystem.GetCategory(guessedFilename)
 
+	if replacePath != "" {
+		tmpPath, err := replacementPath(replacePath, reservedPaths)
+		if err != nil {
+			return "", err
+		}
+		replacePath = filepath.Clean(replacePath)
+		finalPath = tmpPath
+		category = filesystem.GetCategory(filepath.Base(replacePath))
+	}
+
+	expectedHash := strings.TrimSpace(options["expected_hash"])
+	hashAlgorithm := options["hash_algorithm"]
+	if expectedHash != "" && hashAlgorithm == "" {
+		prefixAlgo, digest := integrity.NormalizeHash(expectedHash)
+		hashAlgorithm = prefixAlgo
+		if hashAlgorithm == "" {
+			hashAlgorithm = integrity.DetectAlgorithm(digest)
+		}
+	}
+
 	// Handle Scheduled Start
 	var startTime string
-	initialStatus := "pending"
+	initialStatus := storage.StatusPending
 
 	if st, ok := options["start_time"]; ok && st != "" {
 		if _, err := time.Parse(time.RFC3339, st); err == nil {
 			startTime = st
-			initialStatus = "scheduled"
+			initialStatus = storage.StatusScheduled
 		} else {
 			e.logger.Warn("Invalid start_time format", "time", st)
 		}
 	}
 
-	// Parse size hint from extension (e.g. YouTube contentLength)
+	// Parse size hint from extension (e.g. YouTube contentLength), or take
+	// it from a recent probe so size-ordered dispatch knows it
 	var sizeHint int64
 	if sh, ok := options["size_hint"]; ok && sh != "" {
 		if v, err := parseInt64(sh); err == nil && v > 0 {
 			sizeHint = v
 		}
 	}
+	if cached := e.probes.Get(urlStr); sizeHint == 0 && cached != nil && cached.Size > 0 {
+		sizeHint = cached.Size
+	}
+
+	// Optional pinned connection count (disables auto-tuning for this task)
+	var connections int
+	if c, ok := options["connections"]; ok && c != "" {
+		if v, err := strconv.Atoi(c); err == nil && v > 0 {
+			connections = e.clampConnections(v)
+		} else {
+			e.logger.Warn("Invalid connections option", "connections", c)
+		}
+	}
+
+	// Optional byte cap: keep only the head of the file (previews, samples)
+	var maxBytes int64
+	if mb, ok := options["max_bytes"]; ok && mb != "" {
+		v, err := parseInt64(mb)
+		if err != nil || v <= 0 {
+			return "", fmt.Errorf("invalid max_bytes %q", mb)
+		}
+		if replacePath != "" {
+			return "", fmt.Errorf("max_bytes cannot be combined with replace")
+		}
+		maxBytes = v
+	}
+
+	if c := options["cookies_json"]; c != "" {
+		if err := checkCookies(c); err != nil {
+			return "", fmt.Errorf("invalid cookies_json: %w", err)
+		}
+	}
 
 	task := storage.DownloadTask{
-		ID:         downloadID,
-		URL:        urlStr,
-		Filename:   filepath.Base(finalPath),
-		SavePath:   finalPath,
-		Status:     initialStatus,
-		Category:   category,
-		TotalSize:  sizeHint,
-		QueueOrder: e.queue.GetNextOrder(),
-		CreatedAt:  time.Now().Format(time.RFC3339),
-		UpdatedAt:  time.Now().Format(time.RFC3339),
-		Headers:    options["headers_json"],
-		Cookies:    options["cookies_json"],
-		StartTime:  startTime,
+		ID:          downloadID,
+		URL:         urlStr,
+		Filename:    filepath.Base(finalPath),
+		SavePath:    finalPath,
+		Status:      initialStatus,
+		Category:    category,
+		TotalSize:   sizeHint,
+		QueueOrder:  e.queue.GetNextOrder(),
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		UpdatedAt:   time.Now().Format(time.RFC3339),
+		Headers:     options["headers_json"],
+		Cookies:     options["cookies_json"],
+		StartTime:   startTime,
+		Connections: connections,
+		Priority:    e.GetDefaultPriority(),
+
+		ExpectedHash:  expectedHash,
+		HashAlgorithm: hashAlgorithm,
+		ReplacePath:   replacePath,
+		ReplaceBackup: replacePath != "" && options["replace_backup"] == "true",
+		Debug:         options["debug"] == "true",
+		SetID:         options["set_id"],
+		MaxBytes:      maxBytes,
 	}
 
-	if err := e.storage.SaveTask(task); err != nil {
+	if err := e.saveNewTask(task); err != nil {
 		e.logger.Error("Failed to save initial task", "error", err)
 		return "", fmt.Errorf("failed to persist download: %w", err)
 	}
 
 	e.queue.Push(&task)
+	if startTime != "" {
+		e.armSchedule(startTime)
+	}
 
 	if e.ctx != nil {
 		runtime.EventsEmit(e.ctx, "download:progress", map[string]interface{}{
@@ -163,15 +334,45 @@ func (e *TachyonEngine) StartDownload(urlStr string, destPath string, customFile
 	return downloadID, nil
 }
 
+// existingDownload returns the ID of an unfinished task for urlStr, so a
+// URL added twice reuses the first download. Paused, stopped and failed
+// tasks are re-queued when resume is set.
+func (e *TachyonEngine) existingDownload(urlStr string, resume bool) (string, bool) {
+	task, err := e.GetTaskByURL(urlStr)
+	if err != nil {
+		return "", false
+	}
+	switch task.Status {
+	case storage.StatusPending, storage.StatusScheduled, storage.StatusProbing,
+		storage.StatusDownloading, storage.StatusMerging, storage.StatusVerifying:
+	case storage.StatusPaused, storage.StatusStopped, storage.StatusError:
+		if resume {
+			if err := e.ResumeDownload(task.ID); err != nil {
+				e.logger.Warn("Could not resume existing download", "id", task.ID, "error", err)
+			}
+		}
+	default:
+		// Completed downloads and expired links get a fresh task
+		return "", false
+	}
+	e.logger.Info("Reusing existing download for URL", "id", task.ID, "status", task.Status)
+	return task.ID, true
+}
+
 // PauseDownload cancels an active download
 func (e *TachyonEngine) PauseDownload(id string) error {
+	e.emergencyPaused.Delete(id)
 	val, ok := e.activeDownloads.Load(id)
 	if !ok {
 		// Not active, update DB if pending
 		task, err := e.storage.GetTask(id)
-		if err == nil && (task.Status == "pending" || task.Status == "downloading") {
-			task.Status = "paused"
-			e.storage.SaveTask(task)
+		if err == nil && (task.Status == storage.StatusPending || task.Status == storage.StatusDownloading) {
+			if err := setStatus(&task, storage.StatusPaused); err != nil {
+				return err
+			}
+			if err := e.storage.SaveTask(task); err != nil {
+				return fmt.Errorf("failed to save task: %w", err)
+			}
 			if e.ctx != nil {
 				runtime.EventsEmit(e.ctx, "download:paused", map[string]interface{}{
 					"id":         id,
@@ -208,7 +409,7 @@ func (e *TachyonEngine) ResumeDownload(id string) error {
 	}
 
 	// Only resume if it's in a resumable state
-	resumableStates := map[string]bool{"paused": true, "stopped": true, "error": true, "scheduled": true}
+	resumableStates := map[storage.Status]bool{storage.StatusPaused: true, storage.StatusStopped: true, storage.StatusError: true, storage.StatusScheduled: true}
 	if !resumableStates[task.Status] {
 		return fmt.Errorf("cannot resume download in status: %s", task.Status)
 	}
@@ -219,7 +420,7 @@ func (e *TachyonEngine) ResumeDownload(id string) error {
 		if _, err := os.Stat(task.SavePath); err == nil {
 			finalExists = true
 		}
-		tempDir := filepath.Join(filepath.Dir(task.SavePath), ".tachyon_parts")
+		tempDir := e.partsDirForTask(task.ID, task.SavePath)
 		tempExists := false
 		if _, err := os.Stat(tempDir); err == nil {
 			tempExists = true
@@ -232,8 +433,13 @@ func (e *TachyonEngine) ResumeDownload(id string) error {
 		}
 	}
 
+	e.forgetAutoPaused(id)
+	e.emergencyPaused.Delete(id)
+
 	// Update status to pending and re-queue
-	task.Status = "pending"
+	if err := setStatus(&task, storage.StatusPending); err != nil {
+		return err
+	}
 	task.StartTime = "" // Clear schedule time so it starts immediately
 	task.UpdatedAt = time.Now().Format(time.RFC3339)
 	if err := e.storage.SaveTask(task); err != nil {
@@ -271,8 +477,11 @@ func (e *TachyonEngine) StopDownload(id string) error {
 		return err
 	}
 
-	task.Status = "stopped"
+	if err := setStatus(&task, storage.StatusStopped); err != nil {
+		return err
+	}
 	e.storage.SaveTask(task)
+	e.forgetVerifyRetries(id)
 
 	// Emit event
 	if e.ctx != nil {
@@ -286,6 +495,31 @@ func (e *TachyonEngine) StopDownload(id string) error {
 
 // PauseAllDownloads pauses all active or pending downloads
 func (e *TachyonEngine) PauseAllDownloads() {
+	// Remember what pause-all stopped so resume-all leaves downloads the
+	// user had already paused or stopped alone.
+	e.rememberAutoPaused(e.pauseAll())
+
+	if e.ctx != nil {
+		runtime.EventsEmit(e.ctx, "download:paused_all", nil)
+	}
+}
+
+// SuspendDownloads pauses every active or pending download like
+// PauseAllDownloads, but leaves the pause-all set alone and returns the
+// IDs it paused so the caller can resume exactly those later.
+func (e *TachyonEngine) SuspendDownloads() []string {
+	return e.pauseAll()
+}
+
+// HoldDispatch stops the queue from starting downloads while hold is set;
+// downloads added meanwhile wait as pending. Running ones are left alone.
+func (e *TachyonEngine) HoldDispatch(hold bool) {
+	e.dispatchHold.Store(hold)
+	e.queue.Broadcast()
+}
+
+// pauseAll pauses every active or pending download and returns their IDs.
+func (e *TachyonEngine) pauseAll() []string {
 	active := make([]string, 0)
 	e.activeDownloads.Range(func(key, value interface{}) bool {
 		active = append(active, key.(string))
@@ -300,11 +534,16 @@ func (e *TachyonEngine) PauseAllDownloads() {
 	tasks, _ := e.storage.GetAllTasks()
 	var toSave []storage.DownloadTask
 	for _, task := range tasks {
-		if task.Status == "pending" {
-			task.Status = "paused"
+		if task.Status == storage.StatusPending {
+			task.Status = storage.StatusPaused
 			toSave = append(toSave, task)
 		}
 	}
+
+	paused := append([]string{}, active...)
+	for _, task := range toSave {
+		paused = append(paused, task.ID)
+	}
 	if len(toSave) > 0 {
 		e.storage.SaveTasks(toSave)
 		for _, task := range toSave {
@@ -315,13 +554,12 @@ func (e *TachyonEngine) PauseAllDownloads() {
 			}
 		}
 	}
-
-	if e.ctx != nil {
-		runtime.EventsEmit(e.ctx, "download:paused_all", nil)
-	}
+	return paused
 }
 
-// ResumeAllDownloads resumes all paused downloads
+// ResumeAllDownloads resumes the downloads stopped by the last
+// PauseAllDownloads. Without a pending pause-all it resumes every paused
+// download.
 func (e *TachyonEngine) ResumeAllDownloads() {
 	tasks, err := e.storage.GetAllTasks()
 	if err != nil {
@@ -329,10 +567,15 @@ func (e *TachyonEngine) ResumeAllDownloads() {
 		return
 	}
 
+	autoPaused := e.takeAutoPaused()
 	for _, task := range tasks {
-		if task.Status == "paused" {
-			e.ResumeDownload(task.ID)
+		if task.Status != storage.StatusPaused {
+			continue
+		}
+		if autoPaused != nil && !autoPaused[task.ID] {
+			continue
 		}
+		e.ResumeDownload(task.ID)
 	}
 
 	if e.ctx != nil {
@@ -340,6 +583,87 @@ func (e *TachyonEngine) ResumeAllDownloads() {
 	}
 }
 
+// autoPausedKey holds the IDs paused by PauseAllDownloads as a JSON array.
+// It is persisted so a resume-all after a restart still knows which downloads
+// to bring back. An empty value means no pause-all is outstanding.
+const autoPausedKey = "pause_all_ids"
+
+func (e *TachyonEngine) loadAutoPaused() ([]string, bool) {
+	raw, err := e.storage.GetString(autoPausedKey)
+	if err != nil || raw == "" {
+		return nil, false
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(raw), &ids); err != nil {
+		e.logger.Warn("Discarding malformed pause-all IDs", "error", err)
+		return nil, false
+	}
+	return ids, true
+}
+
+func (e *TachyonEngine) saveAutoPaused(ids []string) {
+	if ids == nil {
+		ids = []string{}
+	}
+	data, _ := json.Marshal(ids)
+	if err := e.storage.SetString(autoPausedKey, string(data)); err != nil {
+		e.logger.Error("Failed to save pause-all IDs", "error", err)
+	}
+}
+
+// rememberAutoPaused adds ids to the pause-all set.
+func (e *TachyonEngine) rememberAutoPaused(ids []string) {
+	e.autoPausedMu.Lock()
+	defer e.autoPausedMu.Unlock()
+	existing, _ := e.loadAutoPaused()
+	seen := make(map[string]bool)
+	merged := []string{}
+	for _, id := range append(existing, ids...) {
+		if !seen[id] {
+			seen[id] = true
+			merged = append(merged, id)
+		}
+	}
+	e.saveAutoPaused(merged)
+}
+
+// takeAutoPaused returns and clears the pause-all set, or nil if no
+// pause-all is outstanding.
+func (e *TachyonEngine) takeAutoPaused() map[string]bool {
+	e.autoPausedMu.Lock()
+	defer e.autoPausedMu.Unlock()
+	ids, ok := e.loadAutoPaused()
+	if !ok {
+		return nil
+	}
+	_ = e.storage.SetString(autoPausedKey, "")
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// forgetAutoPaused drops id from the pause-all set once the user handles
+// that download individually.
+func (e *TachyonEngine) forgetAutoPaused(id string) {
+	e.autoPausedMu.Lock()
+	defer e.autoPausedMu.Unlock()
+	ids, ok := e.loadAutoPaused()
+	if !ok {
+		return
+	}
+	kept := make([]string, 0, len(ids))
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) != len(ids) {
+		e.saveAutoPaused(kept)
+	}
+}
+
 // UpdateScheduledTime updates the start_time for all queued "scheduled" tasks.
 // Called when the user changes the global scheduler time in the UI.
 func (e *TachyonEngine) UpdateScheduledTime(newStartTime string) error {
@@ -353,7 +677,7 @@ func (e *TachyonEngine) UpdateScheduledTime(newStartTime string) error {
 	}
 
 	for _, task := range tasks {
-		if task.Status != "scheduled" {
+		if task.Status != storage.StatusScheduled {
 			continue
 		}
 		task.StartTime = newStartTime
@@ -374,6 +698,169 @@ func (e *TachyonEngine) UpdateScheduledTime(newStartTime string) error {
 
 	// Wake the queue worker so it can re-evaluate with the new times
 	e.queue.Broadcast()
+	e.armSchedule(newStartTime)
+	return nil
+}
+
+// armSchedule wakes the queue worker when startTime arrives so a scheduled
+// download fires on time rather than at the worker's next periodic re-check.
+// A start time already in the past wakes it immediately.
+func (e *TachyonEngine) armSchedule(startTime string) {
+	at, err := time.Parse(time.RFC3339, startTime)
+	if err != nil {
+		return
+	}
+	delay := time.Until(at)
+	if delay <= 0 {
+		e.queue.Broadcast()
+		return
+	}
+	time.AfterFunc(delay, e.queue.Broadcast)
+}
+
+// RelocateAndResume moves a paused, stopped or failed download to a new
+// directory and resumes it there. Partial part files are moved along; if
+// one can't be, the others are moved back and the download is left where
+// it was. Parts that can't be found (e.g. on an unplugged drive) mean a
+// restart from scratch at the new location. The new directory must be
+// writable and have room for the whole file.
+func (e *TachyonEngine) RelocateAndResume(id, newDir string) error {
+	if _, active := e.activeDownloads.Load(id); active {
+		return fmt.Errorf("download %s is active, pause it first", id)
+	}
+
+	task, err := e.storage.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	relocatable := map[storage.Status]bool{storage.StatusPaused: true, storage.StatusStopped: true, storage.StatusError: true, StatusNeedsAuth: true}
+	if !relocatable[task.Status] {
+		return fmt.Errorf("cannot relocate download in status: %s", task.Status)
+	}
+
+	if newDir == "" {
+		return fmt.Errorf("new location is empty")
+	}
+	newDir, err = filepath.Abs(newDir)
+	if err != nil {
+		return fmt.Errorf("invalid location: %w", err)
+	}
+
+	filename := task.Filename
+	if filename == "" && task.SavePath != "" {
+		filename = filepath.Base(task.SavePath)
+	}
+	organizedPath, _ := filesystem.GetOrganizedPath(newDir, filename)
+	newPath := filesystem.FindAvailablePathExcluding(organizedPath, e.getReservedPaths())
+
+	if err := checkWritable(filepath.Dir(newPath)); err != nil {
+		return fmt.Errorf("location is not writable: %w", err)
+	}
+	if task.TotalSize > 0 && e.diskSpaceCheck != nil {
+		if err := e.diskSpaceCheck(newPath, task.TotalSize); err != nil {
+			return fmt.Errorf("not enough space at new location: %w", err)
+		}
+	}
+
+	// Carry over finished parts. If they can't all be moved the download
+	// stays where it was, unless moving them back failed too; then it
+	// starts over at the new location. Parts already under the temp
+	// download dir stay where they are.
+	fromDir := e.partsDirForTask(task.ID, task.SavePath)
+	toDir := e.partsDirForTask(task.ID, newPath)
+	if task.SavePath != "" && fromDir != toDir {
+		if err := movePartFiles(fromDir, toDir, task.ID); err != nil {
+			if !errors.Is(err, errPartsSplit) {
+				return fmt.Errorf("could not move partial data: %w", err)
+			}
+			e.logger.Warn("Could not move partial data, restarting at new location", "id", id, "error", err)
+			cleanupPartFiles(toDir, task.ID)
+			cleanupPartFiles(fromDir, task.ID)
+			task.Downloaded = 0
+			task.Progress = 0
+			task.MetaJSON = ""
+		}
+	}
+
+	e.logger.Info("Relocating download", "id", id, "from", task.SavePath, "to", newPath)
+	task.SavePath = newPath
+	task.Filename = filepath.Base(newPath)
+	if err := e.storage.SaveTask(task); err != nil {
+		return err
+	}
+
+	e.emit("download:relocated", map[string]interface{}{
+		"id":   id,
+		"path": newPath,
+	})
+	return e.ResumeDownload(id)
+}
+
+// ValidateLocation checks that dir can be used as a download location and
+// returns its absolute path. A missing directory is created; an existing
+// path must be a directory that a file can be written to.
+func ValidateLocation(dir string) (string, error) {
+	if strings.TrimSpace(dir) == "" {
+		return "", fmt.Errorf("no folder given")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid folder %q: %w", dir, err)
+	}
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("%s is a file, not a folder", abs)
+	}
+	if err := checkWritable(abs); err != nil {
+		return "", fmt.Errorf("cannot save downloads to %s: %w", abs, err)
+	}
+	return abs, nil
+}
+
+// checkWritable creates dir if needed and verifies a file can be written there.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tachyon_write_test_*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// errPartsSplit is returned by movePartFiles when a failed move could not
+// be rolled back, leaving a task's parts split between both dirs.
+var errPartsSplit = errors.New("part files left in both locations")
+
+// movePartFiles moves all part files of a task from one temp dir to another.
+// If one can't be moved, those already moved are moved back, so the parts
+// stay together in fromDir.
+func movePartFiles(fromDir, toDir, taskID string) error {
+	matches, _ := filepath.Glob(filepath.Join(fromDir, taskID+".part.*"))
+	if len(matches) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(toDir, 0755); err != nil {
+		return err
+	}
+	var moved []string
+	for _, m := range matches {
+		name := filepath.Base(m)
+		if err := filesystem.MoveFile(m, filepath.Join(toDir, name)); err != nil {
+			for _, back := range moved {
+				if rerr := filesystem.MoveFile(filepath.Join(toDir, back), filepath.Join(fromDir, back)); rerr != nil {
+					return fmt.Errorf("%w: %v (moving %s back: %v)", errPartsSplit, err, back, rerr)
+				}
+			}
+			os.Remove(toDir)
+			return err
+		}
+		moved = append(moved, name)
+	}
+	// Drop the old temp dir if this task was its last user
+	os.Remove(fromDir)
 	return nil
 }
 
@@ -386,13 +873,17 @@ func (e *TachyonEngine) UpdateDownloadURL(taskID, newURL string) error {
 	}
 
 	// Only allow URL update for tasks in needs_auth status
-	if task.Status != StatusNeedsAuth && task.Status != "paused" && task.Status != "error" {
+	if task.Status != StatusNeedsAuth && task.Status != storage.StatusPaused && task.Status != storage.StatusError {
 		return fmt.Errorf("task is not in a state that allows URL refresh (status: %s)", task.Status)
 	}
 
 	oldURL := task.URL
+	e.forgetProbe(&task)
 	task.URL = newURL
-	task.Status = "paused" // Reset to paused so it can be resumed
+	// Reset to paused so it can be resumed
+	if err := setStatus(&task, storage.StatusPaused); err != nil {
+		return err
+	}
 
 	if err := e.storage.SaveTask(task); err != nil {
 		return fmt.Errorf("failed to save task: %w", err)
@@ -410,6 +901,77 @@ func (e *TachyonEngine) UpdateDownloadURL(taskID, newURL string) error {
 	return nil
 }
 
+// RefreshDownloadAuth replaces the URL, headers and cookies of a download that
+// lost access (typically HTTP 403) and resumes it. Empty arguments keep the
+// stored value. The new material is probed first so a still-broken link is
+// rejected without touching the task.
+func (e *TachyonEngine) RefreshDownloadAuth(taskID, newURL, headersJSON, cookiesJSON string) error {
+	task, err := e.storage.GetTask(taskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	if task.Status != StatusNeedsAuth && task.Status != storage.StatusPaused && task.Status != storage.StatusError {
+		return fmt.Errorf("task is not in a state that allows auth refresh (status: %s)", task.Status)
+	}
+
+	if newURL == "" {
+		newURL = task.URL
+	}
+	if e.allowLoopback {
+		err = ValidateURLAllowLoopback(newURL)
+	} else {
+		err = ValidateURL(newURL)
+	}
+	if err != nil {
+		return err
+	}
+
+	if headersJSON == "" {
+		headersJSON = task.Headers
+	} else {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+			return fmt.Errorf("invalid headers JSON: %w", err)
+		}
+	}
+	if cookiesJSON == "" {
+		cookiesJSON = task.Cookies
+	} else if err := checkCookies(cookiesJSON); err != nil {
+		return fmt.Errorf("invalid cookies: %w", err)
+	}
+
+	// Always hit the network: a cached probe of the same URL says nothing
+	// about whether the new credentials work.
+	e.probes.Delete(newURL)
+	probe, err := e.ProbeURL(newURL, headersJSON, cookiesJSON)
+	if err != nil {
+		return fmt.Errorf("refreshed link is not usable: %w", err)
+	}
+	if task.TotalSize > 0 && probe.Size > 0 && probe.Size != task.TotalSize {
+		return fmt.Errorf("refreshed link points to a different file (size %d, expected %d)", probe.Size, task.TotalSize)
+	}
+
+	e.forgetProbe(&task)
+	task.URL = newURL
+	task.Headers = headersJSON
+	task.Cookies = cookiesJSON
+	if err := setStatus(&task, storage.StatusPaused); err != nil {
+		return err
+	}
+	if err := e.storage.SaveTask(task); err != nil {
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+
+	e.logger.Info("Download auth refreshed", "id", taskID, "url", newURL)
+	e.emit("download:auth_refreshed", map[string]interface{}{
+		"id":      taskID,
+		"new_url": newURL,
+	})
+
+	return e.ResumeDownload(taskID)
+}
+
 // DeleteDownload removes the task and optionally the file
 func (e *TachyonEngine) DeleteDownload(id string, deleteFile bool) error {
 	e.PauseDownload(id)
@@ -421,11 +983,12 @@ func (e *TachyonEngine) DeleteDownload(id string, deleteFile bool) error {
 
 	var fileDeleteErr error
 	if deleteFile && task.SavePath != "" {
-		// Remove file, ignore not found error
-		if err := os.Remove(task.SavePath); err != nil && !os.IsNotExist(err) {
+		// Remove file, waiting briefly for scanners/players to let go
+		if err := filesystem.RemoveFile(task.SavePath); err != nil {
 			e.logger.Warn("Failed to delete file", "path", task.SavePath, "error", err)
 			fileDeleteErr = err
 		}
+		e.removeSidecar(task.SavePath)
 	}
 
 	// Always delete from storage even if file delete failed
@@ -435,6 +998,9 @@ func (e *TachyonEngine) DeleteDownload(id string, deleteFile bool) error {
 
 	// Also remove from queue if present
 	e.queue.Remove(id)
+	e.files.remove(id)
+	e.debugLogs.Delete(id)
+	e.forgetVerifyRetries(id)
 
 	// Emit deleted event for instant UI feedback
 	if e.ctx != nil {
@@ -444,6 +1010,9 @@ func (e *TachyonEngine) DeleteDownload(id string, deleteFile bool) error {
 	}
 
 	if fileDeleteErr != nil {
+		if filesystem.IsFileLocked(fileDeleteErr) {
+			return fmt.Errorf("Record deleted but file could not be removed: %w", fileDeleteErr)
+		}
 		return fmt.Errorf("Record deleted but file could not be removed (locked or in use)")
 	}
 	return nil
@@ -464,9 +1033,10 @@ func (e *TachyonEngine) BulkDeleteDownloads(ids []string, deleteFile bool) error
 				continue
 			}
 			if task.SavePath != "" {
-				if err := os.Remove(task.SavePath); err != nil && !os.IsNotExist(err) {
+				if err := filesystem.RemoveFile(task.SavePath); err != nil {
 					e.logger.Warn("Failed to delete file", "path", task.SavePath, "error", err)
 				}
+				e.removeSidecar(task.SavePath)
 			}
 		}
 	}
@@ -479,6 +1049,8 @@ func (e *TachyonEngine) BulkDeleteDownloads(ids []string, deleteFile bool) error
 	// Remove from queue
 	for _, id := range ids {
 		e.queue.Remove(id)
+		e.debugLogs.Delete(id)
+		e.forgetVerifyRetries(id)
 	}
 
 	// Emit a single bulk event
@@ -497,7 +1069,7 @@ func (e *TachyonEngine) CheckHistory(urlStr string) (bool, error) {
 	if err != nil {
 		return false, nil
 	}
-	if task.Status == "completed" {
+	if task.Status == storage.StatusCompleted {
 		return true, nil
 	}
 	return false, nil
@@ -522,6 +1094,8 @@ func (e *TachyonEngine) CheckCollision(filename string) (bool, string, error) {
 // ReorderDownload moves a download in the queue
 // direction: "first", "prev", "next", "last"
 func (e *TachyonEngine) ReorderDownload(id string, direction string) error {
+	before := e.snapshotQueueOrder()
+
 	var success bool
 	switch direction {
 	case "first":
@@ -540,18 +1114,240 @@ func (e *TachyonEngine) ReorderDownload(id string, direction string) error {
 		return fmt.Errorf("could not reorder download %s", id)
 	}
 
-	// Update QueueOrder in database for all queued items (single transaction)
-	items := e.queue.GetAll()
-	batch := make([]storage.DownloadTask, len(items))
-	for i, item := range items {
-		batch[i] = *item
+	// The in-memory queue is authoritative; only rows whose order changed are written
+	if _, err := e.persistQueueOrder(before); err != nil {
+		e.logger.Error("Failed to persist queue order", "error", err)
 	}
-	e.storage.SaveTasks(batch)
 
 	// Emit event notification
-	if e.ctx != nil {
-		runtime.EventsEmit(e.ctx, "queue:reordered", nil)
+	e.emit("queue:reordered", nil)
+
+	return nil
+}
+
+// PromoteDownload moves a download to the front of the queue and makes it
+// the next one dispatched, ahead of queued downloads with a higher
+// priority. A paused or stopped download is resumed first. If preempt is set and every slot is
+// busy, the running download with the lowest priority (most recently started
+// on ties) is paused and re-queued right behind the promoted one, so the
+// promoted download starts immediately. Running downloads with a higher
+// priority than the promoted one are never preempted.
+func (e *TachyonEngine) PromoteDownload(id string, preempt bool) error {
+	if _, active := e.activeDownloads.Load(id); active {
+		return fmt.Errorf("download %s is already active", id)
+	}
+
+	task, err := e.storage.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	if task.Status != storage.StatusPending {
+		if err := e.ResumeDownload(id); err != nil {
+			return err
+		}
+	}
+
+	before := e.snapshotQueueOrder()
+	e.queue.MoveToFirst(id)
+	if _, err := e.persistQueueOrder(before); err != nil {
+		e.logger.Error("Failed to persist queue order", "error", err)
+	}
+	// The scheduler dispatches by priority before queue order
+	e.scheduler.Promote(id)
+
+	victim := ""
+	if preempt {
+		victim = e.preemptFor(id, task.Priority)
+	}
+
+	e.logger.Info("Download promoted", "id", id, "preempted", victim)
+	e.recordEvent(EventDownloadPromoted, id, "Moved to the front of the queue")
+	if victim != "" {
+		e.recordEvent(EventDownloadPreempted, victim, "Paused to make room for %s", id)
+	}
+	e.emit("download:promoted", map[string]interface{}{
+		"id":        id,
+		"preempted": victim,
+	})
+	e.emit("queue:reordered", nil)
+	return nil
+}
+
+// preemptFor cancels one running download to make room for promotedID when all
+// slots are busy. Nothing is cancelled when the freed slot wouldn't go to
+// promotedID anyway, e.g. because its host is at its limit. It returns the
+// ID of the preempted download, or "".
+func (e *TachyonEngine) preemptFor(promotedID string, priority int) string {
+	e.preemptMu.Lock()
+	defer e.preemptMu.Unlock()
+
+	e.workerMutex.Lock()
+	running, max := e.runningDownloads, e.maxConcurrent
+	e.workerMutex.Unlock()
+	if running < max {
+		return ""
+	}
+	if e.dispatchHeld() || e.scheduler.NextTaskID(max-1, max) != promotedID {
+		return ""
+	}
+
+	var victimID string
+	var victim *activeDownloadInfo
+	e.activeDownloads.Range(func(key, value interface{}) bool {
+		info, ok := value.(*activeDownloadInfo)
+		if !ok || info.Priority > priority {
+			return true
+		}
+		if _, already := e.preempted.Load(key); already {
+			return true
+		}
+		if victim == nil || info.Priority < victim.Priority ||
+			(info.Priority == victim.Priority && info.StartedAt.After(victim.StartedAt)) {
+			victimID, victim = key.(string), info
+		}
+		return true
+	})
+	if victim == nil {
+		return ""
+	}
+
+	e.preempted.Store(victimID, promotedID)
+	if victim.Cancel != nil {
+		victim.Cancel()
+	}
+	return victimID
+}
+
+// requeuePreempted puts a preempted download back into the queue behind the
+// download that displaced it.
+func (e *TachyonEngine) requeuePreempted(id, promotedID string) {
+	task, err := e.storage.GetTask(id)
+	if err != nil || task.Status != storage.StatusPaused {
+		return // finished or failed before the cancel landed
+	}
+
+	if err := setStatus(&task, storage.StatusPending); err != nil {
+		return
+	}
+	if err := e.storage.SaveTask(task); err != nil {
+		e.logger.Error("Failed to re-queue preempted download", "id", id, "error", err)
+		return
+	}
+
+	before := e.snapshotQueueOrder()
+	requeued := task
+	e.queue.Push(&requeued)
+	if !e.queue.MoveAfter(id, promotedID) {
+		e.queue.MoveToFirst(id)
+	}
+	if _, err := e.persistQueueOrder(before); err != nil {
+		e.logger.Error("Failed to persist queue order", "error", err)
+	}
+
+	e.emit("download:preempted", map[string]interface{}{
+		"id": id,
+		"by": promotedID,
+	})
+}
+
+// snapshotQueueOrder records the current QueueOrder of every queued task
+func (e *TachyonEngine) snapshotQueueOrder() map[string]int {
+	items := e.queue.GetAll()
+	orders := make(map[string]int, len(items))
+	for _, item := range items {
+		orders[item.ID] = item.QueueOrder
+	}
+	return orders
+}
+
+// persistQueueOrder saves the queued tasks whose QueueOrder differs from the
+// snapshot in a single transaction and returns how many rows were written.
+func (e *TachyonEngine) persistQueueOrder(before map[string]int) (int, error) {
+	var changed []storage.DownloadTask
+	for _, item := range e.queue.GetAll() {
+		if order, ok := before[item.ID]; ok && order == item.QueueOrder {
+			continue
+		}
+		changed = append(changed, *item)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	return len(changed), e.storage.SaveTasks(changed)
+}
+
+// ReorderBatch moves several queued downloads at once ("first", "prev",
+// "next" or "last"), persisting the new order in one transaction and
+// emitting a single queue:reordered event.
+func (e *TachyonEngine) ReorderBatch(ids []string, position string) error {
+	switch position {
+	case "first", "prev", "next", "last":
+	default:
+		return fmt.Errorf("invalid direction: %s", position)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	before := e.snapshotQueueOrder()
+	if !e.queue.MoveBatch(ids, position) {
+		return nil
+	}
+
+	if _, err := e.persistQueueOrder(before); err != nil {
+		return fmt.Errorf("failed to persist queue order: %w", err)
+	}
+
+	e.emit("queue:reordered", nil)
+	return nil
+}
+
+// DefaultPriority is the priority new downloads get unless changed with
+// SetDefaultPriority (Normal).
+const DefaultPriority = 1
+
+// SetDefaultPriority sets the priority (0=Low, 1=Normal, 2=High) given to
+// downloads added from now on. Queued downloads keep theirs.
+func (e *TachyonEngine) SetDefaultPriority(priority int) error {
+	if priority < 0 || priority > 2 {
+		return fmt.Errorf("invalid priority: %d", priority)
+	}
+	e.defaultPriority.Store(int32(priority))
+	return nil
+}
+
+// GetDefaultPriority returns the priority new downloads get.
+func (e *TachyonEngine) GetDefaultPriority() int {
+	return int(e.defaultPriority.Load())
+}
+
+// saveNewTask stores a task the engine has just created. GORM fills a zero
+// Priority (Low) with the column default on insert, so it is written again.
+func (e *TachyonEngine) saveNewTask(task storage.DownloadTask) error {
+	if err := e.storage.SaveTask(task); err != nil {
+		return err
+	}
+	if task.Priority == 0 {
+		return e.storage.UpdatePriority([]string{task.ID}, 0)
+	}
+	return nil
+}
+
+// SetPriorityBatch sets the priority (0=Low, 1=Normal, 2=High) of several
+// downloads in one storage update and emits a single queue:reordered event.
+func (e *TachyonEngine) SetPriorityBatch(ids []string, priority int) error {
+	if priority < 0 || priority > 2 {
+		return fmt.Errorf("invalid priority: %d", priority)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := e.storage.UpdatePriority(ids, priority); err != nil {
+		return fmt.Errorf("failed to update priority: %w", err)
 	}
+	e.queue.SetPriority(ids, priority)
 
+	e.emit("queue:reordered", nil)
 	return nil
 }
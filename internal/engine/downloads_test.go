@@ -1,11 +1,20 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"project-tachyon/internal/filesystem"
 	"project-tachyon/internal/storage"
 
 	"github.com/glebarez/sqlite"
@@ -24,6 +33,7 @@ func createDownloadsTestDB(t *testing.T) *storage.Storage {
 		&storage.DailyStat{},
 		&storage.AppSetting{},
 		&storage.SpeedTestHistory{},
+		&storage.HostProfile{},
 	); err != nil {
 		t.Fatalf("Migration failed: %v", err)
 	}
@@ -104,6 +114,83 @@ func TestGetTask_NotFound(t *testing.T) {
 	}
 }
 
+func TestGetLiveProgress_AheadOfStoredTask(t *testing.T) {
+	content := generateDummyContent(4 * 1024 * 1024)
+	// Every ranged GET sends half its bytes, then hangs until cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Range"), "bytes="), "-")
+		start, _ := strconv.Atoi(parts[0])
+		end := len(content) - 1
+		if len(parts) > 1 && parts[1] != "" {
+			end, _ = strconv.Atoi(parts[1])
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		data := content[start : end+1]
+		if len(data) < 2 {
+			w.Write(data) // range probe
+			return
+		}
+		w.Write(data[:len(data)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+
+	if _, ok := e.GetLiveProgress("missing"); ok {
+		t.Error("live progress reported for a download that is not running")
+	}
+	id, err := e.StartDownload(server.URL+"/file.bin", t.TempDir(), "file.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var live LiveProgress
+	for {
+		var ok bool
+		if live, ok = e.GetLiveProgress(id); ok && live.Downloaded > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no live bytes reported (last %+v)", live)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Progress is saved every few seconds; the stored task lags behind
+	stored, err := store.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Downloaded >= live.Downloaded {
+		t.Errorf("stored downloaded = %d, want it behind live %d", stored.Downloaded, live.Downloaded)
+	}
+	if live.Transferred < live.Downloaded {
+		t.Errorf("transferred = %d, want at least downloaded %d", live.Transferred, live.Downloaded)
+	}
+
+	if err := e.PauseDownload(id); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, ok := e.GetLiveProgress(id); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("live progress still reported after pausing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestPauseDownload_NotActive(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	s := createDownloadsTestDB(t)
@@ -265,6 +352,32 @@ func TestStartDownload_InvalidURL(t *testing.T) {
 	}
 }
 
+func TestStartDownload_InvalidCookies(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	for _, c := range []string{`[{"Name":"session"`, `[{"Name":1}]`, `{"session":"x"}`, `[{"Name":"sid","Value":"s3cr3t","Expires":"s3cr3t"}]`} {
+		_, err := e.StartDownload("https://example.com/a.zip", t.TempDir(), "", map[string]string{"cookies_json": c})
+		if err == nil {
+			t.Errorf("cookies_json=%q accepted", c)
+		} else if strings.Contains(err.Error(), "s3cr3t") {
+			t.Errorf("error echoes a cookie value: %v", err)
+		}
+	}
+	if tasks, _ := s.GetAllTasks(); len(tasks) != 0 {
+		t.Errorf("rejected downloads were queued: %d tasks", len(tasks))
+	}
+
+	opts := map[string]string{"start_time": heldStartTime()}
+	for _, c := range []string{`[{"Name":"session","Value":"x"}]`, "session=x; theme=dark"} {
+		opts["cookies_json"] = c
+		if _, err := e.StartDownload("https://example.com/a.zip", t.TempDir(), "", opts); err != nil {
+			t.Errorf("cookies_json=%q rejected: %v", c, err)
+		}
+	}
+}
+
 func TestStartDownload_ScheduledStart(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	s := createDownloadsTestDB(t)
@@ -300,3 +413,546 @@ func TestStartDownload_CustomFilename(t *testing.T) {
 		t.Error("Filename should not be empty")
 	}
 }
+
+// heldStartTime returns a start time far enough in the future that the
+// queue worker leaves the task in the queue during the test.
+func heldStartTime() string {
+	return time.Now().Add(time.Hour).Format(time.RFC3339)
+}
+
+func TestReorderBatch_SingleEventAndPersist(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	events := 0
+	e.eventHook = func(name string, _ interface{}) {
+		if name == "queue:reordered" {
+			events++
+		}
+	}
+
+	for i, id := range []string{"q1", "q2", "q3", "q4"} {
+		task := storage.DownloadTask{ID: id, URL: "http://example.com/" + id, Status: "pending", QueueOrder: i + 1, StartTime: heldStartTime()}
+		s.SaveTask(task)
+		e.queue.Push(&task)
+	}
+
+	if err := e.ReorderBatch([]string{"q3", "q4"}, "first"); err != nil {
+		t.Fatalf("ReorderBatch() error: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("expected 1 queue:reordered event, got %d", events)
+	}
+
+	want := map[string]int{"q3": 1, "q4": 2, "q1": 3, "q2": 4}
+	for id, order := range want {
+		task, _ := s.GetTask(id)
+		if task.QueueOrder != order {
+			t.Errorf("%s QueueOrder = %d, want %d", id, task.QueueOrder, order)
+		}
+	}
+
+	if err := e.ReorderBatch([]string{"q1"}, "sideways"); err == nil {
+		t.Error("expected error for invalid position")
+	}
+}
+
+func TestSetPriorityBatch(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	events := 0
+	e.eventHook = func(name string, _ interface{}) {
+		if name == "queue:reordered" {
+			events++
+		}
+	}
+
+	queued := storage.DownloadTask{ID: "p1", URL: "http://example.com/1", Status: "pending", Priority: 1, StartTime: heldStartTime()}
+	s.SaveTask(queued)
+	e.queue.Push(&queued)
+	s.SaveTask(storage.DownloadTask{ID: "p2", URL: "http://example.com/2", Status: "paused", Priority: 1})
+	s.SaveTask(storage.DownloadTask{ID: "p3", URL: "http://example.com/3", Status: "paused", Priority: 1})
+
+	if err := e.SetPriorityBatch([]string{"p1", "p2"}, 2); err != nil {
+		t.Fatalf("SetPriorityBatch() error: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("expected 1 queue:reordered event, got %d", events)
+	}
+
+	for id, want := range map[string]int{"p1": 2, "p2": 2, "p3": 1} {
+		task, _ := s.GetTask(id)
+		if task.Priority != want {
+			t.Errorf("%s priority = %d, want %d", id, task.Priority, want)
+		}
+	}
+	if got := e.queue.GetAll()[0].Priority; got != 2 {
+		t.Errorf("queued item priority = %d, want 2", got)
+	}
+
+	if err := e.SetPriorityBatch([]string{"p1"}, 5); err == nil {
+		t.Error("expected error for out-of-range priority")
+	}
+}
+
+func TestStartDownload_DefaultPriority(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	if got := e.GetDefaultPriority(); got != DefaultPriority {
+		t.Fatalf("GetDefaultPriority = %d, want %d", got, DefaultPriority)
+	}
+
+	dir := t.TempDir()
+	opts := map[string]string{"start_time": heldStartTime()}
+	for _, priority := range []int{2, 0} {
+		if err := e.SetDefaultPriority(priority); err != nil {
+			t.Fatal(err)
+		}
+		id, err := e.StartDownload(fmt.Sprintf("http://example.com/p%d.bin", priority), dir, "", opts)
+		if err != nil {
+			t.Fatalf("StartDownload: %v", err)
+		}
+		if task, _ := s.GetTask(id); task.Priority != priority {
+			t.Errorf("stored priority = %d, want %d", task.Priority, priority)
+		}
+		for _, queued := range e.queue.GetAll() {
+			if queued.ID == id && queued.Priority != priority {
+				t.Errorf("queued priority = %d, want %d", queued.Priority, priority)
+			}
+		}
+	}
+
+	for _, p := range []int{-1, 3} {
+		if err := e.SetDefaultPriority(p); err == nil {
+			t.Errorf("SetDefaultPriority(%d) succeeded", p)
+		}
+	}
+	if got := e.GetDefaultPriority(); got != 0 {
+		t.Errorf("invalid values changed the default to %d", got)
+	}
+}
+
+func TestStartDownload_SizeFromProbe(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	if err := e.SetDispatchOrder("size_asc"); err != nil || e.GetDispatchOrder() != "size_asc" {
+		t.Fatalf("SetDispatchOrder: %v (order %q)", err, e.GetDispatchOrder())
+	}
+	if err := e.SetDispatchOrder("biggest"); err == nil {
+		t.Error("unknown dispatch order accepted")
+	}
+
+	e.probes.Put("http://example.com/probed.bin", &ProbeResult{Size: 4096, Status: 200})
+	opts := map[string]string{"start_time": heldStartTime()}
+	dir := t.TempDir()
+	probed, _ := e.StartDownload("http://example.com/probed.bin", dir, "", opts)
+	unknown, _ := e.StartDownload("http://example.com/unknown.bin", dir, "", opts)
+
+	for id, want := range map[string]int64{probed: 4096, unknown: 0} {
+		if task, _ := s.GetTask(id); task.TotalSize != want {
+			t.Errorf("%s size = %d, want %d", task.Filename, task.TotalSize, want)
+		}
+	}
+}
+
+func TestReorderDownload_PersistsOnlyChangedItems(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	const n = 500
+	tasks := make([]storage.DownloadTask, n)
+	for i := range tasks {
+		tasks[i] = storage.DownloadTask{ID: fmt.Sprintf("big-%03d", i), Status: "pending", QueueOrder: i + 1, StartTime: heldStartTime()}
+	}
+	s.SaveTasks(tasks)
+	for i := range tasks {
+		e.queue.Push(&tasks[i])
+	}
+
+	before := e.snapshotQueueOrder()
+	if !e.queue.MoveToNext("big-250") {
+		t.Fatal("MoveToNext failed")
+	}
+	written, err := e.persistQueueOrder(before)
+	if err != nil {
+		t.Fatalf("persistQueueOrder() error: %v", err)
+	}
+	if written != 2 {
+		t.Errorf("expected 2 rows written for an adjacent swap, got %d", written)
+	}
+
+	// A no-op snapshot writes nothing
+	if written, _ := e.persistQueueOrder(e.snapshotQueueOrder()); written != 0 {
+		t.Errorf("expected 0 rows written without changes, got %d", written)
+	}
+
+	if err := e.ReorderDownload("big-100", "prev"); err != nil {
+		t.Fatalf("ReorderDownload() error: %v", err)
+	}
+	moved, _ := s.GetTask("big-100")
+	if moved.QueueOrder != 100 {
+		t.Errorf("big-100 QueueOrder = %d, want 100", moved.QueueOrder)
+	}
+	swapped, _ := s.GetTask("big-099")
+	if swapped.QueueOrder != 101 {
+		t.Errorf("big-099 QueueOrder = %d, want 101", swapped.QueueOrder)
+	}
+}
+
+func TestRelocateAndResume_MovesPartFiles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	e.diskSpaceCheck = nil
+
+	oldDir := t.TempDir()
+	newDir := t.TempDir()
+	oldPath := filepath.Join(oldDir, "file.bin")
+	oldTemp := tempDirForTask(oldPath)
+	os.MkdirAll(oldTemp, 0755)
+	os.WriteFile(filepath.Join(oldTemp, "mv.part.0"), []byte("part-data"), 0644)
+
+	s.SaveTask(storage.DownloadTask{
+		ID:         "mv",
+		URL:        "http://127.0.0.1:1/file.bin", // dispatch fails fast, no network
+		Filename:   "file.bin",
+		SavePath:   oldPath,
+		Status:     "paused",
+		Downloaded: 9,
+		StartTime:  heldStartTime(),
+	})
+
+	if err := e.RelocateAndResume("mv", newDir); err != nil {
+		t.Fatalf("RelocateAndResume() error: %v", err)
+	}
+
+	task, _ := s.GetTask("mv")
+	if !strings.HasPrefix(task.SavePath, newDir) {
+		t.Errorf("SavePath = %q, want under %q", task.SavePath, newDir)
+	}
+	moved := filepath.Join(tempDirForTask(task.SavePath), "mv.part.0")
+	if data, err := os.ReadFile(moved); err != nil || string(data) != "part-data" {
+		t.Errorf("part file not moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(oldTemp, "mv.part.0")); !os.IsNotExist(err) {
+		t.Error("old part file should be gone")
+	}
+	if task.Downloaded != 9 {
+		t.Errorf("Downloaded = %d, want progress kept", task.Downloaded)
+	}
+}
+
+func TestRelocateAndResume_RejectsActiveOrCompleted(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{ID: "done", URL: "http://example.com/a", Status: "completed"})
+	if err := e.RelocateAndResume("done", t.TempDir()); err == nil {
+		t.Error("expected error relocating a completed download")
+	}
+	if err := e.RelocateAndResume("missing", t.TempDir()); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
+func TestRefreshDownloadAuth_RejectsInvalidInput(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{ID: "done", URL: "http://example.com/a", Status: "completed"})
+	s.SaveTask(storage.DownloadTask{ID: "auth", URL: "http://example.com/b", Status: StatusNeedsAuth})
+
+	if err := e.RefreshDownloadAuth("done", "", `{"X-Token":"a"}`, ""); err == nil {
+		t.Error("expected error refreshing a completed download")
+	}
+	if err := e.RefreshDownloadAuth("missing", "", "", ""); err == nil {
+		t.Error("expected error for unknown task")
+	}
+	if err := e.RefreshDownloadAuth("auth", "ftp://example.com/b", "", ""); err == nil {
+		t.Error("expected error for unsupported URL scheme")
+	}
+	if err := e.RefreshDownloadAuth("auth", "", "not json", ""); err == nil {
+		t.Error("expected error for malformed headers JSON")
+	}
+	if err := e.RefreshDownloadAuth("auth", "", "", `[{"Name":`); err == nil {
+		t.Error("expected error for malformed cookies JSON")
+	}
+
+	task, _ := s.GetTask("auth")
+	if task.Status != StatusNeedsAuth || task.URL != "http://example.com/b" {
+		t.Errorf("rejected refresh modified task: status=%q url=%q", task.Status, task.URL)
+	}
+}
+
+func TestStartDownload_QueueFull(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	e.SetMaxQueueSize(2)
+
+	// Finished downloads don't count against the limit
+	s.SaveTask(storage.DownloadTask{ID: "old", URL: "http://example.com/old", Status: "completed"})
+
+	dir := t.TempDir()
+	opts := map[string]string{"start_time": heldStartTime()}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, err := e.StartDownload(fmt.Sprintf("http://example.com/f%d.bin", i), dir, "", opts)
+		if err != nil {
+			t.Fatalf("StartDownload %d failed: %v", i, err)
+		}
+		ids = append(ids, id)
+	}
+	if got := e.QueueSize(); got != 2 {
+		t.Errorf("QueueSize = %d, want 2", got)
+	}
+
+	if _, err := e.StartDownload("http://example.com/f2.bin", dir, "", opts); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// A finished download frees a slot
+	s.UpdateTaskStatus(ids[0], "completed")
+	if _, err := e.StartDownload("http://example.com/f2.bin", dir, "", opts); err != nil {
+		t.Fatalf("expected slot to be freed, got %v", err)
+	}
+
+	e.SetMaxQueueSize(0)
+	if _, err := e.StartDownload("http://example.com/f3.bin", dir, "", opts); err != nil {
+		t.Fatalf("unlimited queue rejected download: %v", err)
+	}
+}
+
+func TestStartDownload_DetectsHashAlgorithm(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	dir := t.TempDir()
+
+	for hash, want := range map[string]string{
+		"D41D8CD98F00B204E9800998ECF8427E":   "md5",
+		"sha512:" + strings.Repeat("a", 128): "sha512",
+		strings.Repeat("b", 64):              "sha256",
+	} {
+		id, err := e.StartDownload("http://example.com/hashed.bin", dir, "", map[string]string{
+			"start_time":    heldStartTime(),
+			"expected_hash": hash,
+		})
+		if err != nil {
+			t.Fatalf("StartDownload failed: %v", err)
+		}
+		task, _ := s.GetTask(id)
+		if task.HashAlgorithm != want {
+			t.Errorf("hash %q: algorithm = %q, want %q", hash, task.HashAlgorithm, want)
+		}
+	}
+}
+
+func TestStartDownload_Dedupe(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	dir := t.TempDir()
+	opts := map[string]string{"start_time": heldStartTime(), "dedupe": "true"}
+	first, err := e.StartDownload("http://example.com/dup.bin", dir, "", opts)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	second, err := e.StartDownload("http://example.com/dup.bin", dir, "", opts)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if first != second {
+		t.Errorf("expected the existing task %s to be reused, got %s", first, second)
+	}
+	if tasks, _ := e.GetHistory(); len(tasks) != 1 {
+		t.Errorf("expected 1 task, got %d", len(tasks))
+	}
+
+	// Without dedupe the URL is added again
+	if third, _ := e.StartDownload("http://example.com/dup.bin", dir, "", map[string]string{"start_time": heldStartTime()}); third == first {
+		t.Error("expected a new task without dedupe")
+	}
+}
+
+func TestStartDownload_DedupeResumesPaused(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{ID: "paused", URL: "http://example.com/p.bin", Status: storage.StatusPaused, CreatedAt: time.Now().Format(time.RFC3339)})
+	s.SaveTask(storage.DownloadTask{ID: "done", URL: "http://example.com/c.bin", Status: storage.StatusCompleted, CreatedAt: time.Now().Format(time.RFC3339)})
+
+	id, err := e.StartDownload("http://example.com/p.bin", t.TempDir(), "", map[string]string{"dedupe": "true", "dedupe_resume": "true"})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if id != "paused" {
+		t.Fatalf("expected paused task to be reused, got %s", id)
+	}
+	if task, _ := s.GetTask("paused"); task.Status != storage.StatusPending {
+		t.Errorf("expected paused task to be re-queued, got %s", task.Status)
+	}
+
+	// Completed downloads are not reused
+	id, err = e.StartDownload("http://example.com/c.bin", t.TempDir(), "", map[string]string{"dedupe": "true"})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if id == "done" {
+		t.Error("completed task should not be reused")
+	}
+}
+
+func TestResumeAllDownloads_OnlyResumesAutoPaused(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	// A was paused by the user before pause-all; B was still queued
+	s.SaveTask(storage.DownloadTask{ID: "A", URL: "http://127.0.0.1:1/a.zip", Status: "paused"})
+	s.SaveTask(storage.DownloadTask{ID: "B", URL: "http://127.0.0.1:1/b.zip", Status: "pending"})
+	s.SaveTask(storage.DownloadTask{ID: "C", URL: "http://127.0.0.1:1/c.zip", Status: "stopped"})
+
+	e.PauseAllDownloads()
+	if task, _ := s.GetTask("B"); task.Status != "paused" {
+		t.Fatalf("B status after pause-all = %q, want paused", task.Status)
+	}
+
+	e.ResumeAllDownloads()
+
+	if task, _ := s.GetTask("A"); task.Status != "paused" {
+		t.Errorf("manually paused A was resumed (status %q)", task.Status)
+	}
+	if task, _ := s.GetTask("C"); task.Status != "stopped" {
+		t.Errorf("stopped C was resumed (status %q)", task.Status)
+	}
+	if task, _ := s.GetTask("B"); task.Status == "paused" {
+		t.Error("auto-paused B should have been resumed")
+	}
+	if raw, _ := s.GetString(autoPausedKey); raw != "" {
+		t.Errorf("pause-all set should be cleared, got %q", raw)
+	}
+}
+
+func TestResumeAllDownloads_EmptyPauseAllResumesNothing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{ID: "A", URL: "http://127.0.0.1:1/a.zip", Status: "paused"})
+
+	// Nothing was running, so pause-all paused nothing and resume-all must
+	// not revive the manually paused download.
+	e.PauseAllDownloads()
+	e.ResumeAllDownloads()
+	if task, _ := s.GetTask("A"); task.Status != "paused" {
+		t.Errorf("A status = %q, want paused", task.Status)
+	}
+
+	// Without an outstanding pause-all, resume-all resumes every paused download
+	e.ResumeAllDownloads()
+	if task, _ := s.GetTask("A"); task.Status == "paused" {
+		t.Error("resume-all without pause-all should resume A")
+	}
+}
+
+func TestSuspendDownloads_LeavesPauseAllSet(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{ID: "A", URL: "http://127.0.0.1:1/a.zip", Status: "paused"})
+	s.SaveTask(storage.DownloadTask{ID: "B", URL: "http://127.0.0.1:1/b.zip", Status: "pending"})
+
+	ids := e.SuspendDownloads()
+	if len(ids) != 1 || ids[0] != "B" {
+		t.Fatalf("SuspendDownloads() = %v, want [B]", ids)
+	}
+	if task, _ := s.GetTask("B"); task.Status != "paused" {
+		t.Errorf("B status = %q, want paused", task.Status)
+	}
+	if raw, _ := s.GetString(autoPausedKey); raw != "" {
+		t.Errorf("suspend should not record a pause-all, got %q", raw)
+	}
+}
+
+func TestResumeDownload_ForgetsAutoPaused(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{ID: "B", URL: "http://127.0.0.1:1/b.zip", Status: "pending"})
+	s.SaveTask(storage.DownloadTask{ID: "D", URL: "http://127.0.0.1:1/d.zip", Status: "pending"})
+	e.PauseAllDownloads()
+
+	// Resuming B by hand makes any later pause of it a manual one, so it
+	// must leave the pause-all set.
+	if err := e.ResumeDownload("B"); err != nil {
+		t.Fatal(err)
+	}
+	ids, ok := e.loadAutoPaused()
+	if !ok || len(ids) != 1 || ids[0] != "D" {
+		t.Errorf("pause-all set = %v, want [D]", ids)
+	}
+}
+
+func TestRelocateAndResume_MidMoveFailureRollsBack(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	e.diskSpaceCheck = nil
+
+	oldDir := t.TempDir()
+	newDir := t.TempDir()
+	oldPath := filepath.Join(oldDir, "file.bin")
+	oldTemp := tempDirForTask(oldPath)
+	os.MkdirAll(oldTemp, 0755)
+	os.WriteFile(filepath.Join(oldTemp, "rb.part.0"), []byte("first"), 0644)
+	os.WriteFile(filepath.Join(oldTemp, "rb.part.100"), []byte("second"), 0644)
+
+	// The second part can't land at the new location
+	organized, _ := filesystem.GetOrganizedPath(newDir, "file.bin")
+	newTemp := tempDirForTask(organized)
+	blocker := filepath.Join(newTemp, "rb.part.100")
+	os.MkdirAll(filepath.Join(blocker, "taken"), 0755)
+
+	s.SaveTask(storage.DownloadTask{
+		ID:         "rb",
+		URL:        "http://127.0.0.1:1/file.bin",
+		Filename:   "file.bin",
+		SavePath:   oldPath,
+		Status:     storage.StatusPaused,
+		Downloaded: 11,
+		MetaJSON:   `{"kept":true}`,
+	})
+
+	if err := e.RelocateAndResume("rb", newDir); err == nil {
+		t.Fatal("expected an error when a part can't be moved")
+	}
+
+	task, _ := s.GetTask("rb")
+	if task.SavePath != oldPath || task.Status != storage.StatusPaused {
+		t.Errorf("task moved on failure: path %q status %s", task.SavePath, task.Status)
+	}
+	if task.Downloaded != 11 || task.MetaJSON == "" {
+		t.Errorf("progress dropped: downloaded %d, meta %q", task.Downloaded, task.MetaJSON)
+	}
+	for name, want := range map[string]string{"rb.part.0": "first", "rb.part.100": "second"} {
+		if data, err := os.ReadFile(filepath.Join(oldTemp, name)); err != nil || string(data) != want {
+			t.Errorf("%s not back in place: %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(newTemp, "rb.part.0")); !os.IsNotExist(err) {
+		t.Error("moved part left behind at the new location")
+	}
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// DefaultEmergencyTimeout bounds EmergencyStop when the caller gives no
+// deadline. Power events leave the process very little time.
+const DefaultEmergencyTimeout = 2 * time.Second
+
+// EmergencyStop pauses every running download and writes its resume state
+// straight to the database, then checkpoints the WAL, all within timeout.
+// Unlike Shutdown it does not wait for workers to exit: each download's
+// last published checkpoint is saved instead, so progress survives a power
+// loss or forced kill moments later. It returns the IDs of the downloads
+// it paused; they are also marked to resume on the next start, including
+// by a Shutdown that follows.
+func (e *TachyonEngine) EmergencyStop(timeout time.Duration) ([]string, error) {
+	if timeout <= 0 {
+		timeout = DefaultEmergencyTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	e.logger.Warn("Emergency stop", "timeout", timeout)
+
+	var ids []string
+	var checkpoints []storage.TaskCheckpoint
+	e.activeDownloads.Range(func(key, value interface{}) bool {
+		info, ok := value.(*activeDownloadInfo)
+		if !ok {
+			return true
+		}
+		if info.Cancel != nil {
+			info.Cancel()
+		}
+		ids = append(ids, key.(string))
+		e.emergencyPaused.Store(key, struct{}{})
+		if cp := info.checkpoint.Load(); cp != nil {
+			checkpoints = append(checkpoints, *cp)
+		} else {
+			// Still probing: nothing downloaded yet, just mark it paused.
+			checkpoints = append(checkpoints, storage.TaskCheckpoint{ID: key.(string)})
+		}
+		return true
+	})
+
+	var errs []error
+	if err := e.storage.SaveCheckpoints(ctx, checkpoints); err != nil {
+		errs = append(errs, fmt.Errorf("save checkpoints: %w", err))
+	}
+	if err := e.storage.SetString("auto_resume_ids", joinIDs(ids)); err != nil {
+		errs = append(errs, fmt.Errorf("save auto-resume IDs: %w", err))
+	}
+	if err := e.storage.CheckpointContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("checkpoint WAL: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		e.logger.Error("Emergency stop incomplete", "error", err)
+		return ids, err
+	}
+	e.logger.Info("Emergency stop complete", "paused", len(ids))
+	return ids, nil
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// completedInState counts the finished parts recorded in a MetaJSON string.
+func completedInState(t *testing.T, meta string) int {
+	t.Helper()
+	if meta == "" {
+		return 0
+	}
+	var state storage.ResumeState
+	if err := json.Unmarshal([]byte(meta), &state); err != nil {
+		t.Fatalf("bad resume state %q: %v", meta, err)
+	}
+	n := 0
+	for _, p := range state.Parts {
+		if p.Complete {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEmergencyStop_PersistsCheckpointWithoutWaiting(t *testing.T) {
+	store := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+
+	task := storage.DownloadTask{ID: "running", URL: "http://example.com/big.iso", Status: storage.StatusDownloading, TotalSize: 1000}
+	if err := store.SaveTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// An executor that never unwinds: Cancel is observed but nothing else
+	// happens, so anything persisted comes from EmergencyStop itself.
+	var cancelled sync.WaitGroup
+	cancelled.Add(1)
+	info := &activeDownloadInfo{Cancel: cancelled.Done, Wait: &sync.WaitGroup{}}
+	info.checkpoint.Store(&storage.TaskCheckpoint{
+		ID:         "running",
+		MetaJSON:   `{"v":1,"parts":{"0":{"s":0,"e":499,"c":true}}}`,
+		Downloaded: 640,
+		Progress:   64,
+	})
+	e.activeDownloads.Store("running", info)
+
+	start := time.Now()
+	ids, err := e.EmergencyStop(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("EmergencyStop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("EmergencyStop took %v", elapsed)
+	}
+	cancelled.Wait()
+
+	if len(ids) != 1 || ids[0] != "running" {
+		t.Errorf("paused IDs = %v, want [running]", ids)
+	}
+	got, _ := store.GetTask("running")
+	if got.Status != storage.StatusPaused {
+		t.Errorf("status = %s, want paused", got.Status)
+	}
+	if got.Downloaded != 640 || got.Progress != 64 {
+		t.Errorf("progress not persisted: downloaded=%d progress=%v", got.Downloaded, got.Progress)
+	}
+	if completedInState(t, got.MetaJSON) != 1 {
+		t.Errorf("resume state not persisted: %q", got.MetaJSON)
+	}
+	if resume, _ := store.GetString("auto_resume_ids"); resume != "running" {
+		t.Errorf("auto_resume_ids = %q, want running", resume)
+	}
+}
+
+func TestEmergencyStop_ThenShutdownKeepsAutoResume(t *testing.T) {
+	store := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	for _, id := range []string{"running", "held"} {
+		if err := store.SaveTask(storage.DownloadTask{ID: id, URL: "http://example.com/" + id, Status: storage.StatusDownloading}); err != nil {
+			t.Fatal(err)
+		}
+		e.activeDownloads.Store(id, &activeDownloadInfo{Cancel: func() {}, Wait: &sync.WaitGroup{}})
+	}
+	if _, err := e.EmergencyStop(100 * time.Millisecond); err != nil {
+		t.Fatalf("EmergencyStop: %v", err)
+	}
+	// The executors unwind, and the user pauses one download by hand
+	// before the shutdown.
+	e.activeDownloads.Delete("running")
+	e.activeDownloads.Delete("held")
+	e.PauseDownload("held")
+
+	if err := e.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if resume, _ := store.GetString("auto_resume_ids"); resume != "running" {
+		t.Errorf("auto_resume_ids = %q, want running", resume)
+	}
+}
+
+func TestEmergencyStop_LiveDownload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(12 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 20*time.Millisecond)
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+
+	id, err := e.StartDownload(server.URL+"/big.bin", t.TempDir(), "big.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+
+	// Wait until at least one part is complete so there is state to save.
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		if val, ok := e.activeDownloads.Load(id); ok {
+			if cp := val.(*activeDownloadInfo).checkpoint.Load(); cp != nil && completedInState(t, cp.MetaJSON) > 0 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no part completed in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := e.EmergencyStop(200 * time.Millisecond); err != nil {
+		t.Fatalf("EmergencyStop: %v", err)
+	}
+
+	got, _ := store.GetTask(id)
+	if got.Status != storage.StatusPaused {
+		t.Errorf("status = %s, want paused", got.Status)
+	}
+	if got.Downloaded == 0 || completedInState(t, got.MetaJSON) == 0 {
+		t.Errorf("resume state not persisted: downloaded=%d meta=%q", got.Downloaded, got.MetaJSON)
+	}
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
@@ -10,10 +11,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path"
 	"path/filepath"
 	"project-tachyon/internal/storage"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -40,6 +43,27 @@ func createTempDB(t *testing.T) *storage.Storage {
 	return s
 }
 
+// newLoopbackEngine creates an engine on a fresh test DB that may download
+// from local test servers. It is shut down when the test ends.
+func newLoopbackEngine(t *testing.T) (*TachyonEngine, *storage.Storage) {
+	t.Helper()
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	e.allowLoopback = true
+	t.Cleanup(func() { e.Shutdown() })
+	return e, store
+}
+
+// spawnContentServer serves content at every path, answering HEAD and Range
+// requests like a regular file server. It is closed when the test ends.
+func spawnContentServer(t *testing.T, content []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
 // spawnRangeServer creates a mock HTTP server supporting Range requests
 func spawnRangeServer(_ *testing.T, content []byte, errorEveryN int) *httptest.Server {
 	var requestCount atomic.Int32
@@ -292,8 +316,9 @@ func TestPauseAndResume(t *testing.T) {
 	}
 
 	// 4. Resume by re-queuing
-	task.Status = "pending"
-	engine.queue.Push(&task)
+	resumed := task
+	resumed.Status = "pending"
+	engine.queue.Push(&resumed)
 
 	// Wait for completion
 	timeout := time.After(15 * time.Second)
@@ -484,3 +509,783 @@ func humanizeBytes(s int64) string {
 	}
 	return fmt.Sprintf("%.2f MB", float64(s)/1024/1024)
 }
+
+func TestPromoteDownload_PreemptsAtCapacity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(2 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 200*time.Millisecond)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetMaxConcurrent(1)
+	defer engine.Shutdown()
+
+	waitActive := func(id string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, ok := engine.activeDownloads.Load(id); ok {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("download %s never became active", id)
+	}
+
+	idA, _ := engine.StartDownload(server.URL+"/a.bin", tmpDir, "a.bin", nil)
+	waitActive(idA)
+	idB, _ := engine.StartDownload(server.URL+"/b.bin", tmpDir, "b.bin", nil)
+	idC, _ := engine.StartDownload(server.URL+"/c.bin", tmpDir, "c.bin", nil)
+	// B outranks the promoted download, which must still get the slot
+	if err := engine.SetPriorityBatch([]string{idB}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	var promoted map[string]interface{}
+	engine.eventHook = func(name string, data interface{}) {
+		if name == "download:promoted" {
+			promoted = data.(map[string]interface{})
+		}
+	}
+
+	if err := engine.PromoteDownload(idC, true); err != nil {
+		t.Fatalf("PromoteDownload failed: %v", err)
+	}
+	if promoted == nil || promoted["preempted"] != idA {
+		t.Fatalf("expected promoted event preempting %s, got %v", idA, promoted)
+	}
+
+	waitActive(idC)
+	if _, ok := engine.activeDownloads.Load(idB); ok {
+		t.Error("B should still be waiting behind the promoted download")
+	}
+
+	// A goes back into the queue ahead of B in queue order; B still runs
+	// first on priority
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && engine.queue.Len() < 2 {
+		time.Sleep(20 * time.Millisecond)
+	}
+	queued := engine.queue.GetAll()
+	if len(queued) != 2 || queued[0].ID != idA || queued[1].ID != idB {
+		ids := []string{}
+		for _, q := range queued {
+			ids = append(ids, q.ID)
+		}
+		t.Errorf("queue = %v, want [%s %s]", ids, idA, idB)
+	}
+	if task, _ := store.GetTask(idA); task.Status != "pending" {
+		t.Errorf("preempted task status = %q, want pending", task.Status)
+	}
+}
+
+func TestIdlePartIsRetried(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(256 * 1024)
+	expected := md5.Sum(content)
+
+	// The first ranged GET sends half its bytes and then goes silent until the
+	// client gives up; later requests are served normally.
+	var rangedGets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Range"), "bytes="), "-")
+		start, _ := strconv.Atoi(parts[0])
+		end := len(content) - 1
+		if len(parts) > 1 && parts[1] != "" {
+			end, _ = strconv.Atoi(parts[1])
+		}
+		if end-start < 1 {
+			// Range probe
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[start : end+1])
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		data := content[start : end+1]
+		if rangedGets.Add(1) == 1 {
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetPartIdleTimeout(500 * time.Millisecond)
+
+	id, err := engine.StartDownload(server.URL+"/stall.bin", t.TempDir(), "stall.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(15 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		if task.Status == "completed" {
+			diskHash, err := calculateMD5(task.SavePath)
+			if err != nil {
+				t.Fatalf("MD5 check failed: %v", err)
+			}
+			if diskHash != hex.EncodeToString(expected[:]) {
+				t.Error("content mismatch after retry")
+			}
+			break
+		}
+		if task.Status == "error" {
+			t.Fatal("stalled part should have been retried, got error status")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if n := rangedGets.Load(); n < 2 {
+		t.Errorf("expected the stalled part to be re-requested, saw %d ranged GETs", n)
+	}
+}
+
+func TestDownloadStallDetection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(256 * 1024)
+
+	// Every data request sends a few bytes and then goes silent.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(content[:1024])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetPartIdleTimeout(time.Minute)
+	engine.SetStallThresholds(1*time.Second, 3*time.Second)
+
+	events := make(chan string, 16)
+	engine.eventHook = func(name string, data interface{}) {
+		switch name {
+		case "download:stalled":
+			events <- name
+		case "download:paused":
+			if reason, _ := data.(map[string]interface{})["reason"].(string); reason != "" {
+				events <- name
+			}
+		}
+	}
+
+	id, err := engine.StartDownload(server.URL+"/silent.bin", t.TempDir(), "silent.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	want := []string{"download:stalled", "download:paused"}
+	for _, name := range want {
+		select {
+		case got := <-events:
+			if got != name {
+				t.Fatalf("expected %s, got %s", name, got)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout waiting for %s", name)
+		}
+	}
+
+	task, _ := store.GetTask(id)
+	if task.Status != "paused" {
+		t.Errorf("status = %q, want paused", task.Status)
+	}
+}
+
+func TestRelocateAndResume_AfterDiskFull(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(1024 * 1024)
+	expected := md5.Sum(content)
+	server := spawnRangeServer(t, content, 0)
+	defer server.Close()
+
+	fullDir := t.TempDir()
+	newDir := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.diskSpaceCheck = func(path string, required int64) error {
+		if strings.HasPrefix(path, fullDir) {
+			return fmt.Errorf("disk full: required %d bytes, available 0 bytes", required)
+		}
+		return nil
+	}
+
+	waitStatus := func(id string, want storage.Status) storage.DownloadTask {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			task, _ := store.GetTask(id)
+			if task.Status == want {
+				return task
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		task, _ := store.GetTask(id)
+		t.Fatalf("timeout waiting for %s (status %q)", want, task.Status)
+		return task
+	}
+
+	id, err := engine.StartDownload(server.URL+"/relocate.bin", fullDir, "relocate.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	waitStatus(id, "error")
+
+	if err := engine.RelocateAndResume(id, newDir); err != nil {
+		t.Fatalf("RelocateAndResume failed: %v", err)
+	}
+
+	task := waitStatus(id, "completed")
+	if !strings.HasPrefix(task.SavePath, newDir) {
+		t.Errorf("SavePath = %q, want under %q", task.SavePath, newDir)
+	}
+	diskHash, err := calculateMD5(task.SavePath)
+	if err != nil {
+		t.Fatalf("MD5 check failed: %v", err)
+	}
+	if diskHash != hex.EncodeToString(expected[:]) {
+		t.Error("content mismatch after relocation")
+	}
+}
+
+func TestRefreshDownloadAuth_RecoversFrom403(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(128 * 1024)
+	expected := md5.Sum(content)
+
+	// The session used to start the download expires as soon as the first
+	// data request arrives; from then on only the fresh token is accepted.
+	var expired atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Method == "HEAD" {
+			if expired.Load() && auth != "Bearer fresh" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		if auth != "Bearer fresh" {
+			expired.Store(true)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "auth.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+
+	id, err := engine.StartDownload(server.URL+"/auth.bin", t.TempDir(), "auth.bin", map[string]string{
+		"headers_json": `{"Authorization":"Bearer stale"}`,
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	waitForStatus := func(want storage.Status) storage.DownloadTask {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			task, _ := store.GetTask(id)
+			if task.Status == want {
+				return task
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %q (status %q)", want, task.Status)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	waitForStatus(StatusNeedsAuth)
+
+	// Credentials that still fail the probe leave the task untouched
+	if err := engine.RefreshDownloadAuth(id, "", `{"Authorization":"Bearer wrong"}`, ""); err == nil {
+		t.Fatal("expected refresh with rejected credentials to fail")
+	}
+	if task, _ := store.GetTask(id); task.Status != StatusNeedsAuth || !strings.Contains(task.Headers, "stale") {
+		t.Fatalf("failed refresh modified task: status=%q headers=%q", task.Status, task.Headers)
+	}
+
+	if err := engine.RefreshDownloadAuth(id, "", `{"Authorization":"Bearer fresh"}`, "session=abc"); err != nil {
+		t.Fatalf("RefreshDownloadAuth failed: %v", err)
+	}
+
+	task := waitForStatus("completed")
+	if task.Cookies != "session=abc" {
+		t.Errorf("cookies = %q, want session=abc", task.Cookies)
+	}
+	diskHash, err := calculateMD5(task.SavePath)
+	if err != nil {
+		t.Fatalf("MD5 check failed: %v", err)
+	}
+	if diskHash != hex.EncodeToString(expected[:]) {
+		t.Error("content mismatch after auth refresh")
+	}
+}
+
+func TestPinnedConnectionsRespected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(4 * 1024 * 1024)
+	expected := md5.Sum(content)
+
+	var inflight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if r.Method == "GET" && rng != "" && rng != "bytes=0-0" {
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "pinned.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetDownloadTuning(24, 512*1024)
+
+	// Make the congestion controller eager to use many connections
+	host := "127.0.0.1"
+	for i := 0; i < 200; i++ {
+		engine.congestion.RecordOutcome(host, time.Millisecond, nil)
+		engine.congestion.GetIdealConcurrency(host)
+	}
+
+	id, err := engine.StartDownload(server.URL+"/pinned.bin", t.TempDir(), "pinned.bin", map[string]string{
+		"connections": "2",
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(20 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		if task.Status == "completed" {
+			if task.Connections != 2 {
+				t.Errorf("stored connections = %d, want 2", task.Connections)
+			}
+			diskHash, err := calculateMD5(task.SavePath)
+			if err != nil {
+				t.Fatalf("MD5 check failed: %v", err)
+			}
+			if diskHash != hex.EncodeToString(expected[:]) {
+				t.Error("content mismatch")
+			}
+			break
+		}
+		if task.Status == "error" {
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak concurrent range requests = %d, want 2", p)
+	}
+}
+
+func TestHostConnectionBudgetAcrossDownloads(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(4 * 1024 * 1024)
+
+	var inflight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if r.Method == "GET" && rng != "" && rng != "bytes=0-0" {
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "budget.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetDownloadTuning(24, 512*1024)
+	engine.SetMaxConnectionsPerHost(3)
+
+	// Each download alone would use 4 connections (the congestion floor)
+	dir := t.TempDir()
+	var ids []string
+	for _, name := range []string{"one.bin", "two.bin"} {
+		id, err := engine.StartDownload(server.URL+"/"+name, dir, name, nil)
+		if err != nil {
+			t.Fatalf("StartDownload failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	deadline := time.After(30 * time.Second)
+	for _, id := range ids {
+		for {
+			task, _ := store.GetTask(id)
+			if task.Status == "completed" {
+				break
+			}
+			if task.Status == "error" {
+				t.Fatalf("download %s failed", id)
+			}
+			select {
+			case <-deadline:
+				t.Fatalf("timeout waiting for %s (status %q)", id, task.Status)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak concurrent connections to host = %d, want <= 3", p)
+	}
+	if n := engine.hostBudget.InUse("127.0.0.1"); n != 0 {
+		t.Errorf("budget still holds %d slots after completion", n)
+	}
+}
+
+func TestSpawnPacingSpacesFirstRequests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(4 * 1024 * 1024)
+
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if r.Method == "GET" && rng != "" && rng != "bytes=0-0" {
+			mu.Lock()
+			arrivals = append(arrivals, time.Now())
+			mu.Unlock()
+			// Hold the connection so each worker's first request is distinct
+			time.Sleep(400 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "paced.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetDownloadTuning(24, 512*1024)
+	engine.SetSpawnPacing(20*time.Millisecond, 100*time.Millisecond)
+
+	id, err := engine.StartDownload(server.URL+"/paced.bin", t.TempDir(), "paced.bin", map[string]string{
+		"connections": "4",
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(30 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		if task.Status == "completed" {
+			break
+		}
+		if task.Status == "error" {
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(arrivals) < 4 {
+		t.Fatalf("expected at least 4 range requests, got %d", len(arrivals))
+	}
+	// The first four requests come from the four workers' initial spawns and
+	// should be roughly one ramp step (100ms) apart, not simultaneous.
+	for i := 1; i < 4; i++ {
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < 60*time.Millisecond {
+			t.Errorf("gap between spawn %d and %d = %v, want >= ~100ms", i-1, i, gap)
+		}
+	}
+}
+
+func TestTempDownloadDirMovesOnCompletion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(3 * 1024 * 1024)
+	expected := md5.Sum(content)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "staged.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetDownloadTuning(4, 512*1024)
+
+	tempRoot := t.TempDir()
+	finalDir := t.TempDir()
+	if err := engine.SetTempDownloadDir(tempRoot); err != nil {
+		t.Fatalf("SetTempDownloadDir failed: %v", err)
+	}
+
+	var checkedMu sync.Mutex
+	checked := make(map[string]int64) // dir -> bytes required
+	engine.diskSpaceCheck = func(path string, required int64) error {
+		checkedMu.Lock()
+		checked[filepath.Dir(path)] = required
+		checkedMu.Unlock()
+		return nil
+	}
+
+	id, err := engine.StartDownload(server.URL+"/staged.bin", finalDir, "staged.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(20 * time.Second)
+	var task storage.DownloadTask
+	for {
+		task, _ = store.GetTask(id)
+		if task.Status == "completed" {
+			break
+		}
+		if task.Status == "error" {
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if !strings.HasPrefix(task.SavePath, finalDir) {
+		t.Fatalf("SavePath %q is not under the final dir %q", task.SavePath, finalDir)
+	}
+	diskHash, err := calculateMD5(task.SavePath)
+	if err != nil {
+		t.Fatalf("MD5 check failed: %v", err)
+	}
+	if diskHash != hex.EncodeToString(expected[:]) {
+		t.Error("content mismatch")
+	}
+
+	if _, err := os.Stat(filepath.Join(finalDir, ".tachyon_parts")); !os.IsNotExist(err) {
+		t.Error("part files were written beside the final download")
+	}
+	filepath.Walk(tempRoot, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			t.Errorf("leftover file in temp dir: %s", path)
+		}
+		return nil
+	})
+
+	checkedMu.Lock()
+	defer checkedMu.Unlock()
+	partsDir := filepath.Join(tempRoot, ".tachyon_parts")
+	var sawFinal bool
+	for dir := range checked {
+		sawFinal = sawFinal || strings.HasPrefix(dir, finalDir)
+	}
+	if _, ok := checked[partsDir]; !ok || !sawFinal {
+		t.Errorf("disk space checked in %v, want both temp and final locations", checked)
+	}
+	// The parts plus the staging copy they are merged into
+	if got, want := checked[partsDir], int64(2*len(content)); got != want {
+		t.Errorf("temp directory checked for %d bytes, want %d", got, want)
+	}
+}
+
+// waitTransferred waits for a finished download to leave the active set,
+// which is when its BytesTransferred is flushed, and returns the task.
+func waitTransferred(t *testing.T, engine *TachyonEngine, store *storage.Storage, id string) storage.DownloadTask {
+	t.Helper()
+	deadline := time.After(20 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		if task.Status == storage.StatusError {
+			t.Fatal("download failed")
+		}
+		if _, active := engine.activeDownloads.Load(id); task.Status == storage.StatusCompleted && !active {
+			return task
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestBytesTransferredCountsRetries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	size := 3 * 1024 * 1024
+	content := generateDummyContent(size)
+	server := spawnRangeServer(t, content, 4)
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetDownloadTuning(4, 512*1024)
+
+	id, err := engine.StartDownload(server.URL+"/retry.bin", t.TempDir(), "retry.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitTransferred(t, engine, store, id)
+
+	if task.Downloaded != int64(size) {
+		t.Errorf("Downloaded = %d, want %d", task.Downloaded, size)
+	}
+	if task.BytesTransferred < task.TotalSize {
+		t.Errorf("BytesTransferred = %d, want at least TotalSize %d", task.BytesTransferred, task.TotalSize)
+	}
+}
+
+func TestBytesTransferredIncludesAbortedParts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	size := 2 * 1024 * 1024
+	content := generateDummyContent(size)
+
+	// The first ranged body is cut off half-way; the worker has to fetch
+	// that part again.
+	var cut atomic.Bool
+	const sent = 256 * 1024
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if r.Method == "GET" && rng != "" && rng != "bytes=0-0" && cut.CompareAndSwap(false, true) {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end-start+1 > 2*sent {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+				w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[start : start+sent])
+				w.(http.Flusher).Flush()
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			cut.Store(false)
+		}
+		http.ServeContent(w, r, "cut.bin", time.Time{}, strings.NewReader(string(content)))
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := createTempDB(t)
+	engine := NewEngine(logger, store)
+	engine.allowLoopback = true
+	engine.SetDownloadTuning(2, 1024*1024)
+
+	id, err := engine.StartDownload(server.URL+"/cut.bin", t.TempDir(), "cut.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitTransferred(t, engine, store, id)
+
+	if !cut.Load() {
+		t.Fatal("server never cut a response short")
+	}
+	if task.Downloaded != int64(size) {
+		t.Errorf("Downloaded = %d, want %d", task.Downloaded, size)
+	}
+	if task.BytesTransferred < int64(size+sent) {
+		t.Errorf("BytesTransferred = %d, want at least %d (file plus the aborted %d bytes)", task.BytesTransferred, size+sent, sent)
+	}
+}
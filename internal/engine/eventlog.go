@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultEventLogSize is how many engine events are kept in memory.
+const DefaultEventLogSize = 500
+
+// Engine event kinds recorded in the event log.
+const (
+	EventDownloadStarted   = "download.started"
+	EventDownloadCompleted = "download.completed"
+	EventDownloadFailed    = "download.failed"
+	EventDownloadPartial   = "download.partial" // stopped at its byte cap
+	EventDownloadPromoted  = "scheduler.promoted"
+	EventDownloadPreempted = "scheduler.preempted"
+	EventConcurrencyScaled = "scheduler.autoscaled" // downloads allowed at once changed
+	EventWorkersScaled     = "congestion.workers"   // a download's connection count changed
+	EventIntegrityFailed   = "download.integrity_failed"
+)
+
+// EngineEvent is one entry of the engine's recent activity log. Unlike the
+// audit log, which records access to the control API, it records what the
+// engine itself did.
+type EngineEvent struct {
+	Time    string `json:"time"` // RFC 3339
+	Kind    string `json:"kind"`
+	TaskID  string `json:"task_id,omitempty"`
+	Message string `json:"message"`
+}
+
+// eventLog is a fixed-size ring of the most recent engine events. Its
+// methods are no-ops on nil, so engines built without one need no checks.
+type eventLog struct {
+	mu   sync.Mutex
+	buf  []EngineEvent
+	next int  // slot the next event is written to
+	full bool // buf has wrapped at least once
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{buf: make([]EngineEvent, size)}
+}
+
+// add records ev, overwriting the oldest event once the log is full.
+func (l *eventLog) add(ev EngineEvent) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.buf[l.next] = ev
+	l.next++
+	if l.next == len(l.buf) {
+		l.next = 0
+		l.full = true
+	}
+	l.mu.Unlock()
+}
+
+// recent returns up to n events, newest first. n <= 0 returns them all.
+func (l *eventLog) recent(n int) []EngineEvent {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	count := l.next
+	if l.full {
+		count = len(l.buf)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	out := make([]EngineEvent, n)
+	for i := range out {
+		out[i] = l.buf[(l.next-1-i+len(l.buf))%len(l.buf)]
+	}
+	return out
+}
+
+// recordEvent adds an event to the engine's activity log.
+func (e *TachyonEngine) recordEvent(kind, taskID, format string, args ...interface{}) {
+	e.events.add(EngineEvent{
+		Time:    time.Now().Format(time.RFC3339),
+		Kind:    kind,
+		TaskID:  taskID,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// GetEventLog returns the n most recent engine events, newest first, for
+// activity views and support diagnostics. n <= 0 returns every event kept.
+func (e *TachyonEngine) GetEventLog(n int) []EngineEvent {
+	return e.events.recent(n)
+}
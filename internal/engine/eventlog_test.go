@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestEventLog_CapsAndOrders(t *testing.T) {
+	l := newEventLog(3)
+	if got := l.recent(0); len(got) != 0 {
+		t.Fatalf("empty log returned %v", got)
+	}
+	for i := 1; i <= 5; i++ {
+		l.add(EngineEvent{Message: fmt.Sprint(i)})
+	}
+
+	got := l.recent(0)
+	if len(got) != 3 {
+		t.Fatalf("log holds %d events, want 3", len(got))
+	}
+	for i, want := range []string{"5", "4", "3"} {
+		if got[i].Message != want {
+			t.Errorf("event %d = %q, want %q (newest first)", i, got[i].Message, want)
+		}
+	}
+	if got := l.recent(2); len(got) != 2 || got[0].Message != "5" {
+		t.Errorf("recent(2) = %v", got)
+	}
+	if got := l.recent(10); len(got) != 3 {
+		t.Errorf("recent(10) returned %d events, want 3", len(got))
+	}
+
+	var nilLog *eventLog
+	nilLog.add(EngineEvent{})
+	if nilLog.recent(1) != nil {
+		t.Error("nil log returned events")
+	}
+}
+
+func TestEventLog_ConcurrentAdds(t *testing.T) {
+	l := newEventLog(50)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.add(EngineEvent{Kind: "k"})
+				l.recent(5)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := l.recent(0); len(got) != 50 {
+		t.Errorf("log holds %d events, want 50", len(got))
+	}
+}
+
+func TestEventLog_RecordsDownloadLifecycle(t *testing.T) {
+	engine, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+
+	id, err := engine.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+	engine.failTask(&storage.DownloadTask{ID: "broken"}, "disk full")
+
+	kinds := make(map[string]string)
+	for _, ev := range engine.GetEventLog(0) {
+		if ev.Time == "" || ev.Message == "" {
+			t.Errorf("incomplete event %+v", ev)
+		}
+		if _, seen := kinds[ev.Kind+ev.TaskID]; !seen {
+			kinds[ev.Kind+ev.TaskID] = ev.Message
+		}
+	}
+	for _, key := range []string{EventDownloadStarted + id, EventDownloadCompleted + id, EventDownloadFailed + "broken"} {
+		if _, ok := kinds[key]; !ok {
+			t.Errorf("missing event %q in %v", key, kinds)
+		}
+	}
+	if msg := kinds[EventDownloadFailed+"broken"]; msg != "Failed: disk full" {
+		t.Errorf("failure message = %q", msg)
+	}
+	if got := engine.GetEventLog(1); len(got) != 1 || got[0].Kind != EventDownloadFailed {
+		t.Errorf("newest event = %v, want the failure", got)
+	}
+}
@@ -11,24 +11,90 @@ import (
 	"sync/atomic"
 	"time"
 
+	"project-tachyon/internal/platform"
 	"project-tachyon/internal/storage"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// Cancellation causes for a download's context. A pause and a failure can
+// race; whichever cancels first decides the one terminal event sent.
+var (
+	errDownloadPaused = errors.New("download paused")
+	errDownloadEnded  = errors.New("download ended")
+)
+
 // activeDownloadInfo stores control structures for a running download
 type activeDownloadInfo struct {
-	Cancel context.CancelFunc
-	Wait   *sync.WaitGroup
+	Cancel    context.CancelFunc // pauses the download
+	Wait      *sync.WaitGroup
+	Priority  int
+	StartedAt time.Time
+	SetID     string // download set the task belongs to, if any
+
+	// Transferred counts every body byte received for the task, including
+	// data thrown away by retries; it starts at the stored BytesTransferred.
+	Transferred atomic.Int64
+
+	// Speed is the latest smoothed transfer rate in bytes/sec.
+	Speed atomic.Int64
+
+	// Health is the latest 0-100 health score (see healthScore).
+	Health atomic.Int32
+
+	// downloaded points at the executor's byte counter once the transfer
+	// starts, so GetLiveProgress sees bytes as they land.
+	downloaded atomic.Pointer[int64]
+	// totalSize is the task's size in bytes as of that point (0 = unknown).
+	totalSize atomic.Int64
+
+	// checkpoint is the latest resume state, kept current so EmergencyStop
+	// can persist it without waiting for the executor to unwind.
+	checkpoint atomic.Pointer[storage.TaskCheckpoint]
+
+	// connCh passes SetDownloadConnections changes to the executor; it holds
+	// only the latest one.
+	connCh chan int
+	// singleStream is set once the download runs over a single connection
+	// that cannot be split, so its connection count cannot change.
+	singleStream atomic.Bool
 }
 
-// queueWorker is the background worker that dispatches tasks from the queue
+// pausedWhileProbing records a download paused before it fetched any
+// data. Without it the task would keep its queued status while no longer
+// being in the queue, and could not be resumed.
+func (e *TachyonEngine) pausedWhileProbing(ctx context.Context, task *storage.DownloadTask) {
+	if context.Cause(ctx) != errDownloadPaused {
+		return
+	}
+	if !e.savePaused(task, func(t *storage.DownloadTask) { t.Speed = 0 }) {
+		return
+	}
+	e.logger.Info("Download paused while probing", "id", task.ID)
+	e.emit("download:paused", map[string]interface{}{
+		"id":         task.ID,
+		"downloaded": task.Downloaded,
+		"progress":   task.Progress,
+		"total":      task.TotalSize,
+	})
+}
+
+// queueWorker is the background worker that dispatches tasks from the queue.
+// It returns once the queue is closed by Shutdown.
 func (e *TachyonEngine) queueWorker() {
+	defer close(e.workerDone)
 	for {
+		if e.queue.Closed() {
+			return
+		}
+
 		e.workerMutex.Lock()
 		active := e.runningDownloads
 		max := e.maxConcurrent
 		e.workerMutex.Unlock()
+		if e.dispatchHeld() {
+			max = 0 // no free slot until verification and scanning are done
+		}
 
 		task := e.scheduler.GetNextTask(active, max)
 
@@ -38,6 +104,11 @@ func (e *TachyonEngine) queueWorker() {
 			e.queue.WaitTimeout(10 * time.Second)
 			continue
 		}
+		if e.shuttingDown.Load() || e.queue.Closed() {
+			// Shutting down: leave the task queued for the next start
+			e.queue.Push(task)
+			return
+		}
 
 		e.workerMutex.Lock()
 		e.runningDownloads++
@@ -58,7 +129,19 @@ func (e *TachyonEngine) queueWorker() {
 
 				e.scheduler.OnTaskCompleted(t)
 			}()
+			// t may be queued again, and owned by its next run, once
+			// executeTask returns; read the outcome from the database.
+			id := t.ID
 			e.executeTask(t)
+			if cur, err := e.storage.GetTask(id); err == nil && cur.Status == StatusNeedsAuth {
+				go e.autoRefreshAuth(cur)
+			}
+
+			// Re-queue a preempted task while its slot is still held so the
+			// promoted download is dispatched first.
+			if promotedID, ok := e.preempted.LoadAndDelete(id); ok {
+				e.requeuePreempted(id, promotedID.(string))
+			}
 		}(task)
 	}
 }
@@ -73,15 +156,64 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 	if parentCtx == nil {
 		parentCtx = context.Background()
 	}
-	ctx, cancel := context.WithCancel(parentCtx)
-	e.activeDownloads.Store(task.ID, &activeDownloadInfo{
-		Cancel: cancel,
-		Wait:   &sync.WaitGroup{},
-	})
-	defer e.activeDownloads.Delete(task.ID)
+	ctx, cancelCause := context.WithCancelCause(parentCtx)
+	cancel := func() { cancelCause(errDownloadEnded) }
+	// claimEnd stops the download on the executor's behalf. It returns false
+	// if a pause got in first, in which case the pause is reported instead.
+	claimEnd := func() bool {
+		cancelCause(errDownloadEnded)
+		return context.Cause(ctx) == errDownloadEnded
+	}
+	ctx = e.startDebugLog(ctx, task)
+	info := &activeDownloadInfo{
+		Cancel:    func() { cancelCause(errDownloadPaused) },
+		Wait:      &sync.WaitGroup{},
+		Priority:  task.Priority,
+		StartedAt: startedAt,
+		SetID:     task.SetID,
+		connCh:    make(chan int, 1),
+	}
+	info.Transferred.Store(task.BytesTransferred)
+	e.activeDownloads.Store(task.ID, info)
+	// Only this run's entry: a download retried after a failed integrity
+	// check may already be running again.
+	defer e.activeDownloads.CompareAndDelete(task.ID, info)
+	// Checked after registering, so Shutdown either sees this download and
+	// cancels it or this sees Shutdown and backs out.
+	if e.shuttingDown.Load() {
+		e.logger.Info("Not starting download during shutdown", "id", task.ID)
+		e.queue.Push(task)
+		cancel()
+		return
+	}
+	// A task pushed back onto the queue belongs to its next run; see
+	// requeued below.
+	requeued := false
+	defer func() {
+		if !requeued {
+			e.saveTransferred(task, info)
+		}
+	}()
+	e.recordEvent(EventDownloadStarted, task.ID, "Started %s", task.URL)
+
+	// With every saved cookie expired the server would only answer 403
+	// part-way through; ask for fresh credentials up front instead.
+	if cookiesExpired(task.Cookies, time.Now()) {
+		e.logger.Warn("Saved cookies expired - pausing for new credentials", "id", task.ID)
+		e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+			t.Status = StatusNeedsAuth
+		})
+		task.Status = StatusNeedsAuth
+		cancel()
+		e.emit("download:needs_auth", map[string]interface{}{
+			"id":     task.ID,
+			"reason": "Saved cookies have expired",
+		})
+		return
+	}
 
 	// 2. Probe & Validate
-	task.Status = "probing"
+	task.Status = storage.StatusProbing
 	if e.ctx != nil {
 		runtime.EventsEmit(e.ctx, "download:progress", map[string]interface{}{
 			"id":       task.ID,
@@ -98,6 +230,7 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 	// time-limited, multiple probe requests waste the token and trigger rate
 	// limiting.  Use size from clen param or the extension's size hint.
 	var probe *ProbeResult
+	var probedAt time.Time
 	if strings.HasSuffix(host, "googlevideo.com") {
 		size := task.TotalSize // pre-seeded from extension size_hint
 		if size <= 0 {
@@ -110,13 +243,20 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 			Filename:     task.Filename,
 		}
 		e.logger.Info(fmt.Sprintf("YouTube direct download — skipping probe (size=%d)", size), "id", task.ID)
+	} else if probe, probedAt = e.resumeProbe(task); probe != nil {
+		e.logger.Info("Reusing recent probe from resume state", "id", task.ID, "age", time.Since(probedAt).Round(time.Second))
 	} else {
 		var err error
-		probe, err = e.ProbeURL(task.URL, task.Headers, task.Cookies)
+		probe, err = e.probeWithRetry(ctx, task)
 		if err != nil {
+			if ctx.Err() != nil {
+				e.pausedWhileProbing(ctx, task)
+				return
+			}
 			e.failTask(task, fmt.Sprintf("Probe failed: %v", err))
 			return
 		}
+		probedAt = time.Now()
 		e.logger.Info(fmt.Sprintf("Probe result: size=%d ranges=%v status=%d", probe.Size, probe.AcceptRanges, probe.Status), "id", task.ID)
 		if probe.Size > 0 {
 			task.TotalSize = probe.Size
@@ -126,21 +266,69 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 		}
 	}
 
+	// A hosting page rather than a file: switch to the best link on it
+	retargeted, err := e.resolvePageLink(ctx, task, probe)
+	if err != nil {
+		if ctx.Err() != nil {
+			e.pausedWhileProbing(ctx, task)
+			return
+		}
+		e.failTask(task, fmt.Sprintf("Probe failed: %v", err))
+		return
+	}
+	if retargeted != nil {
+		probe = retargeted
+		probedAt = time.Now()
+		task.TotalSize = probe.Size
+		if u, err := url.Parse(task.URL); err == nil {
+			host = u.Hostname()
+		}
+	}
+	// Remember what this run taught the congestion controller about the host
+	defer func() { e.saveHostProfile(host) }()
+
 	if e.isHostSingleStream(host) {
 		probe.AcceptRanges = false
 	}
 
+	if isHLSPlaylist(task.URL, probe.ContentType) {
+		e.executeHLS(ctx, task, info, host, probe.IsHTTP2, startedAt)
+		cancel()
+		return
+	}
+
+	adoptedHash := false
+	if task.ExpectedHash == "" && probe.Hash != "" {
+		task.ExpectedHash, task.HashAlgorithm = probe.Hash, probe.HashAlgorithm
+		adoptedHash = true
+		e.logger.Info("Using server-provided checksum", "id", task.ID, "algorithm", probe.HashAlgorithm)
+	}
+
+	if e.applyContentTypeExtension(task, probe) || retargeted != nil || adoptedHash {
+		e.storage.SaveTask(*task)
+	}
+
 	isH2 := probe.IsHTTP2
 
 	// 3. Prepare temp directory for part files
-	tempDir := tempDirForTask(task.SavePath)
+	tempDir := e.partsDirForTask(task.ID, task.SavePath)
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
 		e.failTask(task, fmt.Sprintf("Failed to create temp dir: %v", err))
 		return
 	}
 
 	// 4. Job Producer (Generate Parts)
-	parts := e.planDownloadParts(probe.Size, probe.AcceptRanges)
+	capped := task.MaxBytes > 0 && (probe.Size <= 0 || probe.Size > task.MaxBytes)
+	var parts []DownloadPart
+	if capped {
+		parts = e.planCappedParts(task.MaxBytes, probe.Size, probe.AcceptRanges)
+		if probe.Size > 0 {
+			task.TotalSize = task.MaxBytes
+		}
+		e.logger.Info("Download capped", "id", task.ID, "max_bytes", task.MaxBytes, "size", probe.Size)
+	} else {
+		parts = e.planDownloadParts(probe.Size, probe.AcceptRanges)
+	}
 	numParts := len(parts)
 	if !probe.AcceptRanges {
 		e.logger.Info("Server does not support ranges, switching to single-threaded mode", "id", task.ID)
@@ -185,18 +373,31 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 			if !ok {
 				continue
 			}
-			expectedSize := part.EndOffset - part.StartOffset + 1
+			// The plan changes shape when the part size settings do; a part
+			// saved with other offsets is a different range under the same ID
+			if ps.End > 0 && (ps.Start != part.StartOffset || ps.End != part.EndOffset) {
+				continue
+			}
+			// Trust the saved state only as far as the disk backs it: a part
+			// file cut short (e.g. by a crash before the data was flushed)
+			// or deleted is fetched again.
+			size := partFileSize(tempDir, task.ID, part.StartOffset)
 			if part.EndOffset == StreamEndOffset {
-				// Can't validate size for streaming parts
-				completedParts[id] = true
-			} else if partFileExists(tempDir, task.ID, part.StartOffset, expectedSize) {
+				// A stream's size is unknown; any data left has to do
+				if size > 0 {
+					completedParts[id] = true
+				}
+			} else if expectedSize := part.EndOffset - part.StartOffset + 1; size == expectedSize {
 				completedParts[id] = true
+			} else {
+				e.logger.Warn("Completed part is short on disk, fetching it again", "id", task.ID, "part", id, "size", size, "want", expectedSize)
 			}
 		}
 	}
 
-	// Clean up orphaned part files (e.g. from work-stealing in a previous run)
-	// that don't match any planned part offset.
+	// Clean up orphaned part files (e.g. from work-stealing in a previous run,
+	// or a tail the plan has since merged) that don't match any planned part
+	// offset.
 	cleanupOrphanedParts(tempDir, task.ID, plannedOffsets)
 
 	// Channels
@@ -209,11 +410,16 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 	var nextStealID atomic.Int32
 	nextStealID.Store(int32(numParts))
 
+	// Pick the parts to fetch here: completedParts is written by the
+	// main loop while the producer runs.
+	var pending []DownloadPart
+	for _, part := range parts {
+		if !completedParts[part.ID] {
+			pending = append(pending, part)
+		}
+	}
 	go func() {
-		for _, part := range parts {
-			if completedParts[part.ID] {
-				continue
-			}
+		for _, part := range pending {
 			partCh <- part
 		}
 		close(partCh)
@@ -239,23 +445,69 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 	}
 
 	var downloadedBytes int64 = initialBytes
+	info.totalSize.Store(task.TotalSize)
+	info.downloaded.Store(&downloadedBytes)
 
-	workerCount := e.selectWorkerCountH2(host, numParts, probe.AcceptRanges, isH2)
+	// Fail early when the target volume cannot hold the rest of the file;
+	// the user can then move the download with RelocateAndResume.
+	if task.TotalSize > 0 && e.diskSpaceCheck != nil {
+		var err error
+		if tempDir == tempDirForTask(task.SavePath) {
+			err = e.diskSpaceCheck(task.SavePath, task.TotalSize-initialBytes)
+		} else {
+			err = e.checkTempAndFinalSpace(tempDir, task, task.TotalSize-initialBytes)
+		}
+		if err != nil {
+			e.failTask(task, fmt.Sprintf("Not enough disk space: %v", err))
+			cancel()
+			return
+		}
+	}
+
+	// The connection ceiling is fixed when the download starts; changing the
+	// setting only affects downloads started afterwards.
+	ceiling := e.GetMaxConnectionsPerDownload()
+	workerCount := min(e.workerCountForTask(task, host, numParts, probe.AcceptRanges, isH2), ceiling)
 	strictRanges := probe.AcceptRanges && workerCount > 1
 
 	if workerCount > 1 {
 		go e.WarmUpHost(host, workerCount/2)
 	}
 
-	var activeWorkers atomic.Int32
-	activeWorkers.Store(int32(workerCount))
-	for i := 0; i < workerCount; i++ {
+	scale := &workerScale{}
+	scale.target.Store(int32(workerCount))
+	info.singleStream.Store(!strictRanges)
+	spawnWorker := func(delay time.Duration) {
+		scale.running.Add(1)
 		wg.Add(1)
 		e.workerPool.Submit(func() {
 			defer wg.Done()
-			e.downloadWorker(ctx, task.ID, task.URL, host, tempDir, partCh, retryCh, partDoneCh, errCh, &downloadedBytes, &errorCount, task.Headers, task.Cookies, strictRanges, inflight, &nextStealID)
+			if !sleepCtx(ctx, delay) {
+				scale.running.Add(-1)
+				return
+			}
+			e.downloadWorker(ctx, task.ID, task.URL, host, tempDir, partCh, retryCh, partDoneCh, errCh, &downloadedBytes, &errorCount, task.Headers, task.Cookies, strictRanges, inflight, &nextStealID, scale)
 		})
 	}
+	for i := 0; i < workerCount; i++ {
+		spawnWorker(e.spawnDelay(host, i))
+	}
+	// resize sets the worker target, spawning workers up to it; surplus
+	// workers retire as they finish their current part.
+	resize := func(target int32) {
+		current := scale.target.Swap(target)
+		toSpawn := target - scale.running.Load()
+		for i := int32(0); i < toSpawn; i++ {
+			spawnWorker(e.spawnDelay(host, int(i)))
+		}
+		if target > current {
+			e.logger.Info("Scaled up workers", "id", task.ID, "from", current, "to", target)
+			e.recordEvent(EventWorkersScaled, task.ID, "Connections raised from %d to %d", current, target)
+		} else if target < current {
+			e.logger.Info("Scaled down workers target", "id", task.ID, "from", current, "to", target)
+			e.recordEvent(EventWorkersScaled, task.ID, "Connections lowered from %d to %d", current, target)
+		}
+	}
 
 	// 6. Monitor Progress
 	doneCh := make(chan struct{})
@@ -267,16 +519,24 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	scaleTicker := time.NewTicker(5 * time.Second)
+	growth, scaleEvery := e.GetWorkerGrowthPolicy()
+	scaleTicker := time.NewTicker(scaleEvery)
 	defer scaleTicker.Stop()
 
 	var lastDownloadedBytes int64 = atomic.LoadInt64(&downloadedBytes)
 	lastTick := time.Now()
 	var ewmaSpeed float64
 	var tickCount int
+	var health healthTracker
+
+	// Whole-download stall tracking (all workers making no progress)
+	lastProgressAt := time.Now()
+	lastProgressBytes := lastDownloadedBytes
+	stallWarned := false
+	pauseReason := ""
 
 	// Initial Status Update — save once at start
-	task.Status = "downloading"
+	task.Status = storage.StatusDownloading
 	e.storage.SaveTask(*task)
 
 	if e.ctx != nil {
@@ -290,46 +550,100 @@ func (e *TachyonEngine) executeTask(task *storage.DownloadTask) {
 			"category":      task.Category,
 			"started_at":    startedAt.Format(time.RFC3339),
 			"path":          task.SavePath,
+			"connections":   workerCount,
+			"pinned":        task.Connections > 0,
 		})
 	}
 
+	// checkpoint publishes the resume state for EmergencyStop; meta is
+	// re-serialized only when a part completes.
+	var lastMeta string
+	checkpoint := func(refreshMeta bool) {
+		if refreshMeta {
+			lastMeta = e.serializeState(task, probe, probedAt, completedParts, partPlan)
+		}
+		downloaded := atomic.LoadInt64(&downloadedBytes)
+		var progress float64
+		if task.TotalSize > 0 {
+			progress = (float64(downloaded) / float64(task.TotalSize)) * 100
+		}
+		info.checkpoint.Store(&storage.TaskCheckpoint{
+			ID:         task.ID,
+			MetaJSON:   lastMeta,
+			Downloaded: downloaded,
+			Progress:   progress,
+		})
+	}
+	checkpoint(true)
+
+	// stopWorkers waits for the cancelled workers to return, recording parts
+	// they finish meanwhile, so every part file is flushed and closed before
+	// the download is reported paused and can be resumed over them.
+	stopWorkers := func() {
+		for {
+			select {
+			case <-doneCh:
+				return
+			case id := <-partDoneCh:
+				completedParts[id] = true
+			case <-errCh:
+			}
+		}
+	}
+
+	// pause saves progress and reports the download as paused
+	pause := func() {
+		stopWorkers()
+		metaSnap := e.serializeState(task, probe, probedAt, completedParts, partPlan)
+		downloaded := atomic.LoadInt64(&downloadedBytes)
+		var progress float64
+		if task.TotalSize > 0 {
+			progress = (float64(downloaded) / float64(task.TotalSize)) * 100
+		}
+		paused := e.savePaused(task, func(t *storage.DownloadTask) {
+			t.MetaJSON = metaSnap
+			t.Downloaded = downloaded
+			t.Progress = progress
+			t.Speed = 0
+		})
+		task.Progress = progress
+		if !paused {
+			return
+		}
+		e.logger.Info("Download Cancelled/Paused", "id", task.ID, "reason", pauseReason)
+		pausedEvent := map[string]interface{}{
+			"id":         task.ID,
+			"downloaded": downloaded,
+			"progress":   progress,
+			"total":      task.TotalSize,
+		}
+		if pauseReason != "" {
+			pausedEvent["reason"] = pauseReason
+		}
+		e.emit("download:paused", pausedEvent)
+	}
+
+	// A resume can find every part already on disk, in which case no part
+	// will report done: go straight to merging.
+	allOnDisk := countDoneParts(completedParts, numParts) == numParts
 Loop:
-	for {
+	for !allOnDisk {
 		select {
 		case <-ctx.Done():
-			metaSnap := e.serializeState(task, completedParts, partPlan)
-			downloaded := atomic.LoadInt64(&downloadedBytes)
-			var progress float64
-			if task.TotalSize > 0 {
-				progress = (float64(downloaded) / float64(task.TotalSize)) * 100
-			}
-			e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
-				t.Status = "paused"
-				t.MetaJSON = metaSnap
-				t.Downloaded = downloaded
-				t.Progress = progress
-				t.Speed = 0
-			})
-			task.Status = "paused"
-			task.Progress = progress
-			e.logger.Info("Download Cancelled/Paused", "id", task.ID)
-			if e.ctx != nil {
-				runtime.EventsEmit(e.ctx, "download:paused", map[string]interface{}{
-					"id":         task.ID,
-					"downloaded": downloaded,
-					"progress":   progress,
-					"total":      task.TotalSize,
-				})
-			}
+			pause()
 			break Loop
 
 		case err := <-errCh:
+			if !claimEnd() {
+				pause()
+				break Loop
+			}
 			if errors.Is(err, ErrRangeIgnored) {
 				e.logger.Warn("Range ignored by host, downgrading to single-stream mode", "id", task.ID, "host", host)
 				e.markHostSingleStream(host)
 				cleanupPartFiles(tempDir, task.ID)
 				if saveErr := e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
-					t.Status = "pending"
+					t.Status = storage.StatusPending
 					t.MetaJSON = ""
 					t.Progress = 0
 					t.Downloaded = 0
@@ -340,12 +654,16 @@ Loop:
 					cancel()
 					return
 				}
-				task.Status = "pending"
+				task.Status = storage.StatusPending
 				task.MetaJSON = ""
 				task.Progress = 0
 				task.Downloaded = 0
 				task.Speed = 0
 				task.TimeRemaining = ""
+				// Persist the count first: once queued, the next run reads
+				// the task concurrently.
+				e.saveTransferred(task, info)
+				requeued = true
 				e.queue.Push(task)
 				cancel()
 				return
@@ -353,36 +671,38 @@ Loop:
 
 			if errors.Is(err, ErrLinkExpired) {
 				e.logger.Warn("Link expired - pausing for URL refresh", "id", task.ID)
-				metaSnap := e.serializeState(task, completedParts, partPlan)
+				metaSnap := e.serializeState(task, probe, probedAt, completedParts, partPlan)
 				e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
 					t.Status = StatusNeedsAuth
 					t.MetaJSON = metaSnap
 				})
 				task.Status = StatusNeedsAuth
 				cancel()
-				if e.ctx != nil {
-					runtime.EventsEmit(e.ctx, "download:needs_auth", map[string]interface{}{
-						"id":     task.ID,
-						"reason": "Link expired (HTTP 403)",
-					})
-				}
+				e.emit("download:needs_auth", map[string]interface{}{
+					"id":     task.ID,
+					"reason": "Link expired (HTTP 403)",
+				})
 				return
 			}
 
 			if errors.Is(err, ErrStallTimeout) {
-				metaSnap := e.serializeState(task, completedParts, partPlan)
+				metaSnap := e.serializeState(task, probe, probedAt, completedParts, partPlan)
 				e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
-					t.Status = "error"
+					t.Status = storage.StatusError
 					t.MetaJSON = metaSnap
 				})
-				e.failTask(task, "Download timed out: server not responding for 30 seconds")
+				e.failTask(task, "Download timed out: server stopped sending data")
+				cancel()
+				e.emit("download:timeout", map[string]interface{}{
+					"id":     task.ID,
+					"reason": "Server stopped sending data",
+				})
+				return
+			}
+
+			if errors.Is(err, ErrRemoteFileChanged) {
+				e.failTask(task, fmt.Sprintf("The file changed on the server since the download started (%v); download it again", err))
 				cancel()
-				if e.ctx != nil {
-					runtime.EventsEmit(e.ctx, "download:timeout", map[string]interface{}{
-						"id":     task.ID,
-						"reason": "Server not responding for 30 seconds",
-					})
-				}
 				return
 			}
 
@@ -392,15 +712,10 @@ Loop:
 
 		case id := <-partDoneCh:
 			completedParts[id] = true
+			checkpoint(true)
 			// Only count original parts (0..numParts-1) for completion.
 			// Stolen parts (id >= numParts) contribute data but don't replace originals.
-			originalDone := 0
-			for pid := range completedParts {
-				if pid < numParts {
-					originalDone++
-				}
-			}
-			if originalDone == numParts {
+			if countDoneParts(completedParts, numParts) == numParts {
 				break Loop
 			}
 
@@ -417,6 +732,7 @@ Loop:
 			if duration > 0 {
 				bytesDiff := current - lastDownloadedBytes
 				instantSpeed := float64(bytesDiff) / duration
+				e.bandwidthManager.Observe(bytesDiff)
 
 				if ewmaSpeed == 0 {
 					ewmaSpeed = instantSpeed
@@ -424,6 +740,7 @@ Loop:
 					ewmaSpeed = 0.7*ewmaSpeed + 0.3*instantSpeed
 				}
 				task.Speed = ewmaSpeed
+				info.Speed.Store(int64(ewmaSpeed))
 
 				e.stats.UpdateDownloadSpeed(int64(ewmaSpeed))
 
@@ -437,54 +754,102 @@ Loop:
 				}
 			}
 
+			signals := healthSignals{
+				Retries:   int(errorCount.Load()),
+				PartsDone: countDoneParts(completedParts, numParts),
+				Speed:     ewmaSpeed,
+				LineSpeed: e.lineCapacity.Load(),
+			}
+			if hs := e.congestion.GetHostStats(host); hs != nil {
+				signals.RTT = hs.SmoothedRTT
+			}
+			info.Health.Store(int32(health.update(signals)))
+			checkpoint(false)
+
+			// Whole-download stall detection: warn and back off first, then
+			// pause if the download still makes no progress.
+			if current > lastProgressBytes {
+				lastProgressBytes = current
+				lastProgressAt = now
+				stallWarned = false
+			} else {
+				stalledFor := now.Sub(lastProgressAt)
+				warnAfter, pauseAfter := e.GetStallThresholds()
+				if pauseAfter > 0 && stalledFor >= pauseAfter {
+					e.logger.Warn("Download stalled, pausing", "id", task.ID, "stalled_for", stalledFor)
+					pauseReason = fmt.Sprintf("No data received for %s", stalledFor.Round(time.Second))
+					info.Cancel()
+				} else if warnAfter > 0 && stalledFor >= warnAfter && !stallWarned {
+					stallWarned = true
+					e.logger.Warn("Download stalled, reducing concurrency", "id", task.ID, "stalled_for", stalledFor)
+					// Feed the stall to the AIMD controller so the next scale
+					// tick halves the host's concurrency; it re-grows once
+					// data flows again.
+					e.congestion.RecordOutcome(host, stalledFor, ErrStallTimeout)
+					e.emit("download:stalled", map[string]interface{}{
+						"id":          task.ID,
+						"stalled_for": int(stalledFor.Seconds()),
+						"downloaded":  current,
+					})
+				}
+			}
+
 			// Persist progress to DB every 5 seconds so abrupt-close recovery
 			// has recent Downloaded/Progress values.
 			tickCount++
 			if tickCount%5 == 0 {
 				progress := task.Progress
+				transferred := info.Transferred.Load()
 				e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
 					t.Downloaded = current
 					t.Progress = progress
+					t.BytesTransferred = transferred
 				})
 			}
 
 			if e.ctx != nil {
 				runtime.EventsEmit(e.ctx, "download:progress", map[string]interface{}{
-					"id":         task.ID,
-					"status":     task.Status,
-					"progress":   task.Progress,
-					"speed":      task.Speed,
-					"eta":        task.TimeRemaining,
-					"downloaded": task.Downloaded,
-					"total":      task.TotalSize,
+					"id":             task.ID,
+					"status":         task.Status,
+					"progress":       task.Progress,
+					"speed":          task.Speed,
+					"eta":            task.TimeRemaining,
+					"downloaded":     task.Downloaded,
+					"total":          task.TotalSize,
+					"transferred":    info.Transferred.Load(),
+					"connections":    scale.target.Load(),
+					"active_workers": scale.running.Load(),
+					"health":         info.Health.Load(),
 				})
 			}
 
+		case n := <-info.connCh:
+			// SetDownloadConnections: pin the new count, or hand the
+			// download back to the scaler below when n is 0
+			task.Connections = n
+			e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+				t.Connections = n
+			})
+			if strictRanges && n > 0 {
+				resize(int32(min(n, ceiling)))
+			}
+
 		case <-scaleTicker.C:
-			if strictRanges {
-				ideal := int32(e.selectWorkerCountH2(host, numParts-len(completedParts), true, isH2))
-				current := activeWorkers.Load()
+			// Pinned downloads keep the user's connection count
+			if strictRanges && task.Connections == 0 {
+				ideal := int32(min(e.selectWorkerCountH2(host, numParts-countDoneParts(completedParts, numParts), true, isH2), ceiling))
+				current := scale.target.Load()
 				if ideal > current {
-					toSpawn := ideal - current
-					activeWorkers.Store(ideal)
-					for i := int32(0); i < toSpawn; i++ {
-						wg.Add(1)
-						e.workerPool.Submit(func() {
-							defer wg.Done()
-							e.downloadWorker(ctx, task.ID, task.URL, host, tempDir, partCh, retryCh, partDoneCh, errCh, &downloadedBytes, &errorCount, task.Headers, task.Cookies, strictRanges, inflight, &nextStealID)
-						})
-					}
-					e.logger.Info("Scaled up workers", "id", task.ID, "from", current, "to", ideal)
+					resize(growWorkers(current, ideal, growth))
 				} else if ideal < current && ideal >= 1 {
-					activeWorkers.Store(ideal)
-					e.logger.Info("Scaled down workers target", "id", task.ID, "from", current, "to", ideal)
+					resize(ideal)
 				}
 			}
 		}
 	}
 
 	// 7. Merge & Verify
-	if len(completedParts) >= numParts {
+	if countDoneParts(completedParts, numParts) == numParts {
 		// Let remaining workers (stolen parts, retries) finish naturally so their
 		// part files are fully flushed.  Drain partDoneCh / errCh to prevent
 		// workers blocking on channel sends.
@@ -503,7 +868,7 @@ Loop:
 		close(drainDone)
 		cancel()
 
-		task.Status = "merging"
+		task.Status = storage.StatusMerging
 		if e.ctx != nil {
 			runtime.EventsEmit(e.ctx, "download:progress", map[string]interface{}{
 				"id":     task.ID,
@@ -512,15 +877,42 @@ Loop:
 		}
 
 		e.logger.Info("Merging part files", "id", task.ID, "parts", numParts)
-		if err := mergePartFiles(tempDir, task.ID, task.SavePath); err != nil {
+		if err := e.assembleParts(tempDir, task); err != nil {
 			e.failTask(task, fmt.Sprintf("Merge failed: %v", err))
 			return
 		}
+		// A 416 at the end of the file may have moved the end (see
+		// SetTreat416AsComplete)
+		if end := info.totalSize.Load(); end > 0 && end < task.TotalSize {
+			task.TotalSize = end
+		}
+		// Never report a truncated file as complete
+		if task.TotalSize > 0 {
+			if fi, err := os.Stat(task.SavePath); err != nil || fi.Size() != task.TotalSize {
+				var size int64
+				if err == nil {
+					size = fi.Size()
+				}
+				e.failTask(task, fmt.Sprintf("Merged file is %d bytes, expected %d", size, task.TotalSize))
+				return
+			}
+		}
 
 		// Clean up temp dir if empty
 		os.Remove(tempDir)
 
-		task.Status = "verifying"
+		// Cut off at the cap: the file is only the head of the remote one.
+		// A file of unknown size that ended first is simply complete.
+		if capped {
+			if fi, err := os.Stat(task.SavePath); err == nil && fi.Size() >= task.MaxBytes {
+				task.Downloaded = fi.Size()
+				task.TotalSize = fi.Size()
+				e.finishPartial(task, startedAt)
+				return
+			}
+		}
+
+		task.Status = storage.StatusVerifying
 		e.storage.SaveTask(*task)
 		if e.ctx != nil {
 			runtime.EventsEmit(e.ctx, "download:progress", map[string]interface{}{
@@ -529,22 +921,6 @@ Loop:
 			})
 		}
 
-		enabled := true
-		s, err := e.storage.GetString("enable_integrity_check")
-		if err == nil && s == "false" {
-			enabled = false
-		}
-
-		if enabled && task.ExpectedHash != "" {
-			e.logger.Info("Verifying integrity", "id", task.ID, "hash", task.ExpectedHash)
-			if err := e.verifier.Verify(task.SavePath, task.HashAlgorithm, task.ExpectedHash); err != nil {
-				e.failTask(task, fmt.Sprintf("Integrity Check Failed: %v", err))
-				corruptedPath := task.SavePath + ".corrupted"
-				os.Rename(task.SavePath, corruptedPath)
-				return
-			}
-		}
-
 		// Use actual downloaded bytes; fall back to TotalSize only for known-size downloads
 		actualDownloaded := atomic.LoadInt64(&downloadedBytes)
 		if actualDownloaded > 0 {
@@ -556,60 +932,86 @@ Loop:
 			task.Downloaded = task.TotalSize
 		}
 
-		task.Status = "completed"
-		task.Progress = 100
-		for attempt := 0; attempt < 3; attempt++ {
-			if err := e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
-				t.Status = "completed"
-				t.Progress = 100
-				t.Downloaded = task.Downloaded
-				t.TotalSize = task.TotalSize
-			}); err == nil {
-				break
-			} else if attempt < 2 {
-				time.Sleep(time.Duration(100*(attempt+1)) * time.Millisecond)
-			} else {
-				e.logger.Error("Failed to persist completion status", "id", task.ID, "error", err)
-			}
-		}
-		e.logger.Info("Download Completed", "id", task.ID)
+		// Verification and completion run in the verification pool so this
+		// download's slot goes to the next queued task now.
+		e.verifyAndComplete(task, info, startedAt, probe.LastModified)
+	}
+}
 
-		avEnabled := true
-		if av, err := e.storage.GetString("enable_av_scan"); err == nil && av == "false" {
-			avEnabled = false
-		}
-		if avEnabled {
-			if scanErr := e.scanner.ScanFile(ctx, task.SavePath); scanErr != nil {
-				e.logger.Warn("AV scan warning", "id", task.ID, "error", scanErr)
-				if e.ctx != nil {
-					runtime.EventsEmit(e.ctx, "download:av_warning", map[string]interface{}{
-						"id":      task.ID,
-						"path":    task.SavePath,
-						"warning": scanErr.Error(),
-					})
-				}
-			}
+// completeTask records a finished download: swaps it in over the file it
+// replaces (if any), persists the completed status, scans the file, updates
+// stats and notifies the frontend.
+func (e *TachyonEngine) completeTask(ctx context.Context, task *storage.DownloadTask, info *activeDownloadInfo, startedAt time.Time) {
+	if task.ReplacePath != "" {
+		if err := e.commitReplacement(task); err != nil {
+			e.failTask(task, err.Error())
+			return
 		}
+	}
 
-		e.stats.TrackFileCompleted()
-		e.stats.TrackDownloadBytes(task.TotalSize)
-
-		completedAt := time.Now()
-		elapsed := completedAt.Sub(startedAt).Seconds()
-		var avgSpeed float64
-		if elapsed > 0 {
-			avgSpeed = float64(task.TotalSize) / elapsed
+	task.Status = storage.StatusCompleted
+	task.Progress = 100
+	for attempt := 0; attempt < 3; attempt++ {
+		if err := e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+			t.Status = storage.StatusCompleted
+			t.Progress = 100
+			t.Downloaded = task.Downloaded
+			t.TotalSize = task.TotalSize
+			t.SavePath = task.SavePath
+			t.Filename = task.Filename
+		}); err == nil {
+			break
+		} else if attempt < 2 {
+			time.Sleep(time.Duration(100*(attempt+1)) * time.Millisecond)
+		} else {
+			e.logger.Error("Failed to persist completion status", "id", task.ID, "error", err)
 		}
+	}
+	e.logger.Info("Download Completed", "id", task.ID)
+	e.markFileState(task.ID, task.SavePath, true)
+	e.watchDir(task.SavePath)
 
-		if e.ctx != nil {
-			runtime.EventsEmit(e.ctx, "download:completed", map[string]interface{}{
-				"id":           task.ID,
-				"path":         task.SavePath,
-				"completed_at": completedAt.Format(time.RFC3339),
-				"started_at":   startedAt.Format(time.RFC3339),
-				"elapsed":      elapsed,
-				"avg_speed":    avgSpeed,
+	avEnabled := true
+	if av, err := e.storage.GetString("enable_av_scan"); err == nil && av == "false" {
+		avEnabled = false
+	}
+	if avEnabled {
+		done := e.beginPostProcessing()
+		scanErr := e.scanner.ScanFile(ctx, task.SavePath)
+		done()
+		if scanErr != nil {
+			e.logger.Warn("AV scan warning", "id", task.ID, "error", scanErr)
+			e.emit("download:av_warning", map[string]interface{}{
+				"id":      task.ID,
+				"path":    task.SavePath,
+				"warning": scanErr.Error(),
 			})
 		}
 	}
+
+	e.stats.TrackFileCompleted()
+	e.stats.TrackDownloadBytes(task.TotalSize)
+
+	completedAt := time.Now()
+	e.writeTaskSidecar(task, completedAt)
+	elapsed := completedAt.Sub(startedAt).Seconds()
+	var avgSpeed float64
+	if elapsed > 0 {
+		avgSpeed = float64(task.TotalSize) / elapsed
+	}
+	e.recordEvent(EventDownloadCompleted, task.ID, "Completed %s (%d bytes in %.1fs)", task.Filename, task.TotalSize, elapsed)
+
+	if e.ctx != nil {
+		runtime.EventsEmit(e.ctx, "download:completed", map[string]interface{}{
+			"id":           task.ID,
+			"path":         task.SavePath,
+			"completed_at": completedAt.Format(time.RFC3339),
+			"started_at":   startedAt.Format(time.RFC3339),
+			"elapsed":      elapsed,
+			"avg_speed":    avgSpeed,
+			"transferred":  info.Transferred.Load(),
+		})
+	}
+	e.playEventSound(platform.SoundComplete)
+	e.notifyBackends(task, startedAt, "")
 }
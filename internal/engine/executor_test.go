@@ -1,10 +1,24 @@
 package engine
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"project-tachyon/internal/network"
+	"project-tachyon/internal/security"
 	"project-tachyon/internal/storage"
 
 	"github.com/glebarez/sqlite"
@@ -23,6 +37,7 @@ func createExecutorTestDB(t *testing.T) *storage.Storage {
 		&storage.DailyStat{},
 		&storage.AppSetting{},
 		&storage.SpeedTestHistory{},
+		&storage.HostProfile{},
 	); err != nil {
 		t.Fatalf("Migration failed: %v", err)
 	}
@@ -92,3 +107,787 @@ func TestConfigConstants(t *testing.T) {
 		t.Error("GenericUserAgent should not be empty")
 	}
 }
+
+func TestMaxConnectionsPerDownload_CapsCongestion(t *testing.T) {
+	content := generateDummyContent(8 * 1024 * 1024)
+	var inflight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "big.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.baseChunkSize = minAdaptiveChunk
+	e.SetConcurrencyCurve(nil)
+	e.SetMaxConnectionsPerDownload(3)
+	// Congestion control alone would open far more connections
+	e.congestion = network.NewCongestionController(4, MaxConnectionsCap)
+	e.congestion.SeedConcurrency("127.0.0.1", 32)
+
+	id, err := e.StartDownload(server.URL+"/big.bin", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+	if got := peak.Load(); got > 3 {
+		t.Errorf("expected at most 3 concurrent connections, saw %d", got)
+	}
+}
+
+func TestSetMaxConnectionsPerDownload_Clamps(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createExecutorTestDB(t))
+	if got := e.GetMaxConnectionsPerDownload(); got != MaxWorkersPerTask {
+		t.Fatalf("expected default %d, got %d", MaxWorkersPerTask, got)
+	}
+	e.SetMaxConnectionsPerDownload(0)
+	if got := e.GetMaxConnectionsPerDownload(); got != 1 {
+		t.Errorf("expected clamp to 1, got %d", got)
+	}
+	e.SetMaxConnectionsPerDownload(500)
+	if got := e.GetMaxConnectionsPerDownload(); got != MaxConnectionsCap {
+		t.Errorf("expected clamp to %d, got %d", MaxConnectionsCap, got)
+	}
+}
+
+func TestPauseRacingError_SingleTerminalEvent(t *testing.T) {
+	content := generateDummyContent(4 * 1024 * 1024)
+	terminal := map[string]storage.Status{
+		"download:paused":     storage.StatusPaused,
+		"download:error":      storage.StatusError,
+		"download:needs_auth": storage.StatusNeedsAuth,
+		"download:completed":  storage.StatusCompleted,
+	}
+
+	for i := 0; i < 10; i++ {
+		e, store := newLoopbackEngine(t)
+		e.baseChunkSize = minAdaptiveChunk
+
+		var mu sync.Mutex
+		var events []string
+		e.eventHook = func(name string, _ interface{}) {
+			if _, ok := terminal[name]; ok {
+				mu.Lock()
+				events = append(events, name)
+				mu.Unlock()
+			}
+		}
+
+		// Part requests fail with 403 while the user pauses at the same time
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && r.Header.Get("Range") != "" && r.Header.Get("Range") != "bytes=0-0" {
+				e.activeDownloads.Range(func(key, _ interface{}) bool {
+					go e.PauseDownload(key.(string))
+					return true
+				})
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			http.ServeContent(w, r, "race.bin", time.Time{}, bytes.NewReader(content))
+		}))
+
+		id, err := e.StartDownload(server.URL+"/race.bin", t.TempDir(), "", nil)
+		if err != nil {
+			server.Close()
+			t.Fatalf("StartDownload failed: %v", err)
+		}
+		// Read the status before checking the task is no longer active, so a
+		// stopped task is never confused with one not yet dispatched.
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			task, _ := store.GetTask(id)
+			_, active := e.activeDownloads.Load(id)
+			if !active && task.Status != storage.StatusPending {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for download to stop (status %q)", task.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		server.Close()
+
+		mu.Lock()
+		got := append([]string(nil), events...)
+		mu.Unlock()
+		if len(got) != 1 {
+			t.Fatalf("run %d: expected exactly one terminal event, got %v", i, got)
+		}
+		if task, _ := store.GetTask(id); task.Status != terminal[got[0]] {
+			t.Errorf("run %d: event %s but stored status %s", i, got[0], task.Status)
+		}
+	}
+}
+
+// partBytes sums the sizes of the part files anywhere under dir.
+func partBytes(t *testing.T, dir string) int64 {
+	t.Helper()
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.Contains(d.Name(), ".part.") {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+func TestPause_WorkersFlushBeforePaused(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(64 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 5*time.Millisecond)
+	defer server.Close()
+
+	saveDir := t.TempDir()
+	e, store := newLoopbackEngine(t)
+
+	// Measured from inside the paused event, before the executor returns.
+	atPause := make(chan int64, 1)
+	e.eventHook = func(name string, _ interface{}) {
+		if name == "download:paused" {
+			atPause <- partBytes(t, saveDir)
+		}
+	}
+
+	// Many workers, each holding up to a buffer's worth of unflushed data.
+	id, err := e.StartDownload(server.URL+"/flush.bin", saveDir, "flush.bin", map[string]string{"connections": "16"})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		if val, ok := e.activeDownloads.Load(id); ok && val.(*activeDownloadInfo).Transferred.Load() > 8*1024*1024 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("download made no progress")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	e.PauseDownload(id)
+
+	var reported int64
+	select {
+	case reported = <-atPause:
+	case <-time.After(10 * time.Second):
+		t.Fatal("download was not paused")
+	}
+	// Nothing may reach the part files after the pause was reported.
+	time.Sleep(300 * time.Millisecond)
+	if later := partBytes(t, saveDir); later != reported {
+		t.Errorf("part files grew from %d to %d bytes after the pause was reported", reported, later)
+	}
+	task, _ := store.GetTask(id)
+	if task.Status != storage.StatusPaused {
+		t.Fatalf("status = %s, want paused", task.Status)
+	}
+	if task.Downloaded != reported {
+		t.Errorf("persisted %d downloaded bytes, but %d are on disk", task.Downloaded, reported)
+	}
+}
+
+func TestResume_RefetchesTruncatedParts(t *testing.T) {
+	content := generateDummyContent(4 * 1024 * 1024)
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); r.Method == http.MethodGet && rng != "" {
+			mu.Lock()
+			requested = append(requested, rng)
+			mu.Unlock()
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	savePath := filepath.Join(t.TempDir(), "file.bin")
+	task := storage.DownloadTask{
+		ID:        "truncated-resume",
+		URL:       server.URL + "/file.bin",
+		Filename:  "file.bin",
+		SavePath:  savePath,
+		Status:    storage.StatusPaused,
+		TotalSize: int64(len(content)),
+	}
+	parts := e.planDownloadParts(task.TotalSize, true)
+	if len(parts) < 6 {
+		t.Fatalf("planned %d parts, want several", len(parts))
+	}
+
+	// The saved state marks the even parts complete, but a crash cut part 2
+	// short and part 4's file never made it to disk
+	plan := make(map[int]DownloadPart)
+	done := make(map[int]bool)
+	partsDir := tempDirForTask(savePath)
+	os.MkdirAll(partsDir, 0755)
+	for _, p := range parts {
+		plan[p.ID] = p
+		if p.ID%2 != 0 {
+			continue
+		}
+		done[p.ID] = true
+		task.Downloaded += p.EndOffset - p.StartOffset + 1
+		data := content[p.StartOffset : p.EndOffset+1]
+		switch p.ID {
+		case 2:
+			data = data[:len(data)/2]
+		case 4:
+			continue
+		}
+		os.WriteFile(filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, p.StartOffset)), data, 0644)
+	}
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{ETag: `"v1"`, AcceptRanges: true}, time.Time{}, done, plan)
+	store.SaveTask(task)
+
+	if err := e.ResumeDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if final := waitForFinalStatus(t, store, task.ID); final.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", final.Status)
+	}
+	got, err := os.ReadFile(savePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("file is %d bytes and differs from the served %d", len(got), len(content))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	fetched := func(id int) bool {
+		for _, rng := range requested {
+			if strings.HasPrefix(rng, fmt.Sprintf("bytes=%d-", plan[id].StartOffset)) {
+				return true
+			}
+		}
+		return false
+	}
+	for id := range done {
+		switch id {
+		case 2, 4:
+			if !fetched(id) {
+				t.Errorf("part %d is not on disk in full but was not fetched again", id)
+			}
+		default:
+			if fetched(id) {
+				t.Errorf("intact part %d fetched again", id)
+			}
+		}
+	}
+}
+
+func TestResume_ReplanRemovesStaleTailPart(t *testing.T) {
+	content := generateDummyContent(4*1024*1024 + 100)
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+
+	// Saved with merging off, the plan ends in a 100-byte part; the resumed
+	// plan folds it into the part before
+	e.SetMinPartSize(0)
+	old := e.planDownloadParts(int64(len(content)), true)
+	e.SetMinPartSize(DefaultMinPartSize)
+	if parts := e.planDownloadParts(int64(len(content)), true); len(parts) != len(old)-1 {
+		t.Fatalf("resumed plan has %d parts, want %d", len(parts), len(old)-1)
+	}
+	merged, tail := old[len(old)-2], old[len(old)-1]
+
+	fetching := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", merged.StartOffset)) {
+			select {
+			case fetching <- struct{}{}:
+			default:
+			}
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	savePath := filepath.Join(t.TempDir(), "file.bin")
+	task := storage.DownloadTask{
+		ID:        "replan-resume",
+		URL:       server.URL + "/file.bin",
+		Filename:  "file.bin",
+		SavePath:  savePath,
+		Status:    storage.StatusPaused,
+		TotalSize: int64(len(content)),
+	}
+	plan := make(map[int]DownloadPart)
+	done := make(map[int]bool)
+	partsDir := tempDirForTask(savePath)
+	os.MkdirAll(partsDir, 0755)
+	for _, p := range old {
+		plan[p.ID] = p
+		if p.ID == merged.ID {
+			continue
+		}
+		done[p.ID] = true
+		task.Downloaded += p.EndOffset - p.StartOffset + 1
+		os.WriteFile(filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, p.StartOffset)), content[p.StartOffset:p.EndOffset+1], 0644)
+	}
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{ETag: `"v1"`, AcceptRanges: true}, time.Time{}, done, plan)
+	store.SaveTask(task)
+
+	if err := e.ResumeDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-fetching:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("merged part was never fetched")
+	}
+	stale := filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, tail.StartOffset))
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("part file at the old tail offset survived the re-plan (err %v)", err)
+	}
+	close(release)
+
+	if final := waitForFinalStatus(t, store, task.ID); final.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", final.Status)
+	}
+	if got, _ := os.ReadFile(savePath); !bytes.Equal(got, content) {
+		t.Fatalf("file is %d bytes and differs from the served %d", len(got), len(content))
+	}
+}
+
+func TestResume_RefetchesEmptyStreamPart(t *testing.T) {
+	content := generateDummyContent(256 * 1024)
+	var gets atomic.Int32
+	fetching := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No ranges and no length: the download is a single stream part.
+		// Send some of it, then hang until the client goes away.
+		if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+			return
+		}
+		w.Write(content[:64*1024])
+		w.(http.Flusher).Flush()
+		// The first plain GET is the probe
+		if gets.Add(1) == 2 {
+			fetching <- struct{}{}
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	savePath := filepath.Join(t.TempDir(), "stream.bin")
+	task := storage.DownloadTask{
+		ID:       "empty-stream-resume",
+		URL:      server.URL + "/stream.bin",
+		Filename: "stream.bin",
+		SavePath: savePath,
+		Status:   storage.StatusPaused,
+	}
+	parts := e.planDownloadParts(0, false)
+	plan := map[int]DownloadPart{parts[0].ID: parts[0]}
+	done := map[int]bool{parts[0].ID: true}
+
+	// The state says the stream finished, but its part file is empty
+	partsDir := tempDirForTask(savePath)
+	os.MkdirAll(partsDir, 0755)
+	os.WriteFile(filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, parts[0].StartOffset)), nil, 0644)
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{}, time.Time{}, done, plan)
+	store.SaveTask(task)
+
+	if err := e.ResumeDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-fetching:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the empty stream part was not fetched again")
+	}
+	if err := e.PauseDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, active := e.activeDownloads.Load(task.ID); !active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the download to stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if final, _ := store.GetTask(task.ID); final.Status != storage.StatusPaused {
+		t.Errorf("status = %s, want paused", final.Status)
+	}
+	if _, err := os.Stat(savePath); !os.IsNotExist(err) {
+		t.Error("the half-fetched stream was merged into the final file")
+	}
+}
+
+func TestResume_AllPartsOnDiskFinalizes(t *testing.T) {
+	content := generateDummyContent(2 * 1024 * 1024)
+	var rangedGets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			rangedGets.Add(1)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// Paused after the last part landed but before it was merged
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	savePath := filepath.Join(t.TempDir(), "file.bin")
+	task := storage.DownloadTask{
+		ID:         "complete-resume",
+		URL:        server.URL + "/file.bin",
+		Filename:   "file.bin",
+		SavePath:   savePath,
+		Status:     storage.StatusPaused,
+		TotalSize:  int64(len(content)),
+		Downloaded: int64(len(content)),
+	}
+	plan := make(map[int]DownloadPart)
+	done := make(map[int]bool)
+	partsDir := tempDirForTask(savePath)
+	os.MkdirAll(partsDir, 0755)
+	for _, p := range e.planDownloadParts(task.TotalSize, true) {
+		plan[p.ID] = p
+		done[p.ID] = true
+		os.WriteFile(filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, p.StartOffset)), content[p.StartOffset:p.EndOffset+1], 0644)
+	}
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{ETag: `"v1"`, AcceptRanges: true}, time.Time{}, done, plan)
+	store.SaveTask(task)
+
+	if err := e.ResumeDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if final := waitForFinalStatus(t, store, task.ID); final.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", final.Status)
+	}
+	if got, _ := os.ReadFile(savePath); !bytes.Equal(got, content) {
+		t.Error("merged file does not match the served content")
+	}
+	// The probe may use a one-byte range; no part was fetched
+	if n := rangedGets.Load(); n > 1 {
+		t.Errorf("%d ranged GETs, want no part fetched again", n)
+	}
+}
+
+func TestResume_FailsWhenRemoteFileShrank(t *testing.T) {
+	content := generateDummyContent(2 * 1024 * 1024)
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	parts := e.planDownloadParts(int64(len(content)), true)
+	last := parts[len(parts)-1]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", last.StartOffset)) {
+			// The file was cut back to where the last part starts
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", last.StartOffset))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	savePath := filepath.Join(t.TempDir(), "file.bin")
+	task := storage.DownloadTask{
+		ID:         "shrunk-resume",
+		URL:        server.URL + "/file.bin",
+		Filename:   "file.bin",
+		SavePath:   savePath,
+		Status:     storage.StatusPaused,
+		TotalSize:  int64(len(content)),
+		Downloaded: last.StartOffset,
+	}
+	plan := make(map[int]DownloadPart)
+	done := make(map[int]bool)
+	partsDir := tempDirForTask(savePath)
+	os.MkdirAll(partsDir, 0755)
+	for _, p := range parts {
+		plan[p.ID] = p
+		if p.ID == last.ID {
+			continue
+		}
+		done[p.ID] = true
+		os.WriteFile(filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, p.StartOffset)), content[p.StartOffset:p.EndOffset+1], 0644)
+	}
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{ETag: `"v1"`, AcceptRanges: true}, time.Time{}, done, plan)
+	store.SaveTask(task)
+
+	if err := e.ResumeDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if final := waitForFinalStatus(t, store, task.ID); final.Status != storage.StatusError {
+		t.Fatalf("status = %s, want error", final.Status)
+	}
+	if ev := e.GetEventLog(1); len(ev) != 1 || !strings.Contains(ev[0].Message, "changed on the server") {
+		t.Errorf("last event = %+v, want the remote change reported", ev)
+	}
+	if _, err := os.Stat(savePath); !os.IsNotExist(err) {
+		t.Error("a file was assembled from the incomplete parts")
+	}
+}
+
+func TestResume_416AtEndOfFileCompletesWhenOptedIn(t *testing.T) {
+	content := generateDummyContent(2 * 1024 * 1024)
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	e.SetTreat416AsComplete(true)
+	parts := e.planDownloadParts(int64(len(content)), true)
+	last := parts[len(parts)-1]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", last.StartOffset)) {
+			// The server says the file ends where the last part starts
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", last.StartOffset))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	savePath := filepath.Join(t.TempDir(), "file.bin")
+	task := storage.DownloadTask{
+		ID:         "ends-early-resume",
+		URL:        server.URL + "/file.bin",
+		Filename:   "file.bin",
+		SavePath:   savePath,
+		Status:     storage.StatusPaused,
+		TotalSize:  int64(len(content)),
+		Downloaded: last.StartOffset,
+	}
+	plan := make(map[int]DownloadPart)
+	done := make(map[int]bool)
+	partsDir := tempDirForTask(savePath)
+	os.MkdirAll(partsDir, 0755)
+	for _, p := range parts {
+		plan[p.ID] = p
+		if p.ID == last.ID {
+			continue
+		}
+		done[p.ID] = true
+		os.WriteFile(filepath.Join(partsDir, fmt.Sprintf("%s.part.%d", task.ID, p.StartOffset)), content[p.StartOffset:p.EndOffset+1], 0644)
+	}
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{ETag: `"v1"`, AcceptRanges: true}, time.Time{}, done, plan)
+	store.SaveTask(task)
+
+	if err := e.ResumeDownload(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	final := waitForFinalStatus(t, store, task.ID)
+	if final.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", final.Status)
+	}
+	if final.TotalSize != last.StartOffset {
+		t.Errorf("total size = %d, want the %d the server reported", final.TotalSize, last.StartOffset)
+	}
+	if got, _ := os.ReadFile(savePath); !bytes.Equal(got, content[:last.StartOffset]) {
+		t.Errorf("file is %d bytes, want the first %d of the content", len(got), last.StartOffset)
+	}
+}
+
+func TestPause_WhileProbingLeavesResumableTask(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	e, store := newLoopbackEngine(t)
+
+	id, err := e.StartDownload(server.URL+"/slow.bin", t.TempDir(), "slow.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := e.activeDownloads.Load(id); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("download never started probing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := e.PauseDownload(id); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, ok := e.activeDownloads.Load(id); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("probe was not cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if task, _ := store.GetTask(id); task.Status != storage.StatusPaused {
+		t.Errorf("status = %s, want paused", task.Status)
+	}
+}
+
+// stubScanner is a security.Scanner whose result is set by the test.
+type stubScanner struct {
+	mu      sync.Mutex
+	scanned []string
+	result  error
+}
+
+func (s *stubScanner) ScanFile(_ context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanned = append(s.scanned, path)
+	return s.result
+}
+
+func (s *stubScanner) Name() string      { return "Stub AV" }
+func (s *stubScanner) IsAvailable() bool { return true }
+
+func (s *stubScanner) paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.scanned...)
+}
+
+func TestCompleteTask_ThreatEmitsAVWarning(t *testing.T) {
+	engine, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+	scanner := &stubScanner{result: fmt.Errorf("%w: Eicar-Test-Signature", security.ErrThreatDetected)}
+	engine.SetScanner(scanner)
+	warnings := make(chan map[string]interface{}, 1)
+	engine.eventHook = func(name string, data interface{}) {
+		if name == "download:av_warning" {
+			warnings <- data.(map[string]interface{})
+		}
+	}
+
+	id, err := engine.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+
+	select {
+	case w := <-warnings:
+		if w["id"] != id || w["path"] != task.SavePath || !strings.Contains(w["warning"].(string), "Eicar-Test-Signature") {
+			t.Errorf("av_warning = %v", w)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no av_warning for a detected threat")
+	}
+	if got := scanner.paths(); len(got) != 1 || got[0] != task.SavePath {
+		t.Errorf("scanned %v, want [%s]", got, task.SavePath)
+	}
+	// Remediation is left to the AV; the download itself still completes
+	if task.Status != storage.StatusCompleted {
+		t.Errorf("status = %s, want completed", task.Status)
+	}
+	if _, err := os.Stat(task.SavePath); err != nil {
+		t.Errorf("file was moved: %v", err)
+	}
+}
+
+func TestCompleteTask_CleanOrDisabledScan(t *testing.T) {
+	engine, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+	scanner := &stubScanner{}
+	engine.SetScanner(scanner)
+	var warned atomic.Bool
+	engine.eventHook = func(name string, _ interface{}) {
+		if name == "download:av_warning" {
+			warned.Store(true)
+		}
+	}
+
+	id, _ := engine.StartDownload(url, t.TempDir(), "clean.bin", nil)
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if len(scanner.paths()) != 1 || warned.Load() {
+		t.Errorf("clean scan: scanned %v, warned %v", scanner.paths(), warned.Load())
+	}
+
+	store.SetString("enable_av_scan", "false")
+	scanner.result = security.ErrThreatDetected
+	id, _ = engine.StartDownload(url, t.TempDir(), "skipped.bin", nil)
+	waitForFinalStatus(t, store, id)
+	if len(scanner.paths()) != 1 || warned.Load() {
+		t.Errorf("disabled scan: scanned %v, warned %v", scanner.paths(), warned.Load())
+	}
+}
+
+func TestExecuteTask_ExpiredCookiesNeedAuth(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	needsAuth := make(chan string, 1)
+	e.eventHook = func(name string, data interface{}) {
+		if name == "download:needs_auth" {
+			needsAuth <- data.(map[string]interface{})["reason"].(string)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	id, err := e.StartDownload(server.URL+"/members.bin", t.TempDir(), "", map[string]string{
+		"cookies_json": fmt.Sprintf(`[{"Name":"session","Value":"x","Expires":%q}]`, past),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-needsAuth:
+		if !strings.Contains(reason, "expired") {
+			t.Errorf("reason = %q", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("download did not ask for new credentials")
+	}
+	if task, _ := store.GetTask(id); task.Status != StatusNeedsAuth {
+		t.Errorf("status = %s, want needs_auth", task.Status)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("server was contacted %d times with expired cookies", n)
+	}
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// redactedValue replaces credential values in exported commands.
+const redactedValue = "REDACTED"
+
+// ExportAsCurl renders a download as an equivalent curl command: the same
+// URL, headers and cookies, resuming into the task's file. With redact set,
+// cookies and authorization headers are replaced by a placeholder, and the
+// URL's password and query values are redacted as in debug logs.
+func (e *TachyonEngine) ExportAsCurl(id string, redact bool) (string, error) {
+	url, headers, cookie, filename, err := e.exportRequest(id, redact)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"curl", "-L", "--fail", "-C", "-", "-o", shellQuote(filename)}
+	for _, h := range headers {
+		args = append(args, "-H", shellQuote(h))
+	}
+	if cookie != "" {
+		args = append(args, "-b", shellQuote(cookie))
+	}
+	args = append(args, shellQuote(url))
+	return strings.Join(args, " "), nil
+}
+
+// ExportAsWget renders a download as an equivalent wget command. See
+// ExportAsCurl.
+func (e *TachyonEngine) ExportAsWget(id string, redact bool) (string, error) {
+	url, headers, cookie, filename, err := e.exportRequest(id, redact)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"wget", "-c", "-O", shellQuote(filename)}
+	for _, h := range headers {
+		args = append(args, "--header="+shellQuote(h))
+	}
+	if cookie != "" {
+		args = append(args, "--header="+shellQuote("Cookie: "+cookie))
+	}
+	args = append(args, shellQuote(url))
+	return strings.Join(args, " "), nil
+}
+
+// exportRequest builds the request the engine would send for a task and
+// returns its headers as sorted "Key: value" lines, with the cookie header
+// split out so each tool can use its own cookie flag.
+func (e *TachyonEngine) exportRequest(id string, redact bool) (link string, headers []string, cookie string, filename string, err error) {
+	task, err := e.storage.GetTask(id)
+	if err != nil {
+		return "", nil, "", "", fmt.Errorf("task not found: %w", err)
+	}
+	req, err := e.newRequest(http.MethodGet, task.URL, task.Headers, task.Cookies)
+	if err != nil {
+		return "", nil, "", "", err
+	}
+	// The tools manage the connection and ranges themselves
+	req.Header.Del("Connection")
+	req.Header.Del("Range")
+
+	link = task.URL
+	cookie = req.Header.Get("Cookie")
+	req.Header.Del("Cookie")
+	if redact {
+		// Signed links carry their credentials in the query
+		link = redactURL(req.URL)
+		if cookie != "" {
+			cookie = redactedValue
+		}
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			if redact && isCredentialHeader(k) {
+				v = redactedValue
+			}
+			headers = append(headers, k+": "+v)
+		}
+	}
+
+	filename = task.Filename
+	if filename == "" {
+		filename = "download"
+	}
+	return link, headers, cookie, filename, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"project-tachyon/internal/storage"
+)
+
+func newExportEngine(t *testing.T) *TachyonEngine {
+	t.Helper()
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	store.SaveTask(storage.DownloadTask{
+		ID:       "exp",
+		URL:      "https://cdn.example.com/files/it's.zip?token=abc",
+		Filename: "it's.zip",
+		Headers:  `{"Referer":"https://example.com/page","Authorization":"Bearer secret"}`,
+		Cookies:  "session=xyz; theme=dark",
+	})
+	return e
+}
+
+func TestExportAsCurl(t *testing.T) {
+	e := newExportEngine(t)
+	cmd, err := e.ExportAsCurl("exp", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`curl -L --fail -C - -o 'it'\''s.zip'`,
+		`-H 'Referer: https://example.com/page'`,
+		`-H 'Authorization: Bearer secret'`,
+		`-b 'session=xyz; theme=dark'`,
+		`'https://cdn.example.com/files/it'\''s.zip?token=abc'`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("expected %q in %s", want, cmd)
+		}
+	}
+	if strings.Contains(cmd, "Connection") {
+		t.Errorf("connection header should be left to curl: %s", cmd)
+	}
+}
+
+func TestExportAsWget(t *testing.T) {
+	e := newExportEngine(t)
+	cmd, err := e.ExportAsWget("exp", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`wget -c -O 'it'\''s.zip'`,
+		`--header='Referer: https://example.com/page'`,
+		`--header='Cookie: session=xyz; theme=dark'`,
+		`'https://cdn.example.com/files/it'\''s.zip?token=abc'`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("expected %q in %s", want, cmd)
+		}
+	}
+}
+
+func TestExport_Redacted(t *testing.T) {
+	e := newExportEngine(t)
+	for _, export := range []func(string, bool) (string, error){e.ExportAsCurl, e.ExportAsWget} {
+		cmd, err := export("exp", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(cmd, "secret") || strings.Contains(cmd, "xyz") || strings.Contains(cmd, "token=abc") {
+			t.Errorf("credentials leaked: %s", cmd)
+		}
+		if !strings.Contains(cmd, "it'\\''s.zip?token=[redacted]") {
+			t.Errorf("expected the URL with its query value redacted: %s", cmd)
+		}
+		if !strings.Contains(cmd, "Authorization: REDACTED") {
+			t.Errorf("expected redacted authorization header: %s", cmd)
+		}
+		if !strings.Contains(cmd, "Referer: https://example.com/page") {
+			t.Errorf("non-credential headers should be kept: %s", cmd)
+		}
+	}
+}
+
+func TestExport_UnknownTask(t *testing.T) {
+	e := newExportEngine(t)
+	if _, err := e.ExportAsCurl("missing", false); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"mime"
+	"path/filepath"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// SetFixMissingExtensions enables appending an extension derived from the
+// server's Content-Type to downloads whose URL gave a filename without one
+// (e.g. https://cdn.example.com/download/123). Off by default so files are
+// never renamed unexpectedly.
+func (e *TachyonEngine) SetFixMissingExtensions(enabled bool) {
+	e.fixExtensions.Store(enabled)
+}
+
+// GetFixMissingExtensions reports whether extension correction is enabled.
+func (e *TachyonEngine) GetFixMissingExtensions() bool {
+	return e.fixExtensions.Load()
+}
+
+// extensionForContentType picks a file extension for a MIME type, preferring
+// one the organizer knows a category for. Generic binary types yield "".
+func extensionForContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "application/octet-stream", "binary/octet-stream", "application/unknown":
+		return ""
+	}
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	for _, ext := range exts {
+		if filesystem.GetCategory("f"+ext) != "Others" {
+			return ext
+		}
+	}
+	return exts[0]
+}
+
+// applyContentTypeExtension renames a fresh download that has no extension
+// using the probe's Content-Type. Reports whether the task changed.
+func (e *TachyonEngine) applyContentTypeExtension(task *storage.DownloadTask, probe *ProbeResult) bool {
+	if !e.GetFixMissingExtensions() || probe == nil || task.Downloaded > 0 {
+		return false
+	}
+	if filepath.Ext(task.Filename) != "" || task.SavePath == "" {
+		return false
+	}
+	ext := extensionForContentType(probe.ContentType)
+	if ext == "" {
+		return false
+	}
+
+	e.logger.Info("Adding extension from Content-Type", "id", task.ID, "content_type", probe.ContentType)
+	e.renameQueuedTask(task, task.Filename+ext)
+	return true
+}
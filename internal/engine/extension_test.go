@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestExtensionForContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"application/zip", ".zip"},
+		{"application/pdf", ".pdf"},
+		{"video/mp4", ".mp4"},
+		{"application/zip; charset=binary", ".zip"},
+		{"application/octet-stream", ""},
+		{"", ""},
+		{"not a type", ""},
+	}
+	for _, tt := range tests {
+		if got := extensionForContentType(tt.contentType); got != tt.want {
+			t.Errorf("extensionForContentType(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// downloadZipWithoutExtension downloads a Content-Type: application/zip
+// response from an extensionless URL and returns the finished task.
+func downloadZipWithoutExtension(t *testing.T, fix bool) storage.DownloadTask {
+	t.Helper()
+	content := generateDummyContent(64 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+	engine.SetFixMissingExtensions(fix)
+
+	id, err := engine.StartDownload(server.URL+"/download/123", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(10 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		switch task.Status {
+		case storage.StatusCompleted:
+			return task
+		case storage.StatusError:
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestContentTypeExtensionAppended(t *testing.T) {
+	task := downloadZipWithoutExtension(t, true)
+
+	if task.Filename != "123.zip" {
+		t.Errorf("expected filename 123.zip, got %q", task.Filename)
+	}
+	if task.Category != "Archives" {
+		t.Errorf("expected category Archives, got %q", task.Category)
+	}
+	if filepath.Base(filepath.Dir(task.SavePath)) != "Archives" {
+		t.Errorf("expected file filed under Archives, got %q", task.SavePath)
+	}
+	if _, err := os.Stat(task.SavePath); err != nil {
+		t.Errorf("downloaded file missing: %v", err)
+	}
+}
+
+func TestContentTypeExtensionOptIn(t *testing.T) {
+	task := downloadZipWithoutExtension(t, false)
+
+	if task.Filename != "123" {
+		t.Errorf("expected filename unchanged when disabled, got %q", task.Filename)
+	}
+	if task.Category != "Others" {
+		t.Errorf("expected category Others, got %q", task.Category)
+	}
+}
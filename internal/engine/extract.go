@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"project-tachyon/internal/extractor"
+	"project-tachyon/internal/storage"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxExtractPageSize bounds how much of an HTML page is read when looking
+// for download links.
+const maxExtractPageSize = 5 * 1024 * 1024
+
+// SetLinkExtractors replaces the extractors run when a download URL turns
+// out to be an HTML page. nil disables link extraction.
+func (e *TachyonEngine) SetLinkExtractors(r *extractor.Registry) {
+	e.extractorsMu.Lock()
+	e.extractors = r
+	e.extractorsMu.Unlock()
+}
+
+func (e *TachyonEngine) linkExtractors() *extractor.Registry {
+	e.extractorsMu.RLock()
+	defer e.extractorsMu.RUnlock()
+	return e.extractors
+}
+
+// ExtractLinks fetches the page at pageURL and returns the download links
+// found on it, best first, so the UI can let the user pick one.
+func (e *TachyonEngine) ExtractLinks(pageURL string) ([]extractor.Candidate, error) {
+	if err := e.validateURL(pageURL); err != nil {
+		return nil, err
+	}
+	reg := e.linkExtractors()
+	if reg == nil {
+		return nil, fmt.Errorf("link extraction is disabled")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	page, err := e.fetchPage(ctx, pageURL, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return reg.Extract(pageURL, page)
+}
+
+// validateURL applies the engine's URL policy.
+func (e *TachyonEngine) validateURL(urlStr string) error {
+	if e.allowLoopback {
+		return ValidateURLAllowLoopback(urlStr)
+	}
+	return ValidateURL(urlStr)
+}
+
+// fetchPage downloads up to maxExtractPageSize bytes of an HTML page.
+func (e *TachyonEngine) fetchPage(ctx context.Context, urlStr, headersStr, cookiesStr string) ([]byte, error) {
+	req, err := e.newRequest("GET", urlStr, headersStr, cookiesStr)
+	if err != nil {
+		return nil, friendlyError(err)
+	}
+	resp, err := e.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, friendlyError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, friendlyHTTPError(resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxExtractPageSize))
+}
+
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// resolvePageLink handles a download whose URL serves an HTML page: the page
+// is scanned for download links and the task is pointed at the best one if
+// it is a strong candidate. Weaker links are only reported, in a
+// download:link_candidates event, for the user to choose from.
+// Returns the probe for the new URL, or nil when the task is left as is
+// (not a page, the user asked for the .html file, or no strong link found).
+func (e *TachyonEngine) resolvePageLink(ctx context.Context, task *storage.DownloadTask, probe *ProbeResult) (*ProbeResult, error) {
+	if probe == nil || task.Downloaded > 0 || !isHTMLContentType(probe.ContentType) {
+		return nil, nil
+	}
+	if ext := strings.ToLower(filepath.Ext(task.Filename)); ext == ".html" || ext == ".htm" {
+		return nil, nil
+	}
+	reg := e.linkExtractors()
+	if reg == nil {
+		return nil, nil
+	}
+
+	page, err := e.fetchPage(ctx, task.URL, task.Headers, task.Cookies)
+	if err != nil {
+		e.logger.Warn("Failed to fetch page for link extraction", "id", task.ID, "error", err)
+		return nil, nil
+	}
+	candidates, err := reg.Extract(task.URL, page)
+	if err != nil || len(candidates) == 0 {
+		return nil, nil
+	}
+	var best *extractor.Candidate
+	for i := range candidates {
+		if e.validateURL(candidates[i].URL) == nil {
+			best = &candidates[i]
+			break
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	if best.Score < extractor.MinAutoFollowScore {
+		e.logger.Info("Page has no strong download link, not following", "id", task.ID, "page", task.URL, "candidates", len(candidates))
+		e.emit("download:link_candidates", map[string]interface{}{
+			"id":         task.ID,
+			"page_url":   task.URL,
+			"candidates": candidates,
+		})
+		return nil, nil
+	}
+
+	e.logger.Info("Page link extracted", "id", task.ID, "page", task.URL, "url", best.URL, "source", best.Source)
+	pageURL, pageHeaders, pageCookies := task.URL, task.Headers, task.Cookies
+	task.URL = best.URL
+	// The page's cookies and credentials belong to its site; a link to
+	// another site gets neither
+	if !sameSite(pageURL, best.URL) {
+		task.Cookies = ""
+		task.Headers = withoutCredentialHeaders(task.Headers)
+	}
+	next, err := e.probeWithRetry(ctx, task)
+	if err != nil {
+		task.URL, task.Headers, task.Cookies = pageURL, pageHeaders, pageCookies
+		return nil, err
+	}
+	name := SanitizeFilename(best.Filename)
+	if name == "" {
+		name = SanitizeFilename(next.Filename)
+	}
+	if name != "" {
+		e.renameQueuedTask(task, name)
+	}
+	e.emit("download:link_extracted", map[string]interface{}{
+		"id":         task.ID,
+		"page_url":   pageURL,
+		"url":        task.URL,
+		"filename":   task.Filename,
+		"candidates": candidates,
+	})
+	return next, nil
+}
+
+// sameSite reports whether a and b are on the same registrable domain
+// (e.g. www.example.com and dl.example.com). IP addresses and names
+// without a public suffix must match exactly.
+func sameSite(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	ha, hb := strings.ToLower(ua.Hostname()), strings.ToLower(ub.Hostname())
+	if ha == hb {
+		return true
+	}
+	if net.ParseIP(ha) != nil || net.ParseIP(hb) != nil {
+		return false
+	}
+	da, err := publicsuffix.EffectiveTLDPlusOne(ha)
+	if err != nil {
+		return false
+	}
+	db, err := publicsuffix.EffectiveTLDPlusOne(hb)
+	return err == nil && da == db
+}
+
+// withoutCredentialHeaders returns headersJSON with every credential header
+// (see isCredentialHeader) removed.
+func withoutCredentialHeaders(headersJSON string) string {
+	if headersJSON == "" {
+		return ""
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+		// newRequest ignores headers it cannot parse, so none are sent
+		return ""
+	}
+	for k := range headers {
+		if isCredentialHeader(k) {
+			delete(headers, k)
+		}
+	}
+	if len(headers) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
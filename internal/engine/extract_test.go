@@ -0,0 +1,246 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// hostingServer serves an HTML page at /release linking to a zip at
+// /dl/get?id=7, the way file hosting sites do.
+func hostingServer(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><body>
+			<h1>Tool 2.1</h1>
+			<a href="/about">About</a>
+			<a href="/dl/get?id=7" download="tool-2.1.zip">Download now</a>
+		</body></html>`)
+	})
+	mux.HandleFunc("/dl/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExtractLinks(t *testing.T) {
+	server := hostingServer(t, []byte("zip"))
+	engine := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	engine.allowLoopback = true
+
+	cands, err := engine.ExtractLinks(server.URL + "/release")
+	if err != nil {
+		t.Fatalf("ExtractLinks failed: %v", err)
+	}
+	if len(cands) != 1 {
+		t.Fatalf("expected 1 candidate, got %+v", cands)
+	}
+	if cands[0].URL != server.URL+"/dl/get?id=7" || cands[0].Filename != "tool-2.1.zip" {
+		t.Errorf("unexpected candidate %+v", cands[0])
+	}
+}
+
+func TestPageURLDownloadsExtractedLink(t *testing.T) {
+	content := generateDummyContent(256 * 1024)
+	server := hostingServer(t, content)
+
+	engine, store := newLoopbackEngine(t)
+	var extracted atomic.Bool
+	engine.eventHook = func(name string, data interface{}) {
+		if name == "download:link_extracted" {
+			extracted.Store(true)
+		}
+	}
+
+	id, err := engine.StartDownload(server.URL+"/release", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(10 * time.Second)
+	var task storage.DownloadTask
+	for task.Status != storage.StatusCompleted {
+		task, _ = store.GetTask(id)
+		if task.Status == storage.StatusError {
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if task.Filename != "tool-2.1.zip" {
+		t.Errorf("expected filename tool-2.1.zip, got %q", task.Filename)
+	}
+	if task.URL != server.URL+"/dl/get?id=7" {
+		t.Errorf("expected task URL to point at the file, got %q", task.URL)
+	}
+	if task.Category != "Archives" {
+		t.Errorf("expected category Archives, got %q", task.Category)
+	}
+	if !extracted.Load() {
+		t.Error("expected download:link_extracted event")
+	}
+	got, err := calculateMD5(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%x", md5.Sum(content)); got != want {
+		t.Errorf("content mismatch: got %s want %s", got, want)
+	}
+}
+
+func TestWeakPageLinkNotFollowed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a href="/"><img src="/logo.png"></a>
+			<a href="/brand/logo.png">Press kit</a><form>Sign in</form></body></html>`)
+	})
+	mux.HandleFunc("/brand/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("weak link was followed")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+	var offered atomic.Bool
+	engine.eventHook = func(name string, data interface{}) {
+		if name == "download:link_candidates" {
+			offered.Store(true)
+		}
+	}
+
+	id, err := engine.StartDownload(server.URL+"/login", t.TempDir(), "report.zip", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.URL != server.URL+"/login" || task.Filename != "report.zip" {
+		t.Errorf("task was retargeted to %q as %q", task.URL, task.Filename)
+	}
+	if !offered.Load() {
+		t.Error("expected download:link_candidates event")
+	}
+}
+
+func TestHTMLFileNotRetargeted(t *testing.T) {
+	server := hostingServer(t, []byte("zip"))
+	engine, store := newLoopbackEngine(t)
+
+	id, err := engine.StartDownload(server.URL+"/release", t.TempDir(), "release.html", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	deadline := time.After(10 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		if task.Status == storage.StatusCompleted {
+			if task.Filename != "release.html" || task.URL != server.URL+"/release" {
+				t.Errorf("explicit .html download should be kept, got %q from %q", task.Filename, task.URL)
+			}
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestPageLinkToOtherHostDropsCredentials(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	type seen struct{ cookie, auth, custom string }
+	got := make(chan seen, 16)
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- seen{r.Header.Get("Cookie"), r.Header.Get("Authorization"), r.Header.Get("X-Client")}
+		w.Header().Set("Content-Type", "application/zip")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+	// Same loopback address under another name: a different host
+	otherURL := strings.Replace(files.URL, "127.0.0.1", "localhost", 1) + "/tool.zip"
+
+	var pageCookie atomic.Value
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageCookie.Store(r.Header.Get("Cookie"))
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><a href="%s" download="tool.zip">Download now</a></body></html>`, otherURL)
+	}))
+	defer page.Close()
+
+	engine, store := newLoopbackEngine(t)
+
+	id, err := engine.StartDownload(page.URL+"/release", t.TempDir(), "", map[string]string{
+		"cookies_json": `[{"Name":"session","Value":"s3cret"}]`,
+		"headers_json": `{"Authorization":"Bearer t0ken","X-Client":"tachyon-test"}`,
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if task.URL != otherURL {
+		t.Fatalf("task URL = %q, want the link on the other host", task.URL)
+	}
+	if c, _ := pageCookie.Load().(string); !strings.Contains(c, "s3cret") {
+		t.Errorf("page request cookie = %q, want the user's session", c)
+	}
+	close(got)
+	n := 0
+	for s := range got {
+		n++
+		if s.cookie != "" || s.auth != "" {
+			t.Errorf("other host received Cookie %q, Authorization %q", s.cookie, s.auth)
+		}
+		if s.custom != "tachyon-test" {
+			t.Errorf("other host received X-Client %q, want non-credential headers kept", s.custom)
+		}
+	}
+	if n == 0 {
+		t.Fatal("the other host was never asked for the file")
+	}
+	if strings.Contains(task.Cookies, "s3cret") || strings.Contains(task.Headers, "t0ken") {
+		t.Errorf("stored task still carries the page's credentials: cookies %q, headers %q", task.Cookies, task.Headers)
+	}
+}
+
+func TestSameSite(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"https://example.com/page", "https://example.com/file", true},
+		{"https://www.example.com/page", "https://dl.example.com/file", true},
+		{"https://example.com/page", "https://example.com:8443/file", true},
+		{"https://example.com/page", "https://example.org/file", false},
+		{"https://a.github.io/page", "https://b.github.io/file", false},
+		{"http://127.0.0.1:80/page", "http://10.0.0.1/file", false},
+		{"http://127.0.0.1/page", "http://localhost/file", false},
+	}
+	for _, tt := range tests {
+		if got := sameSite(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameSite(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
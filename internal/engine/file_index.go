@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// fileReconcileInterval is how often the background scan re-checks that
+// downloaded files are still on disk.
+const fileReconcileInterval = 30 * time.Second
+
+type fileState struct {
+	path   string
+	exists bool
+}
+
+// fileIndex caches whether each task's SavePath exists so UI refreshes don't
+// stat every file. Entries are keyed by task ID and invalidated when the
+// task's path changes.
+type fileIndex struct {
+	mu      sync.RWMutex
+	entries map[string]fileState
+}
+
+func newFileIndex() *fileIndex {
+	return &fileIndex{entries: make(map[string]fileState)}
+}
+
+func (fi *fileIndex) get(id, path string) (exists, ok bool) {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+	st, ok := fi.entries[id]
+	if !ok || st.path != path {
+		return false, false
+	}
+	return st.exists, true
+}
+
+// set stores the state for id and returns the previous state for the same
+// path, if any.
+func (fi *fileIndex) set(id, path string, exists bool) (prev, hadPrev bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	old, ok := fi.entries[id]
+	fi.entries[id] = fileState{path: path, exists: exists}
+	if !ok || old.path != path {
+		return false, false
+	}
+	return old.exists, true
+}
+
+func (fi *fileIndex) remove(id string) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	delete(fi.entries, id)
+}
+
+// FileExists reports whether the task's file is on disk, using the cached
+// state when available and statting once otherwise.
+func (e *TachyonEngine) FileExists(id, path string) bool {
+	if path == "" {
+		return false
+	}
+	if exists, ok := e.files.get(id, path); ok {
+		return exists
+	}
+	_, err := os.Stat(path)
+	exists := err == nil
+	e.files.set(id, path, exists)
+	return exists
+}
+
+// markFileState records a known file state without touching the disk.
+func (e *TachyonEngine) markFileState(id, path string, exists bool) {
+	if path == "" {
+		return
+	}
+	e.files.set(id, path, exists)
+}
+
+// ReconcileFiles stats every task's SavePath, refreshes the cache and emits
+// download:file_missing for completed downloads whose file was present on
+// the previous check but has since been removed outside the app.
+func (e *TachyonEngine) ReconcileFiles() {
+	tasks, err := e.storage.GetAllTasks()
+	if err != nil {
+		e.logger.Warn("File reconciliation failed", "error", err)
+		return
+	}
+	live := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		live[task.ID] = true
+		if task.SavePath == "" {
+			continue
+		}
+		_, statErr := os.Stat(task.SavePath)
+		exists := statErr == nil
+		prev, hadPrev := e.files.set(task.ID, task.SavePath, exists)
+		if task.Status == storage.StatusCompleted && hadPrev && prev && !exists {
+			e.logger.Info("Completed file missing on disk", "id", task.ID, "path", task.SavePath)
+			e.emit("download:file_missing", map[string]interface{}{
+				"id":   task.ID,
+				"path": task.SavePath,
+			})
+		}
+	}
+
+	// Drop entries for deleted tasks
+	e.files.mu.Lock()
+	for id := range e.files.entries {
+		if !live[id] {
+			delete(e.files.entries, id)
+		}
+	}
+	e.files.mu.Unlock()
+}
+
+// fileReconcileLoop runs ReconcileFiles periodically until ctx is done.
+func (e *TachyonEngine) fileReconcileLoop(ctx context.Context) {
+	e.ReconcileFiles()
+	ticker := time.NewTicker(fileReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.ReconcileFiles()
+		}
+	}
+}
+
+// PopulateFileExists fills FileExists on tasks from the cache.
+func (e *TachyonEngine) PopulateFileExists(tasks []storage.DownloadTask) {
+	for i := range tasks {
+		tasks[i].FileExists = e.FileExists(tasks[i].ID, tasks[i].SavePath)
+	}
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestReconcileFiles_EmitsMissingForDeletedCompletedFile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	var mu sync.Mutex
+	var missing []string
+	e.eventHook = func(name string, data interface{}) {
+		if name == "download:file_missing" {
+			mu.Lock()
+			missing = append(missing, data.(map[string]interface{})["id"].(string))
+			mu.Unlock()
+		}
+	}
+
+	dir := t.TempDir()
+	done := filepath.Join(dir, "done.zip")
+	os.WriteFile(done, []byte("data"), 0644)
+	s.SaveTask(storage.DownloadTask{ID: "done", SavePath: done, Status: "completed"})
+	// Never present: no event, it wasn't "previously present"
+	s.SaveTask(storage.DownloadTask{ID: "gone", SavePath: filepath.Join(dir, "gone.zip"), Status: "completed"})
+
+	e.ReconcileFiles()
+	if !e.FileExists("done", done) {
+		t.Fatal("expected cached FileExists=true after first scan")
+	}
+
+	os.Remove(done)
+	// The cache isn't refreshed until the next scan
+	if !e.FileExists("done", done) {
+		t.Error("FileExists should be served from cache between scans")
+	}
+
+	e.ReconcileFiles()
+	if e.FileExists("done", done) {
+		t.Error("expected cached FileExists=false after rescan")
+	}
+	mu.Lock()
+	if len(missing) != 1 || missing[0] != "done" {
+		t.Errorf("file_missing events = %v, want [done]", missing)
+	}
+	mu.Unlock()
+
+	// Still missing on the next scan: no duplicate event
+	e.ReconcileFiles()
+	mu.Lock()
+	if len(missing) != 1 {
+		t.Errorf("expected a single file_missing event, got %d", len(missing))
+	}
+	mu.Unlock()
+}
+
+func TestFileExists_PathChangeInvalidatesCache(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	e := NewEngine(logger, createDownloadsTestDB(t))
+
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.bin")
+	newPath := filepath.Join(dir, "new.bin")
+	os.WriteFile(newPath, []byte("x"), 0644)
+
+	if e.FileExists("t1", oldPath) {
+		t.Error("old path does not exist")
+	}
+	if !e.FileExists("t1", newPath) {
+		t.Error("new path should be statted, not served from the old entry")
+	}
+	if e.FileExists("t1", "") {
+		t.Error("empty path never exists")
+	}
+}
+
+func TestPopulateFileExists(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	e := NewEngine(logger, createDownloadsTestDB(t))
+
+	present := filepath.Join(t.TempDir(), "here.txt")
+	os.WriteFile(present, []byte("x"), 0644)
+	tasks := []storage.DownloadTask{
+		{ID: "a", SavePath: present},
+		{ID: "b", SavePath: present + ".missing"},
+	}
+	e.PopulateFileExists(tasks)
+	if !tasks[0].FileExists || tasks[1].FileExists {
+		t.Errorf("FileExists = %v/%v, want true/false", tasks[0].FileExists, tasks[1].FileExists)
+	}
+}
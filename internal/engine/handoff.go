@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// handoffVersion is the current HandoffToken format.
+const handoffVersion = 1
+
+// HandoffToken is the portable state of an unfinished download, passed
+// between instances by ExportTaskState and ImportTaskState. It carries the
+// request's headers and cookies, so it is as sensitive as they are.
+type HandoffToken struct {
+	Version       int                 `json:"v"`
+	ID            string              `json:"id"` // names the part files
+	URL           string              `json:"url"`
+	Filename      string              `json:"filename"`
+	Headers       string              `json:"headers,omitempty"`
+	Cookies       string              `json:"cookies,omitempty"`
+	TotalSize     int64               `json:"total_size"`
+	Downloaded    int64               `json:"downloaded"`
+	ExpectedHash  string              `json:"hash,omitempty"`
+	HashAlgorithm string              `json:"hash_algo,omitempty"`
+	Connections   int                 `json:"connections,omitempty"`
+	State         *CompactResumeState `json:"state,omitempty"` // ETag and completed-part bitfield
+}
+
+// ExportTaskState returns a token from which ImportTaskState can recreate
+// an unfinished download on another instance. Moving the download's part
+// files along with it lets the other instance resume instead of starting
+// over.
+func (e *TachyonEngine) ExportTaskState(id string) (string, error) {
+	task, err := e.storage.GetTask(id)
+	if err != nil {
+		return "", fmt.Errorf("task not found: %w", err)
+	}
+	if task.Status == storage.StatusCompleted || task.Status == storage.StatusPartial {
+		return "", fmt.Errorf("download %s is already complete", id)
+	}
+
+	meta, downloaded := task.MetaJSON, task.Downloaded
+	if v, ok := e.activeDownloads.Load(id); ok {
+		if cp := v.(*activeDownloadInfo).checkpoint.Load(); cp != nil {
+			meta, downloaded = cp.MetaJSON, cp.Downloaded
+		}
+	}
+	token := HandoffToken{
+		Version:       handoffVersion,
+		ID:            task.ID,
+		URL:           task.URL,
+		Filename:      task.Filename,
+		Headers:       task.Headers,
+		Cookies:       task.Cookies,
+		TotalSize:     task.TotalSize,
+		Downloaded:    downloaded,
+		ExpectedHash:  task.ExpectedHash,
+		HashAlgorithm: task.HashAlgorithm,
+		Connections:   task.Connections,
+	}
+	state, err := e.loadState(meta)
+	if err != nil {
+		e.logger.Warn("Exporting download without its resume state", "id", id, "error", err)
+	} else if state != nil {
+		numParts := 0
+		for partID, part := range state.Parts {
+			if part.Complete && partID >= numParts {
+				numParts = partID + 1
+			}
+		}
+		token.State = e.stateManager.ToCompact(state, numParts)
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ImportTaskState recreates a download from an ExportTaskState token and
+// queues it. newPath is the file to download to, or a directory to put the
+// token's file name in. Part files moved into the parts folder beside
+// newPath (or the temp download dir) are resumed from. It returns the new
+// task's ID.
+func (e *TachyonEngine) ImportTaskState(blob, newPath string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(blob))
+	if err != nil {
+		return "", fmt.Errorf("invalid handoff token: %w", err)
+	}
+	var token HandoffToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("invalid handoff token: %w", err)
+	}
+	if token.Version != handoffVersion {
+		return "", fmt.Errorf("unsupported handoff token version %d", token.Version)
+	}
+	if err := e.validateURL(token.URL); err != nil {
+		return "", err
+	}
+
+	if fi, err := os.Stat(newPath); err == nil && fi.IsDir() {
+		name := SanitizeFilename(token.Filename)
+		if name == "" {
+			name = "download"
+		}
+		newPath = filepath.Join(newPath, name)
+	}
+	savePath, err := filepath.Abs(newPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	// Never write over a file already there or one another download
+	// is heading for, same as StartDownload
+	savePath = filesystem.FindAvailablePathExcluding(fitPath(savePath), e.getReservedPaths())
+
+	// Part files are named after the task, so keep the exported ID unless
+	// this instance already has a task with it; the new task then takes
+	// over the parts, or a copy of them while that task still uses them.
+	// IDs that aren't UUIDs never reach a file name.
+	id := token.ID
+	if _, err := uuid.Parse(id); err != nil {
+		id = uuid.New().String()
+	} else if existing, err := e.storage.GetTask(id); err == nil {
+		id = uuid.New().String()
+		e.adoptPartFiles(token.ID, id, savePath, e.partsDirForTask(existing.ID, existing.SavePath))
+	}
+
+	var meta string
+	if token.State != nil {
+		if meta, err = e.stateManager.Serialize(e.stateManager.FromCompact(token.State)); err != nil {
+			return "", err
+		}
+	}
+	var progress float64
+	if token.TotalSize > 0 {
+		progress = float64(token.Downloaded) / float64(token.TotalSize) * 100
+	}
+	now := time.Now().Format(time.RFC3339)
+	task := storage.DownloadTask{
+		ID:            id,
+		URL:           token.URL,
+		Filename:      filepath.Base(savePath),
+		SavePath:      savePath,
+		Status:        storage.StatusPaused,
+		Category:      filesystem.GetCategory(filepath.Base(savePath)),
+		TotalSize:     token.TotalSize,
+		Downloaded:    token.Downloaded,
+		Progress:      progress,
+		MetaJSON:      meta,
+		QueueOrder:    e.queue.GetNextOrder(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Headers:       token.Headers,
+		Cookies:       token.Cookies,
+		Connections:   token.Connections,
+		Priority:      e.GetDefaultPriority(),
+		ExpectedHash:  token.ExpectedHash,
+		HashAlgorithm: token.HashAlgorithm,
+	}
+	if err := e.saveNewTask(task); err != nil {
+		return "", fmt.Errorf("failed to persist download: %w", err)
+	}
+	e.logger.Info("Imported download", "id", id, "from", token.ID, "path", savePath, "downloaded", token.Downloaded)
+
+	// ResumeDownload starts over if no part files came along
+	if err := e.ResumeDownload(id); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+// adoptPartFiles gives part files left under fromID to toID, in the parts
+// folder a download to savePath would use. They are renamed, unless that is
+// ownerDir, the folder the local task with fromID keeps its own parts in:
+// then they are copied, so that task can still resume.
+func (e *TachyonEngine) adoptPartFiles(fromID, toID, savePath, ownerDir string) {
+	dir := e.partsDirForTask(fromID, savePath)
+	owned := filepath.Clean(dir) == filepath.Clean(ownerDir)
+	matches, _ := filepath.Glob(filepath.Join(dir, fromID+".part.*"))
+	for _, m := range matches {
+		adopted := filepath.Join(dir, toID+strings.TrimPrefix(filepath.Base(m), fromID))
+		var err error
+		if owned {
+			err = copyPartFile(m, adopted)
+		} else {
+			err = os.Rename(m, adopted)
+		}
+		if err != nil {
+			e.logger.Warn("Failed to adopt part file", "path", m, "error", err)
+		}
+	}
+}
+
+func copyPartFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
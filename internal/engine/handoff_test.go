@@ -0,0 +1,314 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestExportImportTaskState_ResumesPartialDownload(t *testing.T) {
+	content := generateDummyContent(8 * 1024 * 1024)
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); r.Method == http.MethodGet && rng != "" {
+			mu.Lock()
+			requested = append(requested, rng)
+			mu.Unlock()
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// A paused download on the first instance with half its parts on disk
+	src, srcStore := newLoopbackEngine(t)
+	src.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	srcPath := filepath.Join(t.TempDir(), "file.bin")
+	task := storage.DownloadTask{
+		ID:        uuid.New().String(),
+		URL:       server.URL + "/file.bin",
+		Filename:  "file.bin",
+		SavePath:  srcPath,
+		Status:    storage.StatusPaused,
+		TotalSize: int64(len(content)),
+		Headers:   `{"X-Token":"abc"}`,
+	}
+	parts := src.planDownloadParts(task.TotalSize, true)
+	if len(parts) < 4 {
+		t.Fatalf("planned %d parts, want several", len(parts))
+	}
+	plan := make(map[int]DownloadPart)
+	done := make(map[int]bool)
+	srcParts := tempDirForTask(srcPath)
+	os.MkdirAll(srcParts, 0755)
+	for _, p := range parts {
+		plan[p.ID] = p
+		if p.ID%2 == 0 {
+			done[p.ID] = true
+			name := fmt.Sprintf("%s.part.%d", task.ID, p.StartOffset)
+			os.WriteFile(filepath.Join(srcParts, name), content[p.StartOffset:p.EndOffset+1], 0644)
+			task.Downloaded += p.EndOffset - p.StartOffset + 1
+		}
+	}
+	task.MetaJSON = src.serializeState(&task, &ProbeResult{ETag: `"v1"`, AcceptRanges: true}, time.Time{}, done, plan)
+	srcStore.SaveTask(task)
+
+	token, err := src.ExportTaskState(task.ID)
+	if err != nil {
+		t.Fatalf("ExportTaskState: %v", err)
+	}
+
+	// Move the part files to the second instance and import there
+	dst, dstStore := newLoopbackEngine(t)
+	dst.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	dstDir := t.TempDir()
+	dstParts := tempDirForTask(filepath.Join(dstDir, "file.bin"))
+	os.MkdirAll(dstParts, 0755)
+	entries, _ := os.ReadDir(srcParts)
+	for _, entry := range entries {
+		data, _ := os.ReadFile(filepath.Join(srcParts, entry.Name()))
+		os.WriteFile(filepath.Join(dstParts, entry.Name()), data, 0644)
+	}
+
+	id, err := dst.ImportTaskState(token, dstDir)
+	if err != nil {
+		t.Fatalf("ImportTaskState: %v", err)
+	}
+	if id != task.ID {
+		t.Errorf("imported ID = %s, want the exported %s", id, task.ID)
+	}
+	imported := waitForFinalStatus(t, dstStore, id)
+	if imported.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", imported.Status)
+	}
+	if imported.SavePath != filepath.Join(dstDir, "file.bin") || imported.Headers != task.Headers {
+		t.Errorf("imported task = %q headers %q", imported.SavePath, imported.Headers)
+	}
+	got, err := os.ReadFile(imported.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded file does not match the served content")
+	}
+
+	// Only the missing parts were fetched again
+	mu.Lock()
+	defer mu.Unlock()
+	for _, rng := range requested {
+		for id := range done {
+			p := plan[id]
+			if strings.HasPrefix(rng, fmt.Sprintf("bytes=%d-", p.StartOffset)) {
+				t.Errorf("completed part %d fetched again (%s)", id, rng)
+			}
+		}
+	}
+}
+
+func TestImportTaskState_ExistingIDTakesOverParts(t *testing.T) {
+	// The imported copy never gets a response, so it stays put
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	dir := t.TempDir()
+	task := storage.DownloadTask{
+		ID:       uuid.New().String(),
+		URL:      server.URL + "/file.bin",
+		Filename: "file.bin",
+		SavePath: filepath.Join(dir, "file.bin"),
+		Status:   storage.StatusPaused,
+	}
+	store.SaveTask(task)
+	parts := tempDirForTask(task.SavePath)
+	os.MkdirAll(parts, 0755)
+	os.WriteFile(filepath.Join(parts, task.ID+".part.0"), []byte("data"), 0644)
+
+	token, err := e.ExportTaskState(task.ID)
+	if err != nil {
+		t.Fatalf("ExportTaskState: %v", err)
+	}
+	id, err := e.ImportTaskState(token, filepath.Join(dir, "copy.bin"))
+	if err != nil {
+		t.Fatalf("ImportTaskState: %v", err)
+	}
+	if id == task.ID {
+		t.Fatal("import reused an ID already in use")
+	}
+	if data, err := os.ReadFile(filepath.Join(parts, id+".part.0")); err != nil || string(data) != "data" {
+		t.Errorf("part file not adopted: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(parts, task.ID+".part.0")); err != nil {
+		t.Errorf("the existing task lost its part file: %v", err)
+	}
+}
+
+func TestImportTaskState_ExistingIDElsewhereMovesParts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	task := storage.DownloadTask{
+		ID:       uuid.New().String(),
+		URL:      server.URL + "/file.bin",
+		Filename: "file.bin",
+		SavePath: filepath.Join(t.TempDir(), "file.bin"),
+		Status:   storage.StatusPaused,
+	}
+	store.SaveTask(task)
+	token, err := e.ExportTaskState(task.ID)
+	if err != nil {
+		t.Fatalf("ExportTaskState: %v", err)
+	}
+
+	// Parts carried over by hand into another folder belong to no local task
+	dir := t.TempDir()
+	parts := tempDirForTask(filepath.Join(dir, "copy.bin"))
+	os.MkdirAll(parts, 0755)
+	os.WriteFile(filepath.Join(parts, task.ID+".part.0"), []byte("data"), 0644)
+
+	id, err := e.ImportTaskState(token, filepath.Join(dir, "copy.bin"))
+	if err != nil {
+		t.Fatalf("ImportTaskState: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parts, id+".part.0")); err != nil {
+		t.Errorf("part file not adopted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parts, task.ID+".part.0")); !os.IsNotExist(err) {
+		t.Errorf("part file was copied rather than moved: %v", err)
+	}
+}
+
+func TestImportTaskState_RejectsBadTokens(t *testing.T) {
+	e, _ := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	for _, blob := range []string{"", "not base64!", "e30", "eyJ2Ijo5OX0"} { // "{}", {"v":99}
+		if _, err := e.ImportTaskState(blob, t.TempDir()); err == nil {
+			t.Errorf("ImportTaskState(%q) succeeded", blob)
+		}
+	}
+	if _, err := e.ExportTaskState("missing"); err == nil {
+		t.Error("expected an error exporting an unknown task")
+	}
+}
+
+func TestImportTaskState_AvoidsTakenPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	task := storage.DownloadTask{
+		ID:       uuid.New().String(),
+		URL:      server.URL + "/file.bin",
+		Filename: "file.bin",
+		SavePath: filepath.Join(t.TempDir(), "file.bin"),
+		Status:   storage.StatusPaused,
+	}
+	store.SaveTask(task)
+	token, err := e.ExportTaskState(task.ID)
+	if err != nil {
+		t.Fatalf("ExportTaskState: %v", err)
+	}
+
+	// The target folder has the file already, and another download is
+	// heading for the next free name
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "file.bin")
+	os.WriteFile(existing, []byte("keep me"), 0644)
+	store.SaveTask(storage.DownloadTask{
+		ID:       uuid.New().String(),
+		URL:      server.URL + "/other.bin",
+		Filename: "file (1).bin",
+		SavePath: filepath.Join(dir, "file (1).bin"),
+		Status:   storage.StatusDownloading,
+	})
+
+	id, err := e.ImportTaskState(token, dir)
+	if err != nil {
+		t.Fatalf("ImportTaskState: %v", err)
+	}
+	imported, _ := store.GetTask(id)
+	if want := filepath.Join(dir, "file (2).bin"); imported.SavePath != want {
+		t.Errorf("imported path = %q, want %q", imported.SavePath, want)
+	}
+	if imported.Filename != "file (2).bin" {
+		t.Errorf("imported filename = %q", imported.Filename)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "keep me" {
+		t.Errorf("existing file changed: %q", data)
+	}
+}
+
+func TestImportTaskState_RefetchesMissingStreamPart(t *testing.T) {
+	content := generateDummyContent(256 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No ranges and no length: the download is a single stream part
+		w.Write(content[:128*1024])
+		w.(http.Flusher).Flush()
+		w.Write(content[128*1024:])
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+	task := storage.DownloadTask{
+		ID:       uuid.New().String(),
+		URL:      server.URL + "/stream.bin",
+		Filename: "stream.bin",
+		SavePath: filepath.Join(t.TempDir(), "stream.bin"),
+		Status:   storage.StatusPaused,
+	}
+	parts := e.planDownloadParts(0, false)
+	plan := map[int]DownloadPart{parts[0].ID: parts[0]}
+	done := map[int]bool{parts[0].ID: true}
+	task.MetaJSON = e.serializeState(&task, &ProbeResult{}, time.Time{}, done, plan)
+	store.SaveTask(task)
+	token, err := e.ExportTaskState(task.ID)
+	if err != nil {
+		t.Fatalf("ExportTaskState: %v", err)
+	}
+
+	// The token says the stream finished, but its part file didn't come
+	// along; another download's parts keep the folder from looking fresh
+	dir := t.TempDir()
+	partsDir := tempDirForTask(filepath.Join(dir, "stream.bin"))
+	os.MkdirAll(partsDir, 0755)
+	os.WriteFile(filepath.Join(partsDir, uuid.New().String()+".part.0"), []byte("other"), 0644)
+
+	id, err := e.ImportTaskState(token, dir)
+	if err != nil {
+		t.Fatalf("ImportTaskState: %v", err)
+	}
+	imported := waitForFinalStatus(t, store, id)
+	if imported.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", imported.Status)
+	}
+	got, err := os.ReadFile(imported.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("merged %d bytes, want the %d-byte stream", len(got), len(content))
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import "time"
+
+const (
+	// healthGoodRTT and healthBadRTT bound the smoothed time to fetch one
+	// part: at or under the first costs nothing, at the second costs the
+	// full RTT penalty.
+	healthGoodRTT = 2 * time.Second
+	healthBadRTT  = 30 * time.Second
+
+	// healthGoodLineShare is the share of line capacity at which a download
+	// is considered to be using the connection well.
+	healthGoodLineShare = 0.5
+
+	// healthSmoothing weights the newest score against the running value so
+	// one bad second doesn't flip the indicator.
+	healthSmoothing = 0.3
+)
+
+// healthSignals are the inputs to a download's health score.
+type healthSignals struct {
+	Retries   int           // part attempts that failed and were retried
+	PartsDone int           // parts finished successfully
+	RTT       time.Duration // smoothed time to fetch a part from this host
+	Speed     float64       // achieved bytes/sec
+	LineSpeed int64         // line capacity in bytes/sec, 0 = unknown
+}
+
+// healthScore rates a download from 0 (failing) to 100 (healthy). Errors
+// weigh most, then retries, slow parts and under-use of the line.
+func healthScore(s healthSignals) int {
+	score := 100.0
+
+	if attempts := s.Retries + s.PartsDone; attempts > 0 {
+		errorRate := float64(s.Retries) / float64(attempts)
+		score -= 40 * min(errorRate*2, 1)
+	}
+	score -= float64(min(s.Retries*5, 20))
+
+	if s.RTT > healthGoodRTT {
+		over := float64(s.RTT-healthGoodRTT) / float64(healthBadRTT-healthGoodRTT)
+		score -= 20 * min(over, 1)
+	}
+
+	if s.LineSpeed > 0 {
+		share := s.Speed / float64(s.LineSpeed)
+		score -= 20 * (1 - min(share/healthGoodLineShare, 1))
+	}
+
+	return int(max(score, 0) + 0.5)
+}
+
+// healthTracker smooths health scores across progress ticks.
+type healthTracker struct {
+	value float64
+	seen  bool
+}
+
+// update folds the score for s into the running value and returns it.
+func (h *healthTracker) update(s healthSignals) int {
+	score := float64(healthScore(s))
+	if !h.seen {
+		h.value, h.seen = score, true
+	} else {
+		h.value = (1-healthSmoothing)*h.value + healthSmoothing*score
+	}
+	return int(h.value + 0.5)
+}
+
+// DownloadHealth returns the health score of a running download and
+// whether it is running.
+func (e *TachyonEngine) DownloadHealth(id string) (int, bool) {
+	val, ok := e.activeDownloads.Load(id)
+	if !ok {
+		return 0, false
+	}
+	info, ok := val.(*activeDownloadInfo)
+	if !ok {
+		return 0, false
+	}
+	return int(info.Health.Load()), true
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthScore(t *testing.T) {
+	const line = 10 * 1024 * 1024
+	tests := []struct {
+		name     string
+		s        healthSignals
+		min, max int
+	}{
+		{"perfect", healthSignals{PartsDone: 20, RTT: time.Second, Speed: line, LineSpeed: line}, 100, 100},
+		{"no data yet", healthSignals{}, 100, 100},
+		{"one retry", healthSignals{Retries: 1, PartsDone: 20, RTT: time.Second}, 85, 95},
+		{"half the parts failing", healthSignals{Retries: 10, PartsDone: 10, RTT: time.Second}, 40, 40},
+		{"slow parts", healthSignals{PartsDone: 20, RTT: healthBadRTT}, 80, 80},
+		{"using a tenth of the line", healthSignals{PartsDone: 20, Speed: line / 10, LineSpeed: line}, 80, 90},
+		{"everything wrong", healthSignals{Retries: 30, PartsDone: 1, RTT: time.Minute, Speed: 0, LineSpeed: line}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := healthScore(tt.s)
+			if got < tt.min || got > tt.max {
+				t.Errorf("healthScore = %d, want %d..%d", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestHealthTracker_FollowsSignals(t *testing.T) {
+	const line = 10 * 1024 * 1024
+	var h healthTracker
+
+	good := healthSignals{PartsDone: 10, RTT: time.Second, Speed: line, LineSpeed: line}
+	for i := 0; i < 5; i++ {
+		if got := h.update(good); got < 95 {
+			t.Fatalf("tick %d: healthy download scored %d", i, got)
+		}
+		good.PartsDone++
+	}
+
+	// The source starts failing: retries mount, parts crawl, speed collapses.
+	bad := good
+	var score int
+	for i := 0; i < 10; i++ {
+		bad.Retries += 2
+		bad.RTT += 3 * time.Second
+		bad.Speed = line / 20
+		prev := score
+		score = h.update(bad)
+		if i > 0 && score > prev {
+			t.Fatalf("tick %d: score rose from %d to %d while the source degraded", i, prev, score)
+		}
+	}
+	if score > 30 {
+		t.Errorf("failing download still scored %d", score)
+	}
+
+	// One good second doesn't erase the history.
+	recovered := healthSignals{PartsDone: 100, RTT: time.Second, Speed: line, LineSpeed: line}
+	if got := h.update(recovered); got >= 60 {
+		t.Errorf("score jumped to %d after a single good tick", got)
+	}
+	for i := 0; i < 20; i++ {
+		score = h.update(recovered)
+	}
+	if score < 95 {
+		t.Errorf("score = %d after sustained recovery, want >= 95", score)
+	}
+}
@@ -0,0 +1,698 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+const (
+	// maxHLSSegmentAttempts is how often a segment is tried before the
+	// download fails.
+	maxHLSSegmentAttempts = 3
+
+	// maxHLSKeySize bounds the body read for an AES key URI.
+	maxHLSKeySize = 1024
+)
+
+// hlsKey is an EXT-X-KEY in effect for a run of segments.
+type hlsKey struct {
+	Method string // "AES-128"
+	URI    string
+	IV     []byte // nil: derived from the media sequence number
+}
+
+type hlsSegment struct {
+	URI string
+	Seq int64
+	Key *hlsKey
+}
+
+type hlsVariant struct {
+	URI       string
+	Bandwidth int
+}
+
+// hlsPlaylist is a parsed master or media playlist.
+type hlsPlaylist struct {
+	Variants []hlsVariant // master playlist
+	Segments []hlsSegment // media playlist
+	Init     *hlsSegment  // EXT-X-MAP initialization section (fMP4)
+	Live     bool         // no EXT-X-ENDLIST: only the listed segments are fetched
+}
+
+// isHLSPlaylist reports whether a URL or Content-Type denotes an m3u8 playlist.
+func isHLSPlaylist(urlStr, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch strings.ToLower(mediaType) {
+		case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+			return true
+		}
+	}
+	if u, err := url.Parse(urlStr); err == nil {
+		return strings.EqualFold(path.Ext(u.Path), ".m3u8")
+	}
+	return false
+}
+
+// parseHLSPlaylist parses an m3u8 playlist, resolving URIs against base.
+func parseHLSPlaylist(body []byte, base *url.URL) (*hlsPlaylist, error) {
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !sc.Scan() || strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff")) != "#EXTM3U" {
+		return nil, fmt.Errorf("not an m3u8 playlist")
+	}
+
+	pl := &hlsPlaylist{Live: true}
+	var seq int64
+	var key *hlsKey
+	var pendingVariant *hlsVariant
+	inSegment := false
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseHLSAttrs(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bw, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pendingVariant = &hlsVariant{Bandwidth: bw}
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			n, err := strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid media sequence: %w", err)
+			}
+			seq = n
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseHLSAttrs(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			switch attrs["METHOD"] {
+			case "NONE":
+				key = nil
+			case "AES-128":
+				k := &hlsKey{Method: "AES-128", URI: resolveHLSURI(base, attrs["URI"])}
+				if iv := attrs["IV"]; iv != "" {
+					raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+					if err != nil || len(raw) != aes.BlockSize {
+						return nil, fmt.Errorf("invalid key IV %q", iv)
+					}
+					k.IV = raw
+				}
+				if k.URI == "" {
+					return nil, fmt.Errorf("AES-128 key without URI")
+				}
+				key = k
+			default:
+				return nil, fmt.Errorf("unsupported HLS encryption %q", attrs["METHOD"])
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			attrs := parseHLSAttrs(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			if attrs["BYTERANGE"] != "" {
+				return nil, fmt.Errorf("byte-range HLS segments are not supported")
+			}
+			pl.Init = &hlsSegment{URI: resolveHLSURI(base, attrs["URI"]), Seq: seq, Key: key}
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			return nil, fmt.Errorf("byte-range HLS segments are not supported")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			inSegment = true
+		case line == "#EXT-X-ENDLIST":
+			pl.Live = false
+		case strings.HasPrefix(line, "#"):
+			// Other tags don't affect what is downloaded
+		default:
+			uri := resolveHLSURI(base, line)
+			if pendingVariant != nil {
+				pendingVariant.URI = uri
+				pl.Variants = append(pl.Variants, *pendingVariant)
+				pendingVariant = nil
+			} else if inSegment {
+				pl.Segments = append(pl.Segments, hlsSegment{URI: uri, Seq: seq, Key: key})
+				seq++
+				inSegment = false
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(pl.Variants) == 0 && len(pl.Segments) == 0 {
+		return nil, fmt.Errorf("playlist has no segments")
+	}
+	return pl, nil
+}
+
+// parseHLSAttrs splits an attribute list (KEY=value,KEY="quoted, value").
+func parseHLSAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+			s = strings.TrimPrefix(s, ",")
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			val, s = s[:comma], s[comma+1:]
+		} else {
+			val, s = s, ""
+		}
+		attrs[name] = val
+	}
+	return attrs
+}
+
+func resolveHLSURI(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// decryptAES128 decrypts an AES-128-CBC segment and strips PKCS#7 padding.
+func decryptAES128(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment size %d is not a multiple of the block size", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) {
+		return nil, fmt.Errorf("invalid segment padding")
+	}
+	for _, b := range out[len(out)-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("invalid segment padding")
+		}
+	}
+	return out[:len(out)-pad], nil
+}
+
+// sequenceIV is the default IV for a segment: its media sequence number as a
+// 128-bit big-endian integer.
+func sequenceIV(seq int64) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(seq))
+	return iv
+}
+
+// hlsSegmentPath is where segment i is kept until the download is assembled.
+func hlsSegmentPath(tempDir, taskID string, i int) string {
+	return filepath.Join(tempDir, fmt.Sprintf("%s.seg.%d", taskID, i))
+}
+
+// hlsListPath holds the hlsFingerprint of the segment list the kept
+// segments were fetched from.
+func hlsListPath(tempDir, taskID string) string {
+	return filepath.Join(tempDir, taskID+".hls")
+}
+
+// hlsFingerprint identifies a segment list by each segment's media
+// sequence number and URI. Queries are left out, since signed segment URLs
+// change on every playlist fetch.
+func hlsFingerprint(segments []hlsSegment) string {
+	h := sha256.New()
+	for _, seg := range segments {
+		uri := seg.URI
+		if u, err := url.Parse(uri); err == nil {
+			u.RawQuery, u.Fragment = "", ""
+			uri = u.String()
+		}
+		fmt.Fprintf(h, "%d %s\n", seg.Seq, uri)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keepHLSSegments drops the segments kept for taskID unless they were
+// fetched from the same segment list, since they are matched up by index,
+// and records the list for the next run. Segments from a run that left no
+// record are kept.
+func (e *TachyonEngine) keepHLSSegments(tempDir, taskID string, segments []hlsSegment) {
+	fingerprint := hlsFingerprint(segments)
+	listPath := hlsListPath(tempDir, taskID)
+	if kept, err := os.ReadFile(listPath); err == nil && string(kept) != fingerprint {
+		e.logger.Info("HLS playlist changed since the last run, fetching every segment again", "id", taskID)
+		matches, _ := filepath.Glob(filepath.Join(tempDir, taskID+".seg.*"))
+		for _, m := range matches {
+			os.Remove(m)
+		}
+	}
+	if err := os.WriteFile(listPath, []byte(fingerprint), 0644); err != nil {
+		e.logger.Warn("Failed to record HLS segment list", "id", taskID, "error", err)
+	}
+}
+
+// hlsJob downloads the segments of one media playlist.
+type hlsJob struct {
+	e       *TachyonEngine
+	task    *storage.DownloadTask
+	host    string
+	tempDir string
+
+	keysMu sync.Mutex
+	keys   map[string][]byte
+}
+
+// executeHLS downloads an HLS stream: it resolves a master playlist to its
+// highest-bandwidth variant, fetches the segments (decrypting AES-128 ones)
+// over the task's connections and concatenates them into SavePath.
+// Finished segments are kept across pauses so a resumed download only
+// fetches what's missing, as long as the playlist still lists the same
+// segments.
+func (e *TachyonEngine) executeHLS(ctx context.Context, task *storage.DownloadTask, info *activeDownloadInfo, host string, isH2 bool, startedAt time.Time) {
+	pl, err := e.loadHLSPlaylist(ctx, task)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.failTask(task, fmt.Sprintf("HLS playlist: %v", err))
+		}
+		return
+	}
+	if pl.Live {
+		e.logger.Warn("HLS playlist has no end marker, downloading the segments listed now", "id", task.ID)
+	}
+
+	segments := pl.Segments
+	if pl.Init != nil {
+		segments = append([]hlsSegment{*pl.Init}, segments...)
+	}
+
+	// Name the output after the container rather than the playlist
+	if ext := strings.ToLower(filepath.Ext(task.Filename)); task.Downloaded == 0 && (ext == ".m3u8" || ext == "") {
+		outExt := ".ts"
+		if pl.Init != nil {
+			outExt = ".mp4"
+		}
+		e.renameQueuedTask(task, strings.TrimSuffix(task.Filename, filepath.Ext(task.Filename))+outExt)
+	}
+
+	tempDir := e.partsDirForTask(task.ID, task.SavePath)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		e.failTask(task, fmt.Sprintf("Failed to create temp dir: %v", err))
+		return
+	}
+
+	e.keepHLSSegments(tempDir, task.ID, segments)
+	job := &hlsJob{e: e, task: task, host: host, tempDir: tempDir, keys: make(map[string][]byte)}
+
+	var downloaded int64
+	var done int
+	var pending []int
+	for i := range segments {
+		if fi, err := os.Stat(hlsSegmentPath(tempDir, task.ID, i)); err == nil {
+			downloaded += fi.Size()
+			done++
+			continue
+		}
+		pending = append(pending, i)
+	}
+	total := len(segments)
+
+	task.Status = storage.StatusDownloading
+	task.TotalSize = 0 // unknown until every segment is in
+	e.storage.SaveTask(*task)
+
+	workers := e.workerCountForTask(task, host, len(pending), true, isH2)
+	e.emit("download:progress", map[string]interface{}{
+		"id":             task.ID,
+		"status":         "downloading",
+		"filename":       task.Filename,
+		"url":            task.URL,
+		"category":       task.Category,
+		"path":           task.SavePath,
+		"started_at":     startedAt.Format(time.RFC3339),
+		"connections":    workers,
+		"segments_done":  done,
+		"segments_total": total,
+	})
+
+	segCtx, segCancel := context.WithCancel(ctx)
+	defer segCancel()
+	indexCh := make(chan int, len(pending))
+	for _, i := range pending {
+		indexCh <- i
+	}
+	close(indexCh)
+
+	type segResult struct {
+		size int64
+		err  error
+	}
+	resultCh := make(chan segResult, len(pending))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		e.workerPool.Submit(func() {
+			defer wg.Done()
+			for i := range indexCh {
+				if segCtx.Err() != nil {
+					return
+				}
+				size, err := job.fetchSegment(segCtx, i, segments[i])
+				resultCh <- segResult{size, err}
+				if err != nil {
+					return
+				}
+			}
+		})
+	}
+
+	runStart := time.Now()
+	var runBytes int64
+	var failure error
+	for remaining := len(pending); remaining > 0 && failure == nil; {
+		select {
+		case <-ctx.Done():
+			failure = ctx.Err()
+		case r := <-resultCh:
+			remaining--
+			if r.err != nil {
+				failure = r.err
+				continue
+			}
+			done++
+			downloaded += r.size
+			runBytes += r.size
+			e.bandwidthManager.Observe(r.size)
+			progress := float64(done) / float64(total) * 100
+			var speed float64
+			if secs := time.Since(runStart).Seconds(); secs > 0 {
+				speed = float64(runBytes) / secs
+			}
+			info.Speed.Store(int64(speed))
+			task.Downloaded = downloaded
+			task.Progress = progress
+			e.emit("download:progress", map[string]interface{}{
+				"id":             task.ID,
+				"status":         "downloading",
+				"progress":       progress,
+				"downloaded":     downloaded,
+				"speed":          speed,
+				"segments_done":  done,
+				"segments_total": total,
+				"transferred":    info.Transferred.Load(),
+			})
+		}
+	}
+	segCancel()
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		paused := e.savePaused(task, func(t *storage.DownloadTask) {
+			t.Downloaded = downloaded
+			t.Progress = task.Progress
+			t.Speed = 0
+		})
+		if !paused {
+			return
+		}
+		e.logger.Info("HLS download paused", "id", task.ID, "segments_done", done, "segments_total", total)
+		e.emit("download:paused", map[string]interface{}{
+			"id":         task.ID,
+			"downloaded": downloaded,
+			"progress":   task.Progress,
+		})
+		return
+	}
+	if failure != nil {
+		if errors.Is(failure, ErrLinkExpired) {
+			e.logger.Warn("Link expired - pausing for URL refresh", "id", task.ID)
+			e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+				t.Status = StatusNeedsAuth
+				t.Downloaded = downloaded
+			})
+			task.Status = StatusNeedsAuth
+			e.emit("download:needs_auth", map[string]interface{}{
+				"id":     task.ID,
+				"reason": "Link expired (HTTP 403)",
+			})
+			return
+		}
+		e.failTask(task, fmt.Sprintf("HLS segment failed: %v", failure))
+		return
+	}
+
+	task.Status = storage.StatusMerging
+	e.emit("download:progress", map[string]interface{}{"id": task.ID, "status": "merging"})
+	size, err := e.concatHLSSegments(tempDir, task, total)
+	if err != nil {
+		e.failTask(task, fmt.Sprintf("Merge failed: %v", err))
+		return
+	}
+	os.Remove(tempDir)
+
+	task.Downloaded = size
+	task.TotalSize = size
+	e.completeTask(ctx, task, info, startedAt)
+}
+
+// loadHLSPlaylist fetches the task's playlist, following a master playlist
+// to its highest-bandwidth variant.
+func (e *TachyonEngine) loadHLSPlaylist(ctx context.Context, task *storage.DownloadTask) (*hlsPlaylist, error) {
+	playlistURL := task.URL
+	for depth := 0; depth < 2; depth++ {
+		body, err := e.fetchPage(ctx, playlistURL, task.Headers, task.Cookies)
+		if err != nil {
+			return nil, err
+		}
+		base, err := url.Parse(playlistURL)
+		if err != nil {
+			return nil, err
+		}
+		pl, err := parseHLSPlaylist(body, base)
+		if err != nil {
+			return nil, err
+		}
+		if len(pl.Variants) == 0 {
+			return pl, nil
+		}
+		best := pl.Variants[0]
+		for _, v := range pl.Variants[1:] {
+			if v.Bandwidth > best.Bandwidth {
+				best = v
+			}
+		}
+		e.logger.Info("HLS master playlist, selected variant", "id", task.ID, "bandwidth", best.Bandwidth, "url", best.URI)
+		if err := e.validateURL(best.URI); err != nil {
+			return nil, err
+		}
+		playlistURL = best.URI
+	}
+	return nil, fmt.Errorf("nested master playlists")
+}
+
+// fetchSegment downloads, decrypts and stores segment i, retrying transient
+// failures. It returns the stored size.
+func (j *hlsJob) fetchSegment(ctx context.Context, i int, seg hlsSegment) (int64, error) {
+	if err := j.e.validateURL(seg.URI); err != nil {
+		return 0, err
+	}
+	var data []byte
+	var err error
+	for attempt := 1; attempt <= maxHLSSegmentAttempts; attempt++ {
+		data, err = j.download(ctx, seg.URI, 0)
+		if err == nil || ctx.Err() != nil || errors.Is(err, ErrLinkExpired) {
+			break
+		}
+		j.e.logger.Warn("HLS segment failed, retrying", "id", j.task.ID, "segment", i, "attempt", attempt, "error", err)
+		if attempt < maxHLSSegmentAttempts && !sleepCtx(ctx, time.Duration(1<<(attempt-1))*time.Second) {
+			return 0, ctx.Err()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if seg.Key != nil {
+		key, err := j.key(ctx, seg.Key.URI)
+		if err != nil {
+			return 0, fmt.Errorf("segment %d key: %w", i, err)
+		}
+		iv := seg.Key.IV
+		if iv == nil {
+			iv = sequenceIV(seg.Seq)
+		}
+		if data, err = decryptAES128(data, key, iv); err != nil {
+			return 0, fmt.Errorf("segment %d: %w", i, err)
+		}
+	}
+
+	// Write then rename so a segment file on disk is always complete
+	final := hlsSegmentPath(j.tempDir, j.task.ID, i)
+	tmp := final + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// key returns the AES key at uri, fetching it once per download.
+func (j *hlsJob) key(ctx context.Context, uri string) ([]byte, error) {
+	j.keysMu.Lock()
+	defer j.keysMu.Unlock()
+	if k, ok := j.keys[uri]; ok {
+		return k, nil
+	}
+	if err := j.e.validateURL(uri); err != nil {
+		return nil, err
+	}
+	k, err := j.download(ctx, uri, maxHLSKeySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(k) != aes.BlockSize {
+		return nil, fmt.Errorf("key is %d bytes, want %d", len(k), aes.BlockSize)
+	}
+	j.keys[uri] = k
+	return k, nil
+}
+
+// download GETs uri with the task's headers, counting the bytes against the
+// host connection budget, bandwidth limit and transfer total. limit > 0
+// caps the body size.
+func (j *hlsJob) download(ctx context.Context, uri string, limit int64) ([]byte, error) {
+	e := j.e
+	host := j.host
+	if u, err := url.Parse(uri); err == nil {
+		host = u.Hostname()
+	}
+	if err := e.hostBudget.Acquire(ctx, host); err != nil {
+		return nil, err
+	}
+	defer e.hostBudget.Release(host)
+
+	req, err := e.newRequest("GET", uri, j.task.Headers, j.task.Cookies)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, ErrLinkExpired
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit)
+	}
+	transferred := e.transferCounter(j.task.ID)
+	bufPtr := e.bufferPool.Get().(*[]byte)
+	defer e.bufferPool.Put(bufPtr)
+	buf := *bufPtr
+
+	var out bytes.Buffer
+	if resp.ContentLength > 0 {
+		out.Grow(int(resp.ContentLength))
+	}
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if transferred != nil {
+				transferred.Add(int64(n))
+			}
+			e.sessionBytes.Add(int64(n))
+			if err := e.bandwidthManager.Wait(ctx, j.task.ID, n); err != nil {
+				return nil, err
+			}
+			out.Write(buf[:n])
+		}
+		if readErr == io.EOF {
+			return out.Bytes(), nil
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+}
+
+// concatHLSSegments joins the stored segments in order into the task's
+// SavePath and returns the resulting size.
+func (e *TachyonEngine) concatHLSSegments(tempDir string, task *storage.DownloadTask, n int) (int64, error) {
+	staging := filepath.Join(tempDir, task.ID+".merging")
+	out, err := os.Create(staging)
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	buf := make([]byte, 1024*1024)
+	for i := 0; i < n; i++ {
+		f, err := os.Open(hlsSegmentPath(tempDir, task.ID, i))
+		if err != nil {
+			out.Close()
+			os.Remove(staging)
+			return 0, fmt.Errorf("segment %d missing: %w", i, err)
+		}
+		w, err := io.CopyBuffer(out, f, buf)
+		f.Close()
+		if err != nil {
+			out.Close()
+			os.Remove(staging)
+			return 0, err
+		}
+		written += w
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(staging)
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(task.SavePath), 0755); err != nil {
+		os.Remove(staging)
+		return 0, err
+	}
+	if err := filesystem.MoveFile(staging, task.SavePath); err != nil {
+		os.Remove(staging)
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		os.Remove(hlsSegmentPath(tempDir, task.ID, i))
+	}
+	os.Remove(hlsListPath(tempDir, task.ID))
+	return written, nil
+}
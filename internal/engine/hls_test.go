@@ -0,0 +1,357 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestIsHLSPlaylist(t *testing.T) {
+	tests := []struct {
+		url, contentType string
+		want             bool
+	}{
+		{"https://cdn.example.com/live/index.m3u8", "", true},
+		{"https://cdn.example.com/live/INDEX.M3U8?token=1", "", true},
+		{"https://cdn.example.com/play?id=1", "application/vnd.apple.mpegurl", true},
+		{"https://cdn.example.com/play?id=1", "application/x-mpegURL; charset=utf-8", true},
+		{"https://cdn.example.com/video.mp4", "video/mp4", false},
+	}
+	for _, tt := range tests {
+		if got := isHLSPlaylist(tt.url, tt.contentType); got != tt.want {
+			t.Errorf("isHLSPlaylist(%q, %q) = %v, want %v", tt.url, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestParseHLSPlaylist_Master(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/show/master.m3u8")
+	pl, err := parseHLSPlaylist([]byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720
+/hd/index.m3u8
+`), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pl.Variants) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(pl.Variants))
+	}
+	if pl.Variants[0].URI != "https://cdn.example.com/show/low/index.m3u8" || pl.Variants[0].Bandwidth != 800000 {
+		t.Errorf("unexpected variant %+v", pl.Variants[0])
+	}
+	if pl.Variants[1].URI != "https://cdn.example.com/hd/index.m3u8" {
+		t.Errorf("unexpected variant %+v", pl.Variants[1])
+	}
+}
+
+func TestParseHLSPlaylist_Media(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/show/hd/index.m3u8")
+	pl, err := parseHLSPlaylist([]byte(`#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:10
+#EXTINF:6.0,
+seg10.ts
+#EXT-X-KEY:METHOD=AES-128,URI="keys/k1.bin",IV=0x000102030405060708090a0b0c0d0e0f
+#EXTINF:6.0,
+seg11.ts
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4.5,
+https://other.example.com/seg12.ts
+#EXT-X-ENDLIST
+`), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Live {
+		t.Error("playlist with EXT-X-ENDLIST should not be live")
+	}
+	if len(pl.Segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(pl.Segments))
+	}
+	s0, s1, s2 := pl.Segments[0], pl.Segments[1], pl.Segments[2]
+	if s0.Seq != 10 || s0.Key != nil || s0.URI != "https://cdn.example.com/show/hd/seg10.ts" {
+		t.Errorf("unexpected first segment %+v", s0)
+	}
+	if s1.Key == nil || s1.Key.URI != "https://cdn.example.com/show/hd/keys/k1.bin" || len(s1.Key.IV) != 16 || s1.Key.IV[15] != 0x0f {
+		t.Errorf("unexpected key on second segment %+v", s1.Key)
+	}
+	if s2.Seq != 12 || s2.Key != nil || s2.URI != "https://other.example.com/seg12.ts" {
+		t.Errorf("unexpected third segment %+v", s2)
+	}
+}
+
+func TestParseHLSPlaylist_Rejects(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/index.m3u8")
+	for name, body := range map[string]string{
+		"not m3u8":   "<html></html>",
+		"sample-aes": "#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"k\"\n#EXTINF:1,\na.ts\n",
+		"empty":      "#EXTM3U\n#EXT-X-ENDLIST\n",
+	} {
+		if _, err := parseHLSPlaylist([]byte(body), base); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseHLSAttrs(t *testing.T) {
+	attrs := parseHLSAttrs(`METHOD=AES-128,URI="https://k.example.com/key?a=1,b=2",IV=0x01`)
+	if attrs["METHOD"] != "AES-128" || attrs["URI"] != "https://k.example.com/key?a=1,b=2" || attrs["IV"] != "0x01" {
+		t.Errorf("unexpected attrs %v", attrs)
+	}
+}
+
+// encryptAES128 is the inverse of decryptAES128 (CBC + PKCS#7).
+func encryptAES128(t *testing.T, plain, key, iv []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+	return out
+}
+
+func TestDecryptAES128_RoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := sequenceIV(42)
+	plain := []byte("segment payload that is not block aligned")
+	got, err := decryptAES128(encryptAES128(t, plain, key, iv), key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("round trip mismatch: %q", got)
+	}
+	if _, err := decryptAES128([]byte("short"), key, iv); err == nil {
+		t.Error("expected error for unaligned ciphertext")
+	}
+}
+
+// hlsTestServer serves a master playlist pointing at a media playlist of
+// three segments; the last two are AES-128 encrypted, one with an explicit
+// IV and one with the sequence-number IV.
+func hlsTestServer(t *testing.T, segments [][]byte) (*httptest.Server, *sync.Map) {
+	t.Helper()
+	key := []byte("fedcba9876543210")
+	explicitIV := []byte("ivivivivivivivii")
+	hits := &sync.Map{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/show/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100000\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=900000\nhigh.m3u8\n")
+	})
+	mux.HandleFunc("/show/low.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("low bandwidth variant should not be fetched")
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/show/high.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		fmt.Fprintf(w, `#EXTM3U
+#EXT-X-MEDIA-SEQUENCE:7
+#EXTINF:2.0,
+seg/0.ts
+#EXT-X-KEY:METHOD=AES-128,URI="/keys/k.bin",IV=0x%x
+#EXTINF:2.0,
+seg/1.ts
+#EXT-X-KEY:METHOD=AES-128,URI="/keys/k.bin"
+#EXTINF:2.0,
+seg/2.ts
+#EXT-X-ENDLIST
+`, explicitIV)
+	})
+	mux.HandleFunc("/keys/k.bin", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(key)
+	})
+	mux.HandleFunc("/show/seg/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/show/seg/")
+		n, _ := hits.LoadOrStore(name, new(int))
+		*n.(*int)++
+		switch name {
+		case "0.ts":
+			w.Write(segments[0])
+		case "1.ts":
+			w.Write(encryptAES128(t, segments[1], key, explicitIV))
+		case "2.ts":
+			w.Write(encryptAES128(t, segments[2], key, sequenceIV(9)))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, hits
+}
+
+func TestHLSDownload(t *testing.T) {
+	segments := [][]byte{
+		generateDummyContent(70 * 1024),
+		generateDummyContent(50 * 1024),
+		generateDummyContent(33 * 1024),
+	}
+	server, _ := hlsTestServer(t, segments)
+
+	engine, store := newLoopbackEngine(t)
+
+	var mu sync.Mutex
+	var segProgress []int
+	engine.eventHook = func(name string, data interface{}) {
+		if name != "download:progress" {
+			return
+		}
+		if m, ok := data.(map[string]interface{}); ok {
+			if total, ok := m["segments_total"].(int); ok && total != 3 {
+				t.Errorf("expected segments_total 3, got %d", total)
+			}
+			if done, ok := m["segments_done"].(int); ok {
+				mu.Lock()
+				segProgress = append(segProgress, done)
+				mu.Unlock()
+			}
+		}
+	}
+
+	id, err := engine.StartDownload(server.URL+"/show/master.m3u8", t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(10 * time.Second)
+	var task storage.DownloadTask
+	for task.Status != storage.StatusCompleted {
+		task, _ = store.GetTask(id)
+		if task.Status == storage.StatusError {
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Join(segments, nil)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output mismatch: got %d bytes, want %d", len(got), len(want))
+	}
+	if task.Filename != "master.ts" {
+		t.Errorf("expected output named master.ts, got %q", task.Filename)
+	}
+	if task.TotalSize != int64(len(want)) || task.Downloaded != task.TotalSize {
+		t.Errorf("expected size %d, got total=%d downloaded=%d", len(want), task.TotalSize, task.Downloaded)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(segProgress) == 0 || segProgress[len(segProgress)-1] != 3 {
+		t.Errorf("expected progress to reach 3 segments, got %v", segProgress)
+	}
+}
+
+func TestHLSDownloadResumesSkipsFinishedSegments(t *testing.T) {
+	segments := [][]byte{[]byte("first segment"), []byte("second"), []byte("third")}
+	server, hits := hlsTestServer(t, segments)
+
+	engine, store := newLoopbackEngine(t)
+
+	dest := t.TempDir()
+	// Pretend a previous run already stored segment 0
+	task := storage.DownloadTask{
+		ID:       "hls-resume",
+		URL:      server.URL + "/show/master.m3u8",
+		Filename: "show.ts",
+		SavePath: dest + "/show.ts",
+		Status:   storage.StatusPending,
+	}
+	partsDir := tempDirForTask(task.SavePath)
+	os.MkdirAll(partsDir, 0755)
+	if err := os.WriteFile(hlsSegmentPath(partsDir, task.ID, 0), segments[0], 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.SaveTask(task)
+
+	engine.executeTask(&task)
+
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %q", task.Status)
+	}
+	if _, fetched := hits.Load("0.ts"); fetched {
+		t.Error("segment stored by the previous run was downloaded again")
+	}
+	got, _ := os.ReadFile(task.SavePath)
+	if want := bytes.Join(segments, nil); !bytes.Equal(got, want) {
+		t.Errorf("output mismatch: %q", got)
+	}
+}
+
+func TestHLSDownloadRefetchesWhenPlaylistChanged(t *testing.T) {
+	segments := [][]byte{[]byte("first segment"), []byte("second"), []byte("third")}
+	server, hits := hlsTestServer(t, segments)
+
+	engine, store := newLoopbackEngine(t)
+
+	dest := t.TempDir()
+	task := storage.DownloadTask{
+		ID:       "hls-changed",
+		URL:      server.URL + "/show/master.m3u8",
+		Filename: "show.ts",
+		SavePath: dest + "/show.ts",
+		Status:   storage.StatusPending,
+	}
+	// A previous run stored segment 0 of a playlist that has since moved on
+	partsDir := tempDirForTask(task.SavePath)
+	os.MkdirAll(partsDir, 0755)
+	old := []hlsSegment{{URI: server.URL + "/show/seg/old.ts", Seq: 3}}
+	os.WriteFile(hlsListPath(partsDir, task.ID), []byte(hlsFingerprint(old)), 0644)
+	os.WriteFile(hlsSegmentPath(partsDir, task.ID, 0), []byte("stale segment"), 0644)
+	store.SaveTask(task)
+
+	engine.executeTask(&task)
+
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %q", task.Status)
+	}
+	if _, fetched := hits.Load("0.ts"); !fetched {
+		t.Error("segment from the old playlist was reused")
+	}
+	got, _ := os.ReadFile(task.SavePath)
+	if want := bytes.Join(segments, nil); !bytes.Equal(got, want) {
+		t.Errorf("output mismatch: %q", got)
+	}
+	if _, err := os.Stat(hlsListPath(partsDir, task.ID)); !os.IsNotExist(err) {
+		t.Error("segment list record left behind after assembly")
+	}
+}
+
+func TestHLSFingerprint_IgnoresQuery(t *testing.T) {
+	a := []hlsSegment{{URI: "https://cdn.example/seg/1.ts?token=a", Seq: 1}}
+	b := []hlsSegment{{URI: "https://cdn.example/seg/1.ts?token=b", Seq: 1}}
+	if hlsFingerprint(a) != hlsFingerprint(b) {
+		t.Error("re-signed segment URLs changed the fingerprint")
+	}
+	b[0].Seq = 2
+	if hlsFingerprint(a) == hlsFingerprint(b) {
+		t.Error("a shifted media sequence kept the fingerprint")
+	}
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultMaxConnectionsPerHost caps sockets to one host across all running
+// downloads. It matches MaxWorkersPerTask so a lone download is unaffected,
+// and follows the per-download ceiling until a host cap is set explicitly
+// (see SetMaxConnectionsPerDownload).
+const DefaultMaxConnectionsPerHost = MaxWorkersPerTask
+
+// hostConnBudget is a per-host counting semaphore shared by every download.
+// Waiters are woken through a broadcast channel that is replaced on each
+// release, which lets the limit change at runtime. A nil budget is unlimited.
+type hostConnBudget struct {
+	mu      sync.Mutex
+	limit   int // 0 = unlimited
+	inUse   map[string]int
+	changed chan struct{}
+}
+
+func newHostConnBudget(limit int) *hostConnBudget {
+	return &hostConnBudget{
+		limit:   limit,
+		inUse:   make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// Acquire blocks until a connection slot for host is free or ctx is done.
+func (b *hostConnBudget) Acquire(ctx context.Context, host string) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		if b.limit <= 0 || b.inUse[host] < b.limit {
+			b.inUse[host]++
+			b.mu.Unlock()
+			return nil
+		}
+		wait := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// Release returns a slot taken by Acquire.
+func (b *hostConnBudget) Release(host string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.inUse[host] > 1 {
+		b.inUse[host]--
+	} else {
+		delete(b.inUse, host)
+	}
+	b.broadcastLocked()
+	b.mu.Unlock()
+}
+
+// SetLimit changes the per-host cap (0 = unlimited). Connections already open
+// above a lowered cap finish normally; new ones wait.
+func (b *hostConnBudget) SetLimit(limit int) {
+	if b == nil {
+		return
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	b.mu.Lock()
+	b.limit = limit
+	b.broadcastLocked()
+	b.mu.Unlock()
+}
+
+// Limit returns the per-host cap (0 = unlimited).
+func (b *hostConnBudget) Limit() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit
+}
+
+// InUse returns the number of open connections counted against host.
+func (b *hostConnBudget) InUse(host string) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inUse[host]
+}
+
+func (b *hostConnBudget) broadcastLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostConnBudget_BlocksAtLimit(t *testing.T) {
+	b := newHostConnBudget(2)
+	ctx := context.Background()
+
+	b.Acquire(ctx, "a.com")
+	b.Acquire(ctx, "a.com")
+	// Other hosts have their own budget
+	if err := b.Acquire(ctx, "b.com"); err != nil {
+		t.Fatalf("b.com should not be limited by a.com: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		b.Acquire(ctx, "a.com")
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("third acquire should block at limit 2")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.Release("a.com")
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("release should unblock a waiter")
+	}
+	if got := b.InUse("a.com"); got != 2 {
+		t.Errorf("InUse = %d, want 2", got)
+	}
+}
+
+func TestHostConnBudget_ContextCancel(t *testing.T) {
+	b := newHostConnBudget(1)
+	b.Acquire(context.Background(), "a.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := b.Acquire(ctx, "a.com"); err == nil {
+		t.Fatal("expected context error while waiting for a slot")
+	}
+	if got := b.InUse("a.com"); got != 1 {
+		t.Errorf("cancelled waiter must not hold a slot, InUse = %d", got)
+	}
+}
+
+func TestHostConnBudget_RaisingLimitWakesWaiters(t *testing.T) {
+	b := newHostConnBudget(1)
+	b.Acquire(context.Background(), "a.com")
+
+	done := make(chan struct{})
+	go func() {
+		b.Acquire(context.Background(), "a.com")
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	b.SetLimit(0)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("removing the limit should wake waiters")
+	}
+}
+
+func TestHostConnBudget_NeverExceedsLimit(t *testing.T) {
+	b := newHostConnBudget(3)
+	var cur, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Acquire(context.Background(), "a.com")
+			n := cur.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			cur.Add(-1)
+			b.Release("a.com")
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak = %d, want <= 3", p)
+	}
+	if got := b.InUse("a.com"); got != 0 {
+		t.Errorf("InUse after all releases = %d, want 0", got)
+	}
+}
+
+func TestHostConnBudget_NilIsUnlimited(t *testing.T) {
+	var b *hostConnBudget
+	if err := b.Acquire(context.Background(), "a.com"); err != nil {
+		t.Fatal(err)
+	}
+	b.Release("a.com")
+	if b.Limit() != 0 {
+		t.Error("nil budget should report no limit")
+	}
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// loadHostProfiles seeds the congestion controller with what earlier
+// sessions learned about each host.
+func (e *TachyonEngine) loadHostProfiles() {
+	profiles, err := e.storage.GetHostProfiles()
+	if err != nil {
+		e.logger.Warn("Failed to load host profiles", "error", err)
+		return
+	}
+	for _, p := range profiles {
+		e.congestion.RestoreHost(p.Host, p.Concurrency, time.Duration(p.RTTMillis)*time.Millisecond, p.ErrorRate)
+	}
+	if len(profiles) > 0 {
+		e.logger.Debug("Restored host profiles", "hosts", len(profiles))
+	}
+}
+
+// saveHostProfile stores what the congestion controller has learned about
+// host, if it has seen the host at all.
+func (e *TachyonEngine) saveHostProfile(host string) {
+	stats := e.congestion.GetHostStats(host)
+	if host == "" || stats == nil {
+		return
+	}
+	profile, _ := e.storage.GetHostProfile(host)
+	profile.Host = host
+	profile.Concurrency = stats.Concurrency
+	profile.RTTMillis = stats.SmoothedRTT.Milliseconds()
+	profile.ErrorRate = stats.ErrorRate
+	profile.Downloads++
+	profile.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := e.storage.SaveHostProfile(profile); err != nil {
+		e.logger.Warn("Failed to save host profile", "host", host, "error", err)
+	}
+}
+
+// GetHostProfile returns what has been learned about downloading from
+// domain: the concurrency it settles on, its typical round trip and how
+// often its requests fail. Live figures from this session take precedence
+// over the saved profile.
+func (e *TachyonEngine) GetHostProfile(domain string) (storage.HostProfile, error) {
+	host := strings.ToLower(strings.TrimSpace(domain))
+	profile, err := e.storage.GetHostProfile(host)
+	stats := e.congestion.GetHostStats(host)
+	if stats == nil {
+		if err != nil {
+			return storage.HostProfile{}, fmt.Errorf("no profile for %s", host)
+		}
+		return profile, nil
+	}
+	profile.Host = host
+	profile.Concurrency = stats.Concurrency
+	profile.RTTMillis = stats.SmoothedRTT.Milliseconds()
+	profile.ErrorRate = stats.ErrorRate
+	return profile, nil
+}
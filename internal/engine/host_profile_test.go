@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestHostProfile_SurvivesRestart(t *testing.T) {
+	store := createTempDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := NewEngine(logger, store)
+
+	// Learn a host the way downloads do: successes grow concurrency
+	const host = "cdn.example.com"
+	for e.congestion.GetIdealConcurrency(host) < 10 {
+		for i := 0; i < 16; i++ {
+			e.congestion.RecordOutcome(host, 40*time.Millisecond, nil)
+		}
+	}
+	learned := e.congestion.GetIdealConcurrency(host)
+	e.saveHostProfile(host)
+	e.Shutdown()
+
+	saved, err := store.GetHostProfile(host)
+	if err != nil {
+		t.Fatalf("profile not saved: %v", err)
+	}
+	if saved.Concurrency != learned || saved.RTTMillis != 40 || saved.Downloads != 1 {
+		t.Errorf("saved profile = %+v, want concurrency %d and 40ms", saved, learned)
+	}
+
+	// A fresh engine on the same database starts the host where it left off
+	e2 := NewEngine(logger, store)
+	defer e2.Shutdown()
+	if got := e2.congestion.GetIdealConcurrency(host); got != learned {
+		t.Errorf("initial concurrency = %d after restart, want %d", got, learned)
+	}
+	if got := e2.congestion.GetIdealConcurrency("unknown.example.com"); got >= learned {
+		t.Errorf("unknown host starts at %d, want the cold minimum", got)
+	}
+	// Retuning rebuilds the controller without forgetting profiles
+	e2.SetDownloadTuning(16, 0)
+	if got := e2.congestion.GetIdealConcurrency(host); got != learned {
+		t.Errorf("initial concurrency = %d after retuning, want %d", got, learned)
+	}
+
+	profile, err := e2.GetHostProfile("CDN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Host != host || profile.Concurrency != learned || profile.RTTMillis != 40 {
+		t.Errorf("GetHostProfile = %+v", profile)
+	}
+	if _, err := e2.GetHostProfile("unknown.example.com"); err == nil {
+		t.Error("expected error for a host never downloaded from")
+	}
+}
+
+func TestHostProfile_SavedAfterDownload(t *testing.T) {
+	e, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+	id, err := e.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if p, err := store.GetHostProfile("127.0.0.1"); err == nil {
+			if p.Concurrency < 1 || p.Downloads != 1 {
+				t.Errorf("profile = %+v", p)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no host profile saved after the download")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -2,16 +2,20 @@ package engine
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"mime"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"project-tachyon/internal/storage"
 )
 
 // Sentinel errors
@@ -20,8 +24,58 @@ var (
 	ErrLinkExpired = errors.New("link expired or access denied (403)")
 	// ErrRangeIgnored indicates the server ignored byte range requests.
 	ErrRangeIgnored = errors.New("server ignored range request")
+	// ErrRangeMismatch indicates a 206 response covered different bytes
+	// than the range that was requested.
+	ErrRangeMismatch = errors.New("server returned a different range")
+	// ErrRemoteFileChanged indicates the file on the server no longer has
+	// the size the download was planned for.
+	ErrRemoteFileChanged = errors.New("remote file changed")
 )
 
+// parseContentRange parses a "bytes start-end/total" Content-Range value.
+// total is -1 when the server sent "*".
+func parseContentRange(v string) (start, end, total int64, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(v), "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total <= end {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+		}
+	}
+	return start, end, total, nil
+}
+
+// parseUnsatisfiedRange parses the "bytes */total" Content-Range sent with
+// 416 Range Not Satisfiable, which gives the current size of the file.
+func parseUnsatisfiedRange(v string) (int64, error) {
+	size, ok := strings.CutPrefix(strings.TrimSpace(v), "bytes */")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || total < 0 {
+		return 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	return total, nil
+}
+
 // ProbeResult contains metadata from a URL probe
 type ProbeResult struct {
 	Size         int64  `json:"size"`
@@ -31,6 +85,12 @@ type ProbeResult struct {
 	ETag         string `json:"etag"`
 	LastModified string `json:"last_modified"`
 	IsHTTP2      bool   `json:"is_http2"`
+	ContentType  string `json:"content_type"`
+
+	// Whole-file checksum announced by the server in a Digest or
+	// Content-MD5 header, as a hex digest; "" if none.
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+	Hash          string `json:"hash,omitempty"`
 }
 
 // newRequest creates an HTTP request with configured headers
@@ -69,7 +129,11 @@ func (e *TachyonEngine) newRequest(method, urlStr string, headersStr string, coo
 		if strings.HasPrefix(strings.TrimSpace(cookiesStr), "[") {
 			var cookies []*http.Cookie
 			if err := json.Unmarshal([]byte(cookiesStr), &cookies); err == nil {
+				now := time.Now()
 				for _, c := range cookies {
+					if c == nil || cookieExpired(c, now) {
+						continue
+					}
 					req.AddCookie(c)
 				}
 			} else {
@@ -85,16 +149,72 @@ func (e *TachyonEngine) newRequest(method, urlStr string, headersStr string, coo
 	return req, nil
 }
 
+// cookieExpired reports whether a stored cookie should no longer be sent:
+// its Expires time has passed or its Max-Age marks it deleted. A positive
+// Max-Age is relative to when the server set the cookie, which is not
+// stored, so it is not checked.
+func cookieExpired(c *http.Cookie, now time.Time) bool {
+	if c.MaxAge < 0 {
+		return true
+	}
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// cookiesExpired reports whether cookiesStr is a JSON cookie list in which
+// every cookie has expired, so a request would go out with no cookies at
+// all. Raw cookie strings carry no expiry and never count as expired.
+func cookiesExpired(cookiesStr string, now time.Time) bool {
+	if !strings.HasPrefix(strings.TrimSpace(cookiesStr), "[") {
+		return false
+	}
+	var cookies []*http.Cookie
+	if err := json.Unmarshal([]byte(cookiesStr), &cookies); err != nil || len(cookies) == 0 {
+		return false
+	}
+	for _, c := range cookies {
+		if c != nil && !cookieExpired(c, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCookies reports an error unless cookiesStr, as stored in a task, is
+// a JSON cookie list or a raw "name=value; ..." Cookie header. The values
+// are credentials, so they are kept out of the error.
+func checkCookies(cookiesStr string) error {
+	trimmed := strings.TrimSpace(cookiesStr)
+	if strings.HasPrefix(trimmed, "[") {
+		var cookies []*http.Cookie
+		// The decoder's error can quote the offending value
+		if err := json.Unmarshal([]byte(trimmed), &cookies); err != nil {
+			return errors.New("invalid cookies JSON")
+		}
+		return nil
+	}
+	if len((&http.Request{Header: http.Header{"Cookie": {trimmed}}}).Cookies()) == 0 {
+		return errors.New("neither a JSON cookie list nor a Cookie header")
+	}
+	return nil
+}
+
 // ProbeURL checks the URL using HEAD first, falling back to GET+Range if needed.
 // Results are cached so the executor can skip re-probing recently probed URLs.
 func (e *TachyonEngine) ProbeURL(urlStr string, headersStr string, cookiesStr string) (*ProbeResult, error) {
+	return e.ProbeURLContext(context.Background(), urlStr, headersStr, cookiesStr)
+}
+
+// ProbeURLContext is ProbeURL bounded by parent: cancelling it (a paused
+// download, a disconnected API client) aborts the probe and returns the
+// context's error instead of trying the remaining fallbacks.
+func (e *TachyonEngine) ProbeURLContext(parent context.Context, urlStr string, headersStr string, cookiesStr string) (*ProbeResult, error) {
 	// Check cache first (frontend modal may have just probed this URL)
 	if cached := e.probes.Get(urlStr); cached != nil {
 		e.logger.Info("Using cached probe result", "url", urlStr)
 		return cached, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
 	defer cancel()
 
 	// 1. Try HEAD first (fast, no body transfer)
@@ -103,6 +223,9 @@ func (e *TachyonEngine) ProbeURL(urlStr string, headersStr string, cookiesStr st
 		e.probes.Put(urlStr, result)
 		return result, nil
 	}
+	if parent.Err() != nil {
+		return nil, parent.Err()
+	}
 
 	// 2. Always fallback to GET+Range -- many servers/CDNs block HEAD at the
 	//    transport layer (connection reset) while serving GET just fine.
@@ -116,6 +239,9 @@ func (e *TachyonEngine) ProbeURL(urlStr string, headersStr string, cookiesStr st
 		e.probes.Put(urlStr, result)
 		return result, nil
 	}
+	if parent.Err() != nil {
+		return nil, parent.Err()
+	}
 
 	// 3. Final fallback: plain GET without Range header -- some servers reject
 	//    the Range header entirely with a 400.
@@ -125,6 +251,9 @@ func (e *TachyonEngine) ProbeURL(urlStr string, headersStr string, cookiesStr st
 		e.logger.Info("GET+Range probe insufficient (size=0), trying plain GET", "url", urlStr)
 	}
 	result, err = e.probePlainGET(ctx, urlStr, headersStr, cookiesStr)
+	if parent.Err() != nil {
+		return nil, parent.Err()
+	}
 	if err == nil && result != nil {
 		result.AcceptRanges = false // Server doesn't support ranges
 	}
@@ -133,12 +262,49 @@ func (e *TachyonEngine) ProbeURL(urlStr string, headersStr string, cookiesStr st
 	if result != nil && result.Size <= 0 {
 		result.Size = extractSizeFromURL(urlStr)
 	}
-	if result != nil {
+	if result != nil && err == nil {
 		e.probes.Put(urlStr, result)
 	}
 	return result, err
 }
 
+// probeWithRetry runs ProbeURL, retrying transient failures with exponential
+// backoff. It gives up early if ctx is cancelled (e.g. the task was paused).
+func (e *TachyonEngine) probeWithRetry(ctx context.Context, task *storage.DownloadTask) (*ProbeResult, error) {
+	retries := e.GetProbeRetries()
+	delay := e.probeRetryDelay
+	for attempt := 0; ; attempt++ {
+		result, err := e.ProbeURLContext(ctx, task.URL, task.Headers, task.Cookies)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil || attempt >= retries || !isTransientProbeFailure(result) {
+			return result, err
+		}
+		e.logger.Warn("Probe failed, retrying", "id", task.ID, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isTransientProbeFailure reports whether a failed probe is worth retrying.
+// Network errors carry no result; HTTP errors are retried only for statuses
+// that signal a temporary server condition.
+func isTransientProbeFailure(result *ProbeResult) bool {
+	if result == nil {
+		return true
+	}
+	switch result.Status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return result.Status >= 500
+}
+
 // probeHEAD performs a lightweight HEAD request to gather file metadata
 func (e *TachyonEngine) probeHEAD(ctx context.Context, urlStr string, headersStr string, cookiesStr string) (*ProbeResult, error) {
 	req, err := e.newRequest("HEAD", urlStr, headersStr, cookiesStr)
@@ -148,6 +314,7 @@ func (e *TachyonEngine) probeHEAD(ctx context.Context, urlStr string, headersStr
 	req = req.WithContext(ctx)
 
 	resp, err := e.httpClient.Do(req)
+	debugLogFrom(ctx).recordExchange("probe HEAD", req, resp, err)
 	if err != nil {
 		e.logger.Error("HEAD probe failed", "url", urlStr, "error", err)
 		return nil, friendlyError(err)
@@ -171,6 +338,7 @@ func (e *TachyonEngine) probeGETRange(ctx context.Context, urlStr string, header
 	req.Header.Set("Range", "bytes=0-0")
 
 	resp, err := e.httpClient.Do(req)
+	debugLogFrom(ctx).recordExchange("probe GET+Range", req, resp, err)
 	if err != nil {
 		e.logger.Error("GET range probe failed", "url", urlStr, "error", err)
 		return nil, friendlyError(err)
@@ -194,6 +362,7 @@ func (e *TachyonEngine) probePlainGET(ctx context.Context, urlStr string, header
 	req = req.WithContext(ctx)
 
 	resp, err := e.httpClient.Do(req)
+	debugLogFrom(ctx).recordExchange("probe GET", req, resp, err)
 	if err != nil {
 		e.logger.Error("Plain GET probe failed", "url", urlStr, "error", err)
 		return nil, friendlyError(err)
@@ -209,13 +378,7 @@ func (e *TachyonEngine) probePlainGET(ctx context.Context, urlStr string, header
 
 // parseProbeResponse extracts metadata from an HTTP response
 func (e *TachyonEngine) parseProbeResponse(resp *http.Response) *ProbeResult {
-	filename := ""
-	cd := resp.Header.Get("Content-Disposition")
-	if cd != "" {
-		if _, params, err := mime.ParseMediaType(cd); err == nil {
-			filename = params["filename"]
-		}
-	}
+	filename := contentDispositionFilename(resp.Header.Get("Content-Disposition"))
 	if filename == "" {
 		filename = filepath.Base(resp.Request.URL.Path)
 		if filename == "." || filename == "/" || filename == "\\" {
@@ -244,7 +407,7 @@ func (e *TachyonEngine) parseProbeResponse(resp *http.Response) *ProbeResult {
 		}
 	}
 
-	return &ProbeResult{
+	result := &ProbeResult{
 		Size:         size,
 		Filename:     filename,
 		Status:       resp.StatusCode,
@@ -252,7 +415,170 @@ func (e *TachyonEngine) parseProbeResponse(resp *http.Response) *ProbeResult {
 		ETag:         resp.Header.Get("ETag"),
 		LastModified: resp.Header.Get("Last-Modified"),
 		IsHTTP2:      resp.ProtoMajor == 2,
+		ContentType:  resp.Header.Get("Content-Type"),
+	}
+	result.HashAlgorithm, result.Hash = serverDigest(resp.Header, resp.StatusCode == http.StatusPartialContent)
+	return result
+}
+
+// contentDispositionFilename returns the file name from a
+// Content-Disposition header, or "" if it has none. An RFC 5987 filename*
+// parameter is preferred over filename, as it is how servers send names
+// that are not plain ASCII. The header is parsed by hand because
+// mime.ParseMediaType only decodes filename* in UTF-8 and drops every
+// parameter when any one of them is malformed.
+func contentDispositionFilename(cd string) string {
+	if cd == "" {
+		return ""
+	}
+	var plain string
+	for _, param := range splitHeaderParams(cd) {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "filename*":
+			if name := decodeExtValue(strings.TrimSpace(value)); name != "" {
+				return name
+			}
+		case "filename":
+			plain = unquoteHeaderValue(strings.TrimSpace(value))
+		}
+	}
+	return plain
+}
+
+// splitHeaderParams splits a header value on semicolons outside quoted
+// strings.
+func splitHeaderParams(v string) []string {
+	var params []string
+	inQuotes, escaped := false, false
+	start := 0
+	for i := 0; i < len(v); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case v[i] == '\\' && inQuotes:
+			escaped = true
+		case v[i] == '"':
+			inQuotes = !inQuotes
+		case v[i] == ';' && !inQuotes:
+			params = append(params, v[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, v[start:])
+}
+
+// unquoteHeaderValue removes the quotes and backslash escapes of an HTTP
+// quoted-string; other values are returned as they are.
+func unquoteHeaderValue(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range v[1 : len(v)-1] {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// decodeExtValue decodes an RFC 5987 ext-value (charset'language'pct-encoded).
+// UTF-8, ISO-8859-1 and US-ASCII are supported; anything else, or a value
+// that is not valid in its charset, gives "".
+func decodeExtValue(v string) string {
+	charset, rest, ok := strings.Cut(unquoteHeaderValue(v), "'")
+	if !ok {
+		return ""
+	}
+	_, encoded, ok := strings.Cut(rest, "'")
+	if !ok {
+		return ""
+	}
+	raw, err := url.PathUnescape(encoded)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(charset) {
+	case "utf-8":
+		if !utf8.ValidString(raw) {
+			return ""
+		}
+		return raw
+	case "iso-8859-1", "latin1":
+		runes := make([]rune, len(raw))
+		for i := 0; i < len(raw); i++ {
+			runes[i] = rune(raw[i]) // Latin-1 bytes are the first 256 code points
+		}
+		return string(runes)
+	case "us-ascii":
+		for i := 0; i < len(raw); i++ {
+			if raw[i] >= utf8.RuneSelf {
+				return ""
+			}
+		}
+		return raw
+	}
+	return ""
+}
+
+// digestAlgorithms maps RFC 3230 Digest algorithm names to verifier
+// algorithms and digest sizes, strongest first.
+var digestAlgorithms = []struct {
+	name, algo string
+	size       int
+}{
+	{"sha-512", "sha512", 64},
+	{"sha-256", "sha256", 32},
+	{"sha", "sha1", 20},
+	{"md5", "md5", 16},
+}
+
+// serverDigest returns the strongest whole-file checksum in a response's
+// Digest header, falling back to Content-MD5. Content-MD5 covers only the
+// body actually sent, so it is ignored on partial responses.
+func serverDigest(h http.Header, partial bool) (algo, digest string) {
+	values := map[string]string{}
+	for _, header := range h.Values("Digest") {
+		for _, item := range strings.Split(header, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if ok {
+				values[strings.ToLower(name)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if contentMD5 := h.Get("Content-MD5"); contentMD5 != "" && !partial {
+		if _, ok := values["md5"]; !ok {
+			values["md5"] = strings.TrimSpace(contentMD5)
+		}
+	}
+	for _, a := range digestAlgorithms {
+		if v, ok := values[a.name]; ok {
+			if digest := decodeDigest(v, a.size); digest != "" {
+				return a.algo, digest
+			}
+		}
+	}
+	return "", ""
+}
+
+// decodeDigest turns a base64 digest of size bytes into hex. Hex values of
+// the right length are accepted too, as some servers send those.
+func decodeDigest(v string, size int) string {
+	if raw, err := base64.StdEncoding.DecodeString(v); err == nil && len(raw) == size {
+		return hex.EncodeToString(raw)
+	}
+	if raw, err := hex.DecodeString(v); err == nil && len(raw) == size {
+		return strings.ToLower(v)
 	}
+	return ""
 }
 
 // friendlyError converts technical errors to user-friendly messages
@@ -1,15 +1,24 @@
 package engine
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"project-tachyon/internal/network"
+	"project-tachyon/internal/storage"
 )
 
 // newHTTPEngine creates a minimal TachyonEngine for HTTP-related tests.
@@ -160,6 +169,52 @@ func TestNewRequest_CookiesJSON(t *testing.T) {
 	}
 }
 
+func TestNewRequest_SkipsExpiredCookies(t *testing.T) {
+	e := newHTTPEngine()
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	cookies := fmt.Sprintf(`[
+		{"Name":"old","Value":"1","Expires":%q},
+		{"Name":"deleted","Value":"2","MaxAge":-1},
+		{"Name":"valid","Value":"3","Expires":%q},
+		{"Name":"session","Value":"4"}
+	]`, past, future)
+	req, err := e.newRequest("GET", "https://example.com/file.bin", "", cookies)
+	if err != nil {
+		t.Fatalf("newRequest failed: %v", err)
+	}
+	var names []string
+	for _, c := range req.Cookies() {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "valid,session" {
+		t.Errorf("sent cookies %v, want valid and session", names)
+	}
+}
+
+func TestCookiesExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute).UTC().Format(time.RFC3339)
+	future := now.Add(time.Minute).UTC().Format(time.RFC3339)
+	cases := []struct {
+		cookies string
+		want    bool
+	}{
+		{"", false},
+		{"session=abc", false},
+		{"[]", false},
+		{"[bad json", false},
+		{fmt.Sprintf(`[{"Name":"a","Expires":%q},{"Name":"b","MaxAge":-1}]`, past), true},
+		{fmt.Sprintf(`[{"Name":"a","Expires":%q},{"Name":"b","Expires":%q}]`, past, future), false},
+		{fmt.Sprintf(`[{"Name":"a","Expires":%q},{"Name":"b"}]`, past), false}, // session cookie
+	}
+	for _, tc := range cases {
+		if got := cookiesExpired(tc.cookies, now); got != tc.want {
+			t.Errorf("cookiesExpired(%s) = %v, want %v", tc.cookies, got, tc.want)
+		}
+	}
+}
+
 func TestNewRequest_CookiesRawString(t *testing.T) {
 	e := newHTTPEngine()
 	req, err := e.newRequest("GET", "https://example.com/file.bin", "", "session=abc; lang=en")
@@ -218,6 +273,47 @@ func TestSentinelErrors(t *testing.T) {
 	}
 }
 
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		in                string
+		start, end, total int64
+		ok                bool
+	}{
+		{"bytes 0-499/1000", 0, 499, 1000, true},
+		{"bytes 500-999/*", 500, 999, -1, true},
+		{" bytes 7-7/8", 7, 7, 8, true},
+		{"", 0, 0, 0, false},
+		{"bytes */1000", 0, 0, 0, false},
+		{"items 0-1/2", 0, 0, 0, false},
+		{"bytes 10-5/100", 0, 0, 0, false},
+		{"bytes 0-99/50", 0, 0, 0, false},
+		{"bytes 0-99", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, total, err := parseContentRange(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseContentRange(%q) err = %v, want ok=%v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && (start != tt.start || end != tt.end || total != tt.total) {
+			t.Errorf("parseContentRange(%q) = %d-%d/%d, want %d-%d/%d", tt.in, start, end, total, tt.start, tt.end, tt.total)
+		}
+	}
+}
+
+func TestParseUnsatisfiedRange(t *testing.T) {
+	for in, want := range map[string]int64{"bytes */1000": 1000, " bytes */0": 0} {
+		if got, err := parseUnsatisfiedRange(in); err != nil || got != want {
+			t.Errorf("parseUnsatisfiedRange(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "bytes */*", "bytes 0-9/10", "bytes */-1", "items */10"} {
+		if _, err := parseUnsatisfiedRange(in); err == nil {
+			t.Errorf("parseUnsatisfiedRange(%q) succeeded", in)
+		}
+	}
+}
+
 // --- ProbeURL with mock server ---
 
 func TestProbeURL_HEAD(t *testing.T) {
@@ -278,6 +374,50 @@ func TestProbeURL_FallbackToGETRange(t *testing.T) {
 	}
 }
 
+func TestProbeURL_ExtendedFilename(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A%20%E2%82%AC.pdf`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	e := newHTTPEngine()
+	result, err := e.ProbeURL(server.URL+"/download?id=1", "", "")
+	if err != nil {
+		t.Fatalf("ProbeURL failed: %v", err)
+	}
+	if result.Filename != "报告 €.pdf" {
+		t.Errorf("Filename = %q, want the decoded filename*", result.Filename)
+	}
+}
+
+func TestContentDispositionFilename(t *testing.T) {
+	tests := []struct {
+		name, header, want string
+	}{
+		{"plain", `attachment; filename="test.zip"`, "test.zip"},
+		{"unquoted", `attachment; filename=test.zip`, "test.zip"},
+		{"utf-8 extended", `attachment; filename*=UTF-8''na%C3%AFve%20caf%C3%A9.txt`, "naïve café.txt"},
+		{"latin-1 extended", `attachment; filename*=ISO-8859-1'de'M%FCller%20%C4rger.pdf`, "Müller Ärger.pdf"},
+		{"extended wins over plain", `attachment; filename*=utf-8'en'%E2%82%AC%20rates.csv; filename="EUR rates.csv"`, "€ rates.csv"},
+		{"unknown charset falls back", `attachment; filename="fallback.bin"; filename*=KOI8-R''%F0%D2%C9%D7%C5%D4.bin`, "fallback.bin"},
+		{"invalid utf-8 falls back", `attachment; filename="fallback.bin"; filename*=UTF-8''%FF%FE.bin`, "fallback.bin"},
+		{"bad escape falls back", `attachment; filename="fallback.bin"; filename*=UTF-8''%ZZ.bin`, "fallback.bin"},
+		{"semicolon in quotes", `attachment; filename="a;b.zip"`, "a;b.zip"},
+		{"escaped quote", `attachment; filename="say \"hi\".txt"`, `say "hi".txt`},
+		{"no filename", `inline`, ""},
+		{"empty", ``, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentDispositionFilename(tt.header); got != tt.want {
+				t.Errorf("contentDispositionFilename(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestProbeURL_404(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -294,6 +434,135 @@ func TestProbeURL_404(t *testing.T) {
 	}
 }
 
+// dropFirst returns a handler that closes the connection without a response
+// for the first n requests, then serves a 1000-byte ranged file.
+func dropFirst(n int32, hits *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= n {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Length", "1000")
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestProbeURLContext_CancelReturnsPromptly(t *testing.T) {
+	release := make(chan struct{})
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select { // hang until the test ends
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	e := newHTTPEngine()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	result, err := e.ProbeURLContext(ctx, server.URL+"/slow.bin", "", "")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("probe took %v after cancellation", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) || result != nil {
+		t.Fatalf("expected context.Canceled, got result=%v err=%v", result, err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected the fallbacks to be skipped after cancel, server saw %d requests", n)
+	}
+	if e.probes.Get(server.URL+"/slow.bin") != nil {
+		t.Error("cancelled probe must not be cached")
+	}
+}
+
+func TestProbeWithRetry_RecoversFromTransientFailure(t *testing.T) {
+	var hits atomic.Int32
+	// One full probe round (HEAD, GET+Range, plain GET) fails
+	server := httptest.NewServer(dropFirst(3, &hits))
+	defer server.Close()
+
+	e := newHTTPEngine()
+	e.probeRetryDelay = 10 * time.Millisecond
+	e.SetProbeRetries(2)
+
+	result, err := e.probeWithRetry(context.Background(), &storage.DownloadTask{ID: "t", URL: server.URL + "/file.bin"})
+	if err != nil {
+		t.Fatalf("expected probe to succeed on retry, got %v", err)
+	}
+	if result.Size != 1000 {
+		t.Errorf("expected size 1000, got %d", result.Size)
+	}
+}
+
+func TestProbeWithRetry_ZeroRetriesFailsImmediately(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(dropFirst(3, &hits))
+	defer server.Close()
+
+	e := newHTTPEngine()
+	e.probeRetryDelay = 10 * time.Millisecond
+	e.SetProbeRetries(0)
+
+	if _, err := e.probeWithRetry(context.Background(), &storage.DownloadTask{ID: "t", URL: server.URL}); err == nil {
+		t.Fatal("expected failure with retries disabled")
+	}
+}
+
+func TestProbeWithRetry_PermanentErrorsNotRetried(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden} {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.WriteHeader(status)
+		}))
+
+		e := newHTTPEngine()
+		e.probeRetryDelay = 10 * time.Millisecond
+		e.SetProbeRetries(3)
+
+		if _, err := e.probeWithRetry(context.Background(), &storage.DownloadTask{ID: "t", URL: server.URL}); err == nil {
+			t.Errorf("%d: expected error", status)
+		}
+		// A single probe round is HEAD + GET+Range + plain GET
+		if got := hits.Load(); got != 3 {
+			t.Errorf("%d: expected one probe round (3 requests), got %d", status, got)
+		}
+		server.Close()
+	}
+}
+
+func TestProbeWithRetry_ServerErrorRetried(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Length", "500")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	e := newHTTPEngine()
+	e.probeRetryDelay = 10 * time.Millisecond
+
+	e.SetProbeRetries(1)
+	result, err := e.probeWithRetry(context.Background(), &storage.DownloadTask{ID: "t", URL: server.URL})
+	if err != nil {
+		t.Fatalf("expected 503 to be retried, got %v", err)
+	}
+	if result.Size != 500 {
+		t.Errorf("expected size 500, got %d", result.Size)
+	}
+}
+
 func TestProbeURL_FilenameFromURL(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Length", "100")
@@ -363,3 +632,140 @@ func TestProbeURL_HEADRefusedGETFallback(t *testing.T) {
 		t.Error("expected AcceptRanges true from 206 response")
 	}
 }
+
+// --- server-provided checksums ---
+
+func TestServerDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	sha256B64 := base64.StdEncoding.EncodeToString(sum[:])
+	sha256Hex := hex.EncodeToString(sum[:])
+	md5Sum := md5.Sum([]byte("hello"))
+	md5B64 := base64.StdEncoding.EncodeToString(md5Sum[:])
+	md5Hex := hex.EncodeToString(md5Sum[:])
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		partial   bool
+		wantAlgo  string
+		wantValue string
+	}{
+		{"content-md5", map[string]string{"Content-MD5": md5B64}, false, "md5", md5Hex},
+		{"content-md5 on a range response", map[string]string{"Content-MD5": md5B64}, true, "", ""},
+		{"digest sha-256", map[string]string{"Digest": "sha-256=" + sha256B64}, true, "sha256", sha256Hex},
+		{"digest upper-case name", map[string]string{"Digest": "SHA-256=" + sha256B64}, false, "sha256", sha256Hex},
+		{"strongest of several", map[string]string{"Digest": "md5=" + md5B64 + ", sha-256=" + sha256B64}, false, "sha256", sha256Hex},
+		{"digest beats content-md5", map[string]string{"Digest": "sha-256=" + sha256B64, "Content-MD5": md5B64}, false, "sha256", sha256Hex},
+		{"hex instead of base64", map[string]string{"Digest": "sha-256=" + sha256Hex}, false, "sha256", sha256Hex},
+		{"unsupported algorithm", map[string]string{"Digest": "crc32c=AAAAAA=="}, false, "", ""},
+		{"wrong length", map[string]string{"Digest": "sha-256=" + md5B64}, false, "", ""},
+		{"none", nil, false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			algo, digest := serverDigest(h, tt.partial)
+			if algo != tt.wantAlgo || digest != tt.wantValue {
+				t.Errorf("serverDigest = %q/%q, want %q/%q", algo, digest, tt.wantAlgo, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestProbeURL_ReportsServerDigest(t *testing.T) {
+	md5Sum := md5.Sum([]byte("payload"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "7")
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result, err := newHTTPEngine().ProbeURL(server.URL+"/payload.bin", "", "")
+	if err != nil {
+		t.Fatalf("ProbeURL failed: %v", err)
+	}
+	if result.HashAlgorithm != "md5" || result.Hash != hex.EncodeToString(md5Sum[:]) {
+		t.Errorf("probe hash = %s/%s", result.HashAlgorithm, result.Hash)
+	}
+}
+
+// spawnDigestServer serves content with ranges, adding header to every response.
+func spawnDigestServer(t *testing.T, content []byte, header, value string) *httptest.Server {
+	inner := spawnRangeServer(t, content, 0)
+	t.Cleanup(inner.Close)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(header, value)
+		inner.Config.Handler.ServeHTTP(w, r)
+	}))
+}
+
+func TestDownload_VerifiesAgainstServerDigest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(256 * 1024)
+	sha := sha256.Sum256(content)
+	md5Sum := md5.Sum(content)
+	wrong := sha256.Sum256([]byte("something else"))
+
+	tests := []struct {
+		name, header, value string
+		wantAlgo, wantHash  string
+		wantStatus          storage.Status
+	}{
+		{"content-md5", "Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]), "md5", hex.EncodeToString(md5Sum[:]), storage.StatusCompleted},
+		{"digest sha-256", "Digest", "sha-256=" + base64.StdEncoding.EncodeToString(sha[:]), "sha256", hex.EncodeToString(sha[:]), storage.StatusCompleted},
+		{"mismatch", "Digest", "sha-256=" + base64.StdEncoding.EncodeToString(wrong[:]), "sha256", hex.EncodeToString(wrong[:]), storage.StatusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := spawnDigestServer(t, content, tt.header, tt.value)
+			defer server.Close()
+
+			e, store := newLoopbackEngine(t)
+
+			id, err := e.StartDownload(server.URL+"/digest.bin", t.TempDir(), "digest.bin", nil)
+			if err != nil {
+				t.Fatalf("StartDownload: %v", err)
+			}
+			task := waitForFinalStatus(t, store, id)
+			if task.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", task.Status, tt.wantStatus)
+			}
+			if task.HashAlgorithm != tt.wantAlgo || task.ExpectedHash != tt.wantHash {
+				t.Errorf("expected hash = %s/%s, want %s/%s", task.HashAlgorithm, task.ExpectedHash, tt.wantAlgo, tt.wantHash)
+			}
+		})
+	}
+}
+
+func TestDownload_ExplicitHashWinsOverServerDigest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(128 * 1024)
+	sha := sha256.Sum256(content)
+	wrong := sha256.Sum256([]byte("something else"))
+	server := spawnDigestServer(t, content, "Digest", "sha-256="+base64.StdEncoding.EncodeToString(wrong[:]))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+
+	id, err := e.StartDownload(server.URL+"/explicit.bin", t.TempDir(), "explicit.bin", map[string]string{"expected_hash": hex.EncodeToString(sha[:])})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if task.ExpectedHash != hex.EncodeToString(sha[:]) {
+		t.Errorf("server digest replaced the explicit hash: %s", task.ExpectedHash)
+	}
+}
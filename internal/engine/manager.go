@@ -6,15 +6,21 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"project-tachyon/internal/analytics"
+	"project-tachyon/internal/extractor"
 	"project-tachyon/internal/filesystem"
 	"project-tachyon/internal/integrity"
 	"project-tachyon/internal/network"
+	"project-tachyon/internal/notify"
+	"project-tachyon/internal/platform"
 	"project-tachyon/internal/queue"
 	"project-tachyon/internal/security"
 	"project-tachyon/internal/storage"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // Configurable constants
@@ -22,10 +28,22 @@ const (
 	DownloadChunkSize = 4 * 1024 * 1024 // 4MB Part Size — fewer HTTP requests, better TCP ramp-up
 	BufferSize        = 1 * 1024 * 1024 // 1MB Buffer — fewer read loop iterations on fast links
 	MaxWorkersPerTask = 24              // Aggressive upper bound; dynamic tuning chooses active count
+	MaxConnectionsCap = 64              // Highest per-download connection ceiling a user may set
+	MaxConcurrentCap  = 10              // Most downloads that may run at once
 	GenericUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/132.0.0.0 Safari/537.36"
 
+	// Whole-download stall detection defaults
+	DefaultStallWarnAfter  = 45 * time.Second
+	DefaultStallPauseAfter = 5 * time.Minute
+
+	// Extra probe attempts after a transient failure (timeout, reset, 5xx)
+	DefaultProbeRetries = 2
+
+	// How long Shutdown waits for the queue worker to exit
+	queueWorkerStopTimeout = 2 * time.Second
+
 	// Status for tasks needing URL refresh (403 received)
-	StatusNeedsAuth = "needs_auth"
+	StatusNeedsAuth = storage.StatusNeedsAuth
 )
 
 // TachyonEngine is the core download orchestrator
@@ -36,33 +54,88 @@ type TachyonEngine struct {
 	queue           *queue.DownloadQueue
 	scheduler       *queue.SmartScheduler
 	activeDownloads sync.Map // map[string]*activeDownloadInfo
+	emergencyPaused sync.Map // IDs EmergencyStop paused, until resumed or paused by hand
 	allowLoopback   bool     // allow 127.0.0.1 downloads (testing only)
 	bufferPool      *sync.Pool
 	httpClient      *http.Client
+	transport       *swappableTransport // httpClient's transport, rebuilt per network profile
+	dnsCache        *network.DNSCache
+	netProfile      string
+	dohURL          string        // DNS-over-HTTPS provider; "" = system DNS
+	workerGrowth    int           // custom workers added per scale tick; 0 = profile's
+	scaleInterval   time.Duration // custom scale tick; 0 = profile's
+	netProfileMu    sync.Mutex
 	stats           *analytics.StatsManager
 
 	// Concurrency Control
 	maxConcurrent    int
+	manualConcurrent int // user's limit, restored when autoscaling stops
 	runningDownloads int
 	workerCond       *sync.Cond
 	workerMutex      sync.Mutex
+	workerDone       chan struct{} // closed when queueWorker exits
+	shuttingDown     atomic.Bool   // set by Shutdown; no new downloads start
 
 	// Bandwidth & Traffic
 	bandwidthManager *network.BandwidthManager
 	congestion       *network.CongestionController
 	breaker          *network.CircuitBreaker
 	hostSingleStream sync.Map // map[string]bool
+	hostBudget       *hostConnBudget
+	hostLimitSet     atomic.Bool // SetMaxConnectionsPerHost was called; until then the cap follows the per-download ceiling
+	diskThrottle     *diskThrottle
+
+	// Worker start-up pacing (see SetSpawnPacing)
+	spawnJitter atomic.Int64
+	spawnRamp   atomic.Int64
+	hostPacing  sync.Map // map[string]bool
 
 	// Download tuning knobs
 	maxWorkersPerTask int
 	baseChunkSize     int64
+	sizeCurveMu       sync.RWMutex
+	sizeCurve         []ConcurrencyStep // initial worker cap by file size
+	partIdleTimeout   atomic.Int64      // fixed per-part idle timeout in ns; 0 = adaptive
+	minPartSize       atomic.Int64      // smallest final part in bytes; see SetMinPartSize
+
+	// app:progress interval in ns (0 = off); see SetAppProgressInterval
+	appProgressInterval atomic.Int64
+
+	// Whole-download stall thresholds in ns (0 disables the stage)
+	stallWarnAfter  atomic.Int64
+	stallPauseAfter atomic.Int64
+
+	// Probe retries on transient failures; the delay doubles per attempt
+	probeRetries    atomic.Int32
+	probeRetryDelay time.Duration
+
+	// Queue admission: 0 = unlimited. admitMu serialises the count-then-insert
+	// in StartDownload so concurrent callers can't overshoot the limit.
+	maxQueueSize atomic.Int32
+	admitMu      sync.Mutex
+
+	// History retention in days (0 = keep forever); see PruneHistory
+	retentionDays        atomic.Int32
+	retentionDeleteFiles atomic.Bool
+
+	autoPausedMu sync.Mutex // guards the persisted pause-all ID set
+
+	// Concurrency autoscaling (see SetConcurrencyAutoscale)
+	autoscale    atomic.Bool
+	lineCapacity atomic.Int64 // bytes/sec, 0 = unknown
+	diskBusy     busyMeter    // time part files were being written
 
 	// integrity
-	allocator *filesystem.Allocator
-	verifier  *integrity.FileVerifier
+	allocator      *filesystem.Allocator
+	verifier       *integrity.FileVerifier
+	verifyFile     func(path, algo, expected string) error // verifier.Verify; replaced in tests
+	diskSpaceCheck func(path string, required int64) error // defaults to allocator.CheckDiskSpace
 
 	// utilities
 	organizer *filesystem.SmartOrganizer
+	files     *fileIndex // cached FileExists state, refreshed by ReconcileFiles
+	watcher   *downloadWatcher
+	watcherMu sync.Mutex
 
 	// Phase 7 Components
 	stateManager *StateManager
@@ -79,6 +152,92 @@ type TachyonEngine struct {
 	// Custom User-Agent (thread-safe)
 	userAgentMu sync.RWMutex
 	userAgent   string
+
+	// Append an extension from Content-Type to extensionless filenames
+	fixExtensions atomic.Bool
+
+	// Link extractors run when a download URL serves an HTML page
+	extractorsMu sync.RWMutex
+	extractors   *extractor.Registry
+
+	// Stamp completed files with the server's Last-Modified time
+	preserveModTime atomic.Bool
+
+	// Count a part as done when the server says the file ends where it starts
+	treat416AsComplete atomic.Bool
+
+	// Write <file>.tachyon.json provenance records on completion
+	writeSidecar atomic.Bool
+
+	// Optional root for in-progress part files (see SetTempDownloadDir)
+	tempDirMu       sync.RWMutex
+	tempDownloadDir string
+
+	// What StartDownload does when the file name is taken (see SetCollisionStrategy)
+	collisionMu       sync.RWMutex
+	collisionStrategy string
+
+	// Preemption: victim task ID -> ID of the promoted task that displaced it
+	preempted sync.Map
+	preemptMu sync.Mutex
+
+	// Session counters reported by Status
+	startedAt    time.Time
+	sessionBytes atomic.Int64 // body bytes received since startup
+
+	// Completion/error sounds (see SetSoundPreferences)
+	soundMu    sync.Mutex
+	soundPrefs SoundPreferences
+	playSound  func(platform.Sound) error
+
+	// Completion webhook (see SetCompletionWebhook)
+	webhookMu         sync.RWMutex
+	webhook           *webhookNotifier // nil = off
+	webhookClient     *http.Client
+	webhookRetryDelay time.Duration
+
+	// Email/chat notifications (see SetNotificationSettings)
+	notifyMu       sync.RWMutex
+	notifySettings notify.Settings
+	notifiers      []notify.Notifier
+
+	// Automatic refresh of expired links (see SetAuthRefresher)
+	authRefreshMu sync.RWMutex
+	authRefresher AuthRefresher
+	authRefreshes sync.Map // task ID -> time.Time of the last refresh
+
+	// Header logs of downloads with debugging on: task ID -> *debugLog
+	debugLogs sync.Map
+
+	// Recent engine activity (see GetEventLog)
+	events *eventLog
+
+	// Verification pool for finished downloads (see SetMaxVerifications)
+	verifyMu         sync.Mutex
+	verifyCond       *sync.Cond
+	verifyRunning    int // verifications hashing or scanning now
+	verifyPending    int // handed to the pool and not yet finished
+	maxVerifications int
+	verifyRetried    map[string]int // re-downloads so far after a failed check, by task
+
+	// Re-downloads after a failed integrity check (see SetVerifyRetries)
+	verifyRetries atomic.Int32
+
+	// Dispatch hold during post-processing (see SetHoldQueueWhileVerifying)
+	holdWhileVerifying atomic.Bool
+	postProcessing     atomic.Int32 // downloads verifying or scanning now
+	dispatchHold       atomic.Bool  // no new downloads start (see HoldDispatch)
+
+	// Priority given to new downloads (see SetDefaultPriority)
+	defaultPriority atomic.Int32
+
+	// Re-verification of completed files (see RecheckIntegrity)
+	verifyOnOpen atomic.Bool
+	recheckMu    sync.Mutex
+	rechecked    map[string]recheckStamp // task ID -> last passed recheck
+
+	// Optional observer invoked for every event emitted via emit()
+	eventHook func(name string, data interface{})
 }
 
 // NewEngine creates a new TachyonEngine instance
@@ -86,22 +245,8 @@ func NewEngine(logger *slog.Logger, storage *storage.Storage) *TachyonEngine {
 	// DNS cache reduces lookup latency on multi-part downloads to the same host
 	dnsCache := network.NewDNSCache(5 * time.Minute)
 
-	// Custom Transport for Connection Reuse + HTTP/2 multiplexing
-	transport := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dnsCache.DialContext(30*time.Second, 30*time.Second),
-		MaxIdleConns:          100, // Global pool size
-		MaxIdleConnsPerHost:   32,  // Allow high concurrency per host
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		ResponseHeaderTimeout: 30 * time.Second, // Bound header wait to detect dead connections
-		DisableCompression:    true,             // We want raw bytes
-		ForceAttemptHTTP2:     true,             // Enable HTTP/2 multiplexing
-		ReadBufferSize:        128 * 1024,       // 128KB — reduces syscalls on fast links
-		WriteBufferSize:       32 * 1024,        // 32KB — sufficient for request headers
-	}
-
+	// Swappable transport so the network profile can be changed at runtime
+	transport := &swappableTransport{}
 	client := &http.Client{
 		Transport: transport,
 		Timeout:   0, // No timeout for the client itself, request contexts handles it
@@ -123,14 +268,22 @@ func NewEngine(logger *slog.Logger, storage *storage.Storage) *TachyonEngine {
 			},
 		},
 		httpClient:        client,
+		transport:         transport,
+		dnsCache:          dnsCache,
+		netProfile:        DefaultNetworkProfile,
+		collisionStrategy: DefaultCollisionStrategy,
 		stats:             analytics.NewStatsManager(storage, filesystem.GetDefaultDownloadPath),
 		maxConcurrent:     5, // System wide limit of downloads
+		manualConcurrent:  5,
 		runningDownloads:  0,
 		bandwidthManager:  network.NewBandwidthManager(),
 		congestion:        network.NewCongestionController(4, MaxWorkersPerTask),
 		breaker:           network.NewCircuitBreaker(5, 30*time.Second),
+		hostBudget:        newHostConnBudget(DefaultMaxConnectionsPerHost),
+		diskThrottle:      newDiskThrottle(),
 		maxWorkersPerTask: MaxWorkersPerTask,
 		baseChunkSize:     0,
+		sizeCurve:         DefaultConcurrencyCurve(),
 		allocator:         filesystem.NewAllocator(),
 		verifier:          integrity.NewFileVerifier(),
 		organizer:         filesystem.NewSmartOrganizer(),
@@ -138,8 +291,30 @@ func NewEngine(logger *slog.Logger, storage *storage.Storage) *TachyonEngine {
 		scanner:           security.NewScanner(logger),
 		workerPool:        NewWorkerPool(64), // Global pool — covers all concurrent download workers
 		probes:            newProbeCache(),
+		files:             newFileIndex(),
+		probeRetryDelay:   time.Second,
+		extractors:        extractor.NewDefaultRegistry(),
+		startedAt:         time.Now(),
+		playSound:         platform.PlaySound,
+		webhookClient:     &http.Client{},
+		webhookRetryDelay: webhookRetryDelay,
+		events:            newEventLog(DefaultEventLogSize),
+		maxVerifications:  DefaultMaxVerifications,
+		verifyRetried:     make(map[string]int),
+		rechecked:         make(map[string]recheckStamp),
 	}
 	e.workerCond = sync.NewCond(&e.workerMutex)
+	e.verifyCond = sync.NewCond(&e.verifyMu)
+	e.verifyFile = e.verifier.Verify
+	e.workerDone = make(chan struct{})
+	transport.current.Store(e.newTransport(networkProfiles[DefaultNetworkProfile]))
+	e.diskSpaceCheck = e.allocator.CheckDiskSpace
+	e.SetStallThresholds(DefaultStallWarnAfter, DefaultStallPauseAfter)
+	e.probeRetries.Store(DefaultProbeRetries)
+	e.minPartSize.Store(DefaultMinPartSize)
+	e.appProgressInterval.Store(int64(DefaultAppProgressInterval))
+	e.defaultPriority.Store(DefaultPriority)
+	e.loadHostProfiles()
 
 	go e.queueWorker()
 	return e
@@ -151,8 +326,8 @@ func (e *TachyonEngine) SetDownloadTuning(maxWorkers int, baseChunkBytes int64)
 	if maxWorkers < 1 {
 		maxWorkers = 1
 	}
-	if maxWorkers > 64 {
-		maxWorkers = 64
+	if maxWorkers > MaxConnectionsCap {
+		maxWorkers = MaxConnectionsCap
 	}
 	e.maxWorkersPerTask = maxWorkers
 	e.workerMutex.Unlock()
@@ -164,29 +339,159 @@ func (e *TachyonEngine) SetDownloadTuning(maxWorkers int, baseChunkBytes int64)
 
 	// Keep congestion controller bounds aligned with worker caps.
 	e.congestion = network.NewCongestionController(4, maxWorkers)
+	e.loadHostProfiles()
+}
+
+// SetPartIdleTimeout sets how long a part request may go without receiving
+// any bytes before it is abandoned and retried on a fresh connection.
+// A value <= 0 restores the adaptive default (5-30s based on recent speed).
+func (e *TachyonEngine) SetPartIdleTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	e.partIdleTimeout.Store(int64(d))
+}
+
+// GetPartIdleTimeout returns the fixed per-part idle timeout (0 = adaptive).
+func (e *TachyonEngine) GetPartIdleTimeout() time.Duration {
+	return time.Duration(e.partIdleTimeout.Load())
+}
+
+// SetTreat416AsComplete sets whether a 416 Range Not Satisfiable that puts
+// the end of the file exactly where a part starts completes the part, and
+// shortens the download to that size, instead of failing it as a remote
+// change. Off by default.
+func (e *TachyonEngine) SetTreat416AsComplete(enabled bool) {
+	e.treat416AsComplete.Store(enabled)
+}
+
+// GetTreat416AsComplete reports whether such a 416 completes the part.
+func (e *TachyonEngine) GetTreat416AsComplete() bool {
+	return e.treat416AsComplete.Load()
+}
+
+// SetStallThresholds configures whole-download stall handling. After warnAfter
+// without any progress a download:stalled event is emitted and the host's
+// concurrency is cut back; after pauseAfter the download is paused. A value
+// <= 0 disables that stage.
+func (e *TachyonEngine) SetStallThresholds(warnAfter, pauseAfter time.Duration) {
+	if warnAfter < 0 {
+		warnAfter = 0
+	}
+	if pauseAfter < 0 {
+		pauseAfter = 0
+	}
+	e.stallWarnAfter.Store(int64(warnAfter))
+	e.stallPauseAfter.Store(int64(pauseAfter))
+}
+
+// GetStallThresholds returns the warn and pause stall thresholds.
+func (e *TachyonEngine) GetStallThresholds() (warnAfter, pauseAfter time.Duration) {
+	return time.Duration(e.stallWarnAfter.Load()), time.Duration(e.stallPauseAfter.Load())
+}
+
+// SetMaxQueueSize caps how many downloads may be pending, scheduled or
+// running at once. StartDownload returns ErrQueueFull beyond the cap.
+// A value <= 0 removes the limit.
+func (e *TachyonEngine) SetMaxQueueSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	e.maxQueueSize.Store(int32(n))
+}
+
+// GetMaxQueueSize returns the queue cap (0 = unlimited).
+func (e *TachyonEngine) GetMaxQueueSize() int {
+	return int(e.maxQueueSize.Load())
+}
+
+// SetProbeRetries sets how many more times a download's initial probe is
+// tried after a transient failure (timeout, connection reset, 5xx) before the
+// download fails. Permanent failures such as 404 or 403 are never retried.
+func (e *TachyonEngine) SetProbeRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	e.probeRetries.Store(int32(n))
+}
+
+// GetProbeRetries returns the number of probe retries.
+func (e *TachyonEngine) GetProbeRetries() int {
+	return int(e.probeRetries.Load())
+}
+
+// QueueSize returns the number of downloads counted against the queue cap.
+func (e *TachyonEngine) QueueSize() int {
+	n, err := e.storage.CountTasksByStatus(queuedStatuses)
+	if err != nil {
+		e.logger.Warn("Failed to count queued downloads", "error", err)
+		return 0
+	}
+	return int(n)
 }
 
-// SetContext sets the Wails context for event emission
+// SetContext sets the Wails context for event emission and starts the
+// engine's background work (see Run).
 func (e *TachyonEngine) SetContext(ctx context.Context) {
 	e.ctx = ctx
+	e.Run(ctx)
+}
+
+// Run resumes the downloads interrupted by the last exit and starts the
+// engine's background loops, which stop when ctx is cancelled. Headless
+// modes call it in place of SetContext, leaving events unrouted.
+func (e *TachyonEngine) Run(ctx context.Context) {
 	// Recover any downloads that were interrupted by app close
 	e.RecoverInterruptedDownloads()
+	go e.fileReconcileLoop(ctx)
+	go e.historyPruneLoop(ctx)
+	go e.autoscaleLoop(ctx)
+	go e.setProgressLoop(ctx)
+	go e.appProgressLoop(ctx)
+}
+
+// emit sends an event to the frontend (when a Wails context is set) and to
+// the optional event hook.
+func (e *TachyonEngine) emit(name string, data interface{}) {
+	if e.eventHook != nil {
+		e.eventHook(name, data)
+	}
+	if e.ctx != nil {
+		runtime.EventsEmit(e.ctx, name, data)
+	}
 }
 
 // Shutdown gracefully stops the engine
 func (e *TachyonEngine) Shutdown() error {
 	e.logger.Info("Engine shutting down...")
 
+	// 0. Stop dispatching so nothing new starts while we wind down
+	e.shuttingDown.Store(true)
+	e.queue.Close()
+	e.verifyMu.Lock()
+	e.verifyCond.Broadcast() // verifications still waiting for a slot give up
+	e.verifyMu.Unlock()
+	select {
+	case <-e.workerDone:
+	case <-time.After(queueWorkerStopTimeout):
+		e.logger.Warn("Queue worker did not stop in time")
+	}
+
 	// 1. Record IDs of actively running downloads so they can auto-resume on restart
 	var activeIDs []string
 	e.activeDownloads.Range(func(key, value interface{}) bool {
 		activeIDs = append(activeIDs, key.(string))
 		return true
 	})
+	// And those an emergency stop has already taken out of activeDownloads
+	e.emergencyPaused.Range(func(key, _ interface{}) bool {
+		activeIDs = append(activeIDs, key.(string))
+		return true
+	})
 	// Also include pending tasks (queued but not yet started)
 	if tasks, err := e.storage.GetAllTasks(); err == nil {
 		for _, t := range tasks {
-			if t.Status == "pending" || t.Status == "probing" {
+			if t.Status == storage.StatusPending || t.Status == storage.StatusProbing {
 				activeIDs = append(activeIDs, t.ID)
 			}
 		}
@@ -209,12 +514,15 @@ func (e *TachyonEngine) Shutdown() error {
 		return true
 	})
 
-	// Wait for workers to cleanup (max 2 seconds)
+	// Wait for workers and running verifications to cleanup (max 2 seconds)
 	deadline := time.Now().Add(2 * time.Second)
 	for {
 		e.workerMutex.Lock()
 		count := e.runningDownloads
 		e.workerMutex.Unlock()
+		e.verifyMu.Lock()
+		count += e.verifyPending
+		e.verifyMu.Unlock()
 		if count == 0 || time.Now().After(deadline) {
 			break
 		}
@@ -230,13 +538,16 @@ func (e *TachyonEngine) Shutdown() error {
 	// 4. Drain global worker pool
 	e.workerPool.Close()
 
+	e.SetFileWatching(false)
+
 	e.logger.Info("Engine shutdown complete")
 	return nil
 }
 
-// RecoverInterruptedDownloads finds downloads that were actively running when the
-// app last closed and auto-resumes them.  Downloads that were manually paused,
-// stopped, or in error are left untouched.
+// RecoverInterruptedDownloads finds downloads that were actively running when
+// the app last closed and auto-resumes them. Scheduled downloads are re-queued
+// with their start timer re-armed, and interrupted verifications re-run.
+// Downloads that were manually paused, stopped, or in error are left untouched.
 func (e *TachyonEngine) RecoverInterruptedDownloads() {
 	tasks, err := e.storage.GetAllTasks()
 	if err != nil {
@@ -258,10 +569,10 @@ func (e *TachyonEngine) RecoverInterruptedDownloads() {
 
 	for _, task := range tasks {
 		switch task.Status {
-		case "downloading", "pending", "probing", "merging":
+		case storage.StatusDownloading, storage.StatusPending, storage.StatusProbing, storage.StatusMerging:
 			// Active at close — always auto-resume regardless of whether
 			// shutdown was graceful or abrupt.
-			task.Status = "paused"
+			task.Status = storage.StatusPaused
 			if err := e.storage.SaveTask(task); err != nil {
 				e.logger.Error("Failed to pause interrupted download", "id", task.ID, "error", err)
 				continue
@@ -269,13 +580,19 @@ func (e *TachyonEngine) RecoverInterruptedDownloads() {
 			toResume = append(toResume, task.ID)
 			e.logger.Info("Recovered interrupted download (will auto-resume)", "id", task.ID, "filename", task.Filename)
 
-		case "scheduled":
-			// Re-queue scheduled tasks so the scheduler can fire them at the right time
+		case storage.StatusVerifying:
+			// The file was complete; only its check was cut short.
+			e.recoverVerification(task)
+
+		case storage.StatusScheduled:
+			// Re-queue scheduled tasks and re-arm their start timer; past-due
+			// ones are dispatched straight away.
 			restoredTask := task
 			e.queue.Push(&restoredTask)
+			e.armSchedule(task.StartTime)
 			e.logger.Info("Recovered scheduled download", "id", task.ID, "filename", task.Filename, "start_time", task.StartTime)
 
-		case "paused":
+		case storage.StatusPaused:
 			// Graceful shutdown — only auto-resume if it was active before shutdown
 			if autoResumeSet[task.ID] {
 				toResume = append(toResume, task.ID)
@@ -307,6 +624,12 @@ func (e *TachyonEngine) GetScanner() security.Scanner {
 	return e.scanner
 }
 
+// SetScanner replaces the AV scanner used on completed downloads. Call it
+// before downloads start; it is not synchronized with running ones.
+func (e *TachyonEngine) SetScanner(s security.Scanner) {
+	e.scanner = s
+}
+
 // joinIDs concatenates non-empty IDs with a comma separator.
 func joinIDs(ids []string) string { return strings.Join(ids, ",") }
 
@@ -346,8 +669,12 @@ func (e *TachyonEngine) SetMaxConcurrent(n int) {
 	if n < 1 {
 		n = 1
 	}
-	if n > 10 {
-		n = 10
+	if n > MaxConcurrentCap {
+		n = MaxConcurrentCap
+	}
+	e.manualConcurrent = n
+	if e.autoscale.Load() {
+		return // the autoscaler owns maxConcurrent until switched off
 	}
 	e.maxConcurrent = n
 	// Signal to check if more can be started
@@ -359,11 +686,68 @@ func (e *TachyonEngine) SetGlobalLimit(bytesPerSec int) {
 	e.bandwidthManager.SetLimit(bytesPerSec)
 }
 
+// QueueWaitTime returns how long a queued download has been waiting for a
+// slot. Long waits raise its effective priority in the scheduler.
+func (e *TachyonEngine) QueueWaitTime(id string) time.Duration {
+	return e.scheduler.WaitTime(id)
+}
+
+// SetDispatchOrder selects the order queued downloads start in: "priority"
+// (the default), "fifo", "size_asc" (smallest first) or "size_desc". Size
+// orders break ties between downloads of the same priority; downloads of
+// unknown size go last.
+func (e *TachyonEngine) SetDispatchOrder(mode string) error {
+	if err := e.scheduler.SetOrder(mode); err != nil {
+		return err
+	}
+	e.queue.Broadcast()
+	return nil
+}
+
+// GetDispatchOrder returns the order queued downloads start in.
+func (e *TachyonEngine) GetDispatchOrder() string {
+	return e.scheduler.GetOrder()
+}
+
 // SetHostLimit sets the per-host connection limit
 func (e *TachyonEngine) SetHostLimit(domain string, limit int) {
 	e.scheduler.SetHostLimit(domain, limit)
 }
 
+// SetMaxConnectionsPerHost caps the number of simultaneous connections to a
+// single host summed over all downloads. A value <= 0 removes the cap.
+func (e *TachyonEngine) SetMaxConnectionsPerHost(n int) {
+	e.hostLimitSet.Store(true)
+	e.hostBudget.SetLimit(n)
+}
+
+// GetMaxConnectionsPerHost returns the per-host connection cap (0 = unlimited).
+func (e *TachyonEngine) GetMaxConnectionsPerHost() int {
+	return e.hostBudget.Limit()
+}
+
+// SetMaxConnectionsPerDownload sets the most connections one download may
+// open (1..MaxConnectionsCap). Congestion control scales within it, and a
+// pinned connection count is clamped to it. The per-host cap moves with it
+// unless SetMaxConnectionsPerHost has set one.
+func (e *TachyonEngine) SetMaxConnectionsPerDownload(n int) {
+	n = min(max(n, 1), MaxConnectionsCap)
+	e.workerMutex.Lock()
+	e.maxWorkersPerTask = n
+	e.workerMutex.Unlock()
+	e.congestion.SetMaxWorkers(n)
+	if !e.hostLimitSet.Load() {
+		e.hostBudget.SetLimit(n)
+	}
+}
+
+// GetMaxConnectionsPerDownload returns the per-download connection ceiling.
+func (e *TachyonEngine) GetMaxConnectionsPerDownload() int {
+	e.workerMutex.Lock()
+	defer e.workerMutex.Unlock()
+	return e.maxWorkersPerTask
+}
+
 // GetHostLimit returns the per-host connection limit
 func (e *TachyonEngine) GetHostLimit(domain string) int {
 	return e.scheduler.GetHostLimit(domain)
@@ -1,13 +1,23 @@
 package engine
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"project-tachyon/internal/storage"
 
 	"github.com/glebarez/sqlite"
+	"go.uber.org/goleak"
 	"gorm.io/gorm"
 )
 
@@ -23,6 +33,7 @@ func createTestDB(t *testing.T) *storage.Storage {
 		&storage.DailyStat{},
 		&storage.AppSetting{},
 		&storage.SpeedTestHistory{},
+		&storage.HostProfile{},
 	); err != nil {
 		t.Fatalf("Migration failed: %v", err)
 	}
@@ -182,6 +193,194 @@ func TestRecoverInterruptedDownloads_NoAutoResumeForStoppedOrError(t *testing.T)
 	}
 }
 
+func TestRecoverInterruptedDownloads_ScheduledFiresAfterRestart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	var mu sync.Mutex
+	firstHit := make(map[string]time.Time)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if _, ok := firstHit[r.URL.Path]; !ok {
+			firstHit[r.URL.Path] = time.Now()
+		}
+		mu.Unlock()
+		http.ServeContent(w, r, "f.bin", time.Time{}, strings.NewReader("scheduled payload"))
+	}))
+	defer server.Close()
+
+	s := createTestDB(t)
+	dir := t.TempDir()
+	future := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	past := time.Now().Add(-time.Hour)
+
+	// Left behind by the previous run
+	s.SaveTask(storage.DownloadTask{
+		ID: "later", URL: server.URL + "/later", SavePath: filepath.Join(dir, "later.bin"),
+		Status: storage.StatusScheduled, StartTime: future.Format(time.RFC3339), QueueOrder: 1,
+	})
+	s.SaveTask(storage.DownloadTask{
+		ID: "overdue", URL: server.URL + "/overdue", SavePath: filepath.Join(dir, "overdue.bin"),
+		Status: storage.StatusScheduled, StartTime: past.Format(time.RFC3339), QueueOrder: 2,
+	})
+
+	// "Restart": a fresh engine over the same database
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := NewEngine(logger, s)
+	defer e.Shutdown()
+	e.allowLoopback = true
+	recovered := time.Now()
+	e.RecoverInterruptedDownloads()
+
+	waitHit := func(path string, within time.Duration) time.Time {
+		t.Helper()
+		deadline := time.Now().Add(within)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			at, ok := firstHit[path]
+			mu.Unlock()
+			if ok {
+				return at
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("%s was not requested within %v", path, within)
+		return time.Time{}
+	}
+
+	if at := waitHit("/overdue", 3*time.Second); at.Sub(recovered) > 2*time.Second {
+		t.Errorf("past-due download started %v after recovery, want immediately", at.Sub(recovered))
+	}
+
+	// Well inside the worker's 10s periodic re-check, so only the re-armed
+	// timer can have fired it
+	at := waitHit("/later", 6*time.Second)
+	if at.Before(future) {
+		t.Errorf("scheduled download fired at %v, before its start time %v", at, future)
+	}
+	if late := at.Sub(future); late > 2*time.Second {
+		t.Errorf("scheduled download fired %v late", late)
+	}
+}
+
+func TestShutdown_StopsQueueWorker(t *testing.T) {
+	s := createTestDB(t)
+	// Only goroutines started by the engine itself must be gone afterwards.
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+	start := time.Now()
+	if err := e.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v waiting for an idle queue worker", elapsed)
+	}
+	select {
+	case <-e.workerDone:
+	default:
+		t.Error("queue worker still running after Shutdown")
+	}
+}
+
+func TestShutdown_NoDispatchWhileStopping(t *testing.T) {
+	s := createTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+	e.Shutdown()
+
+	// A task queued after shutdown began is kept, not started.
+	task := &storage.DownloadTask{ID: "late", URL: "http://example.com/late.bin", Status: storage.StatusPending}
+	s.SaveTask(*task)
+	e.queue.Push(task)
+	e.queue.Broadcast()
+	time.Sleep(50 * time.Millisecond)
+
+	if _, active := e.activeDownloads.Load("late"); active {
+		t.Error("task dispatched after Shutdown")
+	}
+	if e.queue.Len() != 1 {
+		t.Errorf("queue length = %d, want the task left queued", e.queue.Len())
+	}
+}
+
+func TestShutdown_QueuedTasksNotStarted(t *testing.T) {
+	var requests atomic.Int64
+	content := generateDummyContent(4 * 1024 * 1024)
+	inner := spawnThrottledRangeServer(t, content, 20*time.Millisecond)
+	defer inner.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		inner.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	e.allowLoopback = true
+	e.SetMaxConcurrent(1)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := e.StartDownload(fmt.Sprintf("%s/q%d.bin", server.URL, i), t.TempDir(), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := e.activeDownloads.Load(ids[0]); ok && requests.Load() > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first download never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Cancelling the running download frees its slot; the queued ones must
+	// still not start.
+	if err := e.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	after := requests.Load()
+	time.Sleep(300 * time.Millisecond)
+	if got := requests.Load(); got != after {
+		t.Errorf("%d requests made after Shutdown", got-after)
+	}
+	for _, id := range ids[1:] {
+		task, _ := store.GetTask(id)
+		if task.Status != storage.StatusPending {
+			t.Errorf("queued task %s moved to %s during shutdown", id, task.Status)
+		}
+		if _, ok := e.activeDownloads.Load(id); ok {
+			t.Errorf("queued task %s started during shutdown", id)
+		}
+	}
+}
+
+func TestExecuteTask_BacksOutDuringShutdown(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	e.Shutdown()
+
+	// The worker picked this task just before Shutdown began.
+	task := &storage.DownloadTask{ID: "raced", URL: "http://example.com/raced.bin", Status: storage.StatusPending}
+	store.SaveTask(*task)
+	e.executeTask(task)
+
+	if got, _ := store.GetTask("raced"); got.Status != storage.StatusPending {
+		t.Errorf("status = %s, want pending", got.Status)
+	}
+	if _, ok := e.activeDownloads.Load("raced"); ok {
+		t.Error("task left registered as active")
+	}
+	if e.queue.Len() != 1 {
+		t.Errorf("queue length = %d, want the task put back", e.queue.Len())
+	}
+}
+
 func TestJoinSplitIDs(t *testing.T) {
 	ids := []string{"abc", "def", "ghi"}
 	joined := joinIDs(ids)
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// MigrationFailure is a completed download MigrateLocation could not move.
+type MigrationFailure struct {
+	ID    string `json:"id"`
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// MigrationResult summarizes a MigrateLocation run.
+type MigrationResult struct {
+	Moved   int                `json:"moved"`
+	Bytes   int64              `json:"bytes"`
+	Skipped int                `json:"skipped"` // unfinished downloads, left in place
+	Failed  []MigrationFailure `json:"failed"`
+}
+
+// MigrateLocation moves the files of every completed download saved under
+// oldPath to the same relative place under newPath, e.g. before retiring a
+// drive, and updates their SavePath. Files are renamed where possible and
+// copied across volumes otherwise; a name already taken at the destination
+// gets a numbered suffix. Unfinished downloads are skipped. With
+// updateSaved, a saved location at oldPath is re-pointed at newPath,
+// keeping its nickname and write limit.
+//
+// location:migrate_progress is emitted after each file and
+// location:migrated with the result at the end.
+func (e *TachyonEngine) MigrateLocation(oldPath, newPath string, updateSaved bool) (MigrationResult, error) {
+	result := MigrationResult{Failed: []MigrationFailure{}}
+	if strings.TrimSpace(oldPath) == "" {
+		return result, fmt.Errorf("no folder to move from")
+	}
+	from, err := filepath.Abs(oldPath)
+	if err != nil {
+		return result, fmt.Errorf("invalid folder %q: %w", oldPath, err)
+	}
+	if to, err := filepath.Abs(newPath); err == nil && (to == from || pathWithin(from, to)) {
+		return result, fmt.Errorf("%s is inside %s", to, from)
+	}
+	to, err := ValidateLocation(newPath)
+	if err != nil {
+		return result, err
+	}
+
+	tasks, err := e.storage.GetAllTasks()
+	if err != nil {
+		return result, err
+	}
+	var moving []storage.DownloadTask
+	for _, task := range tasks {
+		if !pathWithin(from, task.SavePath) {
+			continue
+		}
+		if _, active := e.activeDownloads.Load(task.ID); active || task.Status != storage.StatusCompleted {
+			result.Skipped++
+			continue
+		}
+		moving = append(moving, task)
+	}
+
+	e.logger.Info("Migrating download location", "from", from, "to", to, "files", len(moving), "skipped", result.Skipped)
+	for i, task := range moving {
+		newSavePath, err := e.migrateTask(&task, from, to)
+		if err != nil {
+			e.logger.Warn("Failed to migrate download", "id", task.ID, "path", task.SavePath, "error", err)
+			result.Failed = append(result.Failed, MigrationFailure{ID: task.ID, Path: task.SavePath, Error: err.Error()})
+		} else {
+			result.Moved++
+			result.Bytes += task.TotalSize
+			e.emit("download:relocated", map[string]interface{}{
+				"id":   task.ID,
+				"path": newSavePath,
+			})
+		}
+		e.emit("location:migrate_progress", map[string]interface{}{
+			"id":    task.ID,
+			"path":  newSavePath,
+			"done":  i + 1,
+			"total": len(moving),
+		})
+	}
+
+	if updateSaved {
+		e.moveSavedLocation(from, to)
+	}
+	e.logger.Info("Download location migrated", "from", from, "to", to, "moved", result.Moved, "failed", len(result.Failed))
+	e.emit("location:migrated", result)
+	return result, nil
+}
+
+// migrateTask moves a completed download's file (and sidecar) from under
+// from to the same place under to, and stores the new SavePath.
+func (e *TachyonEngine) migrateTask(task *storage.DownloadTask, from, to string) (string, error) {
+	if _, err := os.Stat(task.SavePath); err != nil {
+		return "", fmt.Errorf("file unavailable: %w", err)
+	}
+	rel, err := filepath.Rel(from, task.SavePath)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(to, rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	dest = filesystem.FindAvailablePathExcluding(dest, e.getReservedPaths())
+	if err := filesystem.MoveFile(task.SavePath, dest); err != nil {
+		return "", err
+	}
+
+	oldPath := task.SavePath
+	if err := e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+		t.SavePath = dest
+		t.Filename = filepath.Base(dest)
+	}); err != nil {
+		// Put the file back so the record still points at it
+		if rerr := filesystem.MoveFile(dest, oldPath); rerr != nil {
+			e.logger.Error("Migrated file left at new location", "id", task.ID, "path", dest, "error", rerr)
+		}
+		return "", fmt.Errorf("failed to update download: %w", err)
+	}
+	if _, err := os.Stat(sidecarPath(oldPath)); err == nil {
+		if err := filesystem.MoveFile(sidecarPath(oldPath), sidecarPath(dest)); err != nil {
+			e.logger.Warn("Failed to move sidecar", "path", sidecarPath(oldPath), "error", err)
+		}
+	}
+	e.files.remove(task.ID)
+	e.markFileState(task.ID, dest, true)
+	e.watchDir(dest)
+	return dest, nil
+}
+
+// moveSavedLocation re-points the saved location at from to to, if there is
+// one.
+func (e *TachyonEngine) moveSavedLocation(from, to string) {
+	locs, err := e.storage.GetLocations()
+	if err != nil {
+		e.logger.Warn("Failed to read saved locations", "error", err)
+		return
+	}
+	for _, loc := range locs {
+		if filepath.Clean(loc.Path) != from {
+			continue
+		}
+		if err := e.storage.AddLocation(to, loc.Nickname); err != nil {
+			e.logger.Warn("Failed to save migrated location", "path", to, "error", err)
+			return
+		}
+		if loc.WriteLimit > 0 {
+			e.storage.SetLocationWriteLimit(to, loc.WriteLimit)
+			e.SetDiskWriteLimit(to, loc.WriteLimit)
+			e.SetDiskWriteLimit(from, 0)
+		}
+		e.storage.DeleteLocation(loc.Path)
+		return
+	}
+}
+
+// pathWithin reports whether path lies inside dir.
+func pathWithin(dir, path string) bool {
+	if path == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestMigrateLocation(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	defer e.Shutdown()
+
+	var mu sync.Mutex
+	progress := 0
+	e.eventHook = func(name string, _ interface{}) {
+		if name == "location:migrate_progress" {
+			mu.Lock()
+			progress++
+			mu.Unlock()
+		}
+	}
+
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	write := func(rel, content string) string {
+		path := filepath.Join(oldDir, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	store.SaveTask(storage.DownloadTask{ID: "a", Filename: "a.zip", SavePath: write("Compressed/a.zip", "aaa"), Status: storage.StatusCompleted, TotalSize: 3})
+	store.SaveTask(storage.DownloadTask{ID: "b", Filename: "b.txt", SavePath: write("b.txt", "bb"), Status: storage.StatusCompleted, TotalSize: 2})
+	os.WriteFile(sidecarPath(filepath.Join(oldDir, "b.txt")), []byte("{}"), 0644)
+	store.SaveTask(storage.DownloadTask{ID: "paused", Filename: "p.bin", SavePath: filepath.Join(oldDir, "p.bin"), Status: storage.StatusPaused})
+	store.SaveTask(storage.DownloadTask{ID: "gone", Filename: "gone.bin", SavePath: filepath.Join(oldDir, "gone.bin"), Status: storage.StatusCompleted})
+	store.SaveTask(storage.DownloadTask{ID: "elsewhere", Filename: "x.bin", SavePath: filepath.Join(t.TempDir(), "x.bin"), Status: storage.StatusCompleted})
+
+	// A file already at the destination is not overwritten
+	os.WriteFile(filepath.Join(newDir, "b.txt"), []byte("existing"), 0644)
+
+	store.AddLocation(oldDir, "Old Drive")
+	store.SetLocationWriteLimit(oldDir, 1024)
+
+	result, err := e.MigrateLocation(oldDir, newDir, true)
+	if err != nil {
+		t.Fatalf("MigrateLocation: %v", err)
+	}
+	if result.Moved != 2 || result.Bytes != 5 || result.Skipped != 1 || len(result.Failed) != 1 || result.Failed[0].ID != "gone" {
+		t.Errorf("result = %+v", result)
+	}
+	if progress != 3 {
+		t.Errorf("%d progress events, want 3", progress)
+	}
+
+	a, _ := store.GetTask("a")
+	if want := filepath.Join(newDir, "Compressed", "a.zip"); a.SavePath != want {
+		t.Errorf("a moved to %s, want %s", a.SavePath, want)
+	}
+	if data, err := os.ReadFile(a.SavePath); err != nil || string(data) != "aaa" {
+		t.Errorf("a content = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(oldDir, "Compressed", "a.zip")); !os.IsNotExist(err) {
+		t.Error("a still at the old location")
+	}
+
+	b, _ := store.GetTask("b")
+	if b.SavePath == filepath.Join(newDir, "b.txt") || filepath.Dir(b.SavePath) != newDir || b.Filename != filepath.Base(b.SavePath) {
+		t.Errorf("b moved to %s (filename %s), want a new name beside the existing file", b.SavePath, b.Filename)
+	}
+	if data, _ := os.ReadFile(filepath.Join(newDir, "b.txt")); string(data) != "existing" {
+		t.Error("existing file at the destination was overwritten")
+	}
+	if _, err := os.Stat(sidecarPath(b.SavePath)); err != nil {
+		t.Errorf("sidecar not moved: %v", err)
+	}
+
+	for id, want := range map[string]string{
+		"paused": filepath.Join(oldDir, "p.bin"),
+		"gone":   filepath.Join(oldDir, "gone.bin"),
+	} {
+		if task, _ := store.GetTask(id); task.SavePath != want {
+			t.Errorf("%s path changed to %s", id, task.SavePath)
+		}
+	}
+
+	locs, _ := store.GetLocations()
+	if len(locs) != 1 || locs[0].Path != newDir || locs[0].Nickname != "Old Drive" || locs[0].WriteLimit != 1024 {
+		t.Errorf("saved locations = %+v", locs)
+	}
+	if e.GetDiskWriteLimit(newDir) != 1024 {
+		t.Error("write limit not moved to the new location")
+	}
+}
+
+func TestMigrateLocation_Rejects(t *testing.T) {
+	store := createTempDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+	defer e.Shutdown()
+
+	dir := t.TempDir()
+	for _, to := range []string{dir, filepath.Join(dir, "inner")} {
+		if _, err := e.MigrateLocation(dir, to, false); err == nil {
+			t.Errorf("migrating %s to %s succeeded", dir, to)
+		}
+	}
+	if _, err := e.MigrateLocation("", t.TempDir(), false); err == nil {
+		t.Error("empty source accepted")
+	}
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// SetPreserveModTime makes completed downloads take the server's
+// Last-Modified time as their modification time instead of the time the
+// download finished. Off by default.
+func (e *TachyonEngine) SetPreserveModTime(enabled bool) {
+	e.preserveModTime.Store(enabled)
+}
+
+// GetPreserveModTime reports whether Last-Modified is applied to completed files.
+func (e *TachyonEngine) GetPreserveModTime() bool {
+	return e.preserveModTime.Load()
+}
+
+// applyLastModified stamps the completed file with the server's
+// Last-Modified date when enabled. Missing or unparseable dates leave the
+// file untouched.
+func (e *TachyonEngine) applyLastModified(task *storage.DownloadTask, lastModified string) {
+	if !e.GetPreserveModTime() || lastModified == "" {
+		return
+	}
+	mtime, err := http.ParseTime(lastModified)
+	if err != nil {
+		e.logger.Warn("Ignoring unparseable Last-Modified", "id", task.ID, "value", lastModified)
+		return
+	}
+	if err := os.Chtimes(task.SavePath, time.Time{}, mtime); err != nil {
+		e.logger.Warn("Failed to set file modification time", "id", task.ID, "path", task.SavePath, "error", err)
+	}
+}
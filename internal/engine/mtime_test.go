@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// downloadWithLastModified downloads a file served with the given
+// Last-Modified header and returns the completed task.
+func downloadWithLastModified(t *testing.T, lastModified string, preserve bool) storage.DownloadTask {
+	t.Helper()
+	content := generateDummyContent(128 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", lastModified)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+	engine.SetPreserveModTime(preserve)
+
+	id, err := engine.StartDownload(server.URL+"/archive.bin", t.TempDir(), "archive.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(10 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		switch task.Status {
+		case storage.StatusCompleted:
+			return task
+		case storage.StatusError:
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestPreserveModTimeFromLastModified(t *testing.T) {
+	serverTime := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+	task := downloadWithLastModified(t, serverTime.Format(http.TimeFormat), true)
+
+	info, err := os.Stat(task.SavePath)
+	if err != nil {
+		t.Fatalf("stat completed file: %v", err)
+	}
+	if !info.ModTime().Equal(serverTime) {
+		t.Errorf("expected mtime %v, got %v", serverTime, info.ModTime().UTC())
+	}
+}
+
+func TestPreserveModTimeDisabledByDefault(t *testing.T) {
+	serverTime := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+	task := downloadWithLastModified(t, serverTime.Format(http.TimeFormat), false)
+
+	info, err := os.Stat(task.SavePath)
+	if err != nil {
+		t.Fatalf("stat completed file: %v", err)
+	}
+	if info.ModTime().Equal(serverTime) {
+		t.Error("mtime should not be changed when the option is off")
+	}
+}
+
+func TestApplyLastModifiedIgnoresBadDates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(path)
+
+	e := newHTTPEngine()
+	e.SetPreserveModTime(true)
+	e.applyLastModified(&storage.DownloadTask{ID: "t", SavePath: path}, "not a date")
+
+	after, _ := os.Stat(path)
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("unparseable Last-Modified should leave the file untouched")
+	}
+}
@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestSetNetworkProfile_RebuildsTransport(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	if e.GetNetworkProfile() != DefaultNetworkProfile {
+		t.Fatalf("expected %s by default, got %s", DefaultNetworkProfile, e.GetNetworkProfile())
+	}
+	before := e.transport.current.Load()
+
+	for _, name := range NetworkProfiles() {
+		if err := e.SetNetworkProfile(name); err != nil {
+			t.Fatalf("SetNetworkProfile(%s): %v", name, err)
+		}
+		want := networkProfiles[name]
+		tr := e.transport.current.Load()
+		if tr.MaxIdleConns != want.MaxIdleConns ||
+			tr.MaxIdleConnsPerHost != want.MaxIdleConnsPerHost ||
+			tr.IdleConnTimeout != want.IdleConnTimeout ||
+			tr.TLSHandshakeTimeout != want.TLSHandshakeTimeout ||
+			tr.ResponseHeaderTimeout != want.ResponseHeaderTimeout {
+			t.Errorf("%s: transport does not match profile: %+v", name, tr)
+		}
+		if !tr.DisableCompression || !tr.ForceAttemptHTTP2 {
+			t.Errorf("%s: transport lost its fixed settings", name)
+		}
+	}
+	if e.transport.current.Load() == before {
+		t.Error("expected the transport to be rebuilt")
+	}
+	if e.GetNetworkProfile() != NetworkProfiles()[len(NetworkProfiles())-1] {
+		t.Errorf("profile not recorded, got %s", e.GetNetworkProfile())
+	}
+}
+
+func TestSetNetworkProfile_UnknownRejected(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	before := e.transport.current.Load()
+	if err := e.SetNetworkProfile("warp-speed"); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+	if e.transport.current.Load() != before || e.GetNetworkProfile() != DefaultNetworkProfile {
+		t.Error("unknown profile must leave the transport unchanged")
+	}
+}
+
+func TestSetNetworkProfile_ClientKeepsWorking(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	if _, err := e.ProbeURL(server.URL+"/a.bin", "", ""); err != nil {
+		t.Fatalf("probe before switch: %v", err)
+	}
+	if err := e.SetNetworkProfile("satellite"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.ProbeURL(server.URL+"/b.bin", "", "")
+	if err != nil {
+		t.Fatalf("probe after switch: %v", err)
+	}
+	if result.Size != 10 {
+		t.Errorf("expected size 10, got %d", result.Size)
+	}
+}
+
+func TestReload_ActiveDownloadSurvives(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(8 * 1024 * 1024)
+	server := spawnThrottledRangeServer(t, content, 10*time.Millisecond)
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+	var paused atomic.Bool
+	e.eventHook = func(name string, _ interface{}) {
+		if name == "download:paused" {
+			paused.Store(true)
+		}
+	}
+
+	id, err := e.StartDownload(server.URL+"/reload.bin", t.TempDir(), "reload.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if val, ok := e.activeDownloads.Load(id); ok {
+			if cp := val.(*activeDownloadInfo).checkpoint.Load(); cp != nil && cp.Downloaded > 0 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("download never got going")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Switch profile the way a settings reload does, then rebuild.
+	e.netProfileMu.Lock()
+	e.netProfile = "conservative"
+	e.netProfileMu.Unlock()
+	before := e.transport.current.Load()
+	e.Reload()
+	tr := e.transport.current.Load()
+	if tr == before {
+		t.Fatal("Reload kept the old transport")
+	}
+	if tr.MaxIdleConnsPerHost != networkProfiles["conservative"].MaxIdleConnsPerHost {
+		t.Errorf("rebuilt transport ignores the profile: %+v", tr)
+	}
+
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if paused.Load() {
+		t.Error("Reload paused the running download")
+	}
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("download corrupted across Reload")
+	}
+}
+
+func TestWorkerGrowthPolicy_ProfileAndOverride(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	if perTick, interval := e.GetWorkerGrowthPolicy(); perTick != 2 || interval != 5*time.Second {
+		t.Fatalf("balanced policy = %d/%v, want 2/5s", perTick, interval)
+	}
+	if err := e.SetNetworkProfile("aggressive"); err != nil {
+		t.Fatal(err)
+	}
+	if perTick, interval := e.GetWorkerGrowthPolicy(); perTick != 4 || interval != 2*time.Second {
+		t.Fatalf("aggressive policy = %d/%v, want 4/2s", perTick, interval)
+	}
+
+	// Overriding only the growth keeps the profile's interval.
+	if err := e.SetWorkerGrowthPolicy(3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if perTick, interval := e.GetWorkerGrowthPolicy(); perTick != 3 || interval != 2*time.Second {
+		t.Fatalf("policy = %d/%v, want 3/2s", perTick, interval)
+	}
+
+	for _, bad := range []struct {
+		perTick  int
+		interval time.Duration
+	}{
+		{-1, 0}, {MaxWorkerGrowth + 1, 0}, {0, 500 * time.Millisecond}, {0, 2 * time.Minute},
+	} {
+		if err := e.SetWorkerGrowthPolicy(bad.perTick, bad.interval); err == nil {
+			t.Errorf("SetWorkerGrowthPolicy(%d, %v) accepted", bad.perTick, bad.interval)
+		}
+	}
+	if perTick, _ := e.GetWorkerGrowthPolicy(); perTick != 3 {
+		t.Errorf("rejected policy changed the growth to %d", perTick)
+	}
+
+	if err := e.SetWorkerGrowthPolicy(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if perTick, interval := e.GetWorkerGrowthPolicy(); perTick != 4 || interval != 2*time.Second {
+		t.Errorf("0/0 should restore the profile's policy, got %d/%v", perTick, interval)
+	}
+}
+
+func TestGrowWorkers_SpawnRateFollowsPolicy(t *testing.T) {
+	for _, growth := range []int{1, 2, 4, MaxWorkerGrowth} {
+		current, ideal := int32(4), int32(16)
+		var ticks int
+		for current < ideal {
+			next := growWorkers(current, ideal, growth)
+			if added := next - current; added > int32(growth) || added < 1 {
+				t.Fatalf("growth %d: tick %d added %d workers", growth, ticks, added)
+			}
+			current = next
+			ticks++
+		}
+		if want := (12 + growth - 1) / growth; ticks != want {
+			t.Errorf("growth %d: reached %d workers in %d ticks, want %d", growth, ideal, ticks, want)
+		}
+		if growWorkers(current, ideal, growth) != ideal {
+			t.Errorf("growth %d: target moved past ideal", growth)
+		}
+	}
+}
+
+func TestDNSOverHTTPS_DownloadResolvesThroughProvider(t *testing.T) {
+	content := generateDummyContent(128 * 1024)
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer files.Close()
+
+	var mu sync.Mutex
+	var queried []string
+	doh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var q dnsmessage.Message
+		if err := q.Unpack(body); err != nil || len(q.Questions) != 1 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		queried = append(queried, q.Questions[0].Name.String())
+		mu.Unlock()
+		reply := dnsmessage.Message{Header: dnsmessage.Header{Response: true}, Questions: q.Questions}
+		if q.Questions[0].Type == dnsmessage.TypeA {
+			reply.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+			}}
+		}
+		packed, _ := reply.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer doh.Close()
+
+	e, store := newLoopbackEngine(t)
+	if err := e.SetDNSOverHTTPS(doh.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// A name only the DoH server knows
+	port := mustURL(t, files.URL).Port()
+	id, err := e.StartDownload(fmt.Sprintf("http://files.tachyon.invalid:%s/data.bin", port), t.TempDir(), "data.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+	if task.TotalSize != int64(len(content)) {
+		t.Errorf("expected %d bytes, got %d", len(content), task.TotalSize)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queried) == 0 || queried[0] != "files.tachyon.invalid." {
+		t.Errorf("expected the DoH server to be asked for files.tachyon.invalid, got %v", queried)
+	}
+}
+
+func TestSetDNSOverHTTPS_Validation(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	if err := e.SetDNSOverHTTPS("http://dns.example/dns-query"); err == nil {
+		t.Error("expected plain http provider to be rejected")
+	}
+	if err := e.SetDNSOverHTTPS("not a url"); err == nil {
+		t.Error("expected invalid URL to be rejected")
+	}
+	if err := e.SetDNSOverHTTPS("https://dns.example/dns-query"); err != nil {
+		t.Fatal(err)
+	}
+	if e.GetDNSOverHTTPS() != "https://dns.example/dns-query" {
+		t.Errorf("provider not recorded: %q", e.GetDNSOverHTTPS())
+	}
+	if err := e.SetDNSOverHTTPS(""); err != nil || e.GetDNSOverHTTPS() != "" {
+		t.Errorf("expected empty URL to restore system DNS, got %q %v", e.GetDNSOverHTTPS(), err)
+	}
+}
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"project-tachyon/internal/notify"
+	"project-tachyon/internal/storage"
+)
+
+// notifyTimeout bounds one delivery to one backend, retries included.
+const notifyTimeout = time.Minute
+
+// SetNotificationSettings replaces the email and chat backends told when a
+// download completes or fails. Invalid settings are rejected as a whole.
+func (e *TachyonEngine) SetNotificationSettings(s notify.Settings) error {
+	ns, err := s.Notifiers(e.webhookClient)
+	if err != nil {
+		return err
+	}
+	e.notifyMu.Lock()
+	e.notifySettings = s
+	e.notifiers = ns
+	e.notifyMu.Unlock()
+	return nil
+}
+
+// GetNotificationSettings returns the notification backend settings.
+func (e *TachyonEngine) GetNotificationSettings() notify.Settings {
+	e.notifyMu.RLock()
+	defer e.notifyMu.RUnlock()
+	return e.notifySettings
+}
+
+// notifyBackends sends task's outcome to every enabled backend and the
+// completion webhook, each in the background, so a slow mail server or
+// receiver never holds up the download path. startedAt may be zero when
+// the run's start is unknown.
+func (e *TachyonEngine) notifyBackends(task *storage.DownloadTask, startedAt time.Time, reason string) {
+	e.notifyMu.RLock()
+	ns := e.notifiers
+	e.notifyMu.RUnlock()
+	e.webhookMu.RLock()
+	if e.webhook != nil {
+		// Copy rather than append into the shared slice
+		ns = append(ns[:len(ns):len(ns)], e.webhook)
+	}
+	e.webhookMu.RUnlock()
+	if len(ns) == 0 {
+		return
+	}
+
+	// Messages are shared like exports and sidecars, so a signed link's
+	// query and any password stay out of them
+	link := task.URL
+	if u, err := url.Parse(task.URL); err == nil {
+		link = redactURL(u)
+	}
+	ev := notify.Event{
+		Kind:     notify.KindCompleted,
+		ID:       task.ID,
+		Filename: task.Filename,
+		URL:      link,
+		Path:     task.SavePath,
+		Size:     task.TotalSize,
+		Error:    reason,
+	}
+	if task.Status != storage.StatusCompleted {
+		ev.Kind = notify.KindFailed
+		ev.Size = task.Downloaded
+	}
+	if !startedAt.IsZero() {
+		ev.Duration = time.Since(startedAt)
+	}
+	for _, n := range ns {
+		go func(n notify.Notifier) {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := n.Notify(ctx, ev); err != nil {
+				e.logger.Warn("Notification failed", "id", task.ID, "backend", n.Name(), "error", err)
+			}
+		}(n)
+	}
+}
+
+// SendTestNotification sends a test message through every enabled backend
+// and reports the ones that failed.
+func (e *TachyonEngine) SendTestNotification() error {
+	e.notifyMu.RLock()
+	ns := e.notifiers
+	e.notifyMu.RUnlock()
+	if len(ns) == 0 {
+		return fmt.Errorf("no notification backend is enabled")
+	}
+
+	ev := notify.Event{Kind: notify.KindTest, ID: "test", Filename: "tachyon-notification-test.bin"}
+	errs := make([]error, len(ns))
+	var wg sync.WaitGroup
+	for i, n := range ns {
+		wg.Add(1)
+		go func(i int, n notify.Notifier) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			errs[i] = n.Notify(ctx, ev)
+		}(i, n)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/notify"
+	"project-tachyon/internal/storage"
+)
+
+// fakeNotifier records events and fails with err.
+type fakeNotifier struct {
+	events chan notify.Event
+	err    error
+}
+
+func (f *fakeNotifier) Name() string { return "fake" }
+
+func (f *fakeNotifier) Notify(ctx context.Context, ev notify.Event) error {
+	f.events <- ev
+	return f.err
+}
+
+func waitForNotification(t *testing.T, events chan notify.Event) notify.Event {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification was not sent")
+	}
+	return notify.Event{}
+}
+
+func TestNotifyBackends_OnCompletion(t *testing.T) {
+	engine, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+	messages := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Content string }
+		json.NewDecoder(r.Body).Decode(&body)
+		messages <- body.Content
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	if err := engine.SetNotificationSettings(notify.Settings{Discord: notify.DiscordConfig{Enabled: true, WebhookURL: server.URL + "/api/webhooks/1/x"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := engine.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+	select {
+	case msg := <-messages:
+		if !strings.HasPrefix(msg, "Download complete: "+task.Filename) {
+			t.Errorf("message = %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Discord message was not sent")
+	}
+}
+
+func TestNotifyBackends_RedactsSignedURL(t *testing.T) {
+	engine, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+	messages := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Content string }
+		json.NewDecoder(r.Body).Decode(&body)
+		messages <- body.Content
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	if err := engine.SetNotificationSettings(notify.Settings{Discord: notify.DiscordConfig{Enabled: true, WebhookURL: server.URL + "/api/webhooks/1/x"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := engine.StartDownload(url+"?X-Amz-Signature=s3cr3t", t.TempDir(), "file.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	waitForFinalStatus(t, store, id)
+	select {
+	case msg := <-messages:
+		if strings.Contains(msg, "s3cr3t") {
+			t.Errorf("message leaks the signature: %q", msg)
+		}
+		if !strings.Contains(msg, "X-Amz-Signature=") {
+			t.Errorf("message lost the link: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Discord message was not sent")
+	}
+}
+
+func TestNotifyBackends_OnFailure(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	fake := &fakeNotifier{events: make(chan notify.Event, 4)}
+	engine.notifiers = []notify.Notifier{fake}
+
+	task := &storage.DownloadTask{ID: "t1", Filename: "a.bin", Downloaded: 512, TotalSize: 1024}
+	engine.failTask(task, "server went away")
+
+	ev := waitForNotification(t, fake.events)
+	if ev.Kind != notify.KindFailed || ev.ID != "t1" || ev.Error != "server went away" || ev.Size != 512 {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestSendTestNotification(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	if err := engine.SendTestNotification(); err == nil {
+		t.Error("expected an error with no backend enabled")
+	}
+
+	ok := &fakeNotifier{events: make(chan notify.Event, 1)}
+	broken := &fakeNotifier{events: make(chan notify.Event, 1), err: errors.New("bad token")}
+	engine.notifiers = []notify.Notifier{ok, broken}
+	err := engine.SendTestNotification()
+	if err == nil || !strings.Contains(err.Error(), "bad token") {
+		t.Errorf("err = %v, want the failing backend's error", err)
+	}
+	if ev := waitForNotification(t, ok.events); ev.Kind != notify.KindTest {
+		t.Errorf("event kind = %s, want %s", ev.Kind, notify.KindTest)
+	}
+}
+
+func TestSetNotificationSettings_Validation(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	bad := notify.Settings{Telegram: notify.TelegramConfig{Enabled: true}}
+	if err := engine.SetNotificationSettings(bad); err == nil {
+		t.Fatal("expected an error for a Telegram backend without a token")
+	}
+	if got := engine.GetNotificationSettings(); got.Telegram.Enabled {
+		t.Error("rejected settings were stored")
+	}
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// finishPartial records a download that stopped at its byte cap (the
+// "max_bytes" option). The file holds only the head of the remote one, so
+// it is kept as is: not checked against the download's hash, not scanned
+// and not reported as completed.
+func (e *TachyonEngine) finishPartial(task *storage.DownloadTask, startedAt time.Time) {
+	if err := setStatus(task, storage.StatusPartial); err != nil {
+		e.failTask(task, err.Error())
+		return
+	}
+	task.Progress = 100
+	task.Speed = 0
+	task.TimeRemaining = ""
+	if err := e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+		t.Status = storage.StatusPartial
+		t.Progress = 100
+		t.Downloaded = task.Downloaded
+		t.TotalSize = task.TotalSize
+		t.Speed = 0
+		t.TimeRemaining = ""
+	}); err != nil {
+		e.logger.Error("Failed to persist partial status", "id", task.ID, "error", err)
+	}
+
+	elapsed := time.Since(startedAt).Seconds()
+	e.logger.Info("Download stopped at its byte cap", "id", task.ID, "bytes", task.Downloaded)
+	e.recordEvent(EventDownloadPartial, task.ID, "Stopped %s after %d bytes (max_bytes)", task.Filename, task.Downloaded)
+	e.markFileState(task.ID, task.SavePath, true)
+	e.emit("download:partial", map[string]interface{}{
+		"id":         task.ID,
+		"path":       task.SavePath,
+		"downloaded": task.Downloaded,
+		"max_bytes":  task.MaxBytes,
+		"elapsed":    elapsed,
+	})
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// startCapped downloads url with the given max_bytes and waits for it to end.
+func startCapped(t *testing.T, e *TachyonEngine, store *storage.Storage, url string, maxBytes int64) storage.DownloadTask {
+	t.Helper()
+	id, err := e.StartDownload(url, t.TempDir(), "", map[string]string{"max_bytes": strconv.FormatInt(maxBytes, 10)})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	return waitForFinalStatus(t, store, id)
+}
+
+// assertHead checks that task ended partial with exactly the first n bytes
+// of content on disk.
+func assertHead(t *testing.T, task storage.DownloadTask, content []byte, n int64) {
+	t.Helper()
+	if task.Status != storage.StatusPartial {
+		t.Fatalf("status = %s, want partial", task.Status)
+	}
+	if task.Downloaded != n || task.MaxBytes != n {
+		t.Errorf("downloaded = %d, max_bytes = %d, want %d", task.Downloaded, task.MaxBytes, n)
+	}
+	data, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatalf("partial file not kept: %v", err)
+	}
+	if int64(len(data)) != n || !bytes.Equal(data, content[:n]) {
+		t.Errorf("file is %d bytes, want the first %d bytes of the remote file", len(data), n)
+	}
+}
+
+func TestMaxBytes_RangedDownloadStopsAtCap(t *testing.T) {
+	content := generateDummyContent(4 * 1024 * 1024)
+	var whole atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" && r.Method == http.MethodGet {
+			whole.Store(true) // a whole-file GET would defeat the cap
+		}
+		http.ServeContent(w, r, "big.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+
+	// Over several parts and not on a part boundary
+	const maxBytes = 1300 * 1024
+	task := startCapped(t, e, store, server.URL+"/big.bin", maxBytes)
+	assertHead(t, task, content, maxBytes)
+	if task.TotalSize != maxBytes {
+		t.Errorf("total size = %d, want %d", task.TotalSize, maxBytes)
+	}
+	if whole.Load() {
+		t.Error("the whole file was requested")
+	}
+	if ev := e.GetEventLog(0); len(ev) == 0 || ev[0].Kind != EventDownloadPartial {
+		t.Errorf("events = %+v, want %s last", ev, EventDownloadPartial)
+	}
+}
+
+func TestMaxBytes_StreamIsCutOff(t *testing.T) {
+	content := generateDummyContent(512 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No ranges and no length: the body has to be cut off mid-stream
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	}))
+	defer server.Close()
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+
+	const maxBytes = 100 * 1000
+	task := startCapped(t, e, store, server.URL+"/stream.bin", maxBytes)
+	assertHead(t, task, content, maxBytes)
+}
+
+func TestMaxBytes_LargerThanFileCompletes(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "small.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+	e, store := newLoopbackEngine(t)
+	e.SetDownloadTuning(MaxWorkersPerTask, 512*1024)
+
+	task := startCapped(t, e, store, server.URL+"/small.bin", 1024*1024)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed for a file under the cap", task.Status)
+	}
+	if data, _ := os.ReadFile(task.SavePath); !bytes.Equal(data, content) {
+		t.Error("file differs from the remote one")
+	}
+}
+
+func TestMaxBytes_InvalidOption(t *testing.T) {
+	e, _ := newLoopbackEngine(t)
+	for _, v := range []string{"0", "-5", "lots"} {
+		if _, err := e.StartDownload("http://127.0.0.1:1/a.bin", t.TempDir(), "", map[string]string{"max_bytes": v}); err == nil {
+			t.Errorf("max_bytes=%q accepted", v)
+		}
+	}
+	if _, err := e.StartDownload("http://127.0.0.1:1/a.bin", t.TempDir(), "", map[string]string{"max_bytes": "10", "replace": "/tmp/x.bin"}); err == nil {
+		t.Error("max_bytes combined with replace accepted")
+	}
+}
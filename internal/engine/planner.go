@@ -1,13 +1,102 @@
 package engine
 
+import (
+	"fmt"
+	"sort"
+
+	"project-tachyon/internal/storage"
+)
+
 const (
 	minAdaptiveChunk = int64(512 * 1024)
 	maxAdaptiveChunk = int64(16 * 1024 * 1024)
 	StreamEndOffset  = int64(^uint64(0) >> 1)
+
+	// DefaultMinPartSize is the smallest final part the planner leaves on
+	// its own; a shorter remainder is folded into the part before it.
+	DefaultMinPartSize = int64(256 * 1024)
 )
 
+// SetMinPartSize sets the smallest final part a download is split into. A
+// remainder shorter than bytes is merged into the previous part rather than
+// fetched as a tiny request of its own. 0 disables merging.
+func (e *TachyonEngine) SetMinPartSize(bytes int64) {
+	if bytes < 0 {
+		bytes = 0
+	}
+	e.minPartSize.Store(bytes)
+}
+
+// GetMinPartSize returns the minimum final part size in bytes.
+func (e *TachyonEngine) GetMinPartSize() int64 {
+	return e.minPartSize.Load()
+}
+
+// ConcurrencyStep caps the initial connection count of downloads smaller
+// than MaxSize bytes. Files above the last step use the full per-task limit.
+type ConcurrencyStep struct {
+	MaxSize     int64 `json:"max_size"`
+	Connections int   `json:"connections"`
+}
+
+// DefaultConcurrencyCurve returns the default size curve: 4 connections
+// under 10MB, 16 under 1GB, the per-task maximum above that.
+func DefaultConcurrencyCurve() []ConcurrencyStep {
+	return []ConcurrencyStep{
+		{MaxSize: 10 * 1024 * 1024, Connections: 4},
+		{MaxSize: 1024 * 1024 * 1024, Connections: 16},
+	}
+}
+
+// SetConcurrencyCurve replaces the size curve bounding a download's initial
+// worker count. An empty curve removes the size limit.
+func (e *TachyonEngine) SetConcurrencyCurve(steps []ConcurrencyStep) error {
+	curve := make([]ConcurrencyStep, len(steps))
+	copy(curve, steps)
+	for _, s := range curve {
+		if s.MaxSize <= 0 {
+			return fmt.Errorf("invalid concurrency step size: %d", s.MaxSize)
+		}
+		if s.Connections < 1 {
+			return fmt.Errorf("invalid concurrency step connections: %d", s.Connections)
+		}
+	}
+	sort.Slice(curve, func(i, j int) bool { return curve[i].MaxSize < curve[j].MaxSize })
+
+	e.sizeCurveMu.Lock()
+	e.sizeCurve = curve
+	e.sizeCurveMu.Unlock()
+	return nil
+}
+
+// GetConcurrencyCurve returns a copy of the current size curve.
+func (e *TachyonEngine) GetConcurrencyCurve() []ConcurrencyStep {
+	e.sizeCurveMu.RLock()
+	defer e.sizeCurveMu.RUnlock()
+	curve := make([]ConcurrencyStep, len(e.sizeCurve))
+	copy(curve, e.sizeCurve)
+	return curve
+}
+
+// sizeConcurrencyCap returns the curve's connection limit for a file of
+// totalSize bytes, or 0 when the size is unknown or above every step.
+func (e *TachyonEngine) sizeConcurrencyCap(totalSize int64) int {
+	if totalSize <= 0 {
+		return 0
+	}
+	e.sizeCurveMu.RLock()
+	defer e.sizeCurveMu.RUnlock()
+	for _, s := range e.sizeCurve {
+		if totalSize < s.MaxSize {
+			return s.Connections
+		}
+	}
+	return 0
+}
+
 // planDownloadParts builds a deterministic segment plan with finer tail chunks
-// to reduce straggler effects near completion.
+// to reduce straggler effects near completion. A final part shorter than the
+// minimum part size is merged into the one before it.
 func (e *TachyonEngine) planDownloadParts(totalSize int64, acceptRanges bool) []DownloadPart {
 	if totalSize <= 0 || !acceptRanges {
 		return []DownloadPart{{ID: 0, StartOffset: 0, EndOffset: StreamEndOffset, Attempts: 0}}
@@ -44,9 +133,28 @@ func (e *TachyonEngine) planDownloadParts(totalSize int64, acceptRanges bool) []
 		id++
 	}
 
+	// Fold a tiny remainder into the part before it
+	if n := len(parts); n > 1 {
+		last := parts[n-1]
+		if last.EndOffset-last.StartOffset+1 < e.minPartSize.Load() {
+			parts[n-2].EndOffset = last.EndOffset
+			parts = parts[:n-1]
+		}
+	}
+
 	return parts
 }
 
+// planCappedParts plans a download that stops after maxBytes: the file's
+// first maxBytes bytes in ranged parts, or one part cut off at maxBytes when
+// the server sends the file whole or its size is unknown.
+func (e *TachyonEngine) planCappedParts(maxBytes, totalSize int64, acceptRanges bool) []DownloadPart {
+	if acceptRanges && totalSize > 0 {
+		return e.planDownloadParts(maxBytes, true)
+	}
+	return []DownloadPart{{ID: 0, StartOffset: 0, EndOffset: maxBytes - 1}}
+}
+
 func (e *TachyonEngine) selectChunkSize(totalSize int64) int64 {
 	if e.baseChunkSize > 0 {
 		return clampChunk(e.baseChunkSize)
@@ -81,7 +189,7 @@ func (e *TachyonEngine) selectWorkerCountH2(host string, numParts int, acceptRan
 		workers = 4
 	}
 
-	maxWorkers := e.maxWorkersPerTask
+	maxWorkers := e.GetMaxConnectionsPerDownload()
 	if maxWorkers < 1 {
 		maxWorkers = 1
 	}
@@ -104,6 +212,34 @@ func (e *TachyonEngine) selectWorkerCountH2(host string, numParts int, acceptRan
 	return workers
 }
 
+// workerCountForTask returns the initial worker count for a download. The
+// size curve bounds the starting point; congestion control may scale past it
+// afterwards. A pinned connection count bypasses both but is still bounded
+// by range support and the number of parts.
+func (e *TachyonEngine) workerCountForTask(task *storage.DownloadTask, host string, numParts int, acceptRanges bool, isH2 bool) int {
+	if task.Connections <= 0 {
+		workers := e.selectWorkerCountH2(host, numParts, acceptRanges, isH2)
+		if limit := e.sizeConcurrencyCap(task.TotalSize); limit > 0 && workers > limit {
+			workers = limit
+		}
+		return workers
+	}
+	if !acceptRanges || numParts < 1 {
+		return 1
+	}
+	workers := e.clampConnections(task.Connections)
+	if workers > numParts {
+		workers = numParts
+	}
+	return workers
+}
+
+// clampConnections bounds a user-supplied connection count to 1 and the
+// per-download connection ceiling.
+func (e *TachyonEngine) clampConnections(n int) int {
+	return min(max(n, 1), e.GetMaxConnectionsPerDownload())
+}
+
 func clampChunk(size int64) int64 {
 	if size < minAdaptiveChunk {
 		return minAdaptiveChunk
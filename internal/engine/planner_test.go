@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"project-tachyon/internal/network"
+	"project-tachyon/internal/storage"
 )
 
 // newPlannerEngine creates a minimal TachyonEngine for testing planner functions.
@@ -140,6 +141,46 @@ func TestPlanDownloadParts_ExactChunkBoundary(t *testing.T) {
 	}
 }
 
+func TestPlanDownloadParts_MergesTinyTail(t *testing.T) {
+	e := newPlannerEngine(16, 1024*1024)
+	// 1MB body chunks, 512KB tail chunks, then 100 bytes left over
+	size := int64(10*1024*1024 + 100)
+	split := e.planDownloadParts(size, true)
+	if tail := split[len(split)-1]; tail.EndOffset-tail.StartOffset+1 != 100 {
+		t.Fatalf("without a minimum the tail is %d bytes, want 100", tail.EndOffset-tail.StartOffset+1)
+	}
+
+	e.SetMinPartSize(DefaultMinPartSize)
+	parts := e.planDownloadParts(size, true)
+	if len(parts) != len(split)-1 {
+		t.Fatalf("got %d parts, want %d", len(parts), len(split)-1)
+	}
+	last := parts[len(parts)-1]
+	if last.StartOffset != split[len(split)-2].StartOffset || last.EndOffset != size-1 {
+		t.Errorf("last part = [%d, %d], want the tail merged into [%d, %d]",
+			last.StartOffset, last.EndOffset, split[len(split)-2].StartOffset, size-1)
+	}
+	if last.ID != len(parts)-1 {
+		t.Errorf("last part ID = %d, want %d", last.ID, len(parts)-1)
+	}
+	for i := 1; i < len(parts); i++ {
+		if parts[i].StartOffset != parts[i-1].EndOffset+1 {
+			t.Fatalf("gap at part %d", i)
+		}
+	}
+
+	// A tail at or above the minimum stays its own part
+	e.SetMinPartSize(100)
+	if got := e.planDownloadParts(size, true); len(got) != len(split) {
+		t.Errorf("got %d parts with a 100-byte minimum, want %d", len(got), len(split))
+	}
+	// A single-part download has nothing to merge into
+	e.SetMinPartSize(DefaultMinPartSize)
+	if got := e.planDownloadParts(1000, true); len(got) != 1 || got[0].EndOffset != 999 {
+		t.Errorf("small file plan = %+v", got)
+	}
+}
+
 func TestPlanDownloadParts_UniquePartIDs(t *testing.T) {
 	e := newPlannerEngine(16, 0)
 	parts := e.planDownloadParts(10*1024*1024, true)
@@ -319,3 +360,111 @@ func TestHostSingleStream_EmptyHost(t *testing.T) {
 		t.Error("empty host should return false")
 	}
 }
+
+// --- workerCountForTask ---
+
+func TestWorkerCountForTask_PinnedIgnoresCongestion(t *testing.T) {
+	e := newPlannerEngine(24, 0)
+	host := "pinned.example.com"
+	// Drive the AIMD controller well above the pinned value
+	for i := 0; i < 200; i++ {
+		e.congestion.RecordOutcome(host, 0, nil)
+		e.congestion.GetIdealConcurrency(host)
+	}
+
+	task := &storage.DownloadTask{Connections: 3}
+	if got := e.workerCountForTask(task, host, 100, true, false); got != 3 {
+		t.Errorf("pinned 3 → got %d", got)
+	}
+	if got := e.workerCountForTask(task, host, 2, true, false); got != 2 {
+		t.Errorf("pinned 3 with 2 parts → got %d, want 2", got)
+	}
+	if got := e.workerCountForTask(task, host, 100, false, false); got != 1 {
+		t.Errorf("pinned without range support → got %d, want 1", got)
+	}
+
+	auto := &storage.DownloadTask{}
+	if got := e.workerCountForTask(auto, host, 100, true, false); got <= 3 {
+		t.Errorf("auto-tuned count should follow congestion controller, got %d", got)
+	}
+}
+
+func TestClampConnections(t *testing.T) {
+	e := newPlannerEngine(MaxWorkersPerTask, 0)
+	cases := map[int]int{-5: 1, 0: 1, 1: 1, 8: 8, MaxWorkersPerTask: MaxWorkersPerTask, 500: MaxWorkersPerTask}
+	for in, want := range cases {
+		if got := e.clampConnections(in); got != want {
+			t.Errorf("clampConnections(%d) = %d, want %d", in, got, want)
+		}
+	}
+
+	e = newPlannerEngine(48, 0)
+	if got := e.clampConnections(500); got != 48 {
+		t.Errorf("clampConnections(500) = %d, want the raised ceiling 48", got)
+	}
+}
+
+func TestWorkerCountForTask_SizeCurve(t *testing.T) {
+	e := newPlannerEngine(24, 0)
+	if err := e.SetConcurrencyCurve(DefaultConcurrencyCurve()); err != nil {
+		t.Fatal(err)
+	}
+	host := "curve.example.com"
+	for i := 0; i < 200; i++ {
+		e.congestion.RecordOutcome(host, 0, nil)
+		e.congestion.GetIdealConcurrency(host)
+	}
+	uncapped := e.selectWorkerCount(host, 1000, true)
+	if uncapped <= 16 {
+		t.Fatalf("congestion controller should allow more than 16 workers, got %d", uncapped)
+	}
+
+	cases := []struct {
+		size int64
+		want int
+	}{
+		{5 * 1024 * 1024, 4},
+		{500 * 1024 * 1024, 16},
+		{5 * 1024 * 1024 * 1024, uncapped},
+		{0, uncapped}, // unknown size is not capped
+	}
+	for _, c := range cases {
+		task := &storage.DownloadTask{TotalSize: c.size}
+		if got := e.workerCountForTask(task, host, 1000, true, false); got != c.want {
+			t.Errorf("size %d → %d workers, want %d", c.size, got, c.want)
+		}
+	}
+
+	// Pinned connections bypass the curve
+	pinned := &storage.DownloadTask{TotalSize: 1024, Connections: 8}
+	if got := e.workerCountForTask(pinned, host, 1000, true, false); got != 8 {
+		t.Errorf("pinned 8 on small file → got %d", got)
+	}
+}
+
+func TestSetConcurrencyCurve(t *testing.T) {
+	e := newPlannerEngine(24, 0)
+	// Steps are sorted by size regardless of input order
+	if err := e.SetConcurrencyCurve([]ConcurrencyStep{{MaxSize: 1 << 30, Connections: 8}, {MaxSize: 1 << 20, Connections: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.sizeConcurrencyCap(1 << 10); got != 2 {
+		t.Errorf("1KB cap = %d, want 2", got)
+	}
+	if got := e.sizeConcurrencyCap(1 << 25); got != 8 {
+		t.Errorf("32MB cap = %d, want 8", got)
+	}
+	if got := e.sizeConcurrencyCap(1 << 31); got != 0 {
+		t.Errorf("2GB cap = %d, want 0 (no limit)", got)
+	}
+
+	if err := e.SetConcurrencyCurve([]ConcurrencyStep{{MaxSize: 1024, Connections: 0}}); err == nil {
+		t.Error("expected error for zero connections")
+	}
+	if err := e.SetConcurrencyCurve([]ConcurrencyStep{{MaxSize: 0, Connections: 4}}); err == nil {
+		t.Error("expected error for zero size")
+	}
+	if len(e.GetConcurrencyCurve()) != 2 {
+		t.Error("rejected curve should leave the previous one in place")
+	}
+}
@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"net/http"
 	"sync"
 	"time"
+
+	"project-tachyon/internal/storage"
 )
 
 const probeCacheTTL = 30 * time.Second
@@ -23,14 +26,16 @@ func newProbeCache() *probeCache {
 	return &probeCache{items: make(map[string]*cachedProbe)}
 }
 
-// Put stores a probe result for the given URL.
+// Put stores a copy of a probe result for the given URL.
 func (pc *probeCache) Put(url string, result *ProbeResult) {
+	stored := *result
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
-	pc.items[url] = &cachedProbe{result: result, created: time.Now()}
+	pc.items[url] = &cachedProbe{result: &stored, created: time.Now()}
 }
 
-// Get returns a cached probe if it exists and is still fresh.
+// Get returns a copy of a cached probe if it exists and is still fresh, so
+// callers may adjust it without affecting later lookups.
 func (pc *probeCache) Get(url string) *ProbeResult {
 	pc.mu.RLock()
 	defer pc.mu.RUnlock()
@@ -38,7 +43,8 @@ func (pc *probeCache) Get(url string) *ProbeResult {
 	if !ok || time.Since(entry.created) > probeCacheTTL {
 		return nil
 	}
-	return entry.result
+	result := *entry.result
+	return &result
 }
 
 // Delete removes a cached probe.
@@ -47,3 +53,51 @@ func (pc *probeCache) Delete(url string) {
 	defer pc.mu.Unlock()
 	delete(pc.items, url)
 }
+
+// resumeProbe rebuilds the probe recorded in a task's resume state if it is
+// younger than probeCacheTTL, so a quick pause/resume cycle skips the
+// network. It also returns when that probe was taken. A fresher probe of the
+// same URL whose validators disagree means the file changed, and the
+// recorded probe is not used.
+func (e *TachyonEngine) resumeProbe(task *storage.DownloadTask) (*ProbeResult, time.Time) {
+	state, err := e.loadState(task.MetaJSON)
+	if err != nil || state == nil || state.ProbedAt == 0 || state.TotalSize <= 0 {
+		return nil, time.Time{}
+	}
+	probedAt := time.Unix(state.ProbedAt, 0)
+	if age := time.Since(probedAt); age < 0 || age > probeCacheTTL {
+		return nil, time.Time{}
+	}
+	if cached := e.probes.Get(task.URL); cached != nil {
+		if !e.stateManager.Validate(state, map[string]string{
+			"ETag":          cached.ETag,
+			"Last-Modified": cached.LastModified,
+		}) {
+			return nil, time.Time{}
+		}
+	}
+	return &ProbeResult{
+		Size:         state.TotalSize,
+		Filename:     task.Filename,
+		Status:       http.StatusOK,
+		AcceptRanges: state.AcceptRanges,
+		ETag:         state.ETag,
+		LastModified: state.LastModified,
+		IsHTTP2:      state.HTTP2,
+		ContentType:  state.ContentType,
+	}, probedAt
+}
+
+// forgetProbe drops every cached probe of a task's current URL, both in
+// memory and in its resume state, so the next start probes afresh.
+func (e *TachyonEngine) forgetProbe(task *storage.DownloadTask) {
+	e.probes.Delete(task.URL)
+	state, err := e.loadState(task.MetaJSON)
+	if err != nil || state == nil || state.ProbedAt == 0 {
+		return
+	}
+	state.ProbedAt = 0
+	if meta, err := e.stateManager.Serialize(state); err == nil {
+		task.MetaJSON = meta
+	}
+}
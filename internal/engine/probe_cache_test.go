@@ -1,8 +1,17 @@
 package engine
 
 import (
+	"crypto/md5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"project-tachyon/internal/storage"
 )
 
 func TestProbeCache_PutAndGet(t *testing.T) {
@@ -51,3 +60,148 @@ func TestProbeCache_TTLExpiry(t *testing.T) {
 		t.Error("expected nil for expired entry")
 	}
 }
+
+func TestProbeCache_GetReturnsCopy(t *testing.T) {
+	pc := newProbeCache()
+	pc.Put("http://example.com/c", &ProbeResult{Size: 300, AcceptRanges: true})
+
+	pc.Get("http://example.com/c").AcceptRanges = false
+	if !pc.Get("http://example.com/c").AcceptRanges {
+		t.Error("mutating a cached probe leaked into the cache")
+	}
+}
+
+func TestResumeProbe_Freshness(t *testing.T) {
+	store := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+
+	stateAt := func(probedAt time.Time, etag string) string {
+		meta, err := e.stateManager.Serialize(&storage.ResumeState{
+			ETag:         etag,
+			TotalSize:    4096,
+			AcceptRanges: true,
+			ProbedAt:     probedAt.Unix(),
+			Parts:        map[int]storage.PartState{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meta
+	}
+	task := &storage.DownloadTask{ID: "r", URL: "http://example.com/file.bin", Filename: "file.bin"}
+
+	task.MetaJSON = stateAt(time.Now(), `"v1"`)
+	probe, _ := e.resumeProbe(task)
+	if probe == nil || probe.Size != 4096 || !probe.AcceptRanges || probe.ETag != `"v1"` {
+		t.Fatalf("fresh probe not reused: %+v", probe)
+	}
+
+	task.MetaJSON = stateAt(time.Now().Add(-probeCacheTTL-time.Second), `"v1"`)
+	if probe, _ := e.resumeProbe(task); probe != nil {
+		t.Error("stale probe was reused")
+	}
+
+	// A newer probe of the URL reports a different file.
+	task.MetaJSON = stateAt(time.Now(), `"v1"`)
+	e.probes.Put(task.URL, &ProbeResult{Size: 4096, ETag: `"v2"`})
+	if probe, _ := e.resumeProbe(task); probe != nil {
+		t.Error("probe reused although the ETag changed")
+	}
+}
+
+func TestUpdateDownloadURL_InvalidatesProbe(t *testing.T) {
+	store := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), store)
+
+	meta, _ := e.stateManager.Serialize(&storage.ResumeState{TotalSize: 4096, ProbedAt: time.Now().Unix()})
+	task := storage.DownloadTask{ID: "u", URL: "http://example.com/old.bin", Status: storage.StatusPaused, MetaJSON: meta}
+	if err := store.SaveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	e.probes.Put(task.URL, &ProbeResult{Size: 4096})
+
+	if err := e.UpdateDownloadURL("u", "http://example.com/new.bin"); err != nil {
+		t.Fatalf("UpdateDownloadURL: %v", err)
+	}
+	got, _ := store.GetTask("u")
+	if probe, _ := e.resumeProbe(&got); probe != nil {
+		t.Error("recorded probe survived a URL change")
+	}
+	if e.probes.Get("http://example.com/old.bin") != nil {
+		t.Error("cached probe of the old URL survived a URL change")
+	}
+}
+
+func TestQuickResume_ReusesProbe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	content := generateDummyContent(12 * 1024 * 1024)
+	inner := spawnThrottledRangeServer(t, content, 20*time.Millisecond)
+	defer inner.Close()
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			probes.Add(1)
+		}
+		inner.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	e, store := newLoopbackEngine(t)
+
+	url := server.URL + "/quick.bin"
+	id, err := e.StartDownload(url, t.TempDir(), "quick.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		if val, ok := e.activeDownloads.Load(id); ok {
+			if cp := val.(*activeDownloadInfo).checkpoint.Load(); cp != nil && completedInState(t, cp.MetaJSON) > 0 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no part completed in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	e.PauseDownload(id)
+	for {
+		task, _ := store.GetTask(id)
+		if _, active := e.activeDownloads.Load(id); !active && task.Status == storage.StatusPaused {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("download did not pause in time (status %s)", task.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	before := probes.Load()
+	if before == 0 {
+		t.Fatal("the first start did not probe")
+	}
+
+	// Only the resume state remains, as after a restart.
+	e.probes.Delete(url)
+	if err := e.ResumeDownload(id); err != nil {
+		t.Fatalf("ResumeDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if got := probes.Load(); got != before {
+		t.Errorf("resume probed again: %d probes, want %d", got, before)
+	}
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md5.Sum(got) != md5.Sum(content) {
+		t.Error("resumed download is corrupt")
+	}
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// recheckFreshFor is how long a passed recheck is trusted while the file's
+// size and modification time stay the same.
+const recheckFreshFor = time.Hour
+
+// recheckStamp records a passed recheck and the file it saw.
+type recheckStamp struct {
+	at      time.Time
+	size    int64
+	modTime time.Time
+}
+
+// SetVerifyOnOpen makes RecheckIntegrity re-verify completed downloads
+// against their stored hash, e.g. before they are opened.
+func (e *TachyonEngine) SetVerifyOnOpen(enabled bool) {
+	e.verifyOnOpen.Store(enabled)
+}
+
+// GetVerifyOnOpen reports whether completed downloads are re-verified
+// before being opened.
+func (e *TachyonEngine) GetVerifyOnOpen() bool {
+	return e.verifyOnOpen.Load()
+}
+
+// RecheckIntegrity re-verifies a completed download's file against its
+// expected hash when verify-on-open is enabled. It returns nil when the
+// option is off, the task has no hash, or the file passed a recheck within
+// the last hour and has not changed since. On a mismatch it emits
+// download:integrity_warning and returns the verification error.
+func (e *TachyonEngine) RecheckIntegrity(id string) error {
+	if !e.GetVerifyOnOpen() {
+		return nil
+	}
+	task, err := e.storage.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	if task.Status != storage.StatusCompleted || task.ExpectedHash == "" || task.SavePath == "" {
+		return nil
+	}
+	fi, err := os.Stat(task.SavePath)
+	if err != nil {
+		return fmt.Errorf("cannot check %s: %w", task.SavePath, err)
+	}
+
+	e.recheckMu.Lock()
+	stamp, ok := e.rechecked[id]
+	e.recheckMu.Unlock()
+	if ok && time.Since(stamp.at) < recheckFreshFor && stamp.size == fi.Size() && stamp.modTime.Equal(fi.ModTime()) {
+		return nil
+	}
+
+	e.logger.Info("Rechecking integrity", "id", id, "path", task.SavePath)
+	if err := e.verifyFile(task.SavePath, task.HashAlgorithm, task.ExpectedHash); err != nil {
+		e.recheckMu.Lock()
+		delete(e.rechecked, id)
+		e.recheckMu.Unlock()
+		e.logger.Warn("Integrity recheck failed", "id", id, "path", task.SavePath, "error", err)
+		e.recordEvent(EventIntegrityFailed, id, "Recheck failed: %v", err)
+		e.emit("download:integrity_warning", map[string]interface{}{
+			"id":    id,
+			"path":  task.SavePath,
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	e.recheckMu.Lock()
+	e.rechecked[id] = recheckStamp{at: time.Now(), size: fi.Size(), modTime: fi.ModTime()}
+	e.recheckMu.Unlock()
+	return nil
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestRecheckIntegrity_DetectsLaterCorruption(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	sum := sha256.Sum256(content)
+	e, store, base := newVerifyTest(t, content, nil)
+	var verifications atomic.Int32
+	verify := e.verifyFile
+	e.verifyFile = func(path, algo, expected string) error {
+		verifications.Add(1)
+		return verify(path, algo, expected)
+	}
+	var mu sync.Mutex
+	var warnings []map[string]interface{}
+	e.eventHook = func(name string, data interface{}) {
+		if name == "download:integrity_warning" {
+			mu.Lock()
+			warnings = append(warnings, data.(map[string]interface{}))
+			mu.Unlock()
+		}
+	}
+
+	id, err := e.StartDownload(base+"/critical.bin", t.TempDir(), "", map[string]string{
+		"expected_hash": hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+
+	// Off by default: nothing is checked.
+	if err := e.RecheckIntegrity(id); err != nil || verifications.Load() != 1 {
+		t.Fatalf("recheck with the option off: err=%v, %d verifications", err, verifications.Load())
+	}
+
+	e.SetVerifyOnOpen(true)
+	if err := e.RecheckIntegrity(id); err != nil {
+		t.Fatalf("intact file failed its recheck: %v", err)
+	}
+	if err := e.RecheckIntegrity(id); err != nil || verifications.Load() != 2 {
+		t.Errorf("a fresh recheck should be reused: err=%v, %d verifications, want 2", err, verifications.Load())
+	}
+
+	// Corrupt the file in place after the download finished.
+	data, _ := os.ReadFile(task.SavePath)
+	data[100] ^= 0xFF
+	if err := os.WriteFile(task.SavePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(task.SavePath, later, later)
+
+	if err := e.RecheckIntegrity(id); err == nil {
+		t.Fatal("recheck did not detect the corrupted file")
+	}
+	mu.Lock()
+	if len(warnings) != 1 || warnings[0]["id"] != id {
+		t.Errorf("integrity warnings = %v, want one for %s", warnings, id)
+	}
+	mu.Unlock()
+	if got := e.GetEventLog(1); len(got) != 1 || got[0].Kind != EventIntegrityFailed {
+		t.Errorf("newest event = %v, want the failed recheck", got)
+	}
+
+	// A failure is never cached.
+	if err := e.RecheckIntegrity(id); err == nil {
+		t.Error("second recheck of the corrupted file passed")
+	}
+}
+
+func TestRecheckIntegrity_SkipsTasksWithoutHash(t *testing.T) {
+	e, store, _ := newVerifyTest(t, nil, func(path, algo, expected string) error {
+		t.Error("verification should not run")
+		return nil
+	})
+	e.SetVerifyOnOpen(true)
+	store.SaveTask(storage.DownloadTask{ID: "nohash", Status: storage.StatusCompleted, SavePath: "/nonexistent"})
+	store.SaveTask(storage.DownloadTask{ID: "paused", Status: storage.StatusPaused, SavePath: "/nonexistent", ExpectedHash: "ab"})
+
+	for _, id := range []string{"nohash", "paused"} {
+		if err := e.RecheckIntegrity(id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+	if err := e.RecheckIntegrity("missing"); err == nil {
+		t.Error("expected an error for an unknown task")
+	}
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// replacementPath checks that existing is a file a download may replace and
+// returns the path the new copy downloads to, in the same folder so the
+// final swap is a rename rather than a copy.
+func replacementPath(existing string, reserved map[string]bool) (string, error) {
+	existing = filepath.Clean(existing)
+	info, err := os.Stat(existing)
+	if err != nil {
+		return "", fmt.Errorf("file to replace not found: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", existing)
+	}
+	return filesystem.FindAvailablePathExcluding(existing+".new", reserved), nil
+}
+
+// commitReplacement swaps a verified download in over the file it replaces,
+// first moving the original to <path>.bak when the task asks for a backup.
+// If the swap fails the backup is moved back so the original stays put.
+func (e *TachyonEngine) commitReplacement(task *storage.DownloadTask) error {
+	target := task.ReplacePath
+	var backup string
+	if task.ReplaceBackup {
+		backup = target + ".bak"
+		if err := filesystem.RemoveFile(backup); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		if err := filesystem.RenameFile(target, backup); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to back up %s: %w", target, err)
+			}
+			backup = ""
+		}
+	}
+	if err := filesystem.RenameFile(task.SavePath, target); err != nil {
+		if backup != "" {
+			if rerr := filesystem.RenameFile(backup, target); rerr != nil {
+				e.logger.Error("Failed to restore replaced file", "path", target, "backup", backup, "error", rerr)
+			}
+		}
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+
+	e.logger.Info("Replaced existing file", "id", task.ID, "path", target, "backup", backup)
+	task.SavePath = target
+	task.Filename = filepath.Base(target)
+	e.emit("download:path_updated", map[string]interface{}{
+		"id":       task.ID,
+		"path":     task.SavePath,
+		"filename": task.Filename,
+		"backup":   backup,
+	})
+	return nil
+}
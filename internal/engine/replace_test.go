@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// waitForFinalStatus polls until the task completes or fails.
+func waitForFinalStatus(t *testing.T, store *storage.Storage, id string) storage.DownloadTask {
+	t.Helper()
+	deadline := time.After(10 * time.Second)
+	for {
+		task, _ := store.GetTask(id)
+		if task.Status == storage.StatusCompleted || task.Status == storage.StatusPartial || task.Status == storage.StatusError {
+			return task
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func newReplaceTest(t *testing.T, content []byte) (*TachyonEngine, *storage.Storage, string, string) {
+	t.Helper()
+	server := spawnContentServer(t, content)
+
+	existing := filepath.Join(t.TempDir(), "tool.bin")
+	if err := os.WriteFile(existing, []byte("old version"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, store := newLoopbackEngine(t)
+	return engine, store, server.URL + "/tool.bin", existing
+}
+
+func TestReplaceExistingFile(t *testing.T) {
+	content := generateDummyContent(300 * 1024)
+	engine, store, url, existing := newReplaceTest(t, content)
+	sum := sha256.Sum256(content)
+
+	id, err := engine.StartDownload(url, filepath.Dir(existing), "", map[string]string{
+		"replace":        existing,
+		"replace_backup": "true",
+		"expected_hash":  hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+	if task.SavePath != existing {
+		t.Errorf("expected task to point at %s, got %s", existing, task.SavePath)
+	}
+
+	got, _ := os.ReadFile(existing)
+	if !bytes.Equal(got, content) {
+		t.Error("existing file was not replaced with the download")
+	}
+	if bak, _ := os.ReadFile(existing + ".bak"); string(bak) != "old version" {
+		t.Errorf("expected backup of the old file, got %q", bak)
+	}
+	if _, err := os.Stat(existing + ".new"); !os.IsNotExist(err) {
+		t.Error("temporary download should be gone after the swap")
+	}
+}
+
+func TestReplaceKeepsOriginalOnVerifyFailure(t *testing.T) {
+	engine, store, url, existing := newReplaceTest(t, generateDummyContent(300*1024))
+
+	id, err := engine.StartDownload(url, filepath.Dir(existing), "", map[string]string{
+		"replace":        existing,
+		"replace_backup": "true",
+		"expected_hash":  "0000000000000000000000000000000000000000000000000000000000000000",
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusError {
+		t.Fatalf("expected verification failure, got %s", task.Status)
+	}
+
+	if got, _ := os.ReadFile(existing); string(got) != "old version" {
+		t.Errorf("original must be left intact, got %d bytes", len(got))
+	}
+	if _, err := os.Stat(existing + ".bak"); !os.IsNotExist(err) {
+		t.Error("no backup should be made when nothing was replaced")
+	}
+	if _, err := os.Stat(task.SavePath + ".corrupted"); err != nil {
+		t.Errorf("failed download should be quarantined beside the original: %v", err)
+	}
+}
+
+func TestReplaceRequiresExistingFile(t *testing.T) {
+	engine, _, url, existing := newReplaceTest(t, []byte("x"))
+	_, err := engine.StartDownload(url, filepath.Dir(existing), "", map[string]string{
+		"replace": filepath.Join(filepath.Dir(existing), "missing.bin"),
+	})
+	if err == nil {
+		t.Fatal("expected error replacing a file that does not exist")
+	}
+}
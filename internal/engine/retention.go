@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// historyPruneInterval is how often finished downloads older than the
+// retention window are removed from history.
+const historyPruneInterval = 24 * time.Hour
+
+// SetHistoryRetention sets how many days finished downloads stay in history
+// (0 keeps them forever) and whether pruning also deletes their files.
+func (e *TachyonEngine) SetHistoryRetention(days int, deleteFiles bool) {
+	if days < 0 {
+		days = 0
+	}
+	e.retentionDays.Store(int32(days))
+	e.retentionDeleteFiles.Store(deleteFiles)
+}
+
+// GetHistoryRetention returns the history retention window in days and
+// whether pruning deletes files.
+func (e *TachyonEngine) GetHistoryRetention() (days int, deleteFiles bool) {
+	return int(e.retentionDays.Load()), e.retentionDeleteFiles.Load()
+}
+
+// PruneHistory removes completed, failed and stopped downloads last updated
+// before the retention window and returns how many were removed. Active and
+// paused downloads are never pruned. Deleting files only removes those of
+// completed and partial downloads; failed and stopped ones lose their part
+// files either way.
+func (e *TachyonEngine) PruneHistory() (int, error) {
+	days, deleteFiles := e.GetHistoryRetention()
+	if days <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	tasks, err := e.storage.GetAllTasks()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load history: %w", err)
+	}
+	var ids []string
+	for _, t := range tasks {
+		switch t.Status {
+		case storage.StatusCompleted, storage.StatusPartial, storage.StatusError, storage.StatusStopped:
+		default:
+			continue
+		}
+		if _, active := e.activeDownloads.Load(t.ID); active {
+			continue
+		}
+		if !lastActivity(t).Before(cutoff) {
+			continue
+		}
+		switch t.Status {
+		case storage.StatusCompleted, storage.StatusPartial:
+			if deleteFiles && t.SavePath != "" {
+				if err := filesystem.RemoveFile(t.SavePath); err != nil {
+					e.logger.Warn("Failed to delete file", "path", t.SavePath, "error", err)
+				}
+				e.removeSidecar(t.SavePath)
+			}
+		default:
+			// A failed or stopped download never wrote SavePath, and its
+			// path is free for a newer download to use; only its part
+			// files are its own.
+			partsDir := e.partsDirForTask(t.ID, t.SavePath)
+			cleanupPartFiles(partsDir, t.ID)
+			os.Remove(partsDir) // only once no other download uses it
+		}
+		ids = append(ids, t.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := e.storage.DeleteTasks(ids); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		e.queue.Remove(id)
+		e.files.remove(id)
+		e.debugLogs.Delete(id)
+	}
+	e.logger.Info("Pruned download history", "removed", len(ids), "retention_days", days)
+	e.emit("download:bulk-deleted", map[string]interface{}{
+		"ids": ids,
+	})
+	return len(ids), nil
+}
+
+// lastActivity returns when a task was last updated, falling back to its
+// creation time. Tasks with no parseable time are treated as recent.
+func lastActivity(t storage.DownloadTask) time.Time {
+	for _, ts := range []string{t.UpdatedAt, t.CreatedAt} {
+		if at, err := time.Parse(time.RFC3339, ts); err == nil {
+			return at
+		}
+	}
+	return time.Now()
+}
+
+// historyPruneLoop prunes history at start-up and then daily until ctx is
+// done.
+func (e *TachyonEngine) historyPruneLoop(ctx context.Context) {
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.PruneHistory(); err != nil {
+			e.logger.Warn("History pruning failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// insertAgedTask stores a task whose UpdatedAt is age in the past. SaveTask
+// always stamps the current time, so the row is written directly.
+func insertAgedTask(t *testing.T, s *storage.Storage, id string, status storage.Status, path string, age time.Duration) {
+	t.Helper()
+	at := time.Now().Add(-age).Format(time.RFC3339)
+	task := storage.DownloadTask{
+		ID:        id,
+		URL:       "http://example.com/" + id,
+		Filename:  filepath.Base(path),
+		SavePath:  path,
+		Status:    status,
+		CreatedAt: at,
+		UpdatedAt: at,
+	}
+	if err := s.DB.Create(&task).Error; err != nil {
+		t.Fatalf("insert %s: %v", id, err)
+	}
+}
+
+func TestPruneHistory_RemovesOnlyOldFinished(t *testing.T) {
+	s := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+	dir := t.TempDir()
+	day := 24 * time.Hour
+
+	oldFile := filepath.Join(dir, "old.bin")
+	if err := os.WriteFile(oldFile, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	insertAgedTask(t, s, "old-done", storage.StatusCompleted, oldFile, 40*day)
+	insertAgedTask(t, s, "old-error", storage.StatusError, filepath.Join(dir, "e.bin"), 40*day)
+	insertAgedTask(t, s, "old-stopped", storage.StatusStopped, filepath.Join(dir, "s.bin"), 40*day)
+	insertAgedTask(t, s, "old-paused", storage.StatusPaused, filepath.Join(dir, "p.bin"), 40*day)
+	insertAgedTask(t, s, "old-pending", storage.StatusPending, filepath.Join(dir, "q.bin"), 40*day)
+	insertAgedTask(t, s, "new-done", storage.StatusCompleted, filepath.Join(dir, "n.bin"), day)
+
+	e.SetHistoryRetention(30, false)
+	n, err := e.PruneHistory()
+	if err != nil {
+		t.Fatalf("PruneHistory: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 pruned, got %d", n)
+	}
+
+	for _, id := range []string{"old-done", "old-error", "old-stopped"} {
+		if _, err := s.GetTask(id); err == nil {
+			t.Errorf("%s should have been pruned", id)
+		}
+	}
+	for _, id := range []string{"old-paused", "old-pending", "new-done"} {
+		if _, err := s.GetTask(id); err != nil {
+			t.Errorf("%s should have been kept: %v", id, err)
+		}
+	}
+	if _, err := os.Stat(oldFile); err != nil {
+		t.Errorf("file should be kept when deleteFiles is off: %v", err)
+	}
+}
+
+func TestPruneHistory_DeletesFiles(t *testing.T) {
+	s := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+
+	path := filepath.Join(t.TempDir(), "old.bin")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	insertAgedTask(t, s, "old", storage.StatusCompleted, path, 10*24*time.Hour)
+
+	e.SetHistoryRetention(7, true)
+	if n, err := e.PruneHistory(); err != nil || n != 1 {
+		t.Fatalf("expected 1 pruned, got %d (%v)", n, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected file to be deleted, stat err = %v", err)
+	}
+}
+
+func TestPruneHistory_DisabledByDefault(t *testing.T) {
+	s := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+	insertAgedTask(t, s, "ancient", storage.StatusCompleted, "", 1000*24*time.Hour)
+
+	if n, err := e.PruneHistory(); err != nil || n != 0 {
+		t.Fatalf("expected nothing pruned with retention off, got %d (%v)", n, err)
+	}
+	if _, err := s.GetTask("ancient"); err != nil {
+		t.Errorf("task should be kept: %v", err)
+	}
+}
+
+func TestPruneHistory_FailedTaskKeepsReusedPath(t *testing.T) {
+	s := createDownloadsTestDB(t)
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), s)
+	dir := t.TempDir()
+
+	// A newer download finished at the path the failed one would have used
+	path := filepath.Join(dir, "tool.zip")
+	if err := os.WriteFile(path, []byte("newer download"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	insertAgedTask(t, s, "old-error", storage.StatusError, path, 10*24*time.Hour)
+	partsDir := tempDirForTask(path)
+	if err := os.MkdirAll(partsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	part := filepath.Join(partsDir, "old-error.part.0")
+	if err := os.WriteFile(part, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e.SetHistoryRetention(7, true)
+	if n, err := e.PruneHistory(); err != nil || n != 1 {
+		t.Fatalf("expected 1 pruned, got %d (%v)", n, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "newer download" {
+		t.Errorf("the other download's file was touched: %q, %v", data, err)
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Errorf("part file left behind, stat err = %v", err)
+	}
+	if _, err := os.Stat(partsDir); !os.IsNotExist(err) {
+		t.Errorf("empty parts folder left behind, stat err = %v", err)
+	}
+}
@@ -153,7 +153,7 @@ func (s *APIServer) handleDownload(w http.ResponseWriter, r *http.Request) {
 	defaultPath := filepath.Join(homeDir, "Downloads")
 
 	// Start Download
-	id, err := s.engine.StartDownload(req.URL, defaultPath, "", nil)
+	id, err := s.engine.StartDownload(req.URL, defaultPath, "", req.options())
 	if err != nil {
 		s.logger.Error("API failed to start download", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -164,6 +164,31 @@ func (s *APIServer) handleDownload(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"id": id, "status": "started"})
 }
 
+// options carries the request's cookies, user agent and referer over to
+// the download, the same way the browser trigger endpoint does.
+func (req DownloadRequest) options() map[string]string {
+	options := make(map[string]string)
+	if req.Cookies != "" {
+		parsed := (&http.Request{Header: http.Header{"Cookie": {req.Cookies}}}).Cookies()
+		if b, err := json.Marshal(parsed); err == nil && len(parsed) > 0 {
+			options["cookies_json"] = string(b)
+		}
+	}
+	headers := make(map[string]string)
+	if req.UserAgent != "" {
+		headers["User-Agent"] = req.UserAgent
+	}
+	if req.Referer != "" {
+		headers["Referer"] = req.Referer
+	}
+	if len(headers) > 0 {
+		if b, err := json.Marshal(headers); err == nil {
+			options["headers_json"] = string(b)
+		}
+	}
+	return options
+}
+
 // checkDomainFilters checks if the request should be blocked, allowed, or handled by silent mode
 // Returns: "blocked", "whitelisted", "silent", or "allowed"
 func (s *APIServer) checkDomainFilters(referer, downloadURL string) string {
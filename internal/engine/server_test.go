@@ -2,12 +2,14 @@ package engine
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"project-tachyon/internal/storage"
 
@@ -22,7 +24,7 @@ func newTestAPIServer(t *testing.T) (*APIServer, *storage.Storage) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db.AutoMigrate(&storage.DownloadTask{}, &storage.DownloadLocation{}, &storage.DailyStat{}, &storage.AppSetting{})
+	db.AutoMigrate(&storage.DownloadTask{}, &storage.DownloadLocation{}, &storage.DailyStat{}, &storage.AppSetting{}, &storage.HostProfile{})
 	store := &storage.Storage{DB: db}
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
@@ -203,6 +205,51 @@ func TestHandleDownload_ValidToken(t *testing.T) {
 	}
 }
 
+func TestHandleDownload_PassesCookiesAndHeaders(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // downloads go to ~/Downloads
+	seen := make(chan http.Header, 4)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Clone()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer origin.Close()
+
+	srv, store := newTestAPIServer(t)
+	srv.engine.allowLoopback = true
+	defer srv.engine.Shutdown()
+
+	body := fmt.Sprintf(`{"url":%q,"cookies":"session=abc123; theme=dark","userAgent":"TestBrowser/1.0","referer":"https://example.com/page"}`, origin.URL+"/file.zip")
+	req := httptest.NewRequest("POST", "/api/v1/download", strings.NewReader(body))
+	req.Header.Set("X-Tachyon-Token", srv.token)
+	rec := httptest.NewRecorder()
+	srv.handleDownload(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]string
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	task, err := store.GetTask(resp["id"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(task.Cookies, `"session"`) || !strings.Contains(task.Cookies, `"abc123"`) || !strings.Contains(task.Cookies, `"theme"`) {
+		t.Errorf("task cookies = %q", task.Cookies)
+	}
+	if !strings.Contains(task.Headers, "TestBrowser/1.0") || !strings.Contains(task.Headers, "https://example.com/page") {
+		t.Errorf("task headers = %q", task.Headers)
+	}
+
+	select {
+	case h := <-seen:
+		if !strings.Contains(h.Get("Cookie"), "session=abc123") || h.Get("User-Agent") != "TestBrowser/1.0" || h.Get("Referer") != "https://example.com/page" {
+			t.Errorf("origin saw Cookie=%q User-Agent=%q Referer=%q", h.Get("Cookie"), h.Get("User-Agent"), h.Get("Referer"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("download never reached the origin")
+	}
+}
+
 func TestHandleDownload_InvalidBody(t *testing.T) {
 	srv, _ := newTestAPIServer(t)
 	req := httptest.NewRequest("POST", "/api/v1/download", strings.NewReader("not json"))
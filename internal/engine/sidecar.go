@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/integrity"
+	"project-tachyon/internal/storage"
+)
+
+// sidecarSuffix is appended to a completed file's path for its metadata file.
+const sidecarSuffix = ".tachyon.json"
+
+// Sidecar is the provenance record written next to a completed download.
+type Sidecar struct {
+	Version       int               `json:"version"`
+	URL           string            `json:"url"`
+	Filename      string            `json:"filename"`
+	Size          int64             `json:"size"`
+	HashAlgorithm string            `json:"hash_algorithm"`
+	Hash          string            `json:"hash"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	CreatedAt     string            `json:"created_at"`
+	CompletedAt   string            `json:"completed_at"`
+}
+
+// SetWriteSidecar enables writing <file>.tachyon.json beside each completed
+// download, recording where it came from. Off by default.
+func (e *TachyonEngine) SetWriteSidecar(enabled bool) {
+	e.writeSidecar.Store(enabled)
+}
+
+// GetWriteSidecar reports whether sidecar files are written.
+func (e *TachyonEngine) GetWriteSidecar() bool {
+	return e.writeSidecar.Load()
+}
+
+func sidecarPath(savePath string) string {
+	return savePath + sidecarSuffix
+}
+
+// writeTaskSidecar records the completed task's provenance when enabled.
+// The file is written to a temp name and renamed so readers never see a
+// partial sidecar.
+func (e *TachyonEngine) writeTaskSidecar(task *storage.DownloadTask, completedAt time.Time) {
+	if !e.GetWriteSidecar() || task.SavePath == "" {
+		return
+	}
+
+	// Like the credential headers below, a signed link's query and any
+	// password would leak into a file meant to be kept and shared
+	link := task.URL
+	if u, err := url.Parse(task.URL); err == nil {
+		link = redactURL(u)
+	}
+	sc := Sidecar{
+		Version:       1,
+		URL:           link,
+		Filename:      task.Filename,
+		Size:          task.TotalSize,
+		HashAlgorithm: task.HashAlgorithm,
+		Hash:          task.ExpectedHash,
+		CreatedAt:     task.CreatedAt,
+		CompletedAt:   completedAt.Format(time.RFC3339),
+	}
+	if sc.Hash == "" {
+		hash, err := integrity.CalculateHash(task.SavePath, "sha256")
+		if err != nil {
+			e.logger.Warn("Failed to hash file for sidecar", "id", task.ID, "error", err)
+		} else {
+			sc.HashAlgorithm, sc.Hash = "sha256", hash
+		}
+	}
+	if task.Headers != "" {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(task.Headers), &headers); err == nil {
+			for k, v := range headers {
+				// Credentials would leak into a file meant to be kept and shared
+				if isCredentialHeader(k) {
+					continue
+				}
+				if sc.Headers == nil {
+					sc.Headers = make(map[string]string)
+				}
+				sc.Headers[k] = v
+			}
+		}
+	}
+
+	if err := writeFileAtomic(sidecarPath(task.SavePath), sc); err != nil {
+		e.logger.Warn("Failed to write sidecar", "id", task.ID, "error", err)
+	}
+}
+
+// writeFileAtomic writes v as indented JSON to path via a temp file in the
+// same directory.
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := filesystem.RenameFile(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to place sidecar: %w", err)
+	}
+	return nil
+}
+
+// removeSidecar deletes a download's sidecar, if any.
+func (e *TachyonEngine) removeSidecar(savePath string) {
+	if err := filesystem.RemoveFile(sidecarPath(savePath)); err != nil {
+		e.logger.Warn("Failed to delete sidecar", "path", sidecarPath(savePath), "error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestSidecarWrittenOnCompletion(t *testing.T) {
+	content := generateDummyContent(200 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "report.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+	engine.SetWriteSidecar(true)
+
+	id, err := engine.StartDownload(server.URL+"/report.pdf?sig=s3cr3t", t.TempDir(), "report.pdf", map[string]string{
+		"headers_json": `{"Referer":"https://example.com/reports","Authorization":"Bearer secret","x-api-key":"k1","X-Portal-Session":"s1"}`,
+	})
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+
+	deadline := time.After(10 * time.Second)
+	var task storage.DownloadTask
+	for task.Status != storage.StatusCompleted {
+		task, _ = store.GetTask(id)
+		if task.Status == storage.StatusError {
+			t.Fatal("download failed")
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for download (status %q)", task.Status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	data, err := os.ReadFile(task.SavePath + ".tachyon.json")
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	var sc Sidecar
+	if err := json.Unmarshal(data, &sc); err != nil {
+		t.Fatalf("invalid sidecar JSON: %v", err)
+	}
+
+	sum := sha256.Sum256(content)
+	if want := server.URL + "/report.pdf?sig=[redacted]"; sc.URL != want {
+		t.Errorf("sidecar URL = %q, want %q", sc.URL, want)
+	}
+	if sc.Filename != task.Filename || sc.Size != task.TotalSize {
+		t.Errorf("sidecar does not match task: %+v vs url=%s file=%s size=%d", sc, task.URL, task.Filename, task.TotalSize)
+	}
+	if sc.HashAlgorithm != "sha256" || sc.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected hash %s:%s", sc.HashAlgorithm, sc.Hash)
+	}
+	if sc.CreatedAt != task.CreatedAt || sc.CompletedAt == "" {
+		t.Errorf("unexpected timestamps created=%q completed=%q", sc.CreatedAt, sc.CompletedAt)
+	}
+	if sc.Headers["Referer"] != "https://example.com/reports" {
+		t.Errorf("expected Referer header recorded, got %v", sc.Headers)
+	}
+	for _, name := range []string{"Authorization", "x-api-key", "X-Portal-Session"} {
+		if _, leaked := sc.Headers[name]; leaked {
+			t.Errorf("%s header must not be written to the sidecar", name)
+		}
+	}
+
+	// Leftover temp files would mean the write wasn't atomic
+	if tmps, _ := filepath.Glob(task.SavePath + ".tachyon.json.*.tmp"); len(tmps) != 0 {
+		t.Errorf("temp sidecar files left behind: %v", tmps)
+	}
+
+	if err := engine.DeleteDownload(id, true); err != nil {
+		t.Fatalf("DeleteDownload failed: %v", err)
+	}
+	if _, err := os.Stat(task.SavePath + ".tachyon.json"); !os.IsNotExist(err) {
+		t.Error("sidecar should be deleted with the file")
+	}
+}
+
+func TestSidecarDisabledByDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := newHTTPEngine()
+	e.writeTaskSidecar(&storage.DownloadTask{ID: "t", SavePath: path}, time.Now())
+	if _, err := os.Stat(path + ".tachyon.json"); !os.IsNotExist(err) {
+		t.Error("sidecar written although the option is off")
+	}
+}
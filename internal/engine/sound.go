@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"project-tachyon/internal/platform"
+)
+
+// SoundPreferences control the sounds played when downloads finish. Sounds
+// are off until enabled, so headless and MCP runs stay silent.
+type SoundPreferences struct {
+	Enabled    bool   `json:"enabled"`
+	OnComplete bool   `json:"on_complete"`
+	OnError    bool   `json:"on_error"`
+	QuietStart string `json:"quiet_start"` // "22:00"; empty = no quiet hours
+	QuietEnd   string `json:"quiet_end"`   // "07:00"
+}
+
+// SetSoundPreferences replaces the sound preferences. Quiet hours must be
+// given as HH:MM and may span midnight.
+func (e *TachyonEngine) SetSoundPreferences(p SoundPreferences) error {
+	if (p.QuietStart == "") != (p.QuietEnd == "") {
+		return fmt.Errorf("quiet hours need both a start and an end")
+	}
+	if p.QuietStart != "" {
+		if _, err := time.Parse("15:04", p.QuietStart); err != nil {
+			return fmt.Errorf("invalid quiet hours start %q (want HH:MM)", p.QuietStart)
+		}
+		if _, err := time.Parse("15:04", p.QuietEnd); err != nil {
+			return fmt.Errorf("invalid quiet hours end %q (want HH:MM)", p.QuietEnd)
+		}
+	}
+	e.soundMu.Lock()
+	e.soundPrefs = p
+	e.soundMu.Unlock()
+	return nil
+}
+
+// GetSoundPreferences returns the sound preferences.
+func (e *TachyonEngine) GetSoundPreferences() SoundPreferences {
+	e.soundMu.Lock()
+	defer e.soundMu.Unlock()
+	return e.soundPrefs
+}
+
+// playEventSound plays s in the background if the preferences ask for it,
+// so a slow or missing audio player never holds up the download path.
+func (e *TachyonEngine) playEventSound(s platform.Sound) {
+	p := e.GetSoundPreferences()
+	if !p.Enabled || e.playSound == nil {
+		return
+	}
+	if (s == platform.SoundComplete && !p.OnComplete) || (s == platform.SoundError && !p.OnError) {
+		return
+	}
+	if inQuietHours(time.Now(), p.QuietStart, p.QuietEnd) {
+		return
+	}
+	go func() {
+		if err := e.playSound(s); err != nil {
+			e.logger.Debug("Could not play sound", "sound", s, "error", err)
+		}
+	}()
+}
+
+// inQuietHours reports whether now's time of day falls in [start, end).
+// A window whose end is before its start runs past midnight.
+func inQuietHours(now time.Time, start, end string) bool {
+	if start == "" || end == "" {
+		return false
+	}
+	s, err1 := time.Parse("15:04", start)
+	en, err2 := time.Parse("15:04", end)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	from := s.Hour()*60 + s.Minute()
+	to := en.Hour()*60 + en.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
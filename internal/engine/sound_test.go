@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"project-tachyon/internal/platform"
+	"project-tachyon/internal/storage"
+)
+
+func newSoundTest(t *testing.T) (*TachyonEngine, *storage.Storage, string, chan platform.Sound) {
+	t.Helper()
+	server := spawnContentServer(t, generateDummyContent(64*1024))
+	engine, store := newLoopbackEngine(t)
+	played := make(chan platform.Sound, 4)
+	engine.playSound = func(s platform.Sound) error {
+		played <- s
+		return nil
+	}
+	return engine, store, server.URL + "/file.bin", played
+}
+
+func TestSoundPlayedOnCompletion(t *testing.T) {
+	engine, store, url, played := newSoundTest(t)
+	if err := engine.SetSoundPreferences(SoundPreferences{Enabled: true, OnComplete: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := engine.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+	select {
+	case s := <-played:
+		if s != platform.SoundComplete {
+			t.Errorf("expected %q sound, got %q", platform.SoundComplete, s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("completion sound was not played")
+	}
+}
+
+func TestSoundSilentByDefault(t *testing.T) {
+	engine, store, url, played := newSoundTest(t)
+
+	id, err := engine.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	waitForFinalStatus(t, store, id)
+	select {
+	case s := <-played:
+		t.Errorf("expected no sound with default preferences, got %q", s)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestPlayEventSoundPerEvent(t *testing.T) {
+	engine, _, _, played := newSoundTest(t)
+	engine.SetSoundPreferences(SoundPreferences{Enabled: true, OnError: true})
+
+	engine.playEventSound(platform.SoundComplete)
+	engine.playEventSound(platform.SoundError)
+	select {
+	case s := <-played:
+		if s != platform.SoundError {
+			t.Errorf("expected only the error sound, got %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("error sound was not played")
+	}
+}
+
+func TestSetSoundPreferences_Validation(t *testing.T) {
+	engine, _, _, _ := newSoundTest(t)
+	bad := []SoundPreferences{
+		{QuietStart: "22:00"},
+		{QuietStart: "25:00", QuietEnd: "07:00"},
+		{QuietStart: "22:00", QuietEnd: "7am"},
+	}
+	for _, p := range bad {
+		if err := engine.SetSoundPreferences(p); err == nil {
+			t.Errorf("expected error for %+v", p)
+		}
+	}
+	good := SoundPreferences{Enabled: true, OnComplete: true, QuietStart: "22:00", QuietEnd: "07:00"}
+	if err := engine.SetSoundPreferences(good); err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.GetSoundPreferences(); got != good {
+		t.Errorf("expected %+v, got %+v", good, got)
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 1, h, m, 0, 0, time.Local) }
+	cases := []struct {
+		now        time.Time
+		start, end string
+		want       bool
+	}{
+		{at(23, 0), "", "", false},
+		{at(23, 0), "22:00", "07:00", true},
+		{at(3, 30), "22:00", "07:00", true},
+		{at(7, 0), "22:00", "07:00", false},
+		{at(12, 0), "22:00", "07:00", false},
+		{at(13, 0), "12:30", "14:00", true},
+		{at(14, 0), "12:30", "14:00", false},
+	}
+	for _, c := range cases {
+		if got := inQuietHours(c.now, c.start, c.end); got != c.want {
+			t.Errorf("inQuietHours(%s, %s-%s) = %v, want %v", c.now.Format("15:04"), c.start, c.end, got, c.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"math"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -343,8 +344,9 @@ func TestPauseResumeIntegrity(t *testing.T) {
 	t.Logf("Paused at %.1f%%", pausedProgress)
 
 	// Resume
-	task.Status = "pending"
-	engine.queue.Push(&task)
+	resumed := task
+	resumed.Status = "pending"
+	engine.queue.Push(&resumed)
 
 	deadline := time.After(30 * time.Second)
 	for {
@@ -426,8 +428,9 @@ func TestMultiplePauseResumeCycles(t *testing.T) {
 		t.Logf("Cycle %d: paused at %.1f%%", cycle, task.Progress)
 
 		// Resume
-		task.Status = "pending"
-		engine.queue.Push(&task)
+		resumed := task
+		resumed.Status = "pending"
+		engine.queue.Push(&resumed)
 	}
 
 	// Wait for final completion
@@ -780,6 +783,40 @@ check:
 	}
 }
 
+// TestBandwidthLimitAchieved verifies the rate actually achieved against a
+// fast local server settles near the configured cap.
+func TestBandwidthLimitAchieved(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping bandwidth limit test in short mode")
+	}
+
+	const limit = 8 << 20 // 8MB/s
+	content := make([]byte, 6*limit)
+	rand.Read(content)
+	server := spawnRangeServer(t, content, 0)
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+	engine.SetGlobalLimit(limit)
+
+	started := time.Now()
+	id, err := engine.StartDownload(server.URL, t.TempDir(), "capped.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != "completed" {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+
+	// Reads are up to BufferSize each, so progress moves in large steps;
+	// the whole download's duration is the steadier measure.
+	rate := float64(len(content)) / time.Since(started).Seconds()
+	t.Logf("achieved %.0f B/s against a %d B/s cap", rate, limit)
+	if math.Abs(rate-limit)/limit > 0.1 {
+		t.Errorf("achieved %.0f B/s, want within 10%% of %d", rate, limit)
+	}
+}
+
 // TestHTTP403HandledGracefully verifies link-expired handling
 func TestHTTP403HandledGracefully(t *testing.T) {
 	if testing.Short() {
@@ -94,8 +94,8 @@ func CompletedPartsToBitfield(completedParts map[int]bool, numParts int) []byte
 	numBytes := (numParts + 7) / 8
 	bitfield := make([]byte, numBytes)
 
-	for partID := range completedParts {
-		if partID >= 0 && partID < numParts {
+	for partID, done := range completedParts {
+		if done && partID >= 0 && partID < numParts {
 			byteIdx := partID / 8
 			bitIdx := uint(partID % 8)
 			bitfield[byteIdx] |= (1 << bitIdx)
@@ -105,6 +105,19 @@ func CompletedPartsToBitfield(completedParts map[int]bool, numParts int) []byte
 	return bitfield
 }
 
+// countDoneParts returns how many of the original parts (0..numParts-1)
+// are marked complete. Stolen parts (id >= numParts) carry data for an
+// original part and never count on their own.
+func countDoneParts(completedParts map[int]bool, numParts int) int {
+	n := 0
+	for id, done := range completedParts {
+		if done && id >= 0 && id < numParts {
+			n++
+		}
+	}
+	return n
+}
+
 // BitfieldToCompletedParts converts a []byte bitmap back to map[int]bool
 // Only includes parts that are marked as complete (bit set to 1)
 func BitfieldToCompletedParts(bitfield []byte, numParts int) map[int]bool {
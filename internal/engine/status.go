@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"project-tachyon/internal/storage"
+)
+
+// ErrInvalidTransition is returned when a task is asked to move to a status
+// its current status cannot reach, e.g. resuming a completed download.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// statusTransitions lists the statuses each status may move to. Staying in
+// the same status is always allowed; completed and partial are terminal.
+var statusTransitions = map[storage.Status][]storage.Status{
+	"": {storage.StatusPending, storage.StatusScheduled},
+	storage.StatusPending: {
+		storage.StatusScheduled, storage.StatusProbing, storage.StatusPaused,
+		storage.StatusStopped, storage.StatusError,
+	},
+	storage.StatusScheduled: {
+		storage.StatusPending, storage.StatusProbing, storage.StatusPaused,
+		storage.StatusStopped, storage.StatusError,
+	},
+	storage.StatusProbing: {
+		storage.StatusDownloading, storage.StatusPending, storage.StatusPaused,
+		storage.StatusStopped, storage.StatusError, storage.StatusNeedsAuth,
+	},
+	storage.StatusDownloading: {
+		storage.StatusMerging, storage.StatusCompleted, storage.StatusPending,
+		storage.StatusPaused, storage.StatusStopped, storage.StatusError,
+		storage.StatusNeedsAuth,
+	},
+	storage.StatusMerging: {
+		storage.StatusVerifying, storage.StatusCompleted, storage.StatusPartial,
+		storage.StatusPaused, storage.StatusStopped, storage.StatusError,
+	},
+	storage.StatusVerifying: {
+		storage.StatusCompleted, storage.StatusPending, storage.StatusError,
+	},
+	storage.StatusPaused: {
+		storage.StatusPending, storage.StatusStopped, storage.StatusError,
+	},
+	storage.StatusStopped: {storage.StatusPending},
+	storage.StatusError: {
+		storage.StatusPending, storage.StatusPaused, storage.StatusStopped,
+	},
+	storage.StatusNeedsAuth: {
+		storage.StatusPending, storage.StatusPaused, storage.StatusStopped,
+		storage.StatusError,
+	},
+}
+
+// canTransition reports whether a task in status from may move to status to.
+func canTransition(from, to storage.Status) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// setStatus moves task to status to, refusing moves the state machine does
+// not allow. The caller saves the task.
+func setStatus(task *storage.DownloadTask, to storage.Status) error {
+	if !canTransition(task.Status, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, task.Status, to)
+	}
+	task.Status = to
+	return nil
+}
+
+// savePaused marks the stored task paused through setStatus, applying
+// mutate in the same transaction, and mirrors the new status on task. If
+// the stored task has meanwhile moved where paused can't follow, such as
+// stopped, it keeps that status and savePaused reports false; mutate is
+// applied either way.
+func (e *TachyonEngine) savePaused(task *storage.DownloadTask, mutate func(t *storage.DownloadTask)) bool {
+	var statusErr error
+	stored := task.Status
+	err := e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+		statusErr = setStatus(t, storage.StatusPaused)
+		mutate(t)
+		stored = t.Status
+	})
+	if err != nil {
+		e.logger.Error("Failed to save paused download", "id", task.ID, "error", err)
+		return false
+	}
+	task.Status = stored
+	if statusErr != nil {
+		e.logger.Warn("Download not marked paused", "id", task.ID, "error", statusErr)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to storage.Status
+		want     bool
+	}{
+		{"", storage.StatusPending, true},
+		{storage.StatusPending, storage.StatusProbing, true},
+		{storage.StatusScheduled, storage.StatusProbing, true},
+		{storage.StatusProbing, storage.StatusDownloading, true},
+		{storage.StatusDownloading, storage.StatusMerging, true},
+		{storage.StatusMerging, storage.StatusVerifying, true},
+		{storage.StatusVerifying, storage.StatusCompleted, true},
+		{storage.StatusMerging, storage.StatusPartial, true},
+		{storage.StatusDownloading, storage.StatusNeedsAuth, true},
+		{storage.StatusNeedsAuth, storage.StatusPaused, true},
+		{storage.StatusPaused, storage.StatusPending, true},
+		{storage.StatusStopped, storage.StatusPending, true},
+		{storage.StatusError, storage.StatusPending, true},
+		{storage.StatusPaused, storage.StatusPaused, true},
+
+		{storage.StatusCompleted, storage.StatusDownloading, false},
+		{storage.StatusCompleted, storage.StatusPending, false},
+		{storage.StatusCompleted, storage.StatusStopped, false},
+		{storage.StatusPartial, storage.StatusPending, false},
+		{storage.StatusPending, storage.StatusCompleted, false},
+		{storage.StatusPaused, storage.StatusDownloading, false},
+		{storage.StatusStopped, storage.StatusPaused, false},
+		{storage.StatusVerifying, storage.StatusPending, true}, // re-download after a failed check
+		{"", storage.StatusDownloading, false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestSetStatus_RejectsIllegalMove(t *testing.T) {
+	task := storage.DownloadTask{ID: "t1", Status: storage.StatusCompleted}
+	err := setStatus(&task, storage.StatusPending)
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	if task.Status != storage.StatusCompleted {
+		t.Errorf("status changed to %q on a rejected move", task.Status)
+	}
+
+	task.Status = storage.StatusPaused
+	if err := setStatus(&task, storage.StatusPending); err != nil {
+		t.Fatalf("paused -> pending should be allowed: %v", err)
+	}
+	if task.Status != storage.StatusPending {
+		t.Errorf("Status = %q, want pending", task.Status)
+	}
+}
+
+func TestStopDownload_CompletedIsRejected(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	s.SaveTask(storage.DownloadTask{
+		ID:       "done",
+		URL:      "http://example.com/file.zip",
+		Filename: "file.zip",
+		Status:   storage.StatusCompleted,
+	})
+
+	if err := e.StopDownload("done"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	task, _ := s.GetTask("done")
+	if task.Status != storage.StatusCompleted {
+		t.Errorf("Status = %q, want completed", task.Status)
+	}
+}
+
+func TestSavePaused_KeepsStoppedStatus(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	// The executor still thinks it is downloading; the user stopped it
+	// while it wound down
+	s.SaveTask(storage.DownloadTask{ID: "stopped", Status: storage.StatusStopped})
+	task := storage.DownloadTask{ID: "stopped", Status: storage.StatusDownloading}
+	if e.savePaused(&task, func(t *storage.DownloadTask) { t.Downloaded = 42 }) {
+		t.Error("a stopped download was reported paused")
+	}
+	stored, _ := s.GetTask("stopped")
+	if stored.Status != storage.StatusStopped || task.Status != storage.StatusStopped {
+		t.Errorf("status = %q (task %q), want stopped", stored.Status, task.Status)
+	}
+	if stored.Downloaded != 42 {
+		t.Errorf("progress not saved: downloaded = %d", stored.Downloaded)
+	}
+
+	s.SaveTask(storage.DownloadTask{ID: "running", Status: storage.StatusDownloading})
+	task = storage.DownloadTask{ID: "running", Status: storage.StatusDownloading}
+	if !e.savePaused(&task, func(*storage.DownloadTask) {}) {
+		t.Error("a running download was not paused")
+	}
+	if stored, _ := s.GetTask("running"); stored.Status != storage.StatusPaused || task.Status != storage.StatusPaused {
+		t.Errorf("status = %q (task %q), want paused", stored.Status, task.Status)
+	}
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// EngineStatus is a point-in-time summary of the engine for health checks
+// and monitoring.
+type EngineStatus struct {
+	Uptime       float64 `json:"uptime_seconds"`
+	Active       int     `json:"active"`        // downloads currently transferring
+	Queued       int     `json:"queued"`        // waiting for a slot
+	Completed    int     `json:"completed"`     // finished downloads in history
+	Speed        int64   `json:"speed"`         // bytes/sec summed over active downloads
+	SessionBytes int64   `json:"session_bytes"` // bytes received since startup
+	SpeedLimit   int     `json:"speed_limit"`   // bytes/sec, 0 = unlimited
+}
+
+// Status returns the engine's current counters.
+func (e *TachyonEngine) Status() EngineStatus {
+	st := EngineStatus{
+		Uptime:       time.Since(e.startedAt).Seconds(),
+		SessionBytes: e.sessionBytes.Load(),
+		SpeedLimit:   e.bandwidthManager.Limit(),
+	}
+	e.activeDownloads.Range(func(_, value interface{}) bool {
+		st.Active++
+		if info, ok := value.(*activeDownloadInfo); ok {
+			st.Speed += info.Speed.Load()
+		}
+		return true
+	})
+	if n, err := e.storage.CountTasksByStatus(queuedStatuses); err == nil {
+		st.Queued = max(int(n)-st.Active, 0)
+	}
+	if n, err := e.storage.CountTasksByStatus([]storage.Status{storage.StatusCompleted}); err == nil {
+		st.Completed = int(n)
+	}
+	return st
+}
+
+// Ready reports whether the engine can take work, returning the reason it
+// can't: no storage, or a database that doesn't answer within ctx.
+func (e *TachyonEngine) Ready(ctx context.Context) error {
+	if e == nil || e.storage == nil || e.queue == nil {
+		return errors.New("engine not initialized")
+	}
+	return e.storage.Ping(ctx)
+}
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+func TestStatusCountsSessionBytes(t *testing.T) {
+	content := generateDummyContent(256 * 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	engine, store := newLoopbackEngine(t)
+
+	if st := engine.Status(); st.SessionBytes != 0 || st.Completed != 0 || st.Active != 0 {
+		t.Fatalf("expected empty status on a fresh engine, got %+v", st)
+	}
+
+	id, err := engine.StartDownload(server.URL+"/data.bin", t.TempDir(), "data.bin", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+
+	st := engine.Status()
+	if st.SessionBytes < int64(len(content)) {
+		t.Errorf("session bytes = %d, want at least %d", st.SessionBytes, len(content))
+	}
+	if st.Completed != 1 {
+		t.Errorf("completed = %d, want 1", st.Completed)
+	}
+	if st.Uptime <= 0 {
+		t.Errorf("uptime = %v, want > 0", st.Uptime)
+	}
+}
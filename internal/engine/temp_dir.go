@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// SetTempDownloadDir sets where in-progress part files are kept, e.g. a fast
+// local disk while the final files land on a NAS. Completed downloads are
+// moved to their SavePath. An empty dir restores the default of a hidden
+// folder beside each download.
+func (e *TachyonEngine) SetTempDownloadDir(dir string) error {
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("invalid temp directory: %w", err)
+		}
+		if err := checkWritable(abs); err != nil {
+			return fmt.Errorf("temp directory is not writable: %w", err)
+		}
+		dir = abs
+	}
+	e.tempDirMu.Lock()
+	e.tempDownloadDir = dir
+	e.tempDirMu.Unlock()
+	return nil
+}
+
+// GetTempDownloadDir returns the configured temp directory ("" = default).
+func (e *TachyonEngine) GetTempDownloadDir() string {
+	e.tempDirMu.RLock()
+	defer e.tempDirMu.RUnlock()
+	return e.tempDownloadDir
+}
+
+// partsDirForTask returns the directory holding a task's part files. Parts
+// already on disk beside the download win over the configured temp dir, so
+// changing the setting doesn't orphan a paused download.
+func (e *TachyonEngine) partsDirForTask(taskID, savePath string) string {
+	local := tempDirForTask(savePath)
+	root := e.GetTempDownloadDir()
+	if root == "" {
+		return local
+	}
+	custom := filepath.Join(root, ".tachyon_parts")
+	if !hasPartFiles(custom, taskID) && hasPartFiles(local, taskID) {
+		return local
+	}
+	return custom
+}
+
+func hasPartFiles(dir, taskID string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, taskID+".part.*"))
+	return len(matches) > 0
+}
+
+// checkTempAndFinalSpace verifies both the parts directory and the final
+// location can take the download when they differ. The parts are merged
+// into a staging copy beside them (see assembleParts), so the parts
+// directory must also hold the whole file once more. When it shares a
+// volume with the final location, the staging copy is then renamed into
+// place, so that covers the final location too.
+func (e *TachyonEngine) checkTempAndFinalSpace(partsDir string, task *storage.DownloadTask, remaining int64) error {
+	if err := e.diskSpaceCheck(filepath.Join(partsDir, task.ID), remaining+task.TotalSize); err != nil {
+		return fmt.Errorf("temp directory: %w", err)
+	}
+	if err := e.diskSpaceCheck(task.SavePath, task.TotalSize); err != nil {
+		return fmt.Errorf("download location: %w", err)
+	}
+	return nil
+}
+
+// assembleParts merges a task's parts into its SavePath. Parts kept outside
+// the download folder are merged next to themselves first and then moved,
+// falling back to a copy when the two are on different volumes.
+func (e *TachyonEngine) assembleParts(partsDir string, task *storage.DownloadTask) error {
+	if partsDir == tempDirForTask(task.SavePath) {
+		return mergePartFiles(partsDir, task.ID, task.SavePath)
+	}
+
+	staging := filepath.Join(partsDir, task.ID+".merging")
+	if err := mergePartFiles(partsDir, task.ID, staging); err != nil {
+		os.Remove(staging)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(task.SavePath), 0755); err != nil {
+		os.Remove(staging)
+		return fmt.Errorf("failed to create destination folder: %w", err)
+	}
+	if err := filesystem.MoveFile(staging, task.SavePath); err != nil {
+		os.Remove(staging)
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// minThroughputStage is the shortest time spent measuring one
+	// concurrency level; shorter samples are dominated by TCP ramp-up.
+	minThroughputStage = 500 * time.Millisecond
+
+	// throughputGain is how much faster a level must be than the best so far
+	// for the test to keep doubling the connection count.
+	throughputGain = 1.10
+
+	maxThroughputSeconds = 60
+)
+
+// ThroughputLevel is the rate measured at one connection count.
+type ThroughputLevel struct {
+	Connections int     `json:"connections"`
+	Mbps        float64 `json:"mbps"`
+	Bytes       int64   `json:"bytes"`
+}
+
+// HostThroughputResult reports a bandwidth test against a download server.
+type HostThroughputResult struct {
+	Host               string            `json:"host"`
+	Mbps               float64           `json:"mbps"`                // best rate achieved
+	OptimalConcurrency int               `json:"optimal_concurrency"` // connections at that rate
+	AcceptRanges       bool              `json:"accept_ranges"`
+	Levels             []ThroughputLevel `json:"levels"`
+}
+
+// TestHostThroughput measures throughput to the server hosting urlStr for
+// roughly seconds, using the engine's own HTTP client and request headers.
+// Connection counts are doubled from 1 until the rate stops improving; the
+// best count seeds the congestion controller so the next download from that
+// host starts there instead of slow-starting. Nothing is written to disk.
+func (e *TachyonEngine) TestHostThroughput(urlStr string, seconds int) (*HostThroughputResult, error) {
+	var err error
+	if e.allowLoopback {
+		err = ValidateURLAllowLoopback(urlStr)
+	} else {
+		err = ValidateURL(urlStr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if seconds < 1 {
+		seconds = 1
+	}
+	if seconds > maxThroughputSeconds {
+		seconds = maxThroughputSeconds
+	}
+
+	probe, err := e.ProbeURL(urlStr, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("probe failed: %w", err)
+	}
+	u, _ := url.Parse(urlStr)
+	host := u.Hostname()
+	ranged := probe.AcceptRanges && probe.Size > 0
+
+	maxConns := e.GetMaxConnectionsPerDownload()
+	if !ranged {
+		maxConns = 1
+	}
+	var levels []int
+	for n := 1; n <= maxConns; n *= 2 {
+		levels = append(levels, n)
+	}
+	total := time.Duration(seconds) * time.Second
+	stage := total / time.Duration(len(levels))
+	if stage < minThroughputStage {
+		stage = minThroughputStage
+		if fit := int(total / stage); fit < len(levels) {
+			levels = levels[:max(fit, 1)]
+		}
+	}
+
+	parent := e.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	result := &HostThroughputResult{Host: host, AcceptRanges: ranged, OptimalConcurrency: 1}
+	for _, conns := range levels {
+		bytes, elapsed := e.measureThroughput(parent, urlStr, probe.Size, ranged, conns, stage)
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
+		mbps := float64(bytes) * 8 / 1e6 / elapsed.Seconds()
+		result.Levels = append(result.Levels, ThroughputLevel{Connections: conns, Mbps: mbps, Bytes: bytes})
+		e.logger.Info("Throughput test stage", "host", host, "connections", conns, "mbps", fmt.Sprintf("%.1f", mbps))
+
+		if mbps > result.Mbps*throughputGain || result.Mbps == 0 {
+			result.Mbps = mbps
+			result.OptimalConcurrency = conns
+			continue
+		}
+		if mbps > result.Mbps {
+			result.Mbps = mbps
+			result.OptimalConcurrency = conns
+		}
+		break // no longer scaling
+	}
+
+	if result.Mbps == 0 {
+		return nil, fmt.Errorf("no data received from %s", host)
+	}
+	if ranged {
+		e.congestion.SeedConcurrency(host, result.OptimalConcurrency)
+	}
+	return result, nil
+}
+
+// measureThroughput downloads from urlStr over conns connections for d and
+// returns the bytes read and the time taken. Ranged requests walk the file
+// in DownloadChunkSize windows, wrapping around at the end.
+func (e *TachyonEngine) measureThroughput(parent context.Context, urlStr string, size int64, ranged bool, conns int, d time.Duration) (int64, time.Duration) {
+	ctx, cancel := context.WithTimeout(parent, d)
+	defer cancel()
+
+	var total atomic.Int64
+	var cursor atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bufPtr := e.bufferPool.Get().(*[]byte)
+			defer e.bufferPool.Put(bufPtr)
+
+			for ctx.Err() == nil {
+				req, err := e.newRequest("GET", urlStr, "", "")
+				if err != nil {
+					return
+				}
+				req = req.WithContext(ctx)
+				if ranged {
+					from := (cursor.Add(DownloadChunkSize) - DownloadChunkSize) % size
+					to := min(from+DownloadChunkSize, size) - 1
+					req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+				}
+				resp, err := e.httpClient.Do(req)
+				if err != nil {
+					return
+				}
+				if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+					resp.Body.Close()
+					return
+				}
+				n, _ := io.CopyBuffer(io.Discard, resp.Body, *bufPtr)
+				resp.Body.Close()
+				total.Add(n)
+			}
+		}()
+	}
+	wg.Wait()
+	return total.Load(), time.Since(start)
+}
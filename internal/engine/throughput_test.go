@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// throttledServer serves size bytes with range support, limiting each
+// response to roughly bytesPerSec so more connections mean more throughput.
+func throttledServer(size int64, bytesPerSec int) *httptest.Server {
+	const slice = 16 * 1024
+	pause := time.Duration(float64(time.Second) * slice / float64(bytesPerSec))
+	chunk := make([]byte, slice)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end := int64(0), size-1
+		if rng := r.Header.Get("Range"); rng != "" {
+			var s, e int64
+			if n, _ := fmt.Sscanf(rng, "bytes=%d-%d", &s, &e); n == 2 {
+				start, end = s, min(e, size-1)
+			}
+			w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(size, 10))
+			w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			w.Header().Set("Accept-Ranges", "bytes")
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		for remaining := end - start + 1; remaining > 0; {
+			n := min(remaining, int64(slice))
+			if _, err := w.Write(chunk[:n]); err != nil {
+				return
+			}
+			remaining -= n
+			time.Sleep(pause)
+		}
+	}))
+}
+
+func TestHostThroughput_MeasuresLocalServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	// ~2 MB/s (16 Mbps) per connection
+	const perConn = 2 * 1024 * 1024
+	server := throttledServer(64*1024*1024, perConn)
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := NewEngine(logger, createTestDB(t))
+	e.allowLoopback = true
+	e.SetDownloadTuning(4, 0)
+
+	res, err := e.TestHostThroughput(server.URL+"/big.bin", 2)
+	if err != nil {
+		t.Fatalf("TestHostThroughput failed: %v", err)
+	}
+	if !res.AcceptRanges || res.Host != "127.0.0.1" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(res.Levels) == 0 || res.Levels[0].Connections != 1 {
+		t.Fatalf("expected stages starting at one connection, got %+v", res.Levels)
+	}
+
+	// One throttled connection: about 16 Mbps
+	single := res.Levels[0].Mbps
+	wantMbps := float64(perConn) * 8 / 1e6
+	if single < wantMbps*0.5 || single > wantMbps*1.5 {
+		t.Errorf("single-connection rate %.1f Mbps, want about %.1f", single, wantMbps)
+	}
+	if res.OptimalConcurrency < 2 {
+		t.Errorf("optimal concurrency = %d, want more than one connection on a per-connection throttle", res.OptimalConcurrency)
+	}
+	if res.Mbps < single {
+		t.Errorf("best rate %.1f below single-connection rate %.1f", res.Mbps, single)
+	}
+
+	stats := e.congestion.GetHostStats("127.0.0.1")
+	if stats == nil || stats.Concurrency != res.OptimalConcurrency {
+		t.Errorf("congestion controller not seeded with %d: %+v", res.OptimalConcurrency, stats)
+	}
+}
+
+func TestHostThroughput_RejectsInvalidURL(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := NewEngine(logger, createTestDB(t))
+	if _, err := e.TestHostThroughput("ftp://example.com/file", 1); err == nil {
+		t.Fatal("expected an error for a non-HTTP URL")
+	}
+}
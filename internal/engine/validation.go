@@ -4,11 +4,31 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"unicode/utf8"
 )
 
 const maxURLLength = 8192
 
+// Filename limits. Lengths are counted in UTF-8 bytes, which is never less
+// than the UTF-16 units Windows counts, so the limits hold on every OS.
+const (
+	maxFilenameBytes     = 255 // NTFS, ext4, APFS
+	collisionSuffixBytes = 6   // room for the " (n)" FindAvailablePath may add
+	maxExtensionBytes    = 16  // longer suffixes are part of the name
+	minFilenameBytes     = 16  // what a name keeps when its folder is too deep
+)
+
+// maxPathBytes is the longest full path that is safe to create: MAX_PATH
+// on Windows, which many tools still enforce, and PATH_MAX elsewhere.
+var maxPathBytes = func() int {
+	if runtime.GOOS == "windows" {
+		return 259
+	}
+	return 4095
+}()
+
 // dangerousHeaders that must not be set via user input
 var dangerousHeaders = map[string]bool{
 	"host":                true,
@@ -120,13 +140,47 @@ func SanitizeFilename(name string) string {
 		return "download"
 	}
 
-	// Enforce a reasonable filename length (255 bytes is the OS limit)
-	if len(cleaned) > 200 {
-		ext := filepath.Ext(cleaned)
-		cleaned = cleaned[:200-len(ext)] + ext
+	return truncateFilename(cleaned, maxFilenameBytes-collisionSuffixBytes)
+}
+
+// fitPath shortens the file name in path so the whole path, plus a
+// collision suffix, stays within the filesystem's limits.
+func fitPath(path string) string {
+	dir, name := filepath.Split(path)
+	budget := maxFilenameBytes - collisionSuffixBytes
+	if room := maxPathBytes - len(path) + len(name) - collisionSuffixBytes; room < budget {
+		budget = max(room, minFilenameBytes)
+	}
+	if len(name) <= budget {
+		return path
 	}
+	return filepath.Join(dir, truncateFilename(name, budget))
+}
 
-	return cleaned
+// truncateFilename cuts name down to maxBytes, keeping its extension
+// (".tar.gz" as a whole) and never splitting a UTF-8 character.
+func truncateFilename(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if inner := filepath.Ext(strings.TrimSuffix(name, ext)); strings.EqualFold(inner, ".tar") {
+		ext = inner + ext
+	}
+	if len(ext) > maxExtensionBytes || len(ext) >= maxBytes {
+		ext = ""
+	}
+	stem := name[:len(name)-len(ext)]
+	cut := maxBytes - len(ext)
+	for cut > 0 && !utf8.RuneStart(stem[cut]) {
+		cut--
+	}
+	// Windows drops trailing dots and spaces, which would change the name
+	stem = strings.TrimRight(stem[:cut], ". ")
+	if stem == "" {
+		stem = "download"
+	}
+	return stem + ext
 }
 
 // ValidateHeaderKey checks that a custom header key is safe to apply.
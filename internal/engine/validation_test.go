@@ -1,8 +1,13 @@
 package engine
 
 import (
+	"io"
+	"log/slog"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func TestValidateURL(t *testing.T) {
@@ -74,6 +79,88 @@ func TestSanitizeFilename(t *testing.T) {
 	}
 }
 
+func TestSanitizeFilename_TruncatesOverlongNames(t *testing.T) {
+	long := strings.Repeat("a", 400) + ".mkv"
+	got := SanitizeFilename(long)
+	if len(got) > maxFilenameBytes-collisionSuffixBytes {
+		t.Errorf("len = %d, want at most %d", len(got), maxFilenameBytes-collisionSuffixBytes)
+	}
+	if !strings.HasSuffix(got, ".mkv") {
+		t.Errorf("extension lost: %q", got)
+	}
+}
+
+func TestTruncateFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxBytes int
+		want     string
+	}{
+		{"fits", "short.zip", 20, "short.zip"},
+		{"keeps extension", "abcdefghij.zip", 10, "abcdef.zip"},
+		{"keeps tar.gz", "abcdefghij.tar.gz", 12, "abcde.tar.gz"},
+		{"whole runes only", "ééééé.txt", 9, "éé.txt"},
+		{"overlong extension is name", "report." + strings.Repeat("x", 30), 10, "report.xxx"},
+		{"trailing dots and spaces", "abc . . def.pdf", 10, "abc.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateFilename(tt.input, tt.maxBytes)
+			if got != tt.want {
+				t.Errorf("truncateFilename(%q, %d) = %q, want %q", tt.input, tt.maxBytes, got, tt.want)
+			}
+			if len(got) > tt.maxBytes || !utf8.ValidString(got) {
+				t.Errorf("result %q is %d bytes or not valid UTF-8", got, len(got))
+			}
+		})
+	}
+}
+
+func TestFitPath_FitsWithinPathLimit(t *testing.T) {
+	defer func(old int) { maxPathBytes = old }(maxPathBytes)
+	maxPathBytes = 259 // Windows MAX_PATH
+
+	dir := filepath.Join(string(filepath.Separator)+"downloads", strings.Repeat("deep", 40))
+	got := fitPath(filepath.Join(dir, strings.Repeat("n", 300)+".iso"))
+	if filepath.Dir(got) != dir {
+		t.Errorf("directory changed: %q", filepath.Dir(got))
+	}
+	if !strings.HasSuffix(got, ".iso") {
+		t.Errorf("extension lost: %q", got)
+	}
+	if len(got)+collisionSuffixBytes > maxPathBytes {
+		t.Errorf("path is %d bytes, want at most %d with room for a suffix", len(got), maxPathBytes-collisionSuffixBytes)
+	}
+
+	short := filepath.Join(dir, "file.iso")
+	if got := fitPath(short); got != short {
+		t.Errorf("fitPath(%q) = %q, want it unchanged", short, got)
+	}
+}
+
+func TestStartDownload_TruncatesOverlongFilename(t *testing.T) {
+	e := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), createTempDB(t))
+	defer e.Shutdown()
+
+	name := strings.Repeat("é", 200) + ".tar.gz" // 407 bytes
+	later := map[string]string{"start_time": time.Now().Add(time.Hour).Format(time.RFC3339)}
+	id, err := e.StartDownload("https://example.com/file", t.TempDir(), name, later)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task, err := e.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(task.Filename, ".tar.gz") || len(task.Filename) > maxFilenameBytes {
+		t.Errorf("Filename = %q (%d bytes)", task.Filename, len(task.Filename))
+	}
+	if len(task.SavePath) > maxPathBytes {
+		t.Errorf("SavePath is %d bytes, want at most %d", len(task.SavePath), maxPathBytes)
+	}
+}
+
 func TestValidateHeaderKey(t *testing.T) {
 	tests := []struct {
 		key     string
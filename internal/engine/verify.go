@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"project-tachyon/internal/filesystem"
+	"project-tachyon/internal/storage"
+)
+
+// DefaultMaxVerifications is how many finished downloads are verified and
+// scanned at once. Hashing reads the whole file, so a few at a time keeps
+// the disk from thrashing while still overlapping large files.
+const DefaultMaxVerifications = 2
+
+// SetMaxVerifications sets how many finished downloads may be verified at
+// once (values below 1 restore the default). Verifications already waiting
+// pick up the new limit straight away.
+func (e *TachyonEngine) SetMaxVerifications(n int) {
+	if n < 1 {
+		n = DefaultMaxVerifications
+	}
+	e.verifyMu.Lock()
+	e.maxVerifications = n
+	e.verifyCond.Broadcast()
+	e.verifyMu.Unlock()
+}
+
+// GetMaxVerifications returns the verification concurrency limit.
+func (e *TachyonEngine) GetMaxVerifications() int {
+	e.verifyMu.Lock()
+	defer e.verifyMu.Unlock()
+	return e.maxVerifications
+}
+
+// MaxVerifyRetries caps SetVerifyRetries.
+const MaxVerifyRetries = 5
+
+// SetVerifyRetries sets how many times a download that fails its integrity
+// check is discarded and downloaded again before the file is quarantined
+// (0, the default, quarantines it straight away). Corruption often comes
+// from one bad CDN edge, and a fresh connection may reach a good one.
+// Values are clamped to 0..MaxVerifyRetries.
+func (e *TachyonEngine) SetVerifyRetries(n int) {
+	n = max(0, min(n, MaxVerifyRetries))
+	e.verifyRetries.Store(int32(n))
+}
+
+// GetVerifyRetries returns how many re-downloads a failed integrity check
+// gets.
+func (e *TachyonEngine) GetVerifyRetries() int {
+	return int(e.verifyRetries.Load())
+}
+
+// SetHoldQueueWhileVerifying stops new downloads from being dispatched
+// while any finished download is being verified or scanned, so that work
+// does not compete with fresh transfers for disk and CPU. Dispatch resumes
+// once the last one is done.
+func (e *TachyonEngine) SetHoldQueueWhileVerifying(enabled bool) {
+	e.holdWhileVerifying.Store(enabled)
+	e.queue.Broadcast()
+}
+
+// GetHoldQueueWhileVerifying reports whether dispatch waits for
+// verification and scanning to finish.
+func (e *TachyonEngine) GetHoldQueueWhileVerifying() bool {
+	return e.holdWhileVerifying.Load()
+}
+
+// beginPostProcessing counts a download as verifying or scanning until the
+// returned func is called.
+func (e *TachyonEngine) beginPostProcessing() func() {
+	e.postProcessing.Add(1)
+	return func() {
+		if e.postProcessing.Add(-1) == 0 {
+			e.queue.Broadcast()
+		}
+	}
+}
+
+// dispatchHeld reports whether the queue worker should start nothing new
+// because of HoldDispatch or SetHoldQueueWhileVerifying.
+func (e *TachyonEngine) dispatchHeld() bool {
+	if e.dispatchHold.Load() {
+		return true
+	}
+	return e.holdWhileVerifying.Load() && e.postProcessing.Load() > 0
+}
+
+// verifyAndComplete hands a merged download to the verification pool and
+// returns at once, so the download's slot is freed for the next queued
+// task instead of being held while a large file is hashed. The pool checks
+// the file's hash, then completes the task (scan, stats, events). The task
+// stays "verifying" until then; if the engine shuts down first it is
+// picked up again by RecoverInterruptedDownloads.
+func (e *TachyonEngine) verifyAndComplete(task *storage.DownloadTask, info *activeDownloadInfo, startedAt time.Time, lastModified string) {
+	// The executor and scheduler keep using their task after this returns;
+	// the pool works on its own copy.
+	job := *task
+	task = &job
+
+	e.verifyMu.Lock()
+	e.verifyPending++
+	e.verifyMu.Unlock()
+	// Counted before the download's slot is freed, so a held queue never
+	// sees the gap.
+	done := e.beginPostProcessing()
+
+	go func() {
+		defer done()
+		defer func() {
+			e.verifyMu.Lock()
+			e.verifyPending--
+			e.verifyMu.Unlock()
+		}()
+		if !e.acquireVerifySlot() {
+			e.logger.Info("Verification left for next start", "id", task.ID)
+			return
+		}
+		defer e.releaseVerifySlot()
+
+		if err := e.verifyDownload(task); err != nil {
+			if e.retryVerification(task, err) {
+				return
+			}
+			e.failTask(task, fmt.Sprintf("Integrity Check Failed: %v", err))
+			corruptedPath := task.SavePath + ".corrupted"
+			if err := filesystem.RenameFile(task.SavePath, corruptedPath); err != nil {
+				e.logger.Warn("Failed to quarantine corrupted file", "path", task.SavePath, "error", err)
+			}
+			return
+		}
+		e.forgetVerifyRetries(task.ID)
+		e.applyLastModified(task, lastModified)
+
+		// The download's own context ended with the transfer; the scan in
+		// completeTask only stops for shutdown.
+		ctx := e.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		e.completeTask(ctx, task, info, startedAt)
+	}()
+}
+
+// verifyDownload checks task's file against its expected hash, unless it
+// has none or integrity checks are turned off.
+func (e *TachyonEngine) verifyDownload(task *storage.DownloadTask) error {
+	if task.ExpectedHash == "" {
+		return nil
+	}
+	if s, err := e.storage.GetString("enable_integrity_check"); err == nil && s == "false" {
+		return nil
+	}
+	e.logger.Info("Verifying integrity", "id", task.ID, "hash", task.ExpectedHash)
+	return e.verifyFile(task.SavePath, task.HashAlgorithm, task.ExpectedHash)
+}
+
+// retryVerification discards a download that failed its integrity check
+// and queues it to be downloaded again from scratch, unless it has used up
+// its retries (then the attempt count is forgotten and it returns false).
+func (e *TachyonEngine) retryVerification(task *storage.DownloadTask, verifyErr error) bool {
+	limit := e.GetVerifyRetries()
+	e.verifyMu.Lock()
+	attempt := e.verifyRetried[task.ID] + 1
+	if attempt > limit || e.shuttingDown.Load() {
+		delete(e.verifyRetried, task.ID)
+		e.verifyMu.Unlock()
+		return false
+	}
+	e.verifyRetried[task.ID] = attempt
+	e.verifyMu.Unlock()
+
+	e.logger.Warn("Integrity check failed, downloading again", "id", task.ID, "attempt", attempt, "of", limit, "error", verifyErr)
+	e.recordEvent(EventIntegrityFailed, task.ID, "Check failed, downloading again (%d of %d): %v", attempt, limit, verifyErr)
+
+	if err := os.Remove(task.SavePath); err != nil && !os.IsNotExist(err) {
+		e.logger.Warn("Failed to remove corrupted file", "path", task.SavePath, "error", err)
+	}
+	cleanupPartFiles(e.partsDirForTask(task.ID, task.SavePath), task.ID)
+	task.Downloaded = 0
+	task.Progress = 0
+	task.MetaJSON = ""
+	if err := setStatus(task, storage.StatusPending); err != nil {
+		e.logger.Error("Cannot requeue download for retry", "id", task.ID, "error", err)
+		return false
+	}
+	task.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := e.storage.SaveTask(*task); err != nil {
+		e.logger.Error("Failed to save download for retry", "id", task.ID, "error", err)
+	}
+	e.emit("download:progress", map[string]interface{}{
+		"id":         task.ID,
+		"status":     string(storage.StatusPending),
+		"filename":   task.Filename,
+		"downloaded": int64(0),
+		"progress":   0.0,
+		"total":      task.TotalSize,
+	})
+	// Last: once queued, the task belongs to its next run.
+	e.queue.Push(task)
+	return true
+}
+
+// forgetVerifyRetries drops the re-download count of a task that passed,
+// failed, or was stopped or deleted.
+func (e *TachyonEngine) forgetVerifyRetries(id string) {
+	e.verifyMu.Lock()
+	delete(e.verifyRetried, id)
+	e.verifyMu.Unlock()
+}
+
+// acquireVerifySlot waits for a free verification slot. It returns false
+// if the engine starts shutting down first.
+func (e *TachyonEngine) acquireVerifySlot() bool {
+	e.verifyMu.Lock()
+	defer e.verifyMu.Unlock()
+	for e.verifyRunning >= e.maxVerifications {
+		if e.shuttingDown.Load() {
+			return false
+		}
+		e.verifyCond.Wait()
+	}
+	if e.shuttingDown.Load() {
+		return false
+	}
+	e.verifyRunning++
+	return true
+}
+
+func (e *TachyonEngine) releaseVerifySlot() {
+	e.verifyMu.Lock()
+	e.verifyRunning--
+	e.verifyCond.Signal()
+	e.verifyMu.Unlock()
+}
+
+// recoverVerification re-runs the verification of a download that was
+// still being verified when the app last closed. Its file is complete.
+func (e *TachyonEngine) recoverVerification(task storage.DownloadTask) {
+	info := &activeDownloadInfo{StartedAt: time.Now()}
+	info.Transferred.Store(task.BytesTransferred)
+	e.logger.Info("Resuming interrupted verification", "id", task.ID, "filename", task.Filename)
+	e.verifyAndComplete(&task, info, info.StartedAt, "")
+}
@@ -0,0 +1,357 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// newVerifyTest returns an engine whose verifyFile is replaced by verify,
+// and a server that serves content at any path.
+func newVerifyTest(t *testing.T, content []byte, verify func(path, algo, expected string) error) (*TachyonEngine, *storage.Storage, string) {
+	t.Helper()
+	server := spawnContentServer(t, content)
+	e, store := newLoopbackEngine(t)
+	if verify != nil {
+		e.verifyFile = verify
+	}
+	return e, store, server.URL
+}
+
+// concurrencyProbe records the peak number of overlapping calls.
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	current int
+	peak    int
+	reached chan struct{} // closed once current hits want
+	want    int
+}
+
+func newConcurrencyProbe(want int) *concurrencyProbe {
+	return &concurrencyProbe{reached: make(chan struct{}), want: want}
+}
+
+func (p *concurrencyProbe) enter() {
+	p.mu.Lock()
+	p.current++
+	if p.current > p.peak {
+		p.peak = p.current
+	}
+	if p.current == p.want {
+		close(p.reached)
+	}
+	p.mu.Unlock()
+}
+
+func (p *concurrencyProbe) leave() {
+	p.mu.Lock()
+	p.current--
+	p.mu.Unlock()
+}
+
+func (p *concurrencyProbe) max() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.peak
+}
+
+func TestVerification_CompletionsVerifyConcurrently(t *testing.T) {
+	probe := newConcurrencyProbe(2)
+	e, store, base := newVerifyTest(t, generateDummyContent(64*1024), func(path, algo, expected string) error {
+		probe.enter()
+		defer probe.leave()
+		// Hold each verification until both overlap; if verification ran
+		// one at a time the second would never start and this times out.
+		select {
+		case <-probe.reached:
+		case <-time.After(5 * time.Second):
+		}
+		return nil
+	})
+	// One download slot: the second download can only start once the
+	// first has handed its verification off and freed the slot.
+	e.SetMaxConcurrent(1)
+	e.SetMaxVerifications(2)
+
+	dir := t.TempDir()
+	opts := map[string]string{"expected_hash": "sha256:" + hex.EncodeToString(make([]byte, 32))}
+	var ids []string
+	for _, name := range []string{"/a.bin", "/b.bin"} {
+		id, err := e.StartDownload(base+name, dir, "", opts)
+		if err != nil {
+			t.Fatalf("StartDownload: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+			t.Fatalf("task %s ended %s, want completed", id, task.Status)
+		}
+	}
+	if got := probe.max(); got != 2 {
+		t.Errorf("peak concurrent verifications = %d, want 2", got)
+	}
+}
+
+func TestVerification_RespectsLimit(t *testing.T) {
+	probe := newConcurrencyProbe(-1)
+	e, store, base := newVerifyTest(t, generateDummyContent(64*1024), func(path, algo, expected string) error {
+		probe.enter()
+		defer probe.leave()
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	e.SetMaxVerifications(1)
+	if got := e.GetMaxVerifications(); got != 1 {
+		t.Fatalf("GetMaxVerifications = %d", got)
+	}
+
+	dir := t.TempDir()
+	opts := map[string]string{"expected_hash": "sha256:" + hex.EncodeToString(make([]byte, 32))}
+	var ids []string
+	for _, name := range []string{"/a.bin", "/b.bin", "/c.bin"} {
+		id, err := e.StartDownload(base+name, dir, "", opts)
+		if err != nil {
+			t.Fatalf("StartDownload: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+			t.Fatalf("task %s ended %s, want completed", id, task.Status)
+		}
+	}
+	if got := probe.max(); got != 1 {
+		t.Errorf("peak concurrent verifications = %d, want 1", got)
+	}
+
+	e.SetMaxVerifications(0)
+	if got := e.GetMaxVerifications(); got != DefaultMaxVerifications {
+		t.Errorf("SetMaxVerifications(0) left %d, want the default", got)
+	}
+}
+
+func TestVerification_MismatchQuarantinesFile(t *testing.T) {
+	e, store, base := newVerifyTest(t, generateDummyContent(64*1024), nil)
+
+	id, err := e.StartDownload(base+"/bad.bin", t.TempDir(), "", map[string]string{
+		"expected_hash": hex.EncodeToString(make([]byte, 32)), // not the file's hash
+	})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusError {
+		t.Fatalf("status = %s, want error", task.Status)
+	}
+	if _, err := os.Stat(task.SavePath + ".corrupted"); err != nil {
+		t.Errorf("corrupted file not quarantined: %v", err)
+	}
+}
+
+// newFlakyEdgeTest returns an engine downloading from a server that serves
+// corrupted bytes until the verifier has rejected badServes downloads,
+// and the number of verifications run.
+func newFlakyEdgeTest(t *testing.T, content []byte, badServes int32) (*TachyonEngine, *storage.Storage, string, *atomic.Int32) {
+	t.Helper()
+	corrupt := append([]byte(nil), content...)
+	corrupt[len(corrupt)/2] ^= 0xFF
+	var rejected, verified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := content
+		if rejected.Load() < badServes {
+			body = corrupt
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(body))
+	}))
+	t.Cleanup(server.Close)
+
+	e, store := newLoopbackEngine(t)
+	verify := e.verifyFile
+	e.verifyFile = func(path, algo, expected string) error {
+		verified.Add(1)
+		err := verify(path, algo, expected)
+		if err != nil {
+			rejected.Add(1)
+		}
+		return err
+	}
+	return e, store, server.URL + "/file.bin", &verified
+}
+
+func TestVerification_RetryAfterMismatch(t *testing.T) {
+	content := generateDummyContent(256 * 1024)
+	sum := sha256.Sum256(content)
+	e, store, url, verified := newFlakyEdgeTest(t, content, 1)
+	e.SetVerifyRetries(2)
+
+	id, err := e.StartDownload(url, t.TempDir(), "", map[string]string{"expected_hash": "sha256:" + hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed after a retry", task.Status)
+	}
+	if got := verified.Load(); got != 2 {
+		t.Errorf("verified %d times, want 2 (corrupted, then good)", got)
+	}
+	data, err := os.ReadFile(task.SavePath)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Errorf("final file differs from the good content (err %v)", err)
+	}
+	if _, err := os.Stat(task.SavePath + ".corrupted"); !os.IsNotExist(err) {
+		t.Error("a file that passed on retry was quarantined")
+	}
+}
+
+func TestVerification_RetriesExhausted(t *testing.T) {
+	content := generateDummyContent(64 * 1024)
+	sum := sha256.Sum256(content)
+	e, store, url, verified := newFlakyEdgeTest(t, content, 100)
+	e.SetVerifyRetries(2)
+
+	id, err := e.StartDownload(url, t.TempDir(), "", map[string]string{"expected_hash": "sha256:" + hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusError {
+		t.Fatalf("status = %s, want error", task.Status)
+	}
+	if got := verified.Load(); got != 3 {
+		t.Errorf("verified %d times, want 3 (the download and two retries)", got)
+	}
+	if _, err := os.Stat(task.SavePath + ".corrupted"); err != nil {
+		t.Errorf("corrupted file not quarantined after the last retry: %v", err)
+	}
+}
+
+func TestVerifyRetries_ForgottenWhenTaskEnds(t *testing.T) {
+	e, store, _ := newVerifyTest(t, nil, nil)
+	for _, id := range []string{"stopped", "deleted", "failed"} {
+		if err := store.SaveTask(storage.DownloadTask{ID: id, Status: storage.StatusPaused}); err != nil {
+			t.Fatal(err)
+		}
+		e.verifyRetried[id] = 1
+	}
+
+	if err := e.StopDownload("stopped"); err != nil {
+		t.Fatalf("StopDownload: %v", err)
+	}
+	if err := e.DeleteDownload("deleted", false); err != nil {
+		t.Fatalf("DeleteDownload: %v", err)
+	}
+	e.failTask(&storage.DownloadTask{ID: "failed"}, "boom")
+
+	e.verifyMu.Lock()
+	defer e.verifyMu.Unlock()
+	if len(e.verifyRetried) != 0 {
+		t.Errorf("retry counts left behind: %v", e.verifyRetried)
+	}
+}
+
+func TestSetVerifyRetries_Bounds(t *testing.T) {
+	e, _, _ := newVerifyTest(t, nil, nil)
+	if got := e.GetVerifyRetries(); got != 0 {
+		t.Errorf("default = %d, want 0", got)
+	}
+	for _, tc := range []struct{ set, want int }{{3, 3}, {-1, 0}, {100, MaxVerifyRetries}} {
+		e.SetVerifyRetries(tc.set)
+		if got := e.GetVerifyRetries(); got != tc.want {
+			t.Errorf("SetVerifyRetries(%d) left %d, want %d", tc.set, got, tc.want)
+		}
+	}
+}
+
+func TestRecoverInterruptedDownloads_ResumesVerification(t *testing.T) {
+	content := generateDummyContent(16 * 1024)
+	e, store, _ := newVerifyTest(t, content, nil)
+
+	path := filepath.Join(t.TempDir(), "done.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	store.SaveTask(storage.DownloadTask{
+		ID:           "verifying",
+		Filename:     "done.bin",
+		SavePath:     path,
+		Status:       storage.StatusVerifying,
+		TotalSize:    int64(len(content)),
+		Downloaded:   int64(len(content)),
+		ExpectedHash: hex.EncodeToString(sum[:]),
+	})
+
+	e.RecoverInterruptedDownloads()
+	if task := waitForFinalStatus(t, store, "verifying"); task.Status != storage.StatusCompleted {
+		t.Errorf("status = %s, want completed", task.Status)
+	}
+}
+
+// started reports whether the event log has a start entry for id.
+func started(e *TachyonEngine, id string) bool {
+	for _, ev := range e.GetEventLog(0) {
+		if ev.Kind == EventDownloadStarted && ev.TaskID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHoldQueueWhileVerifying(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	e, store, base := newVerifyTest(t, generateDummyContent(64*1024), func(path, algo, expected string) error {
+		once.Do(func() { close(entered) })
+		<-release
+		return nil
+	})
+	e.SetMaxConcurrent(2)
+	e.SetHoldQueueWhileVerifying(true)
+	if !e.GetHoldQueueWhileVerifying() {
+		t.Fatal("GetHoldQueueWhileVerifying = false after enabling")
+	}
+
+	dir := t.TempDir()
+	first, err := e.StartDownload(base+"/a.bin", dir, "", map[string]string{"expected_hash": "sha256:" + hex.EncodeToString(make([]byte, 32))})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("verification never started")
+	}
+
+	second, err := e.StartDownload(base+"/b.bin", dir, "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	// A slot is free, but the first download is still being verified.
+	time.Sleep(300 * time.Millisecond)
+	if started(e, second) {
+		t.Fatal("a new download started while a verification was in progress")
+	}
+	if task, _ := store.GetTask(second); task.Status != storage.StatusPending {
+		t.Fatalf("second task status = %s, want pending", task.Status)
+	}
+
+	close(release)
+	for _, id := range []string{first, second} {
+		if task := waitForFinalStatus(t, store, id); task.Status != storage.StatusCompleted {
+			t.Fatalf("task %s ended %s, want completed", id, task.Status)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"time"
 )
@@ -43,3 +44,85 @@ func (e *TachyonEngine) WarmUpHost(host string, count int) {
 		<-done
 	}
 }
+
+// Default pacing used when pacing is switched on for a host without global
+// values configured.
+const (
+	DefaultSpawnJitter = 250 * time.Millisecond
+	DefaultSpawnRamp   = 150 * time.Millisecond
+)
+
+// SetSpawnPacing staggers worker start-up so a download doesn't open all of
+// its range requests in the same instant. Worker i waits i*ramp plus a
+// random delay of up to jitter before its first request. Zero values turn
+// pacing off globally; SetHostPacing can still enable it per host.
+func (e *TachyonEngine) SetSpawnPacing(jitter, ramp time.Duration) {
+	if jitter < 0 {
+		jitter = 0
+	}
+	if ramp < 0 {
+		ramp = 0
+	}
+	e.spawnJitter.Store(int64(jitter))
+	e.spawnRamp.Store(int64(ramp))
+}
+
+// GetSpawnPacing returns the global jitter and ramp step.
+func (e *TachyonEngine) GetSpawnPacing() (jitter, ramp time.Duration) {
+	return time.Duration(e.spawnJitter.Load()), time.Duration(e.spawnRamp.Load())
+}
+
+// SetHostPacing forces pacing on or off for one host regardless of the
+// global setting.
+func (e *TachyonEngine) SetHostPacing(host string, enabled bool) {
+	e.hostPacing.Store(host, enabled)
+}
+
+// ClearHostPacing removes a per-host override.
+func (e *TachyonEngine) ClearHostPacing(host string) {
+	e.hostPacing.Delete(host)
+}
+
+// pacingFor resolves the jitter and ramp that apply to host.
+func (e *TachyonEngine) pacingFor(host string) (jitter, ramp time.Duration) {
+	jitter, ramp = e.GetSpawnPacing()
+	if v, ok := e.hostPacing.Load(host); ok {
+		if !v.(bool) {
+			return 0, 0
+		}
+		if jitter == 0 && ramp == 0 {
+			return DefaultSpawnJitter, DefaultSpawnRamp
+		}
+	}
+	return jitter, ramp
+}
+
+// spawnDelay returns how long worker index should wait before its first
+// request. The first worker always starts immediately.
+func (e *TachyonEngine) spawnDelay(host string, index int) time.Duration {
+	if index <= 0 {
+		return 0
+	}
+	jitter, ramp := e.pacingFor(host)
+	d := time.Duration(index) * ramp
+	if jitter > 0 {
+		d += time.Duration(rand.Int64N(int64(jitter)))
+	}
+	return d
+}
+
+// sleepCtx waits for d or until ctx is cancelled. It reports whether the
+// full duration elapsed.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
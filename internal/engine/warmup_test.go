@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newPacingEngine() *TachyonEngine {
+	return &TachyonEngine{hostPacing: sync.Map{}}
+}
+
+func TestSpawnDelay_DisabledByDefault(t *testing.T) {
+	e := newPacingEngine()
+	for i := 0; i < 8; i++ {
+		if d := e.spawnDelay("example.com", i); d != 0 {
+			t.Errorf("worker %d delay = %v, want 0 with pacing off", i, d)
+		}
+	}
+}
+
+func TestSpawnDelay_RampAndJitterBounds(t *testing.T) {
+	e := newPacingEngine()
+	e.SetSpawnPacing(50*time.Millisecond, 100*time.Millisecond)
+
+	if d := e.spawnDelay("example.com", 0); d != 0 {
+		t.Errorf("first worker should start immediately, got %v", d)
+	}
+	for i := 1; i < 6; i++ {
+		for n := 0; n < 20; n++ {
+			d := e.spawnDelay("example.com", i)
+			min := time.Duration(i) * 100 * time.Millisecond
+			if d < min || d >= min+50*time.Millisecond {
+				t.Fatalf("worker %d delay = %v, want in [%v, %v)", i, d, min, min+50*time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestSpawnDelay_HostOverride(t *testing.T) {
+	e := newPacingEngine()
+
+	// Enabling a host with no global values falls back to the defaults
+	e.SetHostPacing("cdn.example.com", true)
+	if d := e.spawnDelay("cdn.example.com", 2); d < 2*DefaultSpawnRamp {
+		t.Errorf("host override delay = %v, want >= %v", d, 2*DefaultSpawnRamp)
+	}
+	if d := e.spawnDelay("other.com", 2); d != 0 {
+		t.Errorf("other host delay = %v, want 0", d)
+	}
+
+	// Disabling a host wins over the global setting
+	e.SetSpawnPacing(0, time.Second)
+	e.SetHostPacing("fast.example.com", false)
+	if d := e.spawnDelay("fast.example.com", 3); d != 0 {
+		t.Errorf("disabled host delay = %v, want 0", d)
+	}
+
+	e.ClearHostPacing("fast.example.com")
+	if d := e.spawnDelay("fast.example.com", 3); d != 3*time.Second {
+		t.Errorf("cleared host delay = %v, want 3s", d)
+	}
+}
+
+func TestSleepCtx(t *testing.T) {
+	if !sleepCtx(context.Background(), time.Millisecond) {
+		t.Error("sleepCtx should complete when not cancelled")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if sleepCtx(ctx, time.Hour) {
+		t.Error("sleepCtx should report cancellation")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("sleepCtx did not return promptly on cancel")
+	}
+}
@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"project-tachyon/internal/storage"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// maxWatchedDirs bounds how many directories the watcher registers.
+	// Directories beyond the limit are left to the periodic reconciliation
+	// scan, as are directories the OS refuses to watch (inotify limits).
+	maxWatchedDirs = 256
+
+	// moveMatchWindow is how soon a create must follow a rename for the two
+	// to be treated as the user moving a file.
+	moveMatchWindow = 2 * time.Second
+)
+
+type pendingMove struct {
+	taskID string
+	path   string
+	size   int64
+	at     time.Time
+}
+
+// downloadWatcher watches the folders holding downloaded files and keeps the
+// file-existence cache current between reconciliation scans.
+type downloadWatcher struct {
+	e       *TachyonEngine
+	w       *fsnotify.Watcher
+	mu      sync.Mutex
+	dirs    map[string]bool
+	full    bool // stopped adding directories after hitting a limit
+	pending []pendingMove
+	done    chan struct{}
+}
+
+// SetFileWatching starts or stops watching download directories. When the
+// platform has no watcher support an error is returned and the periodic
+// reconciliation scan keeps running.
+func (e *TachyonEngine) SetFileWatching(enabled bool) error {
+	e.watcherMu.Lock()
+	defer e.watcherMu.Unlock()
+
+	if !enabled {
+		if e.watcher != nil {
+			e.watcher.close()
+			e.watcher = nil
+		}
+		return nil
+	}
+	if e.watcher != nil {
+		return nil
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("file watching unavailable: %w", err)
+	}
+	dw := &downloadWatcher{
+		e:    e,
+		w:    fw,
+		dirs: make(map[string]bool),
+		done: make(chan struct{}),
+	}
+	e.watcher = dw
+	go dw.run()
+
+	if tasks, err := e.storage.GetAllTasks(); err == nil {
+		for _, t := range tasks {
+			if t.Status == storage.StatusCompleted && t.SavePath != "" {
+				dw.addDir(filepath.Dir(t.SavePath))
+			}
+		}
+	}
+	return nil
+}
+
+// IsFileWatching reports whether the directory watcher is running.
+func (e *TachyonEngine) IsFileWatching() bool {
+	e.watcherMu.Lock()
+	defer e.watcherMu.Unlock()
+	return e.watcher != nil
+}
+
+// watchDir adds the folder containing path to the watcher, if running.
+func (e *TachyonEngine) watchDir(path string) {
+	if path == "" {
+		return
+	}
+	e.watcherMu.Lock()
+	dw := e.watcher
+	e.watcherMu.Unlock()
+	if dw != nil {
+		dw.addDir(filepath.Dir(path))
+	}
+}
+
+func (dw *downloadWatcher) addDir(dir string) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	if dw.dirs[dir] || dw.full {
+		return
+	}
+	if len(dw.dirs) >= maxWatchedDirs {
+		dw.full = true
+		dw.e.logger.Warn("Watched directory limit reached, relying on periodic scans", "limit", maxWatchedDirs)
+		return
+	}
+	if err := dw.w.Add(dir); err != nil {
+		if errors.Is(err, fsnotify.ErrClosed) {
+			return
+		}
+		if os.IsNotExist(err) {
+			return
+		}
+		// Typically ENOSPC (inotify watch limit): stop trying so we don't
+		// log for every remaining folder.
+		dw.full = true
+		dw.e.logger.Warn("Cannot watch more directories, relying on periodic scans", "dir", dir, "error", err)
+		return
+	}
+	dw.dirs[dir] = true
+}
+
+func (dw *downloadWatcher) close() {
+	close(dw.done)
+	dw.w.Close()
+}
+
+func (dw *downloadWatcher) run() {
+	for {
+		select {
+		case <-dw.done:
+			return
+		case ev, ok := <-dw.w.Events:
+			if !ok {
+				return
+			}
+			dw.handle(ev)
+		case err, ok := <-dw.w.Errors:
+			if !ok {
+				return
+			}
+			dw.e.logger.Warn("File watcher error", "error", err)
+		}
+	}
+}
+
+func (dw *downloadWatcher) handle(ev fsnotify.Event) {
+	e := dw.e
+	switch {
+	case ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename):
+		task, err := e.storage.GetTaskBySavePath(ev.Name)
+		if err != nil || task.Status != storage.StatusCompleted {
+			return
+		}
+		prev, hadPrev := e.files.set(task.ID, task.SavePath, false)
+		if ev.Has(fsnotify.Rename) {
+			dw.mu.Lock()
+			dw.pending = append(dw.pending, pendingMove{taskID: task.ID, path: task.SavePath, size: task.TotalSize, at: time.Now()})
+			dw.mu.Unlock()
+		}
+		if !hadPrev || prev {
+			e.emit("download:file_missing", map[string]interface{}{
+				"id":   task.ID,
+				"path": task.SavePath,
+			})
+		}
+
+	case ev.Has(fsnotify.Create):
+		if task, err := e.storage.GetTaskBySavePath(ev.Name); err == nil {
+			if task.Status == storage.StatusCompleted {
+				if prev, hadPrev := e.files.set(task.ID, task.SavePath, true); hadPrev && !prev {
+					e.emit("download:file_restored", map[string]interface{}{
+						"id":   task.ID,
+						"path": task.SavePath,
+					})
+				}
+			}
+			return
+		}
+		if mv := dw.matchMove(ev.Name); mv != nil {
+			e.logger.Info("Completed file moved", "id", mv.taskID, "from", mv.path, "to", ev.Name)
+			e.emit("download:file_moved", map[string]interface{}{
+				"id":       mv.taskID,
+				"old_path": mv.path,
+				"new_path": ev.Name,
+			})
+		}
+	}
+}
+
+// matchMove pairs a newly created file with a recent rename of a completed
+// download of the same size.
+func (dw *downloadWatcher) matchMove(path string) *pendingMove {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	now := time.Now()
+	kept := dw.pending[:0]
+	var match *pendingMove
+	for _, mv := range dw.pending {
+		if now.Sub(mv.at) > moveMatchWindow {
+			continue
+		}
+		if match == nil && (mv.size <= 0 || mv.size == info.Size()) {
+			m := mv
+			match = &m
+			continue
+		}
+		kept = append(kept, mv)
+	}
+	dw.pending = kept
+	return match
+}
+
+// UpdateSavePath points a completed download at a file the user moved, as
+// offered by download:file_moved.
+func (e *TachyonEngine) UpdateSavePath(id, newPath string) error {
+	task, err := e.storage.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+	if task.Status != storage.StatusCompleted {
+		return fmt.Errorf("only completed downloads can be re-linked (status: %s)", task.Status)
+	}
+	info, err := os.Stat(newPath)
+	if err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", newPath)
+	}
+
+	task.SavePath = newPath
+	task.Filename = filepath.Base(newPath)
+	task.UpdatedAt = time.Now().Format(time.RFC3339)
+	if err := e.storage.SaveTask(task); err != nil {
+		return fmt.Errorf("failed to save task: %w", err)
+	}
+	e.markFileState(id, newPath, true)
+	e.watchDir(newPath)
+
+	e.emit("download:path_updated", map[string]interface{}{
+		"id":       id,
+		"path":     newPath,
+		"filename": task.Filename,
+	})
+	return nil
+}
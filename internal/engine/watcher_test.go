@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+type watchEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+func newWatchedEngine(t *testing.T) (*TachyonEngine, *storage.Storage, chan watchEvent) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+	events := make(chan watchEvent, 32)
+	e.eventHook = func(name string, data interface{}) {
+		m, _ := data.(map[string]interface{})
+		events <- watchEvent{name, m}
+	}
+	t.Cleanup(func() { e.SetFileWatching(false) })
+	return e, s, events
+}
+
+func waitForEvent(t *testing.T, events chan watchEvent, name string) map[string]interface{} {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.name == name {
+				return ev.data
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", name)
+			return nil
+		}
+	}
+}
+
+func TestFileWatcher_DeleteAndRestore(t *testing.T) {
+	e, s, events := newWatchedEngine(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "movie.mkv")
+	os.WriteFile(path, []byte("data"), 0644)
+	s.SaveTask(storage.DownloadTask{ID: "m1", SavePath: path, Status: "completed", TotalSize: 4})
+
+	if err := e.SetFileWatching(true); err != nil {
+		t.Skipf("file watching unavailable: %v", err)
+	}
+	if !e.FileExists("m1", path) {
+		t.Fatal("file should exist before deletion")
+	}
+
+	os.Remove(path)
+	data := waitForEvent(t, events, "download:file_missing")
+	if data["id"] != "m1" {
+		t.Errorf("file_missing id = %v, want m1", data["id"])
+	}
+	if e.FileExists("m1", path) {
+		t.Error("cache should report the file missing after the watcher event")
+	}
+
+	os.WriteFile(path, []byte("data"), 0644)
+	waitForEvent(t, events, "download:file_restored")
+	if !e.FileExists("m1", path) {
+		t.Error("cache should report the file present after it is recreated")
+	}
+}
+
+func TestFileWatcher_DetectsMoveAndRelink(t *testing.T) {
+	e, s, events := newWatchedEngine(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	os.WriteFile(path, []byte("12345678"), 0644)
+	s.SaveTask(storage.DownloadTask{ID: "r1", SavePath: path, Status: "completed", TotalSize: 8})
+
+	if err := e.SetFileWatching(true); err != nil {
+		t.Skipf("file watching unavailable: %v", err)
+	}
+
+	newPath := filepath.Join(dir, "report-final.pdf")
+	if err := os.Rename(path, newPath); err != nil {
+		t.Fatal(err)
+	}
+	data := waitForEvent(t, events, "download:file_moved")
+	if data["id"] != "r1" || data["new_path"] != newPath {
+		t.Fatalf("file_moved = %v, want id r1 new_path %s", data, newPath)
+	}
+
+	if err := e.UpdateSavePath("r1", newPath); err != nil {
+		t.Fatalf("UpdateSavePath failed: %v", err)
+	}
+	task, _ := s.GetTask("r1")
+	if task.SavePath != newPath || task.Filename != "report-final.pdf" {
+		t.Errorf("task not re-linked: path=%q filename=%q", task.SavePath, task.Filename)
+	}
+	if !e.FileExists("r1", newPath) {
+		t.Error("re-linked file should be cached as present")
+	}
+}
+
+func TestUpdateSavePath_Validation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := createDownloadsTestDB(t)
+	e := NewEngine(logger, s)
+
+	dir := t.TempDir()
+	s.SaveTask(storage.DownloadTask{ID: "p", SavePath: filepath.Join(dir, "a"), Status: "paused"})
+	s.SaveTask(storage.DownloadTask{ID: "c", SavePath: filepath.Join(dir, "b"), Status: "completed"})
+
+	if err := e.UpdateSavePath("p", dir); err == nil {
+		t.Error("expected error re-linking an unfinished download")
+	}
+	if err := e.UpdateSavePath("c", filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+	if err := e.UpdateSavePath("c", dir); err == nil {
+		t.Error("expected error for a directory")
+	}
+}
+
+func TestFileWatcher_Toggle(t *testing.T) {
+	e, _, _ := newWatchedEngine(t)
+	if e.IsFileWatching() {
+		t.Fatal("watching should be off until enabled")
+	}
+	if err := e.SetFileWatching(true); err != nil {
+		t.Skipf("file watching unavailable: %v", err)
+	}
+	// Missing folders are skipped without exhausting the watcher
+	e.watchDir(filepath.Join(t.TempDir(), "nope", "file.bin"))
+	if e.watcher.full {
+		t.Error("a missing folder should not disable further watches")
+	}
+	e.SetFileWatching(false)
+	if e.IsFileWatching() {
+		t.Error("watching should be off after disabling")
+	}
+}
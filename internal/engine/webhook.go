@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"project-tachyon/internal/notify"
+	"project-tachyon/internal/storage"
+)
+
+const (
+	webhookTimeout    = 10 * time.Second // per delivery attempt
+	webhookAttempts   = 3
+	webhookRetryDelay = 2 * time.Second // doubled after each failed attempt
+
+	// WebhookSignatureHeader carries "sha256=" + the hex HMAC-SHA256 of the
+	// request body, keyed with the webhook secret. It is only sent when a
+	// secret is configured.
+	WebhookSignatureHeader = "X-Tachyon-Signature"
+	// WebhookEventHeader names the event, matching WebhookPayload.Event.
+	WebhookEventHeader = "X-Tachyon-Event"
+)
+
+// WebhookPayload is the JSON body POSTed to the completion webhook when a
+// download completes or fails.
+type WebhookPayload struct {
+	Event     string         `json:"event"` // "download.completed", "download.failed" or "test"
+	ID        string         `json:"id"`
+	Filename  string         `json:"filename"`
+	URL       string         `json:"url"`
+	Status    storage.Status `json:"status"`
+	Size      int64          `json:"size"` // bytes; what was received so far for failures
+	Path      string         `json:"path"`
+	Duration  float64        `json:"duration"` // seconds this run took, 0 if unknown
+	Error     string         `json:"error,omitempty"`
+	Timestamp string         `json:"timestamp"`
+}
+
+// SetCompletionWebhook sets the URL notified when a download completes or
+// fails, and the secret used to sign each payload (empty = unsigned). An
+// empty URL turns the webhook off.
+func (e *TachyonEngine) SetCompletionWebhook(rawURL, secret string) error {
+	var n *webhookNotifier
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q (want http or https)", rawURL)
+		}
+		n = &webhookNotifier{
+			url:        rawURL,
+			secret:     secret,
+			client:     e.webhookClient,
+			retryDelay: e.webhookRetryDelay,
+			logger:     e.logger,
+		}
+	}
+	e.webhookMu.Lock()
+	e.webhook = n
+	e.webhookMu.Unlock()
+	return nil
+}
+
+// GetCompletionWebhook returns the completion webhook URL ("" = off).
+func (e *TachyonEngine) GetCompletionWebhook() string {
+	e.webhookMu.RLock()
+	defer e.webhookMu.RUnlock()
+	if e.webhook == nil {
+		return ""
+	}
+	return e.webhook.url
+}
+
+// SendTestWebhook delivers a synthetic "test" event to the completion
+// webhook once, without retries, and reports whether it was accepted.
+func (e *TachyonEngine) SendTestWebhook() error {
+	e.webhookMu.RLock()
+	n := e.webhook
+	e.webhookMu.RUnlock()
+	if n == nil {
+		return fmt.Errorf("no completion webhook is configured")
+	}
+	p := n.payload(notify.Event{Kind: notify.KindTest, ID: "test", Filename: "tachyon-webhook-test.bin"})
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return n.deliver(context.Background(), p.Event, body)
+}
+
+// webhookNotifier POSTs a signed WebhookPayload to the completion webhook,
+// retrying failed deliveries with a growing delay.
+type webhookNotifier struct {
+	url        string
+	secret     string
+	client     *http.Client
+	retryDelay time.Duration
+	logger     *slog.Logger
+}
+
+func (n *webhookNotifier) Name() string { return "webhook" }
+
+func (n *webhookNotifier) Notify(ctx context.Context, ev notify.Event) error {
+	p := n.payload(ev)
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	delay := n.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := n.deliver(ctx, p.Event, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= webhookAttempts {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+		n.logger.Debug("Retrying completion webhook", "id", p.ID, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// payload maps ev onto the webhook's JSON body.
+func (n *webhookNotifier) payload(ev notify.Event) WebhookPayload {
+	p := WebhookPayload{
+		Event:     "download.completed",
+		ID:        ev.ID,
+		Filename:  ev.Filename,
+		URL:       ev.URL,
+		Status:    storage.StatusCompleted,
+		Size:      ev.Size,
+		Path:      ev.Path,
+		Duration:  ev.Duration.Seconds(),
+		Error:     ev.Error,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	switch ev.Kind {
+	case notify.KindFailed:
+		p.Event = "download.failed"
+		p.Status = storage.StatusError
+	case notify.KindTest:
+		p.Event = "test"
+	}
+	return p
+}
+
+// deliver makes one delivery attempt. Any 2xx response counts as
+// delivered.
+func (n *webhookNotifier) deliver(ctx context.Context, event string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tachyon-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, event)
+	if n.secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the WebhookSignatureHeader value for body, so
+// receivers written in Go can verify deliveries the same way.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
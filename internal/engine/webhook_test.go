@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-tachyon/internal/storage"
+)
+
+// webhookDelivery is one request seen by the mock webhook receiver.
+type webhookDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+// spawnWebhookReceiver records every delivery; the first failFirst
+// requests are answered with 500.
+func spawnWebhookReceiver(t *testing.T, failFirst int32) (string, chan webhookDelivery, *atomic.Int32) {
+	t.Helper()
+	deliveries := make(chan webhookDelivery, 8)
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if hits.Add(1) <= failFirst {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		deliveries <- webhookDelivery{header: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/hook", deliveries, &hits
+}
+
+func waitForDelivery(t *testing.T, deliveries chan webhookDelivery) (webhookDelivery, WebhookPayload) {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		var p WebhookPayload
+		if err := json.Unmarshal(d.body, &p); err != nil {
+			t.Fatalf("invalid webhook payload %q: %v", d.body, err)
+		}
+		return d, p
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	return webhookDelivery{}, WebhookPayload{}
+}
+
+func TestWebhookOnCompletion(t *testing.T) {
+	engine, store := newLoopbackEngine(t)
+	url := spawnContentServer(t, generateDummyContent(64*1024)).URL + "/file.bin"
+	hook, deliveries, _ := spawnWebhookReceiver(t, 0)
+	if err := engine.SetCompletionWebhook(hook, "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := engine.StartDownload(url, t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("StartDownload failed: %v", err)
+	}
+	task := waitForFinalStatus(t, store, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("expected completed, got %s", task.Status)
+	}
+
+	d, p := waitForDelivery(t, deliveries)
+	if got, want := d.header.Get(WebhookSignatureHeader), SignWebhookPayload("s3cret", d.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if d.header.Get(WebhookEventHeader) != "download.completed" || d.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", d.header)
+	}
+	if p.Event != "download.completed" || p.ID != id || p.Status != storage.StatusCompleted {
+		t.Errorf("payload = %+v", p)
+	}
+	if p.Filename != task.Filename || p.Path != task.SavePath || p.Size != 64*1024 {
+		t.Errorf("payload file = %q %q %d, want %q %q %d", p.Filename, p.Path, p.Size, task.Filename, task.SavePath, 64*1024)
+	}
+	if p.Duration <= 0 {
+		t.Errorf("duration = %v, want > 0", p.Duration)
+	}
+}
+
+func TestWebhookOnFailureRetries(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	engine.webhookRetryDelay = 10 * time.Millisecond
+	hook, deliveries, hits := spawnWebhookReceiver(t, 2)
+	engine.SetCompletionWebhook(hook, "")
+
+	task := &storage.DownloadTask{ID: "t1", Filename: "a.bin", SavePath: "/tmp/a.bin", Downloaded: 512, TotalSize: 1024}
+	engine.failTask(task, "server went away")
+
+	d, p := waitForDelivery(t, deliveries)
+	if hits.Load() != 3 {
+		t.Errorf("receiver hit %d times, want 3 (two failures, then success)", hits.Load())
+	}
+	if d.header.Get(WebhookSignatureHeader) != "" {
+		t.Error("unsigned webhook carried a signature")
+	}
+	if p.Event != "download.failed" || p.Status != storage.StatusError || p.Error != "server went away" || p.Size != 512 {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	engine.webhookRetryDelay = time.Millisecond
+	hook, _, hits := spawnWebhookReceiver(t, 100)
+	engine.SetCompletionWebhook(hook, "")
+
+	engine.failTask(&storage.DownloadTask{ID: "t1"}, "boom")
+	time.Sleep(200 * time.Millisecond)
+	if hits.Load() != webhookAttempts {
+		t.Errorf("receiver hit %d times, want %d", hits.Load(), webhookAttempts)
+	}
+}
+
+func TestSetCompletionWebhook_Validation(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	for _, u := range []string{"ftp://example.com/hook", "example.com/hook", "http://", "://bad"} {
+		if err := engine.SetCompletionWebhook(u, ""); err == nil {
+			t.Errorf("expected error for %q", u)
+		}
+	}
+	if err := engine.SetCompletionWebhook("https://hooks.example.com/x", "k"); err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.GetCompletionWebhook(); got != "https://hooks.example.com/x" {
+		t.Errorf("GetCompletionWebhook = %q", got)
+	}
+	if err := engine.SetCompletionWebhook("", ""); err != nil || engine.GetCompletionWebhook() != "" {
+		t.Errorf("clearing the webhook: err=%v url=%q", err, engine.GetCompletionWebhook())
+	}
+}
+
+func TestSendTestWebhook(t *testing.T) {
+	engine, _ := newLoopbackEngine(t)
+	if err := engine.SendTestWebhook(); err == nil {
+		t.Error("expected an error with no webhook configured")
+	}
+
+	hook, deliveries, _ := spawnWebhookReceiver(t, 0)
+	engine.SetCompletionWebhook(hook, "k")
+	if err := engine.SendTestWebhook(); err != nil {
+		t.Fatalf("SendTestWebhook: %v", err)
+	}
+	d, p := waitForDelivery(t, deliveries)
+	if p.Event != "test" || d.header.Get(WebhookSignatureHeader) != SignWebhookPayload("k", d.body) {
+		t.Errorf("test delivery = %+v, headers %v", p, d.header)
+	}
+
+	failing, _, _ := spawnWebhookReceiver(t, 100)
+	engine.SetCompletionWebhook(failing, "")
+	if err := engine.SendTestWebhook(); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected the receiver's 500 to be reported, got %v", err)
+	}
+}
@@ -9,9 +9,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"project-tachyon/internal/platform"
 	"project-tachyon/internal/storage"
-
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // DownloadPart represents a single unit of work
@@ -22,13 +21,47 @@ type DownloadPart struct {
 	Attempts    int   // Retry count
 }
 
+// workerScale is a download's target worker count and the workers counted
+// towards it (started, or waiting out their spawn delay).
+type workerScale struct {
+	target  atomic.Int32
+	running atomic.Int32
+}
+
+// retire reports whether a worker should exit because more are running
+// than the target, and if so counts it out. Only as many workers retire as
+// are over the target.
+func (s *workerScale) retire() bool {
+	for {
+		running := s.running.Load()
+		if running <= s.target.Load() {
+			return false
+		}
+		if s.running.CompareAndSwap(running, running-1) {
+			return true
+		}
+	}
+}
+
 // downloadWorker consumes parts and downloads them to individual temp files.
-func (e *TachyonEngine) downloadWorker(ctx context.Context, taskID string, urlStr string, host string, tempDir string, partCh <-chan DownloadPart, retryCh chan DownloadPart, partDoneCh chan<- int, errCh chan<- error, downloadedBytes *int64, errorCount *atomic.Int32, headersStr string, cookiesStr string, strictRanges bool, inflight *inflightTracker, nextStealID *atomic.Int32) {
+// Between parts it exits if scale has more workers than its target.
+func (e *TachyonEngine) downloadWorker(ctx context.Context, taskID string, urlStr string, host string, tempDir string, partCh <-chan DownloadPart, retryCh chan DownloadPart, partDoneCh chan<- int, errCh chan<- error, downloadedBytes *int64, errorCount *atomic.Int32, headersStr string, cookiesStr string, strictRanges bool, inflight *inflightTracker, nextStealID *atomic.Int32, scale *workerScale) {
+	retired := false
+	defer func() {
+		if !retired {
+			scale.running.Add(-1)
+		}
+	}()
+
 	partChOpen := true
 	for {
 		if ctx.Err() != nil {
 			return
 		}
+		if scale.retire() {
+			retired = true
+			return
+		}
 
 		// Phase 1: consume from primary channel and retries
 		if partChOpen {
@@ -74,10 +107,25 @@ func (e *TachyonEngine) downloadWorker(ctx context.Context, taskID string, urlSt
 
 // processDownloadPart handles downloading a single part with retry logic
 func (e *TachyonEngine) processDownloadPart(ctx context.Context, taskID string, urlStr string, host string, tempDir string, part DownloadPart, retryCh chan DownloadPart, partDoneCh chan<- int, errCh chan<- error, downloadedBytes *int64, errorCount *atomic.Int32, headersStr string, cookiesStr string, strictRanges bool, inflight *inflightTracker) {
+	// Take a slot in the host-wide connection budget before the part becomes
+	// visible to stealers; give it back before any backoff sleep.
+	if err := e.hostBudget.Acquire(ctx, host); err != nil {
+		return
+	}
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			e.hostBudget.Release(host)
+		}
+	}
+	defer release()
+
 	inflight.Start(part)
 	defer inflight.Complete(part.ID)
 
 	if err := e.breaker.Allow(host); err != nil {
+		release()
 		if part.Attempts < 3 {
 			part.Attempts++
 			// Exponential backoff before circuit breaker retry
@@ -108,6 +156,7 @@ func (e *TachyonEngine) processDownloadPart(ctx context.Context, taskID string,
 	}
 
 	e.congestion.RecordOutcome(host, time.Since(startedAt), err)
+	release()
 
 	if err != nil {
 		e.breaker.RecordFailure(host)
@@ -118,18 +167,20 @@ func (e *TachyonEngine) processDownloadPart(ctx context.Context, taskID string,
 			return
 		}
 
-		if err == ErrLinkExpired {
-			e.logger.Warn("Link expired (403), task needs URL refresh", "id", taskID)
-			errCh <- ErrLinkExpired
+		// Retrying can't bring back bytes the server no longer has
+		if errors.Is(err, ErrRemoteFileChanged) {
+			errCh <- err
 			return
 		}
 
-		if errors.Is(err, ErrStallTimeout) {
-			e.logger.Error("Download stalled (30s timeout)", "id", taskID, "part", part.ID)
-			errCh <- ErrStallTimeout
+		if err == ErrLinkExpired {
+			e.logger.Warn("Link expired (403), task needs URL refresh", "id", taskID)
+			errCh <- ErrLinkExpired
 			return
 		}
 
+		// An idle part is retried like any other failure: the stalled
+		// connection was closed, so the retry gets a fresh one.
 		if part.Attempts < 3 {
 			part.Attempts++
 			e.logger.Warn("Retrying part", "id", part.ID, "attempt", part.Attempts)
@@ -151,6 +202,10 @@ func (e *TachyonEngine) processDownloadPart(ctx context.Context, taskID string,
 			}
 		} else {
 			e.logger.Error("Part exceeded max retries", "id", part.ID)
+			if errors.Is(err, ErrStallTimeout) {
+				errCh <- ErrStallTimeout
+				return
+			}
 			errCh <- fmt.Errorf("Part %d run out of attempts", part.ID)
 			return
 		}
@@ -160,6 +215,28 @@ func (e *TachyonEngine) processDownloadPart(ctx context.Context, taskID string,
 	}
 }
 
+// endFileAt records that the server reports the file ending at size, so the
+// merged file is checked against that rather than the probed size.
+func (e *TachyonEngine) endFileAt(taskID string, size int64) {
+	v, ok := e.activeDownloads.Load(taskID)
+	if !ok {
+		return
+	}
+	info, ok := v.(*activeDownloadInfo)
+	if !ok {
+		return
+	}
+	for {
+		cur := info.totalSize.Load()
+		if cur > 0 && cur <= size {
+			return
+		}
+		if info.totalSize.CompareAndSwap(cur, size) {
+			return
+		}
+	}
+}
+
 // ErrStallTimeout is returned when a download stalls for too long without receiving data.
 var ErrStallTimeout = fmt.Errorf("download stalled: no data received")
 
@@ -183,8 +260,17 @@ func adaptiveStallTimeout(recentBytesPerSec float64, bufSize int) time.Duration
 	return timeout
 }
 
+// stallTimeout returns the configured part idle timeout, or the adaptive one
+// when none is set.
+func (e *TachyonEngine) stallTimeout(recentBytesPerSec float64, bufSize int) time.Duration {
+	if fixed := e.GetPartIdleTimeout(); fixed > 0 {
+		return fixed
+	}
+	return adaptiveStallTimeout(recentBytesPerSec, bufSize)
+}
+
 // downloadPart downloads a single part into its own temp file.
-func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr string, tempDir string, part DownloadPart, chunkSize int, headersStr string, cookiesStr string, strictRanges bool, downloadedBytes *int64, inflight *inflightTracker) error {
+func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr string, tempDir string, part DownloadPart, chunkSize int, headersStr string, cookiesStr string, strictRanges bool, downloadedBytes *int64, inflight *inflightTracker) (err error) {
 	req, err := e.newRequest("GET", urlStr, headersStr, cookiesStr)
 	if err != nil {
 		return err
@@ -195,6 +281,9 @@ func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr
 	}
 
 	resp, err := e.httpClient.Do(req)
+	if dl := debugLogFrom(ctx); dl.claimFirstPart() {
+		dl.recordExchange(fmt.Sprintf("part %d", part.ID), req, resp, err)
+	}
 	if err != nil {
 		return err
 	}
@@ -206,6 +295,21 @@ func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr
 		return ErrRangeIgnored
 	}
 
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && part.EndOffset != StreamEndOffset {
+		// Planned parts all start inside the probed file, so a server that
+		// says this one starts past the end has a shorter file now.
+		if size, crErr := parseUnsatisfiedRange(resp.Header.Get("Content-Range")); crErr == nil && size <= part.StartOffset {
+			// Opted in: take the server's word that the file ends right
+			// where this part starts, leaving nothing to fetch
+			if size == part.StartOffset && e.GetTreat416AsComplete() {
+				e.logger.Info("Part starts at the end of the file, nothing to fetch", "id", taskID, "part", part.ID, "size", size)
+				e.endFileAt(taskID, size)
+				return nil
+			}
+			return fmt.Errorf("%w: the server now reports %d bytes", ErrRemoteFileChanged, size)
+		}
+	}
+
 	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusForbidden {
 			return ErrLinkExpired
@@ -213,12 +317,43 @@ func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr
 		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
 
+	if part.EndOffset != StreamEndOffset {
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			// Writing another range's bytes at this part's offset would
+			// corrupt the file, so fail the part and let it be retried.
+			start, end, _, crErr := parseContentRange(resp.Header.Get("Content-Range"))
+			if crErr != nil {
+				return crErr
+			}
+			if start != part.StartOffset || end != part.EndOffset {
+				return fmt.Errorf("%w: asked for %d-%d, got %d-%d", ErrRangeMismatch, part.StartOffset, part.EndOffset, start, end)
+			}
+		case http.StatusOK:
+			// The whole file came back. Its head is this part only when the
+			// part starts at 0; the read below stops at the part's end.
+			if part.StartOffset != 0 {
+				return ErrRangeIgnored
+			}
+		}
+	}
+
+	transferred := e.transferCounter(taskID)
+
 	// Create temp file for this part
 	pw, err := newPartWriter(tempDir, taskID, part.StartOffset, downloadedBytes)
 	if err != nil {
 		return err
 	}
+	pw.timeWrites(&e.diskBusy)
 	defer pw.Close()
+	// A failed attempt is retried into a truncated part file, so its bytes
+	// no longer count towards the file (they stay in BytesTransferred).
+	defer func() {
+		if err != nil && ctx.Err() == nil {
+			atomic.AddInt64(downloadedBytes, -pw.Written())
+		}
+	}()
 
 	totalBytesToRead := part.EndOffset - part.StartOffset + 1
 	if part.EndOffset == StreamEndOffset {
@@ -264,7 +399,7 @@ func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr
 	defer stallTimer.Stop()
 
 	for bytesReadTotal < totalBytesToRead {
-		stall := adaptiveStallTimeout(recentSpeed, chunkSize)
+		stall := e.stallTimeout(recentSpeed, chunkSize)
 
 		if !stallTimer.Stop() {
 			select {
@@ -289,25 +424,31 @@ func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr
 		}
 
 		if len(rr.data) > 0 {
+			if transferred != nil {
+				transferred.Add(int64(len(rr.data)))
+			}
+			e.sessionBytes.Add(int64(len(rr.data)))
 			if err := e.bandwidthManager.Wait(ctx, taskID, len(rr.data)); err != nil {
 				return err
 			}
 
 			// Check if this part was stolen (EndOffset reduced by work-stealing).
-			// Only write up to the adjusted boundary to avoid overlap.
-			writeData := rr.data
 			if adj := inflight.AdjustedEnd(part.ID); adj >= 0 {
-				newLimit := adj - part.StartOffset + 1
-				if bytesReadTotal+int64(len(writeData)) > newLimit {
-					allowed := int(newLimit - bytesReadTotal)
-					if allowed <= 0 {
-						break // Nothing more to write for this part
-					}
-					writeData = writeData[:allowed]
+				totalBytesToRead = adj - part.StartOffset + 1
+			}
+			// Only write up to the part's boundary: a stolen tail belongs to
+			// another part, and a full-body 200 carries the rest of the file.
+			writeData := rr.data
+			if remaining := totalBytesToRead - bytesReadTotal; int64(len(writeData)) > remaining {
+				if remaining <= 0 {
+					break // Nothing more to write for this part
 				}
-				totalBytesToRead = newLimit
+				writeData = writeData[:remaining]
 			}
 
+			if err := e.diskThrottle.wait(ctx, tempDir, len(writeData)); err != nil {
+				return err
+			}
 			if writeErr := pw.Write(writeData); writeErr != nil {
 				return writeErr
 			}
@@ -332,19 +473,48 @@ func (e *TachyonEngine) downloadPart(ctx context.Context, taskID string, urlStr
 	return nil
 }
 
+// transferCounter returns the running task's received-bytes counter, or nil
+// when the task is not active.
+func (e *TachyonEngine) transferCounter(taskID string) *atomic.Int64 {
+	if v, ok := e.activeDownloads.Load(taskID); ok {
+		if info, ok := v.(*activeDownloadInfo); ok {
+			return &info.Transferred
+		}
+	}
+	return nil
+}
+
+// saveTransferred persists the task's BytesTransferred when its run ends,
+// whatever the outcome, so retries and abandoned attempts stay accounted for.
+func (e *TachyonEngine) saveTransferred(task *storage.DownloadTask, info *activeDownloadInfo) {
+	transferred := info.Transferred.Load()
+	task.BytesTransferred = transferred
+	e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
+		t.BytesTransferred = transferred
+	})
+}
+
 // failTask marks a task as failed
 func (e *TachyonEngine) failTask(task *storage.DownloadTask, reason string) {
 	e.logger.Error(fmt.Sprintf("Task Failed: %s", reason), "id", task.ID)
-	task.Status = "error"
+	e.recordEvent(EventDownloadFailed, task.ID, "Failed: %s", reason)
+	e.forgetVerifyRetries(task.ID)
+	task.Status = storage.StatusError
 	e.storage.SaveTaskAtomic(task.ID, func(t *storage.DownloadTask) {
-		t.Status = "error"
+		t.Status = storage.StatusError
+	})
+	e.emit("download:error", map[string]interface{}{
+		"id":    task.ID,
+		"error": reason,
 	})
-	if e.ctx != nil {
-		runtime.EventsEmit(e.ctx, "download:error", map[string]interface{}{
-			"id":    task.ID,
-			"error": reason,
-		})
+	e.playEventSound(platform.SoundError)
+	var startedAt time.Time
+	if v, ok := e.activeDownloads.Load(task.ID); ok {
+		if info, ok := v.(*activeDownloadInfo); ok {
+			startedAt = info.StartedAt
+		}
 	}
+	e.notifyBackends(task, startedAt, reason)
 }
 
 // loadState deserializes download state from MetaJSON
@@ -352,14 +522,21 @@ func (e *TachyonEngine) loadState(metaJSON string) (*storage.ResumeState, error)
 	return e.stateManager.Load(metaJSON)
 }
 
-// serializeState serializes download state to MetaJSON
-func (e *TachyonEngine) serializeState(task *storage.DownloadTask, completedParts map[int]bool, partPlan map[int]DownloadPart) string {
+// serializeState serializes download state to MetaJSON, along with the
+// probe it was planned from so a quick resume can reuse it.
+func (e *TachyonEngine) serializeState(task *storage.DownloadTask, probe *ProbeResult, probedAt time.Time, completedParts map[int]bool, partPlan map[int]DownloadPart) string {
 	state := &storage.ResumeState{
 		Version:      1,
-		ETag:         "",
-		LastModified: "",
+		ETag:         probe.ETag,
+		LastModified: probe.LastModified,
 		TotalSize:    task.TotalSize,
 		Parts:        make(map[int]storage.PartState),
+		AcceptRanges: probe.AcceptRanges,
+		ContentType:  probe.ContentType,
+		HTTP2:        probe.IsHTTP2,
+	}
+	if !probedAt.IsZero() {
+		state.ProbedAt = probedAt.Unix()
 	}
 
 	// Track completed parts
@@ -1,7 +1,19 @@
 package engine
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"project-tachyon/internal/storage"
 )
 
 func TestDownloadPartType(t *testing.T) {
@@ -42,3 +54,233 @@ func TestActiveDownloadInfo(t *testing.T) {
 		t.Error("Cancel function was not called")
 	}
 }
+
+// spawnFixedRangeServer answers every GET with status and the given
+// Content-Range, followed by body.
+func spawnFixedRangeServer(t *testing.T, status int, contentRange string, body []byte) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentRange != "" {
+			w.Header().Set("Content-Range", contentRange)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestDownloadPart_RejectsMismatchedContentRange(t *testing.T) {
+	body := generateDummyContent(100)
+	tests := []struct {
+		name         string
+		contentRange string
+		wantErr      error
+	}{
+		{"wrong start", "bytes 0-99/1000", ErrRangeMismatch},
+		{"short range", "bytes 100-149/1000", ErrRangeMismatch},
+		{"missing header", "", nil},
+		{"malformed header", "bytes 100-xyz/1000", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newLoopbackEngine(t)
+			url := spawnFixedRangeServer(t, http.StatusPartialContent, tt.contentRange, body)
+			part := DownloadPart{ID: 1, StartOffset: 100, EndOffset: 199}
+			var downloaded int64
+
+			err := e.downloadPart(context.Background(), "task", url, t.TempDir(), part, BufferSize, "", "", true, &downloaded, newInflightTracker())
+			if err == nil {
+				t.Fatal("expected the part to fail")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if downloaded != 0 {
+				t.Errorf("downloaded = %d, want nothing written", downloaded)
+			}
+		})
+	}
+}
+
+func TestDownloadPart_AcceptsMatchingContentRange(t *testing.T) {
+	e, _ := newLoopbackEngine(t)
+	url := spawnFixedRangeServer(t, http.StatusPartialContent, "bytes 100-199/1000", generateDummyContent(100))
+	var downloaded int64
+
+	part := DownloadPart{ID: 1, StartOffset: 100, EndOffset: 199}
+	if err := e.downloadPart(context.Background(), "task", url, t.TempDir(), part, BufferSize, "", "", true, &downloaded, newInflightTracker()); err != nil {
+		t.Fatalf("downloadPart: %v", err)
+	}
+	if downloaded != 100 {
+		t.Errorf("downloaded = %d, want 100", downloaded)
+	}
+}
+
+func TestDownloadPart_416PastEndOfFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentRange string
+		enabled      bool
+		wantErr      bool
+		wantChanged  bool
+	}{
+		{"ends at part start", "bytes */1000", false, true, true},
+		{"ends at part start, opted in", "bytes */1000", true, false, false},
+		{"file shorter", "bytes */900", false, true, true},
+		{"file shorter, opted in", "bytes */900", true, true, true},
+		{"no size", "", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newLoopbackEngine(t)
+			e.SetTreat416AsComplete(tt.enabled)
+			url := spawnFixedRangeServer(t, http.StatusRequestedRangeNotSatisfiable, tt.contentRange, nil)
+			part := DownloadPart{ID: 3, StartOffset: 1000, EndOffset: 1099}
+			var downloaded int64
+
+			err := e.downloadPart(context.Background(), "task", url, t.TempDir(), part, BufferSize, "", "", true, &downloaded, newInflightTracker())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if changed := errors.Is(err, ErrRemoteFileChanged); changed != tt.wantChanged {
+				t.Errorf("err = %v, want remote file changed %v", err, tt.wantChanged)
+			}
+			if downloaded != 0 {
+				t.Errorf("downloaded = %d, want nothing written", downloaded)
+			}
+		})
+	}
+}
+
+func TestDownloadPart_FullBodyForRangedRequest(t *testing.T) {
+	body := generateDummyContent(1000)
+	url := spawnFixedRangeServer(t, http.StatusOK, "", body)
+
+	// A part starting at 0 takes its bytes from the head of the full body.
+	e, _ := newLoopbackEngine(t)
+	var downloaded int64
+	part := DownloadPart{ID: 1, StartOffset: 0, EndOffset: 99}
+	if err := e.downloadPart(context.Background(), "task", url, t.TempDir(), part, BufferSize, "", "", false, &downloaded, newInflightTracker()); err != nil {
+		t.Fatalf("downloadPart: %v", err)
+	}
+	if downloaded != 100 {
+		t.Errorf("downloaded = %d, want 100", downloaded)
+	}
+
+	// Any other part would be written with the file's first bytes.
+	downloaded = 0
+	part = DownloadPart{ID: 2, StartOffset: 100, EndOffset: 199}
+	err := e.downloadPart(context.Background(), "task", url, t.TempDir(), part, BufferSize, "", "", false, &downloaded, newInflightTracker())
+	if !errors.Is(err, ErrRangeIgnored) {
+		t.Errorf("err = %v, want ErrRangeIgnored", err)
+	}
+	if downloaded != 0 {
+		t.Errorf("downloaded = %d, want nothing written", downloaded)
+	}
+}
+
+func TestDownload_RetriesMismatchedRange(t *testing.T) {
+	content := generateDummyContent(4 * 1024 * 1024)
+	var misled atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if r.Method == http.MethodHead || rng == "" {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			if r.Method != http.MethodHead {
+				w.Write(content)
+			}
+			return
+		}
+		first, last, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+		start, _ := strconv.Atoi(first)
+		end, _ := strconv.Atoi(last)
+		// The first request for a later part gets the file's head instead.
+		if start > 0 && misled.CompareAndSwap(false, true) {
+			start, end = 0, end-start
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start : end+1])
+	}))
+	defer server.Close()
+
+	e, _ := newLoopbackEngine(t)
+	id, err := e.StartDownload(server.URL+"/file.bin", t.TempDir(), "", map[string]string{"connections": "4"})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	task := waitForFinalStatus(t, e.storage, id)
+	if task.Status != storage.StatusCompleted {
+		t.Fatalf("status = %s, want completed", task.Status)
+	}
+	if !misled.Load() {
+		t.Fatal("server never returned a mismatched range")
+	}
+	got, err := os.ReadFile(task.SavePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded file does not match the served content")
+	}
+}
+
+func TestDownload_FullBodyToRangedRequestsFallsBack(t *testing.T) {
+	content := generateDummyContent(8 * 1024 * 1024)
+	// One connection takes part 0 from the head of the body and meets the
+	// 200 on part 1; four meet it on whichever later part comes first
+	for _, connections := range []string{"1", "4"} {
+		t.Run(connections, func(t *testing.T) {
+			var ranged, plain atomic.Int32
+			// The probe advertises ranges, but every GET sends the whole file
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Accept-Ranges", "bytes")
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				if r.Method == http.MethodHead {
+					return
+				}
+				if r.Header.Get("Range") != "" {
+					ranged.Add(1)
+				} else {
+					plain.Add(1)
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write(content)
+			}))
+			defer server.Close()
+
+			e, _ := newLoopbackEngine(t)
+			id, err := e.StartDownload(server.URL+"/file.bin", t.TempDir(), "", map[string]string{"connections": connections})
+			if err != nil {
+				t.Fatalf("StartDownload: %v", err)
+			}
+			task := waitTransferred(t, e, e.storage, id)
+			// Bytes thrown away by the ranged attempt (part 0 on one
+			// connection) still count
+			want := int64(len(content))
+			if connections == "1" {
+				want++
+			}
+			if task.BytesTransferred < want {
+				t.Errorf("BytesTransferred = %d, want at least %d", task.BytesTransferred, want)
+			}
+			if ranged.Load() == 0 || plain.Load() == 0 {
+				t.Errorf("ranged GETs = %d, plain GETs = %d; want the ranged attempt then a single-stream restart", ranged.Load(), plain.Load())
+			}
+			if !e.isHostSingleStream("127.0.0.1") {
+				t.Error("host not marked single-stream")
+			}
+			got, err := os.ReadFile(task.SavePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("downloaded file does not match the served content")
+			}
+		})
+	}
+}
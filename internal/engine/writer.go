@@ -8,7 +8,11 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"project-tachyon/internal/filesystem"
 )
 
 const partFileBufferSize = 1 * 1024 * 1024 // 1MB write buffer per part file
@@ -78,6 +82,63 @@ func (pw *partWriter) Write(data []byte) error {
 	return nil
 }
 
+// timeWrites records the time spent flushing to disk in busy. Call it
+// before the first Write.
+func (pw *partWriter) timeWrites(busy *busyMeter) {
+	pw.bw.Reset(&timedWriter{w: pw.file, busy: busy})
+}
+
+// timedWriter records the time spent in w.Write.
+type timedWriter struct {
+	w    io.Writer
+	busy *busyMeter
+}
+
+func (tw *timedWriter) Write(p []byte) (int, error) {
+	tw.busy.begin(time.Now())
+	n, err := tw.w.Write(p)
+	tw.busy.end(time.Now())
+	return n, err
+}
+
+// busyMeter measures wall-clock time during which at least one write is in
+// progress, so writers overlapping in time are not counted twice. The zero
+// value is ready to use.
+type busyMeter struct {
+	mu     sync.Mutex
+	active int
+	since  time.Time     // when active last rose from 0
+	busy   time.Duration // closed busy periods
+}
+
+func (m *busyMeter) begin(now time.Time) {
+	m.mu.Lock()
+	if m.active == 0 {
+		m.since = now
+	}
+	m.active++
+	m.mu.Unlock()
+}
+
+func (m *busyMeter) end(now time.Time) {
+	m.mu.Lock()
+	m.active--
+	if m.active == 0 {
+		m.busy += now.Sub(m.since)
+	}
+	m.mu.Unlock()
+}
+
+// total returns the busy time up to now, including a period still open.
+func (m *busyMeter) total(now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active > 0 {
+		return m.busy + now.Sub(m.since)
+	}
+	return m.busy
+}
+
 // Close flushes the buffer and closes the underlying file.
 func (pw *partWriter) Close() error {
 	if err := pw.bw.Flush(); err != nil {
@@ -115,7 +176,7 @@ func mergePartFiles(tempDir, taskID, destPath string) error {
 		return extractPartID(matches[i]) < extractPartID(matches[j])
 	})
 
-	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	dest, err := filesystem.OpenFileLocked(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return fmt.Errorf("failed to open destination: %w", err)
 	}
@@ -169,13 +230,17 @@ func cleanupOrphanedParts(tempDir, taskID string, plannedOffsets map[int64]bool)
 
 // partFileExists checks if a completed part file exists with expected size.
 func partFileExists(tempDir, taskID string, startOffset int64, expectedSize int64) bool {
+	return partFileSize(tempDir, taskID, startOffset) == expectedSize
+}
+
+// partFileSize returns the size of a part's temp file, or -1 if it is missing.
+func partFileSize(tempDir, taskID string, startOffset int64) int64 {
 	name := fmt.Sprintf("%s.part.%d", taskID, startOffset)
-	path := filepath.Join(tempDir, name)
-	info, err := os.Stat(path)
+	info, err := os.Stat(filepath.Join(tempDir, name))
 	if err != nil {
-		return false
+		return -1
 	}
-	return info.Size() == expectedSize
+	return info.Size()
 }
 
 // extractPartID parses the numeric part ID from a filename like "abc.part.7"
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestPartWriter_BasicWrite(t *testing.T) {
@@ -34,6 +35,53 @@ func TestPartWriter_BasicWrite(t *testing.T) {
 	}
 }
 
+func TestPartWriter_TimeWrites(t *testing.T) {
+	var downloaded int64
+	var busy busyMeter
+
+	pw, err := newPartWriter(t.TempDir(), "test-task", 0, &downloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.timeWrites(&busy)
+	if err := pw.Write([]byte("timed")); err != nil {
+		t.Fatal(err)
+	}
+	if busy.total(time.Now()) != 0 {
+		t.Error("buffered write should not count as disk time")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if busy.total(time.Now()) <= 0 {
+		t.Error("expected flush to record disk time")
+	}
+	content, _ := os.ReadFile(pw.Path())
+	if string(content) != "timed" {
+		t.Errorf("expected 'timed', got %q", content)
+	}
+}
+
+func TestBusyMeter_OverlappingWritesCountOnce(t *testing.T) {
+	var m busyMeter
+	t0 := time.Unix(1000, 0)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+
+	// Two writers over the same 100ms, then one alone for 50ms
+	m.begin(at(0))
+	m.begin(at(10))
+	m.end(at(90))
+	m.end(at(100))
+	m.begin(at(200))
+	if got := m.total(at(230)); got != 130*time.Millisecond {
+		t.Errorf("total with a write in progress = %v, want 130ms", got)
+	}
+	m.end(at(250))
+	if got := m.total(at(400)); got != 150*time.Millisecond {
+		t.Errorf("total = %v, want 150ms", got)
+	}
+}
+
 func TestPartWriter_MultipleWrites(t *testing.T) {
 	tmpDir := t.TempDir()
 	var downloaded int64
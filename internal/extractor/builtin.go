@@ -0,0 +1,155 @@
+package extractor
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"strings"
+
+	"project-tachyon/internal/filesystem"
+
+	"golang.org/x/net/html"
+)
+
+// Scores for the built-in extractors: an explicit download link beats an
+// embedded player, which beats page metadata, which beats a bare file link.
+const (
+	scoreDownloadAttr = 100
+	scoreVideo        = 80
+	scoreAudio        = 70
+	scoreOpenGraph    = 60
+	scoreFileLink     = 50
+)
+
+// MinAutoFollowScore is the lowest score strong enough to follow without
+// asking: an explicit download link, an embedded player or page metadata.
+// A bare link to a file of a known type, such as the logo on a login page,
+// is only offered.
+const MinAutoFollowScore = scoreOpenGraph
+
+// DownloadLinkExtractor finds <a download> links and links to files of a
+// known type (archives, installers, documents, media).
+type DownloadLinkExtractor struct{}
+
+func (DownloadLinkExtractor) Name() string { return "download-link" }
+
+func (DownloadLinkExtractor) Extract(pageURL *url.URL, page []byte) []Candidate {
+	var out []Candidate
+	walkTags(page, func(tag string, attrs map[string]string) {
+		if tag != "a" {
+			return
+		}
+		u := resolve(pageURL, attrs["href"])
+		if u == nil {
+			return
+		}
+		if name, ok := attrs["download"]; ok {
+			out = append(out, candidate(u, name, scoreDownloadAttr))
+			return
+		}
+		if filesystem.GetCategory(path.Base(u.Path)) != "Others" {
+			out = append(out, candidate(u, "", scoreFileLink))
+		}
+	})
+	return out
+}
+
+// MediaExtractor finds the sources of <video> and <audio> elements.
+type MediaExtractor struct{}
+
+func (MediaExtractor) Name() string { return "media" }
+
+func (MediaExtractor) Extract(pageURL *url.URL, page []byte) []Candidate {
+	var out []Candidate
+	score := 0 // score of the enclosing media element, for <source> children
+	walkTags(page, func(tag string, attrs map[string]string) {
+		switch tag {
+		case "video":
+			score = scoreVideo
+		case "audio":
+			score = scoreAudio
+		case "/video", "/audio":
+			score = 0
+			return
+		case "source":
+			if score == 0 {
+				return // <source> inside <picture>
+			}
+		default:
+			return
+		}
+		if u := resolve(pageURL, attrs["src"]); u != nil {
+			out = append(out, candidate(u, "", score))
+		}
+	})
+	return out
+}
+
+// OpenGraphExtractor reads og:video and og:audio meta tags.
+type OpenGraphExtractor struct{}
+
+func (OpenGraphExtractor) Name() string { return "opengraph" }
+
+func (OpenGraphExtractor) Extract(pageURL *url.URL, page []byte) []Candidate {
+	var out []Candidate
+	walkTags(page, func(tag string, attrs map[string]string) {
+		if tag != "meta" {
+			return
+		}
+		switch attrs["property"] {
+		case "og:video", "og:video:url", "og:video:secure_url", "og:audio", "og:audio:url", "og:audio:secure_url":
+			if u := resolve(pageURL, attrs["content"]); u != nil {
+				out = append(out, candidate(u, "", scoreOpenGraph))
+			}
+		}
+	})
+	return out
+}
+
+// walkTags calls fn for every tag in page with its lower-cased name and
+// attributes. End tags are reported as "/name" with no attributes.
+func walkTags(page []byte, fn func(tag string, attrs map[string]string)) {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := make(map[string]string)
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			fn(string(name), attrs)
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			fn("/"+string(name), nil)
+		}
+	}
+}
+
+// resolve turns ref into an absolute http(s) URL, or nil if it isn't one.
+func resolve(base *url.URL, ref string) *url.URL {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	u.Fragment = ""
+	return u
+}
+
+func candidate(u *url.URL, filename string, score int) Candidate {
+	if filename == "" {
+		filename = path.Base(u.Path)
+		if filename == "/" || filename == "." {
+			filename = ""
+		}
+	}
+	return Candidate{URL: u.String(), Filename: filename, Score: score}
+}
@@ -0,0 +1,82 @@
+// Package extractor finds direct download links in web pages, for when a
+// user pastes the URL of a hosting page rather than the file itself.
+package extractor
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Candidate is a download link found on a page.
+type Candidate struct {
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Source   string `json:"source"` // name of the extractor that found it
+	Score    int    `json:"score"`  // higher is more likely the intended download
+}
+
+// Extractor finds download candidates in an HTML page.
+type Extractor interface {
+	// Name identifies the extractor in logs and Candidate.Source
+	Name() string
+	// Extract returns the candidates in page, with links resolved against pageURL
+	Extract(pageURL *url.URL, page []byte) []Candidate
+}
+
+// Registry runs a set of extractors over a page and merges their results.
+type Registry struct {
+	mu         sync.RWMutex
+	extractors []Extractor
+}
+
+// NewRegistry creates a registry with the given extractors.
+func NewRegistry(extractors ...Extractor) *Registry {
+	return &Registry{extractors: extractors}
+}
+
+// NewDefaultRegistry creates a registry with the built-in extractors.
+func NewDefaultRegistry() *Registry {
+	return NewRegistry(DownloadLinkExtractor{}, MediaExtractor{}, OpenGraphExtractor{})
+}
+
+// Register adds an extractor. Later extractors lose ties to earlier ones.
+func (r *Registry) Register(x Extractor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extractors = append(r.extractors, x)
+}
+
+// Extract runs every extractor over page and returns the candidates best
+// first. A URL found by several extractors is listed once, at its best score.
+func (r *Registry) Extract(pageURL string, page []byte) ([]Candidate, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL: %w", err)
+	}
+
+	r.mu.RLock()
+	extractors := append([]Extractor(nil), r.extractors...)
+	r.mu.RUnlock()
+
+	var out []Candidate
+	index := make(map[string]int)
+	for _, x := range extractors {
+		for _, c := range x.Extract(base, page) {
+			if c.Source == "" {
+				c.Source = x.Name()
+			}
+			if i, ok := index[c.URL]; ok {
+				if c.Score > out[i].Score {
+					out[i] = c
+				}
+				continue
+			}
+			index[c.URL] = len(out)
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
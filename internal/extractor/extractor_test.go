@@ -0,0 +1,92 @@
+package extractor
+
+import (
+	"net/url"
+	"testing"
+)
+
+const hostingPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta property="og:video" content="https://cdn.example.com/media/trailer.mp4">
+  <title>Release 2.1</title>
+</head>
+<body>
+  <a href="/about">About</a>
+  <a href="#top">Top</a>
+  <a href="mailto:me@example.com">Mail</a>
+  <a href="files/notes.pdf">Release notes</a>
+  <a href="/dl/get?id=42" download="tool-2.1.zip">Download</a>
+  <video controls poster="/poster.jpg">
+    <source src="https://cdn.example.com/media/trailer.mp4" type="video/mp4">
+  </video>
+  <picture><source srcset="/hero.webp"><img src="/hero.png"></picture>
+</body>
+</html>`
+
+func TestRegistryExtract_RanksCandidates(t *testing.T) {
+	cands, err := NewDefaultRegistry().Extract("https://example.com/releases/2.1", []byte(hostingPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 3 {
+		t.Fatalf("expected 3 candidates, got %d: %+v", len(cands), cands)
+	}
+
+	best := cands[0]
+	if best.URL != "https://example.com/dl/get?id=42" {
+		t.Errorf("expected <a download> link first, got %s", best.URL)
+	}
+	if best.Filename != "tool-2.1.zip" {
+		t.Errorf("expected filename from download attribute, got %q", best.Filename)
+	}
+	if best.Source != "download-link" {
+		t.Errorf("expected source download-link, got %q", best.Source)
+	}
+
+	// The trailer is found by both the media and OpenGraph extractors but
+	// listed once, with the higher media score.
+	if cands[1].URL != "https://cdn.example.com/media/trailer.mp4" || cands[1].Source != "media" {
+		t.Errorf("expected video source second, got %+v", cands[1])
+	}
+	if cands[2].URL != "https://example.com/releases/files/notes.pdf" || cands[2].Filename != "notes.pdf" {
+		t.Errorf("expected relative file link resolved, got %+v", cands[2])
+	}
+}
+
+func TestRegistryExtract_NoLinks(t *testing.T) {
+	cands, err := NewDefaultRegistry().Extract("https://example.com/", []byte(`<p>nothing <a href="/home">here</a></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 0 {
+		t.Errorf("expected no candidates, got %+v", cands)
+	}
+}
+
+type fixedExtractor struct{ url string }
+
+func (fixedExtractor) Name() string { return "fixed" }
+
+func (f fixedExtractor) Extract(*url.URL, []byte) []Candidate {
+	return []Candidate{{URL: f.url, Score: 500}}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewDefaultRegistry()
+	r.Register(fixedExtractor{url: "https://mirror.example.com/file.iso"})
+
+	cands, err := r.Extract("https://example.com/", []byte(hostingPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cands[0].Source != "fixed" || cands[0].URL != "https://mirror.example.com/file.iso" {
+		t.Errorf("expected registered extractor's candidate first, got %+v", cands[0])
+	}
+}
+
+func TestRegistryExtract_InvalidPageURL(t *testing.T) {
+	if _, err := NewDefaultRegistry().Extract("://bad", nil); err == nil {
+		t.Error("expected error for invalid page URL")
+	}
+}
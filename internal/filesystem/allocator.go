@@ -38,6 +38,13 @@ func (a *Allocator) AllocateFile(path string, size int64) error {
 	return nil
 }
 
+// CheckDiskSpace verifies that the volume holding path can take another
+// required bytes (plus a safety buffer). The parent directory is created if
+// missing.
+func (a *Allocator) CheckDiskSpace(path string, required int64) error {
+	return a.checkDiskSpace(path, required)
+}
+
 func (a *Allocator) checkDiskSpace(path string, required int64) error {
 	dir := filepath.Dir(path)
 
@@ -0,0 +1,82 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockRetryDelays is the backoff between attempts when a file is held open
+// by another process. Antivirus scanners and indexers usually let go within
+// a second or two of a file being closed.
+var lockRetryDelays = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+}
+
+// FileLockedError reports a file operation that kept failing because another
+// process had the file open.
+type FileLockedError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *FileLockedError) Error() string {
+	return fmt.Sprintf("cannot %s %s: the file is in use by another program (an antivirus scan, media player or file indexer may be holding it); close it and try again", e.Op, e.Path)
+}
+
+func (e *FileLockedError) Unwrap() error { return e.Err }
+
+// IsFileLocked reports whether err means another process holds the file.
+func IsFileLocked(err error) bool {
+	var le *FileLockedError
+	return errors.As(err, &le) || isSharingViolation(err)
+}
+
+// retryLocked runs op, retrying with backoff while locked(err) holds. If the
+// file is still locked after the last attempt a *FileLockedError is returned.
+func retryLocked(op, path string, fn func() error, locked func(error) bool) error {
+	err := fn()
+	for _, delay := range lockRetryDelays {
+		if err == nil || !locked(err) {
+			return err
+		}
+		time.Sleep(delay)
+		err = fn()
+	}
+	if err != nil && locked(err) {
+		return &FileLockedError{Op: op, Path: path, Err: err}
+	}
+	return err
+}
+
+// RemoveFile deletes path, waiting out short-lived locks held by other
+// processes. A missing file is not an error.
+func RemoveFile(path string) error {
+	err := retryLocked("delete", path, func() error { return os.Remove(path) }, isSharingViolation)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// RenameFile renames src to dst, waiting out short-lived locks on either.
+func RenameFile(src, dst string) error {
+	return retryLocked("rename", src, func() error { return os.Rename(src, dst) }, isSharingViolation)
+}
+
+// OpenFileLocked is os.OpenFile that waits out short-lived locks.
+func OpenFileLocked(path string, flag int, perm os.FileMode) (*os.File, error) {
+	var f *os.File
+	err := retryLocked("open", path, func() error {
+		var err error
+		f, err = os.OpenFile(path, flag, perm)
+		return err
+	}, isSharingViolation)
+	return f, err
+}
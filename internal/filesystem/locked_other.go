@@ -0,0 +1,9 @@
+//go:build !windows
+
+package filesystem
+
+// isSharingViolation is always false outside Windows: open files can be
+// renamed and deleted freely.
+func isSharingViolation(err error) bool {
+	return false
+}
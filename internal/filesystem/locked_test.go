@@ -0,0 +1,100 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errFakeLock = errors.New("fake sharing violation")
+
+func fastLockRetries(t *testing.T) {
+	t.Helper()
+	old := lockRetryDelays
+	lockRetryDelays = []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+	t.Cleanup(func() { lockRetryDelays = old })
+}
+
+func TestRetryLocked_SucceedsOnceReleased(t *testing.T) {
+	fastLockRetries(t)
+	calls := 0
+	err := retryLocked("delete", "file.bin", func() error {
+		calls++
+		if calls < 3 {
+			return errFakeLock
+		}
+		return nil
+	}, func(err error) bool { return errors.Is(err, errFakeLock) })
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRetryLocked_GivesUpWithLockedError(t *testing.T) {
+	fastLockRetries(t)
+	calls := 0
+	err := retryLocked("rename", "file.bin", func() error {
+		calls++
+		return errFakeLock
+	}, func(err error) bool { return errors.Is(err, errFakeLock) })
+
+	var le *FileLockedError
+	if !errors.As(err, &le) {
+		t.Fatalf("expected FileLockedError, got %v", err)
+	}
+	if !errors.Is(err, errFakeLock) {
+		t.Error("FileLockedError should wrap the underlying error")
+	}
+	if !IsFileLocked(err) {
+		t.Error("IsFileLocked should report true")
+	}
+	if !strings.Contains(err.Error(), "in use by another program") {
+		t.Errorf("unhelpful message: %q", err.Error())
+	}
+	if calls != len(lockRetryDelays)+1 {
+		t.Errorf("expected %d attempts, got %d", len(lockRetryDelays)+1, calls)
+	}
+}
+
+func TestRetryLocked_OtherErrorsNotRetried(t *testing.T) {
+	fastLockRetries(t)
+	calls := 0
+	boom := errors.New("boom")
+	err := retryLocked("delete", "file.bin", func() error {
+		calls++
+		return boom
+	}, func(err error) bool { return errors.Is(err, errFakeLock) })
+	if err != boom {
+		t.Fatalf("expected original error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestRemoveFile_MissingIsNotError(t *testing.T) {
+	if err := RemoveFile(filepath.Join(t.TempDir(), "nope.bin")); err != nil {
+		t.Errorf("expected nil for missing file, got %v", err)
+	}
+}
+
+func TestRenameFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.bin")
+	dst := filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(src, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenameFile(src, dst); err != nil {
+		t.Fatalf("RenameFile failed: %v", err)
+	}
+	if _, err := os.Stat(dst); err != nil {
+		t.Errorf("destination missing: %v", err)
+	}
+}
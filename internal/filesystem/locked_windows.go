@@ -0,0 +1,16 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isSharingViolation reports whether err comes from another process holding
+// the file open without sharing (ERROR_SHARING_VIOLATION) or with a byte
+// range locked (ERROR_LOCK_VIOLATION).
+func isSharingViolation(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
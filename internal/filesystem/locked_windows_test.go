@@ -0,0 +1,80 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// holdExclusive opens path with no sharing, the way an antivirus scanner or
+// media player can, so other processes get ERROR_SHARING_VIOLATION.
+func holdExclusive(t *testing.T, path string) windows.Handle {
+	t.Helper()
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := windows.CreateFile(p, windows.GENERIC_READ, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	return h
+}
+
+func TestRemoveFile_LockedReportsFileLockedError(t *testing.T) {
+	fastLockRetries(t)
+	path := filepath.Join(t.TempDir(), "held.bin")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := holdExclusive(t, path)
+	defer windows.CloseHandle(h)
+
+	err := RemoveFile(path)
+	if !IsFileLocked(err) {
+		t.Fatalf("expected locked-file error, got %v", err)
+	}
+	if _, ok := err.(*FileLockedError); !ok {
+		t.Errorf("expected *FileLockedError, got %T", err)
+	}
+}
+
+func TestRemoveFile_SucceedsWhenHandleReleased(t *testing.T) {
+	old := lockRetryDelays
+	lockRetryDelays = []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	t.Cleanup(func() { lockRetryDelays = old })
+
+	path := filepath.Join(t.TempDir(), "held.bin")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := holdExclusive(t, path)
+	time.AfterFunc(120*time.Millisecond, func() { windows.CloseHandle(h) })
+
+	if err := RemoveFile(path); err != nil {
+		t.Fatalf("expected delete to succeed once released, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("file should be gone")
+	}
+}
+
+func TestRenameFile_LockedReportsFileLockedError(t *testing.T) {
+	fastLockRetries(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "held.bin")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := holdExclusive(t, src)
+	defer windows.CloseHandle(h)
+
+	if err := RenameFile(src, filepath.Join(dir, "moved.bin")); !IsFileLocked(err) {
+		t.Fatalf("expected locked-file error, got %v", err)
+	}
+}
@@ -2,15 +2,38 @@ package filesystem
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+var (
+	defaultDirMu sync.RWMutex
+	defaultDir   string
 )
 
-// GetDefaultDownloadPath returns the user's Downloads directory.
-// In test mode (TACHYON_DOWNLOAD_DIR set), returns the test directory instead.
+// SetDefaultDownloadPath makes GetDefaultDownloadPath return dir. An empty
+// dir goes back to the environment or the user's Downloads directory.
+func SetDefaultDownloadPath(dir string) {
+	defaultDirMu.Lock()
+	defaultDir = dir
+	defaultDirMu.Unlock()
+}
+
+// GetDefaultDownloadPath returns the folder set with SetDefaultDownloadPath,
+// else TACHYON_DOWNLOAD_DIR when set, else the user's Downloads directory.
 func GetDefaultDownloadPath() (string, error) {
+	defaultDirMu.RLock()
+	dir := defaultDir
+	defaultDirMu.RUnlock()
+	if dir != "" {
+		return dir, nil
+	}
 	if dir := os.Getenv("TACHYON_DOWNLOAD_DIR"); dir != "" {
 		return dir, nil
 	}
@@ -63,3 +86,60 @@ func OpenFolder(path string) error {
 
 	return cmd.Start()
 }
+
+// MoveFile moves src to dst. It tries a rename first and falls back to
+// copy-and-delete when the paths are on different volumes.
+func MoveFile(src, dst string) error {
+	err := RenameFile(src, dst)
+	if err == nil {
+		return nil
+	}
+	if IsFileLocked(err) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	in.Close()
+	return RemoveFile(src)
+}
+
+// DiskSpace returns the free and total bytes of the volume holding dir. It
+// fails if dir is not an existing directory, e.g. on an unplugged drive.
+func DiskSpace(dir string) (free, total uint64, err error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !info.IsDir() {
+		return 0, 0, fmt.Errorf("%s is not a directory", dir)
+	}
+	usage, err := disk.Usage(dir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check disk space: %w", err)
+	}
+	return usage.Free, usage.Total, nil
+}
@@ -29,6 +29,24 @@ func TestGetDefaultDownloadPath_Format(t *testing.T) {
 	}
 }
 
+func TestGetDefaultDownloadPath_Precedence(t *testing.T) {
+	t.Setenv("TACHYON_DOWNLOAD_DIR", "/env/downloads")
+	if path, _ := GetDefaultDownloadPath(); path != "/env/downloads" {
+		t.Errorf("with TACHYON_DOWNLOAD_DIR set, path = %q", path)
+	}
+
+	SetDefaultDownloadPath("/configured")
+	defer SetDefaultDownloadPath("")
+	if path, _ := GetDefaultDownloadPath(); path != "/configured" {
+		t.Errorf("with a configured folder, path = %q", path)
+	}
+
+	SetDefaultDownloadPath("")
+	if path, _ := GetDefaultDownloadPath(); path != "/env/downloads" {
+		t.Errorf("after clearing the configured folder, path = %q", path)
+	}
+}
+
 func TestOpenFile_UnsupportedPlatform(t *testing.T) {
 	// We can test the function doesn't panic with a nonexistent file
 	// The actual open will fail gracefully via cmd.Start() error
@@ -59,3 +77,50 @@ func TestOpenFolder_ErrorOnRelativePath(t *testing.T) {
 	// Should not panic — may fail with explorer but that's OK
 	_ = err
 }
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.bin")
+	dst := filepath.Join(dir, "sub", "dst.bin")
+	if err := os.WriteFile(src, []byte("payload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(dst), 0755)
+
+	if err := MoveFile(src, dst); err != nil {
+		t.Fatalf("MoveFile failed: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("source should be gone after move")
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil || string(data) != "payload" {
+		t.Errorf("destination content = %q, err = %v", data, err)
+	}
+}
+
+func TestMoveFile_MissingSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := MoveFile(filepath.Join(dir, "nope"), filepath.Join(dir, "dst")); err == nil {
+		t.Error("expected error for missing source")
+	}
+}
+
+func TestDiskSpace(t *testing.T) {
+	free, total, err := DiskSpace(t.TempDir())
+	if err != nil {
+		t.Fatalf("DiskSpace: %v", err)
+	}
+	if total == 0 || free > total {
+		t.Errorf("free %d of %d bytes", free, total)
+	}
+
+	if _, _, err := DiskSpace(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+	file := filepath.Join(t.TempDir(), "file.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+	if _, _, err := DiskSpace(file); err == nil {
+		t.Error("expected an error for a file")
+	}
+}
@@ -3,11 +3,15 @@ package integrity
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"strings"
 )
 
 // FileVerifier handles file integrity checks
@@ -17,45 +21,102 @@ func NewFileVerifier() *FileVerifier {
 	return &FileVerifier{}
 }
 
-// Verify checks if the file at path matches the expected hash
+// Verify checks if the file at path matches the expected hash. The expected
+// value may carry surrounding whitespace, upper-case digits or an
+// "algo:" prefix. When algo is empty it is taken from the prefix or guessed
+// from the hash length.
 func (v *FileVerifier) Verify(path string, algo string, expected string) error {
+	prefixAlgo, want := NormalizeHash(expected)
+	algo = normalizeAlgorithm(algo)
+	if algo == "" {
+		algo = prefixAlgo
+	}
+	if algo == "" {
+		algo = DetectAlgorithm(want)
+	}
+	if algo == "" {
+		return fmt.Errorf("cannot tell the hash algorithm of %q", expected)
+	}
+
 	actual, err := CalculateHash(path, algo)
 	if err != nil {
 		return err
 	}
 
-	if actual != expected {
-		return fmt.Errorf("hash mismatch: expected %s, got %s", expected, actual)
+	if actual != want {
+		return fmt.Errorf("hash mismatch: expected %s, got %s", want, actual)
+	}
+
+	return nil
+}
+
+// NormalizeHash trims and lower-cases a pasted hash and strips an algorithm
+// prefix such as "sha256:" or "SHA-256=". It returns the prefix's algorithm
+// ("" if there was none) and the bare hex digest.
+func NormalizeHash(s string) (algo, digest string) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(s, ":="); i > 0 {
+		if a := normalizeAlgorithm(s[:i]); newHasher(a) != nil {
+			algo, s = a, strings.TrimSpace(s[i+1:])
+		}
+	}
+	return algo, s
+}
+
+// DetectAlgorithm guesses the algorithm of a hex digest from its length,
+// returning "" when the length matches none of the supported algorithms.
+func DetectAlgorithm(digest string) string {
+	if _, err := hex.DecodeString(digest); err != nil {
+		return ""
 	}
+	switch len(digest) {
+	case 32:
+		return "md5"
+	case 40:
+		return "sha1"
+	case 64:
+		return "sha256"
+	case 128:
+		return "sha512"
+	}
+	return ""
+}
+
+// normalizeAlgorithm maps spellings like "SHA-256" to "sha256".
+func normalizeAlgorithm(algo string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(algo)), "-", "")
+}
 
+func newHasher(algo string) hash.Hash {
+	switch algo {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	}
 	return nil
 }
 
 // CalculateHash computes the hash of a file
-// algorithm should be "sha256" or "md5"
+// algorithm should be "md5", "sha1", "sha256" or "sha512"
 func CalculateHash(filePath string, algorithm string) (string, error) {
+	hasher := newHasher(normalizeAlgorithm(algorithm))
+	if hasher == nil {
+		return "", fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	var hash string
-	if algorithm == "sha256" {
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return "", err
-		}
-		hash = hex.EncodeToString(hasher.Sum(nil))
-	} else if algorithm == "md5" {
-		hasher := md5.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return "", err
-		}
-		hash = hex.EncodeToString(hasher.Sum(nil))
-	} else {
-		return "", fmt.Errorf("unsupported algorithm: %s", algorithm)
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
 	}
-
-	return hash, nil
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
@@ -2,9 +2,13 @@ package integrity
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -65,3 +69,89 @@ func TestVerifier_MismatchDetection(t *testing.T) {
 		t.Error("Expected error for mismatching hash, got nil")
 	}
 }
+
+func writeHashFile(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifier_TolerantComparison(t *testing.T) {
+	content := []byte("hello world")
+	path := writeHashFile(t, content)
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	v := NewFileVerifier()
+	for _, expected := range []string{
+		digest,
+		strings.ToUpper(digest),
+		"  " + digest + "\n",
+		"sha256:" + digest,
+		"SHA256:" + strings.ToUpper(digest),
+		"SHA-256=" + digest,
+		"\tsha256: " + digest + " ",
+	} {
+		if err := v.Verify(path, "sha256", expected); err != nil {
+			t.Errorf("Verify(%q): %v", expected, err)
+		}
+		if err := v.Verify(path, "", expected); err != nil {
+			t.Errorf("Verify(%q) without algorithm: %v", expected, err)
+		}
+	}
+	if err := v.Verify(path, "SHA-256", digest); err != nil {
+		t.Errorf("Verify with algorithm spelled SHA-256: %v", err)
+	}
+}
+
+func TestVerifier_DetectsAlgorithmFromLength(t *testing.T) {
+	content := []byte("hello world")
+	path := writeHashFile(t, content)
+
+	md5Sum := md5.Sum(content)
+	sha1Sum := sha1.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	sha512Sum := sha512.Sum512(content)
+
+	v := NewFileVerifier()
+	for algo, digest := range map[string]string{
+		"md5":    hex.EncodeToString(md5Sum[:]),
+		"sha1":   hex.EncodeToString(sha1Sum[:]),
+		"sha256": hex.EncodeToString(sha256Sum[:]),
+		"sha512": hex.EncodeToString(sha512Sum[:]),
+	} {
+		if got := DetectAlgorithm(digest); got != algo {
+			t.Errorf("DetectAlgorithm(%d hex chars) = %q, want %q", len(digest), got, algo)
+		}
+		if err := v.Verify(path, "", strings.ToUpper(digest)); err != nil {
+			t.Errorf("Verify(%s) without algorithm: %v", algo, err)
+		}
+	}
+
+	if got := DetectAlgorithm("not-a-hash"); got != "" {
+		t.Errorf("DetectAlgorithm on garbage = %q, want empty", got)
+	}
+	if err := v.Verify(path, "", "abc123"); err == nil {
+		t.Error("expected an error for a hash of unknown length")
+	}
+}
+
+func TestNormalizeHash(t *testing.T) {
+	tests := []struct {
+		in, algo, digest string
+	}{
+		{" ABCDEF ", "", "abcdef"},
+		{"md5:ABCDEF", "md5", "abcdef"},
+		{"SHA-512= abc", "sha512", "abc"},
+		{"blake3:abc", "", "blake3:abc"},
+	}
+	for _, tt := range tests {
+		algo, digest := NormalizeHash(tt.in)
+		if algo != tt.algo || digest != tt.digest {
+			t.Errorf("NormalizeHash(%q) = %q, %q; want %q, %q", tt.in, algo, digest, tt.algo, tt.digest)
+		}
+	}
+}
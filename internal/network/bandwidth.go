@@ -4,15 +4,55 @@ package network
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
+const (
+	// rateWindow is how often the achieved rate is compared to the limit.
+	rateWindow = 2 * time.Second
+
+	// refillGain is the share of the gap between the achieved rate and the
+	// limit corrected per window. Below 1 the refill approaches its steady
+	// value without overshooting it.
+	refillGain = 0.5
+
+	// refillDeadband is the relative error tolerated without adjustment,
+	// so measurement noise does not keep nudging the refill.
+	refillDeadband = 0.03
+
+	// maxRefillBoost bounds the refill to this multiple of the limit (and
+	// its inverse), so one bad window cannot run the rate away.
+	maxRefillBoost = 2.0
+
+	// throttledShare is the fraction of a window's Wait calls that must
+	// have been held back for the limiter to count as the bottleneck.
+	throttledShare = 0.5
+)
+
 // BandwidthManager handles global speed limiting for concurrent downloads.
+//
+// The token bucket alone tends to undershoot: tokens that pile up while
+// readers are stalled on round trips or disk writes spill over the burst,
+// and bytes read for retried or stolen parts are paid for but never land.
+// So downloads report the progress they actually make (see Observe) and,
+// while the limiter is the bottleneck, the bucket's refill rate is tuned
+// until that aggregate rate matches the limit.
 type BandwidthManager struct {
 	globalLimiter *rate.Limiter
 	limitEnabled  atomic.Bool
+
+	mu          sync.Mutex
+	target      float64 // configured limit, bytes/sec
+	refill      float64 // current bucket refill rate, bytes/sec
+	windowStart time.Time
+	warm        bool  // the first window after SetLimit has been discarded
+	bytes       int64 // progress observed this window
+	calls       int   // Wait calls this window
+	held        int   // Wait calls this window that had to wait for tokens
 }
 
 // NewBandwidthManager creates a new bandwidth manager with no limits
@@ -25,21 +65,122 @@ func NewBandwidthManager() *BandwidthManager {
 // SetLimit updates the global speed limit in bytes per second.
 // 0 means unlimited.
 func (bm *BandwidthManager) SetLimit(bytesPerSec int) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.resetWindow(time.Now())
+	bm.warm = false
 	if bytesPerSec <= 0 {
 		bm.limitEnabled.Store(false)
+		bm.target, bm.refill = 0, 0
 		bm.globalLimiter.SetLimit(rate.Inf)
 	} else {
 		bm.limitEnabled.Store(true)
+		bm.target, bm.refill = float64(bytesPerSec), float64(bytesPerSec)
 		bm.globalLimiter.SetLimit(rate.Limit(bytesPerSec))
 		bm.globalLimiter.SetBurst(bytesPerSec)
 	}
 }
 
+// Limit returns the global speed limit in bytes per second (0 = unlimited).
+func (bm *BandwidthManager) Limit() int {
+	if !bm.limitEnabled.Load() {
+		return 0
+	}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return int(bm.target)
+}
+
+// Refill returns the rate the token bucket currently refills at, in bytes
+// per second (0 = unlimited). It differs from Limit while the manager is
+// compensating for an achieved rate below or above the limit.
+func (bm *BandwidthManager) Refill() int {
+	if !bm.limitEnabled.Load() {
+		return 0
+	}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return int(bm.refill)
+}
+
 // Wait blocks until the requested bytes can be consumed under the global
 // rate limit.  Returns immediately when no limit is configured.
 func (bm *BandwidthManager) Wait(ctx context.Context, taskID string, bytes int) error {
-	if bm.limitEnabled.Load() {
-		return bm.globalLimiter.WaitN(ctx, bytes)
+	if !bm.limitEnabled.Load() {
+		return nil
+	}
+	start := time.Now()
+	if err := bm.globalLimiter.WaitN(ctx, bytes); err != nil {
+		return err
+	}
+	held := time.Since(start) > time.Millisecond
+	bm.mu.Lock()
+	bm.calls++
+	if held {
+		bm.held++
 	}
+	bm.mu.Unlock()
 	return nil
 }
+
+// Observe adds bytes of progress made by a download (negative when a
+// failed attempt's bytes are discarded). Once a window has passed, the
+// aggregate rate is compared with the limit and the refill retuned.
+func (bm *BandwidthManager) Observe(bytes int64) {
+	if !bm.limitEnabled.Load() {
+		return
+	}
+	bm.observe(time.Now(), bytes)
+}
+
+func (bm *BandwidthManager) observe(now time.Time, bytes int64) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.target <= 0 {
+		return
+	}
+	bm.bytes += bytes
+	elapsed := now.Sub(bm.windowStart)
+	if elapsed < rateWindow {
+		return
+	}
+	if !bm.warm {
+		// The full bucket handed out at the start skews the first window
+		bm.warm = true
+		bm.resetWindow(now)
+		return
+	}
+	achieved := float64(bm.bytes) / elapsed.Seconds()
+	throttled := bm.calls > 0 && float64(bm.held) >= float64(bm.calls)*throttledShare
+	next := nextRefill(bm.refill, bm.target, achieved, throttled)
+	if next != bm.refill {
+		bm.refill = next
+		bm.globalLimiter.SetLimit(rate.Limit(next))
+	}
+	bm.resetWindow(now)
+}
+
+func (bm *BandwidthManager) resetWindow(now time.Time) {
+	bm.windowStart = now
+	bm.bytes, bm.calls, bm.held = 0, 0, 0
+}
+
+// nextRefill returns the refill rate that follows refill after a window in
+// which achieved bytes/sec got through against a limit of target. While
+// the limiter was the bottleneck (throttled) the refill is scaled by part
+// of the achieved-to-target error; otherwise demand was below the limit,
+// which says nothing about the bucket, and the refill eases back to target.
+func nextRefill(refill, target, achieved float64, throttled bool) float64 {
+	if target <= 0 {
+		return refill
+	}
+	next := refill + (target-refill)*refillGain
+	if throttled && achieved > 0 {
+		if err := (target - achieved) / target; err > refillDeadband || err < -refillDeadband {
+			next = refill * (1 + (target/achieved-1)*refillGain)
+		} else {
+			next = refill
+		}
+	}
+	return min(max(next, target/maxRefillBoost), target*maxRefillBoost)
+}
@@ -2,6 +2,7 @@ package network
 
 import (
 	"context"
+	"math"
 	"testing"
 	"time"
 )
@@ -29,11 +30,17 @@ func TestBandwidthManager_SetLimitEnablesThrottle(t *testing.T) {
 	if !bm.limitEnabled.Load() {
 		t.Fatal("expected limit to be enabled")
 	}
+	if bm.Limit() != 1024 {
+		t.Fatalf("Limit() = %d, want 1024", bm.Limit())
+	}
 
 	bm.SetLimit(0) // Disable
 	if bm.limitEnabled.Load() {
 		t.Fatal("expected limit to be disabled after SetLimit(0)")
 	}
+	if bm.Limit() != 0 {
+		t.Fatalf("Limit() = %d, want 0 when unlimited", bm.Limit())
+	}
 }
 
 func TestBandwidthManager_ContextCancellation(t *testing.T) {
@@ -49,3 +56,89 @@ func TestBandwidthManager_ContextCancellation(t *testing.T) {
 		t.Fatal("expected error from cancelled context")
 	}
 }
+
+func TestNextRefill_ConvergesWithoutOscillation(t *testing.T) {
+	const target = 1 << 20
+	// A plant that only gets 70% of the refill through, e.g. tokens spilled
+	// while readers wait on round trips
+	refill := float64(target)
+	lastErr := math.Inf(1)
+	for i := 0; i < 20; i++ {
+		achieved := refill * 0.7
+		refill = nextRefill(refill, target, achieved, true)
+		err := math.Abs(target - refill*0.7)
+		if err > lastErr {
+			t.Fatalf("window %d: error grew from %.0f to %.0f", i, lastErr, err)
+		}
+		lastErr = err
+	}
+	if got := refill * 0.7; math.Abs(got-target)/target > refillDeadband {
+		t.Errorf("achieved %.0f B/s after 20 windows, want ~%d", got, target)
+	}
+
+	// Inside the deadband nothing moves
+	if got := nextRefill(refill, target, target*0.99, true); got != refill {
+		t.Errorf("refill moved inside the deadband: %.0f -> %.0f", refill, got)
+	}
+}
+
+func TestNextRefill_Bounds(t *testing.T) {
+	const target = 1000.0
+	// Demand below the limit eases the refill back instead of boosting it
+	if got := nextRefill(1800, target, 100, false); got != 1400 {
+		t.Errorf("unthrottled refill = %.0f, want 1400", got)
+	}
+	if got := nextRefill(1900, target, 10, true); got != target*maxRefillBoost {
+		t.Errorf("boost = %.0f, want clamp at %.0f", got, target*maxRefillBoost)
+	}
+	if got := nextRefill(600, target, 50000, true); got != target/maxRefillBoost {
+		t.Errorf("cut = %.0f, want clamp at %.0f", got, target/maxRefillBoost)
+	}
+}
+
+func TestBandwidthManager_RefillResetBySetLimit(t *testing.T) {
+	bm := NewBandwidthManager()
+	bm.SetLimit(1000)
+	bm.mu.Lock()
+	bm.refill = 1500
+	bm.mu.Unlock()
+	bm.SetLimit(2000)
+	if got := bm.Refill(); got != 2000 {
+		t.Errorf("Refill() = %d after SetLimit, want 2000", got)
+	}
+	if got := bm.Limit(); got != 2000 {
+		t.Errorf("Limit() = %d, want 2000", got)
+	}
+}
+
+func TestBandwidthManager_ObserveRetunesRefill(t *testing.T) {
+	bm := NewBandwidthManager()
+	bm.SetLimit(1000)
+	start := bm.windowStart
+
+	// Every Wait held back, but only 600 B/s of progress lands
+	window := func(n int, progress int64) {
+		bm.mu.Lock()
+		bm.calls, bm.held = 10, 10
+		bm.mu.Unlock()
+		bm.observe(start.Add(time.Duration(n)*rateWindow), progress)
+	}
+	window(1, 10000) // warm-up window, discarded
+	if got := bm.Refill(); got != 1000 {
+		t.Fatalf("refill = %d after warm-up, want 1000", got)
+	}
+	window(2, 1200)
+	if got := bm.Refill(); got <= 1000 {
+		t.Fatalf("refill = %d after undershoot, want above 1000", got)
+	}
+	if got := bm.Limit(); got != 1000 {
+		t.Errorf("Limit() = %d, want the configured 1000", got)
+	}
+
+	// Progress without throttling eases the refill back towards the limit
+	boosted := bm.Refill()
+	bm.observe(start.Add(3*rateWindow), 2000)
+	if got := bm.Refill(); got >= boosted || got < 1000 {
+		t.Errorf("refill = %d after unthrottled window, want between 1000 and %d", got, boosted)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// errorRateAlpha weights the newest outcome in a host's decaying error rate.
+const errorRateAlpha = 0.1
+
 // CongestionController implements an AIMD (Additive Increase, Multiplicative Decrease) algorithm
 // to dynamically scale worker concurrency based on network conditions.
 type CongestionController struct {
@@ -19,7 +22,7 @@ type CongestionController struct {
 type HostStats struct {
 	LastRTT      time.Duration
 	SmoothedRTT  time.Duration // SRTT
-	ErrorRate    float64       // Errors per minute (decaying)
+	ErrorRate    float64       // Share of recent outcomes that failed (decaying)
 	Concurrency  int
 	LastUpdate   time.Time
 	SuccessCount int
@@ -56,11 +59,14 @@ func (cc *CongestionController) RecordOutcome(host string, latency time.Duration
 	stats.LastRTT = latency
 	stats.LastUpdate = time.Now()
 
+	failed := 0.0
 	if err != nil {
 		stats.ErrorCount++
+		failed = 1
 	} else {
 		stats.SuccessCount++
 	}
+	stats.ErrorRate = (1-errorRateAlpha)*stats.ErrorRate + errorRateAlpha*failed
 }
 
 // GetIdealConcurrency calculates the target worker count using AIMD logic
@@ -96,6 +102,66 @@ func (cc *CongestionController) GetIdealConcurrency(host string) int {
 	return stats.Concurrency
 }
 
+// SeedConcurrency sets the starting concurrency for a host, e.g. from a
+// throughput test, so its next download skips slow start. AIMD continues to
+// adjust from there.
+func (cc *CongestionController) SeedConcurrency(host string, n int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if n < cc.minWorkers {
+		n = cc.minWorkers
+	}
+	if n > cc.maxWorkers {
+		n = cc.maxWorkers
+	}
+	stats, ok := cc.hosts[host]
+	if !ok {
+		stats = &HostStats{SmoothedRTT: cc.baseRTT}
+		cc.hosts[host] = stats
+	}
+	stats.Concurrency = n
+	stats.SuccessCount = 0
+	stats.ErrorCount = 0
+	stats.LastUpdate = time.Now()
+}
+
+// RestoreHost seeds a host not seen yet this session from a saved profile,
+// so its first download starts at the concurrency it settled on before
+// rather than at the minimum. Hosts already being tracked are left alone.
+func (cc *CongestionController) RestoreHost(host string, concurrency int, rtt time.Duration, errorRate float64) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, ok := cc.hosts[host]; ok {
+		return
+	}
+	concurrency = min(max(concurrency, cc.minWorkers), cc.maxWorkers)
+	if rtt <= 0 {
+		rtt = cc.baseRTT
+	}
+	cc.hosts[host] = &HostStats{
+		Concurrency: concurrency,
+		SmoothedRTT: rtt,
+		LastRTT:     rtt,
+		ErrorRate:   min(max(errorRate, 0), 1),
+	}
+}
+
+// SetMaxWorkers changes the upper bound, pulling any host already above it
+// down to the new limit.
+func (cc *CongestionController) SetMaxWorkers(max int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.maxWorkers = max
+	for _, stats := range cc.hosts {
+		if stats.Concurrency > max {
+			stats.Concurrency = max
+		}
+	}
+}
+
 // GetHostStats returns a copy of stats for a host (for testing/monitoring)
 func (cc *CongestionController) GetHostStats(host string) *HostStats {
 	cc.mu.RLock()
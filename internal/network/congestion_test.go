@@ -74,8 +74,81 @@ func TestCongestionController_GetHostStats(t *testing.T) {
 	}
 }
 
+func TestCongestionController_SeedConcurrency(t *testing.T) {
+	cc := NewCongestionController(2, 8)
+
+	cc.SeedConcurrency("seeded.com", 6)
+	if got := cc.GetIdealConcurrency("seeded.com"); got != 6 {
+		t.Fatalf("expected seeded concurrency 6, got %d", got)
+	}
+
+	cc.SeedConcurrency("seeded.com", 100)
+	if got := cc.GetIdealConcurrency("seeded.com"); got != 8 {
+		t.Fatalf("expected seed clamped to max 8, got %d", got)
+	}
+	cc.SeedConcurrency("seeded.com", 0)
+	if got := cc.GetIdealConcurrency("seeded.com"); got != 2 {
+		t.Fatalf("expected seed clamped to min 2, got %d", got)
+	}
+}
+
+func TestCongestionController_SetMaxWorkers(t *testing.T) {
+	cc := NewCongestionController(2, 8)
+	cc.SeedConcurrency("a.com", 8)
+
+	cc.SetMaxWorkers(4)
+	if got := cc.GetIdealConcurrency("a.com"); got != 4 {
+		t.Fatalf("expected concurrency pulled down to 4, got %d", got)
+	}
+	cc.SetMaxWorkers(16)
+	cc.SeedConcurrency("a.com", 12)
+	if got := cc.GetIdealConcurrency("a.com"); got != 12 {
+		t.Fatalf("expected raised limit to allow 12, got %d", got)
+	}
+}
+
 var errTestSentinel = errForTest("test error")
 
 type errForTest string
 
 func (e errForTest) Error() string { return string(e) }
+
+func TestCongestionController_RestoreHost(t *testing.T) {
+	cc := NewCongestionController(4, 24)
+	cc.RestoreHost("known.com", 11, 80*time.Millisecond, 0.2)
+	if got := cc.GetIdealConcurrency("known.com"); got != 11 {
+		t.Fatalf("restored host starts at %d, want 11", got)
+	}
+	stats := cc.GetHostStats("known.com")
+	if stats.SmoothedRTT != 80*time.Millisecond || stats.ErrorRate != 0.2 {
+		t.Errorf("restored stats = %+v", stats)
+	}
+
+	// Out-of-range profiles are clamped to the controller's bounds
+	cc.RestoreHost("greedy.com", 100, 0, 3)
+	if stats := cc.GetHostStats("greedy.com"); stats.Concurrency != 24 || stats.ErrorRate != 1 {
+		t.Errorf("clamped stats = %+v", stats)
+	}
+
+	// Live state wins over a stale profile
+	cc.RecordOutcome("live.com", 10*time.Millisecond, nil)
+	cc.RestoreHost("live.com", 20, time.Second, 0)
+	if stats := cc.GetHostStats("live.com"); stats.Concurrency == 20 {
+		t.Error("RestoreHost overwrote a host already being tracked")
+	}
+}
+
+func TestCongestionController_ErrorRateDecays(t *testing.T) {
+	cc := NewCongestionController(4, 24)
+	cc.RecordOutcome("flaky.com", 10*time.Millisecond, errTestSentinel)
+	high := cc.GetHostStats("flaky.com").ErrorRate
+	if high <= 0 {
+		t.Fatalf("error rate after a failure = %v, want > 0", high)
+	}
+	for i := 0; i < 10; i++ {
+		cc.RecordOutcome("flaky.com", 10*time.Millisecond, nil)
+	}
+	if low := cc.GetHostStats("flaky.com").ErrorRate; low >= high {
+		t.Errorf("error rate did not decay: %v -> %v", high, low)
+	}
+}
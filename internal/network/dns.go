@@ -10,9 +10,16 @@ import (
 // DNSCache provides a thread-safe local DNS cache to avoid redundant lookups
 // during multi-part downloads to the same host.
 type DNSCache struct {
-	mu      sync.RWMutex
-	entries map[string]*dnsEntry
-	ttl     time.Duration
+	mu       sync.RWMutex
+	entries  map[string]*dnsEntry
+	ttl      time.Duration
+	resolver HostResolver // nil = system DNS
+}
+
+// HostResolver looks up the addresses of a host. *net.Resolver and
+// *DoHResolver implement it.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
 }
 
 type dnsEntry struct {
@@ -47,7 +54,7 @@ func (c *DNSCache) DialContext(timeout, keepAlive time.Duration) func(ctx contex
 		}
 
 		// Resolve and cache
-		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		addrs, err := c.lookup(ctx, host)
 		if err != nil || len(addrs) == 0 {
 			// Fall through to normal dial on lookup failure
 			return dialer.DialContext(ctx, netw, addr)
@@ -58,6 +65,35 @@ func (c *DNSCache) DialContext(timeout, keepAlive time.Duration) func(ctx contex
 	}
 }
 
+// SetResolver routes lookups through r, falling back to system DNS when it
+// fails. nil restores plain system DNS. Cached entries are dropped so the
+// new resolver takes effect immediately.
+func (c *DNSCache) SetResolver(r HostResolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolver = r
+	c.entries = make(map[string]*dnsEntry)
+}
+
+// Flush drops every cached entry so the next dial resolves afresh.
+func (c *DNSCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*dnsEntry)
+}
+
+func (c *DNSCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.RLock()
+	r := c.resolver
+	c.mu.RUnlock()
+	if r != nil {
+		if addrs, err := r.LookupHost(ctx, host); err == nil && len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
 func (c *DNSCache) get(host string) string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
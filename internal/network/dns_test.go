@@ -124,3 +124,18 @@ func TestNewDNSCache(t *testing.T) {
 		t.Error("entries map should be initialized")
 	}
 }
+
+func TestDNSCache_Flush(t *testing.T) {
+	cache := NewDNSCache(5 * time.Minute)
+	cache.put("example.com", []string{"1.2.3.4"})
+	cache.put("example.org", []string{"5.6.7.8"})
+
+	cache.Flush()
+
+	if ip := cache.get("example.com"); ip != "" {
+		t.Errorf("expected empty after flush, got %s", ip)
+	}
+	if ip := cache.get("example.org"); ip != "" {
+		t.Errorf("expected empty after flush, got %s", ip)
+	}
+}
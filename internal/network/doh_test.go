@@ -0,0 +1,126 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newMockDoH serves A records for the names in records (without trailing
+// dot) and NXDOMAIN for anything else.
+func newMockDoH(t *testing.T, records map[string]string, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits != nil {
+			hits.Add(1)
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var q dnsmessage.Message
+		if err := q.Unpack(body); err != nil || len(q.Questions) != 1 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		question := q.Questions[0]
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: q.ID, Response: true, RCode: dnsmessage.RCodeNameError},
+			Questions: q.Questions,
+		}
+		name := question.Name.String()
+		if ip, ok := records[name[:len(name)-1]]; ok {
+			reply.RCode = dnsmessage.RCodeSuccess
+			if question.Type == dnsmessage.TypeA {
+				var a [4]byte
+				copy(a[:], net.ParseIP(ip).To4())
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: a},
+				}}
+			}
+		}
+		packed, _ := reply.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDoHResolver_LookupHost(t *testing.T) {
+	srv := newMockDoH(t, map[string]string{"files.example.test": "10.1.2.3"}, nil)
+	r := NewDoHResolver(srv.URL, srv.Client())
+
+	addrs, err := r.LookupHost(context.Background(), "files.example.test")
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.1.2.3" {
+		t.Errorf("expected [10.1.2.3], got %v", addrs)
+	}
+}
+
+func TestDoHResolver_NotFound(t *testing.T) {
+	srv := newMockDoH(t, nil, nil)
+	r := NewDoHResolver(srv.URL, srv.Client())
+
+	_, err := r.LookupHost(context.Background(), "missing.example.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected not-found DNSError, got %v", err)
+	}
+}
+
+func TestDoHResolver_IPLiteralSkipsQuery(t *testing.T) {
+	var hits atomic.Int32
+	srv := newMockDoH(t, nil, &hits)
+	r := NewDoHResolver(srv.URL, srv.Client())
+
+	addrs, err := r.LookupHost(context.Background(), "192.0.2.7")
+	if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.7" {
+		t.Fatalf("expected IP literal returned as-is, got %v %v", addrs, err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("IP literal should not be sent to the DoH server")
+	}
+}
+
+type failingResolver struct{ calls atomic.Int32 }
+
+func (f *failingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.calls.Add(1)
+	return nil, errors.New("provider unreachable")
+}
+
+func TestDNSCache_ResolverFallsBackToSystem(t *testing.T) {
+	c := NewDNSCache(time.Minute)
+	f := &failingResolver{}
+	c.SetResolver(f)
+
+	addrs, err := c.lookup(context.Background(), "localhost")
+	if err != nil || len(addrs) == 0 {
+		t.Fatalf("expected system DNS fallback to resolve localhost, got %v %v", addrs, err)
+	}
+	if f.calls.Load() != 1 {
+		t.Errorf("expected the configured resolver to be tried first")
+	}
+}
+
+func TestDNSCache_SetResolverClearsEntries(t *testing.T) {
+	c := NewDNSCache(time.Minute)
+	c.put("example.com", []string{"1.2.3.4"})
+	c.SetResolver(nil)
+	if c.get("example.com") != "" {
+		t.Error("expected cached entries to be dropped when the resolver changes")
+	}
+}
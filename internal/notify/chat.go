@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	telegramAPI = "https://api.telegram.org"
+
+	// discordMaxContent is the longest message Discord accepts.
+	discordMaxContent = 2000
+)
+
+// telegramNotifier sends messages with the Bot API's sendMessage.
+type telegramNotifier struct {
+	cfg     TelegramConfig
+	client  *http.Client
+	apiBase string
+}
+
+func (n *telegramNotifier) Name() string { return "telegram" }
+
+func (n *telegramNotifier) Notify(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  n.cfg.ChatID,
+		"text":                     ev.Text(),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	// The token is part of the path; keep it out of errors
+	target := fmt.Sprintf("%s/bot%s/sendMessage", n.apiBase, n.cfg.BotToken)
+	resp, err := postJSON(ctx, n.client, target, body)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result)
+	if resp.StatusCode != http.StatusOK || !result.OK {
+		if result.Description != "" {
+			return fmt.Errorf("telegram: %s", result.Description)
+		}
+		return fmt.Errorf("telegram: %s", resp.Status)
+	}
+	return nil
+}
+
+// discordNotifier posts to a channel webhook.
+type discordNotifier struct {
+	cfg    DiscordConfig
+	client *http.Client
+}
+
+func (n *discordNotifier) Name() string { return "discord" }
+
+func (n *discordNotifier) Notify(ctx context.Context, ev Event) error {
+	text := []rune(ev.Text())
+	if len(text) > discordMaxContent {
+		text = append(text[:discordMaxContent-1], '…')
+	}
+	body, err := json.Marshal(map[string]string{"content": string(text)})
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, n.client, n.cfg.WebhookURL, body)
+	if err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("discord: webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tachyon-Notify/1.0")
+	return client.Do(req)
+}
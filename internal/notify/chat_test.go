@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// request is one call seen by a mock chat API.
+type request struct {
+	path string
+	body map[string]interface{}
+}
+
+// spawnChatAPI records each request and answers with status and reply.
+func spawnChatAPI(t *testing.T, status int, reply string) (*httptest.Server, chan request) {
+	t.Helper()
+	requests := make(chan request, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("bad request: %s %q", r.Header.Get("Content-Type"), data)
+		}
+		requests <- request{path: r.URL.Path, body: body}
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+var completed = Event{Kind: KindCompleted, ID: "t1", Filename: "file.bin", Path: "/dl/file.bin", Size: 100}
+
+func TestTelegramNotify(t *testing.T) {
+	server, requests := spawnChatAPI(t, http.StatusOK, `{"ok":true,"result":{}}`)
+	n := &telegramNotifier{cfg: TelegramConfig{BotToken: "123:abc", ChatID: "-10042"}, client: server.Client(), apiBase: server.URL}
+
+	if err := n.Notify(context.Background(), completed); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	r := <-requests
+	if r.path != "/bot123:abc/sendMessage" {
+		t.Errorf("path = %s", r.path)
+	}
+	if r.body["chat_id"] != "-10042" || !strings.HasPrefix(r.body["text"].(string), "Download complete: file.bin") {
+		t.Errorf("body = %v", r.body)
+	}
+}
+
+func TestTelegramNotify_Errors(t *testing.T) {
+	server, _ := spawnChatAPI(t, http.StatusUnauthorized, `{"ok":false,"description":"Unauthorized"}`)
+	n := &telegramNotifier{cfg: TelegramConfig{BotToken: "secret-token", ChatID: "1"}, client: server.Client(), apiBase: server.URL}
+	err := n.Notify(context.Background(), completed)
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("err = %v, want the API's description", err)
+	}
+
+	// Transport errors leave the token, which sits in the URL, out
+	n.apiBase = "http://127.0.0.1:1"
+	err = n.Notify(context.Background(), completed)
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("err = %v, want an error without the token", err)
+	}
+}
+
+func TestDiscordNotify(t *testing.T) {
+	server, requests := spawnChatAPI(t, http.StatusNoContent, "")
+	n := &discordNotifier{cfg: DiscordConfig{WebhookURL: server.URL + "/api/webhooks/1/x"}, client: server.Client()}
+
+	failed := Event{Kind: KindFailed, Filename: "file.bin", Error: strings.Repeat("x", 3000)}
+	if err := n.Notify(context.Background(), failed); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	r := <-requests
+	if r.path != "/api/webhooks/1/x" {
+		t.Errorf("path = %s", r.path)
+	}
+	content := r.body["content"].(string)
+	if !strings.HasPrefix(content, "Download failed: file.bin") {
+		t.Errorf("content = %.60q", content)
+	}
+	if n := len([]rune(content)); n > discordMaxContent {
+		t.Errorf("content is %d characters, over Discord's %d", n, discordMaxContent)
+	}
+}
+
+func TestDiscordNotify_Rejected(t *testing.T) {
+	server, _ := spawnChatAPI(t, http.StatusNotFound, `{"message":"Unknown Webhook"}`)
+	n := &discordNotifier{cfg: DiscordConfig{WebhookURL: server.URL}, client: server.Client()}
+	if err := n.Notify(context.Background(), completed); err == nil {
+		t.Error("expected an error for a rejected delivery")
+	}
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpMessage is one message accepted by the mock SMTP server.
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// spawnSMTPServer runs a minimal SMTP server on loopback that accepts every
+// message, without TLS or authentication.
+func spawnSMTPServer(t *testing.T) (int, chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	msgs := make(chan smtpMessage, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, msgs)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, msgs
+}
+
+func serveSMTP(conn net.Conn, msgs chan<- smtpMessage) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 mock ESMTP")
+	var msg smtpMessage
+	address := func(line string) string {
+		_, addr, _ := strings.Cut(line, "<")
+		addr, _, _ = strings.Cut(addr, ">")
+		return addr
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(line); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			tp.PrintfLine("250-mock")
+			tp.PrintfLine("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg.from = address(line)
+			tp.PrintfLine("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, address(line))
+			tp.PrintfLine("250 ok")
+		case cmd == "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			tp.PrintfLine("250 queued")
+			msgs <- msg
+		case cmd == "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestEmailNotify(t *testing.T) {
+	port, msgs := spawnSMTPServer(t)
+	n := &emailNotifier{cfg: EmailConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "tachyon@example.com",
+		To:   []string{"me@example.com", "ops@example.com"},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, completed); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	msg := <-msgs
+	if msg.from != "tachyon@example.com" || strings.Join(msg.to, ",") != "me@example.com,ops@example.com" {
+		t.Errorf("envelope = %s -> %v", msg.from, msg.to)
+	}
+	for _, want := range []string{"Subject: Download complete: file.bin\n", "To: me@example.com, ops@example.com\n", "Content-Type: text/plain; charset=utf-8\n", "Saved to: /dl/file.bin"} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message missing %q:\n%s", want, msg.data)
+		}
+	}
+}
+
+func TestEmailNotify_AuthNotOffered(t *testing.T) {
+	port, _ := spawnSMTPServer(t)
+	n := &emailNotifier{cfg: EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "user",
+		Password: "pass",
+		From:     "tachyon@example.com",
+		To:       []string{"me@example.com"},
+	}}
+	// Refuse to send at all rather than send without the credentials
+	if err := n.Notify(context.Background(), completed); err == nil {
+		t.Error("expected an error when the server offers no authentication")
+	}
+}
+
+func TestEmailNotify_UnresponsiveServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept, then never send the greeting
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	n := &emailNotifier{cfg: EmailConfig{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, From: "a@example.com", To: []string{"b@example.com"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Notify(ctx, completed); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Notify took %v, want it bounded by the context", elapsed)
+	}
+}
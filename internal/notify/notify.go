@@ -0,0 +1,193 @@
+// Package notify tells chat and mail services when downloads finish, for
+// instances nobody is watching (servers, containers).
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event kinds.
+const (
+	KindCompleted = "completed"
+	KindFailed    = "failed"
+	KindTest      = "test"
+)
+
+// Event is a download outcome to report.
+type Event struct {
+	Kind     string // KindCompleted, KindFailed or KindTest
+	ID       string
+	Filename string
+	URL      string // with credentials redacted; messages leave the machine
+	Path     string
+	Size     int64 // bytes; what was received so far for failures
+	Duration time.Duration
+	Error    string
+}
+
+// Subject is a one-line summary of the event.
+func (ev Event) Subject() string {
+	switch ev.Kind {
+	case KindCompleted:
+		return "Download complete: " + ev.Filename
+	case KindFailed:
+		return "Download failed: " + ev.Filename
+	default:
+		return "Tachyon test notification"
+	}
+}
+
+// Text is the message body: the subject, then one detail per line.
+func (ev Event) Text() string {
+	var b strings.Builder
+	b.WriteString(ev.Subject())
+	if ev.Kind == KindTest {
+		b.WriteString("\nNotifications are set up correctly.")
+	}
+	if ev.Error != "" {
+		fmt.Fprintf(&b, "\nError: %s", ev.Error)
+	}
+	if ev.Path != "" {
+		fmt.Fprintf(&b, "\nSaved to: %s", ev.Path)
+	}
+	if ev.Size > 0 {
+		fmt.Fprintf(&b, "\nSize: %d bytes", ev.Size)
+	}
+	if ev.Duration > 0 {
+		fmt.Fprintf(&b, "\nTook: %s", ev.Duration.Round(time.Second))
+	}
+	if ev.URL != "" {
+		fmt.Fprintf(&b, "\nFrom: %s", ev.URL)
+	}
+	return b.String()
+}
+
+// Notifier delivers events to one service.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, ev Event) error
+}
+
+// Settings configure the notification backends; each one is off until
+// enabled. Enabled backends are told about every completed and failed
+// download.
+type Settings struct {
+	Email    EmailConfig    `json:"email"`
+	Telegram TelegramConfig `json:"telegram"`
+	Discord  DiscordConfig  `json:"discord"`
+}
+
+// EmailConfig sends mail through an SMTP server, upgrading to TLS with
+// STARTTLS when the server offers it.
+type EmailConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`     // 0 = 587
+	Username string   `json:"username"` // empty = no authentication
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// TelegramConfig posts through a Telegram bot to one chat.
+type TelegramConfig struct {
+	Enabled  bool   `json:"enabled"`
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+// DiscordConfig posts to a Discord channel webhook.
+type DiscordConfig struct {
+	Enabled    bool   `json:"enabled"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// Redacted returns s with the SMTP password, bot token and Discord
+// webhook URL (which embeds its token) blanked, for showing in a UI.
+func (s Settings) Redacted() Settings {
+	s.Email.Password = ""
+	s.Email.To = append([]string(nil), s.Email.To...)
+	s.Telegram.BotToken = ""
+	s.Discord.WebhookURL = ""
+	return s
+}
+
+// KeepSecrets fills each credential left blank in s from old, so settings
+// edited from their Redacted form keep the credentials they had.
+func (s Settings) KeepSecrets(old Settings) Settings {
+	if s.Email.Password == "" {
+		s.Email.Password = old.Email.Password
+	}
+	if s.Telegram.BotToken == "" {
+		s.Telegram.BotToken = old.Telegram.BotToken
+	}
+	if s.Discord.WebhookURL == "" {
+		s.Discord.WebhookURL = old.Discord.WebhookURL
+	}
+	return s
+}
+
+// Validate reports every problem with the enabled backends.
+func (s Settings) Validate() error {
+	var errs []error
+	if e := s.Email; e.Enabled {
+		if e.Host == "" {
+			errs = append(errs, errors.New("email: SMTP host is required"))
+		}
+		if e.Port < 0 || e.Port > 65535 {
+			errs = append(errs, fmt.Errorf("email: invalid port %d", e.Port))
+		}
+		if _, err := mail.ParseAddress(e.From); err != nil {
+			errs = append(errs, fmt.Errorf("email: invalid sender %q", e.From))
+		}
+		if len(e.To) == 0 {
+			errs = append(errs, errors.New("email: at least one recipient is required"))
+		}
+		for _, to := range e.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				errs = append(errs, fmt.Errorf("email: invalid recipient %q", to))
+			}
+		}
+	}
+	if t := s.Telegram; t.Enabled {
+		if t.BotToken == "" || t.ChatID == "" {
+			errs = append(errs, errors.New("telegram: bot token and chat ID are required"))
+		}
+	}
+	if d := s.Discord; d.Enabled {
+		u, err := url.Parse(d.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("discord: invalid webhook URL %q", d.WebhookURL))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Notifiers validates s and returns a Notifier for each enabled backend.
+// The chat backends send their requests with client.
+func (s Settings) Notifiers(client *http.Client) ([]Notifier, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var ns []Notifier
+	if s.Email.Enabled {
+		ns = append(ns, &emailNotifier{cfg: s.Email})
+	}
+	if s.Telegram.Enabled {
+		ns = append(ns, &telegramNotifier{cfg: s.Telegram, client: client, apiBase: telegramAPI})
+	}
+	if s.Discord.Enabled {
+		ns = append(ns, &discordNotifier{cfg: s.Discord, client: client})
+	}
+	return ns, nil
+}
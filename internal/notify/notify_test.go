@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventText(t *testing.T) {
+	ev := Event{
+		Kind:     KindCompleted,
+		Filename: "ubuntu.iso",
+		URL:      "https://example.com/ubuntu.iso",
+		Path:     "/downloads/ubuntu.iso",
+		Size:     4096,
+		Duration: 90*time.Second + 400*time.Millisecond,
+	}
+	text := ev.Text()
+	for _, want := range []string{"Download complete: ubuntu.iso", "Saved to: /downloads/ubuntu.iso", "Size: 4096 bytes", "Took: 1m30s", "From: https://example.com/ubuntu.iso"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Error") {
+		t.Errorf("completed event mentions an error:\n%s", text)
+	}
+
+	failed := Event{Kind: KindFailed, Filename: "a.zip", Error: "HTTP 404"}
+	if text := failed.Text(); !strings.HasPrefix(text, "Download failed: a.zip\nError: HTTP 404") {
+		t.Errorf("failed text = %q", text)
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	valid := Settings{
+		Email:    EmailConfig{Enabled: true, Host: "smtp.example.com", From: "tachyon@example.com", To: []string{"me@example.com"}},
+		Telegram: TelegramConfig{Enabled: true, BotToken: "123:abc", ChatID: "42"},
+		Discord:  DiscordConfig{Enabled: true, WebhookURL: "https://discord.com/api/webhooks/1/x"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid settings rejected: %v", err)
+	}
+	if err := (Settings{}).Validate(); err != nil {
+		t.Errorf("all disabled rejected: %v", err)
+	}
+	// Disabled backends are not checked
+	if err := (Settings{Discord: DiscordConfig{WebhookURL: "junk"}}).Validate(); err != nil {
+		t.Errorf("disabled backend checked: %v", err)
+	}
+
+	tests := map[string]func(s *Settings){
+		"no host":        func(s *Settings) { s.Email.Host = "" },
+		"bad port":       func(s *Settings) { s.Email.Port = 70000 },
+		"bad sender":     func(s *Settings) { s.Email.From = "not an address" },
+		"no recipients":  func(s *Settings) { s.Email.To = nil },
+		"bad recipient":  func(s *Settings) { s.Email.To = []string{"me@example.com", "nope"} },
+		"no bot token":   func(s *Settings) { s.Telegram.BotToken = "" },
+		"no chat":        func(s *Settings) { s.Telegram.ChatID = "" },
+		"bad discord":    func(s *Settings) { s.Discord.WebhookURL = "ftp://example.com/hook" },
+		"discord nohost": func(s *Settings) { s.Discord.WebhookURL = "https:///hook" },
+	}
+	for name, mutate := range tests {
+		s := valid
+		s.Email.To = append([]string(nil), valid.Email.To...)
+		mutate(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+		if _, err := s.Notifiers(nil); err == nil {
+			t.Errorf("%s: Notifiers accepted invalid settings", name)
+		}
+	}
+}
+
+func TestSettingsNotifiers(t *testing.T) {
+	s := Settings{
+		Telegram: TelegramConfig{Enabled: true, BotToken: "123:abc", ChatID: "42"},
+		Discord:  DiscordConfig{Enabled: true, WebhookURL: "https://discord.com/api/webhooks/1/x"},
+	}
+	ns, err := s.Notifiers(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, n := range ns {
+		names = append(names, n.Name())
+	}
+	if got := strings.Join(names, ","); got != "telegram,discord" {
+		t.Errorf("notifiers = %s, want telegram,discord", got)
+	}
+	if ns, _ := (Settings{}).Notifiers(nil); len(ns) != 0 {
+		t.Errorf("expected no notifiers when all are disabled, got %d", len(ns))
+	}
+}
+
+func TestSettingsRedacted(t *testing.T) {
+	s := Settings{
+		Email:    EmailConfig{Enabled: true, Host: "smtp.example.com", Username: "me", Password: "hunter2", To: []string{"me@example.com"}},
+		Telegram: TelegramConfig{Enabled: true, BotToken: "123:abc", ChatID: "42"},
+		Discord:  DiscordConfig{Enabled: true, WebhookURL: "https://discord.com/api/webhooks/1/x"},
+	}
+	r := s.Redacted()
+	if r.Email.Password != "" || r.Telegram.BotToken != "" || r.Discord.WebhookURL != "" {
+		t.Errorf("redacted settings carry a credential: %+v", r)
+	}
+	if r.Email.Host != "smtp.example.com" || r.Email.Username != "me" || r.Telegram.ChatID != "42" || !r.Discord.Enabled {
+		t.Errorf("redaction dropped non-secret fields: %+v", r)
+	}
+
+	// Saving the redacted form back keeps the credentials; new ones replace them
+	r.Telegram.BotToken = "456:def"
+	kept := r.KeepSecrets(s)
+	if kept.Email.Password != "hunter2" || kept.Discord.WebhookURL != s.Discord.WebhookURL || kept.Telegram.BotToken != "456:def" {
+		t.Errorf("KeepSecrets = %+v", kept)
+	}
+}
@@ -0,0 +1,62 @@
+// Package platform contains OS integration helpers such as launching the
+// application at login. OS-specific behaviour lives in build-tagged files.
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AppID identifies Tachyon in OS-level registrations (Run key, LaunchAgent, .desktop).
+const AppID = "TachyonDownloadManager"
+
+// appDisplayName is the human readable name written to launcher entries.
+const appDisplayName = "Tachyon Download Manager"
+
+// Autostart manages the entry that launches the application at login.
+type Autostart struct {
+	Name string   // Entry identifier (registry value, plist label, .desktop file name)
+	Exec string   // Absolute path to the executable
+	Args []string // Arguments passed on launch, e.g. --minimized
+}
+
+// NewAutostart creates an Autostart for the running executable with the given arguments.
+func NewAutostart(args ...string) (*Autostart, error) {
+	exe, err := executablePath()
+	if err != nil {
+		return nil, err
+	}
+	return &Autostart{Name: AppID, Exec: exe, Args: args}, nil
+}
+
+// executablePath returns the absolute, symlink-resolved path of the running binary.
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe, nil
+}
+
+// Enable registers the autostart entry. Calling it again rewrites the entry
+// so a moved executable is picked up.
+func (a *Autostart) Enable() error {
+	if a.Exec == "" {
+		return fmt.Errorf("autostart: executable path is empty")
+	}
+	return a.enable()
+}
+
+// Disable removes the autostart entry. It is not an error if none exists.
+func (a *Autostart) Disable() error {
+	return a.disable()
+}
+
+// IsEnabled reports whether the autostart entry is currently registered.
+func (a *Autostart) IsEnabled() bool {
+	return a.isEnabled()
+}
@@ -0,0 +1,75 @@
+//go:build darwin
+
+package platform
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// launchAgentLabel returns the reverse-DNS label used for the LaunchAgent.
+func (a *Autostart) launchAgentLabel() string {
+	return "com.tachyon." + a.Name
+}
+
+// plistPath returns ~/Library/LaunchAgents/<label>.plist.
+func (a *Autostart) plistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", a.launchAgentLabel()+".plist"), nil
+}
+
+func (a *Autostart) enable() error {
+	path, err := a.plistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents dir: %w", err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+	b.WriteString("\t<key>Label</key>\n\t<string>")
+	xml.EscapeText(&b, []byte(a.launchAgentLabel()))
+	b.WriteString("</string>\n")
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{a.Exec}, a.Args...) {
+		b.WriteString("\t\t<string>")
+		xml.EscapeText(&b, []byte(arg))
+		b.WriteString("</string>\n")
+	}
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("</dict>\n</plist>\n")
+
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+func (a *Autostart) disable() error {
+	path, err := a.plistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (a *Autostart) isEnabled() bool {
+	path, err := a.plistPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,59 @@
+//go:build darwin
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestAutostart(t *testing.T) (*Autostart, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	a := &Autostart{Name: "tachyon-test", Exec: "/Applications/TDM.app/Contents/MacOS/TDM", Args: []string{"--minimized"}}
+	return a, filepath.Join(home, "Library", "LaunchAgents", "com.tachyon.tachyon-test.plist")
+}
+
+func TestAutostartDarwin_EnableDisable(t *testing.T) {
+	a, path := newTestAutostart(t)
+
+	if err := a.Enable(); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("plist not written: %v", err)
+	}
+	for _, want := range []string{"<string>/Applications/TDM.app/Contents/MacOS/TDM</string>", "<string>--minimized</string>", "<key>RunAtLoad</key>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("plist missing %q", want)
+		}
+	}
+	if !a.IsEnabled() {
+		t.Fatal("should be enabled")
+	}
+
+	if err := a.Disable(); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if a.IsEnabled() {
+		t.Fatal("should be disabled")
+	}
+}
+
+func TestAutostartDarwin_Idempotent(t *testing.T) {
+	a, _ := newTestAutostart(t)
+	for i := 0; i < 2; i++ {
+		if err := a.Enable(); err != nil {
+			t.Fatalf("Enable #%d failed: %v", i+1, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := a.Disable(); err != nil {
+			t.Fatalf("Disable #%d failed: %v", i+1, err)
+		}
+	}
+}
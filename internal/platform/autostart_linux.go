@@ -0,0 +1,83 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// desktopFilePath returns ~/.config/autostart/<name>.desktop (honouring XDG_CONFIG_HOME).
+func (a *Autostart) desktopFilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "autostart", a.Name+".desktop"), nil
+}
+
+func (a *Autostart) enable() error {
+	path, err := a.desktopFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create autostart dir: %w", err)
+	}
+
+	execLine := make([]string, 0, len(a.Args)+1)
+	execLine = append(execLine, quoteDesktopExecArg(a.Exec))
+	for _, arg := range a.Args {
+		execLine = append(execLine, quoteDesktopExecArg(arg))
+	}
+
+	content := "[Desktop Entry]\n" +
+		"Type=Application\n" +
+		"Name=" + appDisplayName + "\n" +
+		"Exec=" + strings.Join(execLine, " ") + "\n" +
+		"Terminal=false\n" +
+		"X-GNOME-Autostart-enabled=true\n"
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func (a *Autostart) disable() error {
+	path, err := a.desktopFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (a *Autostart) isEnabled() bool {
+	path, err := a.desktopFilePath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// quoteDesktopExecArg quotes an argument per the Desktop Entry spec: arguments
+// containing reserved characters are double-quoted with ", `, $ and \ escaped.
+func quoteDesktopExecArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range arg {
+		switch r {
+		case '"', '`', '$', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestAutostart(t *testing.T) (*Autostart, string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	a := &Autostart{Name: "tachyon-test", Exec: "/opt/Tachyon App/tdm", Args: []string{"--minimized"}}
+	return a, filepath.Join(dir, "autostart", "tachyon-test.desktop")
+}
+
+func TestAutostartLinux_EnableDisable(t *testing.T) {
+	a, path := newTestAutostart(t)
+
+	if a.IsEnabled() {
+		t.Fatal("should not be enabled initially")
+	}
+	if err := a.Enable(); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if !a.IsEnabled() {
+		t.Fatal("should be enabled after Enable")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("desktop file not written: %v", err)
+	}
+	if !strings.Contains(string(data), `Exec="/opt/Tachyon App/tdm" --minimized`) {
+		t.Errorf("unexpected Exec line in:\n%s", data)
+	}
+
+	if err := a.Disable(); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if a.IsEnabled() {
+		t.Fatal("should not be enabled after Disable")
+	}
+}
+
+func TestAutostartLinux_Idempotent(t *testing.T) {
+	a, _ := newTestAutostart(t)
+
+	for i := 0; i < 2; i++ {
+		if err := a.Enable(); err != nil {
+			t.Fatalf("Enable #%d failed: %v", i+1, err)
+		}
+	}
+	if !a.IsEnabled() {
+		t.Fatal("should be enabled")
+	}
+	for i := 0; i < 2; i++ {
+		if err := a.Disable(); err != nil {
+			t.Fatalf("Disable #%d failed: %v", i+1, err)
+		}
+	}
+}
+
+func TestQuoteDesktopExecArg(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/tdm": "/usr/bin/tdm",
+		"--minimized":  "--minimized",
+		"/a b/tdm":     `"/a b/tdm"`,
+		`/a$b`:         `"/a\$b"`,
+		"":             `""`,
+	}
+	for in, want := range tests {
+		if got := quoteDesktopExecArg(in); got != want {
+			t.Errorf("quoteDesktopExecArg(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
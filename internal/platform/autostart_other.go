@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package platform
+
+import "errors"
+
+// ErrUnsupported is returned on platforms without autostart support.
+var ErrUnsupported = errors.New("autostart is not supported on this platform")
+
+func (a *Autostart) enable() error  { return ErrUnsupported }
+func (a *Autostart) disable() error { return nil }
+func (a *Autostart) isEnabled() bool {
+	return false
+}
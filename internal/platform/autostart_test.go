@@ -0,0 +1,26 @@
+package platform
+
+import "testing"
+
+func TestNewAutostart_UsesExecutable(t *testing.T) {
+	a, err := NewAutostart("--minimized")
+	if err != nil {
+		t.Fatalf("NewAutostart failed: %v", err)
+	}
+	if a.Exec == "" {
+		t.Error("Exec should be set to the running executable")
+	}
+	if a.Name != AppID {
+		t.Errorf("Name = %q, want %q", a.Name, AppID)
+	}
+	if len(a.Args) != 1 || a.Args[0] != "--minimized" {
+		t.Errorf("Args = %v, want [--minimized]", a.Args)
+	}
+}
+
+func TestAutostart_EnableRequiresExec(t *testing.T) {
+	a := &Autostart{Name: "tachyon-test"}
+	if err := a.Enable(); err == nil {
+		t.Error("expected error for empty executable path")
+	}
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package platform
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// runKeyPath is the per-user Run key. It is a variable so tests can point it
+// at a scratch key.
+var runKeyPath = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+// commandLine builds the quoted command line stored in the Run key.
+func (a *Autostart) commandLine() string {
+	parts := make([]string, 0, len(a.Args)+1)
+	parts = append(parts, `"`+a.Exec+`"`)
+	for _, arg := range a.Args {
+		if strings.ContainsAny(arg, " \t") {
+			arg = `"` + arg + `"`
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (a *Autostart) enable() error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, runKeyPath, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	return key.SetStringValue(a.Name, a.commandLine())
+}
+
+func (a *Autostart) disable() error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.SET_VALUE)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer key.Close()
+	if err := key.DeleteValue(a.Name); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (a *Autostart) isEnabled() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	_, _, err = key.GetStringValue(a.Name)
+	return err == nil
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package platform
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func newTestAutostart(t *testing.T) *Autostart {
+	t.Helper()
+	orig := runKeyPath
+	runKeyPath = `Software\TachyonTest\Run`
+	t.Cleanup(func() {
+		registry.DeleteKey(registry.CURRENT_USER, runKeyPath)
+		runKeyPath = orig
+	})
+	return &Autostart{Name: "tachyon-test", Exec: `C:\Program Files\TDM\tdm.exe`, Args: []string{"--minimized"}}
+}
+
+func TestAutostartWindows_EnableDisable(t *testing.T) {
+	a := newTestAutostart(t)
+
+	if err := a.Enable(); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		t.Fatalf("Run key missing: %v", err)
+	}
+	val, _, err := key.GetStringValue("tachyon-test")
+	key.Close()
+	if err != nil {
+		t.Fatalf("Run value missing: %v", err)
+	}
+	if want := `"C:\Program Files\TDM\tdm.exe" --minimized`; val != want {
+		t.Errorf("Run value = %q, want %q", val, want)
+	}
+
+	if err := a.Disable(); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if a.IsEnabled() {
+		t.Fatal("should be disabled")
+	}
+}
+
+func TestAutostartWindows_Idempotent(t *testing.T) {
+	a := newTestAutostart(t)
+	for i := 0; i < 2; i++ {
+		if err := a.Enable(); err != nil {
+			t.Fatalf("Enable #%d failed: %v", i+1, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := a.Disable(); err != nil {
+			t.Fatalf("Disable #%d failed: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package platform
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ProtocolScheme is the custom URL scheme handled by Tachyon (tachyon://).
+const ProtocolScheme = "tachyon"
+
+// maxProtocolURILength bounds the size of URIs accepted from the OS.
+const maxProtocolURILength = 8192
+
+// ProtocolRequest is a parsed tachyon:// link.
+type ProtocolRequest struct {
+	Action   string // Currently only "download"
+	URL      string // Decoded http(s) URL to download
+	Filename string // Optional filename suggestion (not sanitized here)
+}
+
+// IsProtocolURI reports whether arg looks like a tachyon:// link.
+func IsProtocolURI(arg string) bool {
+	return strings.HasPrefix(strings.ToLower(arg), ProtocolScheme+"://")
+}
+
+// ParseProtocolURI parses and validates a link of the form
+// tachyon://download?url=<encoded-url>&filename=<name>.
+func ParseProtocolURI(raw string) (*ProtocolRequest, error) {
+	if len(raw) > maxProtocolURILength {
+		return nil, fmt.Errorf("protocol URI too long")
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid protocol URI: %w", err)
+	}
+	if !strings.EqualFold(u.Scheme, ProtocolScheme) {
+		return nil, fmt.Errorf("unexpected scheme %q", u.Scheme)
+	}
+
+	// Browsers may hand over tachyon://download/?... or tachyon:download?...
+	action := strings.ToLower(strings.Trim(u.Host+u.Path, "/"))
+	if action == "" {
+		action = strings.ToLower(strings.Trim(u.Opaque, "/"))
+	}
+	if action != "download" {
+		return nil, fmt.Errorf("unsupported action %q", action)
+	}
+
+	q := u.Query()
+	target := q.Get("url")
+	if target == "" {
+		return nil, fmt.Errorf("missing url parameter")
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url parameter: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("url parameter must be http or https")
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("url parameter has no host")
+	}
+
+	return &ProtocolRequest{
+		Action:   action,
+		URL:      parsed.String(),
+		Filename: strings.TrimSpace(q.Get("filename")),
+	}, nil
+}
+
+// RegisterProtocolHandler registers the running executable as the handler
+// for tachyon:// links.
+func RegisterProtocolHandler() error {
+	exe, err := executablePath()
+	if err != nil {
+		return err
+	}
+	return registerProtocol(exe)
+}
+
+// UnregisterProtocolHandler removes the tachyon:// registration.
+func UnregisterProtocolHandler() error {
+	return unregisterProtocol()
+}
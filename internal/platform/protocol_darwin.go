@@ -0,0 +1,11 @@
+//go:build darwin
+
+package platform
+
+// On macOS URL schemes are declared by the app bundle (CFBundleURLTypes in
+// Info.plist, generated from wails.json "protocols") and picked up by
+// LaunchServices, so there is nothing to register at runtime.
+
+func registerProtocol(exe string) error { return nil }
+
+func unregisterProtocol() error { return nil }
@@ -0,0 +1,64 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const protocolDesktopFile = "tachyon-url-handler.desktop"
+
+// protocolDesktopPath returns ~/.local/share/applications/<file> (honouring XDG_DATA_HOME).
+func protocolDesktopPath() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "applications", protocolDesktopFile), nil
+}
+
+func registerProtocol(exe string) error {
+	path, err := protocolDesktopPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create applications dir: %w", err)
+	}
+
+	content := "[Desktop Entry]\n" +
+		"Type=Application\n" +
+		"Name=" + appDisplayName + "\n" +
+		"Exec=" + quoteDesktopExecArg(exe) + " %u\n" +
+		"Terminal=false\n" +
+		"NoDisplay=true\n" +
+		"MimeType=x-scheme-handler/" + ProtocolScheme + ";\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+
+	// Best effort: make it the default handler. Not all desktops ship xdg-mime.
+	if xdgMime, err := exec.LookPath("xdg-mime"); err == nil {
+		exec.Command(xdgMime, "default", protocolDesktopFile, "x-scheme-handler/"+ProtocolScheme).Run()
+	}
+	return nil
+}
+
+func unregisterProtocol() error {
+	path, err := protocolDesktopPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
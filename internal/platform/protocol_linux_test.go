@@ -0,0 +1,40 @@
+//go:build linux
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProtocolLinux_RegisterUnregister(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+	t.Setenv("PATH", "") // keep xdg-mime from touching the real user config
+	path := filepath.Join(dir, "applications", protocolDesktopFile)
+
+	if err := registerProtocol("/opt/tdm/tdm"); err != nil {
+		t.Fatalf("registerProtocol failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("desktop file not written: %v", err)
+	}
+	for _, want := range []string{"Exec=/opt/tdm/tdm %u", "MimeType=x-scheme-handler/tachyon;"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("desktop file missing %q:\n%s", want, data)
+		}
+	}
+
+	if err := unregisterProtocol(); err != nil {
+		t.Fatalf("unregisterProtocol failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("desktop file should be removed")
+	}
+	if err := unregisterProtocol(); err != nil {
+		t.Errorf("second unregister should be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package platform
+
+func registerProtocol(exe string) error { return ErrUnsupported }
+
+func unregisterProtocol() error { return nil }
@@ -0,0 +1,66 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProtocolURI_Valid(t *testing.T) {
+	req, err := ParseProtocolURI("tachyon://download?url=https%3A%2F%2Fexample.com%2Ffile.zip%3Fa%3D1%26b%3D2&filename=My%20File.zip")
+	if err != nil {
+		t.Fatalf("ParseProtocolURI failed: %v", err)
+	}
+	if req.Action != "download" {
+		t.Errorf("Action = %q, want download", req.Action)
+	}
+	if req.URL != "https://example.com/file.zip?a=1&b=2" {
+		t.Errorf("URL = %q", req.URL)
+	}
+	if req.Filename != "My File.zip" {
+		t.Errorf("Filename = %q", req.Filename)
+	}
+}
+
+func TestParseProtocolURI_Variants(t *testing.T) {
+	for _, raw := range []string{
+		"tachyon://download/?url=http%3A%2F%2Fexample.com%2Fa.iso",
+		"TACHYON://Download?url=http%3A%2F%2Fexample.com%2Fa.iso",
+		"tachyon:download?url=http%3A%2F%2Fexample.com%2Fa.iso",
+	} {
+		req, err := ParseProtocolURI(raw)
+		if err != nil {
+			t.Errorf("ParseProtocolURI(%q) failed: %v", raw, err)
+			continue
+		}
+		if req.URL != "http://example.com/a.iso" {
+			t.Errorf("ParseProtocolURI(%q).URL = %q", raw, req.URL)
+		}
+	}
+}
+
+func TestParseProtocolURI_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"https://example.com/file.zip",
+		"tachyon://delete?url=https%3A%2F%2Fexample.com",
+		"tachyon://download",
+		"tachyon://download?url=file%3A%2F%2F%2Fetc%2Fpasswd",
+		"tachyon://download?url=javascript%3Aalert(1)",
+		"tachyon://download?url=https%3A%2F%2F",
+		"tachyon://download?url=" + strings.Repeat("a", maxProtocolURILength),
+	}
+	for _, raw := range tests {
+		if _, err := ParseProtocolURI(raw); err == nil {
+			t.Errorf("ParseProtocolURI(%.60q) should fail", raw)
+		}
+	}
+}
+
+func TestIsProtocolURI(t *testing.T) {
+	if !IsProtocolURI("tachyon://download?url=x") {
+		t.Error("expected tachyon:// to be recognised")
+	}
+	if IsProtocolURI("--minimized") || IsProtocolURI("https://example.com") {
+		t.Error("non-tachyon args should not be recognised")
+	}
+}
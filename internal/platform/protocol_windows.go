@@ -0,0 +1,45 @@
+//go:build windows
+
+package platform
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// protocolKeyPath is the per-user class key for the scheme. It is a variable
+// so tests can point it at a scratch key.
+var protocolKeyPath = `Software\Classes\` + ProtocolScheme
+
+func registerProtocol(exe string) error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, protocolKeyPath, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	if err := key.SetStringValue("", "URL:"+appDisplayName); err != nil {
+		return err
+	}
+	if err := key.SetStringValue("URL Protocol", ""); err != nil {
+		return err
+	}
+
+	cmd, _, err := registry.CreateKey(registry.CURRENT_USER, protocolKeyPath+`\shell\open\command`, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer cmd.Close()
+	return cmd.SetStringValue("", `"`+exe+`" "%1"`)
+}
+
+func unregisterProtocol() error {
+	// Registry keys must be deleted leaf first.
+	for _, sub := range []string{`\shell\open\command`, `\shell\open`, `\shell`, ``} {
+		err := registry.DeleteKey(registry.CURRENT_USER, protocolKeyPath+sub)
+		if err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
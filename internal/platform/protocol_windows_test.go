@@ -0,0 +1,38 @@
+//go:build windows
+
+package platform
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func TestProtocolWindows_RegisterUnregister(t *testing.T) {
+	orig := protocolKeyPath
+	protocolKeyPath = `Software\TachyonTest\Classes\tachyon`
+	t.Cleanup(func() { protocolKeyPath = orig })
+
+	if err := registerProtocol(`C:\TDM\tdm.exe`); err != nil {
+		t.Fatalf("registerProtocol failed: %v", err)
+	}
+	key, err := registry.OpenKey(registry.CURRENT_USER, protocolKeyPath+`\shell\open\command`, registry.QUERY_VALUE)
+	if err != nil {
+		t.Fatalf("command key missing: %v", err)
+	}
+	val, _, err := key.GetStringValue("")
+	key.Close()
+	if err != nil || val != `"C:\TDM\tdm.exe" "%1"` {
+		t.Errorf("command = %q, err = %v", val, err)
+	}
+
+	if err := unregisterProtocol(); err != nil {
+		t.Fatalf("unregisterProtocol failed: %v", err)
+	}
+	if _, err := registry.OpenKey(registry.CURRENT_USER, protocolKeyPath, registry.QUERY_VALUE); err == nil {
+		t.Error("protocol key should be removed")
+	}
+	if err := unregisterProtocol(); err != nil {
+		t.Errorf("second unregister should be a no-op, got %v", err)
+	}
+}
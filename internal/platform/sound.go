@@ -0,0 +1,21 @@
+package platform
+
+import "errors"
+
+// Sound is a notification sound played through the OS.
+type Sound string
+
+const (
+	SoundComplete Sound = "complete"
+	SoundError    Sound = "error"
+)
+
+// ErrNoSoundPlayer is returned when the system has no way to play sounds.
+var ErrNoSoundPlayer = errors.New("no sound player available")
+
+// PlaySound plays the system's sound for s and returns once it has finished
+// (or been handed to the OS). Callers that must not block run it in a
+// goroutine.
+func PlaySound(s Sound) error {
+	return playSound(s)
+}
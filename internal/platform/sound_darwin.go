@@ -0,0 +1,13 @@
+//go:build darwin
+
+package platform
+
+import "os/exec"
+
+func playSound(s Sound) error {
+	file := "/System/Library/Sounds/Glass.aiff"
+	if s == SoundError {
+		file = "/System/Library/Sounds/Basso.aiff"
+	}
+	return exec.Command("afplay", file).Run()
+}
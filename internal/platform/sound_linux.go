@@ -0,0 +1,30 @@
+//go:build linux
+
+package platform
+
+import (
+	"os/exec"
+	"path/filepath"
+)
+
+// freedesktopSounds are the sound theme names played for each Sound.
+var freedesktopSounds = map[Sound]string{
+	SoundComplete: "complete",
+	SoundError:    "dialog-error",
+}
+
+// playSound tries the freedesktop sound theme through canberra, then the
+// theme file directly through PulseAudio/PipeWire's paplay.
+func playSound(s Sound) error {
+	name := freedesktopSounds[s]
+	if path, err := exec.LookPath("canberra-gtk-play"); err == nil {
+		if exec.Command(path, "-i", name).Run() == nil {
+			return nil
+		}
+	}
+	if path, err := exec.LookPath("paplay"); err == nil {
+		file := filepath.Join("/usr/share/sounds/freedesktop/stereo", name+".oga")
+		return exec.Command(path, file).Run()
+	}
+	return ErrNoSoundPlayer
+}
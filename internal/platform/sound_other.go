@@ -0,0 +1,5 @@
+//go:build !linux && !darwin && !windows
+
+package platform
+
+func playSound(s Sound) error { return ErrNoSoundPlayer }
@@ -0,0 +1,25 @@
+//go:build windows
+
+package platform
+
+import "golang.org/x/sys/windows"
+
+var procMessageBeep = windows.NewLazySystemDLL("user32.dll").NewProc("MessageBeep")
+
+const (
+	mbIconHand     = 0x10
+	mbIconAsterisk = 0x40
+)
+
+// playSound uses MessageBeep, which queues the user's configured system
+// sound and returns immediately.
+func playSound(s Sound) error {
+	kind := uintptr(mbIconAsterisk)
+	if s == SoundError {
+		kind = mbIconHand
+	}
+	if ok, _, err := procMessageBeep.Call(kind); ok == 0 {
+		return err
+	}
+	return nil
+}
@@ -9,9 +9,10 @@ import (
 
 // DownloadQueue manages ordered queue of downloads
 type DownloadQueue struct {
-	items []*storage.DownloadTask
-	mutex sync.Mutex
-	cond  *sync.Cond
+	items  []*storage.DownloadTask
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	closed bool // set by Close; waits return immediately
 }
 
 func NewDownloadQueue() *DownloadQueue {
@@ -49,18 +50,24 @@ func (dq *DownloadQueue) Pop() *storage.DownloadTask {
 	return task
 }
 
-// PopSpecific removes a specific task by ID (for SmartScheduler picking)
+// Remove removes a specific task by ID and reports whether it was queued
 func (dq *DownloadQueue) Remove(id string) bool {
+	return dq.Take(id) != nil
+}
+
+// Take removes a specific task by ID and returns it as currently queued,
+// or nil if it is not queued (for SmartScheduler picking)
+func (dq *DownloadQueue) Take(id string) *storage.DownloadTask {
 	dq.mutex.Lock()
 	defer dq.mutex.Unlock()
 
 	for i, item := range dq.items {
 		if item.ID == id {
 			dq.items = append(dq.items[:i], dq.items[i+1:]...)
-			return true
+			return item
 		}
 	}
-	return false
+	return nil
 }
 
 // Len returns the number of items in the queue
@@ -70,7 +77,9 @@ func (dq *DownloadQueue) Len() int {
 	return len(dq.items)
 }
 
-// GetAll returns a copy of all queued items
+// GetAll returns a copy of all queued items. The tasks themselves are
+// shared; the queue never changes a task's fields in place (see
+// SetPriority), so the snapshot can be read without the lock.
 func (dq *DownloadQueue) GetAll() []*storage.DownloadTask {
 	dq.mutex.Lock()
 	defer dq.mutex.Unlock()
@@ -97,32 +106,46 @@ func (dq *DownloadQueue) GetNextOrder() int {
 	return maxOrder + 1
 }
 
-// Wait blocks until a signal is received
+// Wait blocks until a signal is received or the queue is closed
 func (dq *DownloadQueue) Wait() {
 	dq.mutex.Lock()
 	defer dq.mutex.Unlock()
-	dq.cond.Wait()
+	if !dq.closed {
+		dq.cond.Wait()
+	}
 }
 
-// WaitTimeout blocks until a signal is received or the timeout expires.
-// This prevents deadlock when only future-scheduled tasks are in the queue.
+// WaitTimeout blocks until a signal is received, the queue is closed or the
+// timeout expires. This prevents deadlock when only future-scheduled tasks
+// are in the queue.
 func (dq *DownloadQueue) WaitTimeout(d time.Duration) {
-	done := make(chan struct{})
-	go func() {
-		dq.mutex.Lock()
+	// Timeout — wake the waiter so it returns
+	timer := time.AfterFunc(d, dq.cond.Broadcast)
+	defer timer.Stop()
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+	if !dq.closed {
 		dq.cond.Wait()
-		dq.mutex.Unlock()
-		close(done)
-	}()
-	select {
-	case <-done:
-	case <-time.After(d):
-		// Timeout — wake the blocked goroutine so it exits
-		dq.cond.Broadcast()
-		<-done
 	}
 }
 
+// Close wakes every waiter and makes later waits return at once, so a
+// dispatcher blocked on the queue can notice it should stop. Tasks stay in
+// the queue.
+func (dq *DownloadQueue) Close() {
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+	dq.closed = true
+	dq.cond.Broadcast()
+}
+
+// Closed reports whether Close has been called
+func (dq *DownloadQueue) Closed() bool {
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+	return dq.closed
+}
+
 // Signal wakes one waiter
 func (dq *DownloadQueue) Signal() {
 	dq.cond.Signal()
@@ -195,6 +218,124 @@ func (dq *DownloadQueue) MoveToLast(id string) bool {
 	return true
 }
 
+// MoveAfter moves a task to sit directly behind afterID. It returns false if
+// either task is not queued.
+func (dq *DownloadQueue) MoveAfter(id, afterID string) bool {
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+	idx := dq.findIndex(id)
+	if idx < 0 || dq.findIndex(afterID) < 0 || id == afterID {
+		return false
+	}
+	task := dq.items[idx]
+	dq.items = append(dq.items[:idx], dq.items[idx+1:]...)
+	target := dq.findIndex(afterID) + 1
+	dq.items = append(dq.items[:target], append([]*storage.DownloadTask{task}, dq.items[target:]...)...)
+	dq.reorderSequential()
+	return true
+}
+
+// MoveBatch moves several tasks in one step, keeping their relative order.
+// position is one of "first", "prev", "next" or "last". It returns false if
+// none of the ids are queued or nothing moved.
+func (dq *DownloadQueue) MoveBatch(ids []string, position string) bool {
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if dq.findIndex(id) >= 0 {
+			selected[id] = true
+		}
+	}
+	if len(selected) == 0 {
+		return false
+	}
+
+	before := make([]string, len(dq.items))
+	for i, item := range dq.items {
+		before[i] = item.ID
+	}
+
+	switch position {
+	case "first", "last":
+		picked := make([]*storage.DownloadTask, 0, len(selected))
+		rest := make([]*storage.DownloadTask, 0, len(dq.items)-len(selected))
+		for _, item := range dq.items {
+			if selected[item.ID] {
+				picked = append(picked, item)
+			} else {
+				rest = append(rest, item)
+			}
+		}
+		if position == "first" {
+			dq.items = append(picked, rest...)
+		} else {
+			dq.items = append(rest, picked...)
+		}
+	case "prev":
+		for i := 1; i < len(dq.items); i++ {
+			if selected[dq.items[i].ID] && !selected[dq.items[i-1].ID] {
+				dq.items[i], dq.items[i-1] = dq.items[i-1], dq.items[i]
+			}
+		}
+	case "next":
+		for i := len(dq.items) - 2; i >= 0; i-- {
+			if selected[dq.items[i].ID] && !selected[dq.items[i+1].ID] {
+				dq.items[i], dq.items[i+1] = dq.items[i+1], dq.items[i]
+			}
+		}
+	default:
+		return false
+	}
+
+	moved := false
+	for i, item := range dq.items {
+		if before[i] != item.ID {
+			moved = true
+			break
+		}
+	}
+	if moved {
+		dq.reorderSequential()
+	}
+	return moved
+}
+
+// SetPriority updates the priority of any queued tasks in ids and returns
+// how many were found. Each is replaced by an updated copy, leaving the
+// one in an earlier GetAll snapshot untouched.
+func (dq *DownloadQueue) SetPriority(ids []string, priority int) int {
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		if idx := dq.findIndex(id); idx >= 0 {
+			task := *dq.items[idx]
+			task.Priority = priority
+			dq.items[idx] = &task
+			count++
+		}
+	}
+	return count
+}
+
+// SetConnections updates the pinned connection count of a queued task and
+// reports whether it was found.
+func (dq *DownloadQueue) SetConnections(id string, n int) bool {
+	dq.mutex.Lock()
+	defer dq.mutex.Unlock()
+
+	if idx := dq.findIndex(id); idx >= 0 {
+		task := *dq.items[idx]
+		task.Connections = n
+		dq.items[idx] = &task
+		return true
+	}
+	return false
+}
+
 func (dq *DownloadQueue) findIndex(id string) int {
 	for i, item := range dq.items {
 		if item.ID == id {
@@ -206,6 +347,10 @@ func (dq *DownloadQueue) findIndex(id string) int {
 
 func (dq *DownloadQueue) reorderSequential() {
 	for i, item := range dq.items {
-		item.QueueOrder = i + 1
+		if item.QueueOrder != i+1 {
+			task := *item
+			task.QueueOrder = i + 1
+			dq.items[i] = &task
+		}
 	}
 }
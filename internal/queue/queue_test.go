@@ -3,6 +3,7 @@ package queue
 import (
 	"project-tachyon/internal/storage"
 	"testing"
+	"time"
 )
 
 func TestDownloadQueue_PushPopOrder(t *testing.T) {
@@ -80,3 +81,143 @@ func TestDownloadQueue_LenEmpty(t *testing.T) {
 		t.Fatal("new queue should have length 0")
 	}
 }
+
+func queueIDs(q *DownloadQueue) string {
+	ids := ""
+	for _, item := range q.GetAll() {
+		ids += item.ID
+	}
+	return ids
+}
+
+func newBatchQueue() *DownloadQueue {
+	q := NewDownloadQueue()
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		q.Push(&storage.DownloadTask{ID: id, QueueOrder: i + 1})
+	}
+	return q
+}
+
+func TestDownloadQueue_MoveBatch(t *testing.T) {
+	tests := []struct {
+		position string
+		ids      []string
+		want     string
+	}{
+		{"first", []string{"d", "b"}, "bdace"},
+		{"last", []string{"a", "c"}, "bdeac"},
+		{"prev", []string{"c", "e"}, "acbed"},
+		{"next", []string{"a", "c"}, "badce"},
+		{"prev", []string{"a", "b"}, "abcde"}, // already at top
+	}
+	for _, tt := range tests {
+		q := newBatchQueue()
+		q.MoveBatch(tt.ids, tt.position)
+		if got := queueIDs(q); got != tt.want {
+			t.Errorf("MoveBatch(%v, %s) = %s, want %s", tt.ids, tt.position, got, tt.want)
+		}
+		for i, item := range q.GetAll() {
+			if item.QueueOrder != i+1 {
+				t.Errorf("QueueOrder of %s = %d, want %d", item.ID, item.QueueOrder, i+1)
+			}
+		}
+	}
+}
+
+func TestDownloadQueue_MoveBatch_NoOp(t *testing.T) {
+	q := newBatchQueue()
+	if q.MoveBatch([]string{"missing"}, "first") {
+		t.Error("expected false for unknown ids")
+	}
+	if q.MoveBatch([]string{"a"}, "first") {
+		t.Error("expected false when nothing moves")
+	}
+	if q.MoveBatch([]string{"b"}, "sideways") {
+		t.Error("expected false for invalid position")
+	}
+}
+
+func TestDownloadQueue_SetPriority(t *testing.T) {
+	q := newBatchQueue()
+	if n := q.SetPriority([]string{"a", "c", "missing"}, 2); n != 2 {
+		t.Fatalf("expected 2 updated, got %d", n)
+	}
+	for _, item := range q.GetAll() {
+		want := 0
+		if item.ID == "a" || item.ID == "c" {
+			want = 2
+		}
+		if item.Priority != want {
+			t.Errorf("priority of %s = %d, want %d", item.ID, item.Priority, want)
+		}
+	}
+}
+
+func TestDownloadQueue_SetConnections(t *testing.T) {
+	q := newBatchQueue()
+	if !q.SetConnections("b", 6) {
+		t.Fatal("expected queued task to be found")
+	}
+	if q.SetConnections("missing", 6) {
+		t.Error("expected false for a task that is not queued")
+	}
+	for _, item := range q.GetAll() {
+		want := 0
+		if item.ID == "b" {
+			want = 6
+		}
+		if item.Connections != want {
+			t.Errorf("connections of %s = %d, want %d", item.ID, item.Connections, want)
+		}
+	}
+}
+
+func TestDownloadQueue_MoveAfter(t *testing.T) {
+	q := newBatchQueue()
+	if !q.MoveAfter("e", "a") {
+		t.Fatal("expected MoveAfter to succeed")
+	}
+	if got := queueIDs(q); got != "aebcd" {
+		t.Errorf("queue = %s, want aebcd", got)
+	}
+	if !q.MoveAfter("a", "d") {
+		t.Fatal("expected MoveAfter to succeed")
+	}
+	if got := queueIDs(q); got != "ebcda" {
+		t.Errorf("queue = %s, want ebcda", got)
+	}
+	if q.MoveAfter("a", "missing") || q.MoveAfter("a", "a") {
+		t.Error("expected false for unknown or identical ids")
+	}
+}
+
+func TestDownloadQueue_CloseWakesWaiters(t *testing.T) {
+	q := NewDownloadQueue()
+	q.Push(&storage.DownloadTask{ID: "kept", QueueOrder: 1})
+
+	done := make(chan struct{})
+	go func() {
+		q.WaitTimeout(time.Minute)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	q.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not wake the waiter")
+	}
+
+	if !q.Closed() {
+		t.Error("Closed = false after Close")
+	}
+	start := time.Now()
+	q.Wait()
+	q.WaitTimeout(time.Minute)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("waits on a closed queue should return at once")
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len = %d, Close must keep queued tasks", q.Len())
+	}
+}
@@ -1,18 +1,48 @@
 package queue
 
 import (
+	"fmt"
 	"log/slog"
 	"net/url"
 	"project-tachyon/internal/storage"
+	"sort"
 	"sync"
 	"time"
 )
 
+const (
+	// DefaultAgingStep is how long a task must be held back by its host
+	// limit to gain a priority level.
+	DefaultAgingStep = 30 * time.Second
+	// maxAgingBoost caps aging, so a waiting task never outranks a task
+	// more than one level above its own.
+	maxAgingBoost = 1
+	// DefaultStarvationAfter is how long a task may wait before the scheduler
+	// holds back the last free slot for it while its host is at its limit.
+	DefaultStarvationAfter = 2 * time.Minute
+)
+
+// Dispatch orders for SetOrder. Only OrderFIFO ignores priority; the size
+// orders pick among tasks of the same effective priority.
+const (
+	OrderPriority = "priority"  // highest effective priority, then queue order
+	OrderFIFO     = "fifo"      // queue order only
+	OrderSizeAsc  = "size_asc"  // smallest first; unknown sizes last
+	OrderSizeDesc = "size_desc" // largest first; unknown sizes last
+)
+
 type SmartScheduler struct {
 	logger        *slog.Logger
 	queue         *DownloadQueue
-	hostLimits    map[string]int // Domain -> Max Concurrent
-	activePerHost map[string]int // Domain -> Current Activce
+	hostLimits    map[string]int       // Domain -> Max Concurrent
+	activePerHost map[string]int       // Domain -> Current Activce
+	queuedSince   map[string]time.Time // Task ID -> when it became runnable
+	blockedSince  map[string]time.Time // Task ID -> when its host limit first held it back
+	promoted      map[string]bool      // Task IDs dispatched before any priority (see Promote)
+	agingStep     time.Duration        // 0 disables aging
+	starveAfter   time.Duration        // 0 disables slot reservation
+	order         string               // one of the Order* modes
+	now           func() time.Time
 	mu            sync.Mutex
 }
 
@@ -22,9 +52,73 @@ func NewSmartScheduler(logger *slog.Logger, queue *DownloadQueue) *SmartSchedule
 		queue:         queue,
 		hostLimits:    make(map[string]int),
 		activePerHost: make(map[string]int),
+		queuedSince:   make(map[string]time.Time),
+		blockedSince:  make(map[string]time.Time),
+		promoted:      make(map[string]bool),
+		agingStep:     DefaultAgingStep,
+		starveAfter:   DefaultStarvationAfter,
+		order:         OrderPriority,
+		now:           time.Now,
 	}
 }
 
+// SetAging changes how quickly waiting tasks gain priority and when a
+// host-blocked task starts reserving a slot. Zero disables either stage.
+func (s *SmartScheduler) SetAging(step, starveAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agingStep = step
+	s.starveAfter = starveAfter
+}
+
+// SetOrder selects how runnable tasks are ordered for dispatch. An empty
+// mode restores OrderPriority.
+func (s *SmartScheduler) SetOrder(mode string) error {
+	switch mode {
+	case "":
+		mode = OrderPriority
+	case OrderPriority, OrderFIFO, OrderSizeAsc, OrderSizeDesc:
+	default:
+		return fmt.Errorf("unknown queue order: %q", mode)
+	}
+	s.mu.Lock()
+	s.order = mode
+	s.mu.Unlock()
+	return nil
+}
+
+// GetOrder returns the dispatch order mode.
+func (s *SmartScheduler) GetOrder() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order
+}
+
+// WaitTime returns how long a queued task has been runnable without being
+// dispatched, or 0 if the scheduler has not seen it yet.
+func (s *SmartScheduler) WaitTime(id string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since, ok := s.queuedSince[id]
+	if !ok {
+		return 0
+	}
+	return s.now().Sub(since)
+}
+
+// effectivePriorityLocked is the task's priority plus one level per aging
+// step since its host limit first held it back, up to maxAgingBoost. Tasks
+// that only wait behind higher priorities don't age.
+func (s *SmartScheduler) effectivePriorityLocked(task *storage.DownloadTask, now time.Time) int {
+	p := task.Priority
+	if s.agingStep > 0 {
+		if since, ok := s.blockedSince[task.ID]; ok {
+			p += min(int(now.Sub(since)/s.agingStep), maxAgingBoost)
+		}
+	}
+	return p
+}
+
 func (s *SmartScheduler) SetHostLimit(domain string, limit int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -40,6 +134,15 @@ func (s *SmartScheduler) GetHostLimit(domain string) int {
 	return 0 // 0 means unlimited
 }
 
+// Promote makes a queued task go ahead of every other runnable task,
+// whatever their priority, until it is dispatched. Its start time and host
+// limit still apply. Of several promoted tasks the queue order decides.
+func (s *SmartScheduler) Promote(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.promoted[id] = true
+}
+
 // OnTaskStarted should be called by Engine when a task starts downloading
 func (s *SmartScheduler) OnTaskStarted(task *storage.DownloadTask) {
 	s.mu.Lock()
@@ -62,62 +165,143 @@ func (s *SmartScheduler) OnTaskCompleted(task *storage.DownloadTask) {
 }
 
 // GetNextTask returns the next eligible task from the queue
-// regarding priority and host limits
+// regarding promotions (see Promote), the dispatch order (see SetOrder) and
+// host limits. Tasks held
+// back by their host limit gain a priority level the longer they wait, and
+// a task starved by its host limit holds back the last free slot
+// so it can run as soon as its host frees up.
 func (s *SmartScheduler) GetNextTask(activeCount, maxConcurrent int) *storage.DownloadTask {
-	// First check global concurrency
-	if activeCount >= maxConcurrent {
-		// Preemption Check?
-		// Logic: If queue has High Priority and Running has Low Priority...
-		// But GetNextTask usually assumes we have a slot.
-		// If we don't have a slot, we shouldn't ask for a task unless we want to preempt?
-		// Engine calls this loop.
+	s.mu.Lock()
+	picked := s.nextLocked(activeCount, maxConcurrent)
+	s.mu.Unlock()
+
+	if picked == nil {
 		return nil
 	}
+	// The queued task may have been updated since the snapshot
+	picked = s.queue.Take(picked.ID)
+	if picked == nil {
+		return nil
+	}
+	s.mu.Lock()
+	delete(s.queuedSince, picked.ID)
+	delete(s.blockedSince, picked.ID)
+	delete(s.promoted, picked.ID)
+	s.mu.Unlock()
+	return picked
+}
 
-	// Iterate queue and find first "Runnable" task
-	// We need to peek/iterate the queue without popping until we find one.
-	// Current DownloadQueue might need iteration support.
-
-	// Assuming Queue exposes Items or Iterator.
-	// We'll add this to DownloadQueue.
+// NextTaskID returns the ID of the task GetNextTask would dispatch with
+// activeCount downloads running, without taking it off the queue, or "".
+func (s *SmartScheduler) NextTaskID(activeCount, maxConcurrent int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if picked := s.nextLocked(activeCount, maxConcurrent); picked != nil {
+		return picked.ID
+	}
+	return ""
+}
 
-	candidates := s.queue.GetAll() // Snapshot
+// nextLocked picks the next task from a snapshot of the queue and updates
+// the wait times used for aging.
+func (s *SmartScheduler) nextLocked(activeCount, maxConcurrent int) *storage.DownloadTask {
+	// Snapshot under s.mu, so a task promoted meanwhile is already queued
+	candidates := s.queue.GetAll()
+	now := s.now()
+	queued := make(map[string]bool, len(candidates))
+	runnable := make([]*storage.DownloadTask, 0, len(candidates))
 	for _, task := range candidates {
-		// 1. Check Schedule
+		queued[task.ID] = true
 		if task.StartTime != "" {
 			t, err := time.Parse(time.RFC3339, task.StartTime)
-			if err == nil && time.Now().Before(t) {
+			if err == nil && now.Before(t) {
 				continue // Too early
 			}
 		}
+		if _, ok := s.queuedSince[task.ID]; !ok {
+			s.queuedSince[task.ID] = now
+		}
+		if _, ok := s.blockedSince[task.ID]; !ok && s.hostFullLocked(extractDomain(task.URL)) {
+			s.blockedSince[task.ID] = now
+		}
+		runnable = append(runnable, task)
+	}
+	for id := range s.queuedSince {
+		if !queued[id] {
+			delete(s.queuedSince, id)
+			delete(s.blockedSince, id)
+		}
+	}
+	for id := range s.promoted {
+		if !queued[id] {
+			delete(s.promoted, id)
+		}
+	}
 
-		// 2. Check Host Limits
-		domain := extractDomain(task.URL)
-		limit := s.GetHostLimit(domain)
+	// Wait times are tracked above even when there is no free slot
+	if activeCount >= maxConcurrent {
+		return nil
+	}
 
-		s.mu.Lock()
-		active := s.activePerHost[domain]
-		s.mu.Unlock()
+	// Promoted tasks first, then the highest effective priority, then by
+	// size in the size orders; the queue order breaks ties
+	effective := make(map[string]int, len(runnable))
+	if s.order != OrderFIFO {
+		for _, task := range runnable {
+			effective[task.ID] = s.effectivePriorityLocked(task, now)
+		}
+	}
+	if s.order != OrderFIFO || len(s.promoted) > 0 {
+		order := s.order
+		sort.SliceStable(runnable, func(i, j int) bool {
+			a, b := runnable[i], runnable[j]
+			if s.promoted[a.ID] != s.promoted[b.ID] {
+				return s.promoted[a.ID]
+			}
+			if effective[a.ID] != effective[b.ID] {
+				return effective[a.ID] > effective[b.ID]
+			}
+			return sizeBefore(order, a.TotalSize, b.TotalSize)
+		})
+	}
 
-		if limit > 0 && active >= limit {
+	reserved := false
+	var picked *storage.DownloadTask
+	for _, task := range runnable {
+		if s.hostFullLocked(extractDomain(task.URL)) {
+			if s.starveAfter > 0 && now.Sub(s.queuedSince[task.ID]) >= s.starveAfter {
+				reserved = true
+			}
 			continue // Host limit reached
 		}
-
-		// Found a candidate!
-		// We need to Pop THIS SPECIFIC task from the queue.
-		// Queue.Remove(id) ??
-		// Or Queue.Pop() if it's the first one.
-		// If it's not the first one, we are "Skipping" previous ones.
-		// Users might be confused if order is 1, 2, 3 and 3 starts.
-		// But that's "Smart Scheduling".
-
-		removed := s.queue.Remove(task.ID)
-		if removed {
-			return task
+		if reserved && activeCount+1 >= maxConcurrent {
+			break // Keep the last slot for the starved task
 		}
+		picked = task
+		break
 	}
+	return picked
+}
 
-	return nil
+// hostFullLocked reports whether domain is at its concurrent download limit.
+func (s *SmartScheduler) hostFullLocked(domain string) bool {
+	limit := s.hostLimits[domain]
+	return limit > 0 && s.activePerHost[domain] >= limit
+}
+
+// sizeBefore reports whether a task of size a goes before one of size b in
+// the given order. Unknown sizes (<= 0) go last in both size orders.
+func sizeBefore(order string, a, b int64) bool {
+	if order != OrderSizeAsc && order != OrderSizeDesc {
+		return false
+	}
+	if a <= 0 || b <= 0 {
+		return a > 0 && b <= 0
+	}
+	if order == OrderSizeAsc {
+		return a < b
+	}
+	return a > b
 }
 
 func extractDomain(urlStr string) string {
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"project-tachyon/internal/storage"
@@ -103,6 +104,234 @@ func TestSmartScheduler_OnTaskCompletedDecrementsCount(t *testing.T) {
 	}
 }
 
+// fakeClock lets tests advance the scheduler's notion of time.
+func fakeClock(sched *SmartScheduler) *time.Time {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sched.now = func() time.Time { return now }
+	return &now
+}
+
+func TestSmartScheduler_PrefersHigherPriority(t *testing.T) {
+	sched, q := newTestScheduler()
+	q.Push(&storage.DownloadTask{ID: "low", URL: "https://a.com/1", QueueOrder: 1, Priority: 0})
+	q.Push(&storage.DownloadTask{ID: "high", URL: "https://b.com/2", QueueOrder: 2, Priority: 2})
+
+	task := sched.GetNextTask(0, 5)
+	if task == nil || task.ID != "high" {
+		t.Fatalf("expected high priority task first, got %+v", task)
+	}
+}
+
+func TestSmartScheduler_PromotedBeatsHigherPriority(t *testing.T) {
+	sched, q := newTestScheduler()
+	q.Push(&storage.DownloadTask{ID: "high", URL: "https://a.com/1", QueueOrder: 1, Priority: 2})
+	q.Push(&storage.DownloadTask{ID: "normal", URL: "https://b.com/2", QueueOrder: 2, Priority: 1})
+	sched.Promote("normal")
+
+	if id := sched.NextTaskID(0, 1); id != "normal" {
+		t.Fatalf("NextTaskID = %q, want the promoted task", id)
+	}
+	if q.Len() != 2 {
+		t.Fatal("NextTaskID must leave the queue alone")
+	}
+	if task := sched.GetNextTask(0, 1); task == nil || task.ID != "normal" {
+		t.Fatalf("expected the promoted task, got %+v", task)
+	}
+	// The promotion is used up once dispatched
+	q.Push(&storage.DownloadTask{ID: "normal", URL: "https://b.com/2", QueueOrder: 3, Priority: 1})
+	if task := sched.GetNextTask(0, 1); task == nil || task.ID != "high" {
+		t.Fatalf("expected high after the promotion, got %+v", task)
+	}
+
+	// A promoted task its host can't take yet doesn't hold others back
+	sched.SetHostLimit("b.com", 1)
+	sched.OnTaskStarted(&storage.DownloadTask{ID: "running", URL: "https://b.com/9"})
+	sched.Promote("normal")
+	q.Push(&storage.DownloadTask{ID: "low", URL: "https://c.com/3", QueueOrder: 4, Priority: 0})
+	if id := sched.NextTaskID(1, 2); id != "low" {
+		t.Fatalf("NextTaskID = %q, want low while the promoted task's host is full", id)
+	}
+}
+
+func TestSmartScheduler_Order(t *testing.T) {
+	tasks := []storage.DownloadTask{
+		{ID: "mid", TotalSize: 50 << 20, Priority: 1},
+		{ID: "unknown", Priority: 1},
+		{ID: "small", TotalSize: 1 << 20, Priority: 1},
+		{ID: "huge", TotalSize: 4 << 30, Priority: 1},
+		{ID: "low-tiny", TotalSize: 1024, Priority: 0},
+	}
+	cases := map[string][]string{
+		OrderPriority: {"mid", "unknown", "small", "huge", "low-tiny"},
+		OrderFIFO:     {"mid", "unknown", "small", "huge", "low-tiny"},
+		OrderSizeAsc:  {"small", "mid", "huge", "unknown", "low-tiny"},
+		OrderSizeDesc: {"huge", "mid", "small", "unknown", "low-tiny"},
+	}
+	for mode, want := range cases {
+		t.Run(mode, func(t *testing.T) {
+			sched, q := newTestScheduler()
+			sched.SetAging(0, 0)
+			if err := sched.SetOrder(mode); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tasks {
+				task := tasks[i]
+				task.URL = fmt.Sprintf("https://host%d.com/f", i)
+				task.QueueOrder = i + 1
+				q.Push(&task)
+			}
+			var got []string
+			for task := sched.GetNextTask(0, 10); task != nil; task = sched.GetNextTask(0, 10) {
+				got = append(got, task.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("dispatch order = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Priority still comes first outside FIFO, but not in it
+	sched, q := newTestScheduler()
+	sched.SetOrder(OrderFIFO)
+	q.Push(&storage.DownloadTask{ID: "first", URL: "https://a.com/1", QueueOrder: 1, Priority: 0})
+	q.Push(&storage.DownloadTask{ID: "high", URL: "https://b.com/2", QueueOrder: 2, Priority: 2})
+	if task := sched.GetNextTask(0, 5); task == nil || task.ID != "first" {
+		t.Errorf("FIFO dispatched %+v first, want the oldest task", task)
+	}
+}
+
+func TestSmartScheduler_SetOrder(t *testing.T) {
+	sched, _ := newTestScheduler()
+	if got := sched.GetOrder(); got != OrderPriority {
+		t.Fatalf("default order = %q", got)
+	}
+	if err := sched.SetOrder("random"); err == nil {
+		t.Error("unknown order accepted")
+	}
+	sched.SetOrder(OrderSizeAsc)
+	if err := sched.SetOrder(""); err != nil || sched.GetOrder() != OrderPriority {
+		t.Errorf("empty order: err=%v order=%q", err, sched.GetOrder())
+	}
+}
+
+func TestSmartScheduler_AgingRaisesHostBlockedTask(t *testing.T) {
+	sched, q := newTestScheduler()
+	now := fakeClock(sched)
+	sched.SetHostLimit("slow.com", 1)
+	running := &storage.DownloadTask{ID: "s1", URL: "https://slow.com/1"}
+	sched.OnTaskStarted(running)
+
+	q.Push(&storage.DownloadTask{ID: "low", URL: "https://slow.com/2", QueueOrder: 1, Priority: 0})
+	if task := sched.GetNextTask(1, 5); task != nil {
+		t.Fatalf("dispatched %s while its host was full", task.ID)
+	}
+
+	// However long it was held back, "low" gains one level only: level
+	// with a fresh normal task, where queue order breaks the tie, but
+	// still below a fresh high one
+	*now = now.Add(10 * DefaultAgingStep)
+	if w := sched.WaitTime("low"); w != 10*DefaultAgingStep {
+		t.Fatalf("WaitTime = %v, want %v", w, 10*DefaultAgingStep)
+	}
+	sched.OnTaskCompleted(running)
+	q.Push(&storage.DownloadTask{ID: "high", URL: "https://b.com/1", QueueOrder: 2, Priority: 2})
+	q.Push(&storage.DownloadTask{ID: "normal", URL: "https://c.com/1", QueueOrder: 3, Priority: 1})
+	for _, want := range []string{"high", "low", "normal"} {
+		if task := sched.GetNextTask(0, 5); task == nil || task.ID != want {
+			t.Fatalf("dispatched %+v, want %s", task, want)
+		}
+	}
+	if sched.WaitTime("low") != 0 {
+		t.Fatal("wait time should be cleared once dispatched")
+	}
+}
+
+func TestSmartScheduler_HighPriorityBeatsLongWaitingLow(t *testing.T) {
+	sched, q := newTestScheduler()
+	now := fakeClock(sched)
+
+	q.Push(&storage.DownloadTask{ID: "low", URL: "https://a.com/1", QueueOrder: 1, Priority: 0})
+	if task := sched.GetNextTask(1, 1); task != nil {
+		t.Fatal("should not dispatch at the global limit")
+	}
+
+	// Waiting behind a full queue is not starvation; priority still rules
+	*now = now.Add(time.Hour)
+	q.Push(&storage.DownloadTask{ID: "high", URL: "https://b.com/2", QueueOrder: 2, Priority: 2})
+	if task := sched.GetNextTask(0, 5); task == nil || task.ID != "high" {
+		t.Fatalf("expected the high-priority task first, got %+v", task)
+	}
+}
+
+func TestSmartScheduler_HostLimitedTaskDoesNotStarve(t *testing.T) {
+	sched, q := newTestScheduler()
+	now := fakeClock(sched)
+	sched.SetHostLimit("slow.com", 1)
+
+	// slow.com already has a long-running download
+	running := &storage.DownloadTask{ID: "s1", URL: "https://slow.com/1"}
+	sched.OnTaskStarted(running)
+	active := 1
+	const maxConcurrent = 2
+
+	q.Push(&storage.DownloadTask{ID: "s2", URL: "https://slow.com/2", QueueOrder: 1, Priority: 0})
+
+	// A steady stream of normal-priority downloads from another host: each
+	// round the previous one finishes and a new one arrives.
+	var otherRunning *storage.DownloadTask
+	for round := 0; round < 20; round++ {
+		if otherRunning != nil {
+			sched.OnTaskCompleted(otherRunning)
+			active--
+			otherRunning = nil
+		}
+		q.Push(&storage.DownloadTask{ID: fmt.Sprintf("f%d", round), URL: "https://fast.com/f", QueueOrder: round + 2, Priority: 1})
+
+		// The slow host frees up only after the starvation threshold
+		if round == 6 {
+			sched.OnTaskCompleted(running)
+			active--
+		}
+
+		task := sched.GetNextTask(active, maxConcurrent)
+		if task != nil && task.ID == "s2" {
+			if round < 6 {
+				t.Fatalf("s2 dispatched at round %d while its host was full", round)
+			}
+			return
+		}
+		if task != nil {
+			sched.OnTaskStarted(task)
+			active++
+			otherRunning = task
+		}
+		*now = now.Add(DefaultAgingStep / 2)
+	}
+	t.Fatal("host-limited task starved")
+}
+
+func TestSmartScheduler_StarvedTaskReservesLastSlot(t *testing.T) {
+	sched, q := newTestScheduler()
+	now := fakeClock(sched)
+	sched.SetAging(0, time.Minute) // reservation only
+	sched.SetHostLimit("slow.com", 1)
+	sched.OnTaskStarted(&storage.DownloadTask{ID: "s1", URL: "https://slow.com/1"})
+
+	q.Push(&storage.DownloadTask{ID: "s2", URL: "https://slow.com/2", QueueOrder: 1})
+	q.Push(&storage.DownloadTask{ID: "f1", URL: "https://fast.com/1", QueueOrder: 2})
+	q.Push(&storage.DownloadTask{ID: "f2", URL: "https://fast.com/2", QueueOrder: 3})
+
+	// Before starvation other hosts use the free slots
+	if task := sched.GetNextTask(1, 3); task == nil || task.ID != "f1" {
+		t.Fatalf("expected f1, got %+v", task)
+	}
+
+	*now = now.Add(time.Minute)
+	if task := sched.GetNextTask(2, 3); task != nil {
+		t.Fatalf("last slot should be held for the starved task, got %s", task.ID)
+	}
+}
+
 func TestExtractDomain(t *testing.T) {
 	tests := []struct {
 		url  string
@@ -119,3 +348,42 @@ func TestExtractDomain(t *testing.T) {
 		}
 	}
 }
+
+func TestSmartScheduler_PriorityChangeWhileDispatching(t *testing.T) {
+	sched, q := newTestScheduler()
+	for i := 0; i < 500; i++ {
+		q.Push(&storage.DownloadTask{ID: fmt.Sprintf("t%d", i), URL: "https://a.com/f", QueueOrder: i + 1})
+	}
+
+	// Run with -race: the scheduler sorts by priority while it changes
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			id := fmt.Sprintf("t%d", i%500)
+			q.SetPriority([]string{id}, 2)
+			q.SetConnections(id, 4)
+		}
+	}()
+	var dispatched []*storage.DownloadTask
+	for len(dispatched) < 500 {
+		if task := sched.GetNextTask(0, 100); task != nil {
+			dispatched = append(dispatched, task)
+		}
+	}
+	close(stop)
+	<-done
+
+	// A dispatched task carries the queue's latest values
+	for _, task := range dispatched {
+		if task.Priority != 0 && task.Priority != 2 {
+			t.Errorf("%s dispatched with priority %d", task.ID, task.Priority)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package security
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -25,6 +26,10 @@ type Scanner interface {
 	IsAvailable() bool
 }
 
+// ErrThreatDetected is wrapped by ScanFile errors that report a threat, as
+// opposed to a scan that could not run.
+var ErrThreatDetected = errors.New("threat detected")
+
 // ScanResult represents the outcome of a scan
 type ScanResult struct {
 	Clean   bool
@@ -122,7 +127,7 @@ func (s *WindowsDefenderScanner) ScanFile(ctx context.Context, filePath string)
 				output := stdout.String()
 				threat := parseThreatFromOutput(output)
 				s.logger.Warn("Threat detected by AV", "file", filePath, "threat", threat)
-				return fmt.Errorf("threat detected: %s", threat)
+				return fmt.Errorf("%w: %s", ErrThreatDetected, threat)
 			default:
 				// Other error (file not found, permission denied, etc.)
 				s.logger.Warn("AV scan failed", "file", filePath, "exitCode", exitCode, "stderr", stderr.String())
@@ -318,7 +323,7 @@ func (s *ClamAVScanner) ScanFile(ctx context.Context, filePath string) error {
 		// Extract virus name
 		threat := parseClamAVThreat(result)
 		s.logger.Warn("ClamAV detected threat", "file", filePath, "threat", threat)
-		return fmt.Errorf("threat detected: %s", threat)
+		return fmt.Errorf("%w: %s", ErrThreatDetected, threat)
 	}
 
 	// Unexpected response
@@ -0,0 +1,68 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// eicarTestFile is the standard EICAR antivirus test file: harmless, but
+// every scanner is expected to report it as a threat.
+const eicarTestFile = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// SelfTestResult reports whether a scanner caught the EICAR test file.
+type SelfTestResult struct {
+	Scanner   string `json:"scanner"`
+	Available bool   `json:"available"`
+	Detected  bool   `json:"detected"` // the test file was reported as a threat
+	Message   string `json:"message"`
+}
+
+// SelfTest writes the EICAR test file to dir (the system temp folder if
+// empty), scans it with s and removes it again. A scanner that is working
+// reports it as a threat; real-time protection removing it before the scan
+// counts as a detection too.
+func SelfTest(ctx context.Context, s Scanner, dir string) SelfTestResult {
+	res := SelfTestResult{Scanner: s.Name(), Available: s.IsAvailable()}
+	if !res.Available {
+		res.Message = "No antivirus scanner is available, so downloads are not scanned"
+		return res
+	}
+
+	f, err := os.CreateTemp(dir, "tachyon-eicar-*.com")
+	if err != nil {
+		res.Message = fmt.Sprintf("Could not create the test file: %v", err)
+		return res
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	_, err = f.WriteString(eicarTestFile)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		res.Message = fmt.Sprintf("Could not write the test file: %v", err)
+		return res
+	}
+
+	err = s.ScanFile(ctx, path)
+	switch {
+	case errors.Is(err, ErrThreatDetected):
+		res.Detected = true
+		res.Message = fmt.Sprintf("%s detected the test file: %v", res.Scanner, err)
+	case err != nil && isMissing(path):
+		res.Detected = true
+		res.Message = fmt.Sprintf("%s removed the test file before it could be scanned", res.Scanner)
+	case err != nil:
+		res.Message = fmt.Sprintf("Scan failed: %v", err)
+	default:
+		res.Message = fmt.Sprintf("%s did not detect the test file", res.Scanner)
+	}
+	return res
+}
+
+func isMissing(path string) bool {
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -15,45 +17,86 @@ import (
 // Storage handles all database operations using SQLite
 type Storage struct {
 	DB *gorm.DB
+
+	// reader is a read-only connection pool for queries; nil sends them
+	// through DB
+	reader *gorm.DB
 }
 
-// NewStorage initializes the SQLite database connection
-func NewStorage() (*Storage, error) {
-	// Get app data directory
+// Options tune the SQLite connections.
+type Options struct {
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection or process before failing with "database is locked".
+	BusyTimeout time.Duration
+	// MaxReaders sizes the read-only connection pool queries use, so they
+	// run alongside writes under WAL. 0 sends reads through the writer.
+	MaxReaders int
+}
+
+// DefaultOptions returns the options NewStorage and NewStorageWithPath use.
+func DefaultOptions() Options {
+	return Options{BusyTimeout: 5 * time.Second, MaxReaders: 4}
+}
+
+// DefaultPath returns where the database lives in the user's config dir,
+// creating the directory if needed.
+func DefaultPath() (string, error) {
 	appData, err := os.UserConfigDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config dir: %w", err)
+		return "", fmt.Errorf("failed to get config dir: %w", err)
 	}
 
 	dbDir := filepath.Join(appData, "Tachyon")
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create db dir: %w", err)
+		return "", fmt.Errorf("failed to create db dir: %w", err)
 	}
+	return filepath.Join(dbDir, "tachyon.db"), nil
+}
 
-	dbPath := filepath.Join(dbDir, "tachyon.db")
+// NewStorage initializes the SQLite database connection
+func NewStorage() (*Storage, error) {
+	dbPath, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
 	return NewStorageWithPath(dbPath)
 }
 
 // NewStorageWithPath opens a SQLite database at the specified path.
 func NewStorageWithPath(dbPath string) (*Storage, error) {
+	return Open(dbPath, DefaultOptions())
+}
+
+// Open opens the SQLite database at dbPath with opts.
+//
+// SQLite allows one writer at a time, so all writes go through a single
+// connection: they queue on database/sql's pool instead of colliding in
+// SQLite's lock and failing with "database is locked" once the busy
+// timeout runs out. Reads use a separate pool of read-only connections,
+// which WAL lets run while a write is in progress.
+func Open(dbPath string, opts Options) (*Storage, error) {
+	if opts.BusyTimeout < 0 || opts.MaxReaders < 0 {
+		return nil, fmt.Errorf("invalid storage options %+v", opts)
+	}
+	// Pragmas go in the DSN so that every connection gets them, including
+	// one opened to replace a connection the pool dropped.
+	pragmas := url.Values{"_pragma": {
+		fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+		"journal_mode(WAL)",
+		"synchronous(NORMAL)",
+		"cache_size(10000)",
+	}}
+	// Take the write lock when a transaction begins, not at its first
+	// write, so a read-then-write transaction never has to upgrade a lock
+	pragmas.Set("_txlock", "immediate")
 
 	// Open SQLite with Glebarez (Pure Go, no CGO)
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	db, err := gorm.Open(sqlite.Open(dbPath+"?"+pragmas.Encode()), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-
-	// Enable WAL mode for better concurrency
-	db.Exec("PRAGMA journal_mode=WAL;")
-	db.Exec("PRAGMA synchronous=NORMAL;")
-	db.Exec("PRAGMA cache_size=10000;")
-	db.Exec("PRAGMA busy_timeout=5000;")
-
-	// SQLite only supports one writer at a time.  Constraining the pool to a
-	// single connection serializes all access through Go's sql.DB mutex which
-	// is far cheaper than hitting SQLite's internal busy-retry loop.
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
@@ -68,16 +111,55 @@ func NewStorageWithPath(dbPath string) (*Storage, error) {
 		&DailyStat{},
 		&AppSetting{},
 		&SpeedTestHistory{},
+		&DownloadSet{},
+		&HostProfile{},
 	)
 	if err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return &Storage{DB: db}, nil
+	s := &Storage{DB: db}
+	// Every connection to an in-memory database sees a database of its own
+	if opts.MaxReaders > 0 && dbPath != ":memory:" {
+		readPragmas := url.Values{"_pragma": {
+			fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+			"query_only(1)",
+		}}
+		reader, err := gorm.Open(sqlite.Open(dbPath+"?"+readPragmas.Encode()), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to open read connections: %w", err)
+		}
+		readDB, err := reader.DB()
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to get sql.DB: %w", err)
+		}
+		readDB.SetMaxOpenConns(opts.MaxReaders)
+		readDB.SetMaxIdleConns(opts.MaxReaders)
+		s.reader = reader
+	}
+	return s, nil
+}
+
+// read returns the connections queries should use.
+func (s *Storage) read() *gorm.DB {
+	if s.reader != nil {
+		return s.reader
+	}
+	return s.DB
 }
 
-// Close closes the database connection
+// Close closes the database connections
 func (s *Storage) Close() error {
+	if s.reader != nil {
+		if readDB, err := s.reader.DB(); err == nil {
+			readDB.Close()
+		}
+	}
 	sqlDB, err := s.DB.DB()
 	if err != nil {
 		return err
@@ -85,9 +167,23 @@ func (s *Storage) Close() error {
 	return sqlDB.Close()
 }
 
+// Ping checks that the database connection is open and answering
+func (s *Storage) Ping(ctx context.Context) error {
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 // Checkpoint forces a WAL checkpoint to ensure durability
 func (s *Storage) Checkpoint() error {
-	return s.DB.Exec("PRAGMA wal_checkpoint(TRUNCATE);").Error
+	return s.CheckpointContext(context.Background())
+}
+
+// CheckpointContext is Checkpoint bounded by ctx
+func (s *Storage) CheckpointContext(ctx context.Context) error {
+	return s.DB.WithContext(ctx).Exec("PRAGMA wal_checkpoint(TRUNCATE);").Error
 }
 
 // ============= Task Management =============
@@ -113,6 +209,16 @@ func (s *Storage) SaveTasks(tasks []DownloadTask) error {
 	})
 }
 
+// UpdatePriority sets the priority of several tasks in a single statement
+func (s *Storage) UpdatePriority(ids []string, priority int) error {
+	return s.DB.Model(&DownloadTask{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"priority":   priority,
+			"updated_at": time.Now().Format(time.RFC3339),
+		}).Error
+}
+
 // SaveTaskAtomic applies multiple field updates to a task inside a single
 // database transaction.  The caller passes a mutator that modifies the task
 // in-place; all changes are committed atomically or rolled back on error.
@@ -128,10 +234,42 @@ func (s *Storage) SaveTaskAtomic(id string, mutate func(task *DownloadTask)) err
 	})
 }
 
+// TaskCheckpoint is the resume state of an interrupted download
+type TaskCheckpoint struct {
+	ID         string
+	MetaJSON   string // "" keeps the stored state
+	Downloaded int64
+	Progress   float64
+}
+
+// SaveCheckpoints marks downloads paused with their resume state in a single
+// transaction, giving up when ctx is done
+func (s *Storage) SaveCheckpoints(ctx context.Context, checkpoints []TaskCheckpoint) error {
+	now := time.Now().Format(time.RFC3339)
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, cp := range checkpoints {
+			updates := map[string]interface{}{
+				"status":     StatusPaused,
+				"downloaded": cp.Downloaded,
+				"progress":   cp.Progress,
+				"speed":      0,
+				"updated_at": now,
+			}
+			if cp.MetaJSON != "" {
+				updates["meta_json"] = cp.MetaJSON
+			}
+			if err := tx.Model(&DownloadTask{}).Where("id = ?", cp.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // GetTask retrieves a specific task by ID
 func (s *Storage) GetTask(id string) (DownloadTask, error) {
 	var task DownloadTask
-	err := s.DB.First(&task, "id = ?", id).Error
+	err := s.read().First(&task, "id = ?", id).Error
 	return task, err
 }
 
@@ -139,7 +277,14 @@ func (s *Storage) GetTask(id string) (DownloadTask, error) {
 func (s *Storage) GetTaskByURL(url string) (DownloadTask, error) {
 	var task DownloadTask
 	// We want the most recent one if duplicates exist?
-	err := s.DB.Where("url = ?", url).Order("created_at desc").First(&task).Error
+	err := s.read().Where("url = ?", url).Order("created_at desc").First(&task).Error
+	return task, err
+}
+
+// GetTaskBySavePath returns the task whose file lives at path
+func (s *Storage) GetTaskBySavePath(path string) (DownloadTask, error) {
+	var task DownloadTask
+	err := s.read().Where("save_path = ?", path).Order("created_at desc").First(&task).Error
 	return task, err
 }
 
@@ -147,14 +292,14 @@ func (s *Storage) GetTaskByURL(url string) (DownloadTask, error) {
 // GetAllTasks returns all non-deleted tasks, newest first
 func (s *Storage) GetAllTasks() ([]DownloadTask, error) {
 	var tasks []DownloadTask
-	err := s.DB.Order("created_at desc").Find(&tasks).Error
+	err := s.read().Order("created_at desc").Find(&tasks).Error
 	return tasks, err
 }
 
 // GetTasksByStatus returns tasks filtered by status
 func (s *Storage) GetTasksByStatus(status string, limit int) ([]DownloadTask, error) {
 	var tasks []DownloadTask
-	query := s.DB.Where("status = ?", status).Order("created_at desc")
+	query := s.read().Where("status = ?", status).Order("created_at desc")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -165,12 +310,19 @@ func (s *Storage) GetTasksByStatus(status string, limit int) ([]DownloadTask, er
 // GetActiveTasks returns all downloading or pending tasks
 func (s *Storage) GetActiveTasks() ([]DownloadTask, error) {
 	var tasks []DownloadTask
-	err := s.DB.Where("status IN ?", []string{"downloading", "pending"}).
+	err := s.read().Where("status IN ?", []string{"downloading", "pending"}).
 		Order("created_at asc").
 		Find(&tasks).Error
 	return tasks, err
 }
 
+// CountTasksByStatus returns how many tasks are in any of the given statuses
+func (s *Storage) CountTasksByStatus(statuses []Status) (int64, error) {
+	var n int64
+	err := s.read().Model(&DownloadTask{}).Where("status IN ?", statuses).Count(&n).Error
+	return n, err
+}
+
 // DeleteTask permanently deletes a task
 func (s *Storage) DeleteTask(id string) error {
 	return s.DB.Unscoped().Delete(&DownloadTask{}, "id = ?", id).Error
@@ -196,6 +348,60 @@ func (s *Storage) UpdateTaskProgress(id string, progress float64, downloaded int
 	}).Error
 }
 
+// ============= Download Sets =============
+
+// SaveDownloadSet creates or updates a download set
+func (s *Storage) SaveDownloadSet(set DownloadSet) error {
+	return s.DB.Save(&set).Error
+}
+
+// GetDownloadSet retrieves a download set by ID
+func (s *Storage) GetDownloadSet(id string) (DownloadSet, error) {
+	var set DownloadSet
+	err := s.read().First(&set, "id = ?", id).Error
+	return set, err
+}
+
+// GetDownloadSets returns all download sets, newest first
+func (s *Storage) GetDownloadSets() ([]DownloadSet, error) {
+	var sets []DownloadSet
+	err := s.read().Order("created_at desc").Find(&sets).Error
+	return sets, err
+}
+
+// GetTasksBySet returns the tasks of a download set in queue order
+func (s *Storage) GetTasksBySet(setID string) ([]DownloadTask, error) {
+	var tasks []DownloadTask
+	err := s.read().Where("set_id = ?", setID).Order("queue_order asc").Find(&tasks).Error
+	return tasks, err
+}
+
+// DeleteDownloadSet removes a download set. Its tasks are left alone.
+func (s *Storage) DeleteDownloadSet(id string) error {
+	return s.DB.Delete(&DownloadSet{}, "id = ?", id).Error
+}
+
+// ============= Host Profiles =============
+
+// SaveHostProfile creates or updates a host's learned profile
+func (s *Storage) SaveHostProfile(profile HostProfile) error {
+	return s.DB.Save(&profile).Error
+}
+
+// GetHostProfile retrieves the learned profile of a host
+func (s *Storage) GetHostProfile(host string) (HostProfile, error) {
+	var profile HostProfile
+	err := s.read().First(&profile, "host = ?", host).Error
+	return profile, err
+}
+
+// GetHostProfiles returns every learned host profile
+func (s *Storage) GetHostProfiles() ([]HostProfile, error) {
+	var profiles []HostProfile
+	err := s.read().Find(&profiles).Error
+	return profiles, err
+}
+
 // ============= Download Locations =============
 
 // AddLocation adds or updates a download location
@@ -210,10 +416,20 @@ func (s *Storage) AddLocation(path, nickname string) error {
 // GetLocations returns all saved download locations
 func (s *Storage) GetLocations() ([]DownloadLocation, error) {
 	var locations []DownloadLocation
-	err := s.DB.Find(&locations).Error
+	err := s.read().Find(&locations).Error
 	return locations, err
 }
 
+// SetLocationWriteLimit sets the disk write cap for a download location in
+// bytes/sec (0 = unlimited), adding the location if it is not saved yet
+func (s *Storage) SetLocationWriteLimit(path string, bytesPerSec int64) error {
+	loc := DownloadLocation{Path: path, WriteLimit: bytesPerSec}
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"write_limit"}),
+	}).Create(&loc).Error
+}
+
 // DeleteLocation removes a download location
 func (s *Storage) DeleteLocation(path string) error {
 	return s.DB.Delete(&DownloadLocation{}, "path = ?", path).Error
@@ -259,21 +475,21 @@ func (s *Storage) IncrementDailyFiles() error {
 // GetTotalLifetime returns total bytes downloaded all-time using SQL SUM
 func (s *Storage) GetTotalLifetime() (int64, error) {
 	var total int64
-	err := s.DB.Model(&DailyStat{}).Select("IFNULL(SUM(bytes), 0)").Row().Scan(&total)
+	err := s.read().Model(&DailyStat{}).Select("IFNULL(SUM(bytes), 0)").Row().Scan(&total)
 	return total, err
 }
 
 // GetTotalFiles returns total files downloaded all-time using SQL SUM
 func (s *Storage) GetTotalFiles() (int64, error) {
 	var total int64
-	err := s.DB.Model(&DailyStat{}).Select("IFNULL(SUM(files), 0)").Row().Scan(&total)
+	err := s.read().Model(&DailyStat{}).Select("IFNULL(SUM(files), 0)").Row().Scan(&total)
 	return total, err
 }
 
 // GetDailyHistory returns the last N days of stats
 func (s *Storage) GetDailyHistory(days int) ([]DailyStat, error) {
 	var stats []DailyStat
-	err := s.DB.Order("date desc").Limit(days).Find(&stats).Error
+	err := s.read().Order("date desc").Limit(days).Find(&stats).Error
 	return stats, err
 }
 
@@ -294,7 +510,7 @@ func (s *Storage) GetStatInt(key string) (int64, error) {
 // GetString retrieves a string setting by key
 func (s *Storage) GetString(key string) (string, error) {
 	var setting AppSetting
-	err := s.DB.First(&setting, "key = ?", key).Error
+	err := s.read().First(&setting, "key = ?", key).Error
 	if err == gorm.ErrRecordNotFound {
 		return "", nil
 	}
@@ -332,6 +548,8 @@ func (s *Storage) FactoryReset() error {
 			"daily_stats",
 			"speed_test_history",
 			"download_locations",
+			"download_sets",
+			"host_profiles",
 			"app_settings", // Assuming we persist settings in DB later
 		}
 
@@ -396,7 +614,7 @@ func (s *Storage) SaveSpeedTest(history SpeedTestHistory) error {
 // GetSpeedTestHistory returns the last N speed tests
 func (s *Storage) GetSpeedTestHistory(limit int) ([]SpeedTestHistory, error) {
 	var history []SpeedTestHistory
-	err := s.DB.Order("timestamp desc").Limit(limit).Find(&history).Error
+	err := s.read().Order("timestamp desc").Limit(limit).Find(&history).Error
 	return history, err
 }
 
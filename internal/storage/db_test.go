@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,6 +30,7 @@ func setupTestDB(t *testing.T) *Storage {
 		&DownloadLocation{},
 		&DailyStat{},
 		&AppSetting{},
+		&HostProfile{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
@@ -165,6 +169,37 @@ func TestStatistics(t *testing.T) {
 	}
 }
 
+func TestSaveCheckpoints(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+
+	s.SaveTask(DownloadTask{ID: "a", URL: "http://x/a", Status: StatusDownloading, MetaJSON: "old"})
+	s.SaveTask(DownloadTask{ID: "b", URL: "http://x/b", Status: StatusProbing, MetaJSON: "keep"})
+
+	err := s.SaveCheckpoints(context.Background(), []TaskCheckpoint{
+		{ID: "a", MetaJSON: "new", Downloaded: 10, Progress: 50},
+		{ID: "b"},
+	})
+	if err != nil {
+		t.Fatalf("SaveCheckpoints failed: %v", err)
+	}
+
+	a, _ := s.GetTask("a")
+	if a.Status != StatusPaused || a.MetaJSON != "new" || a.Downloaded != 10 || a.Progress != 50 {
+		t.Errorf("unexpected task a: %+v", a)
+	}
+	b, _ := s.GetTask("b")
+	if b.Status != StatusPaused || b.MetaJSON != "keep" {
+		t.Errorf("unexpected task b: status=%s meta=%q", b.Status, b.MetaJSON)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.SaveCheckpoints(ctx, []TaskCheckpoint{{ID: "a"}}); err == nil {
+		t.Error("expected an error once the context is done")
+	}
+}
+
 func TestLocations(t *testing.T) {
 	s := setupTestDB(t)
 	defer s.Close()
@@ -204,6 +239,60 @@ func TestLocations(t *testing.T) {
 	}
 }
 
+func TestLocationWriteLimit(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+
+	if err := s.AddLocation("/mnt/nas", "NAS"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLocationWriteLimit("/mnt/nas", 20*1024*1024); err != nil {
+		t.Fatalf("SetLocationWriteLimit failed: %v", err)
+	}
+	// A limit for an unsaved folder adds it
+	if err := s.SetLocationWriteLimit("/mnt/usb", 5*1024*1024); err != nil {
+		t.Fatalf("SetLocationWriteLimit failed: %v", err)
+	}
+
+	locations, _ := s.GetLocations()
+	limits := map[string]int64{}
+	for _, loc := range locations {
+		limits[loc.Path] = loc.WriteLimit
+		if loc.Path == "/mnt/nas" && loc.Nickname != "NAS" {
+			t.Errorf("nickname lost on update: %q", loc.Nickname)
+		}
+	}
+	if limits["/mnt/nas"] != 20*1024*1024 || limits["/mnt/usb"] != 5*1024*1024 {
+		t.Errorf("unexpected limits: %v", limits)
+	}
+}
+
+func TestHostProfiles(t *testing.T) {
+	s := setupTestDB(t)
+
+	if _, err := s.GetHostProfile("cdn.example.com"); err == nil {
+		t.Fatal("expected error for unknown host")
+	}
+	p := HostProfile{Host: "cdn.example.com", Concurrency: 12, RTTMillis: 40, ErrorRate: 0.05, Downloads: 1}
+	if err := s.SaveHostProfile(p); err != nil {
+		t.Fatalf("SaveHostProfile failed: %v", err)
+	}
+	p.Concurrency, p.Downloads = 10, 2
+	if err := s.SaveHostProfile(p); err != nil {
+		t.Fatalf("SaveHostProfile (update) failed: %v", err)
+	}
+	got, err := s.GetHostProfile("cdn.example.com")
+	if err != nil {
+		t.Fatalf("GetHostProfile failed: %v", err)
+	}
+	if got != p {
+		t.Errorf("GetHostProfile = %+v, want %+v", got, p)
+	}
+	if all, _ := s.GetHostProfiles(); len(all) != 1 {
+		t.Errorf("expected 1 profile, got %d", len(all))
+	}
+}
+
 func TestAppSettings(t *testing.T) {
 	s := setupTestDB(t)
 	defer s.Close()
@@ -239,6 +328,17 @@ func TestAppSettings(t *testing.T) {
 	}
 }
 
+func TestPing(t *testing.T) {
+	s := setupTestDB(t)
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping on an open database: %v", err)
+	}
+	s.Close()
+	if err := s.Ping(context.Background()); err == nil {
+		t.Error("expected Ping to fail once the database is closed")
+	}
+}
+
 func TestNewStorage(t *testing.T) {
 	// Skip this test if we can't create a temp directory
 	tmpDir := filepath.Join(os.TempDir(), "tachyon_test_db")
@@ -249,3 +349,121 @@ func TestNewStorage(t *testing.T) {
 	// So we just verify the function signature works
 	t.Log("NewStorage function exists and can be called")
 }
+
+func TestUpdatePriority(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.SaveTask(DownloadTask{ID: id, Priority: 1}); err != nil {
+			t.Fatalf("SaveTask failed: %v", err)
+		}
+	}
+
+	if err := s.UpdatePriority([]string{"a", "c"}, 0); err != nil {
+		t.Fatalf("UpdatePriority failed: %v", err)
+	}
+
+	for id, want := range map[string]int{"a": 0, "b": 1, "c": 0} {
+		task, _ := s.GetTask(id)
+		if task.Priority != want {
+			t.Errorf("%s priority = %d, want %d", id, task.Priority, want)
+		}
+	}
+}
+
+// openTestFile opens a database file in a temp dir with opts.
+func openTestFile(t *testing.T, opts Options) (*Storage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tachyon.db")
+	s, err := Open(path, opts)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func TestOpen_AppliesOptionsToEveryConnection(t *testing.T) {
+	s, _ := openTestFile(t, Options{BusyTimeout: 1500 * time.Millisecond, MaxReaders: 2})
+
+	var timeout int
+	var mode string
+	s.DB.Raw("PRAGMA busy_timeout").Scan(&timeout)
+	s.DB.Raw("PRAGMA journal_mode").Scan(&mode)
+	if timeout != 1500 || mode != "wal" {
+		t.Errorf("writer: busy_timeout = %d, journal_mode = %q, want 1500 and wal", timeout, mode)
+	}
+	timeout = 0
+	s.read().Raw("PRAGMA busy_timeout").Scan(&timeout)
+	if timeout != 1500 {
+		t.Errorf("reader: busy_timeout = %d, want 1500", timeout)
+	}
+	if err := s.read().Exec("DELETE FROM app_settings").Error; err == nil {
+		t.Error("the read pool accepted a write")
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "x.db"), Options{BusyTimeout: -1}); err == nil {
+		t.Error("expected an error for a negative busy timeout")
+	}
+}
+
+func TestOpen_ConcurrentWritesDoNotLock(t *testing.T) {
+	s, _ := openTestFile(t, DefaultOptions())
+
+	const writers, rounds = 16, 40
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*rounds*3)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				id := fmt.Sprintf("task-%d", w)
+				if err := s.SaveTask(DownloadTask{ID: id, Downloaded: int64(i)}); err != nil {
+					errs <- fmt.Errorf("SaveTask: %w", err)
+				}
+				if err := s.IncrementDailyBytes(1); err != nil {
+					errs <- fmt.Errorf("IncrementDailyBytes: %w", err)
+				}
+				if _, err := s.GetTask(id); err != nil {
+					errs <- fmt.Errorf("GetTask: %w", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if total, _ := s.GetTotalLifetime(); total != writers*rounds {
+		t.Errorf("daily bytes = %d, want %d (an increment was lost)", total, writers*rounds)
+	}
+}
+
+func TestOpen_WaitsForAnotherWriter(t *testing.T) {
+	s, path := openTestFile(t, Options{BusyTimeout: 5 * time.Second})
+
+	// Another process holding the write lock for a moment
+	other, err := Open(path, Options{BusyTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	tx := other.DB.Begin()
+	if err := tx.Exec("INSERT INTO app_settings (key, value) VALUES ('held', '1')").Error; err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		tx.Commit()
+	}()
+
+	if err := s.SetString("mine", "1"); err != nil {
+		t.Fatalf("write failed instead of waiting for the lock: %v", err)
+	}
+	if v, _ := s.GetString("held"); v != "1" {
+		t.Errorf("held = %q, want the other writer's value", v)
+	}
+}
@@ -1,30 +1,55 @@
 package storage
 
+// Status is a download task's lifecycle state
+type Status string
+
+const (
+	StatusPending     Status = "pending"     // queued, waiting for a slot
+	StatusScheduled   Status = "scheduled"   // queued with a future StartTime
+	StatusProbing     Status = "probing"     // dispatched, probing the server
+	StatusDownloading Status = "downloading" // transferring data
+	StatusMerging     Status = "merging"     // assembling part files
+	StatusVerifying   Status = "verifying"   // checking the finished file
+	StatusCompleted   Status = "completed"
+	StatusPartial     Status = "partial" // stopped at its byte cap (MaxBytes); the file holds the head only
+	StatusPaused      Status = "paused"
+	StatusStopped     Status = "stopped" // stopped by the user; not auto-resumed
+	StatusError       Status = "error"
+	StatusNeedsAuth   Status = "needs_auth" // link expired (HTTP 403), needs a fresh URL
+)
+
 // DownloadTask represents a download task in the database
 type DownloadTask struct {
-	ID            string  `gorm:"primaryKey" json:"id"`
-	Filename      string  `json:"filename"`
-	URL           string  `json:"url"`
-	SavePath      string  `json:"save_path"`
-	Status        string  `gorm:"index" json:"status"`          // downloading, completed, paused, error, pending
-	Priority      int     `gorm:"default:1" json:"priority"`    // 0=Low, 1=Normal, 2=High
-	QueueOrder    int     `gorm:"default:0" json:"queue_order"` // Sequential order in queue
-	Category      string  `gorm:"index" json:"category"`
-	TotalSize     int64   `json:"total_size"`
-	Downloaded    int64   `json:"downloaded"`
-	Progress      float64 `json:"progress"`
-	Speed         float64 `json:"speed"` // bytes/sec
-	TimeRemaining string  `json:"time_remaining"`
-	MetaJSON      string  `json:"-"` // Store complex chunk data/headers as JSON
-	FileExists    bool    `gorm:"-" json:"file_exists"`
-	ExpectedHash  string  `json:"expected_hash"`
-	HashAlgorithm string  `json:"hash_algorithm"`
-	Headers       string  `json:"headers"`    // JSON serialized
-	Cookies       string  `json:"cookies"`    // JSON serialized
-	StartTime     string  `json:"start_time"` // ISO 8601 for scheduled start
-	Domain        string  `json:"domain"`     // e.g. "google.com" for concurrency limits
-	CreatedAt     string  `json:"created_at"`
-	UpdatedAt     string  `json:"updated_at"`
+	ID               string  `gorm:"primaryKey" json:"id"`
+	Filename         string  `json:"filename"`
+	URL              string  `json:"url"`
+	SavePath         string  `json:"save_path"`
+	Status           Status  `gorm:"index" json:"status"`          // downloading, completed, paused, error, pending
+	Priority         int     `gorm:"default:1" json:"priority"`    // 0=Low, 1=Normal, 2=High
+	QueueOrder       int     `gorm:"default:0" json:"queue_order"` // Sequential order in queue
+	Category         string  `gorm:"index" json:"category"`
+	TotalSize        int64   `json:"total_size"`
+	Downloaded       int64   `json:"downloaded"`
+	BytesTransferred int64   `json:"bytes_transferred"` // All bytes received, including parts re-fetched after errors
+	Progress         float64 `json:"progress"`
+	Speed            float64 `json:"speed"` // bytes/sec
+	TimeRemaining    string  `json:"time_remaining"`
+	MetaJSON         string  `json:"-"` // Store complex chunk data/headers as JSON
+	FileExists       bool    `gorm:"-" json:"file_exists"`
+	ExpectedHash     string  `json:"expected_hash"`
+	HashAlgorithm    string  `json:"hash_algorithm"`
+	Headers          string  `json:"headers"`             // JSON serialized
+	Cookies          string  `json:"cookies"`             // JSON serialized
+	StartTime        string  `json:"start_time"`          // ISO 8601 for scheduled start
+	Domain           string  `json:"domain"`              // e.g. "google.com" for concurrency limits
+	Connections      int     `json:"connections"`         // Pinned connection count; 0 = auto-tuned
+	ReplacePath      string  `json:"replace_path"`        // Existing file swapped out once the download verifies
+	ReplaceBackup    bool    `json:"replace_backup"`      // Keep the replaced file as <path>.bak
+	Debug            bool    `json:"debug"`               // Log exchanged headers (see GetDownloadDebugLog)
+	SetID            string  `gorm:"index" json:"set_id"` // Download set this task belongs to, if any
+	MaxBytes         int64   `json:"max_bytes"`           // Stop after this many bytes, keeping the head of the file; 0 = whole file
+	CreatedAt        string  `json:"created_at"`
+	UpdatedAt        string  `json:"updated_at"`
 }
 
 // TableName specifies the table name for DownloadTask
@@ -47,12 +72,20 @@ type ResumeState struct {
 	LastModified string            `json:"lm"`
 	TotalSize    int64             `json:"total_size"`
 	Parts        map[int]PartState `json:"parts"`
+
+	// Probe details, so a download resumed shortly after it was probed can
+	// skip probing again. ProbedAt is unix seconds, 0 = not recorded.
+	AcceptRanges bool   `json:"ar,omitempty"`
+	ContentType  string `json:"ct,omitempty"`
+	HTTP2        bool   `json:"h2,omitempty"`
+	ProbedAt     int64  `json:"pa,omitempty"`
 }
 
 // DownloadLocation stores saved download locations with nicknames
 type DownloadLocation struct {
-	Path     string `gorm:"primaryKey" json:"path"`
-	Nickname string `json:"nickname"` // e.g., "Gaming Drive", "SSD"
+	Path       string `gorm:"primaryKey" json:"path"`
+	Nickname   string `json:"nickname"`    // e.g., "Gaming Drive", "SSD"
+	WriteLimit int64  `json:"write_limit"` // disk write cap in bytes/sec, 0 = unlimited
 }
 
 // TableName specifies the table name for DownloadLocation
@@ -60,6 +93,36 @@ func (DownloadLocation) TableName() string {
 	return "download_locations"
 }
 
+// DownloadSet groups related downloads (an album, a dataset) that are
+// tracked and controlled together. Members point at it via SetID.
+type DownloadSet struct {
+	ID        string `gorm:"primaryKey" json:"id"`
+	Name      string `json:"name"`
+	Dir       string `json:"dir"`
+	CreatedAt string `json:"created_at"`
+}
+
+// TableName specifies the table name for DownloadSet
+func (DownloadSet) TableName() string {
+	return "download_sets"
+}
+
+// HostProfile is what the congestion controller learned about a host,
+// kept so the next download from it starts near its proven optimum.
+type HostProfile struct {
+	Host        string  `gorm:"primaryKey" json:"host"`
+	Concurrency int     `json:"concurrency"` // ideal connections per download
+	RTTMillis   int64   `json:"rtt_ms"`      // smoothed part request latency
+	ErrorRate   float64 `json:"error_rate"`  // share of recent part requests that failed
+	Downloads   int64   `json:"downloads"`   // download runs the profile was updated from
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+// TableName specifies the table name for HostProfile
+func (HostProfile) TableName() string {
+	return "host_profiles"
+}
+
 // DailyStat tracks daily download statistics for analytics
 type DailyStat struct {
 	Date  string `gorm:"primaryKey"` // Format: "YYYY-MM-DD"
@@ -1,17 +1,20 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 
 	"project-tachyon/internal/api"
 	"project-tachyon/internal/app"
 	"project-tachyon/internal/config"
 	"project-tachyon/internal/engine"
 	"project-tachyon/internal/logger"
+	"project-tachyon/internal/platform"
 	"project-tachyon/internal/security"
 	"project-tachyon/internal/storage"
 
@@ -19,6 +22,8 @@ import (
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	"github.com/wailsapp/wails/v2/pkg/options/mac"
+	"github.com/wailsapp/wails/v2/pkg/options/windows"
 )
 
 //go:embed all:frontend/dist
@@ -27,10 +32,13 @@ var assets embed.FS
 func main() {
 	// Parse Flags
 	mcpMode := false
+	serverMode := false
 	for _, arg := range os.Args {
-		if arg == "--mcp" {
+		switch arg {
+		case "--mcp":
 			mcpMode = true
-			break
+		case "--server":
+			serverMode = true
 		}
 	}
 
@@ -47,7 +55,11 @@ func main() {
 	}
 
 	// Initialize Storage
-	var store *storage.Storage
+	dbOptions, optErr := config.StorageOptionsFromEnv(os.LookupEnv)
+	if optErr != nil {
+		log.Warn("Ignoring invalid database settings", "error", optErr)
+	}
+	var dbPath string
 	testMode := os.Getenv("TACHYON_TEST_MODE") == "1"
 	if testMode {
 		testDir := os.Getenv("TACHYON_TEST_DIR")
@@ -55,14 +67,17 @@ func main() {
 			testDir = filepath.Join(os.TempDir(), "tachyon-test")
 		}
 		os.MkdirAll(testDir, 0755)
-		dbPath := filepath.Join(testDir, "test.db")
-		store, err = storage.NewStorageWithPath(dbPath)
+		dbPath = filepath.Join(testDir, "test.db")
 		// Override download path for tests
 		os.Setenv("TACHYON_DOWNLOAD_DIR", filepath.Join(testDir, "downloads"))
 		os.MkdirAll(filepath.Join(testDir, "downloads"), 0755)
 		log.Info("Test mode enabled", "dir", testDir)
 	} else {
-		store, err = storage.NewStorage()
+		dbPath, err = storage.DefaultPath()
+	}
+	var store *storage.Storage
+	if err == nil {
+		store, err = storage.Open(dbPath, dbOptions)
 	}
 	if err != nil {
 		log.Error("Error initializing storage", "error", err)
@@ -74,6 +89,17 @@ func main() {
 	// Initialize Core Components
 	eng := engine.NewEngine(log, store)
 	cfg := config.NewConfigManager(store)
+	// Environment variables (Docker, headless setups) win over stored settings
+	applied, err := cfg.ApplyEnv(os.LookupEnv)
+	if err != nil {
+		log.Warn("Ignoring invalid environment settings", "error", err)
+	}
+	if len(applied) > 0 {
+		log.Info("Settings overridden from the environment", "vars", applied)
+	}
+	// The desktop app applies them again in Startup; headless and MCP mode
+	// rely on this.
+	app.ApplySettings(cfg, eng, log)
 	audit := security.NewAuditLogger(log)
 	defer audit.Close()
 
@@ -88,14 +114,28 @@ func main() {
 		return
 	}
 
+	// Server Mode (headless: engine and control server only, e.g. Docker)
+	if serverMode {
+		if err := api.RunServer(controlServer, log); err != nil {
+			log.Error("Server mode failed", "error", err)
+			store.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
 	// GUI Mode (Wails)
 
 	// Create an instance of the app structure, injecting dependencies
 	application := app.NewApp(log, eng, wailsHandler, cfg, audit)
+	application.SetControlServer(controlServer)
 
-	// Handle standard OS signals (Ctrl+C) for graceful shutdown
+	// Handle OS signals (Ctrl+C, SIGTERM on logout or system shutdown). Every
+	// download's progress is saved first, as on a Windows suspend, so a kill
+	// moments later loses nothing; then the app shuts down gracefully.
 	engine.WaitForSignals(func() {
 		log.Info("OS Signal received, initiating shutdown...")
+		application.EmergencyStop()
 		application.QuitApp()
 	})
 
@@ -107,6 +147,17 @@ func main() {
 		}
 	}
 
+	// Register the tachyon:// scheme. Any link we were launched with is
+	// enqueued in Startup; if another instance is already running, the
+	// single-instance lock hands the arguments over to it instead (see
+	// HandleSecondInstance) and Startup never runs here.
+	if !testMode {
+		if err := platform.RegisterProtocolHandler(); err != nil {
+			log.Warn("Failed to register protocol handler", "error", err)
+		}
+	}
+	application.SetLaunchArgs(os.Args[1:])
+
 	// Start System Tray (Run in goroutine for Windows)
 	go func() {
 		systray.Run(func() {
@@ -120,15 +171,76 @@ func main() {
 
 			mOpen := systray.AddMenuItem("Open TDM", "Restore the window")
 			systray.AddSeparator()
+			mPauseAll := systray.AddMenuItem("Pause All", "Pause all downloads")
+			mResumeAll := systray.AddMenuItem("Resume All", "Resume all paused downloads")
+			mActive := systray.AddMenuItem("Active Downloads", "Downloads in progress")
+			systray.AddSeparator()
 			mQuit := systray.AddMenuItem("Quit", "Quit the application")
 
+			// systray cannot remove items, so a fixed set of slots is
+			// created up front and shown/hidden as downloads come and go.
+			slots := make([]*systray.MenuItem, app.MaxTrayDownloads)
+			slotIDs := make([]string, app.MaxTrayDownloads)
+			var slotMu sync.Mutex
+			for i := range slots {
+				slots[i] = mActive.AddSubMenuItem("", "Show this download")
+				slots[i].Hide()
+				go func(i int) {
+					for {
+						select {
+						case <-slots[i].ClickedCh:
+							slotMu.Lock()
+							id := slotIDs[i]
+							slotMu.Unlock()
+							if id != "" {
+								application.FocusDownload(id)
+							}
+						case <-application.Done():
+							return
+						}
+					}
+				}(i)
+			}
+
+			refresh := func() {
+				downloads := application.GetTrayDownloads(app.MaxTrayDownloads)
+				slotMu.Lock()
+				defer slotMu.Unlock()
+				for i, slot := range slots {
+					if i < len(downloads) {
+						slotIDs[i] = downloads[i].ID
+						slot.SetTitle(downloads[i].Label())
+						slot.Show()
+					} else {
+						slotIDs[i] = ""
+						slot.Hide()
+					}
+				}
+				if len(downloads) == 0 {
+					mActive.Disable()
+				} else {
+					mActive.Enable()
+				}
+			}
+			refresh()
+
 			go func() {
 				for {
 					select {
 					case <-mOpen.ClickedCh:
 						application.ShowApp()
+					case <-mPauseAll.ClickedCh:
+						application.PauseAllDownloads()
+						refresh()
+					case <-mResumeAll.ClickedCh:
+						application.ResumeAllDownloads()
+						refresh()
+					case <-application.TrayChanged():
+						refresh()
 					case <-mQuit.ClickedCh:
 						application.QuitApp()
+					case <-application.Done():
+						return
 					}
 				}
 			}()
@@ -146,14 +258,33 @@ func main() {
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
-		OnStartup:        application.Startup,
-		OnBeforeClose:    application.BeforeClose,
-		StartHidden:      startHidden,
+		OnStartup: func(ctx context.Context) {
+			application.Startup(ctx)
+			application.WatchTrayEvents(ctx)
+		},
+		OnBeforeClose: application.BeforeClose,
+		StartHidden:   startHidden,
+		Mac: &mac.Options{
+			OnUrlOpen: func(url string) {
+				application.HandleProtocolURL(url)
+			},
+		},
+		Windows: &windows.Options{
+			OnSuspend: application.EmergencyStop,
+			OnResume:  application.OnResume,
+		},
 		Bind: []interface{}{
 			application,
 		},
 	}
 
+	if !testMode {
+		appOpts.SingleInstanceLock = &options.SingleInstanceLock{
+			UniqueId:               "com.tachyon." + platform.AppID,
+			OnSecondInstanceLaunch: application.HandleSecondInstance,
+		}
+	}
+
 	err = wails.Run(appOpts)
 
 	if err != nil {